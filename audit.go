@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/audit"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// auditOptions are the filters and output format of the audit command
+type auditOptions struct {
+	since  string
+	until  string
+	step   string
+	kind   string
+	failed bool
+	output string
+}
+
+// NewAuditCommand creates the audit command
+func NewAuditCommand() *cobra.Command {
+	var opts auditOptions
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show the changes the agent made to this machine",
+		Long: "Show the audit trail of the commands, service operations and file writes the agent made to this machine, " +
+			"with the step that made them and their outcome, oldest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAudit(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.since, "since", "", "Only show changes after a time (RFC 3339) or within a duration, e.g. 24h")
+	cmd.Flags().StringVar(&opts.until, "until", "", "Only show changes before a time (RFC 3339) or a duration ago")
+	cmd.Flags().StringVar(&opts.step, "step", "", "Only show changes of a step, as listed by the steps list command")
+	cmd.Flags().StringVar(&opts.kind, "kind", "", "Only show changes of a kind: command, service or file")
+	cmd.Flags().BoolVar(&opts.failed, "failed", false, "Only show changes that failed")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "table", "Output format: table or json (one event per line)")
+
+	return cmd
+}
+
+// runAudit prints the audit trail events the options select
+func runAudit(opts auditOptions) error {
+	query, err := opts.query(time.Now())
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
+	path := audit.Path(cfg.Agent.LogDir)
+	events, skipped, err := audit.Read(path, query)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d malformed line(s) of %s\n", skipped, path)
+	}
+
+	if opts.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "TIME\tSTEP\tKIND\tOUTCOME\tCHANGE")
+	for _, event := range events {
+		step := event.Step
+		if step == "" {
+			step = "-"
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", event.Time.Local().Format(time.RFC3339), step, event.Kind,
+			event.Outcome, describeChange(event))
+	}
+	return writer.Flush()
+}
+
+// query returns the audit query of the options, resolving durations relative to now
+func (o auditOptions) query(now time.Time) (audit.Query, error) {
+	if o.output != "table" && o.output != "json" {
+		return audit.Query{}, fmt.Errorf("invalid --output %s. Valid values are: table, json", o.output)
+	}
+	switch o.kind {
+	case "", "command", "service", "file":
+	default:
+		return audit.Query{}, fmt.Errorf("invalid --kind %s. Valid values are: command, service, file", o.kind)
+	}
+	since, err := parseAuditTime("--since", o.since, now)
+	if err != nil {
+		return audit.Query{}, err
+	}
+	until, err := parseAuditTime("--until", o.until, now)
+	if err != nil {
+		return audit.Query{}, err
+	}
+	return audit.Query{Since: since, Until: until, Step: o.step, Kind: o.kind, Failed: o.failed}, nil
+}
+
+// parseAuditTime parses an RFC 3339 time, or a duration before now
+func parseAuditTime(flag, value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %s. Must be an RFC 3339 time or a duration such as 24h", flag, value)
+	}
+	return parsed, nil
+}
+
+// describeChange summarizes what an event changed, with the error of a failed change
+func describeChange(event audit.Event) string {
+	var change string
+	switch event.Kind {
+	case "file":
+		change = fmt.Sprintf("write %s (mode %s, sha256 %.12s)", event.Path, event.Mode, event.SHA256)
+	default:
+		change = event.Command
+	}
+	if event.Error != "" {
+		change += ": " + strings.Join(strings.Fields(event.Error), " ")
+	}
+	return change
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+)
+
+// NewCacheCommand creates the cache command with its subcommands
+func NewCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the component download cache",
+	}
+	cmd.AddCommand(newCachePurgeCommand())
+	return cmd
+}
+
+// newCachePurgeCommand creates the cache purge command
+func newCachePurgeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "purge",
+		Short: "Remove all cached component downloads",
+		Long:  fmt.Sprintf("Remove all component archives cached in %s, so the next bootstrap downloads them again", downloadcache.Dir),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			archives, freed, err := downloadcache.Purge()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d cached downloads, freed %.1f MB\n", archives, float64(freed)/(1024*1024))
+			return nil
+		},
+	}
+}
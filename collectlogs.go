@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/supportbundle"
+)
+
+// NewCollectLogsCommand creates the collect-logs command
+func NewCollectLogsCommand() *cobra.Command {
+	opts := supportbundle.Options{}
+
+	cmd := &cobra.Command{
+		Use:   "collect-logs",
+		Short: "Collect logs and configuration into a support bundle",
+		Long: "Collect the journals of the agent, kubelet, containerd and the Arc agent, the agent logs, status and state, " +
+			"the containerd and kubelet configuration, azcmagent output and the agent configuration into a timestamped " +
+			"tar.gz for support tickets. Secrets are redacted. Run as root to collect everything.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCollectLogs(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", ".", "Directory to write the support bundle to")
+	cmd.Flags().DurationVar(&opts.Since, "since", 24*time.Hour, "How far back to collect the journals")
+
+	return cmd
+}
+
+// runCollectLogs writes the support bundle and prints its path
+func runCollectLogs(ctx context.Context, opts supportbundle.Options) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	if opts.Since <= 0 {
+		return fmt.Errorf("invalid --since %s. Must be a positive duration", opts.Since)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
+	path, err := supportbundle.Collect(ctx, cfg, opts, logger)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Support bundle written to %s\n", path)
+	return nil
+}
@@ -1,54 +1,132 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroupdriver"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_proxy"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/npd"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/diagnostics"
+	"go.goms.io/aks/AKSFlexNode/pkg/discovery"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/healthcheck"
+	"go.goms.io/aks/AKSFlexNode/pkg/healthz"
 	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/metrics"
+	"go.goms.io/aks/AKSFlexNode/pkg/readiness"
+	"go.goms.io/aks/AKSFlexNode/pkg/schedule"
 	"go.goms.io/aks/AKSFlexNode/pkg/status"
-)
-
-// Version information variables (set at build time)
-var (
-	Version   = "dev"
-	GitCommit = "unknown"
-	BuildTime = "unknown"
+	"go.goms.io/aks/AKSFlexNode/pkg/update"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
 )
 
 // NewAgentCommand creates a new agent command
 func NewAgentCommand() *cobra.Command {
+	var overrides agentOverrides
+	var resume bootstrapper.ResumeOptions
+	var dryRun bool
+
 	cmd := &cobra.Command{
 		Use:   "agent",
 		Short: "Start AKS node agent with Arc connection",
 		Long:  "Initialize and run the AKS node agent daemon with automatic status tracking and self-recovery",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgent(cmd.Context())
+			if dryRun && (resume.Resume || resume.FromStep != "") {
+				return fmt.Errorf("--resume and --from-step cannot be combined with --dry-run")
+			}
+			if dryRun {
+				return runDryRun(cmd.Context(), "bootstrap", overrides)
+			}
+			return runAgent(cmd.Context(), overrides, resume)
 		},
 	}
 
+	cmd.Flags().BoolVar(&overrides.replaceNode, "replace-node", false, "Replace a Ready node with the same name already registered in the cluster")
+	cmd.Flags().BoolVar(&overrides.allowUnsupportedNetwork, "allow-unsupported-network", false, "Set up the node CNI even if the cluster network profile is incompatible")
+	cmd.Flags().BoolVar(&overrides.skipPreflight, "skip-preflight", false, "Bootstrap even when critical host preflight checks fail, as listed by the preflight command")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the bootstrap steps and the changes they would make without changing the machine, then exit")
+	cmd.Flags().BoolVar(&resume.Resume, "resume", false, "Skip the bootstrap steps that succeeded in the previous bootstrap with the same configuration, restarting from the failed step")
+	cmd.Flags().StringVar(&resume.FromStep, "from-step", "", "Skip the bootstrap steps before the named step, as listed by the steps list command")
+
 	return cmd
 }
 
 // NewUnbootstrapCommand creates a new unbootstrap command
 func NewUnbootstrapCommand() *cobra.Command {
+	var dryRun bool
+
 	cmd := &cobra.Command{
 		Use:   "unbootstrap",
 		Short: "Remove AKS node configuration and Arc connection",
 		Long:  "Clean up and remove all AKS node components and Arc registration from this machine",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if dryRun {
+				return runDryRun(cmd.Context(), "unbootstrap", agentOverrides{})
+			}
 			return runUnbootstrap(cmd.Context())
 		},
 	}
 
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the unbootstrap steps and the changes they would make without changing the machine")
+
+	return cmd
+}
+
+// NewCleanupOrphansCommand creates a new cleanup-orphans command
+func NewCleanupOrphansCommand() *cobra.Command {
+	var assumeYes bool
+
+	cmd := &cobra.Command{
+		Use:   "cleanup-orphans",
+		Short: "Remove role assignments left behind by deleted Arc machines",
+		Long:  "Scan the target cluster scope for role assignments created for Arc machines that no longer exist and remove them after confirmation",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCleanupOrphans(cmd.Context(), assumeYes)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Remove orphaned role assignments without asking for confirmation")
+
+	return cmd
+}
+
+// NewNpdCheckCommand creates the npd-check command invoked by the node-problem-detector custom plugin monitor
+func NewNpdCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "npd-check <problem>",
+		Short:     "Check for an agent-specific node problem",
+		Long:      "Check for an agent-specific node problem and exit with the node-problem-detector custom plugin status code",
+		Hidden:    true,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{npd.ProblemBootstrap, npd.ProblemCertificate, npd.ProblemArc},
+		Run: func(cmd *cobra.Command, args []string) {
+			result := npd.RunCheck(cmd.Context(), args[0])
+			fmt.Println(result.Message)
+			os.Exit(result.Status)
+		},
+	}
+
 	return cmd
 }
 
@@ -66,29 +144,71 @@ func NewVersionCommand() *cobra.Command {
 	return cmd
 }
 
-// runAgent executes the bootstrap process and then runs as daemon
-func runAgent(ctx context.Context) error {
+// agentOverrides holds agent command flags that override configuration values
+type agentOverrides struct {
+	replaceNode             bool
+	allowUnsupportedNetwork bool
+	skipPreflight           bool
+}
+
+// apply sets the overridden values on a freshly loaded configuration
+func (o agentOverrides) apply(cfg *config.Config) {
+	if o.replaceNode {
+		cfg.Node.ReplaceExisting = true
+	}
+	if o.allowUnsupportedNetwork {
+		cfg.CNI.AllowUnsupportedNetwork = true
+	}
+	if o.skipPreflight {
+		cfg.Agent.SkipPreflight = true
+	}
+}
+
+// runAgent executes the bootstrap process, resuming an earlier bootstrap as selected by resume, and then runs as daemon
+func runAgent(ctx context.Context, overrides agentOverrides, resume bootstrapper.ResumeOptions) error {
 	logger := logger.GetLoggerFromContext(ctx)
+	logger.Infof("Starting AKS Flex Node Agent %s", buildinfo.Get())
+	if err := readiness.RecordAgentStart(time.Now()); err != nil {
+		logger.Warnf("Failed to record agent start: %v", err)
+	}
+
+	// Register for daemon signals before bootstrap so they are queued instead of terminating the agent
+	signals := notifyDaemonSignals()
+	defer stopDaemonSignals(signals)
+
+	componentDefaults, err := defaults.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load component defaults: %w", err)
+	}
+	if componentDefaults.Overridden {
+		logger.Infof("Using component defaults overridden by %s", defaults.Path)
+	}
 
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
 	}
+	overrides.apply(cfg)
+	applyCachedSiteTags(ctx, cfg)
 
 	bootstrapExecutor := bootstrapper.New(cfg, logger)
-	result, err := bootstrapExecutor.Bootstrap(ctx)
+	startedAt := time.Now()
+	result, err := bootstrapExecutor.ResumeBootstrap(ctx, resume)
 	if err != nil {
+		recordBootstrapOutcome(ctx, startedAt, err)
 		return err
 	}
 
 	// Handle and log the bootstrap result
 	if err := handleExecutionResult(result, "bootstrap", logger); err != nil {
+		recordBootstrapOutcome(ctx, startedAt, err)
 		return err
 	}
+	recordBootstrapOutcome(ctx, startedAt, nil)
 
 	// After successful bootstrap, transition to daemon mode
 	logger.Info("Bootstrap completed successfully, transitioning to daemon mode...")
-	return runDaemonLoop(ctx, cfg)
+	return runDaemonLoop(ctx, cfg, overrides, signals, result)
 }
 
 // runUnbootstrap executes the unbootstrap process
@@ -110,16 +230,137 @@ func runUnbootstrap(ctx context.Context) error {
 	return handleExecutionResult(result, "unbootstrap", logger)
 }
 
+// runCleanupOrphans removes role assignments of deleted Arc machines from the target cluster scope
+func runCleanupOrphans(ctx context.Context, assumeYes bool) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	cleaner := arc.NewOrphanCleaner(logger)
+	orphans, err := cleaner.FindOrphanedRoleAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned role assignments: %w", err)
+	}
+	if len(orphans) == 0 {
+		fmt.Println("No orphaned role assignments found")
+		return nil
+	}
+
+	fmt.Printf("Found %d orphaned role assignment(s):\n", len(orphans))
+	for _, orphan := range orphans {
+		fmt.Printf("  - %s: %s for deleted Arc machine %s (principal %s)\n", orphan.Name, orphan.RoleName, orphan.MachineName, orphan.PrincipalID)
+	}
+
+	if !assumeYes {
+		fmt.Print("Remove these role assignments? [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Aborted, no role assignments were removed")
+			return nil
+		}
+	}
+
+	if err := cleaner.RemoveRoleAssignments(ctx, orphans); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d orphaned role assignment(s)\n", len(orphans))
+	return nil
+}
+
 // runVersion displays version information
 func runVersion() {
+	build := buildinfo.Get()
 	fmt.Printf("AKS Flex Node Agent\n")
-	fmt.Printf("Version: %s\n", Version)
-	fmt.Printf("Git Commit: %s\n", GitCommit)
-	fmt.Printf("Build Time: %s\n", BuildTime)
+	fmt.Printf("Version: %s\n", build.Version)
+	fmt.Printf("Git Commit: %s\n", build.GitCommit)
+	fmt.Printf("Build Time: %s\n", build.BuildTime)
+	fmt.Printf("Go Version: %s\n", build.GoVersion)
+	fmt.Printf("Platform: %s\n", build.Platform)
+}
+
+// Intervals of the periodic daemon tasks
+const (
+	statusInterval         = 1 * time.Minute
+	bootstrapCheckInterval = 2 * time.Minute
+)
+
+// maxBootstrapRetryInterval caps the backoff between auto-bootstrap retries after transient failures
+const maxBootstrapRetryInterval = 30 * time.Minute
+
+// bootstrapBackoff tracks the auto-bootstrap failures of the daemon. Transient and unknown failures are
+// retried with exponential backoff, a permanent failure stops auto-bootstrap until the configuration is
+// reloaded, the agent restarts or the node recovers by other means.
+type bootstrapBackoff struct {
+	failures  int   // consecutive failed auto-bootstraps
+	permanent error // the last failure, when it was permanent
+}
+
+// record updates the backoff with the outcome of an auto-bootstrap
+func (b *bootstrapBackoff) record(err error) {
+	if err == nil {
+		*b = bootstrapBackoff{}
+		return
+	}
+	b.failures++
+	if failure.Classify(err) == failure.ClassPermanent {
+		b.permanent = err
+	}
+}
+
+// nextCheck returns the delay until the next bootstrap check, doubling the check interval with each
+// consecutive failure up to maxBootstrapRetryInterval
+func (b *bootstrapBackoff) nextCheck() time.Duration {
+	delay := bootstrapCheckInterval
+	for i := 1; i < b.failures && delay < maxBootstrapRetryInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBootstrapRetryInterval)
+}
+
+// remediation describes the self-recovery state of the daemon for its health endpoint
+func (b *bootstrapBackoff) remediation(recovery credentialRecovery) healthz.Remediation {
+	remediation := healthz.Remediation{BootstrapFailures: b.failures, CredentialResets: recovery.resets}
+	if b.permanent != nil {
+		remediation.BootstrapBlocked = b.permanent.Error()
+	}
+	return remediation
+}
+
+// healthzListeners returns where the daemon serves its health, false when the endpoint is disabled
+func healthzListeners(cfg *config.Config) (string, string, bool) {
+	settings := cfg.Agent.Healthz
+	if settings == nil {
+		return healthz.DefaultSocketPath, "", true
+	}
+	if settings.Disabled {
+		return "", "", false
+	}
+	socketPath := settings.SocketPath
+	if socketPath == "" {
+		socketPath = healthz.DefaultSocketPath
+	}
+	return socketPath, settings.Address, true
 }
 
-// runDaemonLoop runs the periodic status collection and bootstrap monitoring daemon
-func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
+// Kubelet client credential recovery: the certificate is reset after it was rejected on consecutive bootstrap checks,
+// and recovery stops after repeated resets did not help, as the bootstrap credential itself is then likely rejected
+const (
+	credentialRejectionThreshold = 3
+	maxCredentialResets          = 3
+)
+
+// credentialRecovery tracks the kubelet client certificate rejections seen by the daemon
+type credentialRecovery struct {
+	rejections int // consecutive checks that found the certificate rejected
+	resets     int // certificate resets since the certificate was last accepted
+}
+
+// certificateRotation remembers the expiry of each kubelet certificate the daemon reset because kubelet did not
+// rotate it, keyed by client or serving
+type certificateRotation map[string]time.Time
+
+// runDaemonLoop runs the periodic status collection and bootstrap monitoring daemon, bootstrapped is the result
+// of the bootstrap that preceded it
+func runDaemonLoop(ctx context.Context, cfg *config.Config, overrides agentOverrides, signals <-chan os.Signal,
+	bootstrapped *bootstrapper.ExecutionResult) error {
 	logger := logger.GetLoggerFromContext(ctx)
 	// Create status file directory - using runtime directory for service or temp for development
 	statusFilePath := status.GetStatusFilePath()
@@ -128,8 +369,16 @@ func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
 		return fmt.Errorf("failed to create status directory %s: %w", statusDir, err)
 	}
 
-	// Clean up any stale status file on daemon startup
-	if _, err := os.Stat(statusFilePath); err == nil {
+	// Resume the task schedule of the previous daemon session, so restarts do not re-run every task at once
+	sched, err := schedule.Load()
+	if err != nil {
+		logger.Warnf("Failed to load daemon schedule, running all tasks now: %v", err)
+	}
+	now := time.Now()
+	statusDelay := sched.Delay(schedule.TaskStatus, statusInterval, now)
+
+	// Clean up any stale status file on daemon startup, a status file collected within the interval is kept
+	if _, err := os.Stat(statusFilePath); err == nil && statusDelay == 0 {
 		logger.Info("Removing stale status file from previous daemon session...")
 		if err := os.Remove(statusFilePath); err != nil {
 			logger.Warnf("Failed to remove stale status file: %v", err)
@@ -138,79 +387,566 @@ func runDaemonLoop(ctx context.Context, cfg *config.Config) error {
 		}
 	}
 
-	logger.Info("Starting periodic status collection daemon (status: 1 minutes, bootstrap check: 2 minute)")
+	logger.Infof("Starting periodic status collection daemon (status: %s, bootstrap check: %s)", statusInterval, bootstrapCheckInterval)
+
+	// Create timers for the periodic tasks, each is reset to its interval after it runs
+	statusTimer := time.NewTimer(statusDelay)
+	bootstrapTimer := time.NewTimer(sched.Delay(schedule.TaskBootstrap, bootstrapCheckInterval, now))
+	defer statusTimer.Stop()
+	defer bootstrapTimer.Stop()
+	// Failed auto-bootstraps lengthen the bootstrap check interval
+	var backoff bootstrapBackoff
+	var recovery credentialRecovery
+	var upgrade kubernetesUpgrade
+	rotation := certificateRotation{}
+	// Fleet labels last applied to the running node
+	var fleetLabels map[string]string
+
+	// Serve Prometheus metrics when an address is configured
+	if cfg.Agent.MetricsAddress != "" {
+		go func() {
+			if err := metrics.Serve(ctx, cfg.Agent.MetricsAddress, logger); err != nil {
+				logger.Errorf("Metrics endpoint stopped: %v", err)
+			}
+		}()
+	}
 
-	// Create tickers for different intervals
-	statusTicker := time.NewTicker(1 * time.Minute)
-	bootstrapTicker := time.NewTicker(2 * time.Minute)
-	defer statusTicker.Stop()
-	defer bootstrapTicker.Stop()
+	// Report the daemon's own health to external watchdogs unless disabled
+	health := healthz.NewTracker()
+	if socketPath, address, ok := healthzListeners(cfg); ok {
+		go func() {
+			if err := healthz.Serve(ctx, health, socketPath, address, logger); err != nil {
+				logger.Errorf("Daemon health endpoint stopped: %v", err)
+			}
+		}()
+	}
+
+	// Serve read-only diagnostics to local tools when an address is configured
+	diag := diagnostics.NewServer(health, statusFilePath, cfg)
+	diag.RecordBootstrap(bootstrapped, nil)
+	if cfg.Agent.DiagnosticsAddress != "" {
+		go func() {
+			if err := diag.Serve(ctx, cfg.Agent.DiagnosticsAddress, logger); err != nil {
+				logger.Errorf("Diagnostics endpoint stopped: %v", err)
+			}
+		}()
+	}
 
-	// Collect status immediately on start
-	if err := collectAndWriteStatus(ctx, cfg, statusFilePath); err != nil {
-		logger.Errorf("Failed to collect initial status: %v", err)
+	// Compare the agent version with the release channel when due and then periodically
+	var updateCheck updateCheckTimer
+	updateCheck.configure(cfg, sched, now)
+	defer updateCheck.stop()
+
+	// Inherit site settings from the Arc machine tags when due and then periodically
+	siteTagsTimer := time.NewTimer(sched.Delay(schedule.TaskSiteTags, siteTagsRefreshInterval, now))
+	defer siteTagsTimer.Stop()
+
+	// Reload the configuration when its file changes and reconfigure the components whose settings changed
+	reload := newConfigReload(configPath)
+	configWatchTicker := time.NewTicker(configWatchInterval)
+	defer configWatchTicker.Stop()
+	reconfigure := func(reloaded *config.Config) {
+		if reloaded == cfg {
+			return
+		}
+		if backoff.failures > 0 {
+			// The new configuration may fix what failed, so retry it right away
+			logger.Info("Configuration reloaded, resetting the auto-bootstrap backoff")
+			backoff = bootstrapBackoff{}
+			bootstrapTimer.Reset(0)
+		}
+		reload.plan(ctx, cfg, reloaded)
+		reload.apply(ctx, reloaded)
+		cfg = reloaded
+		diag.SetConfig(cfg)
+		updateCheck.configure(cfg, sched, time.Now())
 	}
 
 	// Run the periodic collection and monitoring loop
 	for {
+		health.Beat()
 		select {
 		case <-ctx.Done():
 			logger.Info("Daemon shutting down due to context cancellation")
 			return ctx.Err()
-		case <-statusTicker.C:
+		case <-statusTimer.C:
 			logger.Infof("Starting periodic status collection at %s...", time.Now().Format("2006-01-02 15:04:05"))
-			if err := collectAndWriteStatus(ctx, cfg, statusFilePath); err != nil {
+			health.Begin(schedule.TaskStatus)
+			err := collectAndWriteStatus(ctx, cfg, statusFilePath)
+			health.StatusCollected(err)
+			health.End()
+			if err != nil {
 				logger.Errorf("Failed to collect status at %s: %v", time.Now().Format("2006-01-02 15:04:05"), err)
 				// Continue running even if status collection fails
 			} else {
 				logger.Infof("Status collection completed successfully at %s", time.Now().Format("2006-01-02 15:04:05"))
 			}
-		case <-bootstrapTicker.C:
+			recordTaskRun(ctx, sched, schedule.TaskStatus)
+			statusTimer.Reset(statusInterval)
+		case <-bootstrapTimer.C:
 			logger.Infof("Starting bootstrap health check at %s...", time.Now().Format("2006-01-02 15:04:05"))
-			if err := checkAndBootstrap(ctx, cfg); err != nil {
+			health.Begin(schedule.TaskBootstrap)
+			if err := checkAndBootstrap(ctx, cfg, &backoff, diag); err != nil {
 				logger.Errorf("Auto-bootstrap check failed at %s: %v", time.Now().Format("2006-01-02 15:04:05"), err)
 				// Continue running even if bootstrap check fails
 			} else {
 				logger.Infof("Bootstrap health check completed at %s", time.Now().Format("2006-01-02 15:04:05"))
 			}
+			// Only a bootstrapped node is upgraded, a node still failing to bootstrap re-bootstraps on the cluster's version
+			if backoff.failures == 0 {
+				checkKubernetesUpgrade(ctx, cfg, &upgrade)
+			}
+			checkCgroupDrivers(ctx, cfg)
+			checkClientCredential(ctx, cfg, &recovery)
+			checkKubeletCertificates(ctx, cfg, rotation)
+			approveServingCertificates(ctx, cfg)
+			renewKubeProxyToken(ctx, cfg)
+			reconcileFleetLabels(ctx, cfg, &fleetLabels)
+			reconcileNode(ctx, cfg, nil)
+			reload.apply(ctx, cfg)
+			health.SetRemediation(backoff.remediation(recovery))
+			health.End()
+			recordTaskRun(ctx, sched, schedule.TaskBootstrap)
+			bootstrapTimer.Reset(backoff.nextCheck())
+		case <-updateCheck.C():
+			checkForUpdate(ctx, cfg)
+			recordTaskRun(ctx, sched, schedule.TaskUpdateCheck)
+			updateCheck.reset()
+		case <-siteTagsTimer.C:
+			cfg = refreshSiteTags(ctx, cfg, overrides)
+			recordTaskRun(ctx, sched, schedule.TaskSiteTags)
+			siteTagsTimer.Reset(siteTagsRefreshInterval)
+		case <-configWatchTicker.C:
+			if reload.fileChanged() {
+				logger.Infof("Configuration file %s changed, reloading configuration", configPath)
+				reconfigure(reloadConfig(ctx, cfg, overrides))
+			}
+		case sig := <-signals:
+			reconfigure(handleDaemonSignal(ctx, sig, cfg, overrides))
+			if sig == syscall.SIGHUP {
+				// SIGHUP reloaded the current file, the watcher only reloads it after its next change
+				reload.fileChanged()
+			}
 		}
 	}
 }
 
-// checkAndBootstrap checks if the node needs re-bootstrapping and performs it if necessary
-func checkAndBootstrap(ctx context.Context, cfg *config.Config) error {
+// checkAndBootstrap checks if the node needs re-bootstrapping and performs it if necessary,
+// recording the outcome in backoff and diag
+func checkAndBootstrap(ctx context.Context, cfg *config.Config, backoff *bootstrapBackoff, diag *diagnostics.Server) error {
 	logger := logger.GetLoggerFromContext(ctx)
 	// Create status collector to check bootstrap requirements
-	collector := status.NewCollector(cfg, logger, Version)
+	collector := status.NewCollector(cfg, logger)
 
 	// Check if bootstrap is needed
 	needsBootstrap := collector.NeedsBootstrap(ctx)
 	if !needsBootstrap {
+		backoff.record(nil)
 		return nil // All good, no action needed
 	}
 
+	// Retrying a permanent failure fails the same way, an operator has to fix the host or the configuration first
+	if backoff.permanent != nil {
+		logger.Errorf("Node requires re-bootstrapping, but auto-bootstrap stopped after a permanent failure, "+
+			"fix the cause and reload the configuration or restart the agent: %v", backoff.permanent)
+		return nil
+	}
+
+	// Re-bootstrapping restarts kubelet, so it waits for the maintenance policy to permit it
+	if permitted, reason := maintenancePermits(cfg, config.MaintenanceActionRebootstrap); !permitted {
+		logger.Warnf("Node requires re-bootstrapping, deferring auto-bootstrap: %s", reason)
+		return nil
+	}
+
+	// Re-bootstrapping cannot succeed while a site dependency such as a VPN tunnel is down
+	if failing := failingGateChecks(ctx, cfg); len(failing) > 0 {
+		for _, result := range failing {
+			logger.Warnf("Node requires re-bootstrapping, deferring auto-bootstrap until health check %s passes: %s", result.Name, result.Message)
+		}
+		return nil
+	}
+
 	logger.Info("Node requires re-bootstrapping, initiating auto-bootstrap...")
 
 	// Perform bootstrap
 	bootstrapExecutor := bootstrapper.New(cfg, logger)
+	startedAt := time.Now()
 	result, err := bootstrapExecutor.Bootstrap(ctx)
+	diag.RecordBootstrap(result, err)
 	if err != nil {
 		// Bootstrap failed - remove status file so next check will detect the problem
 		removeStatusFile(ctx)
-		return fmt.Errorf("auto-bootstrap failed: %s", err)
+		recordBootstrapOutcome(ctx, startedAt, err)
+		backoff.record(err)
+		return fmt.Errorf("auto-bootstrap failed (%s, next check in %s): %s", failure.Classify(err), backoff.nextCheck(), err)
 	}
 
 	// Handle and log the bootstrap result
 	if err := handleExecutionResult(result, "auto-bootstrap", logger); err != nil {
 		// Bootstrap execution failed - remove status file so next check will detect the problem
 		removeStatusFile(ctx)
+		recordBootstrapOutcome(ctx, startedAt, err)
+		backoff.record(err)
 		return fmt.Errorf("auto-bootstrap execution failed: %s", err)
 	}
 
+	recordBootstrapOutcome(ctx, startedAt, nil)
+	backoff.record(nil)
 	logger.Info("Auto-bootstrap completed successfully")
 	return nil
 }
 
+// checkCgroupDrivers detects kubelet and containerd cgroup drivers drifting apart, for example when another tool
+// rewrites the containerd configuration, and restores the configuration the agent renders when the maintenance policy permits it
+func checkCgroupDrivers(ctx context.Context, cfg *config.Config) {
+	logger := logger.GetLoggerFromContext(ctx)
+	report := cgroupdriver.Detect()
+	if !report.Mismatch() {
+		return
+	}
+	logger.Warnf("Cgroup driver mismatch, pods cannot start: %s", report)
+
+	// The agent configures both with the systemd driver, a kubelet on another driver was not configured by this agent
+	if report.Kubelet != cgroupdriver.Systemd {
+		logger.Warnf("Kubelet is not configured with the systemd cgroup driver, re-bootstrap the node to restore its configuration")
+		return
+	}
+	if permitted, reason := maintenancePermits(cfg, config.MaintenanceActionContainerdRepair); !permitted {
+		logger.Warnf("Deferring containerd configuration repair: %s", reason)
+		return
+	}
+
+	logger.Info("Restoring the containerd configuration and restarting containerd and kubelet")
+	if err := containerd.RepairConfig(logger); err != nil {
+		logger.Errorf("Failed to repair the containerd configuration: %v", err)
+		return
+	}
+	if err := sysutil.RestartService("kubelet"); err != nil {
+		logger.Errorf("Failed to restart kubelet after repairing the containerd configuration: %v", err)
+		return
+	}
+	logger.Info("Containerd configuration repaired, kubelet and containerd use the systemd cgroup driver")
+}
+
+// checkClientCredential detects the API server persistently rejecting the kubelet client certificate, which leaves
+// the node NotReady until the certificate is replaced, and resets it so kubelet requests a new one through TLS bootstrap.
+// Kubelet cannot reach the API server with a rejected certificate, so the reset does not wait for the maintenance window.
+func checkClientCredential(ctx context.Context, cfg *config.Config, recovery *credentialRecovery) {
+	logger := logger.GetLoggerFromContext(ctx)
+	reason := kubelet.CheckClientCredential(ctx, cfg.GetNodeName())
+	if reason == "" {
+		*recovery = credentialRecovery{}
+		return
+	}
+	recovery.rejections++
+	if recovery.rejections < credentialRejectionThreshold {
+		logger.Warnf("Kubelet client certificate rejected (%d of %d checks before reset): %s", recovery.rejections, credentialRejectionThreshold, reason)
+		return
+	}
+	if recovery.resets >= maxCredentialResets {
+		logger.Errorf("Kubelet client certificate still rejected after %d resets, the bootstrap credential may be rejected as well, "+
+			"check the node's authentication configuration and re-bootstrap the node: %s", recovery.resets, reason)
+		return
+	}
+
+	logger.Warnf("Kubelet client certificate persistently rejected, resetting it to re-run TLS bootstrap: %s", reason)
+	recovery.rejections = 0
+	recovery.resets++
+	if err := kubelet.ResetClientCredential(logger); err != nil {
+		logger.Errorf("Failed to reset the kubelet client certificate: %v", err)
+		return
+	}
+	logger.Info("Kubelet client certificate reset, kubelet is requesting a new certificate through TLS bootstrap")
+}
+
+// checkKubeletCertificates resets a kubelet certificate that kubelet did not rotate although 90% of its validity
+// passed, so kubelet requests a new one before the old one expires. Each certificate is reset once: when kubelet
+// cannot get a new one, the daemon logs an error instead of restarting kubelet again. An expired client certificate
+// is left to the next auto-bootstrap, which restarts kubelet into TLS bootstrap. Resets restart kubelet, so they wait
+// for the maintenance policy to permit them.
+func checkKubeletCertificates(ctx context.Context, cfg *config.Config, rotation certificateRotation) {
+	logger := logger.GetLoggerFromContext(ctx)
+	now := time.Now()
+	certs, err := kubelet.ReadCertificates(now)
+	if err != nil {
+		logger.Warnf("Failed to read kubelet certificates: %v", err)
+		return
+	}
+	if certs == nil {
+		return
+	}
+
+	resets := []struct {
+		name   string
+		expiry *kubelet.CertificateExpiry
+		reset  func(*logrus.Logger) error
+	}{
+		{name: "client", expiry: certs.Client, reset: kubelet.ResetClientCredential},
+		{name: "serving", expiry: certs.Serving, reset: kubelet.ResetServingCertificate},
+	}
+	for _, cert := range resets {
+		if cert.expiry == nil || !cert.expiry.RotationOverdue || (cert.name == "client" && cert.expiry.Expired) {
+			continue
+		}
+		if rotation[cert.name].Equal(cert.expiry.NotAfter) {
+			logger.Errorf("Kubelet %s certificate expiring at %s was reset but not renewed, check the kubelet log and the certificate signing requests of the node",
+				cert.name, cert.expiry.NotAfter.Format(time.RFC3339))
+			continue
+		}
+		if permitted, reason := maintenancePermits(cfg, config.MaintenanceActionCertificateReset); !permitted {
+			logger.Warnf("Kubelet did not rotate its %s certificate expiring at %s, deferring its reset: %s",
+				cert.name, cert.expiry.NotAfter.Format(time.RFC3339), reason)
+			continue
+		}
+
+		logger.Warnf("Kubelet did not rotate its %s certificate expiring at %s, resetting it to request a new one",
+			cert.name, cert.expiry.NotAfter.Format(time.RFC3339))
+		rotation[cert.name] = cert.expiry.NotAfter
+		if err := cert.reset(logger); err != nil {
+			logger.Errorf("Failed to reset the kubelet %s certificate: %v", cert.name, err)
+			continue
+		}
+		logger.Infof("Kubelet %s certificate reset, kubelet is requesting a new certificate", cert.name)
+	}
+}
+
+// approveServingCertificates approves the serving certificate requests kubelet makes when it rotates its serving
+// certificate, which would otherwise wait until the certificate expires and metrics-server can no longer scrape the node
+func approveServingCertificates(ctx context.Context, cfg *config.Config) {
+	if !cfg.Node.Kubelet.ApproveServingCSR {
+		return
+	}
+	logger := logger.GetLoggerFromContext(ctx)
+	approved, err := kubelet.ApproveServingCSRs(ctx, cfg.GetNodeName(), logger)
+	if err != nil {
+		logger.Warnf("Failed to approve kubelet serving certificate requests: %v", err)
+		return
+	}
+	if len(approved) > 0 {
+		logger.Infof("Approved kubelet serving certificate requests %s", strings.Join(approved, ", "))
+	}
+}
+
+// renewKubeProxyToken renews the bound service account token of the kube-proxy the agent runs before it expires
+func renewKubeProxyToken(ctx context.Context, cfg *config.Config) {
+	logger := logger.GetLoggerFromContext(ctx)
+	renewed, err := kube_proxy.RenewToken(ctx, cfg, logger)
+	if err != nil {
+		logger.Warnf("Failed to renew the kube-proxy token: %v", err)
+		return
+	}
+	if renewed {
+		logger.Info("Renewed the kube-proxy service account token")
+	}
+}
+
+// reconcileFleetLabels keeps the fleet labels of the running node in line with the discovered hardware, the site and
+// the agent version, which change without a re-bootstrap when hardware is swapped, site tags change or the agent updates.
+// Kubelet only applies its node labels at registration, so the running node is labeled directly.
+func reconcileFleetLabels(ctx context.Context, cfg *config.Config, applied *map[string]string) {
+	logger := logger.GetLoggerFromContext(ctx)
+	if !cfg.Node.FleetLabels || !fsutil.FileExists(kubelet.KubeletKubeconfigPath) {
+		return
+	}
+
+	labels := discovery.FleetLabels(cfg, discovery.Detect("/"))
+	// Labels set in node.labels take precedence over discovered ones
+	for key := range cfg.Node.Labels {
+		delete(labels, key)
+	}
+	if maps.Equal(labels, *applied) {
+		return
+	}
+
+	var removed []string
+	for _, key := range discovery.FleetLabelKeys {
+		_, current := labels[key]
+		_, configured := cfg.Node.Labels[key]
+		if !current && !configured {
+			removed = append(removed, key)
+		}
+	}
+	if err := kubelet.ApplyNodeLabels(cfg, labels, removed, logger); err != nil {
+		logger.Warnf("Failed to apply fleet labels to the node: %v", err)
+		return
+	}
+	*applied = labels
+	logger.Infof("Applied fleet labels to the node: %s", fleetLabelSummary(labels))
+}
+
+// reconcileNode keeps the labels and taints of the running node in line with node.labels and node.taints, which
+// kubelet only applies when the node registers. Labels and taints the configuration does not set are removed when
+// their key starts with one of node.managedPrefixes, or when previous, the configuration before a reload, set them.
+func reconcileNode(ctx context.Context, cfg, previous *config.Config) {
+	logger := logger.GetLoggerFromContext(ctx)
+	if !fsutil.FileExists(kubelet.NodeKubeconfigPath()) {
+		return
+	}
+
+	managed := func(key string) bool {
+		// Fleet labels are reconciled on their own
+		if cfg.Node.FleetLabels && slices.Contains(discovery.FleetLabelKeys, key) {
+			return false
+		}
+		if previous != nil && configuresNodeKey(previous, key) {
+			return true
+		}
+		return cfg.IsManagedNodeKey(key)
+	}
+	sync, err := kubelet.SyncNode(ctx, cfg, managed, logger)
+	if err != nil {
+		logger.Warnf("Failed to reconcile node labels and taints: %v", err)
+		return
+	}
+	if !sync.IsEmpty() {
+		logger.Infof("Reconciled node labels and taints: %s", sync)
+	}
+}
+
+// configuresNodeKey returns true when the configuration sets a node label or taint with the key
+func configuresNodeKey(cfg *config.Config, key string) bool {
+	if _, ok := cfg.Node.Labels[key]; ok {
+		return true
+	}
+	return slices.ContainsFunc(cfg.Node.Taints, func(taint string) bool {
+		parsed, err := kubelet.ParseTaint(taint)
+		return err == nil && parsed.Key == key
+	})
+}
+
+// fleetLabelSummary renders labels as sorted key=value pairs for logging
+func fleetLabelSummary(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// updateCheckInterval returns the configured update check interval, false without an update check
+func updateCheckInterval(cfg *config.Config) (time.Duration, bool) {
+	if cfg.Agent.UpdateCheck == nil {
+		return 0, false
+	}
+	// The interval was validated when the configuration was loaded
+	interval, err := time.ParseDuration(cfg.Agent.UpdateCheck.Interval)
+	if err != nil || interval <= 0 {
+		interval = 6 * time.Hour
+	}
+	return interval, true
+}
+
+// updateCheckTimer fires when the agent version is due to be compared with the release channel. Without an
+// update check its channel is nil and never fires.
+type updateCheckTimer struct {
+	timer    *time.Timer
+	interval time.Duration // zero without an update check
+}
+
+// configure starts, restarts or stops the timer when the update check interval of cfg differs from the one it
+// runs with. A started timer fires when the check is due by the schedule, so a reload neither repeats nor
+// postpones a check that ran recently.
+func (u *updateCheckTimer) configure(cfg *config.Config, sched *schedule.State, now time.Time) {
+	interval, _ := updateCheckInterval(cfg)
+	if interval == u.interval {
+		return
+	}
+	u.stop()
+	u.interval = interval
+	if interval > 0 {
+		u.timer = time.NewTimer(sched.Delay(schedule.TaskUpdateCheck, interval, now))
+	}
+}
+
+// C returns the channel the timer fires on, nil without an update check
+func (u *updateCheckTimer) C() <-chan time.Time {
+	if u.timer == nil {
+		return nil
+	}
+	return u.timer.C
+}
+
+// reset schedules the next check after a check ran
+func (u *updateCheckTimer) reset() {
+	if u.timer != nil {
+		u.timer.Reset(u.interval)
+	}
+}
+
+// stop stops the timer, its channel is nil afterwards
+func (u *updateCheckTimer) stop() {
+	if u.timer != nil {
+		u.timer.Stop()
+		u.timer = nil
+	}
+}
+
+// recordTaskRun records a run of a periodic daemon task so a restarted daemon resumes its schedule
+func recordTaskRun(ctx context.Context, sched *schedule.State, task string) {
+	sched.Record(task, time.Now())
+	if err := schedule.Save(sched); err != nil {
+		logger.GetLoggerFromContext(ctx).Warnf("Failed to save daemon schedule: %v", err)
+	}
+}
+
+// checkForUpdate compares the running agent version with the configured release channel and records the result
+func checkForUpdate(ctx context.Context, cfg *config.Config) {
+	logger := logger.GetLoggerFromContext(ctx)
+	if cfg.Agent.UpdateCheck == nil {
+		return
+	}
+
+	result, err := update.Check(ctx, cfg.Agent.UpdateCheck.ManifestURL, buildinfo.Version)
+	if err != nil {
+		logger.Warnf("Update check failed: %v", err)
+		return
+	}
+	switch {
+	case result.Message != "":
+		logger.Debugf("Update check: %s", result.Message)
+	case result.Outdated:
+		logger.Warnf("Agent %s is outdated, %s is available: %s", result.CurrentVersion, result.LatestVersion, result.ReleaseURL)
+	default:
+		logger.Debugf("Agent %s is up to date", result.CurrentVersion)
+	}
+	if err := update.SaveResult(result); err != nil {
+		logger.Warnf("Failed to save update check result: %v", err)
+	}
+}
+
+// maintenancePermits reports whether the maintenance policy permits a disruptive action now and, if not, why.
+// Disruptive actions are always permitted when no maintenance window or policy is configured.
+func maintenancePermits(cfg *config.Config, action string) (bool, string) {
+	return cfg.MaintenancePolicy().Permits(action, time.Now())
+}
+
+// failingGateChecks runs the health checks that gate auto-bootstrap and returns the failing ones
+func failingGateChecks(ctx context.Context, cfg *config.Config) []healthcheck.Result {
+	gateChecks := healthcheck.Filter(cfg.HealthChecks, config.HealthCheckActionGate)
+	if len(gateChecks) == 0 {
+		return nil
+	}
+	return healthcheck.Failing(healthcheck.Run(ctx, gateChecks), config.HealthCheckActionGate)
+}
+
+// recordBootstrapOutcome persists bootstrap failures so node-problem-detector can report them, clearing them on success.
+// The bootstrap is also recorded in the boot-to-Ready timeline of the current boot.
+func recordBootstrapOutcome(ctx context.Context, startedAt time.Time, bootstrapErr error) {
+	logger := logger.GetLoggerFromContext(ctx)
+	if err := readiness.RecordBootstrap(startedAt, time.Now(), bootstrapErr == nil); err != nil {
+		logger.Warnf("Failed to record bootstrap in the readiness timeline: %v", err)
+	}
+	if bootstrapErr == nil {
+		if err := status.ClearBootstrapFailure(); err != nil {
+			logger.Warnf("Failed to clear bootstrap failure record: %v", err)
+		}
+		return
+	}
+	if err := status.RecordBootstrapFailure(bootstrapErr); err != nil {
+		logger.Warnf("Failed to record bootstrap failure: %v", err)
+	}
+}
+
 func removeStatusFile(ctx context.Context) {
 	logger := logger.GetLoggerFromContext(ctx)
 	statusFilePath := status.GetStatusFilePath()
@@ -226,7 +962,7 @@ func collectAndWriteStatus(ctx context.Context, cfg *config.Config, statusFilePa
 	logger := logger.GetLoggerFromContext(ctx)
 
 	// Create status collector
-	collector := status.NewCollector(cfg, logger, Version)
+	collector := status.NewCollector(cfg, logger)
 
 	// Collect comprehensive status
 	nodeStatus, err := collector.CollectStatus(ctx)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/schedule"
+)
+
+func TestUpdateCheckTimerFollowsReloads(t *testing.T) {
+	now := time.Now()
+	// The last check ran half an hour ago
+	sched := &schedule.State{LastRun: map[string]time.Time{schedule.TaskUpdateCheck: now.Add(-30 * time.Minute)}}
+	withInterval := func(interval string) *config.Config {
+		cfg := &config.Config{}
+		if interval != "" {
+			cfg.Agent.UpdateCheck = &config.UpdateCheckConfig{Interval: interval}
+		}
+		return cfg
+	}
+	fired := func(u *updateCheckTimer) bool {
+		select {
+		case <-u.C():
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+
+	var u updateCheckTimer
+	defer u.stop()
+	u.configure(withInterval(""), sched, now)
+	if u.C() != nil {
+		t.Fatal("timer started without an update check")
+	}
+
+	// Enabled by a reload with an interval that is already over, the check is due now
+	u.configure(withInterval("10m"), sched, now)
+	if !fired(&u) {
+		t.Error("timer enabled by a reload did not fire for a due check")
+	}
+
+	// A reload with an unchanged interval keeps the timer
+	u.reset()
+	timer := u.timer
+	u.configure(withInterval("10m"), sched, now)
+	if u.timer != timer {
+		t.Error("reload with the same interval replaced the timer")
+	}
+
+	// A longer interval postpones the check until it is due by the schedule
+	u.configure(withInterval("1h"), sched, now)
+	if fired(&u) {
+		t.Error("timer fired before the check was due")
+	}
+
+	// Disabled by a reload, the timer stops
+	u.configure(withInterval(""), sched, now)
+	if u.C() != nil {
+		t.Error("timer still runs after a reload disabled the update check")
+	}
+}
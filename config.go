@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// NewConfigCommand creates the config command with its subcommands
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the agent configuration file",
+	}
+	cmd.AddCommand(newConfigMigrateCommand())
+	return cmd
+}
+
+// newConfigMigrateCommand creates the config migrate command
+func newConfigMigrateCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the configuration file to the current schema version",
+		Long: fmt.Sprintf("Rewrite the configuration file in schema version %d, moving renamed settings to their current place. "+
+			"The original file is kept next to it with a .v<version>.bak suffix. The agent upgrades older files in memory "+
+			"when it loads them, so this only makes the change permanent.", config.CurrentSchemaVersion),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dryRun {
+				data, err := os.ReadFile(configPath)
+				if err != nil {
+					return fmt.Errorf("failed to read config file at %s: %w", configPath, err)
+				}
+				migration, err := config.MigrateDocument(data)
+				if err != nil {
+					return err
+				}
+				printMigration(migration)
+				_, err = os.Stdout.Write(migration.Data)
+				return err
+			}
+
+			migration, err := config.MigrateFile(configPath)
+			if err != nil {
+				return err
+			}
+			if !migration.Migrated() {
+				fmt.Printf("%s already uses config schema version %d\n", configPath, config.CurrentSchemaVersion)
+				return nil
+			}
+			printMigration(migration)
+			fmt.Printf("Upgraded %s from schema version %d to %d, the original is kept at %s\n",
+				configPath, migration.FromVersion, config.CurrentSchemaVersion, migration.BackupPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the upgraded configuration instead of rewriting the file")
+
+	return cmd
+}
+
+// printMigration lists the migrations applied to a configuration file on stderr
+func printMigration(migration *config.Migration) {
+	for _, applied := range migration.Applied {
+		fmt.Fprintf(os.Stderr, "- %s\n", applied)
+	}
+}
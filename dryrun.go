@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+)
+
+// runDryRun prints what bootstrap or unbootstrap would do on this machine without changing it.
+// Validation failures are printed rather than returned: a step may only validate once earlier steps ran,
+// e.g. when the cluster preflight resolves the Kubernetes version.
+func runDryRun(ctx context.Context, operation string, overrides agentOverrides) error {
+	logger := logger.GetLoggerFromContext(ctx)
+
+	if _, err := defaults.Load(); err != nil {
+		return fmt.Errorf("failed to load component defaults: %w", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+	overrides.apply(cfg)
+	applyCachedSiteTags(ctx, cfg)
+
+	b := bootstrapper.New(cfg, logger)
+	var plan *bootstrapper.ExecutionPlan
+	if operation == "unbootstrap" {
+		plan = b.PlanUnbootstrap(ctx)
+	} else {
+		plan = b.PlanBootstrap(ctx)
+	}
+	writePlan(os.Stdout, plan)
+	return nil
+}
+
+// writePlan prints the steps of a plan in execution order with the actions each would take
+func writePlan(w io.Writer, plan *bootstrapper.ExecutionPlan) {
+	_, _ = fmt.Fprintf(w, "Dry run of %s, nothing was changed:\n", plan.Operation)
+	for i, step := range plan.Steps {
+		if step.Completed {
+			_, _ = fmt.Fprintf(w, "\n%2d. %s: already completed, skipped\n", i+1, step.StepName)
+		} else {
+			_, _ = fmt.Fprintf(w, "\n%2d. %s\n", i+1, step.StepName)
+		}
+		if step.Error != "" {
+			_, _ = fmt.Fprintf(w, "    ! %s\n", step.Error)
+		}
+		for _, action := range step.Actions {
+			_, _ = fmt.Fprintf(w, "    - %s\n", action)
+		}
+		for _, command := range step.SkippedCommands {
+			_, _ = fmt.Fprintf(w, "    (checks would run: %s)\n", command)
+		}
+	}
+}
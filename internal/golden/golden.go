@@ -0,0 +1,34 @@
+// Package golden compares generated content in tests against golden files in the testdata directory of the
+// package under test. Run the tests with -update to rewrite the golden files from the generated content.
+package golden
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Assert compares generated content against testdata/<name>.golden, rewriting it when -update is set
+func Assert(t testing.TB, name, got string) {
+	t.Helper()
+	goldenPath := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("failed to create testdata directory: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update golden file %s: %v", goldenPath, err)
+		}
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read golden file %s (run with -update to create it): %v", goldenPath, err)
+	}
+	if got != string(want) {
+		t.Errorf("generated content does not match %s (run with -update to refresh it)\ngot:\n%s\nwant:\n%s", goldenPath, got, want)
+	}
+}
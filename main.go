@@ -9,15 +9,29 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"go.goms.io/aks/AKSFlexNode/pkg/audit"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
 	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/secrets"
 )
 
 var (
-	configPath string
+	configPath   string
+	profileName  string
+	traceEnabled bool
 )
 
+// exitPermanentFailure is the exit code of a command that failed permanently, such as a bootstrap
+// blocked by preflight. The agent service is not restarted on it, see RestartPreventExitStatus.
+const exitPermanentFailure = 3
+
 func main() {
+	provenance.SetAgentVersion(buildinfo.Version)
+	config.SetKeyVaultResolver(secrets.ResolveKeyVaultSecret)
+
 	rootCmd := &cobra.Command{
 		Use:   "aks-flex-node",
 		Short: "AKS Flex Node Agent",
@@ -27,10 +41,27 @@ func main() {
 	// Add global flags for configuration
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to configuration JSON file (required)")
 	// Don't mark as required globally - we'll check in PersistentPreRunE for commands that need it
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "",
+		"Node pool profile of the configuration to merge over the node settings (default: the profile matching the hostname)")
+	rootCmd.PersistentFlags().BoolVar(&traceEnabled, "trace", false,
+		"Log every external command, file write and Azure request at trace level (secrets redacted)")
 
 	// Add commands
 	rootCmd.AddCommand(NewAgentCommand())
+	rootCmd.AddCommand(NewAuditCommand())
 	rootCmd.AddCommand(NewUnbootstrapCommand())
+	rootCmd.AddCommand(NewCleanupOrphansCommand())
+	rootCmd.AddCommand(NewCollectLogsCommand())
+	rootCmd.AddCommand(NewConfigCommand())
+	rootCmd.AddCommand(NewInstallServiceCommand())
+	rootCmd.AddCommand(NewUninstallServiceCommand())
+	rootCmd.AddCommand(NewNpdCheckCommand())
+	rootCmd.AddCommand(NewPreflightCommand())
+	rootCmd.AddCommand(NewStepsCommand())
+	rootCmd.AddCommand(NewCacheCommand())
+	rootCmd.AddCommand(NewServeCommand())
+	rootCmd.AddCommand(NewStatusCommand())
+	rootCmd.AddCommand(NewVerifyCommand())
 	rootCmd.AddCommand(NewVersionCommand())
 
 	// Set up context with signal handling
@@ -49,8 +80,8 @@ func main() {
 
 	// Set up persistent pre-run to initialize config and logger
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
-		// Skip config loading for version command
-		if cmd.Name() == "version" {
+		// Skip config loading for version command and node-problem-detector checks
+		if cmd.Name() == "version" || cmd.Name() == "npd-check" {
 			return nil
 		}
 
@@ -59,21 +90,41 @@ func main() {
 			return fmt.Errorf("config path is required for %s command", cmd.Name())
 		}
 
+		// Migrating must work on configuration files this agent cannot load yet
+		if cmd.Name() == "migrate" {
+			return nil
+		}
+
 		// Load config if specified
+		config.SelectProfile(profileName)
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
 			return fmt.Errorf("failed to load config from %s: %w", configPath, err)
 		}
 
 		// Setup logger and update context
-		ctx := logger.SetupLogger(cmd.Context(), cfg.Agent.LogLevel, cfg.Agent.LogDir)
+		ctx := logger.SetupLogger(cmd.Context(), cfg.Agent.LogLevel, cfg.Agent.LogFormat, cfg.Agent.LogDir)
+		if traceEnabled {
+			logger.EnableTrace(ctx)
+		}
+		// A dry run changes nothing, so it has nothing to audit
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); !dryRun {
+			audit.Enable(audit.Path(cfg.Agent.LogDir), logger.GetLoggerFromContext(ctx))
+		}
 		cmd.SetContext(ctx)
+		if version, ok := cfg.NeedsMigration(); ok {
+			logger.GetLoggerFromContext(ctx).Warnf("%s uses config schema version %d and was upgraded in memory, "+
+				"rewrite it with aks-flex-node config migrate", configPath, version)
+		}
 		return nil
 	}
 
 	// Execute command with context
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "Command execution failed: %v\n", err)
+		if failure.Classify(err) == failure.ClassPermanent {
+			os.Exit(exitPermanentFailure)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// DefaultSocketPath is the Unix socket the API listens on when no other socket is configured
+const DefaultSocketPath = "/run/aks-flex-node/api.sock"
+
+// Operations the API can trigger
+const (
+	OperationBootstrap   = "bootstrap"
+	OperationUnbootstrap = "unbootstrap"
+	OperationUpgrade     = "upgrade"
+)
+
+// progressPollInterval is how often a progress stream checks the progress file for changes
+var progressPollInterval = time.Second
+
+// OperationFunc runs a bootstrap orchestration operation to completion
+type OperationFunc func(ctx context.Context) error
+
+// StatusFunc collects the current node status
+type StatusFunc func(ctx context.Context) (any, error)
+
+// Operation describes an operation triggered through the API
+type Operation struct {
+	ID         string     `json:"id"`
+	Operation  string     `json:"operation"`
+	Phase      string     `json:"phase"` // running, succeeded or failed
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Server serves the orchestration API. It runs one operation at a time.
+type Server struct {
+	operations   map[string]OperationFunc
+	status       StatusFunc
+	progressPath string
+	logger       *logrus.Logger
+
+	mu      sync.Mutex
+	latest  *Operation
+	running bool
+	wg      sync.WaitGroup
+}
+
+// NewServer creates an API server running the given operations and reporting the given status
+func NewServer(operations map[string]OperationFunc, statusFunc StatusFunc, progressPath string, logger *logrus.Logger) *Server {
+	return &Server{
+		operations:   operations,
+		status:       statusFunc,
+		progressPath: progressPath,
+		logger:       logger,
+	}
+}
+
+// Handler returns the HTTP handler of the API. Operations it starts run until they finish or ctx is cancelled.
+func (s *Server) Handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	for name := range s.operations {
+		mux.HandleFunc("POST /v1/"+name, func(w http.ResponseWriter, _ *http.Request) {
+			s.startOperation(ctx, w, name)
+		})
+	}
+	mux.HandleFunc("GET /v1/operation", s.getOperation)
+	mux.HandleFunc("GET /v1/progress", s.getProgress)
+	mux.HandleFunc("GET /v1/status", s.getStatus)
+	return mux
+}
+
+// Wait blocks until the running operation, if any, has finished
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// startOperation starts an operation in the background, refusing to run two operations at once
+func (s *Server) startOperation(ctx context.Context, w http.ResponseWriter, name string) {
+	s.mu.Lock()
+	if s.running {
+		latest := *s.latest
+		s.mu.Unlock()
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":     fmt.Sprintf("operation %s is already running", latest.Operation),
+			"operation": latest,
+		})
+		return
+	}
+	operation := &Operation{
+		ID:        uuid.NewString(),
+		Operation: name,
+		Phase:     status.ProgressRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	s.latest, s.running = operation, true
+	snapshot := *operation
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	s.logger.Infof("Starting %s operation %s requested through the API", name, operation.ID)
+	go func() {
+		defer s.wg.Done()
+		err := s.operations[name](ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		finishedAt := time.Now().UTC()
+		operation.FinishedAt = &finishedAt
+		operation.Phase = status.ProgressSucceeded
+		if err != nil {
+			operation.Phase = status.ProgressFailed
+			operation.Error = err.Error()
+			s.logger.Errorf("%s operation %s failed: %v", name, operation.ID, err)
+		} else {
+			s.logger.Infof("%s operation %s succeeded", name, operation.ID)
+		}
+		s.running = false
+	}()
+
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// getOperation returns the running or most recently finished operation
+func (s *Server) getOperation(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no operation was started"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.latest)
+}
+
+// getProgress returns the step progress of the operation. With ?watch=true it streams every change
+// as a JSON document per line until the operation finishes or the client disconnects.
+func (s *Server) getProgress(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("watch") != "true" {
+		progress, err := status.ReadProgress(s.progressPath)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, progress)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	var lastUpdate time.Time
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+	for {
+		progress, err := status.ReadProgress(s.progressPath)
+		if err == nil && !progress.UpdatedAt.Equal(lastUpdate) {
+			lastUpdate = progress.UpdatedAt
+			if encoder.Encode(progress) != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		if err == nil && progress.Phase != status.ProgressRunning && !s.isRunning() {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getStatus returns the current node status
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	nodeStatus, err := s.status(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, nodeStatus)
+}
+
+func (s *Server) isRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(body)
+}
+
+// ListenUnix listens on a Unix socket that only its owner and group can connect to,
+// so access to the API is granted by membership in the group of the agent user
+func ListenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	// Remove the socket left behind by a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict access to %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// ListenMutualTLS listens on a TCP address that only accepts clients presenting a certificate issued by the client CA
+func ListenMutualTLS(address, certFile, keyFile, clientCAFile string) (net.Listener, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API server certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA %s: %w", clientCAFile, err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("client CA %s contains no PEM certificates", clientCAFile)
+	}
+	listener, err := tls.Listen("tcp", address, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return listener, nil
+}
+
+// Serve serves the handler on the listeners until the context is cancelled
+func Serve(ctx context.Context, handler http.Handler, listeners []net.Listener, logger *logrus.Logger) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		logger.Infof("Serving the orchestration API on %s", listener.Addr())
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("failed to serve the API on %s: %w", listener.Addr(), err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	var firstErr error
+	for range listeners {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			_ = server.Close()
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+func newTestServer(t *testing.T, operations map[string]OperationFunc, progressPath string) (*Server, *httptest.Server) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	statusFunc := func(context.Context) (any, error) {
+		return map[string]string{"kubeletRunning": "true"}, nil
+	}
+	server := NewServer(operations, statusFunc, progressPath, logger)
+	httpServer := httptest.NewServer(server.Handler(context.Background()))
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Wait()
+	})
+	return server, httpServer
+}
+
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	defer func() { _ = resp.Body.Close() }()
+	var body T
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return body
+}
+
+func TestOperationsRunOneAtATime(t *testing.T) {
+	release := make(chan error)
+	_, httpServer := newTestServer(t, map[string]OperationFunc{
+		OperationBootstrap:   func(context.Context) error { return <-release },
+		OperationUnbootstrap: func(context.Context) error { return nil },
+	}, filepath.Join(t.TempDir(), "progress.json"))
+
+	resp, err := http.Get(httpServer.URL + "/v1/operation")
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /v1/operation before any operation = %v, %v, want 404", resp, err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = http.Post(httpServer.URL+"/v1/bootstrap", "", nil)
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /v1/bootstrap = %v, %v, want 202", resp, err)
+	}
+	started := decode[Operation](t, resp)
+	if started.Operation != OperationBootstrap || started.Phase != status.ProgressRunning {
+		t.Errorf("started operation = %+v", started)
+	}
+
+	resp, err = http.Post(httpServer.URL+"/v1/unbootstrap", "", nil)
+	if err != nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("POST /v1/unbootstrap while bootstrapping = %v, %v, want 409", resp, err)
+	}
+	_ = resp.Body.Close()
+
+	release <- errors.New("kubelet failed to start")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err = http.Get(httpServer.URL + "/v1/operation")
+		if err != nil {
+			t.Fatalf("GET /v1/operation error = %v", err)
+		}
+		operation := decode[Operation](t, resp)
+		if operation.Phase == status.ProgressFailed {
+			if operation.ID != started.ID || operation.Error != "kubelet failed to start" || operation.FinishedAt == nil {
+				t.Errorf("finished operation = %+v", operation)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("operation did not finish, last = %+v", operation)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err = http.Post(httpServer.URL+"/v1/unbootstrap", "", nil)
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /v1/unbootstrap after bootstrap = %v, %v, want 202", resp, err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestProgressWatchStreamsUntilFinished(t *testing.T) {
+	original := progressPollInterval
+	progressPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { progressPollInterval = original })
+
+	progressPath := filepath.Join(t.TempDir(), "progress.json")
+	progress := &status.Progress{Operation: OperationBootstrap, Phase: status.ProgressRunning, CurrentStep: "containerd", StepIndex: 1, TotalSteps: 2}
+	if err := status.WriteProgress(progressPath, progress); err != nil {
+		t.Fatalf("WriteProgress() error = %v", err)
+	}
+	_, httpServer := newTestServer(t, map[string]OperationFunc{}, progressPath)
+
+	resp, err := http.Get(httpServer.URL + "/v1/progress?watch=true")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /v1/progress?watch=true = %v, %v, want 200", resp, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() {
+		t.Fatalf("stream ended before the first update: %v", lines.Err())
+	}
+	var first status.Progress
+	if err := json.Unmarshal(lines.Bytes(), &first); err != nil || first.CurrentStep != "containerd" {
+		t.Fatalf("first update = %s, %v", lines.Text(), err)
+	}
+
+	progress.CurrentStep, progress.StepIndex, progress.Phase = "kubelet", 2, status.ProgressSucceeded
+	time.Sleep(5 * time.Millisecond) // ensure the update time changes
+	if err := status.WriteProgress(progressPath, progress); err != nil {
+		t.Fatalf("WriteProgress() error = %v", err)
+	}
+	if !lines.Scan() {
+		t.Fatalf("stream ended before the final update: %v", lines.Err())
+	}
+	var last status.Progress
+	if err := json.Unmarshal(lines.Bytes(), &last); err != nil || last.Phase != status.ProgressSucceeded {
+		t.Fatalf("final update = %s, %v", lines.Text(), err)
+	}
+	if lines.Scan() {
+		t.Errorf("stream continued after the operation finished: %s", lines.Text())
+	}
+}
+
+func TestStatus(t *testing.T) {
+	_, httpServer := newTestServer(t, map[string]OperationFunc{}, filepath.Join(t.TempDir(), "progress.json"))
+
+	resp, err := http.Get(httpServer.URL + "/v1/status")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /v1/status = %v, %v, want 200", resp, err)
+	}
+	if body := decode[map[string]string](t, resp); body["kubeletRunning"] != "true" {
+		t.Errorf("GET /v1/status = %v", body)
+	}
+}
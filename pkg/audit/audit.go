@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// FileName is the name of the audit trail in the agent log directory
+const FileName = "audit.jsonl"
+
+// Outcomes of audited changes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is an entry of the audit trail: a command, service operation or file write the agent made on the host
+type Event struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Step       string    `json:"step,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	Service    string    `json:"service,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Mode       string    `json:"mode,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Outcome    string    `json:"outcome"`
+	ExitCode   int       `json:"exitCode,omitempty"`
+	DurationMS int64     `json:"durationMs,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Path returns the path of the audit trail in a log directory
+func Path(logDir string) string {
+	return filepath.Join(logDir, FileName)
+}
+
+// Log appends audit events to a JSON lines file. The file is only ever appended to and opened on the first
+// event, so commands that do not change the host never create it.
+type Log struct {
+	path   string
+	logger *logrus.Logger
+	now    func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	failed bool
+}
+
+// NewLog creates a Log appending to the file at path
+func NewLog(path string, logger *logrus.Logger) *Log {
+	return &Log{path: path, logger: logger, now: time.Now}
+}
+
+// Enable records every change the agent makes to the host from now on to the audit trail at path
+func Enable(path string, logger *logrus.Logger) {
+	sysutil.EnableAudit(NewLog(path, logger))
+}
+
+// Record appends an event for a change reported by the command runner or the file helpers, stamped with the
+// steps running at the time. A trail that cannot be written is reported once and the events are dropped, the
+// change itself is not affected.
+func (l *Log) Record(change sysutil.AuditEvent) {
+	event := Event{
+		Time:       l.now().UTC(),
+		Kind:       change.Kind,
+		Step:       currentSteps(),
+		Command:    change.Command,
+		Service:    change.Service,
+		Path:       change.Path,
+		SHA256:     change.SHA256,
+		Outcome:    OutcomeSuccess,
+		ExitCode:   change.ExitCode,
+		DurationMS: change.Duration.Milliseconds(),
+	}
+	if change.Kind == sysutil.AuditKindFile {
+		event.Mode = fmt.Sprintf("%04o", change.Mode)
+	}
+	if change.Err != nil {
+		event.Outcome = OutcomeFailure
+		event.Error = change.Err.Error()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.append(append(line, '\n')); err != nil && !l.failed {
+		l.failed = true
+		l.logger.Warnf("Failed to write the audit trail %s, changes to the host are not audited: %v", l.path, err)
+	}
+}
+
+// append writes a line to the trail, opening it on the first call
+func (l *Log) append(line []byte) error {
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+		if err != nil {
+			return err
+		}
+		l.file = file
+	}
+	_, err := l.file.Write(line)
+	return err
+}
+
+// Close closes the trail
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Steps running now, stamped on the events recorded while they run
+var (
+	activeSteps   []*string
+	activeStepsMu sync.RWMutex
+)
+
+// BeginStep attributes the events recorded until the returned function is called to a step. While steps run
+// at once, an event cannot be told apart by step, so it carries the comma-separated names of all of them.
+func BeginStep(step string) func() {
+	active := &step
+	activeStepsMu.Lock()
+	activeSteps = append(activeSteps, active)
+	activeStepsMu.Unlock()
+	return func() {
+		activeStepsMu.Lock()
+		defer activeStepsMu.Unlock()
+		activeSteps = slices.DeleteFunc(activeSteps, func(s *string) bool { return s == active })
+	}
+}
+
+// currentSteps returns the names of the steps running now
+func currentSteps() string {
+	activeStepsMu.RLock()
+	defer activeStepsMu.RUnlock()
+	names := make([]string, 0, len(activeSteps))
+	for _, step := range activeSteps {
+		names = append(names, *step)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+func TestLogRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", FileName)
+	logger, _ := test.NewNullLogger()
+	log := NewLog(path, logger)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	log.now = func() time.Time { return now }
+
+	log.Record(sysutil.AuditEvent{Kind: sysutil.AuditKindCommand, Command: "modprobe overlay", Duration: 25 * time.Millisecond})
+
+	endContainerd := BeginStep("ContainerdInstaller")
+	endRunc := BeginStep("Runc_Installer")
+	log.Record(sysutil.AuditEvent{Kind: sysutil.AuditKindFile, Path: "/etc/containerd/config.toml", Mode: 0o644, SHA256: "abc"})
+	endRunc()
+	now = now.Add(time.Hour)
+	log.Record(sysutil.AuditEvent{Kind: sysutil.AuditKindService, Command: "systemctl restart containerd", Service: "containerd",
+		ExitCode: 1, Err: errors.New("exit status 1")})
+	endContainerd()
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A line cut short by a crash is skipped
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString(`{"time":"2026-03-01T13:00:00Z","kind":"comm`)
+	_ = file.Close()
+
+	events, skipped, err := Read(path, Query{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 3 || skipped != 1 {
+		t.Fatalf("Read() = %d events, %d skipped, want 3 events and 1 skipped", len(events), skipped)
+	}
+	if events[0].Step != "" || events[0].Outcome != OutcomeSuccess || events[0].DurationMS != 25 {
+		t.Errorf("first event = %+v, want a successful command of no step taking 25ms", events[0])
+	}
+	if events[1].Step != "ContainerdInstaller,Runc_Installer" || events[1].Mode != "0644" {
+		t.Errorf("file event = %+v, want both running steps and mode 0644", events[1])
+	}
+	if events[2].Step != "ContainerdInstaller" || events[2].Outcome != OutcomeFailure || events[2].Error != "exit status 1" {
+		t.Errorf("service event = %+v, want a failure of ContainerdInstaller", events[2])
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  int
+	}{
+		{name: "step running at once with another", query: Query{Step: "Runc_Installer"}, want: 1},
+		{name: "step", query: Query{Step: "ContainerdInstaller"}, want: 2},
+		{name: "kind", query: Query{Kind: sysutil.AuditKindService}, want: 1},
+		{name: "failed", query: Query{Failed: true}, want: 1},
+		{name: "since", query: Query{Since: now.Add(-time.Minute)}, want: 1},
+		{name: "until", query: Query{Until: now.Add(-time.Minute)}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, _, err := Read(path, tt.query)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("Read() = %d events, want %d", len(events), tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMissingTrail(t *testing.T) {
+	events, skipped, err := Read(filepath.Join(t.TempDir(), FileName), Query{})
+	if err != nil || len(events) != 0 || skipped != 0 {
+		t.Errorf("Read() of a missing trail = %v, %d, %v, want no events", events, skipped, err)
+	}
+}
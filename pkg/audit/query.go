@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds the length of a single audit event, far above what a redacted command line needs
+const maxLineSize = 1024 * 1024
+
+// Query selects audit events. Zero fields select every event.
+type Query struct {
+	Since  time.Time
+	Until  time.Time
+	Step   string // events of the step, also when it ran at once with others
+	Kind   string
+	Failed bool // only changes that failed
+}
+
+// Matches reports whether the query selects the event
+func (q Query) Matches(event Event) bool {
+	switch {
+	case !q.Since.IsZero() && event.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && event.Time.After(q.Until):
+		return false
+	case q.Step != "" && !slices.Contains(strings.Split(event.Step, ","), q.Step):
+		return false
+	case q.Kind != "" && event.Kind != q.Kind:
+		return false
+	case q.Failed && event.Outcome != OutcomeFailure:
+		return false
+	}
+	return true
+}
+
+// Read returns the events of the audit trail at path the query selects, oldest first. Lines that are not valid
+// events, such as a line cut short by a crash, are skipped and counted. A missing trail has no events.
+func Read(path string, query Query) (events []Event, skipped int, err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open audit trail %s: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil || event.Kind == "" {
+			skipped++
+			continue
+		}
+		if query.Matches(event) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return events, skipped, fmt.Errorf("failed to read audit trail %s: %w", path, err)
+	}
+	return events, skipped, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
@@ -29,11 +30,29 @@ func (a *AuthProvider) ArcCredential() (azcore.TokenCredential, error) {
 	return cred, nil
 }
 
-// UserCredential returns credential based on config (service principal or CLI fallback)
+// ManagedIdentityCredential returns the managed identity credential of the machine: the Arc identity of a
+// connected machine or the identity of an Azure VM. clientID selects a user-assigned identity.
+func (a *AuthProvider) ManagedIdentityCredential(clientID string) (azcore.TokenCredential, error) {
+	options := &azidentity.ManagedIdentityCredentialOptions{}
+	if clientID != "" {
+		options.ID = azidentity.ClientID(clientID)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed identity credential: %w", err)
+	}
+	return cred, nil
+}
+
+// UserCredential returns credential based on config (service principal, workload identity or CLI fallback)
+// for the configured Azure cloud
 func (a *AuthProvider) UserCredential(cfg *config.Config) (azcore.TokenCredential, error) {
 	if cfg.IsSPConfigured() {
 		return a.serviceCredential(cfg)
 	}
+	if cfg.IsWorkloadIdentityConfigured() {
+		return a.workloadIdentityCredential(cfg)
+	}
 	return a.cliCredential()
 }
 
@@ -43,7 +62,7 @@ func (a *AuthProvider) serviceCredential(cfg *config.Config) (azcore.TokenCreden
 		cfg.Azure.ServicePrincipal.TenantID,
 		cfg.Azure.ServicePrincipal.ClientID,
 		cfg.Azure.ServicePrincipal.ClientSecret,
-		nil,
+		&azidentity.ClientSecretCredentialOptions{ClientOptions: azcore.ClientOptions{Cloud: Cloud(cfg).Configuration}},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service principal credential: %w", err)
@@ -51,7 +70,24 @@ func (a *AuthProvider) serviceCredential(cfg *config.Config) (azcore.TokenCreden
 	return cred, nil
 }
 
-// cliCredential creates Azure CLI credential
+// workloadIdentityCredential creates a federated credential from config, exchanging the token in the token file
+// for Entra ID tokens. The file is read again for every exchange, so tokens rotated by the identity provider are used.
+func (a *AuthProvider) workloadIdentityCredential(cfg *config.Config) (azcore.TokenCredential, error) {
+	wi := cfg.Azure.WorkloadIdentity
+	cred, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: Cloud(cfg).Configuration},
+		ClientID:      wi.ClientID,
+		TenantID:      wi.TenantID,
+		TokenFilePath: wi.TokenFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workload identity credential: %w", err)
+	}
+	return cred, nil
+}
+
+// cliCredential creates Azure CLI credential. The CLI signs in to the cloud selected with az cloud set,
+// which EnsureAuthenticated selects for azure.cloud.
 func (a *AuthProvider) cliCredential() (azcore.TokenCredential, error) {
 	cred, err := azidentity.NewAzureCLICredential(nil)
 	if err != nil {
@@ -60,9 +96,9 @@ func (a *AuthProvider) cliCredential() (azcore.TokenCredential, error) {
 	return cred, nil
 }
 
-// GetAccessToken retrieves access token for given credential with default ARM scope
-func (a *AuthProvider) GetAccessToken(ctx context.Context, cred azcore.TokenCredential) (string, error) {
-	return a.GetAccessTokenForResource(ctx, cred, "https://management.azure.com/.default")
+// GetAccessToken retrieves access token for given credential with the Azure Resource Manager scope of the configured cloud
+func (a *AuthProvider) GetAccessToken(ctx context.Context, cfg *config.Config, cred azcore.TokenCredential) (string, error) {
+	return a.GetAccessTokenForResource(ctx, cred, Cloud(cfg).ResourceManagerScope())
 }
 
 // GetAccessTokenForResource retrieves access token for given credential and resource
@@ -117,13 +153,36 @@ func (a *AuthProvider) InteractiveAzLogin(ctx context.Context, tenantID string)
 	return nil
 }
 
-// EnsureAuthenticated checks if user is authenticated and prompts for login if needed
-func (a *AuthProvider) EnsureAuthenticated(ctx context.Context, tenantID string) error {
+// EnsureAuthenticated selects the configured Azure cloud in the Azure CLI, checks if user is authenticated
+// and prompts for login if needed
+func (a *AuthProvider) EnsureAuthenticated(ctx context.Context, cfg *config.Config) error {
+	if err := a.setCLICloud(ctx, Cloud(cfg).AzureCLIName); err != nil {
+		return err
+	}
+	tenantID := cfg.GetTenantID()
+
 	// Check if already authenticated with valid token
 	if err := a.CheckCLIAuthStatus(ctx); err == nil {
 		return nil // Already authenticated and token is valid
 	}
 
-	// Not authenticated or token expired, prompt for interactive login
+	// Not authenticated or token expired, prompt for interactive login.
+	// Without a browser on the host, sign in from another device with a device code instead.
+	if isHeadless() {
+		return a.DeviceCodeAzLogin(ctx, tenantID)
+	}
 	return a.InteractiveAzLogin(ctx, tenantID)
 }
+
+// setCLICloud makes the Azure CLI sign in to and request tokens from the named cloud. Switching clouds signs the CLI
+// out of the previous one, so the cloud is only set when it differs.
+func (a *AuthProvider) setCLICloud(ctx context.Context, name string) error {
+	output, err := exec.CommandContext(ctx, "az", "cloud", "show", "--query", "name", "--output", "tsv").Output()
+	if err == nil && strings.TrimSpace(string(output)) == name {
+		return nil
+	}
+	if err := exec.CommandContext(ctx, "az", "cloud", "set", "--name", name).Run(); err != nil {
+		return fmt.Errorf("failed to select Azure cloud %s in the Azure CLI: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// ClientOptions returns the Azure Resource Manager client options of the agent for the configured Azure cloud.
+// Requests carry the agent version and node name in their User-Agent, so the activity of nodes sharing one
+// egress IP or service principal can be told apart.
+func ClientOptions(cfg *config.Config) *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud:           Cloud(cfg).Configuration,
+			PerCallPolicies: []policy.Policy{&userAgentPolicy{suffix: NodeUserAgent(cfg)}},
+		},
+	}
+}
+
+// NodeUserAgent returns the User-Agent product token identifying the agent and node
+func NodeUserAgent(cfg *config.Config) string {
+	agent := "aks-flex-node/" + buildinfo.Version
+	if nodeName := cfg.GetNodeName(); nodeName != "" {
+		agent += fmt.Sprintf(" (node %s)", nodeName)
+	}
+	return agent
+}
+
+// userAgentPolicy appends a product token to the User-Agent set by the SDK telemetry policy
+type userAgentPolicy struct {
+	suffix string
+}
+
+func (p *userAgentPolicy) Do(req *policy.Request) (*http.Response, error) {
+	header := req.Raw().Header
+	if userAgent := header.Get("User-Agent"); userAgent != "" {
+		header.Set("User-Agent", userAgent+" "+p.suffix)
+	} else {
+		header.Set("User-Agent", p.suffix)
+	}
+	return req.Next()
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// CloudEnvironment holds the endpoints of the Azure cloud the target cluster runs in and the names
+// the Azure CLI and the Arc agent know that cloud by
+type CloudEnvironment struct {
+	Configuration cloud.Configuration // Entra ID authority and Azure Resource Manager endpoint for the Azure SDK
+	AzureCLIName  string              // az cloud set --name
+	ArcCloud      string              // azcmagent connect --cloud
+	KeyVault      string              // Key Vault data plane resource tokens are requested for
+}
+
+// cloudEnvironments maps azure.cloud to the cloud environment. The AKS Entra ID server application
+// that kubelet tokens are issued for has the same ID in every cloud.
+var cloudEnvironments = map[string]CloudEnvironment{
+	config.CloudAzurePublic: {Configuration: cloud.AzurePublic, AzureCLIName: "AzureCloud", ArcCloud: "AzureCloud",
+		KeyVault: "https://vault.azure.net"},
+	config.CloudAzureChina: {Configuration: cloud.AzureChina, AzureCLIName: "AzureChinaCloud", ArcCloud: "AzureChinaCloud",
+		KeyVault: "https://vault.azure.cn"},
+	config.CloudAzureUSGovernment: {Configuration: cloud.AzureGovernment, AzureCLIName: "AzureUSGovernment", ArcCloud: "AzureUSGovernment",
+		KeyVault: "https://vault.usgovcloudapi.net"},
+}
+
+// Cloud returns the cloud environment of azure.cloud, the public cloud when it is not set
+func Cloud(cfg *config.Config) CloudEnvironment {
+	if cfg != nil {
+		if env, ok := cloudEnvironments[cfg.Azure.Cloud]; ok {
+			return env
+		}
+	}
+	return cloudEnvironments[config.CloudAzurePublic]
+}
+
+// AuthorityHost returns the Entra ID authority host with a trailing slash, e.g. https://login.microsoftonline.com/
+func (c CloudEnvironment) AuthorityHost() string {
+	return strings.TrimSuffix(c.Configuration.ActiveDirectoryAuthorityHost, "/") + "/"
+}
+
+// ResourceManagerEndpoint returns the Azure Resource Manager endpoint without a trailing slash
+func (c CloudEnvironment) ResourceManagerEndpoint() string {
+	return strings.TrimSuffix(c.Configuration.Services[cloud.ResourceManager].Endpoint, "/")
+}
+
+// ResourceManagerScope returns the token scope for Azure Resource Manager
+func (c CloudEnvironment) ResourceManagerScope() string {
+	return c.ResourceManagerEndpoint() + "/.default"
+}
+
+// TokenEndpoint returns the OAuth 2.0 token endpoint of the tenant
+func (c CloudEnvironment) TokenEndpoint(tenantID string) string {
+	return c.AuthorityHost() + tenantID + "/oauth2/v2.0/token"
+}
+
+// KeyVaultScope returns the token scope for reading Key Vault secrets
+func (c CloudEnvironment) KeyVaultScope() string {
+	return c.KeyVault + "/.default"
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestCloud(t *testing.T) {
+	tests := []struct {
+		cloud         string
+		scope         string
+		tokenEndpoint string
+		arcCloud      string
+		keyVaultScope string
+	}{
+		{cloud: "", scope: "https://management.azure.com/.default", tokenEndpoint: "https://login.microsoftonline.com/tenant/oauth2/v2.0/token", arcCloud: "AzureCloud", keyVaultScope: "https://vault.azure.net/.default"},
+		{cloud: config.CloudAzurePublic, scope: "https://management.azure.com/.default", tokenEndpoint: "https://login.microsoftonline.com/tenant/oauth2/v2.0/token", arcCloud: "AzureCloud", keyVaultScope: "https://vault.azure.net/.default"},
+		{cloud: config.CloudAzureChina, scope: "https://management.chinacloudapi.cn/.default", tokenEndpoint: "https://login.chinacloudapi.cn/tenant/oauth2/v2.0/token", arcCloud: "AzureChinaCloud", keyVaultScope: "https://vault.azure.cn/.default"},
+		{cloud: config.CloudAzureUSGovernment, scope: "https://management.usgovcloudapi.net/.default", tokenEndpoint: "https://login.microsoftonline.us/tenant/oauth2/v2.0/token", arcCloud: "AzureUSGovernment", keyVaultScope: "https://vault.usgovcloudapi.net/.default"},
+	}
+	for _, tt := range tests {
+		env := Cloud(&config.Config{Azure: config.AzureConfig{Cloud: tt.cloud}})
+		if got := env.ResourceManagerScope(); got != tt.scope {
+			t.Errorf("Cloud(%q).ResourceManagerScope() = %q, want %q", tt.cloud, got, tt.scope)
+		}
+		if got := env.TokenEndpoint("tenant"); got != tt.tokenEndpoint {
+			t.Errorf("Cloud(%q).TokenEndpoint() = %q, want %q", tt.cloud, got, tt.tokenEndpoint)
+		}
+		if env.ArcCloud != tt.arcCloud {
+			t.Errorf("Cloud(%q).ArcCloud = %q, want %q", tt.cloud, env.ArcCloud, tt.arcCloud)
+		}
+		if got := env.KeyVaultScope(); got != tt.keyVaultScope {
+			t.Errorf("Cloud(%q).KeyVaultScope() = %q, want %q", tt.cloud, got, tt.keyVaultScope)
+		}
+	}
+}
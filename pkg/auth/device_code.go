@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Device code sign-in as Entra ID issues it: a code stays valid for 15 minutes
+const (
+	deviceCodeLoginTimeout = 15 * time.Minute
+	deviceCodeMethod       = "deviceCode"
+)
+
+// OnboardingStateFilePath records the progress of the first-time Azure CLI sign-in, so an onboarding
+// interrupted by a timeout or restart resumes with a fresh code and the sign-in prompt can be read from disk
+var OnboardingStateFilePath = filepath.Join(config.AgentStateDir, "onboarding.json")
+
+// deviceCodePromptPattern matches the sign-in instructions az login prints for the device code flow
+var deviceCodePromptPattern = regexp.MustCompile(`(https://\S+)\s+and enter the code\s+(\S+)`)
+
+// isHeadless reports whether no graphical session is available to open a browser for interactive login
+var isHeadless = func() bool {
+	return os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+}
+
+// OnboardingState is the progress of the first-time Azure CLI sign-in
+type OnboardingState struct {
+	Method          string     `json:"method"`
+	TenantID        string     `json:"tenantId"`
+	StartedAt       time.Time  `json:"startedAt"`
+	Attempts        int        `json:"attempts"`
+	VerificationURL string     `json:"verificationUrl,omitempty"`
+	UserCode        string     `json:"userCode,omitempty"`
+	CodeExpiresAt   *time.Time `json:"codeExpiresAt,omitempty"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+}
+
+// pending reports whether a sign-in for the tenant was started and has not completed yet
+func (s *OnboardingState) pending(tenantID string) bool {
+	return s.Method == deviceCodeMethod && s.TenantID == tenantID && s.CompletedAt == nil && s.Attempts > 0
+}
+
+// SaveOnboardingState persists the onboarding state. The file holds a live sign-in code and is only readable by root.
+func SaveOnboardingState(state *OnboardingState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal onboarding state: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(OnboardingStateFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", OnboardingStateFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(OnboardingStateFilePath, data, 0o600)
+}
+
+// LoadOnboardingState reads the onboarding state, returning an empty state when no sign-in was started yet
+func LoadOnboardingState() (*OnboardingState, error) {
+	state := &OnboardingState{}
+	data, err := os.ReadFile(OnboardingStateFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read onboarding state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse onboarding state: %w", err)
+	}
+	return state, nil
+}
+
+// DeviceCodeAzLogin signs the Azure CLI in with the device code flow, for hosts without a browser.
+// az prints a URL and code to enter on any other device and polls until the sign-in completes or the code expires.
+// The tokens are kept in the Azure CLI profile, so later runs use the CLI credential without signing in again.
+func (a *AuthProvider) DeviceCodeAzLogin(ctx context.Context, tenantID string) error {
+	state, err := LoadOnboardingState()
+	if err != nil || !state.pending(tenantID) {
+		state = &OnboardingState{Method: deviceCodeMethod, TenantID: tenantID, StartedAt: time.Now()}
+	} else {
+		fmt.Fprintf(os.Stderr, "Resuming Azure sign-in started at %s (attempt %d), previous attempt: %s\n",
+			state.StartedAt.Format(time.RFC3339), state.Attempts+1, state.LastError)
+	}
+	state.Attempts++
+	state.VerificationURL, state.UserCode, state.CodeExpiresAt, state.LastError = "", "", nil, ""
+	a.saveOnboardingState(state)
+
+	loginCtx, cancel := context.WithTimeout(ctx, deviceCodeLoginTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(loginCtx, "az", "login", "--use-device-code", "--tenant", tenantID)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &deviceCodePromptWriter{out: os.Stderr, onPrompt: func(url, code string) {
+		expiresAt := time.Now().Add(deviceCodeLoginTimeout)
+		state.VerificationURL, state.UserCode, state.CodeExpiresAt = url, code, &expiresAt
+		a.saveOnboardingState(state)
+	}}
+
+	err = cmd.Run()
+	if err != nil && errors.Is(loginCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("device code sign-in was not completed within %s, rerun to get a new code", deviceCodeLoginTimeout)
+	}
+	state.VerificationURL, state.UserCode, state.CodeExpiresAt = "", "", nil
+	if err != nil {
+		state.LastError = err.Error()
+		a.saveOnboardingState(state)
+		return fmt.Errorf("device code Azure CLI login failed: %w", err)
+	}
+	completedAt := time.Now()
+	state.CompletedAt = &completedAt
+	a.saveOnboardingState(state)
+	return nil
+}
+
+// saveOnboardingState persists the onboarding state on a best-effort basis, sign-in does not depend on it
+func (a *AuthProvider) saveOnboardingState(state *OnboardingState) {
+	if err := SaveOnboardingState(state); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save onboarding state: %v\n", err)
+	}
+}
+
+// deviceCodePromptWriter passes az output through and reports the device code sign-in prompt once it is printed
+type deviceCodePromptWriter struct {
+	out      io.Writer
+	onPrompt func(url, code string)
+	buf      bytes.Buffer
+	seen     bool
+}
+
+func (w *deviceCodePromptWriter) Write(p []byte) (int, error) {
+	if !w.seen {
+		w.buf.Write(p)
+		if match := deviceCodePromptPattern.FindSubmatch(w.buf.Bytes()); match != nil {
+			w.seen = true
+			w.buf.Reset()
+			w.onPrompt(string(match[1]), string(match[2]))
+		}
+	}
+	return w.out.Write(p)
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeviceCodePromptWriter(t *testing.T) {
+	var out bytes.Buffer
+	var gotURL, gotCode string
+	calls := 0
+	w := &deviceCodePromptWriter{out: &out, onPrompt: func(url, code string) {
+		gotURL, gotCode = url, code
+		calls++
+	}}
+
+	// az may flush the prompt in several writes
+	prompt := "To sign in, use a web browser to open the page https://microsoft.com/devicelogin and enter the code F7QK2ZL9M to authenticate.\n"
+	for _, chunk := range []string{prompt[:40], prompt[40:110], prompt[110:], prompt} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if calls != 1 || gotURL != "https://microsoft.com/devicelogin" || gotCode != "F7QK2ZL9M" {
+		t.Errorf("onPrompt called %d times with %q, %q", calls, gotURL, gotCode)
+	}
+	if out.String() != prompt+prompt {
+		t.Errorf("output = %q, want the az output passed through", out.String())
+	}
+}
+
+func TestOnboardingStateRoundTrip(t *testing.T) {
+	OnboardingStateFilePath = filepath.Join(t.TempDir(), "onboarding.json")
+
+	state, err := LoadOnboardingState()
+	if err != nil || state.pending("tenant") {
+		t.Fatalf("LoadOnboardingState() = %+v, %v, want an empty state", state, err)
+	}
+
+	saved := &OnboardingState{Method: deviceCodeMethod, TenantID: "tenant", StartedAt: time.Now().UTC().Truncate(time.Second), Attempts: 1, LastError: "timed out"}
+	if err := SaveOnboardingState(saved); err != nil {
+		t.Fatalf("SaveOnboardingState() error = %v", err)
+	}
+	loaded, err := LoadOnboardingState()
+	if err != nil {
+		t.Fatalf("LoadOnboardingState() error = %v", err)
+	}
+	if !loaded.pending("tenant") || loaded.pending("other-tenant") || !loaded.StartedAt.Equal(saved.StartedAt) {
+		t.Errorf("LoadOnboardingState() = %+v, want a pending sign-in for tenant", loaded)
+	}
+
+	completedAt := time.Now()
+	loaded.CompletedAt = &completedAt
+	if loaded.pending("tenant") {
+		t.Error("pending() = true for a completed sign-in")
+	}
+}
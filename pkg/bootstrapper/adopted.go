@@ -0,0 +1,54 @@
+package bootstrapper
+
+import (
+	"context"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+)
+
+// adoptedStep wraps a bootstrap step that configures or restarts the container runtime, the host or kubelet's
+// services. On a node whose kubelet was adopted the step counts as completed, so bootstrap leaves the runtime the
+// adopted kubelet runs on as it found it; only the configured labels and taints are applied to such a node.
+type adoptedStep struct {
+	Executor
+	adopted func() bool
+}
+
+// keepAdopted wraps step so it leaves an adopted node alone
+func keepAdopted(step Executor) Executor {
+	return &adoptedStep{Executor: step, adopted: kubelet.IsAdopted}
+}
+
+// IsCompleted returns true on an adopted node and otherwise checks the wrapped step
+func (s *adoptedStep) IsCompleted(ctx context.Context) bool {
+	return s.adopted() || s.Executor.IsCompleted(ctx)
+}
+
+// Validate validates the wrapped step when it has preconditions
+func (s *adoptedStep) Validate(ctx context.Context) error {
+	if step, ok := s.Executor.(StepExecutor); ok {
+		return step.Validate(ctx)
+	}
+	return nil
+}
+
+// Plan describes the changes of the wrapped step when it can describe them
+func (s *adoptedStep) Plan(ctx context.Context) []string {
+	if planner, ok := s.Executor.(Planner); ok {
+		return planner.Plan(ctx)
+	}
+	return nil
+}
+
+// Details returns the details the wrapped step reports
+func (s *adoptedStep) Details() []string {
+	if reporter, ok := s.Executor.(DetailReporter); ok {
+		return reporter.Details()
+	}
+	return nil
+}
+
+// unwrap returns the wrapped step
+func (s *adoptedStep) unwrap() Executor {
+	return s.Executor
+}
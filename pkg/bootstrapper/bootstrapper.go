@@ -2,19 +2,35 @@ package bootstrapper
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
 
+	"go.goms.io/aks/AKSFlexNode/pkg/components/agent_service"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ca_trust"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/gpu"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_binaries"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_proxy"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_vip"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/node_local_dns"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/npd"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/runc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/secure_cleanup"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/services"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/storage"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/system_configuration"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/time_sync"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/windows_node"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/platform"
+	"go.goms.io/aks/AKSFlexNode/pkg/preflight"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
 )
 
 // Bootstrapper executes bootstrap steps sequentially
@@ -24,43 +40,231 @@ type Bootstrapper struct {
 
 // New creates a new bootstrapper
 func New(cfg *config.Config, logger *logrus.Logger) *Bootstrapper {
+	base := NewBaseExecutor(cfg, logger)
+	if cfg.IsProgressReportingEnabled() {
+		base.progressReporter = arc.NewProgressReporter(cfg, logger)
+	}
 	return &Bootstrapper{
-		BaseExecutor: NewBaseExecutor(cfg, logger),
+		BaseExecutor: base,
 	}
 }
 
-// Bootstrap executes all bootstrap steps sequentially
-func (b *Bootstrapper) Bootstrap(ctx context.Context) (*ExecutionResult, error) {
-	// Define the bootstrap steps in order - using modules directly
-	steps := []Executor{
-		arc.NewInstaller(b.logger),                  // Setup Arc
-		services.NewUnInstaller(b.logger),           // Stop kubelet before setup
-		system_configuration.NewInstaller(b.logger), // Configure system (early)
-		runc.NewInstaller(b.logger),                 // Install runc
-		containerd.NewInstaller(b.logger),           // Install containerd
-		kube_binaries.NewInstaller(b.logger),        // Install k8s binaries
-		cni.NewInstaller(b.logger),                  // Setup CNI (after container runtime)
-		kubelet.NewInstaller(b.logger),              // Configure kubelet service with Arc MSI auth
-		npd.NewInstaller(b.logger),                  // Install Node Problem Detector
-		services.NewInstaller(b.logger),             // Start services
+// Step is a registered step and a description of what it does
+type Step struct {
+	Executor    Executor
+	Description string
+}
+
+// newStep builds the executor of a step with a logger of its own, which carries the step fields while the step runs
+func newStep[E Executor](b *Bootstrapper, newExecutor func(*logrus.Logger) E) E {
+	stepLogger := logger.NewStepLogger(b.logger)
+	executor := newExecutor(stepLogger)
+	b.stepLoggers.Store(Executor(executor), stepLogger)
+	return executor
+}
+
+// bootstrapDependencies lists the bootstrap steps that do not have to wait for every earlier step, with the steps
+// they wait for. The runc, containerd and Kubernetes downloads are independent and run at once after the
+// data disk is mounted; the CNI setup needs containerd and kubectl. Steps not listed run after all earlier steps.
+var bootstrapDependencies = map[string][]string{
+	"Runc_Installer":        {"SystemConfigured", "Storage_Installer"},
+	"ContainerdInstaller":   {"SystemConfigured", "Storage_Installer"},
+	"KubeBinariesInstaller": {"SystemConfigured", "Storage_Installer"},
+	"CNISetup":              {"ContainerdInstaller", "KubeBinariesInstaller"},
+}
+
+// BootstrapSteps returns the bootstrap steps of the platform the agent runs on in execution order
+func (b *Bootstrapper) BootstrapSteps() []Step {
+	if platform.Current().IsWindows() {
+		return b.windowsBootstrapSteps()
+	}
+	return []Step{
+		{newStep(b, ca_trust.NewInstaller), "Trust private CAs before any download (optional)"},
+		{newStep(b, preflight.NewHostChecker), "Block hosts that cannot run a node: kernel modules, swap, disk, connectivity, port 10250, privileges"},
+		{newStep(b, preflight.NewClusterChecker), "Block incompatible cluster features early"},
+		{newStep(b, preflight.NewPackageChecker), "Install host packages components require"},
+		{newStep(b, preflight.NewIPTablesChecker), "Use one iptables backend for kubelet and pods"},
+		{newStep(b, preflight.NewEBPFChecker), "Report kernel eBPF support, required for kube-proxy replacement"},
+		{newStep(b, preflight.NewStagingChecker), "Stage downloads on a filesystem that allows execution"},
+		{newStep(b, time_sync.NewInstaller), "Synchronize the clock before Arc and TLS authentication rely on it (optional)"},
+		{newStep(b, arc.NewInstaller), "Set up Arc"},
+		{newStep(b, kubelet.NewAdopter), "Adopt an existing healthy kubelet (opt-in)"},
+		{newStep(b, kubelet.NewNodeNameChecker), "Detect a duplicate node name before registering"},
+		{newStep(b, services.NewPreBootstrapUnInstaller), "Stop kubelet before setup"},
+		{keepAdopted(newStep(b, system_configuration.NewInstaller)), "Configure system (early)"},
+		{keepAdopted(newStep(b, storage.NewInstaller)), "Place containerd and kubelet data on the data disk (optional)"},
+		{keepAdopted(newStep(b, runc.NewInstaller)), "Install runc"},
+		{keepAdopted(newStep(b, containerd.NewInstaller)), "Install containerd"},
+		{keepAdopted(newStep(b, kube_binaries.NewInstaller)), "Install k8s binaries"},
+		{keepAdopted(newStep(b, cni.NewInstaller)), "Set up CNI (after container runtime)"},
+		{keepAdopted(newStep(b, gpu.NewInstaller)), "Set up NVIDIA GPU driver and container runtime (optional)"},
+		{newStep(b, kubelet.NewInstaller), "Configure kubelet service with Arc MSI auth"},
+		{newStep(b, npd.NewInstaller), "Install Node Problem Detector"},
+		{keepAdopted(newStep(b, kube_proxy.NewInstaller)), "Run kube-proxy as a systemd service (optional)"},
+		{newStep(b, kube_vip.NewInstaller), "Install kube-vip static pod (optional)"},
+		{newStep(b, node_local_dns.NewInstaller), "Deploy node-local DNS cache static pod (optional)"},
+		{keepAdopted(newStep(b, services.NewInstaller)), "Start services"},
+		{newStep(b, kubelet.NewReadyGate), "Wait for the node to become Ready (optional)"},
+		{newStep(b, kubelet.NewServingCertChecker), "Check the kubelet serving certificate request was approved (optional)"},
+	}
+}
+
+// UnbootstrapSteps returns the cleanup steps of the platform the agent runs on in execution order (reverse
+// order of bootstrap)
+func (b *Bootstrapper) UnbootstrapSteps() []Step {
+	if platform.Current().IsWindows() {
+		return b.windowsUnbootstrapSteps()
+	}
+	return []Step{
+		{newStep(b, kubelet.NewDrainer), "Drain and delete the node while kubelet still runs"},
+		{newStep(b, services.NewUnInstaller), "Stop services after the drain"},
+		{newStep(b, secure_cleanup.NewUnInstaller), "Shred credentials before their directories are removed"},
+		{newStep(b, node_local_dns.NewUnInstaller), "Remove node-local DNS cache static pod"},
+		{newStep(b, kube_vip.NewUnInstaller), "Remove kube-vip static pod"},
+		{newStep(b, kube_proxy.NewUnInstaller), "Stop kube-proxy and remove its rules"},
+		{newStep(b, npd.NewUnInstaller), "Uninstall Node Problem Detector"},
+		{newStep(b, kubelet.NewUnInstaller), "Clean kubelet configuration"},
+		{newStep(b, cni.NewUnInstaller), "Clean CNI configs"},
+		{newStep(b, gpu.NewUnInstaller), "Remove NVIDIA packages the agent installed (before containerd)"},
+		{newStep(b, kube_binaries.NewUnInstaller), "Uninstall k8s binaries"},
+		{newStep(b, containerd.NewUnInstaller), "Uninstall containerd binary"},
+		{newStep(b, runc.NewUnInstaller), "Uninstall runc binary"},
+		{newStep(b, storage.NewUnInstaller), "Unmount the data directories and the data disk"},
+		{newStep(b, system_configuration.NewUnInstaller), "Clean system settings"},
+		{newStep(b, time_sync.NewUnInstaller), "Remove the configured NTP servers"},
+		{newStep(b, arc.NewUnInstaller), "Uninstall Arc (after cleanup)"},
+		{newStep(b, ca_trust.NewUnInstaller), "Remove trusted CAs (after Arc, which may need them)"},
+		{newStep(b, preflight.NewPackageUnInstaller), "Remove host packages the agent installed (after Arc cleanup, which may use them)"},
+		{newStep(b, agent_service.NewUnInstaller), "Remove the agent service, its sudoers rules and user (last, earlier steps may run through them)"},
 	}
+}
+
+// UpgradeSteps returns the steps upgrading kubelet and the Kubernetes binaries of a running node in execution
+// order, none on Windows, where upgrades are not supported yet
+func (b *Bootstrapper) UpgradeSteps() []Step {
+	if platform.Current().IsWindows() {
+		return nil
+	}
+	return []Step{
+		{newStep(b, kubelet.NewUpgradeDrainer), "Cordon and drain the node before kubelet restarts"},
+		{newStep(b, kube_binaries.NewInstaller), "Install the k8s binaries of the new version"},
+		{newStep(b, kubelet.NewInstaller), "Configure kubelet for the new version"},
+		{newStep(b, kubelet.NewRestarter), "Restart kubelet and kube-proxy on the new binaries"},
+		{newStep(b, kubelet.NewReadyGate), "Wait for the node to become Ready (optional)"},
+		{newStep(b, kubelet.NewUncordoner), "Uncordon the node if the upgrade cordoned it"},
+	}
+}
+
+// windowsBootstrapSteps returns the bootstrap steps of Windows nodes, which run kubelet and containerd only:
+// CNI, GPU, Arc and the host configuration of Linux nodes are not supported there yet
+func (b *Bootstrapper) windowsBootstrapSteps() []Step {
+	return []Step{
+		{newStep(b, preflight.NewClusterChecker), "Block incompatible cluster features early"},
+		{newStep(b, windows_node.NewContainerdInstaller), "Install containerd as a Windows service"},
+		{newStep(b, windows_node.NewKubeletInstaller), "Install kubelet as a Windows service with service principal auth"},
+	}
+}
+
+// windowsUnbootstrapSteps returns the cleanup steps of Windows nodes
+func (b *Bootstrapper) windowsUnbootstrapSteps() []Step {
+	return []Step{
+		{newStep(b, windows_node.NewUnInstaller), "Remove the kubelet and containerd services and files"},
+	}
+}
+
+// Bootstrap executes all bootstrap steps, recording the outcome of each in the bootstrap state
+func (b *Bootstrapper) Bootstrap(ctx context.Context) (*ExecutionResult, error) {
+	return b.ResumeBootstrap(ctx, ResumeOptions{})
+}
 
-	return b.ExecuteSteps(ctx, steps, "bootstrap")
+// ResumeBootstrap executes the bootstrap steps like Bootstrap, skipping the steps opts selects from the
+// bootstrap state of an earlier bootstrap instead of checking whether they are completed
+func (b *Bootstrapper) ResumeBootstrap(ctx context.Context, opts ResumeOptions) (*ExecutionResult, error) {
+	// The cluster preflight also selects the version, but a resumed bootstrap may skip it
+	if err := spec.NewCollector(b.logger).ResolveKubernetesVersion(ctx); err != nil {
+		return nil, err
+	}
+	steps := executors(b.BootstrapSteps())
+	hash, err := inputsHash(b.config)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := status.LoadBootstrapState(b.stateFilePath)
+	if err != nil {
+		if opts.Resume {
+			return nil, err
+		}
+		b.logger.Warnf("Ignoring the recorded bootstrap state: %v", err)
+	}
+	skipped, err := resumeSkips(steps, previous, hash, opts, b.logger)
+	if err != nil {
+		return nil, err
+	}
+	state := newStateRecorder(b.stateFilePath, steps, hash, previous, skipped, b.logger)
+	return b.executeGraph(ctx, steps, bootstrapDependencies, b.config.GetParallelSteps(), "bootstrap", true, state)
 }
 
-// Unbootstrap executes all cleanup steps sequentially (in reverse order of bootstrap)
+// Unbootstrap executes all cleanup steps sequentially (in reverse order of bootstrap) and removes the
+// bootstrap state, as there is nothing left to resume
 func (b *Bootstrapper) Unbootstrap(ctx context.Context) (*ExecutionResult, error) {
-	steps := []Executor{
-		services.NewUnInstaller(b.logger),             // Stop services first
-		npd.NewUnInstaller(b.logger),                  // Uninstall Node Problem Detector
-		kubelet.NewUnInstaller(b.logger),              // Clean kubelet configuration
-		cni.NewUnInstaller(b.logger),                  // Clean CNI configs
-		kube_binaries.NewUnInstaller(b.logger),        // Uninstall k8s binaries
-		containerd.NewUnInstaller(b.logger),           // Uninstall containerd binary
-		runc.NewUnInstaller(b.logger),                 // Uninstall runc binary
-		system_configuration.NewUnInstaller(b.logger), // Clean system settings
-		arc.NewUnInstaller(b.logger),                  // Uninstall Arc (after cleanup)
-	}
-
-	return b.ExecuteSteps(ctx, steps, "unbootstrap")
+	result, err := b.ExecuteSteps(ctx, executors(b.UnbootstrapSteps()), "unbootstrap", false)
+	if clearErr := status.ClearBootstrapState(b.stateFilePath); clearErr != nil {
+		b.logger.Warnf("Failed to remove bootstrap state: %v", clearErr)
+	}
+	return result, err
+}
+
+// Upgrade upgrades kubelet and the Kubernetes binaries of the running node to version: the node is drained,
+// the binaries replaced and kubelet restarted before the node is uncordoned
+func (b *Bootstrapper) Upgrade(ctx context.Context, version string) (*ExecutionResult, error) {
+	if platform.Current().IsWindows() {
+		return nil, fmt.Errorf("upgrading Kubernetes is not supported on Windows nodes yet")
+	}
+	b.config.SetResolvedKubernetesVersion(version)
+	return b.executeUpgrade(ctx, executors(b.UpgradeSteps()))
+}
+
+// executeUpgrade executes the upgrade steps, stopping at the first failure: a node that could not be drained
+// must not have kubelet restarted under its running pods
+func (b *Bootstrapper) executeUpgrade(ctx context.Context, steps []Executor) (*ExecutionResult, error) {
+	return b.ExecuteSteps(ctx, steps, "upgrade", true)
+}
+
+// PlanBootstrap describes what Bootstrap would do without changing the host
+func (b *Bootstrapper) PlanBootstrap(ctx context.Context) *ExecutionPlan {
+	b.resolvePlannedVersion(ctx)
+	return b.plan(ctx, b.BootstrapSteps(), "bootstrap")
+}
+
+// resolvePlannedVersion selects the version for kubernetes.version "auto" or omitted like Bootstrap does, from the
+// cached cluster spec and without writing the cache. When it cannot be selected, the steps needing it report so.
+func (b *Bootstrapper) resolvePlannedVersion(ctx context.Context) {
+	if err := spec.NewReadOnlyCollector(b.logger).ResolveKubernetesVersion(ctx); err != nil {
+		b.logger.Warnf("Planning without a Kubernetes version: %v", err)
+	}
+}
+
+// PlanUnbootstrap describes what Unbootstrap would do without changing the host
+func (b *Bootstrapper) PlanUnbootstrap(ctx context.Context) *ExecutionPlan {
+	return b.plan(ctx, b.UnbootstrapSteps(), "unbootstrap")
+}
+
+// plan plans the steps, describing the steps that cannot describe their own actions by their registration
+func (b *Bootstrapper) plan(ctx context.Context, steps []Step, stepType string) *ExecutionPlan {
+	plan := b.PlanSteps(ctx, executors(steps), stepType)
+	for i := range plan.Steps {
+		if stepPlan := &plan.Steps[i]; !stepPlan.Completed && len(stepPlan.Actions) == 0 {
+			stepPlan.Actions = []string{steps[i].Description}
+		}
+	}
+	return plan
+}
+
+// executors returns the executors of steps
+func executors(steps []Step) []Executor {
+	result := make([]Executor, 0, len(steps))
+	for _, step := range steps {
+		result = append(result, step.Executor)
+	}
+	return result
 }
@@ -4,10 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/audit"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
 )
 
 // executor is a common base interface for all executors
@@ -32,6 +39,18 @@ type StepExecutor interface {
 	Validate(ctx context.Context) error
 }
 
+// DetailReporter is implemented by steps that record what they did in the execution result,
+// for example to prove which files a cleanup step removed
+type DetailReporter interface {
+	Details() []string
+}
+
+// Planner is implemented by steps that can describe the changes Execute would make, for dry runs.
+// Plan must not change the host: it only describes files written, services restarted and Azure resources created.
+type Planner interface {
+	Plan(ctx context.Context) []string
+}
+
 // ExecutionResult represents the result of bootstrap or unbootstrap process
 type ExecutionResult struct {
 	Success     bool          `json:"success"`
@@ -47,53 +66,158 @@ type StepResult struct {
 	Success  bool          `json:"success"`
 	Duration time.Duration `json:"duration"`
 	Error    string        `json:"error,omitempty"`
+	Details  []string      `json:"details,omitempty"`
+	Skipped  string        `json:"skipped,omitempty"` // why a resumed bootstrap skipped the step without checking it
+
+	// FailureClass tells whether retrying a failed step may succeed: transient, permanent or unknown
+	FailureClass failure.Class `json:"failure_class,omitempty"`
+}
+
+// ExecutionPlan describes what bootstrap or unbootstrap would do, without doing it
+type ExecutionPlan struct {
+	Operation string     `json:"operation"`
+	Steps     []StepPlan `json:"steps"`
+}
+
+// StepPlan describes what a single step would do
+type StepPlan struct {
+	StepName  string   `json:"step_name"`
+	Completed bool     `json:"completed"`         // the step is already completed and would be skipped
+	Actions   []string `json:"actions,omitempty"` // changes the step would make
+	Error     string   `json:"error,omitempty"`   // validation failure that would stop the step
+
+	// SkippedCommands are commands the completion check and validation would have run but that change the host,
+	// so the dry run recorded them instead
+	SkippedCommands []string `json:"skipped_commands,omitempty"`
 }
 
 // BaseExecutor provides common functionality for bootstrap and unbootstrap operations
 type BaseExecutor struct {
-	config *config.Config
-	logger *logrus.Logger
+	config           *config.Config
+	logger           *logrus.Logger
+	progressFilePath string
+	stateFilePath    string
+	progressReporter ProgressReporter // publishes bootstrap progress outside the machine, nil when not configured
+	stepLoggers      sync.Map         // step Executor to the logger it logs through, see newStep
 }
 
 // NewBaseExecutor creates a new base executor
 func NewBaseExecutor(cfg *config.Config, logger *logrus.Logger) *BaseExecutor {
 	return &BaseExecutor{
-		config: cfg,
-		logger: logger,
+		config:           cfg,
+		logger:           logger,
+		progressFilePath: status.GetProgressFilePath(),
+		stateFilePath:    status.BootstrapStateFilePath,
 	}
 }
 
-// ExecuteSteps executes a list of steps and returns results
-func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, stepType string) (*ExecutionResult, error) {
+// ExecuteSteps executes a list of steps one after the other and returns results. With failFast no further step
+// starts after a failure; without it the remaining steps still run, for best effort cleanup.
+func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, stepType string, failFast bool) (*ExecutionResult, error) {
+	return be.ExecuteGraph(ctx, steps, nil, 1, stepType, failFast)
+}
+
+// ExecuteGraph executes steps as soon as the steps they depend on finished, running up to parallel steps at once,
+// and returns results in the order the steps finished. after maps a step name to the names of the earlier steps
+// it waits for; a step without an entry waits for every earlier step, so steps keep their order unless they
+// declare their dependencies. With failFast no further step starts after a failure and the running ones are waited for.
+func (be *BaseExecutor) ExecuteGraph(ctx context.Context, steps []Executor, after map[string][]string, parallel int, stepType string, failFast bool) (*ExecutionResult, error) {
+	return be.executeGraph(ctx, steps, after, parallel, stepType, failFast, nil)
+}
+
+// executeGraph executes steps like ExecuteGraph, recording their outcome with state unless it is nil.
+// The steps state says to skip are not run and count as finished.
+func (be *BaseExecutor) executeGraph(ctx context.Context, steps []Executor, after map[string][]string, parallel int, stepType string, failFast bool, state *stateRecorder) (*ExecutionResult, error) {
+	dependencies, err := stepDependencies(steps, after)
+	if err != nil {
+		return nil, err
+	}
+	parallel = max(parallel, 1)
 	be.logger.Infof("Starting AKS node %s", stepType)
 
 	startTime := time.Now()
 	result := &ExecutionResult{
 		StepResults: make([]StepResult, 0),
 	}
+	var reporter ProgressReporter
+	if stepType == "bootstrap" {
+		reporter = be.progressReporter
+	}
+	progress := newProgressTracker(be.progressFilePath, stepType, len(steps), reporter, be.logger)
 
-	// Execute each step
-	for _, step := range steps {
-		stepResult := be.executeStep(ctx, step, stepType)
-		result.StepResults = append(result.StepResults, stepResult)
-
-		if !stepResult.Success {
-			if stepType == "bootstrap" {
-				// Bootstrap fails fast on first error
-				result.Success = false
-				result.Error = stepResult.Error
-				result.Duration = time.Since(startTime)
-				result.StepCount = len(result.StepResults)
+	type finishedStep struct {
+		index  int
+		result StepResult
+	}
+	finished := make(chan finishedStep)
+	started := make([]bool, len(steps))
+	done := make([]bool, len(steps))
+	running := 0
+	var failed *StepResult
 
-				be.logger.Errorf("Bootstrap failed at step %s: %s (completedSteps: %d, totalSteps: %d)",
-					stepResult.StepName, stepResult.Error, len(result.StepResults), len(steps))
+	for {
+		// Start the steps whose dependencies finished, in pipeline order
+		for index, step := range steps {
+			if running >= parallel || failed != nil {
+				break
+			}
+			if started[index] || !allDone(dependencies[index], done) {
+				continue
+			}
+			started[index] = true
+			if reason, ok := state.skip(step.GetName()); ok {
+				be.logger.Infof("%s step %s skipped, %s", stepType, step.GetName(), reason)
+				done[index] = true
+				result.StepResults = append(result.StepResults, StepResult{StepName: step.GetName(), Success: true, Skipped: reason})
+				continue
+			}
+			running++
+			go func() {
+				stopHeartbeat := progress.stepStarted(index, step.GetName())
+				stepResult := be.executeStep(ctx, step, stepType)
+				stopHeartbeat()
+				finished <- finishedStep{index: index, result: stepResult}
+			}()
+		}
+		if running == 0 {
+			break
+		}
 
-				return result, fmt.Errorf("bootstrap failed at step %s: %w", stepResult.StepName, errors.New(stepResult.Error))
+		step := <-finished
+		running--
+		done[step.index] = true
+		stepResult := step.result
+		result.StepResults = append(result.StepResults, stepResult)
+		state.stepFinished(stepResult)
+		if stepResult.Success {
+			continue
+		}
+		progress.stepFailed(stepResult.Error, stepResult.FailureClass)
+		if failFast {
+			// Bootstrap and upgrade fail fast on the first error, letting the steps already running finish
+			if failed == nil {
+				failed = &stepResult
 			}
-			// Unbootstrap continues even if some steps fail for best effort cleanup
-			be.logger.Warnf("Cleanup step %s failed: %s (continuing with remaining steps)",
-				stepResult.StepName, stepResult.Error)
+			continue
 		}
+		// Unbootstrap continues even if some steps fail for best effort cleanup
+		be.logger.Warnf("Cleanup step %s failed: %s (continuing with remaining steps)",
+			stepResult.StepName, stepResult.Error)
+	}
+
+	if failed != nil {
+		progress.finish(false)
+		result.Success = false
+		result.Error = failed.Error
+		result.Duration = time.Since(startTime)
+		result.StepCount = len(result.StepResults)
+
+		be.logger.Errorf("AKS node %s failed at step %s: %s (completedSteps: %d, totalSteps: %d, failureClass: %s)",
+			stepType, failed.StepName, failed.Error, len(result.StepResults), len(steps), failed.FailureClass)
+
+		// Keep the failure class so callers can decide whether to retry
+		return result, failure.WithClass(failed.FailureClass,
+			fmt.Errorf("%s failed at step %s: %w", stepType, failed.StepName, errors.New(failed.Error)))
 	}
 
 	// Calculate final result
@@ -101,11 +225,12 @@ func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, step
 	result.Success = successfulSteps == len(steps)
 	result.Duration = time.Since(startTime)
 	result.StepCount = len(result.StepResults)
+	progress.finish(result.Success)
 
 	if result.Success {
 		be.logger.Infof("AKS node %s completed successfully (duration: %v, stepCount: %d)",
 			stepType, result.Duration, result.StepCount)
-	} else if stepType == "unbootstrap" {
+	} else {
 		be.logger.Warnf("AKS node %s completed with some failures (duration: %v, successfulSteps: %d, totalSteps: %d)",
 			stepType, result.Duration, successfulSteps, len(steps))
 		result.Error = fmt.Sprintf("completed with %d failed steps out of %d total steps",
@@ -115,16 +240,53 @@ func (be *BaseExecutor) ExecuteSteps(ctx context.Context, steps []Executor, step
 	return result, nil
 }
 
+// stepDependencies returns the indexes of the steps each step waits for. A step without an entry in after
+// waits for every earlier step; a dependency must name an earlier step, which keeps the graph acyclic.
+func stepDependencies(steps []Executor, after map[string][]string) ([][]int, error) {
+	indexes := make(map[string]int, len(steps))
+	dependencies := make([][]int, len(steps))
+	for index, step := range steps {
+		names, declared := after[step.GetName()]
+		if !declared {
+			for earlier := range index {
+				dependencies[index] = append(dependencies[index], earlier)
+			}
+		}
+		for _, name := range names {
+			earlier, ok := indexes[name]
+			if !ok {
+				return nil, fmt.Errorf("step %s depends on %s, which is not an earlier step", step.GetName(), name)
+			}
+			dependencies[index] = append(dependencies[index], earlier)
+		}
+		indexes[step.GetName()] = index
+	}
+	return dependencies, nil
+}
+
+// allDone reports whether all the given steps finished
+func allDone(indexes []int, done []bool) bool {
+	for _, index := range indexes {
+		if !done[index] {
+			return false
+		}
+	}
+	return true
+}
+
 // executeStep executes a single step and returns the result
 func (be *BaseExecutor) executeStep(ctx context.Context, step Executor, stepType string) StepResult {
 	stepName := step.GetName()
 	startTime := time.Now()
+	log := be.stepLogger(step)
+	defer logger.BeginStep(log, stepName, componentOf(step))()
+	defer audit.BeginStep(stepName)()
 
-	be.logger.Infof("Executing %s step %s", stepType, stepName)
+	log.Infof("Executing %s step %s", stepType, stepName)
 
 	// Check if step is already completed
 	if step.IsCompleted(ctx) {
-		be.logger.Infof("%s step: %s already completed", stepType, stepName)
+		log.Infof("%s step: %s already completed", stepType, stepName)
 		return be.createStepResult(stepName, startTime, true, "")
 	}
 
@@ -132,20 +294,67 @@ func (be *BaseExecutor) executeStep(ctx context.Context, step Executor, stepType
 	if bootstrapStep, ok := step.(StepExecutor); ok && stepType == "bootstrap" {
 		// Validate preconditions for bootstrap steps
 		if validationErr := bootstrapStep.Validate(ctx); validationErr != nil {
-			be.logger.Errorf("%s step %s validation failed with error: %s", stepType, stepName, validationErr)
-			return be.createStepResult(stepName, startTime, false, fmt.Sprintf("validation failed: %v", validationErr))
+			log.Errorf("%s step %s validation failed with error: %s", stepType, stepName, validationErr)
+			result := be.createStepResult(stepName, startTime, false, fmt.Sprintf("validation failed: %v", validationErr))
+			// Unmet preconditions do not change by retrying unless the error says otherwise
+			result.FailureClass = failure.Classify(validationErr)
+			if result.FailureClass == failure.ClassUnknown {
+				result.FailureClass = failure.ClassPermanent
+			}
+			return result
 		}
 	}
 
 	// Execute the step
 	err = step.Execute(ctx)
 	if err != nil {
-		be.logger.Errorf("%s step: %s failed with error: %s with duration %s", stepType, stepName, err, time.Since(startTime))
-		return be.createStepResult(stepName, startTime, false, err.Error())
+		log.Errorf("%s step: %s failed with error: %s with duration %s", stepType, stepName, err, time.Since(startTime))
+		result := be.createStepResult(stepName, startTime, false, err.Error())
+		result.FailureClass = failure.Classify(err)
+		return be.withDetails(log, step, result)
+	}
+
+	log.Infof("%s step: %s completed successfully with duration %s", stepType, stepName, time.Since(startTime))
+	return be.withDetails(log, step, be.createStepResult(stepName, startTime, true, ""))
+}
+
+// stepLogger returns the logger the step was built with by newStep, or a new step logger for the executor's own
+// entries about steps built otherwise
+func (be *BaseExecutor) stepLogger(step Executor) *logrus.Logger {
+	if wrapper, ok := step.(interface{ unwrap() Executor }); ok {
+		step = wrapper.unwrap()
+	}
+	if reflect.TypeOf(step).Comparable() {
+		if stepLogger, ok := be.stepLoggers.Load(step); ok {
+			return stepLogger.(*logrus.Logger)
+		}
 	}
+	return logger.NewStepLogger(be.logger)
+}
 
-	be.logger.Infof("%s step: %s completed successfully with duration %s", stepType, stepName, time.Since(startTime))
-	return be.createStepResult(stepName, startTime, true, "")
+// componentOf returns the component a step belongs to, the name of the package implementing it
+func componentOf(step Executor) string {
+	if wrapper, ok := step.(interface{ unwrap() Executor }); ok {
+		step = wrapper.unwrap()
+	}
+	t := reflect.TypeOf(step)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return path.Base(t.PkgPath())
+}
+
+// withDetails attaches and logs the details reported by steps implementing DetailReporter
+func (be *BaseExecutor) withDetails(log *logrus.Logger, step Executor, result StepResult) StepResult {
+	reporter, ok := step.(DetailReporter)
+	if !ok {
+		return result
+	}
+	result.Details = reporter.Details()
+	for _, detail := range result.Details {
+		log.Infof("%s: %s", result.StepName, detail)
+	}
+	return result
 }
 
 // createStepResult creates a StepResult with consistent formatting
@@ -0,0 +1,472 @@
+package bootstrapper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_binaries"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+type fakeStep struct {
+	name string
+	err  error
+}
+
+func (s *fakeStep) Execute(context.Context) error    { return s.err }
+func (s *fakeStep) IsCompleted(context.Context) bool { return false }
+func (s *fakeStep) GetName() string                  { return s.name }
+func (s *fakeStep) Validate(context.Context) error   { return nil }
+
+func newTestExecutor(t *testing.T) *BaseExecutor {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &BaseExecutor{
+		logger:           logger,
+		progressFilePath: filepath.Join(t.TempDir(), "progress.json"),
+	}
+}
+
+func TestExecuteStepsWritesProgress(t *testing.T) {
+	tests := []struct {
+		name          string
+		steps         []Executor
+		wantPhase     string
+		wantStep      string
+		wantStepIndex int
+		wantLastError string
+	}{
+		{
+			name:          "successful bootstrap",
+			steps:         []Executor{&fakeStep{name: "First"}, &fakeStep{name: "Second"}},
+			wantPhase:     status.ProgressSucceeded,
+			wantStep:      "Second",
+			wantStepIndex: 2,
+		},
+		{
+			name:          "failed bootstrap stops at failing step",
+			steps:         []Executor{&fakeStep{name: "First", err: errors.New("boom")}, &fakeStep{name: "Second"}},
+			wantPhase:     status.ProgressFailed,
+			wantStep:      "First",
+			wantStepIndex: 1,
+			wantLastError: "boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := newTestExecutor(t)
+			_, _ = executor.ExecuteSteps(context.Background(), tt.steps, "bootstrap", true)
+
+			progress, err := status.ReadProgress(executor.progressFilePath)
+			if err != nil {
+				t.Fatalf("ReadProgress() error = %v", err)
+			}
+			if progress.Operation != "bootstrap" || progress.Phase != tt.wantPhase {
+				t.Errorf("progress = %s/%s, want bootstrap/%s", progress.Operation, progress.Phase, tt.wantPhase)
+			}
+			if progress.CurrentStep != tt.wantStep || progress.StepIndex != tt.wantStepIndex || progress.TotalSteps != len(tt.steps) {
+				t.Errorf("progress step = %s (%d/%d), want %s (%d/%d)",
+					progress.CurrentStep, progress.StepIndex, progress.TotalSteps, tt.wantStep, tt.wantStepIndex, len(tt.steps))
+			}
+			if progress.LastError != tt.wantLastError {
+				t.Errorf("progress lastError = %q, want %q", progress.LastError, tt.wantLastError)
+			}
+			if progress.StartedAt.IsZero() || progress.UpdatedAt.Before(progress.StartedAt) {
+				t.Errorf("progress timestamps not set: startedAt=%v updatedAt=%v", progress.StartedAt, progress.UpdatedAt)
+			}
+		})
+	}
+}
+
+type fakeProgressReporter struct {
+	reports []status.Progress
+	flushed bool
+}
+
+func (r *fakeProgressReporter) Report(progress status.Progress) {
+	r.reports = append(r.reports, progress)
+}
+func (r *fakeProgressReporter) Flush(time.Duration) { r.flushed = true }
+
+func TestExecuteStepsReportsBootstrapProgress(t *testing.T) {
+	executor := newTestExecutor(t)
+	reporter := &fakeProgressReporter{}
+	executor.progressReporter = reporter
+	steps := []Executor{&fakeStep{name: "First"}, &fakeStep{name: "Second", err: errors.New("boom")}}
+
+	_, _ = executor.ExecuteSteps(context.Background(), steps, "bootstrap", true)
+	if !reporter.flushed || len(reporter.reports) == 0 {
+		t.Fatalf("bootstrap progress was not reported and flushed: %+v", reporter)
+	}
+	final := reporter.reports[len(reporter.reports)-1]
+	if final.Phase != status.ProgressFailed || final.CurrentStep != "Second" || final.LastError != "boom" {
+		t.Errorf("final report = %+v, want the failure of Second", final)
+	}
+
+	// Unbootstrap removes the Arc machine, its progress is not reported
+	unbootstrapReporter := &fakeProgressReporter{}
+	executor.progressReporter = unbootstrapReporter
+	_, _ = executor.ExecuteSteps(context.Background(), steps, "unbootstrap", false)
+	if len(unbootstrapReporter.reports) != 0 {
+		t.Errorf("unbootstrap reported %d progress updates, want none", len(unbootstrapReporter.reports))
+	}
+}
+
+type reportingStep struct {
+	fakeStep
+	details []string
+}
+
+func (s *reportingStep) Details() []string { return s.details }
+
+func TestExecuteStepsRecordsDetails(t *testing.T) {
+	be := newTestExecutor(t)
+	steps := []Executor{
+		&fakeStep{name: "Plain"},
+		&reportingStep{fakeStep: fakeStep{name: "Reporting"}, details: []string{"shredded /var/lib/kubelet/token.sh"}},
+	}
+
+	result, err := be.ExecuteSteps(context.Background(), steps, "unbootstrap", false)
+	if err != nil {
+		t.Fatalf("ExecuteSteps() unexpected error: %v", err)
+	}
+	if got := result.StepResults[0].Details; got != nil {
+		t.Errorf("step without DetailReporter has details %v", got)
+	}
+	if got := result.StepResults[1].Details; len(got) != 1 || got[0] != "shredded /var/lib/kubelet/token.sh" {
+		t.Errorf("reporting step details = %v, want the reported detail", got)
+	}
+}
+
+func TestExecuteStepsClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want failure.Class
+	}{
+		{name: "unclassified error", err: errors.New("boom"), want: failure.ClassUnknown},
+		{name: "transient error", err: failure.Transient(errors.New("registry unreachable")), want: failure.ClassTransient},
+		{name: "permanent error", err: failure.Permanent(errors.New("kernel too old")), want: failure.ClassPermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := newTestExecutor(t)
+			result, err := executor.ExecuteSteps(context.Background(), []Executor{&fakeStep{name: "First", err: tt.err}}, "bootstrap", true)
+
+			if got := result.StepResults[0].FailureClass; got != tt.want {
+				t.Errorf("step failure class = %q, want %q", got, tt.want)
+			}
+			if got := failure.Classify(err); got != tt.want {
+				t.Errorf("Classify(ExecuteSteps() error) = %q, want %q", got, tt.want)
+			}
+			progress, err := status.ReadProgress(executor.progressFilePath)
+			if err != nil {
+				t.Fatalf("ReadProgress() error = %v", err)
+			}
+			if progress.FailureClass != tt.want {
+				t.Errorf("progress failureClass = %q, want %q", progress.FailureClass, tt.want)
+			}
+		})
+	}
+}
+
+type plannedStep struct {
+	fakeStep
+	completed bool
+	validErr  error
+	actions   []string
+}
+
+func (s *plannedStep) IsCompleted(ctx context.Context) bool {
+	// A completion check that would change the host must be recorded, not run
+	_ = sysutil.RunSystemCommandContext(ctx, "mkdir", "-p", "/etc/"+s.name)
+	return s.completed
+}
+func (s *plannedStep) Validate(context.Context) error { return s.validErr }
+func (s *plannedStep) Plan(context.Context) []string  { return s.actions }
+func (s *plannedStep) Execute(context.Context) error  { panic("dry run executed " + s.name) }
+
+func TestPlanSteps(t *testing.T) {
+	be := newTestExecutor(t)
+	steps := []Executor{
+		&plannedStep{fakeStep: fakeStep{name: "Done"}, completed: true},
+		&plannedStep{fakeStep: fakeStep{name: "Install"}, actions: []string{"write /etc/install.conf"}},
+		&plannedStep{fakeStep: fakeStep{name: "Broken"}, validErr: errors.New("no TPM")},
+	}
+
+	plan := be.PlanSteps(context.Background(), steps, "bootstrap")
+	if plan.Operation != "bootstrap" || len(plan.Steps) != len(steps) {
+		t.Fatalf("PlanSteps() = %+v, want a bootstrap plan of %d steps", plan, len(steps))
+	}
+	if done := plan.Steps[0]; !done.Completed || done.Actions != nil {
+		t.Errorf("completed step plan = %+v, want it skipped without actions", done)
+	}
+	if install := plan.Steps[1]; install.Completed || len(install.Actions) != 1 || install.Error != "" {
+		t.Errorf("pending step plan = %+v, want its actions", install)
+	}
+	if broken := plan.Steps[2]; broken.Error != "validation failed: no TPM" {
+		t.Errorf("invalid step error = %q, want the validation failure", broken.Error)
+	}
+	for i, stepPlan := range plan.Steps {
+		want := "mkdir -p /etc/" + steps[i].GetName()
+		if len(stepPlan.SkippedCommands) != 1 || stepPlan.SkippedCommands[0] != want {
+			t.Errorf("step %s skipped commands = %q, want %q", stepPlan.StepName, stepPlan.SkippedCommands, want)
+		}
+	}
+}
+
+func TestComponentOf(t *testing.T) {
+	if got := componentOf(&fakeStep{name: "First"}); got != "bootstrapper" {
+		t.Errorf("componentOf() = %q, want the package of the step", got)
+	}
+}
+
+// concurrentStep waits for its peer to start as well, failing when the two do not run at once
+type concurrentStep struct {
+	fakeStep
+	started chan struct{}
+	peer    chan struct{}
+}
+
+func (s *concurrentStep) Execute(context.Context) error {
+	close(s.started)
+	select {
+	case <-s.peer:
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.New(s.name + " ran alone")
+	}
+}
+
+func TestExecuteGraphRunsIndependentStepsConcurrently(t *testing.T) {
+	be := newTestExecutor(t)
+	downloadA, downloadB := make(chan struct{}), make(chan struct{})
+	steps := []Executor{
+		&fakeStep{name: "Prepare"},
+		&concurrentStep{fakeStep: fakeStep{name: "DownloadA"}, started: downloadA, peer: downloadB},
+		&concurrentStep{fakeStep: fakeStep{name: "DownloadB"}, started: downloadB, peer: downloadA},
+		&fakeStep{name: "Configure"},
+	}
+	after := map[string][]string{"DownloadA": {"Prepare"}, "DownloadB": {"Prepare"}}
+
+	result, err := be.ExecuteGraph(context.Background(), steps, after, 2, "bootstrap", true)
+	if err != nil || !result.Success {
+		t.Fatalf("ExecuteGraph() = %+v, %v, want success", result, err)
+	}
+	if first, last := result.StepResults[0].StepName, result.StepResults[3].StepName; first != "Prepare" || last != "Configure" {
+		t.Errorf("ExecuteGraph() ran %s first and %s last, want Prepare and Configure", first, last)
+	}
+}
+
+// loggingStep logs through the logger it was built with while its peer runs
+type loggingStep struct {
+	concurrentStep
+	logger *logrus.Logger
+}
+
+func (s *loggingStep) Execute(ctx context.Context) error {
+	s.logger.Infof("Downloading %s", s.name)
+	return s.concurrentStep.Execute(ctx)
+}
+
+func TestConcurrentStepsLogTheirOwnStep(t *testing.T) {
+	base, hook := test.NewNullLogger()
+	b := &Bootstrapper{BaseExecutor: &BaseExecutor{logger: base, progressFilePath: filepath.Join(t.TempDir(), "progress.json")}}
+	downloadA, downloadB := make(chan struct{}), make(chan struct{})
+	newDownload := func(name string, started, peer chan struct{}) func(*logrus.Logger) *loggingStep {
+		return func(logger *logrus.Logger) *loggingStep {
+			return &loggingStep{concurrentStep{fakeStep{name: name}, started, peer}, logger}
+		}
+	}
+	steps := []Executor{
+		newStep(b, newDownload("DownloadA", downloadA, downloadB)),
+		newStep(b, newDownload("DownloadB", downloadB, downloadA)),
+	}
+
+	result, err := b.ExecuteGraph(context.Background(), steps, map[string][]string{"DownloadB": {}}, 2, "bootstrap", true)
+	if err != nil || !result.Success {
+		t.Fatalf("ExecuteGraph() = %+v, %v, want success", result, err)
+	}
+	logged := map[string]bool{}
+	for _, entry := range hook.AllEntries() {
+		step, _ := entry.Data[logger.FieldStep].(string)
+		if step == "" {
+			continue
+		}
+		if !strings.HasSuffix(entry.Message, step) && !strings.Contains(entry.Message, " "+step+" ") {
+			t.Errorf("entry %q carries step %q, want the step that logged it", entry.Message, step)
+		}
+		logged[step] = true
+	}
+	if !logged["DownloadA"] || !logged["DownloadB"] {
+		t.Errorf("steps with entries = %v, want both downloads", logged)
+	}
+}
+
+func TestExecuteGraphStopsStartingStepsAfterFailure(t *testing.T) {
+	be := newTestExecutor(t)
+	steps := []Executor{
+		&fakeStep{name: "Broken", err: errors.New("boom")},
+		&fakeStep{name: "Independent"},
+		&fakeStep{name: "Dependent"},
+	}
+	after := map[string][]string{"Independent": {}}
+
+	result, err := be.ExecuteGraph(context.Background(), steps, after, 2, "bootstrap", true)
+	if err == nil || result.Success {
+		t.Fatalf("ExecuteGraph() = %+v, %v, want the failure of Broken", result, err)
+	}
+	for _, stepResult := range result.StepResults {
+		if stepResult.StepName == "Dependent" {
+			t.Errorf("ExecuteGraph() ran Dependent after Broken failed")
+		}
+	}
+	if result.Error != "boom" {
+		t.Errorf("ExecuteGraph() error = %q, want boom", result.Error)
+	}
+}
+
+func TestStepDependencies(t *testing.T) {
+	steps := []Executor{&fakeStep{name: "First"}, &fakeStep{name: "Second"}, &fakeStep{name: "Third"}}
+
+	dependencies, err := stepDependencies(steps, map[string][]string{"Third": {"First"}})
+	if err != nil {
+		t.Fatalf("stepDependencies() error = %v", err)
+	}
+	if !reflect.DeepEqual(dependencies, [][]int{nil, {0}, {0}}) {
+		t.Errorf("stepDependencies() = %v, want Second after every earlier step and Third after First only", dependencies)
+	}
+
+	if _, err := stepDependencies(steps, map[string][]string{"First": {"Third"}}); err == nil {
+		t.Error("stepDependencies() with a dependency on a later step succeeded, want an error")
+	}
+}
+
+func TestBootstrapDependenciesNameEarlierSteps(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	configJSON := `{"azure": {"subscriptionId": "12345678-1234-1234-1234-123456789012", "tenantId": "12345678-1234-1234-1234-123456789012",
+		"cloud": "AzurePublicCloud", "targetCluster": {"location": "eastus", "resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"}}}`
+	if err := os.WriteFile(configFile, []byte(configJSON), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	steps := executors(New(cfg, logger).BootstrapSteps())
+	if _, err := stepDependencies(steps, bootstrapDependencies); err != nil {
+		t.Errorf("bootstrapDependencies do not match the bootstrap steps: %v", err)
+	}
+	for name := range bootstrapDependencies {
+		if !slices.ContainsFunc(steps, func(step Executor) bool { return step.GetName() == name }) {
+			t.Errorf("bootstrapDependencies lists %s, which is not a bootstrap step", name)
+		}
+	}
+}
+
+// recordingStep records whether it was executed
+type recordingStep struct {
+	fakeStep
+	executed bool
+}
+
+func (s *recordingStep) Execute(context.Context) error {
+	s.executed = true
+	return s.err
+}
+
+func TestExecuteUpgradeStopsAfterFailedDrain(t *testing.T) {
+	b := &Bootstrapper{BaseExecutor: newTestExecutor(t)}
+	restart := &recordingStep{fakeStep: fakeStep{name: "KubeletRestarter"}}
+	steps := []Executor{
+		&fakeStep{name: "UpgradeDrainer", err: errors.New("cannot evict pod: disruption budget exceeded")},
+		restart,
+	}
+
+	result, err := b.executeUpgrade(context.Background(), steps)
+	if err == nil || result.Success {
+		t.Fatalf("executeUpgrade() = %+v, %v, want the failure of UpgradeDrainer", result, err)
+	}
+	if restart.executed {
+		t.Error("executeUpgrade() restarted kubelet after the drain failed")
+	}
+	if result.Error != "cannot evict pod: disruption budget exceeded" {
+		t.Errorf("executeUpgrade() error = %q, want the drain failure", result.Error)
+	}
+}
+
+func TestAdoptedStepLeavesAdoptedNodeAlone(t *testing.T) {
+	be := newTestExecutor(t)
+	for _, adopted := range []bool{false, true} {
+		containerd := &recordingStep{fakeStep: fakeStep{name: "ContainerdInstaller"}}
+		step := &adoptedStep{Executor: containerd, adopted: func() bool { return adopted }}
+
+		result, err := be.ExecuteSteps(context.Background(), []Executor{step}, "bootstrap", true)
+		if err != nil || !result.Success {
+			t.Fatalf("ExecuteSteps() = %+v, %v, want success", result, err)
+		}
+		if containerd.executed == adopted {
+			t.Errorf("ExecuteSteps() on a node adopted %v executed the step %v, want %v", adopted, containerd.executed, !adopted)
+		}
+	}
+}
+
+func TestPlanBootstrapResolvesAutoVersion(t *testing.T) {
+	dir := t.TempDir()
+	const clusterID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"
+	configFile := filepath.Join(dir, "config.json")
+	configJSON := `{"azure": {"subscriptionId": "12345678-1234-1234-1234-123456789012", "tenantId": "12345678-1234-1234-1234-123456789012",
+		"cloud": "AzurePublicCloud", "targetCluster": {"location": "eastus", "resourceId": "` + clusterID + `"}},
+		"kubernetes": {"version": "auto"}}`
+	if err := os.WriteFile(configFile, []byte(configJSON), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	previousCache := spec.CacheFilePath
+	spec.CacheFilePath = filepath.Join(dir, "cluster-spec.json")
+	t.Cleanup(func() { spec.CacheFilePath = previousCache })
+	cache := `{"clusterId": "` + clusterID + `", "fetchedAt": "` + time.Now().UTC().Format(time.RFC3339) + `",
+		"spec": {"name": "test-cluster", "kubernetesVersion": "1.32.7"}}`
+	if err := os.WriteFile(spec.CacheFilePath, []byte(cache), 0o600); err != nil {
+		t.Fatalf("failed to write cluster spec cache: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	b := &Bootstrapper{BaseExecutor: newTestExecutor(t)}
+	b.config, b.logger = cfg, logger
+
+	b.resolvePlannedVersion(context.Background())
+	plan := b.plan(context.Background(), []Step{{kube_binaries.NewInstaller(logger), "Install k8s binaries"}}, "bootstrap")
+
+	binaries := plan.Steps[0]
+	if binaries.Completed || binaries.Error != "" {
+		t.Fatalf("kube binaries plan = %+v, want the installation of the resolved version", binaries)
+	}
+	if !slices.ContainsFunc(binaries.Actions, func(action string) bool { return strings.Contains(action, "download Kubernetes 1.32.7 ") }) {
+		t.Errorf("kube binaries actions = %q, want the download of the cluster's version 1.32.7", binaries.Actions)
+	}
+}
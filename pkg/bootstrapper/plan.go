@@ -0,0 +1,44 @@
+package bootstrapper
+
+import (
+	"context"
+	"fmt"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// PlanSteps checks the steps the way ExecuteSteps would, running IsCompleted and, for bootstrap, Validate,
+// but executes none of them. Commands run by the checks go through a dry-run runner, which runs the commands
+// that only inspect the host and records the others instead.
+func (be *BaseExecutor) PlanSteps(ctx context.Context, steps []Executor, stepType string) *ExecutionPlan {
+	runner := sysutil.NewDryRunRunner(sysutil.GetCommandRunner())
+	defer sysutil.SetCommandRunner(runner)()
+
+	be.logger.Infof("Planning AKS node %s (dry run)", stepType)
+	plan := &ExecutionPlan{Operation: stepType, Steps: make([]StepPlan, 0, len(steps))}
+	for _, step := range steps {
+		stepPlan := be.planStep(ctx, step, stepType)
+		stepPlan.SkippedCommands = runner.Drain()
+		plan.Steps = append(plan.Steps, stepPlan)
+	}
+	return plan
+}
+
+// planStep checks a single step and describes what executing it would do
+func (be *BaseExecutor) planStep(ctx context.Context, step Executor, stepType string) StepPlan {
+	stepPlan := StepPlan{StepName: step.GetName()}
+	if step.IsCompleted(ctx) {
+		stepPlan.Completed = true
+		return stepPlan
+	}
+
+	if bootstrapStep, ok := step.(StepExecutor); ok && stepType == "bootstrap" {
+		if err := bootstrapStep.Validate(ctx); err != nil {
+			stepPlan.Error = fmt.Sprintf("validation failed: %v", err)
+		}
+	}
+	if planner, ok := step.(Planner); ok {
+		stepPlan.Actions = planner.Plan(ctx)
+	}
+	return stepPlan
+}
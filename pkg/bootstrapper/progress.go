@@ -0,0 +1,138 @@
+package bootstrapper
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// progressHeartbeatInterval is how often the progress file is refreshed while a step runs,
+// letting pollers tell a slow step apart from a dead agent
+const progressHeartbeatInterval = 15 * time.Second
+
+// progressFlushTimeout bounds how long the final outcome may take to reach the progress reporter
+const progressFlushTimeout = 30 * time.Second
+
+// ProgressReporter publishes progress outside the machine, such as to the Arc machine resource. It is sent the
+// progress when a step starts or fails and when the operation finishes, not on every heartbeat.
+type ProgressReporter interface {
+	Report(progress status.Progress)
+	// Flush waits up to timeout for the progress reported last to be published
+	Flush(timeout time.Duration)
+}
+
+// progressTracker records bootstrap progress to the progress file and, when set, the progress reporter.
+// Write failures are logged and never fail the operation being tracked.
+type progressTracker struct {
+	mu       sync.Mutex
+	path     string
+	logger   *logrus.Logger
+	reporter ProgressReporter
+	progress status.Progress
+	running  []string // steps running at once
+}
+
+func newProgressTracker(path, operation string, totalSteps int, reporter ProgressReporter, logger *logrus.Logger) *progressTracker {
+	tracker := &progressTracker{
+		path:     path,
+		logger:   logger,
+		reporter: reporter,
+		progress: status.Progress{
+			Operation:  operation,
+			Phase:      status.ProgressRunning,
+			TotalSteps: totalSteps,
+			StartedAt:  time.Now().UTC(),
+		},
+	}
+	tracker.write()
+	tracker.report()
+	return tracker
+}
+
+// stepStarted records the step that is about to run and keeps the progress file fresh until stop is called.
+// Steps running at once are all listed in the current step.
+func (t *progressTracker) stepStarted(index int, stepName string) (stop func()) {
+	t.mu.Lock()
+	t.running = append(t.running, stepName)
+	t.progress.CurrentStep = strings.Join(t.running, ", ")
+	t.progress.StepIndex = index + 1
+	t.progress.StepStartedAt = time.Now().UTC()
+	t.mu.Unlock()
+	t.write()
+	t.report()
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				t.write()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// The last step to finish stays the current step
+		if len(t.running) > 1 {
+			t.running = slices.DeleteFunc(t.running, func(name string) bool { return name == stepName })
+			t.progress.CurrentStep = strings.Join(t.running, ", ")
+		} else {
+			t.running = nil
+		}
+	}
+}
+
+// stepFailed records the error of a failed step and whether retrying may succeed
+func (t *progressTracker) stepFailed(errMsg string, class failure.Class) {
+	t.mu.Lock()
+	t.progress.LastError = errMsg
+	t.progress.FailureClass = class
+	t.mu.Unlock()
+	t.write()
+	t.report()
+}
+
+// finish records the final outcome of the operation
+func (t *progressTracker) finish(success bool) {
+	t.mu.Lock()
+	t.progress.Phase = status.ProgressFailed
+	if success {
+		t.progress.Phase = status.ProgressSucceeded
+	}
+	t.mu.Unlock()
+	t.write()
+	t.report()
+	if t.reporter != nil {
+		t.reporter.Flush(progressFlushTimeout)
+	}
+}
+
+func (t *progressTracker) write() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := status.WriteProgress(t.path, &t.progress); err != nil {
+		t.logger.Debugf("Failed to write progress file %s: %v", t.path, err)
+	}
+}
+
+// report sends the progress to the progress reporter, if any
+func (t *progressTracker) report() {
+	if t.reporter == nil {
+		return
+	}
+	t.mu.Lock()
+	progress := t.progress
+	t.mu.Unlock()
+	t.reporter.Report(progress)
+}
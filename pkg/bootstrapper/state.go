@@ -0,0 +1,143 @@
+package bootstrapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// ResumeOptions selects the bootstrap steps a restarted bootstrap skips instead of checking them again
+type ResumeOptions struct {
+	Resume   bool   // skip the steps the bootstrap state records as succeeded with the current inputs
+	FromStep string // skip every step before the named one
+}
+
+// inputsHash hashes what bootstrap steps act on, the configuration and the component defaults, so a
+// resumed bootstrap only skips steps that succeeded with the same inputs
+func inputsHash(cfg *config.Config) (string, error) {
+	hash := sha256.New()
+	for _, input := range []any{cfg, defaults.Get()} {
+		data, err := json.Marshal(input)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash bootstrap inputs: %w", err)
+		}
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// stateRecorder records the outcome of each bootstrap step to the bootstrap state file as the steps finish.
+// Write failures are logged and never fail bootstrap.
+type stateRecorder struct {
+	path    string
+	logger  *logrus.Logger
+	state   *status.BootstrapState
+	skipped map[string]string // steps skipped by a resumed bootstrap, with the reason
+}
+
+// newStateRecorder starts the state of a bootstrap of the given steps. The steps skipped by a resumed
+// bootstrap keep their recorded outcome, every other step starts pending.
+func newStateRecorder(path string, steps []Executor, hash string, previous *status.BootstrapState, skipped map[string]string, logger *logrus.Logger) *stateRecorder {
+	state := &status.BootstrapState{StartedAt: time.Now().UTC(), InputsHash: hash}
+	for _, step := range steps {
+		stepState := status.StepState{Name: step.GetName(), Outcome: status.StepPending}
+		if _, ok := skipped[step.GetName()]; ok && previous != nil {
+			if recorded := previous.Step(step.GetName()); recorded != nil {
+				stepState = *recorded
+			}
+		}
+		state.Steps = append(state.Steps, stepState)
+	}
+	recorder := &stateRecorder{path: path, logger: logger, state: state, skipped: skipped}
+	recorder.write()
+	return recorder
+}
+
+// skip returns why a resumed bootstrap skips the step, false when the step runs
+func (r *stateRecorder) skip(stepName string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	reason, ok := r.skipped[stepName]
+	return reason, ok
+}
+
+// stepFinished records the outcome of a step that ran
+func (r *stateRecorder) stepFinished(result StepResult) {
+	if r == nil {
+		return
+	}
+	stepState := r.state.Step(result.StepName)
+	if stepState == nil {
+		return
+	}
+	finishedAt := time.Now().UTC()
+	startedAt := finishedAt.Add(-result.Duration)
+	*stepState = status.StepState{
+		Name:         result.StepName,
+		Outcome:      status.StepSucceeded,
+		InputsHash:   r.state.InputsHash,
+		StartedAt:    &startedAt,
+		FinishedAt:   &finishedAt,
+		Error:        result.Error,
+		FailureClass: result.FailureClass,
+	}
+	if !result.Success {
+		stepState.Outcome = status.StepFailed
+	}
+	r.write()
+}
+
+func (r *stateRecorder) write() {
+	if err := status.WriteBootstrapState(r.path, r.state); err != nil {
+		r.logger.Warnf("Failed to write bootstrap state: %v", err)
+	}
+}
+
+// resumeSkips returns the steps a resumed bootstrap skips, with the reason. With opts.Resume the steps recorded
+// as succeeded with the current inputs are skipped; steps whose inputs changed run again. With opts.FromStep
+// every step before the named one is skipped, whatever its recorded outcome.
+func resumeSkips(steps []Executor, previous *status.BootstrapState, hash string, opts ResumeOptions, logger *logrus.Logger) (map[string]string, error) {
+	skipped := map[string]string{}
+	if opts.FromStep != "" {
+		found := false
+		for _, step := range steps {
+			if step.GetName() == opts.FromStep {
+				found = true
+				break
+			}
+			skipped[step.GetName()] = fmt.Sprintf("before step %s", opts.FromStep)
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown bootstrap step %s, list the steps with aks-flex-node steps list", opts.FromStep)
+		}
+	}
+	if !opts.Resume {
+		return skipped, nil
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("no bootstrap state recorded to resume from, run bootstrap without --resume")
+	}
+	for _, step := range steps {
+		recorded := previous.Step(step.GetName())
+		if recorded == nil || recorded.Outcome != status.StepSucceeded {
+			continue
+		}
+		if recorded.InputsHash != hash {
+			logger.Warnf("Step %s succeeded with a different configuration or component defaults, running it again", step.GetName())
+			continue
+		}
+		if _, ok := skipped[step.GetName()]; !ok {
+			skipped[step.GetName()] = "succeeded in the recorded bootstrap"
+		}
+	}
+	return skipped, nil
+}
@@ -0,0 +1,155 @@
+package bootstrapper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// countingStep counts how often it ran
+type countingStep struct {
+	fakeStep
+	runs int
+}
+
+func (s *countingStep) Execute(ctx context.Context) error {
+	s.runs++
+	return s.fakeStep.Execute(ctx)
+}
+
+func TestResumeSkips(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	steps := []Executor{&fakeStep{name: "First"}, &fakeStep{name: "Second"}, &fakeStep{name: "Third"}, &fakeStep{name: "Fourth"}}
+	previous := &status.BootstrapState{
+		InputsHash: "current",
+		Steps: []status.StepState{
+			{Name: "First", Outcome: status.StepSucceeded, InputsHash: "current"},
+			{Name: "Second", Outcome: status.StepSucceeded, InputsHash: "older"},
+			{Name: "Third", Outcome: status.StepFailed, InputsHash: "current"},
+			{Name: "Fourth", Outcome: status.StepPending},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		previous *status.BootstrapState
+		opts     ResumeOptions
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "resume skips steps that succeeded with the current inputs",
+			previous: previous,
+			opts:     ResumeOptions{Resume: true},
+			want:     []string{"First"},
+		},
+		{
+			name:     "from step skips every earlier step",
+			previous: previous,
+			opts:     ResumeOptions{FromStep: "Fourth"},
+			want:     []string{"First", "Second", "Third"},
+		},
+		{
+			name:     "from step does not need a recorded bootstrap",
+			previous: nil,
+			opts:     ResumeOptions{FromStep: "Second"},
+			want:     []string{"First"},
+		},
+		{
+			name:     "no options skip nothing",
+			previous: previous,
+			want:     nil,
+		},
+		{
+			name:     "resume without recorded bootstrap fails",
+			previous: nil,
+			opts:     ResumeOptions{Resume: true},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown from step fails",
+			previous: previous,
+			opts:     ResumeOptions{FromStep: "Missing"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipped, err := resumeSkips(steps, tt.previous, "current", tt.opts, logger)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resumeSkips() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, step := range steps {
+				if _, ok := skipped[step.GetName()]; ok {
+					got = append(got, step.GetName())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resumeSkips() skipped %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteGraphRecordsAndResumesState(t *testing.T) {
+	be := newTestExecutor(t)
+	statePath := filepath.Join(t.TempDir(), "bootstrap-state.json")
+	first := &countingStep{fakeStep: fakeStep{name: "First"}}
+	second := &countingStep{fakeStep: fakeStep{name: "Second", err: errors.New("boom")}}
+	third := &countingStep{fakeStep: fakeStep{name: "Third"}}
+	steps := []Executor{first, second, third}
+
+	state := newStateRecorder(statePath, steps, "inputs", nil, nil, be.logger)
+	if _, err := be.executeGraph(context.Background(), steps, nil, 1, "bootstrap", true, state); err == nil {
+		t.Fatal("executeGraph() succeeded, want the failure of Second")
+	}
+	recorded, err := status.LoadBootstrapState(statePath)
+	if err != nil || recorded == nil {
+		t.Fatalf("LoadBootstrapState() = %v, %v, want the recorded bootstrap", recorded, err)
+	}
+	var outcomes []string
+	for _, step := range recorded.Steps {
+		outcomes = append(outcomes, step.Outcome)
+	}
+	if want := []string{status.StepSucceeded, status.StepFailed, status.StepPending}; !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("recorded outcomes = %v, want %v", outcomes, want)
+	}
+	if step := recorded.Step("Second"); step.Error != "boom" || step.InputsHash != "inputs" || step.FinishedAt == nil {
+		t.Errorf("recorded Second = %+v, want its error, inputs and finish time", step)
+	}
+
+	second.err = nil
+	skipped, err := resumeSkips(steps, recorded, "inputs", ResumeOptions{Resume: true}, be.logger)
+	if err != nil {
+		t.Fatalf("resumeSkips() error = %v", err)
+	}
+	state = newStateRecorder(statePath, steps, "inputs", recorded, skipped, be.logger)
+	result, err := be.executeGraph(context.Background(), steps, nil, 1, "bootstrap", true, state)
+	if err != nil || !result.Success {
+		t.Fatalf("executeGraph() = %+v, %v, want success", result, err)
+	}
+	if first.runs != 1 || second.runs != 2 || third.runs != 1 {
+		t.Errorf("steps ran %d, %d and %d times, want First skipped on resume", first.runs, second.runs, third.runs)
+	}
+	if result.StepResults[0].Skipped == "" {
+		t.Errorf("result of First = %+v, want it skipped", result.StepResults[0])
+	}
+
+	resumed, err := status.LoadBootstrapState(statePath)
+	if err != nil {
+		t.Fatalf("LoadBootstrapState() error = %v", err)
+	}
+	if step := resumed.Step("First"); step.Outcome != status.StepSucceeded || !step.FinishedAt.Equal(*recorded.Step("First").FinishedAt) {
+		t.Errorf("resumed First = %+v, want the outcome of the first bootstrap kept", step)
+	}
+}
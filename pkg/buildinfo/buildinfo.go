@@ -0,0 +1,75 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with
+// -ldflags "-X go.goms.io/aks/AKSFlexNode/pkg/buildinfo.Version=... -X ...GitCommit=... -X ...BuildTime=..."
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info describes the build of the running agent binary
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the build metadata of the running binary. Values not injected at build time
+// fall back to the VCS metadata the Go toolchain embeds, so plain "go build" binaries are still traceable.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		applyVCSSettings(&info, bi.Settings)
+	}
+	return info
+}
+
+// applyVCSSettings fills commit and build time from embedded VCS settings where they were not injected
+func applyVCSSettings(info *Info, settings []debug.BuildSetting) {
+	var revision, commitTime string
+	modified := false
+	for _, setting := range settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.time":
+			commitTime = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+
+	if info.GitCommit == "unknown" && revision != "" {
+		if len(revision) > 7 {
+			revision = revision[:7]
+		}
+		if modified {
+			revision += "-dirty"
+		}
+		info.GitCommit = revision
+	}
+	// The commit time is the closest reproducible stand-in for the build time
+	if info.BuildTime == "unknown" && commitTime != "" {
+		info.BuildTime = commitTime
+	}
+}
+
+// String returns a one-line summary of the build for logs
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s, %s)", i.Version, i.GitCommit, i.BuildTime, i.GoVersion, i.Platform)
+}
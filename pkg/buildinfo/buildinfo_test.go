@@ -0,0 +1,58 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestApplyVCSSettings(t *testing.T) {
+	settings := []debug.BuildSetting{
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "f5d4f7f0c1a2b3c4d5e6f708192a3b4c5d6e7f80"},
+		{Key: "vcs.time", Value: "2026-10-01T12:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	}
+
+	tests := []struct {
+		name          string
+		info          Info
+		wantCommit    string
+		wantBuildTime string
+	}{
+		{
+			name:          "fills values not injected at build time",
+			info:          Info{Version: "dev", GitCommit: "unknown", BuildTime: "unknown"},
+			wantCommit:    "f5d4f7f-dirty",
+			wantBuildTime: "2026-10-01T12:00:00Z",
+		},
+		{
+			name:          "keeps injected values",
+			info:          Info{Version: "v0.4.0", GitCommit: "abc1234", BuildTime: "2026-10-02T08:30:00Z"},
+			wantCommit:    "abc1234",
+			wantBuildTime: "2026-10-02T08:30:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.info
+			applyVCSSettings(&info, settings)
+			if info.GitCommit != tt.wantCommit {
+				t.Errorf("GitCommit = %q, want %q", info.GitCommit, tt.wantCommit)
+			}
+			if info.BuildTime != tt.wantBuildTime {
+				t.Errorf("BuildTime = %q, want %q", info.BuildTime, tt.wantBuildTime)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version != Version {
+		t.Errorf("Get().Version = %q, want %q", info.Version, Version)
+	}
+	if info.GoVersion == "" || info.Platform == "" {
+		t.Errorf("Get() = %+v, want Go version and platform set", info)
+	}
+}
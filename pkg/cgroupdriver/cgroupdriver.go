@@ -0,0 +1,93 @@
+package cgroupdriver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Cgroup drivers of kubelet and the containerd runc runtime
+const (
+	Systemd  = "systemd"
+	Cgroupfs = "cgroupfs"
+)
+
+// Live configuration files the drivers are read from
+var (
+	kubeletDefaultsPath  = "/etc/default/kubelet"
+	kubeletConfigPath    = "/var/lib/kubelet/config.yaml"
+	containerdConfigPath = "/etc/containerd/config.toml"
+)
+
+var (
+	kubeletFlagPattern   = regexp.MustCompile(`--cgroup-driver=([a-z]+)`)
+	kubeletConfigPattern = regexp.MustCompile(`(?m)^cgroupDriver:\s*"?([a-z]+)"?\s*$`)
+)
+
+// Report holds the cgroup drivers kubelet and containerd are configured with, empty when a configuration is missing
+type Report struct {
+	Kubelet    string `json:"kubelet"`
+	Containerd string `json:"containerd"`
+}
+
+// Mismatch reports whether kubelet and containerd are configured with different cgroup drivers.
+// Pods fail to start with such a mismatch, as kubelet and the runtime disagree about the cgroup hierarchy.
+func (r *Report) Mismatch() bool {
+	return r.Kubelet != "" && r.Containerd != "" && r.Kubelet != r.Containerd
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("kubelet uses the %s cgroup driver, containerd the %s cgroup driver", r.Kubelet, r.Containerd)
+}
+
+// Detect reads the cgroup drivers from the live kubelet and containerd configuration files
+func Detect() *Report {
+	report := &Report{}
+	if defaults, err := os.ReadFile(kubeletDefaultsPath); err == nil {
+		kubeletConfig, _ := os.ReadFile(kubeletConfigPath)
+		report.Kubelet = KubeletDriver(defaults, kubeletConfig)
+	}
+	if config, err := os.ReadFile(containerdConfigPath); err == nil {
+		report.Containerd = ContainerdDriver(config)
+	}
+	return report
+}
+
+// KubeletDriver returns the cgroup driver of kubelet's --cgroup-driver flag or, without the flag, of its
+// configuration file. Kubelet defaults to cgroupfs when neither sets it.
+func KubeletDriver(defaults, config []byte) string {
+	if match := kubeletFlagPattern.FindSubmatch(defaults); match != nil {
+		return string(match[1])
+	}
+	if match := kubeletConfigPattern.FindSubmatch(config); match != nil {
+		return string(match[1])
+	}
+	return Cgroupfs
+}
+
+// ContainerdDriver returns the cgroup driver of the runc runtime in a containerd configuration.
+// containerd defaults to cgroupfs unless SystemdCgroup is enabled in the runc options.
+func ContainerdDriver(config []byte) string {
+	inRuncOptions := false
+	scanner := bufio.NewScanner(strings.NewReader(string(config)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inRuncOptions = strings.HasSuffix(line, `.runtimes.runc.options]`)
+			continue
+		}
+		if !inRuncOptions {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "SystemdCgroup" {
+			if strings.TrimSpace(value) == "true" {
+				return Systemd
+			}
+			return Cgroupfs
+		}
+	}
+	return Cgroupfs
+}
@@ -0,0 +1,69 @@
+package cgroupdriver
+
+import "testing"
+
+func TestKubeletDriver(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults string
+		config   string
+		want     string
+	}{
+		{name: "flag", defaults: `KUBELET_FLAGS="--v=2 --cgroup-driver=systemd --max-pods=110"`, want: Systemd},
+		{name: "flag wins over config file", defaults: `KUBELET_FLAGS="--cgroup-driver=systemd"`, config: "cgroupDriver: cgroupfs\n", want: Systemd},
+		{name: "config file", defaults: `KUBELET_FLAGS="--v=2"`, config: "kind: KubeletConfiguration\ncgroupDriver: \"systemd\"\n", want: Systemd},
+		{name: "kubelet default", defaults: `KUBELET_FLAGS="--v=2"`, want: Cgroupfs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KubeletDriver([]byte(tt.defaults), []byte(tt.config)); got != tt.want {
+				t.Errorf("KubeletDriver() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerdDriver(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{name: "systemd", want: Systemd, config: `version = 2
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+	BinaryName = "/usr/bin/runc"
+	SystemdCgroup = true
+`},
+		{name: "rewritten to cgroupfs", want: Cgroupfs, config: `version = 2
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+	SystemdCgroup = false
+`},
+		{name: "only another runtime uses systemd", want: Cgroupfs, config: `version = 2
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+	BinaryName = "/usr/bin/runc"
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.kata.options]
+	SystemdCgroup = true
+`},
+		{name: "containerd 2 layout", want: Systemd, config: `version = 3
+[plugins.'io.containerd.cri.v1.runtime'.containerd.runtimes.runc.options]
+  SystemdCgroup = true
+`},
+		{name: "containerd default", config: "version = 2\n", want: Cgroupfs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContainerdDriver([]byte(tt.config)); got != tt.want {
+				t.Errorf("ContainerdDriver() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	if !(&Report{Kubelet: Systemd, Containerd: Cgroupfs}).Mismatch() {
+		t.Error("Mismatch() = false for systemd kubelet and cgroupfs containerd")
+	}
+	if (&Report{Kubelet: Systemd}).Mismatch() {
+		t.Error("Mismatch() = true without a containerd configuration")
+	}
+}
@@ -0,0 +1,176 @@
+package clusterca
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// CacheFilePath holds the cluster CA retrieved with the cluster credentials, which the API server chain is verified against
+var CacheFilePath = filepath.Join(config.AgentStateDir, "cluster-ca.json")
+
+// dialTimeout bounds the TLS handshake with the API server
+const dialTimeout = 10 * time.Second
+
+// Cache is the cluster CA retrieved with the cluster credentials
+type Cache struct {
+	Server      string    `json:"server"`      // API server URL the CA belongs to
+	CAData      string    `json:"caData"`      // PEM-encoded CA certificates
+	Fingerprint string    `json:"fingerprint"` // SHA-256 fingerprint of the first CA certificate
+	SavedAt     time.Time `json:"savedAt"`
+}
+
+// Status is the result of verifying the API server's certificate chain against the cached cluster CA
+type Status struct {
+	Fingerprint       string    `json:"fingerprint"`                 // SHA-256 fingerprint of the cached cluster CA
+	ServerFingerprint string    `json:"serverFingerprint,omitempty"` // SHA-256 fingerprint of the certificate the API server presented
+	Verified          bool      `json:"verified"`                    // API server chain verifies against the cached CA
+	Message           string    `json:"message,omitempty"`           // Why verification failed
+	CheckedAt         time.Time `json:"checkedAt"`
+}
+
+// Save caches the cluster CA for an API server. caData is the PEM-encoded CA bundle.
+// It returns the previously cached fingerprint when the CA changed, empty otherwise.
+func Save(server string, caData []byte) (previous string, err error) {
+	certs, err := parseCertificates(caData)
+	if err != nil {
+		return "", err
+	}
+	cache := &Cache{
+		Server:      server,
+		CAData:      string(caData),
+		Fingerprint: Fingerprint(certs[0]),
+		SavedAt:     time.Now().UTC(),
+	}
+	if old, err := Load(); err == nil && old != nil && old.Fingerprint != cache.Fingerprint {
+		previous = old.Fingerprint
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cluster CA: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(CacheFilePath)); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", CacheFilePath, err)
+	}
+	return previous, utils.WriteFileAtomicSystem(CacheFilePath, data, 0o644)
+}
+
+// Load reads the cached cluster CA, returning nil when none was cached yet
+func Load() (*Cache, error) {
+	data, err := os.ReadFile(CacheFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	cache := &Cache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster CA: %w", err)
+	}
+	return cache, nil
+}
+
+// Verify connects to the API server and verifies the certificate chain it presents against the cached CA.
+// A failed verification means the cluster CA was rotated or the connection is intercepted.
+func Verify(ctx context.Context, cache *Cache) *Status {
+	status := &Status{Fingerprint: cache.Fingerprint, CheckedAt: time.Now().UTC()}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(cache.CAData)) {
+		status.Message = "cached cluster CA holds no certificates"
+		return status
+	}
+	host, address, err := serverAddress(cache.Server)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+
+	// The chain is verified against the cached CA below instead of the system roots
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: dialTimeout},
+		Config:    &tls.Config{InsecureSkipVerify: true, ServerName: host, MinVersion: tls.VersionTLS12},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		status.Message = fmt.Sprintf("failed to connect to API server %s: %v", address, err)
+		return status
+	}
+	defer func() { _ = conn.Close() }()
+
+	peers := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		status.Message = "API server presented no certificate"
+		return status
+	}
+	status.ServerFingerprint = Fingerprint(peers[0])
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range peers[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := peers[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: host}); err != nil {
+		status.Message = fmt.Sprintf("API server certificate does not verify against the cached cluster CA %s: %v", cache.Fingerprint, err)
+		return status
+	}
+	status.Verified = true
+	return status
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate in colon-separated hex
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.ToUpper(strings.Join(parts, ":"))
+}
+
+// parseCertificates parses the certificates of a PEM bundle
+func parseCertificates(caData []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(caData); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cluster CA certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("cluster CA data holds no certificates")
+	}
+	return certs, nil
+}
+
+// serverAddress returns the host name and dial address of an API server URL
+func serverAddress(server string) (host, address string, err error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Hostname() == "" {
+		return "", "", fmt.Errorf("invalid API server URL %q", server)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return u.Hostname(), net.JoinHostPort(u.Hostname(), port), nil
+}
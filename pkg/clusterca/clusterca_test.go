@@ -0,0 +1,91 @@
+package clusterca
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSignedCA returns a PEM-encoded CA certificate that did not sign the test server's certificate
+func selfSignedCA(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSaveAndVerify(t *testing.T) {
+	CacheFilePath = filepath.Join(t.TempDir(), "cluster-ca.json")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	previous, err := Save(server.URL, serverCA)
+	if err != nil || previous != "" {
+		t.Fatalf("Save() = %q, %v, want no previous fingerprint", previous, err)
+	}
+	cache, err := Load()
+	if err != nil || cache == nil {
+		t.Fatalf("Load() = %v, %v, want the saved CA", cache, err)
+	}
+
+	status := Verify(context.Background(), cache)
+	if !status.Verified {
+		t.Fatalf("Verify() against the server CA failed: %s", status.Message)
+	}
+	if status.Fingerprint != Fingerprint(server.Certificate()) || status.ServerFingerprint == "" {
+		t.Errorf("Verify() fingerprints = %s / %s, want the server CA and presented certificate", status.Fingerprint, status.ServerFingerprint)
+	}
+
+	// A rotated or intercepted chain no longer verifies against the cached CA
+	previous, err = Save(server.URL, selfSignedCA(t))
+	if err != nil || previous != cache.Fingerprint {
+		t.Fatalf("Save() of a new CA = %q, %v, want previous fingerprint %s", previous, err, cache.Fingerprint)
+	}
+	rotated, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	status = Verify(context.Background(), rotated)
+	if status.Verified || !strings.Contains(status.Message, "does not verify") {
+		t.Errorf("Verify() against a different CA = %+v, want a verification failure", status)
+	}
+}
+
+func TestSaveRejectsInvalidCA(t *testing.T) {
+	CacheFilePath = filepath.Join(t.TempDir(), "cluster-ca.json")
+
+	if _, err := Save("https://example.hcp.eastus.azmk8s.io:443", []byte("not a certificate")); err == nil {
+		t.Error("Save() expected an error for CA data without certificates")
+	}
+	if cache, err := Load(); cache != nil || err != nil {
+		t.Errorf("Load() = %v, %v, want nothing cached", cache, err)
+	}
+}
@@ -0,0 +1,204 @@
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Kubernetes minor versions (1.x) this agent release knows how to configure.
+// Raise MaxKubernetesMinor only after validating kubelet flags and component defaults against the new release.
+const (
+	MinKubernetesMinor = 30
+	MaxKubernetesMinor = 34
+
+	// maxKubeletSkew is how many minor versions kubelet may trail the API server. AKS supports node pools up to
+	// two minor versions behind the control plane, stricter than the upstream Kubernetes version skew policy.
+	maxKubeletSkew = 2
+)
+
+// ReportFilePath records the result of the last compatibility check for status reporting
+var ReportFilePath = filepath.Join(config.AgentStateDir, "compatibility.json")
+
+// Issue is a single compatibility problem; blocking issues refuse bootstrap
+type Issue struct {
+	Blocking bool   `json:"blocking"`
+	Message  string `json:"message"`
+}
+
+// Report is the result of checking this node against the target cluster's Kubernetes version
+type Report struct {
+	ClusterKubernetesVersion string    `json:"clusterKubernetesVersion"`
+	KubeletVersion           string    `json:"kubeletVersion"`
+	KubeletMinorSkew         int       `json:"kubeletMinorSkew"`            // minor versions kubelet trails the API server, negative when newer
+	SupportedVersions        string    `json:"supportedKubernetesVersions"` // Kubernetes versions this agent release supports
+	Compatible               bool      `json:"compatible"`
+	Issues                   []Issue   `json:"issues,omitempty"`
+	CheckedAt                time.Time `json:"checkedAt"`
+}
+
+// Check checks the configured kubelet version and this agent release against the cluster's Kubernetes version
+func Check(clusterVersion, kubeletVersion string) *Report {
+	report := &Report{
+		ClusterKubernetesVersion: clusterVersion,
+		KubeletVersion:           kubeletVersion,
+		SupportedVersions:        fmt.Sprintf("1.%d-1.%d", MinKubernetesMinor, MaxKubernetesMinor),
+		CheckedAt:                time.Now().UTC(),
+	}
+	addIssue := func(blocking bool, format string, args ...any) {
+		report.Issues = append(report.Issues, Issue{Blocking: blocking, Message: fmt.Sprintf(format, args...)})
+	}
+
+	clusterMinor, err := MinorVersion(clusterVersion)
+	if err != nil {
+		addIssue(false, "cannot check version skew, cluster Kubernetes version %q is not recognized: %v", clusterVersion, err)
+		report.Compatible = true
+		return report
+	}
+
+	switch {
+	case clusterMinor > MaxKubernetesMinor:
+		addIssue(true, "this agent release supports Kubernetes %s but the cluster runs %s; upgrade the agent before joining this node",
+			report.SupportedVersions, clusterVersion)
+	case clusterMinor < MinKubernetesMinor:
+		addIssue(false, "the cluster runs Kubernetes %s, which is older than the %s this agent release is validated with",
+			clusterVersion, report.SupportedVersions)
+	}
+
+	if kubeletVersion != "" {
+		kubeletMinor, err := MinorVersion(kubeletVersion)
+		if err != nil {
+			addIssue(true, "kubernetes.version %q is not a valid Kubernetes version: %v", kubeletVersion, err)
+		} else {
+			report.KubeletMinorSkew = clusterMinor - kubeletMinor
+			switch {
+			case kubeletMinor > MaxKubernetesMinor:
+				addIssue(true, "kubelet %s is newer than the Kubernetes %s this agent release supports; upgrade the agent",
+					kubeletVersion, report.SupportedVersions)
+			case report.KubeletMinorSkew < 0:
+				addIssue(true, "kubelet %s is newer than the cluster API server %s, which the Kubernetes version skew policy does not allow",
+					kubeletVersion, clusterVersion)
+			case report.KubeletMinorSkew > maxKubeletSkew:
+				addIssue(true, "kubelet %s is %d minor versions behind the cluster API server %s, more than the %d AKS supports; "+
+					"set kubernetes.version to 1.%d or newer, or to %q to follow the cluster",
+					kubeletVersion, report.KubeletMinorSkew, clusterVersion, maxKubeletSkew, clusterMinor-maxKubeletSkew, config.KubernetesVersionAuto)
+			case report.KubeletMinorSkew > 0:
+				addIssue(false, "kubelet %s is %d minor version(s) behind the cluster API server %s; upgrade it before the cluster's next minor upgrade",
+					kubeletVersion, report.KubeletMinorSkew, clusterVersion)
+			}
+		}
+	}
+
+	report.Compatible = true
+	for _, issue := range report.Issues {
+		if issue.Blocking {
+			report.Compatible = false
+		}
+	}
+	return report
+}
+
+// SelectKubeletVersion picks the kubelet version for kubernetes.version "auto": the cluster's current
+// Kubernetes version, which keeps kubelet on the same minor version as the API server
+func SelectKubeletVersion(clusterVersion string) (string, error) {
+	version := strings.TrimPrefix(strings.TrimSpace(clusterVersion), "v")
+	minor, err := MinorVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("cluster Kubernetes version %q is not recognized: %w", clusterVersion, err)
+	}
+	if strings.Count(version, ".") != 2 {
+		return "", fmt.Errorf("cluster Kubernetes version %q has no patch version to install", clusterVersion)
+	}
+	if minor > MaxKubernetesMinor {
+		return "", fmt.Errorf("this agent release supports Kubernetes 1.%d-1.%d but the cluster runs %s; upgrade the agent before joining this node",
+			MinKubernetesMinor, MaxKubernetesMinor, clusterVersion)
+	}
+	return version, nil
+}
+
+// CheckUpgrade returns why kubernetes.autoUpgrade policy does not allow upgrading kubelet from installed to
+// target, nil when it does. Downgrades are never allowed, and the patch policy stays on the installed minor version.
+func CheckUpgrade(policy, installed, target string) error {
+	installedMinor, installedPatch, err := minorPatchVersion(installed)
+	if err != nil {
+		return fmt.Errorf("installed kubelet version %q is not recognized: %w", installed, err)
+	}
+	targetMinor, targetPatch, err := minorPatchVersion(target)
+	if err != nil {
+		return fmt.Errorf("target Kubernetes version %q is not recognized: %w", target, err)
+	}
+
+	switch {
+	case targetMinor < installedMinor || (targetMinor == installedMinor && targetPatch < installedPatch):
+		return fmt.Errorf("kubelet %s is newer than %s, kubelet is never downgraded", installed, target)
+	case targetMinor > installedMinor && policy != config.AutoUpgradeMinor:
+		return fmt.Errorf("upgrading kubelet from 1.%d to 1.%d is a minor version upgrade, which kubernetes.autoUpgrade %s does not allow",
+			installedMinor, targetMinor, policy)
+	}
+	return nil
+}
+
+// minorPatchVersion returns the minor and patch versions of a 1.x.y Kubernetes version such as "1.31.2" or "v1.31.2"
+func minorPatchVersion(version string) (int, int, error) {
+	minor, err := MinorVersion(version)
+	if err != nil {
+		return 0, 0, err
+	}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) != 3 {
+		return 0, 0, fmt.Errorf("expected a 1.x.y version")
+	}
+	patch, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid patch version %q", parts[2])
+	}
+	return minor, patch, nil
+}
+
+// MinorVersion returns the minor version of a 1.x Kubernetes version such as "1.31.2" or "v1.31"
+func MinorVersion(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("expected a 1.x version")
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid minor version %q", parts[1])
+	}
+	return minor, nil
+}
+
+// SaveReport persists the compatibility report so status collection can surface it
+func SaveReport(report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal compatibility report: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(ReportFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", ReportFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(ReportFilePath, data, 0o644)
+}
+
+// LoadReport reads the last compatibility report, returning nil when no check has run yet
+func LoadReport() (*Report, error) {
+	data, err := os.ReadFile(ReportFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read compatibility report: %w", err)
+	}
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed to parse compatibility report: %w", err)
+	}
+	return report, nil
+}
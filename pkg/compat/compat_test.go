@@ -0,0 +1,163 @@
+package compat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		clusterVersion string
+		kubeletVersion string
+		wantCompatible bool
+		wantSkew       int
+		wantIssue      string
+	}{
+		{
+			name:           "same minor version",
+			clusterVersion: "1.32.4",
+			kubeletVersion: "1.32.7",
+			wantCompatible: true,
+		},
+		{
+			name:           "kubelet within skew warns",
+			clusterVersion: "1.33.1",
+			kubeletVersion: "1.31.9",
+			wantCompatible: true,
+			wantSkew:       2,
+			wantIssue:      "2 minor version(s) behind",
+		},
+		{
+			name:           "kubelet beyond skew blocks",
+			clusterVersion: "1.34.0",
+			kubeletVersion: "1.30.14",
+			wantSkew:       4,
+			wantIssue:      "more than the 2",
+		},
+		{
+			name:           "kubelet three minor versions behind blocks",
+			clusterVersion: "1.33.2",
+			kubeletVersion: "1.30.14",
+			wantSkew:       3,
+			wantIssue:      "set kubernetes.version to 1.31 or newer",
+		},
+		{
+			name:           "kubelet newer than cluster blocks",
+			clusterVersion: "1.31.2",
+			kubeletVersion: "1.32.0",
+			wantSkew:       -1,
+			wantIssue:      "newer than the cluster API server",
+		},
+		{
+			name:           "agent too old for cluster blocks",
+			clusterVersion: fmt.Sprintf("1.%d.0", MaxKubernetesMinor+1),
+			kubeletVersion: fmt.Sprintf("1.%d.0", MaxKubernetesMinor),
+			wantSkew:       1,
+			wantIssue:      "upgrade the agent before joining",
+		},
+		{
+			name:           "cluster older than supported warns",
+			clusterVersion: fmt.Sprintf("v1.%d.5", MinKubernetesMinor-1),
+			kubeletVersion: fmt.Sprintf("1.%d.5", MinKubernetesMinor-1),
+			wantCompatible: true,
+			wantIssue:      "older than the",
+		},
+		{
+			name:           "unrecognized cluster version does not block",
+			clusterVersion: "",
+			kubeletVersion: "1.32.0",
+			wantCompatible: true,
+			wantIssue:      "cannot check version skew",
+		},
+		{
+			name:           "invalid kubelet version blocks",
+			clusterVersion: "1.32.0",
+			kubeletVersion: "latest",
+			wantIssue:      "not a valid Kubernetes version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Check(tt.clusterVersion, tt.kubeletVersion)
+			if report.Compatible != tt.wantCompatible {
+				t.Errorf("Check() Compatible = %v, want %v (issues: %v)", report.Compatible, tt.wantCompatible, report.Issues)
+			}
+			if report.KubeletMinorSkew != tt.wantSkew {
+				t.Errorf("Check() KubeletMinorSkew = %d, want %d", report.KubeletMinorSkew, tt.wantSkew)
+			}
+			if tt.wantIssue == "" {
+				if len(report.Issues) != 0 {
+					t.Errorf("Check() issues = %v, want none", report.Issues)
+				}
+				return
+			}
+			found := false
+			for _, issue := range report.Issues {
+				found = found || strings.Contains(issue.Message, tt.wantIssue)
+			}
+			if !found {
+				t.Errorf("Check() issues = %v, want one containing %q", report.Issues, tt.wantIssue)
+			}
+		})
+	}
+}
+
+func TestSelectKubeletVersion(t *testing.T) {
+	tests := []struct {
+		clusterVersion string
+		want           string
+		wantErr        bool
+	}{
+		{clusterVersion: "1.32.4", want: "1.32.4"},
+		{clusterVersion: " v1.31.9 ", want: "1.31.9"},
+		{clusterVersion: "1.32", wantErr: true},
+		{clusterVersion: "", wantErr: true},
+		{clusterVersion: fmt.Sprintf("1.%d.0", MaxKubernetesMinor+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := SelectKubeletVersion(tt.clusterVersion)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SelectKubeletVersion(%q) error = %v, wantErr %v", tt.clusterVersion, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("SelectKubeletVersion(%q) = %q, want %q", tt.clusterVersion, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUpgrade(t *testing.T) {
+	tests := []struct {
+		policy    string
+		installed string
+		target    string
+		wantErr   string
+	}{
+		{policy: config.AutoUpgradePatch, installed: "1.31.2", target: "1.31.5"},
+		{policy: config.AutoUpgradeMinor, installed: "1.31.2", target: "1.32.0"},
+		{policy: config.AutoUpgradeMinor, installed: "v1.31.2", target: "1.31.3"},
+		{policy: config.AutoUpgradePatch, installed: "1.31.2", target: "1.32.0", wantErr: "minor version upgrade"},
+		{policy: config.AutoUpgradeMinor, installed: "1.32.1", target: "1.31.9", wantErr: "never downgraded"},
+		{policy: config.AutoUpgradeMinor, installed: "1.31.5", target: "1.31.2", wantErr: "never downgraded"},
+		{policy: config.AutoUpgradeMinor, installed: "unknown", target: "1.31.2", wantErr: "not recognized"},
+	}
+
+	for _, tt := range tests {
+		err := CheckUpgrade(tt.policy, tt.installed, tt.target)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("CheckUpgrade(%s, %s, %s) error = %v, want nil", tt.policy, tt.installed, tt.target, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("CheckUpgrade(%s, %s, %s) error = %v, want one containing %q", tt.policy, tt.installed, tt.target, err, tt.wantErr)
+		}
+	}
+}
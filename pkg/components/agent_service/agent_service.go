@@ -0,0 +1,95 @@
+package agent_service
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Files are the service files shipped with the agent: the systemd unit, with placeholders for the Azure CLI
+// login the service shares, and the sudoers rules granting the service user the commands the agent runs
+type Files struct {
+	Unit    []byte
+	Sudoers []byte
+}
+
+// cliLogin is the Azure CLI login of the user who ran the install with sudo, which the service signs in with
+// when no service principal or workload identity is configured
+type cliLogin struct {
+	user      string
+	configDir string
+}
+
+// detectCLILogin returns the Azure CLI login the service shares, nil when it signs in otherwise or the user
+// running sudo has no Azure CLI configuration
+func detectCLILogin(cfg *config.Config) *cliLogin {
+	if cfg.IsSPConfigured() || cfg.IsWorkloadIdentityConfigured() {
+		return nil
+	}
+	name := os.Getenv("SUDO_USER")
+	if name == "" || name == "root" {
+		return nil
+	}
+	sudoUser, err := user.Lookup(name)
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Join(sudoUser.HomeDir, ".azure")
+	if !fsutil.DirectoryExists(dir) {
+		return nil
+	}
+	return &cliLogin{user: name, configDir: dir}
+}
+
+// renderUnit returns the unit running the agent binary with the configuration at configPath and the node pool
+// profile selected with --profile, if any. The placeholders are replaced with the shared Azure CLI login; without
+// one, the Azure CLI environment is dropped.
+func renderUnit(template []byte, binaryPath, configPath, profile string, login *cliLogin) []byte {
+	var lines []string
+	for _, line := range strings.Split(string(template), "\n") {
+		switch {
+		case strings.HasPrefix(line, "ExecStart="):
+			line = fmt.Sprintf("ExecStart=%s agent --config %s", binaryPath, configPath)
+			if profile != "" {
+				line += " --profile " + profile
+			}
+		case strings.Contains(line, placeholderAzureConfigDir):
+			if login == nil {
+				continue
+			}
+			line = strings.ReplaceAll(line, placeholderAzureConfigDir, login.configDir)
+		case strings.Contains(line, placeholderUserGroup):
+			group := ""
+			if login != nil {
+				group = login.user
+			}
+			line = strings.TrimSpace(strings.ReplaceAll(line, placeholderUserGroup, group))
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// userExists reports whether the service user exists
+func userExists() bool {
+	_, err := user.Lookup(serviceUser)
+	return err == nil
+}
+
+// runningInService reports whether this process is the main process of the agent service, which must not stop
+// or remove the service it runs in
+func runningInService() bool {
+	output, err := sysutil.RunCommandWithOutput("systemctl", "show", "--property", "MainPID", "--value", serviceName)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	return err == nil && pid == os.Getpid()
+}
@@ -0,0 +1,187 @@
+package agent_service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// defaultBinaryPath is where the install script puts the agent, used when the running binary cannot be resolved
+const defaultBinaryPath = "/usr/local/bin/aks-flex-node"
+
+// Installer installs the agent itself as a systemd service: the service user, the directories it writes, the
+// sudoers rules and the unit, which it enables without starting
+type Installer struct {
+	config     *config.Config
+	logger     *logrus.Logger
+	files      Files
+	binaryPath string
+	configPath string
+}
+
+// NewInstaller creates a new agent service Installer running the current binary with the configuration at configPath
+func NewInstaller(logger *logrus.Logger, files Files, configPath string) *Installer {
+	binaryPath, err := os.Executable()
+	if err != nil {
+		logger.Warnf("Failed to determine agent binary path, using %s: %v", defaultBinaryPath, err)
+		binaryPath = defaultBinaryPath
+	}
+	if absPath, err := filepath.Abs(configPath); err == nil {
+		configPath = absPath
+	}
+	return &Installer{
+		config:     config.GetConfig(),
+		logger:     logger,
+		files:      files,
+		binaryPath: binaryPath,
+		configPath: configPath,
+	}
+}
+
+// GetName returns the step name
+func (i *Installer) GetName() string {
+	return "AgentService_Installer"
+}
+
+// Validate checks that the host runs systemd and the service files are available
+func (i *Installer) Validate(_ context.Context) error {
+	if len(i.files.Unit) == 0 || len(i.files.Sudoers) == 0 {
+		return fmt.Errorf("the agent service unit and sudoers rules are missing from this build")
+	}
+	if !fsutil.DirectoryExists("/run/systemd/system") {
+		return fmt.Errorf("the agent service needs systemd, which is not running on this host")
+	}
+	return nil
+}
+
+// IsCompleted returns true when the service user exists and the installed unit and sudoers rules are current
+// and enabled
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	if !userExists() {
+		return false
+	}
+	for path, want := range map[string][]byte{unitPath: i.unit(), sudoersPath: i.files.Sudoers} {
+		if installed, err := os.ReadFile(path); err != nil || !bytes.Equal(installed, want) {
+			return false
+		}
+	}
+	_, err := sysutil.RunCommandWithOutput("systemctl", "is-enabled", serviceName)
+	return err == nil
+}
+
+// Plan describes the service installation for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	return []string{
+		fmt.Sprintf("create the %s system user", serviceUser),
+		fmt.Sprintf("create %s, %s and %s owned by %s", config.AgentStateDir, i.config.Agent.LogDir, cacheDir, serviceUser),
+		"install the sudoers rules at " + sudoersPath,
+		fmt.Sprintf("write %s running %s with %s and enable %s", unitPath, i.binaryPath, i.configPath, serviceName),
+	}
+}
+
+// Execute installs and enables the service
+func (i *Installer) Execute(_ context.Context) error {
+	i.logger.Infof("Installing the %s service", serviceName)
+
+	if !userExists() {
+		if err := sysutil.RunSystemCommand("useradd", "--system", "--shell", "/bin/false",
+			"--home-dir", config.AgentStateDir, "--create-home", serviceUser); err != nil {
+			return fmt.Errorf("failed to create service user %s: %w", serviceUser, err)
+		}
+		i.logger.Infof("Created service user %s", serviceUser)
+	}
+
+	if err := i.createDirectories(); err != nil {
+		return err
+	}
+	if login := detectCLILogin(i.config); login != nil {
+		if err := i.shareCLILogin(login); err != nil {
+			return err
+		}
+	}
+	if err := i.installSudoers(); err != nil {
+		return err
+	}
+
+	if err := fsutil.WriteFileAtomicSystem(unitPath, i.unit(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", unitPath, err)
+	}
+	if err := sysutil.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	if err := sysutil.RunSystemCommand("systemctl", "enable", serviceName); err != nil {
+		return fmt.Errorf("failed to enable %s: %w", serviceName, err)
+	}
+
+	i.logger.Infof("Installed and enabled %s, start it with systemctl start %s", serviceName, serviceName)
+	return nil
+}
+
+// unit returns the unit to install
+func (i *Installer) unit() []byte {
+	return renderUnit(i.files.Unit, i.binaryPath, i.configPath, config.SelectedProfile(), detectCLILogin(i.config))
+}
+
+// createDirectories creates the directories the service writes, owned by the service user, and the
+// configuration directory owned by root
+func (i *Installer) createDirectories() error {
+	owned := []string{config.AgentStateDir, i.config.Agent.LogDir, cacheDir, runtimeDir}
+	for _, dir := range append([]string{configDir}, owned...) {
+		if err := sysutil.RunSystemCommand("mkdir", "-p", dir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := sysutil.RunSystemCommand("chmod", "755", dir); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %w", dir, err)
+		}
+	}
+	for _, dir := range owned {
+		if err := sysutil.RunSystemCommand("chown", serviceUser+":"+serviceUser, dir); err != nil {
+			return fmt.Errorf("failed to hand %s to %s: %w", dir, serviceUser, err)
+		}
+	}
+	return nil
+}
+
+// shareCLILogin lets the service user, which joins the group of the login owner, read and refresh the Azure CLI tokens
+func (i *Installer) shareCLILogin(login *cliLogin) error {
+	i.logger.Infof("Sharing the Azure CLI login of %s in %s with the service", login.user, login.configDir)
+	commands := [][]string{
+		{"chgrp", "-R", login.user, login.configDir},
+		{"chmod", "-R", "g+rwX", login.configDir},
+		// New files inherit the group of the directory
+		{"find", login.configDir, "-type", "d", "-exec", "chmod", "g+s", "{}", "+"},
+	}
+	for _, command := range commands {
+		if err := sysutil.RunSystemCommand(command[0], command[1:]...); err != nil {
+			return fmt.Errorf("failed to share the Azure CLI login in %s: %w", login.configDir, err)
+		}
+	}
+	return nil
+}
+
+// installSudoers checks the sudoers rules with visudo before installing them, as invalid rules would break sudo
+// for every user
+func (i *Installer) installSudoers() error {
+	tempFile, err := fsutil.CreateTempFile("aks-flex-node-sudoers-*", i.files.Sudoers)
+	if err != nil {
+		return fmt.Errorf("failed to stage sudoers rules: %w", err)
+	}
+	_ = tempFile.Close()
+	defer fsutil.CleanupTempFile(tempFile.Name())
+
+	if err := sysutil.RunSystemCommand("visudo", "-c", "-f", tempFile.Name()); err != nil {
+		return fmt.Errorf("sudoers rules failed validation: %w", err)
+	}
+	if err := fsutil.WriteFileAtomicSystem(sudoersPath, i.files.Sudoers, 0o440); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sudoersPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package agent_service
+
+import (
+	"testing"
+)
+
+const testUnit = `[Service]
+ExecStart=/usr/local/bin/aks-flex-node agent --config /etc/aks-flex-node/config.json
+User=aks-flex-node
+SupplementaryGroups=himds PLACEHOLDER_USER_GROUP
+Environment=AZURE_CONFIG_DIR=PLACEHOLDER_AZURE_CONFIG_DIR
+RuntimeDirectory=aks-flex-node
+`
+
+func TestRenderUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		login   *cliLogin
+		want    string
+	}{
+		{
+			name:  "shared Azure CLI login",
+			login: &cliLogin{user: "azureuser", configDir: "/home/azureuser/.azure"},
+			want: `[Service]
+ExecStart=/opt/aks-flex-node agent --config /srv/config.json
+User=aks-flex-node
+SupplementaryGroups=himds azureuser
+Environment=AZURE_CONFIG_DIR=/home/azureuser/.azure
+RuntimeDirectory=aks-flex-node
+`,
+		},
+		{
+			name:    "selected node pool profile",
+			profile: "gpu",
+			want: `[Service]
+ExecStart=/opt/aks-flex-node agent --config /srv/config.json --profile gpu
+User=aks-flex-node
+SupplementaryGroups=himds
+RuntimeDirectory=aks-flex-node
+`,
+		},
+		{
+			name: "without Azure CLI login",
+			want: `[Service]
+ExecStart=/opt/aks-flex-node agent --config /srv/config.json
+User=aks-flex-node
+SupplementaryGroups=himds
+RuntimeDirectory=aks-flex-node
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(renderUnit([]byte(testUnit), "/opt/aks-flex-node", "/srv/config.json", tt.profile, tt.login))
+			if got != tt.want {
+				t.Errorf("renderUnit() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package agent_service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller removes the agent service, its sudoers rules and its user. It is the last unbootstrap step, as
+// the steps before it may run their commands through the service user's sudoers rules.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new agent service UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "AgentService_UnInstaller"
+}
+
+// IsCompleted returns true when neither the unit, the sudoers rules nor the service user are left
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return !fsutil.FileExists(unitPath) && !fsutil.FileExists(sudoersPath) && !userExists()
+}
+
+// Plan describes the service removal for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{
+		fmt.Sprintf("stop and disable %s, remove %s and %s", serviceName, unitPath, sudoersPath),
+		fmt.Sprintf("delete the %s user and remove %s and %s", serviceUser, runtimeDir, u.config.Agent.LogDir),
+	}
+}
+
+// Execute stops and removes the service. Run from the service itself, e.g. through the orchestration API, it
+// leaves the service in place, as stopping it would end the unbootstrap.
+func (u *UnInstaller) Execute(_ context.Context) error {
+	if runningInService() {
+		u.logger.Warnf("Unbootstrap runs in %s, leaving the service installed; remove it with aks-flex-node uninstall-service", serviceName)
+		return nil
+	}
+	u.logger.Infof("Removing the %s service", serviceName)
+
+	if sysutil.IsServiceActive(serviceName) {
+		if err := sysutil.StopService(serviceName); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", serviceName, err)
+		}
+	}
+	if sysutil.ServiceExists(serviceName) {
+		if err := sysutil.DisableService(serviceName); err != nil {
+			u.logger.Warnf("Failed to disable %s: %v", serviceName, err)
+		}
+	}
+	if fileErrors := fsutil.RemoveFiles([]string{unitPath, sudoersPath}, u.logger); len(fileErrors) > 0 {
+		return fmt.Errorf("failed to remove the service files: %v", fileErrors)
+	}
+	if err := sysutil.ReloadSystemd(); err != nil {
+		u.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+
+	if userExists() {
+		// The home directory is the agent state directory, kept for the records other steps leave there
+		if err := sysutil.RunSystemCommand("userdel", serviceUser); err != nil {
+			return fmt.Errorf("failed to delete service user %s: %w", serviceUser, err)
+		}
+		// Files owned by the deleted user would belong to the next user given its ID
+		for _, dir := range []string{config.AgentStateDir, cacheDir} {
+			if fsutil.DirectoryExists(dir) {
+				if err := sysutil.RunSystemCommand("chown", "-R", "root:root", dir); err != nil {
+					u.logger.Warnf("Failed to hand %s back to root: %v", dir, err)
+				}
+			}
+		}
+	}
+
+	if dirErrors := fsutil.RemoveDirectories([]string{runtimeDir, u.config.Agent.LogDir}, u.logger); len(dirErrors) > 0 {
+		u.logger.Warnf("Failed to remove service directories: %v", dirErrors)
+	}
+	u.logger.Infof("Removed the %s service", serviceName)
+	return nil
+}
@@ -0,0 +1,21 @@
+package agent_service
+
+// The agent service and the account it runs as, matching the unit and sudoers rules shipped with the agent
+const (
+	serviceName = "aks-flex-node-agent"
+	serviceUser = "aks-flex-node"
+
+	unitPath    = "/etc/systemd/system/aks-flex-node-agent.service"
+	sudoersPath = "/etc/sudoers.d/aks-flex-node"
+
+	// runtimeDir holds the status and progress files of the service, systemd recreates it on every start
+	runtimeDir = "/run/aks-flex-node"
+	configDir  = "/etc/aks-flex-node"
+	cacheDir   = "/var/cache/aks-flex-node"
+)
+
+// Placeholders of the shipped unit replaced with the Azure CLI login the service shares
+const (
+	placeholderAzureConfigDir = "PLACEHOLDER_AZURE_CONFIG_DIR"
+	placeholderUserGroup      = "PLACEHOLDER_USER_GROUP"
+)
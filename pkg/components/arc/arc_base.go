@@ -3,6 +3,8 @@ package arc
 import (
 	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
@@ -12,6 +14,7 @@ import (
 
 	"go.goms.io/aks/AKSFlexNode/pkg/auth"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
 )
 
 // RoleAssignment represents a role assignment configuration
@@ -51,19 +54,19 @@ func (ab *base) setUpClients(ctx context.Context) error {
 	}
 
 	// Create hybrid compute machines client
-	hybridComputeMachineClient, err := armhybridcompute.NewMachinesClient(config.GetConfig().GetSubscriptionID(), cred, nil)
+	hybridComputeMachineClient, err := armhybridcompute.NewMachinesClient(config.GetConfig().GetSubscriptionID(), cred, auth.ClientOptions(config.GetConfig()))
 	if err != nil {
 		return fmt.Errorf("failed to create hybrid compute client: %w", err)
 	}
 
 	// Create managed clusters client
-	mcClient, err := armcontainerservice.NewManagedClustersClient(config.GetConfig().GetSubscriptionID(), cred, nil)
+	mcClient, err := armcontainerservice.NewManagedClustersClient(config.GetConfig().GetSubscriptionID(), cred, auth.ClientOptions(config.GetConfig()))
 	if err != nil {
 		return fmt.Errorf("failed to create managed clusters client: %w", err)
 	}
 
 	// Create role assignments client
-	azureClient, err := armauthorization.NewRoleAssignmentsClient(config.GetConfig().GetSubscriptionID(), cred, nil)
+	azureClient, err := armauthorization.NewRoleAssignmentsClient(config.GetConfig().GetSubscriptionID(), cred, auth.ClientOptions(config.GetConfig()))
 	if err != nil {
 		return fmt.Errorf("failed to create role assignments client: %w", err)
 	}
@@ -136,45 +139,81 @@ func (ab *base) checkRoleAssignment(ctx context.Context, principalID, roleDefini
 	fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
 		ab.config.Azure.SubscriptionID, roleDefinitionID)
 
-	// List role assignments for the scope
-	pager := ab.roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
-		Filter: nil, // We'll filter programmatically
-	})
-
-	for pager.More() {
-		page, err := pager.NextPage(ctx)
-		if err != nil {
-			return false, fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
-		}
+	// List role assignments for the scope, listing again from the start when a page fails transiently
+	found := false
+	err := ab.retryAzure(ctx, "listing role assignments", func(ctx context.Context) error {
+		pager := ab.roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
+			Filter: nil, // We'll filter programmatically
+		})
+
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
+			}
 
-		// Check each role assignment
-		for _, assignment := range page.Value {
-			if assignment.Properties != nil &&
-				assignment.Properties.PrincipalID != nil &&
-				assignment.Properties.RoleDefinitionID != nil &&
-				*assignment.Properties.PrincipalID == principalID &&
-				*assignment.Properties.RoleDefinitionID == fullRoleDefinitionID {
-				return true, nil
+			// Check each role assignment
+			for _, assignment := range page.Value {
+				if assignment.Properties != nil &&
+					assignment.Properties.PrincipalID != nil &&
+					assignment.Properties.RoleDefinitionID != nil &&
+					*assignment.Properties.PrincipalID == principalID &&
+					*assignment.Properties.RoleDefinitionID == fullRoleDefinitionID {
+					found = true
+					return nil
+				}
 			}
 		}
-	}
+		return nil
+	})
+	return found, err
+}
 
-	return false, nil
+// retryAzure runs an Azure API call with the agent retry policy, logging each retry of the operation
+func (ab *base) retryAzure(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	policy := ab.config.GetRetryPolicy()
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		ab.logger.Warnf("⚠️  %s failed (attempt %d/%d), retrying in %v: %v", operation, attempt, policy.Attempts, delay.Round(time.Millisecond), err)
+	}
+	return retry.Do(ctx, policy, fn)
 }
 
-// ensureAuthentication ensures the appropriate authentication (SP or CLI) method is set up
+// ensureAuthentication ensures the appropriate authentication (SP, workload identity or CLI) method is set up
 func (ab *base) ensureAuthentication(ctx context.Context) error {
 	if ab.config.IsSPConfigured() {
 		ab.logger.Info("🔐 Using service principal authentication")
 		return nil
 	}
+	if ab.config.IsWorkloadIdentityConfigured() {
+		ab.logger.Info("🔐 Using workload identity authentication")
+		if _, err := os.Stat(ab.config.Azure.WorkloadIdentity.TokenFile); err != nil {
+			return fmt.Errorf("workload identity token file is not available: %w", err)
+		}
+		return nil
+	}
 
 	ab.logger.Info("🔐 Checking Azure CLI authentication status...")
-	tenantID := ab.config.GetTenantID()
-	if err := ab.authProvider.EnsureAuthenticated(ctx, tenantID); err != nil {
+	if err := ab.authProvider.EnsureAuthenticated(ctx, ab.config); err != nil {
 		ab.logger.Errorf("Failed to ensure Azure CLI authentication: %v", err)
 		return err
 	}
 	ab.logger.Info("✅ Azure CLI authentication verified")
 	return nil
 }
+
+// FetchMachineTags returns the tags of this node's Arc machine
+func FetchMachineTags(ctx context.Context, cfg *config.Config) (map[string]*string, error) {
+	cred, err := auth.NewAuthProvider().UserCredential(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authentication credential: %w", err)
+	}
+	client, err := armhybridcompute.NewMachinesClient(cfg.GetSubscriptionID(), cred, auth.ClientOptions(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hybrid compute client: %w", err)
+	}
+	result, err := client.Get(ctx, cfg.GetArcResourceGroup(), cfg.GetArcMachineName(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Arc machine %s: %w", cfg.GetArcMachineName(), err)
+	}
+	return result.Tags, nil
+}
@@ -2,8 +2,12 @@ package arc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,7 +17,11 @@ import (
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
 )
 
 // Installer handles Azure Arc installation operations
@@ -28,17 +36,13 @@ func NewInstaller(logger *logrus.Logger) *Installer {
 	}
 }
 
-// Validate validates prerequisites for Arc installation
-func (i *Installer) Validate(ctx context.Context) error {
+// Validate validates prerequisites for Arc installation. Setting up authentication may select the Azure CLI cloud
+// and sign in, so Execute does it and dry runs, which validate the steps, leave the Azure CLI alone.
+func (i *Installer) Validate(_ context.Context) error {
 	if !i.config.IsARCEnabled() {
 		i.logger.Info("Azure Arc installation is disabled in configuration")
 		return nil
 	}
-	// Ensure SP or CLI auth is ready for Arc agent setup
-	if err := i.ensureAuthentication(ctx); err != nil {
-		i.logger.Errorf("Authentication setup failed: %v", err)
-		return fmt.Errorf("arc bootstrap setup failed at authentication: %w", err)
-	}
 	// Ensure Arc agent is installed and running
 	if !isArcAgentInstalled() {
 		i.logger.Info("Azure Arc agent not found")
@@ -73,6 +77,11 @@ func (i *Installer) Execute(ctx context.Context) error {
 	}
 	i.logger.Info("Successfully registered Arc machine with Azure")
 
+	// Record the agent build on the machine resource; failing to do so does not affect the node
+	if err := i.syncBuildTags(ctx, arcMachine); err != nil {
+		i.logger.Warnf("Failed to update Arc machine build tags: %v", err)
+	}
+
 	// Step 3: Validate managed cluster requirements
 	i.logger.Info("Step 3: Validating Managed Cluster requirements")
 	if err := i.validateManagedCluster(ctx); err != nil {
@@ -81,7 +90,12 @@ func (i *Installer) Execute(ctx context.Context) error {
 	}
 
 	// Step 4: Assign RBAC roles to managed identity
-	time.Sleep(10 * time.Second) // brief pause to ensure identity is ready
+	// Brief pause to ensure identity is ready
+	select {
+	case <-time.After(10 * time.Second):
+	case <-ctx.Done():
+		return fmt.Errorf("arc bootstrap setup cancelled before RBAC role assignment: %w", ctx.Err())
+	}
 	i.logger.Info("Step 4: Assigning RBAC roles to managed identity")
 	if err := i.assignRBACRoles(ctx, arcMachine); err != nil {
 		i.logger.Errorf("Failed to assign RBAC roles: %v", err)
@@ -129,12 +143,16 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 			if len(parts) == 2 {
 				status := strings.TrimSpace(parts[1])
 				isConnected := strings.ToLower(status) == "connected"
-				if isConnected {
-					i.logger.Debug("Arc setup appears to be completed - agent is connected")
-				} else {
+				if !isConnected {
 					i.logger.Debugf("Arc agent status is '%s' - not ready", status)
+					return false
+				}
+				if !buildTagsCurrent() {
+					i.logger.Debug("Arc machine build tags were written by another agent build - refreshing them")
+					return false
 				}
-				return isConnected
+				i.logger.Debug("Arc setup appears to be completed - agent is connected")
+				return true
 			}
 		}
 	}
@@ -143,6 +161,22 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 	return false
 }
 
+// Plan describes the Arc setup for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	if !i.config.IsARCEnabled() {
+		return nil
+	}
+	actions := []string{
+		fmt.Sprintf("connect Arc machine %s in resource group %s", i.config.GetArcMachineName(), i.config.GetArcResourceGroup()),
+		"tag the Arc machine with the agent build",
+		"validate managed cluster " + i.config.GetTargetClusterID(),
+	}
+	for _, role := range i.getRoleAssignments() {
+		actions = append(actions, fmt.Sprintf("assign role %s on %s to the Arc managed identity", role.roleName, role.scope))
+	}
+	return actions
+}
+
 // registerArcMachine registers the machine with Azure Arc using the Arc agent
 func (i *Installer) registerArcMachine(ctx context.Context) (*armhybridcompute.Machine, error) {
 	i.logger.Info("Registering machine with Azure Arc using Arc agent")
@@ -164,6 +198,24 @@ func (i *Installer) registerArcMachine(ctx context.Context) (*armhybridcompute.M
 	return i.waitForArcRegistration(ctx)
 }
 
+// syncBuildTags writes the agent build to the Arc machine tags when they are out of date
+func (i *Installer) syncBuildTags(ctx context.Context, arcMachine *armhybridcompute.Machine) error {
+	build := buildinfo.Get()
+	tags, changed := mergeBuildTags(arcMachine.Tags, build)
+	if changed {
+		i.logger.Infof("Tagging Arc machine with agent version %s (commit %s)", build.Version, build.GitCommit)
+		if _, err := i.hybridComputeMachineClient.Update(ctx, i.config.GetArcResourceGroup(), i.config.GetArcMachineName(),
+			armhybridcompute.MachineUpdate{Tags: tags}, nil); err != nil {
+			return fmt.Errorf("failed to update Arc machine tags: %w", err)
+		}
+	}
+
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(buildTagStatePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", buildTagStatePath, err)
+	}
+	return utils.WriteFileAtomicSystem(buildTagStatePath, []byte(buildTagState(build)+"\n"), 0o644)
+}
+
 func (i *Installer) validateManagedCluster(ctx context.Context) error {
 	i.logger.Info("Validating target AKS Managed Cluster requirements for Azure RBAC authentication")
 
@@ -234,13 +286,15 @@ func (i *Installer) runArcAgentConnect(ctx context.Context) error {
 		"--location", arcLocation,
 		"--subscription-id", subscriptionID,
 		"--resource-name", arcMachineName,
+		"--cloud", auth.Cloud(i.config).ArcCloud,
 	}
 
-	// Add Arc tags if any
-	tags := i.config.GetArcTags()
+	// Add Arc tags if any, along with the agent build tags
+	tags := maps.Clone(i.config.GetArcTags())
+	maps.Copy(tags, buildTags(buildinfo.Get()))
 	tagArgs := []string{}
-	for key, value := range tags {
-		tagArgs = append(tagArgs, "--tags", fmt.Sprintf("%s=%s", key, value))
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		tagArgs = append(tagArgs, "--tags", fmt.Sprintf("%s=%s", key, tags[key]))
 	}
 	args = append(args, tagArgs...)
 
@@ -251,7 +305,7 @@ func (i *Installer) runArcAgentConnect(ctx context.Context) error {
 	}
 
 	// Execute azcmagent command securely (avoid logging access token)
-	if err := i.runAzcmagentSecurely("azcmagent", args); err != nil {
+	if err := i.runAzcmagentSecurely(ctx, "azcmagent", args); err != nil {
 		return fmt.Errorf("failed to connect to Azure Arc: %w", err)
 	}
 
@@ -299,8 +353,9 @@ func (i *Installer) assignRBACRoles(ctx context.Context, arcMachine *armhybridco
 	return nil
 }
 
-// assignRole creates a role assignment for the given principal, role, and scope
-// Implements retry logic with exponential backoff to handle Azure AD replication delays
+// assignRole creates a role assignment for the given principal, role, and scope.
+// PrincipalNotFound, which Azure AD replication delays cause for a freshly created identity, is retried with the
+// agent retry policy, as are throttling, server and network errors.
 func (i *Installer) assignRole(
 	ctx context.Context, principalID, roleDefinitionID, scope, roleName string,
 ) error {
@@ -308,86 +363,69 @@ func (i *Installer) assignRole(
 	fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
 		i.config.Azure.SubscriptionID, roleDefinitionID)
 
-	const (
-		maxRetries   = 5
-		initialDelay = 5 * time.Second
-		maxDelay     = 30 * time.Second
-	)
-
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			delay := min(initialDelay*time.Duration(1<<(attempt-1)), maxDelay)
-			i.logger.Infof("⏳ Retrying role assignment after %v (attempt %d/%d)...", delay, attempt+1, maxRetries)
-			select {
-			case <-time.After(delay):
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+	policy := i.config.GetRetryPolicy()
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		if strings.Contains(err.Error(), "PrincipalNotFound") {
+			i.logger.Warnf("⚠️  Principal not found (Azure AD replication delay) - will retry...")
 		}
+		i.logger.Infof("⏳ Retrying role assignment after %v (attempt %d/%d)...", delay.Round(time.Millisecond), attempt+1, policy.Attempts)
+	}
 
-		roleAssignmentName := uuid.New().String()
-		i.logger.Debugf("Calling Azure API to create role assignment with ID: %s (attempt %d/%d)", roleAssignmentName, attempt+1, maxRetries)
+	var roleAssignmentName string
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		roleAssignmentName = uuid.New().String()
+		i.logger.Debugf("Calling Azure API to create role assignment with ID: %s", roleAssignmentName)
 
 		// Set PrincipalType to ServicePrincipal for Arc managed identities
 		// This helps Azure work around replication delays when the identity was just created
 		principalType := armauthorization.PrincipalTypeServicePrincipal
+		description := roleAssignmentDescriptionPrefix + i.config.GetArcMachineName() + roleAssignmentDescriptionSubscription + i.config.GetSubscriptionID()
 		assignment := armauthorization.RoleAssignmentCreateParameters{
 			Properties: &armauthorization.RoleAssignmentProperties{
 				PrincipalID:      &principalID,
 				RoleDefinitionID: &fullRoleDefinitionID,
 				PrincipalType:    &principalType,
+				Description:      &description,
 			},
 		}
 
 		// this create operation is synchronous - we need to wait for the role propagation to take effect afterwards
-		if _, err := i.roleAssignmentsClient.Create(ctx, scope, roleAssignmentName, assignment, nil); err != nil {
-			lastErr = err
-			errStr := err.Error()
-
-			// Check for common error patterns
-			if strings.Contains(errStr, "403") || strings.Contains(errStr, "Forbidden") {
-				return fmt.Errorf("insufficient permissions to assign roles - ensure the user/service principal has Owner or User Access Administrator role on the target cluster: %w", err)
-			}
-			if strings.Contains(errStr, "RoleAssignmentExists") {
-				i.logger.Info("ℹ️  Role assignment already exists (detected from error)")
-				return nil
-			}
-
-			// PrincipalNotFound is retriable - likely Azure AD replication delay
-			if strings.Contains(errStr, "PrincipalNotFound") {
-				i.logger.Warnf("⚠️  Principal not found (Azure AD replication delay) - will retry...")
-				// Provide detailed error information on last attempt only
-				if attempt == maxRetries-1 {
-					i.logger.Errorf("❌ Role assignment creation failed after %d attempts:", maxRetries)
-					i.logger.Errorf("   Principal ID: %s", principalID)
-					i.logger.Errorf("   Role Name: %s", roleName)
-					i.logger.Errorf("   Role Definition ID: %s", fullRoleDefinitionID)
-					i.logger.Errorf("   Scope: %s", scope)
-					i.logger.Errorf("   Assignment Name: %s", roleAssignmentName)
-					i.logger.Errorf("   Azure API Error: %v", err)
-				}
-				continue // Retry
-			}
-
-			// Non-retriable error - log details and return
-			i.logger.Errorf("❌ Role assignment creation failed:")
-			i.logger.Errorf("   Principal ID: %s", principalID)
-			i.logger.Errorf("   Role Name: %s", roleName)
-			i.logger.Errorf("   Role Definition ID: %s", fullRoleDefinitionID)
-			i.logger.Errorf("   Scope: %s", scope)
-			i.logger.Errorf("   Assignment Name: %s", roleAssignmentName)
-			i.logger.Errorf("   Azure API Error: %v", err)
-			return fmt.Errorf("failed to create role assignment: %s", err)
+		_, err := i.roleAssignmentsClient.Create(ctx, scope, roleAssignmentName, assignment, nil)
+		if err == nil {
+			return nil
 		}
-
-		// Success
-		i.logger.Debugf("✅ Role assignment created successfully")
-		return nil
+		errStr := err.Error()
+		switch {
+		// Check for common error patterns
+		case strings.Contains(errStr, "403") || strings.Contains(errStr, "Forbidden"):
+			return failure.Permanent(fmt.Errorf("insufficient permissions to assign roles - ensure the user/service principal has Owner or User Access Administrator role on the target cluster: %w", err))
+		case strings.Contains(errStr, "RoleAssignmentExists"):
+			i.logger.Info("ℹ️  Role assignment already exists (detected from error)")
+			return nil
+		case strings.Contains(errStr, "PrincipalNotFound"):
+			// Retriable - likely Azure AD replication delay
+			return failure.Transient(fmt.Errorf("arc managed identity not found due to Azure AD replication delay: %w", err))
+		case failure.Classify(err) == failure.ClassTransient:
+			return err
+		}
+		return failure.Permanent(err)
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		i.logger.Errorf("❌ Role assignment creation failed:")
+		i.logger.Errorf("   Principal ID: %s", principalID)
+		i.logger.Errorf("   Role Name: %s", roleName)
+		i.logger.Errorf("   Role Definition ID: %s", fullRoleDefinitionID)
+		i.logger.Errorf("   Scope: %s", scope)
+		i.logger.Errorf("   Assignment Name: %s", roleAssignmentName)
+		i.logger.Errorf("   Azure API Error: %v", err)
+		return fmt.Errorf("failed to assign role %s: %w", roleName, err)
 	}
 
-	// Max retries exhausted
-	return fmt.Errorf("failed to assign role after %d attempts due to Azure AD replication delay - arc managed identity not found: %w", maxRetries, lastErr)
+	i.logger.Debugf("✅ Role assignment created successfully")
+	return nil
 }
 
 // waitForPermissions waits for RBAC permissions propagation with timeout
@@ -423,7 +461,7 @@ func (i *Installer) addAuthenticationArgs(ctx context.Context, args *[]string) e
 		return fmt.Errorf("failed to get Azure credentials: %w", err)
 	}
 
-	accessToken, err := i.authProvider.GetAccessToken(ctx, cred)
+	accessToken, err := i.authProvider.GetAccessToken(ctx, i.config, cred)
 	if err != nil {
 		return fmt.Errorf("failed to get access token for Arc agent authentication: %w", err)
 	}
@@ -434,23 +472,12 @@ func (i *Installer) addAuthenticationArgs(ctx context.Context, args *[]string) e
 }
 
 // runAzcmagentSecurely executes azcmagent command without logging sensitive arguments
-func (i *Installer) runAzcmagentSecurely(name string, args []string) error {
-	// Create command args without exposing the access token in logs
-	maskedArgs := make([]string, len(args))
-	copy(maskedArgs, args)
-
-	// Find and mask the access token
-	for j := 0; j < len(maskedArgs)-1; j++ {
-		if maskedArgs[j] == "--access-token" {
-			maskedArgs[j+1] = "***REDACTED***"
-			break
-		}
-	}
-
-	i.logger.Infof("Executing command: %s %v", name, maskedArgs)
+func (i *Installer) runAzcmagentSecurely(ctx context.Context, name string, args []string) error {
+	// Log the command without exposing the access token
+	i.logger.Infof("Executing command: %s %v", name, utils.RedactArgs(args))
 
 	// Execute the actual command with real args but capture output to avoid logging
-	_, err := utils.RunCommandWithOutput(name, args...)
+	_, err := utils.RunCommandContext(ctx, name, args...)
 	if err != nil {
 		return err
 	}
@@ -10,7 +10,9 @@ import (
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
 )
 
@@ -137,9 +139,9 @@ func TestAssignRole_PrincipalNotFound_RetriesAndSucceeds(t *testing.T) {
 	if mockClient.callCount != 3 {
 		t.Errorf("Expected 3 API calls (2 failures + 1 success), got %d", mockClient.callCount)
 	}
-	// Should have delays: 5s + 10s = 15s (with some tolerance)
-	if duration < 14*time.Second {
-		t.Errorf("Expected at least 15s of retries, got %v", duration)
+	// Should have delays of 5s + 10s, of which jitter keeps at least half
+	if duration < 7*time.Second {
+		t.Errorf("Expected at least 7.5s of retries, got %v", duration)
 	}
 }
 
@@ -177,8 +179,8 @@ func TestAssignRole_PrincipalNotFound_ExhaustsRetries(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error after exhausting retries, got nil")
 	}
-	if !strings.Contains(err.Error(), "failed to assign role after") {
-		t.Errorf("Expected 'failed to assign role after' error message, got: %v", err)
+	if !strings.Contains(err.Error(), "giving up after 5 attempts") {
+		t.Errorf("Expected 'giving up after 5 attempts' error message, got: %v", err)
 	}
 	if !strings.Contains(err.Error(), "Azure AD replication delay") {
 		t.Errorf("Expected 'Azure AD replication delay' in error message, got: %v", err)
@@ -435,7 +437,8 @@ func TestAssignRole_ExponentialBackoff(t *testing.T) {
 		t.Fatalf("Expected 4 attempts, got %d", len(attemptTimes))
 	}
 
-	// Check backoff delays: attempt1->attempt2 (~5s), attempt2->attempt3 (~10s), attempt3->attempt4 (~20s)
+	// Check backoff delays: attempt1->attempt2 (~5s), attempt2->attempt3 (~10s), attempt3->attempt4 (~20s),
+	// of which jitter keeps between half and all
 	delays := []time.Duration{
 		attemptTimes[1].Sub(attemptTimes[0]),
 		attemptTimes[2].Sub(attemptTimes[1]),
@@ -446,8 +449,28 @@ func TestAssignRole_ExponentialBackoff(t *testing.T) {
 	tolerance := 500 * time.Millisecond
 
 	for i, delay := range delays {
-		if delay < expectedDelays[i]-tolerance || delay > expectedDelays[i]+tolerance {
-			t.Errorf("Attempt %d->%d: expected delay ~%v, got %v", i+1, i+2, expectedDelays[i], delay)
+		if delay < expectedDelays[i]/2-tolerance || delay > expectedDelays[i]+tolerance {
+			t.Errorf("Attempt %d->%d: expected delay between %v and %v, got %v", i+1, i+2, expectedDelays[i]/2, expectedDelays[i], delay)
 		}
 	}
 }
+
+func TestMergeBuildTags(t *testing.T) {
+	build := buildinfo.Info{Version: "v0.5.0", GitCommit: "f5d4f7f"}
+	owner := "edge-team"
+
+	tags, changed := mergeBuildTags(map[string]*string{"owner": &owner}, build)
+	if !changed {
+		t.Error("mergeBuildTags() changed = false for a machine without build tags")
+	}
+	if to.String(tags["owner"]) != owner {
+		t.Errorf("mergeBuildTags() dropped user tag, got %v", tags)
+	}
+	if to.String(tags[agentVersionTag]) != "v0.5.0" || to.String(tags[agentCommitTag]) != "f5d4f7f" {
+		t.Errorf("mergeBuildTags() build tags = %q/%q, want v0.5.0/f5d4f7f", to.String(tags[agentVersionTag]), to.String(tags[agentCommitTag]))
+	}
+
+	if _, changed := mergeBuildTags(tags, build); changed {
+		t.Error("mergeBuildTags() changed = true for tags already matching the build")
+	}
+}
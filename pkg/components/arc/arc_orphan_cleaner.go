@@ -0,0 +1,155 @@
+package arc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// OrphanedRoleAssignment is a role assignment created by the agent whose Arc machine no longer exists
+type OrphanedRoleAssignment struct {
+	Name        string // role assignment name (GUID)
+	Scope       string
+	PrincipalID string
+	RoleName    string
+	MachineName string // Arc machine name recorded in the assignment description
+}
+
+// OrphanCleaner finds and removes role assignments left behind by deleted Arc machines
+type OrphanCleaner struct {
+	*base
+}
+
+// NewOrphanCleaner creates a new OrphanCleaner
+func NewOrphanCleaner(logger *logrus.Logger) *OrphanCleaner {
+	return &OrphanCleaner{
+		base: newBase(logger),
+	}
+}
+
+// FindOrphanedRoleAssignments scans the target cluster scope for role assignments created by the agent
+// whose principal no longer belongs to any Arc machine in the subscription
+func (c *OrphanCleaner) FindOrphanedRoleAssignments(ctx context.Context) ([]OrphanedRoleAssignment, error) {
+	if err := c.setUpClients(ctx); err != nil {
+		return nil, fmt.Errorf("failed to set up Azure SDK clients: %w", err)
+	}
+
+	livePrincipals, err := c.listArcMachinePrincipals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Infof("Found %d Arc machine identities in subscription", len(livePrincipals))
+
+	scope := c.config.GetTargetClusterID()
+	pager := c.roleAssignmentsClient.NewListForScopePager(scope, nil)
+
+	var assignments []*armauthorization.RoleAssignment
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
+		}
+		assignments = append(assignments, page.Value...)
+	}
+
+	orphans, skipped := findOrphanedRoleAssignments(assignments, c.config.GetSubscriptionID(), livePrincipals, c.roleNamesByDefinitionID())
+	if skipped > 0 {
+		c.logger.Warnf("Skipped %d role assignment(s) of Arc machines outside subscription %s or created by agents that did not "+
+			"record the machine's subscription, check and remove them manually", skipped, c.config.GetSubscriptionID())
+	}
+	return orphans, nil
+}
+
+// RemoveRoleAssignments deletes the given role assignments, continuing past individual failures
+func (c *OrphanCleaner) RemoveRoleAssignments(ctx context.Context, orphans []OrphanedRoleAssignment) error {
+	var removalErrors []string
+	for _, orphan := range orphans {
+		c.logger.Infof("Removing role assignment %s (%s) for deleted Arc machine %s", orphan.Name, orphan.RoleName, orphan.MachineName)
+		if _, err := c.roleAssignmentsClient.Delete(ctx, orphan.Scope, orphan.Name, nil); err != nil {
+			if strings.Contains(err.Error(), "RoleAssignmentNotFound") || strings.Contains(err.Error(), "NotFound") {
+				c.logger.Debugf("Role assignment %s not found (already deleted)", orphan.Name)
+				continue
+			}
+			removalErrors = append(removalErrors, fmt.Sprintf("%s: %v", orphan.Name, err))
+		}
+	}
+
+	if len(removalErrors) > 0 {
+		return fmt.Errorf("failed to remove some role assignments: %s", strings.Join(removalErrors, "; "))
+	}
+	return nil
+}
+
+// listArcMachinePrincipals returns the managed identity principal IDs of all Arc machines in the subscription
+func (c *OrphanCleaner) listArcMachinePrincipals(ctx context.Context) (map[string]bool, error) {
+	principals := make(map[string]bool)
+	pager := c.hybridComputeMachineClient.NewListBySubscriptionPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Arc machines: %w", err)
+		}
+		for _, machine := range page.Value {
+			if principalID := getArcMachineIdentityID(machine); principalID != "" {
+				principals[principalID] = true
+			}
+		}
+	}
+	return principals, nil
+}
+
+// roleNamesByDefinitionID maps the full role definition IDs assigned by the agent to their role names
+func (c *OrphanCleaner) roleNamesByDefinitionID() map[string]string {
+	roleNames := make(map[string]string)
+	for _, role := range c.getRoleAssignments() {
+		fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
+			c.config.Azure.SubscriptionID, role.roleID)
+		roleNames[strings.ToLower(fullRoleDefinitionID)] = role.roleName
+	}
+	return roleNames
+}
+
+// findOrphanedRoleAssignments selects the agent-created assignments of roles it manages whose principal is not live.
+// Only assignments of machines in the enumerated subscription are candidates, the principals of machines in other
+// subscriptions are not known. It also returns how many agent-created assignments were skipped for that reason.
+func findOrphanedRoleAssignments(
+	assignments []*armauthorization.RoleAssignment, subscriptionID string, livePrincipals map[string]bool, roleNames map[string]string,
+) ([]OrphanedRoleAssignment, int) {
+	var orphans []OrphanedRoleAssignment
+	skipped := 0
+	for _, assignment := range assignments {
+		if assignment == nil || assignment.Name == nil || assignment.Properties == nil {
+			continue
+		}
+		props := assignment.Properties
+		if props.PrincipalID == nil || props.RoleDefinitionID == nil || props.Description == nil || props.Scope == nil {
+			continue
+		}
+
+		machine, ok := strings.CutPrefix(*props.Description, roleAssignmentDescriptionPrefix)
+		if !ok {
+			continue
+		}
+		roleName, ok := roleNames[strings.ToLower(*props.RoleDefinitionID)]
+		if !ok || livePrincipals[*props.PrincipalID] {
+			continue
+		}
+		machineName, machineSubscription, ok := strings.Cut(machine, roleAssignmentDescriptionSubscription)
+		if !ok || !strings.EqualFold(machineSubscription, subscriptionID) {
+			skipped++
+			continue
+		}
+
+		orphans = append(orphans, OrphanedRoleAssignment{
+			Name:        *assignment.Name,
+			Scope:       *props.Scope,
+			PrincipalID: *props.PrincipalID,
+			RoleName:    roleName,
+			MachineName: machineName,
+		})
+	}
+	return orphans, skipped
+}
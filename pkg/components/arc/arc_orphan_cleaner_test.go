@@ -0,0 +1,57 @@
+package arc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestFindOrphanedRoleAssignments(t *testing.T) {
+	const (
+		scope        = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"
+		readerRoleID = "/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/reader"
+		otherRoleID  = "/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/other"
+	)
+	roleNames := map[string]string{strings.ToLower(readerRoleID): "Reader"}
+	livePrincipals := map[string]bool{"live-principal": true}
+
+	newAssignment := func(name, principalID, roleID, description string) *armauthorization.RoleAssignment {
+		return &armauthorization.RoleAssignment{
+			Name: to.StringPtr(name),
+			Properties: &armauthorization.RoleAssignmentProperties{
+				PrincipalID:      to.StringPtr(principalID),
+				RoleDefinitionID: to.StringPtr(roleID),
+				Description:      to.StringPtr(description),
+				Scope:            to.StringPtr(scope),
+			},
+		}
+	}
+
+	machine := func(name, subscription string) string {
+		return roleAssignmentDescriptionPrefix + name + roleAssignmentDescriptionSubscription + subscription
+	}
+
+	assignments := []*armauthorization.RoleAssignment{
+		newAssignment("orphan", "deleted-principal", readerRoleID, machine("edge-01", "arc-sub")),
+		newAssignment("live", "live-principal", readerRoleID, machine("edge-02", "arc-sub")),
+		newAssignment("user-created", "deleted-principal", readerRoleID, "granted by ops team"),
+		newAssignment("other-role", "deleted-principal", otherRoleID, machine("edge-01", "arc-sub")),
+		newAssignment("other-subscription", "unknown-principal", readerRoleID, machine("edge-03", "other-sub")),
+		newAssignment("no-subscription", "unknown-principal", readerRoleID, roleAssignmentDescriptionPrefix+"edge-04"),
+		{Name: to.StringPtr("no-properties")},
+	}
+
+	orphans, skipped := findOrphanedRoleAssignments(assignments, "ARC-SUB", livePrincipals, roleNames)
+	if len(orphans) != 1 {
+		t.Fatalf("expected 1 orphaned role assignment, got %d: %+v", len(orphans), orphans)
+	}
+	if skipped != 2 {
+		t.Errorf("skipped = %d, want the assignments of machines in other or unknown subscriptions", skipped)
+	}
+	orphan := orphans[0]
+	if orphan.Name != "orphan" || orphan.MachineName != "edge-01" || orphan.RoleName != "Reader" || orphan.Scope != scope {
+		t.Errorf("unexpected orphaned role assignment: %+v", orphan)
+	}
+}
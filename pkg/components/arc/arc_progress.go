@@ -0,0 +1,200 @@
+package arc
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// Arc machine tags carrying the bootstrap progress, so fleet operators can follow hundreds of nodes in Azure
+// Resource Graph
+const (
+	bootstrapPhaseTag     = "aks-flex-node-bootstrap-phase"   // running, succeeded or failed
+	bootstrapStepTag      = "aks-flex-node-bootstrap-step"    // current step with its position, e.g. "7/24 ContainerdInstaller"
+	bootstrapErrorTag     = "aks-flex-node-bootstrap-error"   // error of the failed step, removed when a bootstrap starts
+	bootstrapUpdatedAtTag = "aks-flex-node-bootstrap-updated" // when the agent last reported progress
+)
+
+// maxTagValueLength is the longest value Azure accepts for a tag
+const maxTagValueLength = 256
+
+// progressUpdateTimeout bounds a single update of the Arc machine tags
+const progressUpdateTimeout = 30 * time.Second
+
+// ProgressReporter reports the bootstrap progress in the tags of the Arc machine. Updates are sent in the
+// background so a slow or unreachable ARM never holds up a step. While one is in flight, later progress replaces
+// the pending one, and progress reported before the Arc machine is registered is sent with the next update.
+type ProgressReporter struct {
+	config *config.Config
+	logger *logrus.Logger
+	update func(ctx context.Context, tags map[string]string) error
+
+	mu      sync.Mutex
+	pending map[string]string
+	sent    map[string]string
+	idle    chan struct{} // closed when the running sender finishes, nil while none runs
+}
+
+// NewProgressReporter creates a ProgressReporter updating the tags of this node's Arc machine
+func NewProgressReporter(cfg *config.Config, logger *logrus.Logger) *ProgressReporter {
+	reporter := &ProgressReporter{config: cfg, logger: logger}
+	reporter.update = reporter.updateMachineTags
+	return reporter
+}
+
+// Report sends the progress to the Arc machine in the background
+func (r *ProgressReporter) Report(progress status.Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = progressTags(progress, buildinfo.Get())
+	if r.idle == nil && !maps.Equal(r.pending, r.sent) {
+		r.idle = make(chan struct{})
+		go r.send(r.idle)
+	}
+}
+
+// Flush waits up to timeout for the progress reported last to be sent, sending it once more when the background
+// update failed. It lets the final outcome of a bootstrap reach Azure before the agent moves on.
+func (r *ProgressReporter) Flush(timeout time.Duration) {
+	r.mu.Lock()
+	idle := r.idle
+	r.mu.Unlock()
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-time.After(timeout):
+			r.logger.Warnf("Timed out reporting bootstrap progress to the Arc machine")
+			return
+		}
+	}
+
+	r.mu.Lock()
+	tags := r.pending
+	r.mu.Unlock()
+	if tags == nil || maps.Equal(tags, r.sentTags()) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := r.update(ctx, tags); err != nil {
+		r.logger.Warnf("Failed to report bootstrap progress to the Arc machine: %v", err)
+		return
+	}
+	r.mu.Lock()
+	r.sent = tags
+	r.mu.Unlock()
+}
+
+// send updates the Arc machine until the pending progress is sent, or an update fails
+func (r *ProgressReporter) send(idle chan struct{}) {
+	defer close(idle)
+	for {
+		r.mu.Lock()
+		tags := r.pending
+		if maps.Equal(tags, r.sent) {
+			r.idle = nil
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), progressUpdateTimeout)
+		err := r.update(ctx, tags)
+		cancel()
+
+		r.mu.Lock()
+		if err != nil {
+			// The Arc machine does not exist before the Arc step ran, the next progress is sent with the next update
+			r.logger.Debugf("Failed to report bootstrap progress to the Arc machine: %v", err)
+			r.idle = nil
+			r.mu.Unlock()
+			return
+		}
+		r.sent = tags
+		r.mu.Unlock()
+	}
+}
+
+func (r *ProgressReporter) sentTags() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sent
+}
+
+// updateMachineTags merges the progress tags into the tags of the Arc machine
+func (r *ProgressReporter) updateMachineTags(ctx context.Context, tags map[string]string) error {
+	cred, err := auth.NewAuthProvider().UserCredential(r.config)
+	if err != nil {
+		return fmt.Errorf("failed to get authentication credential: %w", err)
+	}
+	client, err := armhybridcompute.NewMachinesClient(r.config.GetSubscriptionID(), cred, auth.ClientOptions(r.config))
+	if err != nil {
+		return fmt.Errorf("failed to create hybrid compute client: %w", err)
+	}
+	machine, err := client.Get(ctx, r.config.GetArcResourceGroup(), r.config.GetArcMachineName(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get Arc machine %s: %w", r.config.GetArcMachineName(), err)
+	}
+
+	merged := mergeProgressTags(machine.Tags, tags)
+	merged[bootstrapUpdatedAtTag] = to.Ptr(time.Now().UTC().Format(time.RFC3339))
+	if _, err := client.Update(ctx, r.config.GetArcResourceGroup(), r.config.GetArcMachineName(),
+		armhybridcompute.MachineUpdate{Tags: merged}, nil); err != nil {
+		return fmt.Errorf("failed to update Arc machine tags: %w", err)
+	}
+	return nil
+}
+
+// progressTags returns the Arc machine tags describing the progress and the agent build
+func progressTags(progress status.Progress, build buildinfo.Info) map[string]string {
+	tags := buildTags(build)
+	tags[bootstrapPhaseTag] = progress.Phase
+	if progress.CurrentStep != "" {
+		tags[bootstrapStepTag] = truncateTagValue(fmt.Sprintf("%d/%d %s", progress.StepIndex, progress.TotalSteps, progress.CurrentStep))
+	}
+	if progress.LastError != "" {
+		tags[bootstrapErrorTag] = truncateTagValue(progress.LastError)
+	}
+	return tags
+}
+
+// mergeProgressTags returns the machine tags with the progress tags applied. Progress tags missing from the
+// progress, such as the error of an earlier bootstrap, are removed; tags set by users are preserved.
+func mergeProgressTags(existing map[string]*string, progress map[string]string) map[string]*string {
+	merged := make(map[string]*string, len(existing)+len(progress)+1)
+	for key, value := range existing {
+		switch key {
+		case bootstrapStepTag, bootstrapErrorTag:
+			continue
+		}
+		merged[key] = value
+	}
+	for key, value := range progress {
+		merged[key] = to.Ptr(value)
+	}
+	return merged
+}
+
+// truncateTagValue shortens a value to the length Azure accepts for a tag, keeping whole characters
+func truncateTagValue(value string) string {
+	if len(value) <= maxTagValueLength {
+		return value
+	}
+	cut := maxTagValueLength - len("...")
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + "..."
+}
@@ -0,0 +1,94 @@
+package arc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+func TestProgressTags(t *testing.T) {
+	build := buildinfo.Info{Version: "v1.4.0", GitCommit: "abc123"}
+	tags := progressTags(status.Progress{
+		Phase:       status.ProgressFailed,
+		CurrentStep: "ContainerdInstaller",
+		StepIndex:   7,
+		TotalSteps:  24,
+		LastError:   strings.Repeat("x", 300),
+	}, build)
+
+	if tags[bootstrapPhaseTag] != "failed" || tags[bootstrapStepTag] != "7/24 ContainerdInstaller" || tags[agentVersionTag] != "v1.4.0" {
+		t.Errorf("progressTags() = %v", tags)
+	}
+	if got := tags[bootstrapErrorTag]; len(got) != maxTagValueLength || !strings.HasSuffix(got, "...") {
+		t.Errorf("error tag has %d characters, want it truncated to %d", len(got), maxTagValueLength)
+	}
+}
+
+func TestMergeProgressTags(t *testing.T) {
+	existing := map[string]*string{
+		"environment":     to.StringPtr("edge"),
+		bootstrapErrorTag: to.StringPtr("failed earlier"),
+		bootstrapStepTag:  to.StringPtr("3/24 Arc_Installer"),
+	}
+	merged := mergeProgressTags(existing, map[string]string{bootstrapPhaseTag: "running"})
+
+	if to.String(merged["environment"]) != "edge" {
+		t.Error("mergeProgressTags() dropped a user tag")
+	}
+	if _, ok := merged[bootstrapErrorTag]; ok {
+		t.Error("mergeProgressTags() kept the error of an earlier bootstrap")
+	}
+	if _, ok := merged[bootstrapStepTag]; ok {
+		t.Error("mergeProgressTags() kept the step of an earlier bootstrap")
+	}
+	if to.String(merged[bootstrapPhaseTag]) != "running" {
+		t.Errorf("phase tag = %q, want running", to.String(merged[bootstrapPhaseTag]))
+	}
+}
+
+func TestProgressReporterFlushesFinalProgress(t *testing.T) {
+	var mu sync.Mutex
+	var updates []map[string]string
+	registered := false
+	reporter := &ProgressReporter{logger: logrus.New()}
+	reporter.update = func(_ context.Context, tags map[string]string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !registered {
+			return errors.New("Arc machine not found")
+		}
+		updates = append(updates, tags)
+		return nil
+	}
+
+	// Progress before the Arc machine exists is not sent
+	reporter.Report(status.Progress{Phase: status.ProgressRunning, CurrentStep: "Arc_Installer", StepIndex: 9, TotalSteps: 24})
+	reporter.Flush(time.Second)
+
+	mu.Lock()
+	registered = true
+	mu.Unlock()
+	reporter.Report(status.Progress{Phase: status.ProgressSucceeded, CurrentStep: "KubeletServingCertChecker", StepIndex: 24, TotalSteps: 24})
+	reporter.Flush(time.Second)
+	// Unchanged progress is not sent again
+	reporter.Report(status.Progress{Phase: status.ProgressSucceeded, CurrentStep: "KubeletServingCertChecker", StepIndex: 24, TotalSteps: 24})
+	reporter.Flush(time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(updates) != 1 {
+		t.Fatalf("sent %d updates, want 1", len(updates))
+	}
+	if updates[0][bootstrapPhaseTag] != "succeeded" {
+		t.Errorf("sent phase %q, want succeeded", updates[0][bootstrapPhaseTag])
+	}
+}
@@ -38,6 +38,20 @@ func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 	return false
 }
 
+// Plan describes the Arc cleanup for dry runs
+func (u *UnInstaller) Plan(ctx context.Context) []string {
+	if !u.config.IsARCEnabled() {
+		return nil
+	}
+	actions := make([]string, 0, len(u.getRoleAssignments())+2)
+	for _, role := range u.getRoleAssignments() {
+		actions = append(actions, fmt.Sprintf("remove role %s on %s from the Arc managed identity", role.roleName, role.scope))
+	}
+	return append(actions,
+		fmt.Sprintf("delete Arc machine %s from Azure", u.config.GetArcMachineName()),
+		"disconnect the Arc agent, keeping it installed")
+}
+
 // Execute performs Arc cleanup as part of the unbootstrap process
 // This method is designed to be called from unbootstrap steps and handles all Arc-related cleanup
 // It's resilient to failures and continues cleanup even if some operations fail
@@ -168,36 +182,46 @@ func (u *UnInstaller) removeRoleAssignment(ctx context.Context, principalID, rol
 	fullRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s",
 		u.config.Azure.SubscriptionID, roleDefinitionID)
 
-	// List role assignment for the scope
-	pager := u.roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
-		Filter: nil, // We'll filter programmatically
-	})
-
+	// List role assignment for the scope, listing again from the start when a page fails transiently
 	var assignmentsToDelete []string
-	for pager.More() {
-		page, err := pager.NextPage(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
-		}
+	err := u.retryAzure(ctx, "listing role assignments", func(ctx context.Context) error {
+		assignmentsToDelete = nil
+		pager := u.roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
+			Filter: nil, // We'll filter programmatically
+		})
+
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list role assignments for scope %s: %w", scope, err)
+			}
 
-		// Find matching role assignments
-		for _, assignment := range page.Value {
-			if assignment.Properties != nil &&
-				assignment.Properties.PrincipalID != nil &&
-				assignment.Properties.RoleDefinitionID != nil &&
-				*assignment.Properties.PrincipalID == principalID &&
-				*assignment.Properties.RoleDefinitionID == fullRoleDefinitionID {
-				if assignment.Name != nil {
-					assignmentsToDelete = append(assignmentsToDelete, *assignment.Name)
+			// Find matching role assignments
+			for _, assignment := range page.Value {
+				if assignment.Properties != nil &&
+					assignment.Properties.PrincipalID != nil &&
+					assignment.Properties.RoleDefinitionID != nil &&
+					*assignment.Properties.PrincipalID == principalID &&
+					*assignment.Properties.RoleDefinitionID == fullRoleDefinitionID {
+					if assignment.Name != nil {
+						assignmentsToDelete = append(assignmentsToDelete, *assignment.Name)
+					}
 				}
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Delete found assignments
 	for _, assignmentName := range assignmentsToDelete {
 		u.logger.Debugf("Deleting role assignment: %s", assignmentName)
-		_, err := u.roleAssignmentsClient.Delete(ctx, scope, assignmentName, nil)
+		err := u.retryAzure(ctx, "deleting role assignment "+assignmentName, func(ctx context.Context) error {
+			_, err := u.roleAssignmentsClient.Delete(ctx, scope, assignmentName, nil)
+			return err
+		})
 		if err != nil {
 			if strings.Contains(err.Error(), "RoleAssignmentNotFound") || strings.Contains(err.Error(), "NotFound") {
 				u.logger.Debugf("Role assignment %s not found (already deleted)", assignmentName)
@@ -1,5 +1,11 @@
 package arc
 
+import (
+	"path/filepath"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
 var (
 	// Map role names to role definition IDs
 	roleDefinitionIDs = map[string]string{
@@ -12,3 +18,21 @@ var (
 
 	arcServices = []string{"himdsd", "gcarcservice", "extd"}
 )
+
+// roleAssignmentDescriptionPrefix marks role assignments created by the agent, followed by the Arc machine name and
+// its subscription. It lets orphaned assignments of deleted Arc machines be told apart from assignments created by
+// users, and from assignments of machines in subscriptions the orphan cleaner did not enumerate.
+const (
+	roleAssignmentDescriptionPrefix       = "Managed by aks-flex-node for Arc machine "
+	roleAssignmentDescriptionSubscription = " in subscription "
+)
+
+// Arc machine tags carrying the agent build, so fleet-wide resource queries show which agent each node runs
+const (
+	agentVersionTag = "aks-flex-node-version"
+	agentCommitTag  = "aks-flex-node-commit"
+)
+
+// buildTagStatePath records the agent build last written to the Arc machine tags,
+// so the tags are refreshed once after the agent is upgraded
+var buildTagStatePath = filepath.Join(config.AgentStateDir, "arc-build-tags")
@@ -1,9 +1,13 @@
 package arc
 
 import (
+	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/hybridcompute/armhybridcompute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -38,3 +42,39 @@ func getArcMachineIdentityID(arcMachine *armhybridcompute.Machine) string {
 	}
 	return ""
 }
+
+// buildTags returns the Arc machine tags describing the agent build
+func buildTags(build buildinfo.Info) map[string]string {
+	return map[string]string{
+		agentVersionTag: build.Version,
+		agentCommitTag:  build.GitCommit,
+	}
+}
+
+// mergeBuildTags returns the machine tags with the agent build tags applied and whether any tag changed.
+// Tags set by users are preserved.
+func mergeBuildTags(existing map[string]*string, build buildinfo.Info) (map[string]*string, bool) {
+	merged := make(map[string]*string, len(existing)+2)
+	for key, value := range existing {
+		merged[key] = value
+	}
+	changed := false
+	for key, value := range buildTags(build) {
+		if to.String(merged[key]) != value {
+			merged[key] = to.StringPtr(value)
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
+// buildTagState identifies a build in the build tag state file
+func buildTagState(build buildinfo.Info) string {
+	return build.Version + " " + build.GitCommit
+}
+
+// buildTagsCurrent reports whether the Arc machine tags were last written by this agent build
+func buildTagsCurrent() bool {
+	data, err := os.ReadFile(buildTagStatePath)
+	return err == nil && strings.TrimSpace(string(data)) == buildTagState(buildinfo.Get())
+}
@@ -0,0 +1,119 @@
+package ca_trust
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// LoadBundles reads the CA certificates of the PEM bundles, dropping duplicates. Every bundle must hold at
+// least one certificate, so a misplaced key or an empty file is reported rather than trusted silently.
+func LoadBundles(paths []string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle %s: %w", path, err)
+		}
+		bundle, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA bundle %s: %w", path, err)
+		}
+		for _, cert := range bundle {
+			if !slices.ContainsFunc(certs, cert.Equal) {
+				certs = append(certs, cert)
+			}
+		}
+	}
+	return certs, nil
+}
+
+// InjectKubeconfig appends the CA certificates of the bundles to the certificate-authority-data of a kubeconfig,
+// so clients trust a proxy intercepting API server traffic as well as the cluster CA. A kubeconfig without CA
+// data is returned unchanged.
+func InjectKubeconfig(kubeconfig []byte, paths []string) ([]byte, error) {
+	_, caCertData, err := utils.ExtractClusterInfo(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract cluster info from kubeconfig: %w", err)
+	}
+	if caCertData == "" {
+		return kubeconfig, nil
+	}
+	injected, err := injectCAData(caCertData, paths)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Replace(kubeconfig, []byte(caCertData), []byte(injected), 1), nil
+}
+
+// injectCAData appends the bundle certificates missing from the base64 encoded CA data
+func injectCAData(caCertData string, paths []string) (string, error) {
+	caData, err := base64.StdEncoding.DecodeString(caCertData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode kubeconfig CA data: %w", err)
+	}
+	existing, err := parseCertificates(caData)
+	if err != nil {
+		return "", fmt.Errorf("invalid kubeconfig CA data: %w", err)
+	}
+	certs, err := LoadBundles(paths)
+	if err != nil {
+		return "", err
+	}
+
+	caData = bytes.TrimRight(caData, "\n")
+	caData = append(caData, '\n')
+	for _, cert := range certs {
+		if !slices.ContainsFunc(existing, cert.Equal) {
+			caData = append(caData, encodePEM(cert)...)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(caData), nil
+}
+
+// parseCertificates parses the certificates of a PEM bundle, skipping blocks of other types
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+// anchorFiles returns the trust store file name and PEM content of each certificate. Names derive from the
+// certificate fingerprint so re-runs write the same files.
+func anchorFiles(certs []*x509.Certificate) map[string][]byte {
+	files := make(map[string][]byte, len(certs))
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.Raw)
+		files[anchorPrefix+hex.EncodeToString(sum[:8])+".crt"] = encodePEM(cert)
+	}
+	return files
+}
+
+func encodePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
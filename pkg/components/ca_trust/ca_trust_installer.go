@@ -0,0 +1,185 @@
+package ca_trust
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Installer adds the CA certificates of trustedCA.bundles to the system trust store, so downloads, Arc and
+// containerd image pulls succeed behind TLS-intercepting proxies and from registries with internal CAs
+type Installer struct {
+	config      *config.Config
+	logger      *logrus.Logger
+	detectStore func() (*trustStore, error)
+}
+
+// NewInstaller creates a new CA trust Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config:      config.GetConfig(),
+		logger:      logger,
+		detectStore: detectTrustStore,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "CATrust_Installer"
+}
+
+// Validate checks the bundles hold certificates and the machine has a supported trust store
+func (i *Installer) Validate(_ context.Context) error {
+	if len(i.config.TrustedCA.Bundles) == 0 {
+		return nil
+	}
+	if _, err := LoadBundles(i.config.TrustedCA.Bundles); err != nil {
+		return err
+	}
+	_, err := i.detectStore()
+	return err
+}
+
+// IsCompleted returns true when the trust store holds exactly the certificates of the configured bundles,
+// which also removes certificates of bundles dropped from the configuration
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	store, err := i.detectStore()
+	if err != nil {
+		return len(i.config.TrustedCA.Bundles) == 0
+	}
+	want, err := i.wantedAnchors()
+	if err != nil {
+		return false
+	}
+	installed := installedAnchors(store.dir)
+	if !slices.Equal(slices.Sorted(maps.Keys(want)), installed) {
+		return false
+	}
+	for name, data := range want {
+		if current, err := os.ReadFile(filepath.Join(store.dir, name)); err != nil || string(current) != string(data) {
+			return false
+		}
+	}
+	return true
+}
+
+// Plan describes the trust store changes for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	store, err := i.detectStore()
+	if err != nil {
+		return nil
+	}
+	want, err := i.wantedAnchors()
+	if err != nil {
+		return nil
+	}
+	var actions []string
+	if len(want) > 0 {
+		actions = append(actions, fmt.Sprintf("add %d CA certificate(s) from %s to %s",
+			len(want), strings.Join(i.config.TrustedCA.Bundles, ", "), store.dir))
+	}
+	for _, name := range installedAnchors(store.dir) {
+		if _, ok := want[name]; !ok {
+			actions = append(actions, "remove "+filepath.Join(store.dir, name))
+		}
+	}
+	actions = append(actions, "run "+strings.Join(store.update, " "))
+	if sysutil.IsServiceActive("containerd") {
+		actions = append(actions, "restart containerd to load the updated trust store")
+	}
+	return actions
+}
+
+// Execute writes a trust store file per certificate, removes the ones of dropped bundles, rebuilds the system
+// bundle and restarts containerd, which reads the system bundle only at startup
+func (i *Installer) Execute(_ context.Context) error {
+	store, err := i.detectStore()
+	if err != nil {
+		return err
+	}
+	want, err := i.wantedAnchors()
+	if err != nil {
+		return err
+	}
+	i.logger.Infof("Adding %d trusted CA certificate(s) to %s", len(want), store.dir)
+
+	if err := sysutil.RunSystemCommand("mkdir", "-p", store.dir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", store.dir, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(want)) {
+		if err := fsutil.WriteFileAtomicSystem(filepath.Join(store.dir, name), want[name], 0o644); err != nil {
+			return fmt.Errorf("failed to write trusted CA certificate %s: %w", name, err)
+		}
+	}
+	var stale []string
+	for _, name := range installedAnchors(store.dir) {
+		if _, ok := want[name]; !ok {
+			stale = append(stale, filepath.Join(store.dir, name))
+		}
+	}
+	if fileErrors := fsutil.RemoveFiles(stale, i.logger); len(fileErrors) > 0 {
+		return fmt.Errorf("failed to remove CA certificates no longer configured: %v", fileErrors)
+	}
+
+	if err := sysutil.RunSystemCommand(store.update[0], store.update[1:]...); err != nil {
+		return fmt.Errorf("failed to update the system trust store with %s: %w", strings.Join(store.update, " "), err)
+	}
+	if sysutil.IsServiceActive("containerd") {
+		i.logger.Info("Restarting containerd to load the updated trust store")
+		if err := sysutil.RestartService("containerd"); err != nil {
+			return fmt.Errorf("failed to restart containerd: %w", err)
+		}
+	}
+
+	i.logger.Info("Trusted CA certificates installed")
+	return nil
+}
+
+// wantedAnchors returns the trust store files of the configured bundles
+func (i *Installer) wantedAnchors() (map[string][]byte, error) {
+	if len(i.config.TrustedCA.Bundles) == 0 {
+		return map[string][]byte{}, nil
+	}
+	certs, err := LoadBundles(i.config.TrustedCA.Bundles)
+	if err != nil {
+		return nil, err
+	}
+	return anchorFiles(certs), nil
+}
+
+// installedAnchors returns the sorted names of the trust store files the agent added to dir
+func installedAnchors(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, anchorPrefix+"*.crt"))
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// detectTrustStore returns the trust store of the distribution, found by its update command and directory.
+// When no store directory exists yet, the first store with an update command is used.
+func detectTrustStore() (*trustStore, error) {
+	for _, store := range trustStores {
+		if sysutil.BinaryExists(store.update[0]) && fsutil.DirectoryExists(store.dir) {
+			return &store, nil
+		}
+	}
+	for _, store := range trustStores {
+		if sysutil.BinaryExists(store.update[0]) {
+			return &store, nil
+		}
+	}
+	return nil, fmt.Errorf("no supported trust store found (update-ca-certificates or update-ca-trust), install the ca-certificates package")
+}
@@ -0,0 +1,80 @@
+package ca_trust
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller removes the CA certificates the agent added to the system trust store. It runs after Arc is
+// disconnected, which may still need them to reach Azure through an intercepting proxy.
+type UnInstaller struct {
+	logger      *logrus.Logger
+	detectStore func() (*trustStore, error)
+}
+
+// NewUnInstaller creates a new CA trust UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		logger:      logger,
+		detectStore: detectTrustStore,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "CATrust_UnInstaller"
+}
+
+// IsCompleted returns true when the trust store holds no certificates the agent added
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	store, err := u.detectStore()
+	return err != nil || len(installedAnchors(store.dir)) == 0
+}
+
+// Plan describes the certificates Execute would remove, for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	store, err := u.detectStore()
+	if err != nil {
+		return nil
+	}
+	anchors := installedAnchors(store.dir)
+	if len(anchors) == 0 {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("remove %d trusted CA certificate(s) from %s", len(anchors), store.dir),
+		"run " + strings.Join(store.update, " "),
+	}
+}
+
+// Execute removes the certificates and rebuilds the system bundle
+func (u *UnInstaller) Execute(_ context.Context) error {
+	store, err := u.detectStore()
+	if err != nil {
+		return nil
+	}
+	anchors := installedAnchors(store.dir)
+	if len(anchors) == 0 {
+		return nil
+	}
+	u.logger.Infof("Removing %d trusted CA certificate(s) from %s", len(anchors), store.dir)
+
+	paths := make([]string, 0, len(anchors))
+	for _, name := range anchors {
+		paths = append(paths, filepath.Join(store.dir, name))
+	}
+	if fileErrors := fsutil.RemoveFiles(paths, u.logger); len(fileErrors) > 0 {
+		return fmt.Errorf("failed to remove trusted CA certificates: %v", fileErrors)
+	}
+	if err := sysutil.RunSystemCommand(store.update[0], store.update[1:]...); err != nil {
+		return fmt.Errorf("failed to update the system trust store with %s: %w", strings.Join(store.update, " "), err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package ca_trust
+
+const (
+	// anchorPrefix names the certificate files the agent adds to the trust store, so they can be told apart
+	// from the certificates the distribution and the operator installed
+	anchorPrefix = "aks-flex-node-"
+)
+
+// trustStore is the directory of a distribution's trusted CA certificates and the command rebuilding the
+// system bundle from it
+type trustStore struct {
+	dir    string
+	update []string
+}
+
+// trustStores lists the supported trust stores: SUSE, which shares the update command with Debian and Ubuntu
+// but not the directory, then Debian and Ubuntu, then Azure Linux and other rpm based distributions
+var trustStores = []trustStore{
+	{dir: "/etc/pki/trust/anchors", update: []string{"update-ca-certificates"}},
+	{dir: "/usr/local/share/ca-certificates", update: []string{"update-ca-certificates"}},
+	{dir: "/etc/pki/ca-trust/source/anchors", update: []string{"update-ca-trust", "extract"}},
+}
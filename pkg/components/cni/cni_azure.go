@@ -0,0 +1,71 @@
+package cni
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// azureCNIConfig is the Azure CNI Overlay configuration of AKS nodes: azure-vnet plumbs the pod interfaces and
+// asks Azure CNS on the node for pod IPs from the cluster's overlay range
+var azureCNIConfig = fmt.Sprintf(`{
+    "cniVersion": "%s",
+    "name": "azure",
+    "plugins": [
+        {
+            "type": "azure-vnet",
+            "mode": "transparent",
+            "ipsToRouteViaHost": ["169.254.20.10"],
+            "executionMode": "v4swift",
+            "ipam": {
+                "mode": "v4overlay",
+                "type": "azure-cns"
+            },
+            "dns": {},
+            "runtimeConfig": {
+                "dns": {}
+            }
+        },
+        {
+            "type": "portmap",
+            "capabilities": {
+                "portMappings": true
+            },
+            "snat": true
+        }
+    ]
+}`, defaultCNISpecVersion)
+
+// installAzureCNIPlugins downloads the Azure CNI release into the CNI bin directory
+func (i *Installer) installAzureCNIPlugins(ctx context.Context) error {
+	if fsutil.FileExistsAndValid(filepath.Join(DefaultCNIBinDir, azureVNetPlugin)) {
+		i.logger.Info("Azure CNI plugins are already installed, skipping installation")
+		return nil
+	}
+
+	version := defaults.Get().Versions.AzureCNI
+	arch, err := sysutil.GetArc()
+	if err != nil {
+		return fmt.Errorf("failed to get architecture: %w", err)
+	}
+	url := fmt.Sprintf(defaults.Get().URLs.AzureCNI, version, arch, version)
+	fileName := fmt.Sprintf(azureCNIFileName, arch, version)
+	if err := i.installArchive(ctx, url, fileName, DefaultCNIBinDir, azureCNIProvenanceComponent, version); err != nil {
+		return fmt.Errorf("failed to install Azure CNI %s: %w", version, err)
+	}
+	return nil
+}
+
+// createAzureCNIConfig writes the Azure CNI Overlay configuration
+func (i *Installer) createAzureCNIConfig() error {
+	configPath := filepath.Join(DefaultCNIConfDir, azureCNIConfigFile)
+	if err := fsutil.WriteFileAtomicSystem(configPath, []byte(azureCNIConfig), 0o644); err != nil {
+		return fmt.Errorf("failed to write Azure CNI config %s: %w", configPath, err)
+	}
+	i.logger.Info("Azure CNI configuration created")
+	return nil
+}
@@ -0,0 +1,112 @@
+package cni
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// installCilium installs the cilium CLI and, unless the cluster already runs Cilium, installs Cilium into the
+// cluster with it. The Cilium agent then writes the node CNI configuration once it runs on the node.
+func (i *Installer) installCilium(ctx context.Context) error {
+	if err := i.installCiliumCLI(ctx); err != nil {
+		return err
+	}
+
+	adminKubeconfig, err := kubelet.WriteAdminKubeconfig(ctx, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials to install Cilium: %w", err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	if _, err := sysutil.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig,
+		"get", "daemonset", "cilium", "-n", "kube-system"); err == nil {
+		i.logger.Info("Cilium is already installed in the cluster, skipping installation")
+		return nil
+	}
+
+	apiServer := ""
+	if i.config.CNI.KubeProxyReplacement {
+		data, err := os.ReadFile(adminKubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to read cluster credentials: %w", err)
+		}
+		if apiServer, err = utils.ExtractServerURL(data); err != nil {
+			return err
+		}
+	}
+	args, err := ciliumInstallArgs(i.config, apiServer)
+	if err != nil {
+		return err
+	}
+
+	i.logger.Infof("Installing Cilium %s into the cluster", getCiliumVersion(i.config))
+	if err := sysutil.RunSystemCommandContext(ctx, "env", append([]string{"KUBECONFIG=" + adminKubeconfig, ciliumCLIPath, "install"}, args...)...); err != nil {
+		return fmt.Errorf("failed to install Cilium: %w", err)
+	}
+	i.logger.Info("Cilium installed, its agent writes the node CNI configuration once it runs on this node")
+	return nil
+}
+
+// installCiliumCLI downloads the cilium CLI release
+func (i *Installer) installCiliumCLI(ctx context.Context) error {
+	if fsutil.FileExistsAndValid(ciliumCLIPath) {
+		return nil
+	}
+
+	version := defaults.Get().Versions.CiliumCLI
+	arch, err := sysutil.GetArc()
+	if err != nil {
+		return fmt.Errorf("failed to get architecture: %w", err)
+	}
+	url := fmt.Sprintf(defaults.Get().URLs.CiliumCLI, version, arch)
+	fileName := fmt.Sprintf(ciliumCLIFileName, arch)
+	if err := i.installArchive(ctx, url, fileName, "/usr/local/bin", ciliumProvenanceComponent, version); err != nil {
+		return fmt.Errorf("failed to install cilium CLI %s: %w", version, err)
+	}
+	return nil
+}
+
+// ciliumInstallArgs returns the cilium install arguments for a BYO CNI AKS cluster, assigning pod IPs from
+// node.podCIDR. With kube-proxy replacement Cilium reaches the API server directly, as there is no kube-proxy
+// to route to the kubernetes service.
+func ciliumInstallArgs(cfg *config.Config, apiServer string) ([]string, error) {
+	args := []string{
+		"--version", getCiliumVersion(cfg),
+		"--set", "aksbyocni.enabled=true",
+		"--set", "ipam.mode=cluster-pool",
+		"--set", "ipam.operator.clusterPoolIPv4PodCIDRList=" + cfg.GetPodCIDR(),
+	}
+	if !cfg.CNI.KubeProxyReplacement {
+		return args, nil
+	}
+
+	serverURL, err := url.Parse(apiServer)
+	if err != nil || serverURL.Hostname() == "" {
+		return nil, fmt.Errorf("invalid API server URL %q in cluster credentials", apiServer)
+	}
+	port := serverURL.Port()
+	if port == "" {
+		port = "443"
+	}
+	return append(args,
+		"--set", "kubeProxyReplacement=true",
+		"--set", "k8sServiceHost="+serverURL.Hostname(),
+		"--set", "k8sServicePort="+port,
+	), nil
+}
+
+func getCiliumVersion(cfg *config.Config) string {
+	if cfg.CNI.CiliumVersion != "" {
+		return cfg.CNI.CiliumVersion
+	}
+	return defaults.Get().Versions.Cilium
+}
@@ -0,0 +1,122 @@
+package cni
+
+import (
+	"fmt"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+)
+
+// networkStrategy describes how the node CNI is set up for the cluster's network profile
+type networkStrategy struct {
+	Name    string // cni.mode the node CNI is set up with
+	Warning string // caveat to surface to the operator, if any
+}
+
+// selectNetworkStrategy checks the cni.mode against the cluster network profile and refuses
+// combinations where the node CNI would leave pod networking broken
+func selectNetworkStrategy(clusterSpec *spec.ManagedClusterSpec, mode string) (*networkStrategy, error) {
+	switch mode {
+	case config.CNIModeCilium:
+		return selectCiliumStrategy(clusterSpec)
+	case config.CNIModeAzureCNI:
+		return selectAzureCNIStrategy(clusterSpec)
+	case config.CNIModeNone:
+		return selectBYOStrategy(clusterSpec), nil
+	default:
+		return selectBridgeStrategy(clusterSpec)
+	}
+}
+
+// selectBridgeStrategy accepts the network profiles the agent's bridge configuration can serve
+func selectBridgeStrategy(clusterSpec *spec.ManagedClusterSpec) (*networkStrategy, error) {
+	switch clusterSpec.NetworkPlugin {
+	case "", "none":
+		return &networkStrategy{Name: config.CNIModeBridge}, nil
+	case "kubenet":
+		return &networkStrategy{
+			Name:    config.CNIModeBridge,
+			Warning: "kubenet routes are not programmed for flex nodes, pods on this node are only reachable from other nodes with manual routing",
+		}, nil
+	case "azure":
+		if clusterSpec.NetworkDataplane == "cilium" {
+			return nil, fmt.Errorf("cluster uses Azure CNI powered by Cilium, whose managed dataplane does not run on flex nodes")
+		}
+		if clusterSpec.NetworkPluginMode == "overlay" {
+			return nil, fmt.Errorf("cluster uses Azure CNI Overlay, which requires Azure CNS on every node to allocate pod IPs")
+		}
+		return nil, fmt.Errorf("cluster uses Azure CNI, which assigns pod IPs from the cluster VNet that flex nodes cannot allocate from")
+	default:
+		return nil, fmt.Errorf("cluster uses unknown network plugin %q", clusterSpec.NetworkPlugin)
+	}
+}
+
+// selectCiliumStrategy accepts BYO CNI clusters only, as Cilium is installed cluster-wide and would conflict with a managed CNI
+func selectCiliumStrategy(clusterSpec *spec.ManagedClusterSpec) (*networkStrategy, error) {
+	switch clusterSpec.NetworkPlugin {
+	case "", "none":
+		return &networkStrategy{Name: config.CNIModeCilium}, nil
+	default:
+		return nil, fmt.Errorf("cni.mode cilium requires a cluster with network plugin 'none' (BYO CNI), but the cluster uses network plugin %q", clusterSpec.NetworkPlugin)
+	}
+}
+
+// selectAzureCNIStrategy accepts Azure CNI Overlay clusters, whose pod IPs come from the overlay range rather than the VNet
+func selectAzureCNIStrategy(clusterSpec *spec.ManagedClusterSpec) (*networkStrategy, error) {
+	if clusterSpec.NetworkPlugin != "azure" {
+		return nil, fmt.Errorf("cni.mode azure-cni requires a cluster with Azure CNI Overlay, but the cluster uses network plugin %q", clusterSpec.NetworkPlugin)
+	}
+	if clusterSpec.NetworkDataplane == "cilium" {
+		return nil, fmt.Errorf("cluster uses Azure CNI powered by Cilium, whose managed dataplane does not run on flex nodes")
+	}
+	if clusterSpec.NetworkPluginMode != "overlay" {
+		return nil, fmt.Errorf("cluster uses Azure CNI, which assigns pod IPs from the cluster VNet that flex nodes cannot allocate from")
+	}
+	return &networkStrategy{
+		Name:    config.CNIModeAzureCNI,
+		Warning: "pod IPs are allocated by Azure CNS, which must run on this node before pods can start",
+	}, nil
+}
+
+// selectBYOStrategy accepts any cluster, as the operator brings a CNI configuration matching it
+func selectBYOStrategy(clusterSpec *spec.ManagedClusterSpec) *networkStrategy {
+	strategy := &networkStrategy{Name: config.CNIModeNone}
+	if clusterSpec.NetworkPlugin != "" && clusterSpec.NetworkPlugin != "none" {
+		strategy.Warning = fmt.Sprintf("the CNI configuration brought to this node must match network plugin %q of the cluster", clusterSpec.NetworkPlugin)
+	}
+	return strategy
+}
+
+// kubenetMaxPods is the most pods AKS allows on a kubenet node, whose pod range is a /24 allocated from the cluster pod CIDR
+const kubenetMaxPods = 250
+
+// checkKubenetMaxPods refuses a node.maxPods that does not fit the /24 pod range kubenet allocates to every node,
+// so the node does not advertise more pods than the cluster can route to it
+func checkKubenetMaxPods(clusterSpec *spec.ManagedClusterSpec, maxPods int) error {
+	if clusterSpec.NetworkPlugin != "kubenet" || maxPods <= kubenetMaxPods {
+		return nil
+	}
+	return fmt.Errorf("node.maxPods %d exceeds the %d pods a kubenet node supports, as kubenet allocates each node a /24 of cluster pod CIDR %s. "+
+		"Set node.maxPods to at most %d", maxPods, kubenetMaxPods, clusterSpec.PodCIDR, kubenetMaxPods)
+}
+
+// checkKubeProxyReplacement refuses kube-proxy replacement on clusters whose network plugin is not BYO CNI,
+// as only a BYO Cilium installed by the operator can take over service load balancing from kube-proxy
+func checkKubeProxyReplacement(clusterSpec *spec.ManagedClusterSpec) error {
+	switch clusterSpec.NetworkPlugin {
+	case "", "none":
+		return nil
+	default:
+		return fmt.Errorf("cni.kubeProxyReplacement requires a cluster with network plugin 'none' and BYO Cilium, but the cluster uses network plugin %q", clusterSpec.NetworkPlugin)
+	}
+}
+
+// checkClusterDNS returns a warning when the cluster DNS IP kubelet hands to pods is not the one of the cluster,
+// which happens on clusters with a custom service CIDR that node.serviceCIDR does not match
+func checkClusterDNS(clusterSpec *spec.ManagedClusterSpec, dnsServiceIP string) string {
+	if clusterSpec.DNSServiceIP == "" || clusterSpec.DNSServiceIP == dnsServiceIP {
+		return ""
+	}
+	return fmt.Sprintf("node.kubelet.dnsServiceIP %s differs from DNS service IP %s of the cluster (service CIDR %s), so DNS lookups of pods on this node will fail. "+
+		"Set node.serviceCIDR to %s", dnsServiceIP, clusterSpec.DNSServiceIP, clusterSpec.ServiceCIDR, clusterSpec.ServiceCIDR)
+}
@@ -0,0 +1,196 @@
+package cni
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+)
+
+func TestSelectNetworkStrategy(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterSpec *spec.ManagedClusterSpec
+		mode        string
+		wantWarning bool
+		wantErr     string
+	}{
+		{
+			name:        "BYO CNI uses bridge",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "none"},
+		},
+		{
+			name:        "kubenet uses bridge with warning",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "kubenet"},
+			wantWarning: true,
+		},
+		{
+			name:        "Azure CNI is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "azure"},
+			wantErr:     "Azure CNI",
+		},
+		{
+			name:        "Azure CNI Overlay is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "azure", NetworkPluginMode: "overlay"},
+			wantErr:     "Azure CNI Overlay",
+		},
+		{
+			name:        "Azure CNI powered by Cilium is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "azure", NetworkPluginMode: "overlay", NetworkDataplane: "cilium"},
+			wantErr:     "Cilium",
+		},
+		{
+			name:        "unknown plugin is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "calico"},
+			wantErr:     "unknown network plugin",
+		},
+		{
+			name:        "Cilium on BYO CNI",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "none"},
+			mode:        config.CNIModeCilium,
+		},
+		{
+			name:        "Cilium on kubenet is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "kubenet"},
+			mode:        config.CNIModeCilium,
+			wantErr:     "network plugin 'none'",
+		},
+		{
+			name:        "Azure CNI mode on Azure CNI Overlay with warning",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "azure", NetworkPluginMode: "overlay"},
+			mode:        config.CNIModeAzureCNI,
+			wantWarning: true,
+		},
+		{
+			name:        "Azure CNI mode on VNet Azure CNI is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "azure"},
+			mode:        config.CNIModeAzureCNI,
+			wantErr:     "cluster VNet",
+		},
+		{
+			name:        "Azure CNI mode on BYO CNI is refused",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "none"},
+			mode:        config.CNIModeAzureCNI,
+			wantErr:     "requires a cluster with Azure CNI Overlay",
+		},
+		{
+			name:        "BYO CNI mode accepts any plugin with warning",
+			clusterSpec: &spec.ManagedClusterSpec{NetworkPlugin: "calico"},
+			mode:        config.CNIModeNone,
+			wantWarning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := tt.mode
+			if mode == "" {
+				mode = config.CNIModeBridge
+			}
+			strategy, err := selectNetworkStrategy(tt.clusterSpec, mode)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("selectNetworkStrategy() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectNetworkStrategy() unexpected error = %v", err)
+			}
+			if strategy.Name != mode {
+				t.Errorf("selectNetworkStrategy() strategy = %s, want %s", strategy.Name, mode)
+			}
+			if (strategy.Warning != "") != tt.wantWarning {
+				t.Errorf("selectNetworkStrategy() warning = %q, wantWarning %v", strategy.Warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestCheckKubeProxyReplacement(t *testing.T) {
+	for _, plugin := range []string{"", "none"} {
+		if err := checkKubeProxyReplacement(&spec.ManagedClusterSpec{NetworkPlugin: plugin}); err != nil {
+			t.Errorf("checkKubeProxyReplacement(%q) unexpected error = %v", plugin, err)
+		}
+	}
+	for _, plugin := range []string{"kubenet", "azure"} {
+		if err := checkKubeProxyReplacement(&spec.ManagedClusterSpec{NetworkPlugin: plugin}); err == nil {
+			t.Errorf("checkKubeProxyReplacement(%q) error = nil, want kube-proxy replacement refused", plugin)
+		}
+	}
+}
+
+func TestCheckKubenetMaxPods(t *testing.T) {
+	kubenet := &spec.ManagedClusterSpec{NetworkPlugin: "kubenet", PodCIDR: "10.244.0.0/16"}
+	if err := checkKubenetMaxPods(kubenet, 250); err != nil {
+		t.Errorf("checkKubenetMaxPods(250) unexpected error = %v", err)
+	}
+	if err := checkKubenetMaxPods(kubenet, 251); err == nil || !strings.Contains(err.Error(), "at most 250") {
+		t.Errorf("checkKubenetMaxPods(251) error = %v, want maxPods refused", err)
+	}
+	if err := checkKubenetMaxPods(&spec.ManagedClusterSpec{NetworkPlugin: "none"}, 500); err != nil {
+		t.Errorf("checkKubenetMaxPods() with BYO CNI unexpected error = %v", err)
+	}
+}
+
+func TestCheckClusterDNS(t *testing.T) {
+	clusterSpec := &spec.ManagedClusterSpec{ServiceCIDR: "172.20.0.0/16", DNSServiceIP: "172.20.0.10"}
+	if warning := checkClusterDNS(clusterSpec, "172.20.0.10"); warning != "" {
+		t.Errorf("checkClusterDNS() with matching DNS IP = %q, want no warning", warning)
+	}
+	if warning := checkClusterDNS(clusterSpec, "10.0.0.10"); !strings.Contains(warning, "Set node.serviceCIDR to 172.20.0.0/16") {
+		t.Errorf("checkClusterDNS() with default DNS IP = %q, want node.serviceCIDR hint", warning)
+	}
+	if warning := checkClusterDNS(&spec.ManagedClusterSpec{}, "10.0.0.10"); warning != "" {
+		t.Errorf("checkClusterDNS() without cluster DNS IP = %q, want no warning", warning)
+	}
+}
+
+func TestBridgeSubnet(t *testing.T) {
+	subnet, gateway, err := bridgeSubnet("172.16.8.9/22", "")
+	if err != nil || subnet != "172.16.8.0/22" || gateway != "172.16.8.1" {
+		t.Errorf("bridgeSubnet() = %s, %s, %v, want 172.16.8.0/22 with gateway 172.16.8.1", subnet, gateway, err)
+	}
+	if _, gateway, err := bridgeSubnet("172.16.8.0/22", "172.16.11.254"); err != nil || gateway != "172.16.11.254" {
+		t.Errorf("bridgeSubnet() with a configured gateway = %s, %v, want 172.16.11.254", gateway, err)
+	}
+	if _, _, err := bridgeSubnet("172.16.8.0/22", "172.16.12.1"); err == nil {
+		t.Error("bridgeSubnet() with a gateway outside the pod CIDR error = nil")
+	}
+	if _, _, err := bridgeSubnet("fd00::/64", ""); err == nil {
+		t.Error("bridgeSubnet() with an IPv6 CIDR error = nil")
+	}
+}
+
+func TestCiliumInstallArgs(t *testing.T) {
+	cfg := &config.Config{CNI: config.CNIConfig{PodCIDR: "10.50.0.0/16", CiliumVersion: "1.16.1"}}
+	args, err := ciliumInstallArgs(cfg, "")
+	if err != nil {
+		t.Fatalf("ciliumInstallArgs() unexpected error = %v", err)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"--version 1.16.1", "aksbyocni.enabled=true", "clusterPoolIPv4PodCIDRList=10.50.0.0/16"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("ciliumInstallArgs() = %q, want %q", joined, want)
+		}
+	}
+	if strings.Contains(joined, "kubeProxyReplacement") {
+		t.Errorf("ciliumInstallArgs() = %q, want kube-proxy kept", joined)
+	}
+
+	cfg.CNI.KubeProxyReplacement = true
+	args, err = ciliumInstallArgs(cfg, "https://edge-dns-abc.hcp.eastus.azmk8s.io:443")
+	if err != nil {
+		t.Fatalf("ciliumInstallArgs() with kube-proxy replacement unexpected error = %v", err)
+	}
+	joined = strings.Join(args, " ")
+	for _, want := range []string{"kubeProxyReplacement=true", "k8sServiceHost=edge-dns-abc.hcp.eastus.azmk8s.io", "k8sServicePort=443"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("ciliumInstallArgs() = %q, want %q", joined, want)
+		}
+	}
+	if _, err := ciliumInstallArgs(cfg, ""); err == nil {
+		t.Error("ciliumInstallArgs() with kube-proxy replacement and no API server error = nil")
+	}
+}
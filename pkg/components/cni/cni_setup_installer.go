@@ -3,10 +3,18 @@ package cni
 import (
 	"context"
 	"fmt"
+	"net"
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -36,6 +44,51 @@ func (i *Installer) Validate(ctx context.Context) error {
 	if cniVersion == "" {
 		return fmt.Errorf("CNI version cannot be empty")
 	}
+
+	// Validate the node CNI strategy against the cluster network profile
+	return i.validateNetworkProfile(ctx)
+}
+
+// validateNetworkProfile refuses to set up the node CNI for clusters whose network profile the cni.mode cannot serve
+func (i *Installer) validateNetworkProfile(ctx context.Context) error {
+	mode := i.config.GetCNIMode()
+	clusterSpec, err := spec.NewCollector(i.logger).Collect(ctx)
+	if err != nil {
+		i.logger.Warnf("Unable to verify cluster network profile, proceeding with CNI mode %s: %v", mode, err)
+		return nil
+	}
+
+	if i.config.CNI.KubeProxyReplacement {
+		if err := checkKubeProxyReplacement(clusterSpec); err != nil {
+			return err
+		}
+	}
+
+	if err := checkKubenetMaxPods(clusterSpec, i.config.Node.MaxPods); err != nil {
+		return err
+	}
+	if warning := checkClusterDNS(clusterSpec, i.config.Node.Kubelet.DNSServiceIP); warning != "" {
+		i.logger.Warn(warning)
+	}
+
+	strategy, err := selectNetworkStrategy(clusterSpec, mode)
+	if err != nil {
+		if !i.config.CNI.AllowUnsupportedNetwork {
+			hint := "Use a cni.mode matching the cluster network profile"
+			if mode == config.CNIModeBridge {
+				hint = "Use a cluster with network plugin 'none' (BYO CNI) or kubenet, or another cni.mode"
+			}
+			return fmt.Errorf("%w; pod networking on this node would be broken. "+
+				"%s, or set cni.allowUnsupportedNetwork (--allow-unsupported-network) to proceed anyway", err, hint)
+		}
+		i.logger.Warnf("Proceeding with CNI mode %s despite incompatible cluster network profile: %v", mode, err)
+		return nil
+	}
+
+	if strategy.Warning != "" {
+		i.logger.Warnf("Cluster network plugin %s: %s", clusterSpec.NetworkPlugin, strategy.Warning)
+	}
+	i.logger.Infof("Using CNI mode %s for cluster network plugin %q", strategy.Name, clusterSpec.NetworkPlugin)
 	return nil
 }
 
@@ -52,24 +105,54 @@ func (i *Installer) Execute(ctx context.Context) error {
 
 	// Install CNI plugins
 	i.logger.Info("Step 2: Installing CNI plugins")
-	if err := i.installCNIPlugins(); err != nil {
+	if err := i.installCNIPlugins(ctx); err != nil {
 		i.logger.Errorf("CNI plugins installation failed: %v", err)
-		return fmt.Errorf("failed to install CNI plugins version %s: %w", defaultCNIVersion, err)
+		return fmt.Errorf("failed to install CNI plugins version %s: %w", getCNIVersion(i.config), err)
+	}
+	if i.config.GetCNIMode() == config.CNIModeAzureCNI {
+		if err := i.installAzureCNIPlugins(ctx); err != nil {
+			return err
+		}
 	}
 	i.logger.Info("CNI plugins installed successfully")
 
-	// Create bridge configuration for edge node
-	i.logger.Info("Step 3: Creating bridge configuration")
-	if err := i.createBridgeConfig(); err != nil {
-		i.logger.Errorf("Bridge configuration creation failed: %v", err)
-		return fmt.Errorf("failed to create bridge config: %w", err)
+	i.logger.Infof("Step 3: Configuring CNI mode %s", i.config.GetCNIMode())
+	if err := i.configureCNIMode(ctx); err != nil {
+		i.logger.Errorf("CNI configuration failed: %v", err)
+		return err
 	}
-	i.logger.Info("Bridge configuration created successfully")
 
 	i.logger.Info("CNI setup completed successfully")
 	return nil
 }
 
+// configureCNIMode sets up the node CNI configuration of the cni.mode. Modes other than bridge remove the
+// bridge configuration left by an earlier bridge setup, which would otherwise keep serving pods.
+func (i *Installer) configureCNIMode(ctx context.Context) error {
+	mode := i.config.GetCNIMode()
+	if mode != config.CNIModeBridge {
+		if err := utils.RunCleanupCommand(filepath.Join(DefaultCNIConfDir, bridgeConfigFile)); err != nil {
+			i.logger.Warnf("Failed to remove bridge configuration: %v", err)
+		}
+	}
+
+	switch mode {
+	case config.CNIModeCilium:
+		return i.installCilium(ctx)
+	case config.CNIModeAzureCNI:
+		return i.createAzureCNIConfig()
+	case config.CNIModeNone:
+		i.logger.Info("Skipping CNI configuration, cni.mode none expects the operator to bring the CNI")
+		return nil
+	default:
+		if err := i.createBridgeConfig(); err != nil {
+			return fmt.Errorf("failed to create bridge config: %w", err)
+		}
+		i.logger.Info("Bridge configuration created successfully")
+		return nil
+	}
+}
+
 // IsCompleted checks if CNI configuration has been set up properly
 func (i *Installer) IsCompleted(ctx context.Context) bool {
 	// Validate Step 1: CNI directories preparation
@@ -89,17 +172,80 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 		}
 	}
 
-	// Validate Step 3: Bridge configuration
+	// Validate Step 3: configuration of the CNI mode
+	if !i.isModeConfigured() {
+		return false
+	}
+
+	i.logger.Debug("CNI setup validation passed - all components properly configured")
+	return true
+}
+
+// isModeConfigured checks that the node CNI configuration matches the cni.mode
+func (i *Installer) isModeConfigured() bool {
 	configPath := filepath.Join(DefaultCNIConfDir, bridgeConfigFile)
+	mode := i.config.GetCNIMode()
+	if mode != config.CNIModeBridge {
+		if utils.FileExists(configPath) {
+			i.logger.Debugf("Bridge configuration left over with CNI mode %s", mode)
+			return false
+		}
+	}
+
+	switch mode {
+	case config.CNIModeCilium:
+		return utils.FileExistsAndValid(ciliumCLIPath)
+	case config.CNIModeAzureCNI:
+		return utils.FileExistsAndValid(filepath.Join(DefaultCNIBinDir, azureVNetPlugin)) &&
+			utils.FileExistsAndValid(filepath.Join(DefaultCNIConfDir, azureCNIConfigFile))
+	case config.CNIModeNone:
+		return true
+	}
+
 	if !utils.FileExistsAndValid(configPath) {
 		i.logger.Debug("Bridge configuration file not found")
 		return false
 	}
-
-	i.logger.Debug("CNI setup validation passed - all components properly configured")
+	// Rewrite the bridge configuration when node.podCIDR or cni.gateway changed
+	if subnet, gateway, err := bridgeSubnet(i.config.GetPodCIDR(), i.config.CNI.Gateway); err == nil {
+		data, err := os.ReadFile(configPath)
+		if err == nil && (!strings.Contains(string(data), fmt.Sprintf(`"subnet": "%s"`, subnet)) ||
+			!strings.Contains(string(data), fmt.Sprintf(`"gateway": "%s"`, gateway))) {
+			i.logger.Debugf("Bridge configuration does not use pod subnet %s with gateway %s", subnet, gateway)
+			return false
+		}
+	}
 	return true
 }
 
+// Plan describes the CNI setup for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	actions := []string{"create " + strings.Join(cniDirs, ", ")}
+	if !canSkipCNIPluginInstallation() {
+		_, url, err := i.constructCNIDownloadURL()
+		if err != nil {
+			url = "the CNI plugins release"
+		}
+		actions = append(actions, fmt.Sprintf("download CNI plugins %s from %s into %s", getCNIVersion(i.config), url, DefaultCNIBinDir))
+	}
+	switch i.config.GetCNIMode() {
+	case config.CNIModeCilium:
+		return append(actions,
+			fmt.Sprintf("download cilium CLI %s into %s", defaults.Get().Versions.CiliumCLI, ciliumCLIPath),
+			fmt.Sprintf("install Cilium %s into the cluster for pod CIDR %s unless it already runs Cilium", getCiliumVersion(i.config), i.config.GetPodCIDR()))
+	case config.CNIModeAzureCNI:
+		return append(actions,
+			fmt.Sprintf("download Azure CNI %s into %s", defaults.Get().Versions.AzureCNI, DefaultCNIBinDir),
+			"write "+filepath.Join(DefaultCNIConfDir, azureCNIConfigFile))
+	case config.CNIModeNone:
+		return append(actions, "leave the CNI configuration to the operator")
+	}
+	if !i.config.CNI.KubeProxyReplacement {
+		actions = append(actions, "load kernel module br_netfilter")
+	}
+	return append(actions, fmt.Sprintf("write %s for pod CIDR %s", filepath.Join(DefaultCNIConfDir, bridgeConfigFile), i.config.GetPodCIDR()))
+}
+
 func (i *Installer) prepareCNIDirectories() error {
 	for _, dir := range cniDirs {
 		if !utils.DirectoryExists(dir) {
@@ -109,8 +255,8 @@ func (i *Installer) prepareCNIDirectories() error {
 			}
 		}
 
-		// Only clean configuration, not binaries
-		if dir == DefaultCNIConfDir {
+		// Only clean configuration, not binaries, and only when the agent writes it
+		if dir == DefaultCNIConfDir && i.writesCNIConfig() {
 			i.logger.Debugf("Cleaning existing CNI configurations in: %s", dir)
 			if err := utils.RunSystemCommand("rm", "-rf", dir+"/*"); err != nil {
 				return fmt.Errorf("failed to clean CNI configuration directory: %w", err)
@@ -129,8 +275,15 @@ func (i *Installer) prepareCNIDirectories() error {
 	return nil
 }
 
+// writesCNIConfig reports whether the agent owns the CNI configuration directory, which Cilium and
+// operators bringing their own CNI write to themselves
+func (i *Installer) writesCNIConfig() bool {
+	mode := i.config.GetCNIMode()
+	return mode == config.CNIModeBridge || mode == config.CNIModeAzureCNI
+}
+
 // installCNIPlugins downloads and installs CNI plugins (matching reference script)
-func (i *Installer) installCNIPlugins() error {
+func (i *Installer) installCNIPlugins(ctx context.Context) error {
 	if canSkipCNIPluginInstallation() {
 		logrus.Info("CNI plugins are already installed and valid, skipping installation")
 		return nil
@@ -142,27 +295,22 @@ func (i *Installer) installCNIPlugins() error {
 		logrus.Warnf("Failed to clean CNI bin directory: %v", err)
 	}
 
-	// Ensure curl is installed before using it for downloading CNI plugins
-	if err := utils.RunSystemCommand("which", "curl"); err != nil {
-		logrus.Info("Installing curl...")
-		if err := utils.RunSystemCommand("apt", "install", "-y", "curl"); err != nil {
-			logrus.Warnf("Failed to install curl: %v", err)
-		}
-	}
-
 	// Construct CNI download URL
 	cniFileName, cniDownloadURL, err := i.constructCNIDownloadURL()
 	if err != nil {
 		return fmt.Errorf("failed to construct CNI download URL: %w", err)
 	}
 
-	// Download the CNI plugin tar file into tmp directory
-	tempFile := fmt.Sprintf("/tmp/%s", cniFileName)
-	// Clean up any existing CNI temp files from both /tmp and home directory
+	// Download the CNI plugin tar file into the staging directory
+	tempFile, err := utils.StagingPath(i.config.Paths.StagingDir, cniFileName)
+	if err != nil {
+		return err
+	}
+	// Clean up any existing CNI temp files from the staging directory
 	if err := utils.RunSystemCommand("bash", "-c", fmt.Sprintf("rm -f %s", tempFile)); err != nil {
-		logrus.Warnf("Failed to clean up existing CNI temp files from /tmp: %s", err)
+		logrus.Warnf("Failed to clean up existing CNI temp files from the staging directory: %s", err)
 	}
-	if err := utils.RunSystemCommand("curl", "-o", tempFile, "-L", cniDownloadURL); err != nil {
+	if err := downloadcache.New(i.config, i.logger).FetchVerified(ctx, cniDownloadURL, tempFile, i.config.CNI.Checksum); err != nil {
 		return fmt.Errorf("failed to download CNI plugins: %w", err)
 	}
 	defer func() {
@@ -172,10 +320,14 @@ func (i *Installer) installCNIPlugins() error {
 	}()
 
 	// Extract CNI plugins to /opt/cni/bin
-	if err := utils.RunSystemCommand("tar", "-C", DefaultCNIBinDir, "-xzf", tempFile); err != nil {
+	if err := utils.RunSystemCommandContext(ctx, "tar", "-C", DefaultCNIBinDir, "-xzf", tempFile); err != nil {
 		return fmt.Errorf("failed to extract CNI plugins: %w", err)
 	}
 
+	if err := provenance.RecordInstall(provenanceComponent, getCNIVersion(i.config), cniDownloadURL, tempFile); err != nil {
+		logrus.Warnf("Failed to record CNI plugins provenance: %v", err)
+	}
+
 	// Set ownership of extracted CNI plugins - critical for Cilium init containers
 	// Cilium init containers run as root and need to write to /opt/cni/bin
 	if err := utils.RunSystemCommand("chown", "-R", "root:root", DefaultCNIBinDir); err != nil {
@@ -191,6 +343,30 @@ func (i *Installer) installCNIPlugins() error {
 	return nil
 }
 
+// installArchive downloads a release archive into the staging directory and extracts it into dir
+func (i *Installer) installArchive(ctx context.Context, url, fileName, dir, component, version string) error {
+	tempFile, err := utils.StagingPath(i.config.Paths.StagingDir, fileName)
+	if err != nil {
+		return err
+	}
+	if err := downloadcache.New(i.config, i.logger).FetchVerified(ctx, url, tempFile, nil); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer func() {
+		if err := utils.RunCleanupCommand(tempFile); err != nil {
+			i.logger.Warnf("Failed to clean up temp file %s: %v", tempFile, err)
+		}
+	}()
+
+	if err := utils.RunSystemCommandContext(ctx, "tar", "-C", dir, "-xzf", tempFile); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", fileName, err)
+	}
+	if err := provenance.RecordInstall(component, version, url, tempFile); err != nil {
+		i.logger.Warnf("Failed to record %s provenance: %v", component, err)
+	}
+	return nil
+}
+
 func canSkipCNIPluginInstallation() bool {
 	for _, plugin := range requiredCNIPlugins {
 		pluginPath := filepath.Join(DefaultCNIBinDir, plugin)
@@ -207,7 +383,7 @@ func (i *Installer) constructCNIDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	url := fmt.Sprintf(cniDownLoadURL, cniVersion, arch, cniVersion)
+	url := fmt.Sprintf(defaults.Get().URLs.CNI, cniVersion, arch, cniVersion)
 	fileName := fmt.Sprintf(cniFileName, arch, cniVersion)
 	i.logger.Infof("Constructed CNI download URL: %s", url)
 	return fileName, url, nil
@@ -217,7 +393,26 @@ func getCNIVersion(cfg *config.Config) string {
 	if cfg.CNI.Version != "" {
 		return cfg.CNI.Version
 	}
-	return defaultCNIVersion
+	return defaults.Get().Versions.CNI
+}
+
+// bridgeSubnet returns the pod subnet of the bridge CNI and its gateway, the first address of the subnet
+// unless a gateway is configured
+func bridgeSubnet(podCIDR, configuredGateway string) (string, string, error) {
+	_, ipNet, err := net.ParseCIDR(podCIDR)
+	if err != nil || ipNet.IP.To4() == nil {
+		return "", "", fmt.Errorf("invalid pod CIDR %s: must be an IPv4 CIDR", podCIDR)
+	}
+	if configuredGateway != "" {
+		gateway := net.ParseIP(configuredGateway).To4()
+		if gateway == nil || !ipNet.Contains(gateway) {
+			return "", "", fmt.Errorf("invalid gateway %s: must be an IPv4 address within pod CIDR %s", configuredGateway, podCIDR)
+		}
+		return ipNet.String(), gateway.String(), nil
+	}
+	gateway := slices.Clone(ipNet.IP.To4())
+	gateway[3]++
+	return ipNet.String(), gateway.String(), nil
 }
 
 // CreateBridgeConfig creates bridge CNI configuration for edge nodes (compatible with BYO Cilium)
@@ -229,8 +424,11 @@ func (i *Installer) createBridgeConfig() error {
 	// This enables these sysctl settings:
 	// - net.bridge.bridge-nf-call-iptables = 1
 	// - net.bridge.bridge-nf-call-ip6tables = 1
-	if err := utils.RunSystemCommand("modprobe", "br_netfilter"); err != nil {
-		logrus.Warnf("Failed to load br_netfilter module: %v", err)
+	// Cilium replacing kube-proxy handles service traffic in eBPF and does not need bridged traffic to pass iptables
+	if !i.config.CNI.KubeProxyReplacement {
+		if err := utils.RunSystemCommand("modprobe", "br_netfilter"); err != nil {
+			logrus.Warnf("Failed to load br_netfilter module: %v", err)
+		}
 	}
 
 	// Remove any existing config to start fresh
@@ -238,6 +436,11 @@ func (i *Installer) createBridgeConfig() error {
 		logrus.Warnf("Failed to remove existing config file: %v", err)
 	}
 
+	subnet, gateway, err := bridgeSubnet(i.config.GetPodCIDR(), i.config.CNI.Gateway)
+	if err != nil {
+		return err
+	}
+
 	bridgeConfig := fmt.Sprintf(`{
     "cniVersion": "%s",
     "name": "bridge",
@@ -250,8 +453,8 @@ func (i *Installer) createBridgeConfig() error {
         "ranges": [
             [
                 {
-                    "subnet": "10.244.0.0/16",
-                    "gateway": "10.244.0.1"
+                    "subnet": "%s",
+                    "gateway": "%s"
                 }
             ]
         ],
@@ -261,7 +464,7 @@ func (i *Installer) createBridgeConfig() error {
             }
         ]
     }
-}`, defaultCNISpecVersion)
+}`, defaultCNISpecVersion, subnet, gateway)
 
 	// Write the config file into a temp file for Atomic file write
 	tempBridgeFile, err := utils.CreateTempFile("bridge-cni-*.conf", []byte(bridgeConfig))
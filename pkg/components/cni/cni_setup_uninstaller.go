@@ -2,9 +2,11 @@ package cni
 
 import (
 	"context"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -33,6 +35,20 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 		}
 	}
 
+	// Remove the cilium CLI the agent installed for cni.mode cilium; Cilium itself stays installed in the cluster
+	// for the other nodes
+	if ciliumCLIInstalledByAgent() {
+		if err := utils.RunCleanupCommand(ciliumCLIPath); err != nil {
+			u.logger.Warnf("Failed to remove cilium CLI: %v", err)
+		}
+	}
+
+	for _, component := range []string{provenanceComponent, azureCNIProvenanceComponent, ciliumProvenanceComponent} {
+		if err := provenance.Remove(component); err != nil {
+			u.logger.Debugf("Failed to remove %s provenance record: %v", component, err)
+		}
+	}
+
 	u.logger.Info("CNI configuration cleanup completed")
 	return nil
 }
@@ -45,10 +61,25 @@ func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 		}
 	}
 
-	return true
+	return !ciliumCLIInstalledByAgent() || !utils.FileExists(ciliumCLIPath)
+}
+
+// Plan describes the CNI cleanup for dry runs
+func (u *UnInstaller) Plan(ctx context.Context) []string {
+	actions := []string{"remove " + strings.Join(cniDirs, ", ")}
+	if ciliumCLIInstalledByAgent() && utils.FileExists(ciliumCLIPath) {
+		actions = append(actions, "remove "+ciliumCLIPath)
+	}
+	return actions
 }
 
 // GetName returns the cleanup step name
 func (u *UnInstaller) GetName() string {
 	return "CNICleanup"
 }
+
+// ciliumCLIInstalledByAgent reports whether the cilium CLI was installed by the agent rather than the operator
+func ciliumCLIInstalledByAgent() bool {
+	_, err := provenance.Load(ciliumProvenanceComponent)
+	return err == nil
+}
@@ -1,10 +1,15 @@
 package cni
 
-const (
+import "go.goms.io/aks/AKSFlexNode/pkg/defaults"
+
+var (
 	// DefaultCNIBinDir is the directory where CNI binaries are installed
-	DefaultCNIBinDir = "/opt/cni/bin"
+	DefaultCNIBinDir = defaults.Get().Paths.CNIBinDir
 	// DefaultCNIConfDir is the directory where CNI configuration files are stored
-	DefaultCNIConfDir = "/etc/cni/net.d"
+	DefaultCNIConfDir = defaults.Get().Paths.CNIConfDir
+)
+
+const (
 	// DefaultCNILibDir is the directory for CNI library files
 	DefaultCNILibDir = "/var/lib/cni"
 
@@ -12,6 +17,8 @@ const (
 	// Using 99-bridge.conf (high number) ensures other CNI solutions like Cilium
 	// can override this temporary bridge with lower-numbered configs (e.g., 05-cilium.conf)
 	bridgeConfigFile = "99-bridge.conf"
+	// Azure CNI Overlay configuration, named like the one AKS writes on its nodes
+	azureCNIConfigFile = "10-azure.conflist"
 
 	// Required CNI plugins
 	bridgePlugin    = "bridge"
@@ -20,9 +27,15 @@ const (
 	portmapPlugin   = "portmap"
 	bandwidthPlugin = "bandwidth"
 	tuningPlugin    = "tuning"
+	azureVNetPlugin = "azure-vnet"
+
+	// ciliumCLIPath is where the cilium CLI installing Cilium with cni.mode cilium is put
+	ciliumCLIPath = "/usr/local/bin/cilium"
 
-	// CNI version
-	defaultCNIVersion = "1.5.1"
+	// Component name used for provenance records
+	provenanceComponent         = "cni-plugins"
+	azureCNIProvenanceComponent = "azure-cni"
+	ciliumProvenanceComponent   = "cilium-cli"
 
 	// CNI specification version for configuration files
 	defaultCNISpecVersion = "0.3.1"
@@ -41,6 +54,7 @@ var requiredCNIPlugins = []string{
 }
 
 var (
-	cniFileName    = "cni-plugins-linux-%s-v%s.tgz"
-	cniDownLoadURL = "https://github.com/containernetworking/plugins/releases/download/v%s/" + cniFileName
+	cniFileName       = "cni-plugins-linux-%s-v%s.tgz"
+	azureCNIFileName  = "azure-vnet-cni-linux-%s-v%s.tgz"
+	ciliumCLIFileName = "cilium-linux-%s.tar.gz"
 )
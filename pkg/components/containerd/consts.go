@@ -5,8 +5,15 @@ const (
 	defaultContainerdBinaryDir = "/usr/bin/containerd"
 	defaultContainerdConfigDir = "/etc/containerd"
 	containerdConfigFile       = "/etc/containerd/config.toml"
+	registryConfigDir          = "/etc/containerd/certs.d"
 	containerdServiceFile      = "/etc/systemd/system/containerd.service"
 	containerdDataDir          = "/var/lib/containerd"
+
+	// NvidiaContainerRuntimePath is the runtime of the NVIDIA container toolkit, run by the nvidia runtime handler
+	NvidiaContainerRuntimePath = "/usr/bin/nvidia-container-runtime"
+
+	// Component name used for provenance records
+	provenanceComponent = "containerd"
 )
 
 var containerdDirs = []string{
@@ -22,7 +29,4 @@ var containerdBinaries = []string{
 	"containerd-stress",
 }
 
-var (
-	containerdFileName    = "containerd-%s-linux-%s.tar.gz"
-	containerdDownloadURL = "https://github.com/containerd/containerd/releases/download/v%s/" + containerdFileName
-)
+var containerdFileName = "containerd-%s-linux-%s.tar.gz"
@@ -3,12 +3,16 @@ package containerd
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/cni"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -35,7 +39,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Info("Prepared containerd directories successfully")
 
 	i.logger.Infof("Step 2: Downloading and installing containerd version %s", i.getContainerdVersion())
-	if err := i.installContainerd(); err != nil {
+	if err := i.installContainerd(ctx); err != nil {
 		return fmt.Errorf("failed to install containerd: %w", err)
 	}
 	i.logger.Info("containerd binaries installed successfully")
@@ -60,15 +64,7 @@ func (i *Installer) prepareContainerdDirectories() error {
 			}
 		}
 
-		// Clean up any existing configurations to start fresh
-		if dir == defaultContainerdConfigDir {
-			i.logger.Debugf("Cleaning existing containerd configurations in: %s", dir)
-			if err := utils.RunSystemCommand("rm", "-rf", dir+"/*"); err != nil {
-				return fmt.Errorf("failed to clean containerd configuration directory: %w", err)
-			}
-		}
-
-		// Set proper permissions
+		// Set proper permissions, files next to config.toml are kept for containerd.configImports
 		if err := utils.RunSystemCommand("chmod", "-R", "0755", dir); err != nil {
 			logrus.Warnf("Failed to set permissions for containerd directory %s: %v", dir, err)
 		}
@@ -76,7 +72,7 @@ func (i *Installer) prepareContainerdDirectories() error {
 	return nil
 }
 
-func (i *Installer) installContainerd() error {
+func (i *Installer) installContainerd(ctx context.Context) error {
 	// Check if we can skip installation
 	if i.canSkipContainerdInstallation() {
 		i.logger.Info("containerd is already installed and valid, skipping installation")
@@ -96,11 +92,14 @@ func (i *Installer) installContainerd() error {
 		return fmt.Errorf("failed to construct containerd download URL: %w", err)
 	}
 
-	// Download the containerd plugin tar file into tmp directory
-	tempFile := fmt.Sprintf("/tmp/%s", containerdFileName)
-	// Clean up any existing containerd temp files from /tmp directory to avoid conflicts
+	// Download the containerd plugin tar file into the staging directory
+	tempFile, err := utils.StagingPath(i.config.Paths.StagingDir, containerdFileName)
+	if err != nil {
+		return err
+	}
+	// Clean up any existing containerd temp files from the staging directory to avoid conflicts
 	if err := utils.RunSystemCommand("bash", "-c", fmt.Sprintf("rm -f %s", tempFile)); err != nil {
-		logrus.Warnf("Failed to clean up existing containerd temp files from /tmp: %s", err)
+		logrus.Warnf("Failed to clean up existing containerd temp files from the staging directory: %s", err)
 	}
 	defer func() {
 		if err := utils.RunCleanupCommand(tempFile); err != nil {
@@ -109,13 +108,13 @@ func (i *Installer) installContainerd() error {
 	}()
 
 	i.logger.Infof("Downloading containerd from %s into %s", containerdURL, tempFile)
-	if err := utils.DownloadFile(containerdURL, tempFile); err != nil {
+	if err := downloadcache.New(i.config, i.logger).FetchVerified(ctx, containerdURL, tempFile, i.config.Containerd.Checksum); err != nil {
 		return fmt.Errorf("failed to download containerd from %s: %w", containerdURL, err)
 	}
 
 	// Extract containerd binaries directly to /usr/bin, stripping the 'bin/' prefix
 	i.logger.Info("Extracting containerd binaries to /usr/bin")
-	if err := utils.RunSystemCommand("tar", "-C", systemBinDir, "--strip-components=1", "-xzf", tempFile, "bin/"); err != nil {
+	if err := utils.RunSystemCommandContext(ctx, "tar", "-C", systemBinDir, "--strip-components=1", "-xzf", tempFile, "bin/"); err != nil {
 		return fmt.Errorf("failed to extract containerd binaries: %w", err)
 	}
 
@@ -128,6 +127,9 @@ func (i *Installer) installContainerd() error {
 		}
 	}
 
+	if err := provenance.RecordInstall(provenanceComponent, i.getContainerdVersion(), containerdURL, tempFile); err != nil {
+		i.logger.Warnf("Failed to record containerd provenance: %v", err)
+	}
 	return nil
 }
 
@@ -164,7 +166,7 @@ func (i *Installer) constructContainerdDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	url := fmt.Sprintf(containerdDownloadURL, containerdVersion, containerdVersion, arch)
+	url := fmt.Sprintf(defaults.Get().URLs.Containerd, containerdVersion, containerdVersion, arch)
 	fileName := fmt.Sprintf(containerdFileName, containerdVersion, arch)
 	i.logger.Infof("Constructed containerd download URL: %s", url)
 	return fileName, url, nil
@@ -174,8 +176,13 @@ func (i *Installer) constructContainerdDownloadURL() (string, string, error) {
 func (i *Installer) cleanupExistingInstallation() error {
 	i.logger.Debug("Cleaning up existing containerd installation files")
 
-	// Try to stop any processes that might be using containerd (best effort)
-	if err := utils.RunSystemCommand("pkill", "-f", "containerd"); err != nil {
+	// Try to stop any processes that might be using containerd (best effort).
+	// A shared containerd keeps its shims, which run the containers of other workloads.
+	if i.config.Containerd.Shared {
+		if err := utils.StopService("containerd"); err != nil {
+			i.logger.Debugf("Failed to stop containerd: %v", err)
+		}
+	} else if err := utils.RunSystemCommand("pkill", "-f", "containerd"); err != nil {
 		i.logger.Debugf("No containerd processes found to kill (or pkill failed): %v", err)
 	}
 
@@ -215,6 +222,19 @@ func (i *Installer) configure() error {
 	return nil
 }
 
+// RepairConfig rewrites the containerd configuration the agent renders, undoing edits made by other tools,
+// and restarts containerd to apply it
+func RepairConfig(logger *logrus.Logger) error {
+	i := NewInstaller(logger)
+	if err := i.createContainerdConfigFile(); err != nil {
+		return err
+	}
+	if err := utils.RestartService("containerd"); err != nil {
+		return fmt.Errorf("failed to restart containerd: %w", err)
+	}
+	return nil
+}
+
 // createContainerdServiceFile creates the containerd systemd service file
 func (i *Installer) createContainerdServiceFile() error {
 	containerdService := `[Unit]
@@ -263,10 +283,46 @@ WantedBy=multi-user.target`
 
 // createContainerdConfigFile creates the containerd configuration file
 func (i *Installer) createContainerdConfigFile() error {
-	containerdConfig := fmt.Sprintf(`version = 2
+	containerdConfig, err := i.containerdConfig()
+	if err != nil {
+		return err
+	}
+
+	// Create a tmp containerd config file
+	tempConfigFile, err := utils.CreateTempFile("containerd-config-*.toml", []byte(containerdConfig))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary containerd config file: %w", err)
+	}
+	defer utils.CleanupTempFile(tempConfigFile.Name())
+
+	// Copy the temp file to the final location using sudo
+	if err := utils.RunSystemCommand("cp", tempConfigFile.Name(), containerdConfigFile); err != nil {
+		return fmt.Errorf("failed to install containerd config file: %w", err)
+	}
+
+	// Set proper permissions, registry credentials are readable by root only
+	mode := "644"
+	if i.hasRegistryCredentials() {
+		mode = "600"
+	}
+	if err := utils.RunSystemCommand("chmod", mode, containerdConfigFile); err != nil {
+		return fmt.Errorf("failed to set containerd config file permissions: %w", err)
+	}
+
+	return i.createRegistryHostsFiles()
+}
+
+// renderContainerdConfig renders the containerd configuration file content
+func (i *Installer) renderContainerdConfig() string {
+	var downloads string
+	if i.config.Containerd.MaxConcurrentDownloads > 0 {
+		downloads = fmt.Sprintf("\n\tmax_concurrent_downloads = %d", i.config.Containerd.MaxConcurrentDownloads)
+	}
+
+	return fmt.Sprintf(`version = 2%s
 oom_score = 0
 [plugins."io.containerd.grpc.v1.cri"]
-	sandbox_image = "%s"
+	sandbox_image = "%s"%s
 	[plugins."io.containerd.grpc.v1.cri".containerd]
 		default_runtime_name = "runc"
 		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
@@ -277,46 +333,113 @@ oom_score = 0
 		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted]
 			runtime_type = "io.containerd.runc.v2"
 		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.untrusted.options]
-			BinaryName = "/usr/bin/runc"
+			BinaryName = "/usr/bin/runc"%s
 	[plugins."io.containerd.grpc.v1.cri".cni]
 		bin_dir = "%s"
 		conf_dir = "%s"
 	[plugins."io.containerd.grpc.v1.cri".registry]
-		config_path = "/etc/containerd/certs.d"
+		config_path = "%s"
 	[plugins."io.containerd.grpc.v1.cri".registry.headers]
-		X-Meta-Source-Client = ["azure/aks"]
+		X-Meta-Source-Client = ["azure/aks"]%s
 [metrics]
 	address = "%s"`,
+		i.renderImports(),
 		i.getPauseImage(),
+		downloads,
+		i.renderGPURuntime(),
 		cni.DefaultCNIBinDir,
 		cni.DefaultCNIConfDir,
-		i.getMetricsAddress())
+		registryConfigDir,
+		i.renderRegistryAuth(),
+		i.getMetricsAddress()) + i.renderGCConfig()
+}
 
-	// Create a tmp containerd config file
-	tempConfigFile, err := utils.CreateTempFile("containerd-config-*.toml", []byte(containerdConfig))
-	if err != nil {
-		return fmt.Errorf("failed to create temporary containerd config file: %w", err)
+// renderGPURuntime renders the runtime of pods with the nvidia RuntimeClass, which runs containers with the
+// NVIDIA container runtime to expose the GPUs. It is empty unless node.gpu is enabled.
+func (i *Installer) renderGPURuntime() string {
+	if !i.config.IsGPUEnabled() {
+		return ""
 	}
-	defer utils.CleanupTempFile(tempConfigFile.Name())
+	return fmt.Sprintf(`
+		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.%[1]s]
+			runtime_type = "io.containerd.runc.v2"
+		[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.%[1]s.options]
+			BinaryName = "%[2]s"
+			SystemdCgroup = true`, config.GPURuntimeClass, NvidiaContainerRuntimePath)
+}
 
-	// Copy the temp file to the final location using sudo
-	if err := utils.RunSystemCommand("cp", tempConfigFile.Name(), containerdConfigFile); err != nil {
-		return fmt.Errorf("failed to install containerd config file: %w", err)
-	}
+// HasGPURuntime reports whether the containerd configuration on disk has the nvidia runtime
+func HasGPURuntime() bool {
+	data, err := os.ReadFile(containerdConfigFile)
+	return err == nil && strings.Contains(string(data), "containerd.runtimes."+config.GPURuntimeClass+"]")
+}
 
-	// Set proper permissions
-	if err := utils.RunSystemCommand("chmod", "644", containerdConfigFile); err != nil {
-		return fmt.Errorf("failed to set containerd config file permissions: %w", err)
+// renderGCConfig renders the garbage collection scheduler section, empty when containerd defaults apply
+func (i *Installer) renderGCConfig() string {
+	gc := i.config.Containerd.GC
+	if gc == nil {
+		return ""
 	}
 
-	return nil
+	var settings []string
+	if gc.PauseThreshold > 0 {
+		settings = append(settings, fmt.Sprintf("pause_threshold = %g", gc.PauseThreshold))
+	}
+	if gc.DeletionThreshold > 0 {
+		settings = append(settings, fmt.Sprintf("deletion_threshold = %d", gc.DeletionThreshold))
+	}
+	if gc.MutationThreshold > 0 {
+		settings = append(settings, fmt.Sprintf("mutation_threshold = %d", gc.MutationThreshold))
+	}
+	if gc.ScheduleDelay != "" {
+		settings = append(settings, fmt.Sprintf("schedule_delay = \"%s\"", gc.ScheduleDelay))
+	}
+	if gc.StartupDelay != "" {
+		settings = append(settings, fmt.Sprintf("startup_delay = \"%s\"", gc.StartupDelay))
+	}
+	if len(settings) == 0 {
+		return ""
+	}
+	return "\n[plugins.\"io.containerd.gc.v1.scheduler\"]\n\t" + strings.Join(settings, "\n\t")
 }
 
 // Validate validates preconditions before execution
 func (i *Installer) Validate(ctx context.Context) error {
+	if i.config.Containerd.Shared {
+		return nil
+	}
+
+	// A running containerd with workloads outside Kubernetes is restarted by bootstrap and removed by unbootstrap
+	if utils.IsServiceActive("containerd") {
+		if namespaces, err := workloadNamespaces(); err == nil && len(namespaces) > 0 {
+			i.logger.Warnf("containerd runs workloads outside Kubernetes in namespace(s) %s; set containerd.shared to keep them on unbootstrap",
+				strings.Join(namespaces, ", "))
+		}
+	}
 	return nil
 }
 
+// Plan describes the containerd installation for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	var actions []string
+	if !i.canSkipContainerdInstallation() {
+		_, url, err := i.constructContainerdDownloadURL()
+		if err != nil {
+			url = "the containerd release"
+		}
+		actions = append(actions,
+			fmt.Sprintf("download containerd %s from %s", i.getContainerdVersion(), url),
+			fmt.Sprintf("replace %s in %s", strings.Join(containerdBinaries, ", "), systemBinDir))
+	}
+	actions = append(actions,
+		"write "+containerdServiceFile,
+		"write "+containerdConfigFile)
+	for _, registry := range i.config.Containerd.Registries {
+		actions = append(actions, "write "+filepath.Join(registryConfigDir, registry.Host, "hosts.toml"))
+	}
+	return append(actions, "reload systemd")
+}
+
 // GetName returns the step name
 func (i *Installer) GetName() string {
 	return "ContainerdInstaller"
@@ -352,8 +475,8 @@ func (i *Installer) getContainerdVersion() string {
 	if i.config.Containerd.Version != "" {
 		return i.config.Containerd.Version
 	}
-	// Default to a known stable version if not specified
-	return "1.7.20"
+	// Default to the known stable version of the component defaults if not specified
+	return defaults.Get().Versions.Containerd
 }
 
 func (i *Installer) getPauseImage() string {
@@ -361,7 +484,7 @@ func (i *Installer) getPauseImage() string {
 		return i.config.Containerd.PauseImage
 	}
 	// Default pause image
-	return "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+	return defaults.Get().Images.Pause
 }
 
 func (i *Installer) getMetricsAddress() string {
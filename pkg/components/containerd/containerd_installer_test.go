@@ -0,0 +1,81 @@
+package containerd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderContainerdConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		containerd config.ContainerdConfig
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:    "containerd defaults",
+			want:    []string{`sandbox_image = "mcr.microsoft.com/oss/kubernetes/pause:3.6"`, `address = "0.0.0.0:10257"`},
+			notWant: []string{"max_concurrent_downloads", "io.containerd.gc.v1.scheduler"},
+		},
+		{
+			name: "download limit and garbage collection",
+			containerd: config.ContainerdConfig{
+				MaxConcurrentDownloads: 2,
+				GC: &config.ContainerdGCConfig{
+					PauseThreshold:    0.05,
+					DeletionThreshold: 50,
+					ScheduleDelay:     "1s",
+				},
+			},
+			want: []string{
+				"sandbox_image = \"mcr.microsoft.com/oss/kubernetes/pause:3.6\"\n\tmax_concurrent_downloads = 2\n",
+				"[plugins.\"io.containerd.gc.v1.scheduler\"]\n\tpause_threshold = 0.05\n\tdeletion_threshold = 50\n\tschedule_delay = \"1s\"",
+			},
+			notWant: []string{"mutation_threshold", "startup_delay"},
+		},
+		{
+			name:       "empty garbage collection settings",
+			containerd: config.ContainerdConfig{GC: &config.ContainerdGCConfig{}},
+			notWant:    []string{"io.containerd.gc.v1.scheduler"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installer := &Installer{config: &config.Config{Containerd: tt.containerd}, logger: logrus.New()}
+			rendered := installer.renderContainerdConfig()
+
+			for _, want := range tt.want {
+				if !strings.Contains(rendered, want) {
+					t.Errorf("renderContainerdConfig() missing %q:\n%s", want, rendered)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(rendered, notWant) {
+					t.Errorf("renderContainerdConfig() unexpectedly contains %q:\n%s", notWant, rendered)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderContainerdConfigGPURuntime(t *testing.T) {
+	installer := &Installer{config: &config.Config{}, logger: logrus.New()}
+	if rendered := installer.renderContainerdConfig(); strings.Contains(rendered, "runtimes.nvidia") {
+		t.Errorf("renderContainerdConfig() without GPUs contains the nvidia runtime:\n%s", rendered)
+	}
+
+	installer.config.Node.GPU = &config.GPUConfig{Enabled: true}
+	rendered := installer.renderContainerdConfig()
+	want := "[plugins.\"io.containerd.grpc.v1.cri\".containerd.runtimes.nvidia.options]\n\t\t\tBinaryName = \"/usr/bin/nvidia-container-runtime\""
+	if !strings.Contains(rendered, want) {
+		t.Errorf("renderContainerdConfig() with GPUs missing %q:\n%s", want, rendered)
+	}
+	if !strings.Contains(rendered, `default_runtime_name = "runc"`) {
+		t.Errorf("renderContainerdConfig() with GPUs changed the default runtime:\n%s", rendered)
+	}
+}
@@ -0,0 +1,85 @@
+package containerd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// kubernetesNamespace is the containerd namespace the CRI plugin runs pods and pulls images in
+const kubernetesNamespace = "k8s.io"
+
+// ctrList runs a ctr listing command and returns its non-empty output lines
+func ctrList(args ...string) ([]string, error) {
+	output, err := utils.RunCommandWithOutput("ctr", args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+// workloadNamespaces returns the containerd namespaces outside Kubernetes that hold containers or images
+func workloadNamespaces() ([]string, error) {
+	namespaces, err := ctrList("namespaces", "list", "-q")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containerd namespaces: %w", err)
+	}
+
+	var workloads []string
+	for _, namespace := range namespaces {
+		if namespace == kubernetesNamespace {
+			continue
+		}
+		containers, _ := ctrList("-n", namespace, "containers", "list", "-q")
+		images, _ := ctrList("-n", namespace, "images", "list", "-q")
+		if len(containers) > 0 || len(images) > 0 {
+			workloads = append(workloads, namespace)
+		}
+	}
+	return workloads, nil
+}
+
+// kubernetesNamespaceEmpty checks if no pod containers are left in the Kubernetes namespace
+func kubernetesNamespaceEmpty() bool {
+	containers, err := ctrList("-n", kubernetesNamespace, "containers", "list", "-q")
+	return err != nil || len(containers) == 0
+}
+
+// removeKubernetesNamespace removes the pod containers and images of the Kubernetes namespace.
+// Other namespaces are left untouched and content they share is kept by the containerd garbage collector.
+func removeKubernetesNamespace(logger *logrus.Logger) error {
+	containers, err := ctrList("-n", kubernetesNamespace, "containers", "list", "-q")
+	if err != nil {
+		return fmt.Errorf("failed to list containers in namespace %s: %w", kubernetesNamespace, err)
+	}
+	for _, container := range containers {
+		// Force deleting the task kills pod processes still running after kubelet stopped
+		if err := utils.RunSystemCommand("ctr", "-n", kubernetesNamespace, "tasks", "delete", "--force", container); err != nil {
+			logger.Debugf("No task to delete for container %s: %v", container, err)
+		}
+		if err := utils.RunSystemCommand("ctr", "-n", kubernetesNamespace, "containers", "delete", container); err != nil {
+			logger.Warnf("Failed to delete container %s: %v", container, err)
+		}
+	}
+
+	images, err := ctrList("-n", kubernetesNamespace, "images", "list", "-q")
+	if err != nil {
+		return fmt.Errorf("failed to list images in namespace %s: %w", kubernetesNamespace, err)
+	}
+	if len(images) > 0 {
+		args := append([]string{"-n", kubernetesNamespace, "images", "delete"}, images...)
+		if err := utils.RunSystemCommand("ctr", args...); err != nil {
+			logger.Warnf("Failed to delete images in namespace %s: %v", kubernetesNamespace, err)
+		}
+	}
+
+	// Snapshots are released asynchronously, so the namespace itself may not be removable yet
+	if err := utils.RunSystemCommand("ctr", "namespaces", "remove", kubernetesNamespace); err != nil {
+		logger.Debugf("Namespace %s not removed, its remaining content is garbage collected: %v", kubernetesNamespace, err)
+	}
+
+	logger.Infof("Removed %d container(s) and %d image(s) from containerd namespace %s", len(containers), len(images), kubernetesNamespace)
+	return nil
+}
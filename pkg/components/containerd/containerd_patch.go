@@ -0,0 +1,65 @@
+package containerd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// containerdConfig returns the containerd configuration file content, the rendered configuration with
+// containerd.configPatch merged over it
+func (i *Installer) containerdConfig() (string, error) {
+	rendered := i.renderContainerdConfig()
+	if i.config.Containerd.ConfigPatch == "" {
+		return rendered, nil
+	}
+	return mergeConfigPatch(rendered, i.config.Containerd.ConfigPatch)
+}
+
+// renderImports renders the imports of the drop-in files of containerd.configImports, empty without any
+func (i *Installer) renderImports() string {
+	imports := i.config.Containerd.ConfigImports
+	if len(imports) == 0 {
+		return ""
+	}
+	quoted := make([]string, 0, len(imports))
+	for _, path := range imports {
+		quoted = append(quoted, strconv.Quote(path))
+	}
+	return "\nimports = [" + strings.Join(quoted, ", ") + "]"
+}
+
+// mergeConfigPatch merges the TOML of patch over the rendered configuration. Tables are merged key by key,
+// any other value of the patch replaces the rendered one, so a patch changes single settings and keeps the
+// rest of a section.
+func mergeConfigPatch(rendered, patch string) (string, error) {
+	var base, overlay map[string]any
+	if err := toml.Unmarshal([]byte(rendered), &base); err != nil {
+		return "", fmt.Errorf("failed to parse rendered containerd config: %w", err)
+	}
+	if err := toml.Unmarshal([]byte(patch), &overlay); err != nil {
+		return "", fmt.Errorf("failed to parse containerd.configPatch: %w", err)
+	}
+	mergeTables(base, overlay)
+
+	merged, err := toml.Marshal(base)
+	if err != nil {
+		return "", fmt.Errorf("failed to render patched containerd config: %w", err)
+	}
+	return string(merged), nil
+}
+
+// mergeTables merges overlay into base recursively
+func mergeTables(base, overlay map[string]any) {
+	for key, value := range overlay {
+		table, isTable := value.(map[string]any)
+		baseTable, baseIsTable := base[key].(map[string]any)
+		if isTable && baseIsTable {
+			mergeTables(baseTable, table)
+			continue
+		}
+		base[key] = value
+	}
+}
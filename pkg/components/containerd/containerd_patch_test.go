@@ -0,0 +1,72 @@
+package containerd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroupdriver"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestContainerdConfigPatch(t *testing.T) {
+	installer := &Installer{config: &config.Config{Containerd: config.ContainerdConfig{
+		PauseImage:    "mcr.microsoft.com/oss/kubernetes/pause:3.6",
+		ConfigImports: []string{"/etc/containerd/conf.d/*.toml"},
+		ConfigPatch: `
+[plugins."io.containerd.grpc.v1.cri"]
+  enable_unprivileged_ports = true
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+  BinaryName = "/usr/local/bin/runc"
+`,
+	}}, logger: logrus.New()}
+
+	merged, err := installer.containerdConfig()
+	if err != nil {
+		t.Fatalf("containerdConfig() unexpected error = %v", err)
+	}
+
+	var parsed struct {
+		Version int      `toml:"version"`
+		Imports []string `toml:"imports"`
+		Plugins map[string]struct {
+			SandboxImage            string `toml:"sandbox_image"`
+			EnableUnprivilegedPorts bool   `toml:"enable_unprivileged_ports"`
+			Containerd              struct {
+				Runtimes map[string]struct {
+					Options map[string]any `toml:"options"`
+				} `toml:"runtimes"`
+			} `toml:"containerd"`
+		} `toml:"plugins"`
+	}
+	if err := toml.Unmarshal([]byte(merged), &parsed); err != nil {
+		t.Fatalf("patched config is not valid TOML: %v\n%s", err, merged)
+	}
+	cri := parsed.Plugins["io.containerd.grpc.v1.cri"]
+	if parsed.Version != 2 || len(parsed.Imports) != 1 || parsed.Imports[0] != "/etc/containerd/conf.d/*.toml" {
+		t.Errorf("patched config version = %d, imports = %v", parsed.Version, parsed.Imports)
+	}
+	if !cri.EnableUnprivilegedPorts || cri.SandboxImage != "mcr.microsoft.com/oss/kubernetes/pause:3.6" {
+		t.Errorf("patched config lost a rendered setting or missed a patched one:\n%s", merged)
+	}
+	runc := cri.Containerd.Runtimes["runc"].Options
+	if runc["BinaryName"] != "/usr/local/bin/runc" || runc["SystemdCgroup"] != true {
+		t.Errorf("runc options = %v, want the patched binary and the rendered cgroup setting", runc)
+	}
+	if driver := cgroupdriver.ContainerdDriver([]byte(merged)); driver != cgroupdriver.Systemd {
+		t.Errorf("ContainerdDriver() of the patched config = %s, want %s", driver, cgroupdriver.Systemd)
+	}
+}
+
+func TestContainerdConfigWithoutPatch(t *testing.T) {
+	installer := &Installer{config: &config.Config{}, logger: logrus.New()}
+	rendered, err := installer.containerdConfig()
+	if err != nil || rendered != installer.renderContainerdConfig() {
+		t.Errorf("containerdConfig() without a patch = %v, want the rendered config", err)
+	}
+	if strings.Contains(rendered, "imports") {
+		t.Errorf("containerdConfig() without imports has an imports line:\n%s", rendered)
+	}
+}
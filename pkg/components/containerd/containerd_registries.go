@@ -0,0 +1,101 @@
+package containerd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// dockerHubServer is the registry endpoint images from docker.io are pulled from
+const dockerHubServer = "https://registry-1.docker.io"
+
+// tomlEscaper escapes a value for a TOML basic string
+var tomlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// registryServer returns the upstream endpoint of a registry host, empty for the _default host
+func registryServer(host string) string {
+	switch host {
+	case config.DefaultRegistryHost:
+		return ""
+	case "docker.io":
+		return dockerHubServer
+	}
+	return "https://" + host
+}
+
+// renderHostsTOML renders the hosts.toml of a registry: the mirrors are tried in order before the registry itself,
+// all verified with the configured CA or not verified at all for an insecure registry
+func renderHostsTOML(registry config.RegistryConfig) string {
+	var b strings.Builder
+	b.WriteString("# Written by aks-flex-node from containerd.registries, changes are overwritten\n")
+	if server := registryServer(registry.Host); server != "" {
+		fmt.Fprintf(&b, "server = \"%s\"\n", server)
+		b.WriteString(renderHostTLS(registry, ""))
+	}
+	for _, mirror := range registry.Mirrors {
+		fmt.Fprintf(&b, "\n[host.\"%s\"]\n", tomlEscaper.Replace(mirror))
+		b.WriteString("  capabilities = [\"pull\", \"resolve\"]\n")
+		b.WriteString(renderHostTLS(registry, "  "))
+	}
+	return b.String()
+}
+
+// renderHostTLS renders how the TLS certificate of a registry endpoint is verified
+func renderHostTLS(registry config.RegistryConfig, indent string) string {
+	switch {
+	case registry.Insecure:
+		return indent + "skip_verify = true\n"
+	case registry.CAFile != "":
+		return fmt.Sprintf("%sca = \"%s\"\n", indent, tomlEscaper.Replace(registry.CAFile))
+	}
+	return ""
+}
+
+// renderRegistryAuth renders the credentials of the registries that need them for the CRI plugin of config.toml
+func (i *Installer) renderRegistryAuth() string {
+	var b strings.Builder
+	for _, registry := range i.config.Containerd.Registries {
+		if registry.Username == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\t[plugins.\"io.containerd.grpc.v1.cri\".registry.configs.\"%s\".auth]\n", registry.Host)
+		fmt.Fprintf(&b, "\t\tusername = \"%s\"\n", tomlEscaper.Replace(registry.Username))
+		fmt.Fprintf(&b, "\t\tpassword = \"%s\"", tomlEscaper.Replace(registry.Password))
+	}
+	return b.String()
+}
+
+// hasRegistryCredentials returns true when a registry has credentials, which keeps config.toml readable by root only
+func (i *Installer) hasRegistryCredentials() bool {
+	for _, registry := range i.config.Containerd.Registries {
+		if registry.Username != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// createRegistryHostsFiles writes a hosts.toml per configured registry below the containerd registry config path.
+// The directory holds only the files of the configured registries, those of registries removed from the
+// configuration are dropped.
+func (i *Installer) createRegistryHostsFiles() error {
+	if err := sysutil.RunSystemCommand("rm", "-rf", registryConfigDir); err != nil {
+		return fmt.Errorf("failed to clean containerd registry configuration %s: %w", registryConfigDir, err)
+	}
+	for _, registry := range i.config.Containerd.Registries {
+		dir := filepath.Join(registryConfigDir, registry.Host)
+		if err := sysutil.RunSystemCommand("mkdir", "-p", dir); err != nil {
+			return fmt.Errorf("failed to create containerd registry directory %s: %w", dir, err)
+		}
+		path := filepath.Join(dir, "hosts.toml")
+		if err := fsutil.WriteFileAtomicSystem(path, []byte(renderHostsTOML(registry)), 0o644); err != nil {
+			return fmt.Errorf("failed to write containerd registry configuration %s: %w", path, err)
+		}
+		i.logger.Infof("Configured registry %s with %d mirror(s)", registry.Host, len(registry.Mirrors))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package containerd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderHostsTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry config.RegistryConfig
+		want     string
+	}{
+		{
+			name:     "docker hub mirror",
+			registry: config.RegistryConfig{Host: "docker.io", Mirrors: []string{"https://mirror.local:5000"}},
+			want: `server = "https://registry-1.docker.io"
+
+[host."https://mirror.local:5000"]
+  capabilities = ["pull", "resolve"]
+`,
+		},
+		{
+			name:     "insecure private registry",
+			registry: config.RegistryConfig{Host: "registry.local:5000", Mirrors: []string{"http://10.0.0.5:5000"}, Insecure: true},
+			want: `server = "https://registry.local:5000"
+skip_verify = true
+
+[host."http://10.0.0.5:5000"]
+  capabilities = ["pull", "resolve"]
+  skip_verify = true
+`,
+		},
+		{
+			name:     "default mirror with private CA",
+			registry: config.RegistryConfig{Host: config.DefaultRegistryHost, Mirrors: []string{"https://cache.site.example"}, CAFile: "/etc/ssl/site-ca.pem"},
+			want: `
+[host."https://cache.site.example"]
+  capabilities = ["pull", "resolve"]
+  ca = "/etc/ssl/site-ca.pem"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := renderHostsTOML(tt.registry)
+			body := strings.SplitN(rendered, "\n", 2)[1]
+			if body != tt.want {
+				t.Errorf("renderHostsTOML() =\n%s\nwant:\n%s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderContainerdConfigRegistryAuth(t *testing.T) {
+	installer := &Installer{config: &config.Config{Containerd: config.ContainerdConfig{Registries: []config.RegistryConfig{
+		{Host: "docker.io", Mirrors: []string{"https://mirror.local"}},
+		{Host: "registry.example.com", Username: "puller", Password: `pa"ss`},
+	}}}, logger: logrus.New()}
+
+	rendered := installer.renderContainerdConfig()
+	want := "\t[plugins.\"io.containerd.grpc.v1.cri\".registry.configs.\"registry.example.com\".auth]\n\t\tusername = \"puller\"\n\t\tpassword = \"pa\\\"ss\"\n[metrics]"
+	if !strings.Contains(rendered, want) {
+		t.Errorf("renderContainerdConfig() missing registry credentials %q:\n%s", want, rendered)
+	}
+	if strings.Contains(rendered, `configs."docker.io"`) {
+		t.Errorf("renderContainerdConfig() has credentials for a registry without them:\n%s", rendered)
+	}
+	if !installer.hasRegistryCredentials() {
+		t.Error("hasRegistryCredentials() = false with a registry password")
+	}
+}
@@ -4,19 +4,24 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
 // UnInstaller handles containerd uninstallation operations
 type UnInstaller struct {
+	config *config.Config
 	logger *logrus.Logger
 }
 
 // NewUnInstaller creates a new containerd unInstaller
 func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
 	return &UnInstaller{
+		config: config.GetConfig(),
 		logger: logger,
 	}
 }
@@ -28,6 +33,14 @@ func (u *UnInstaller) GetName() string {
 
 // Execute removes containerd container runtime and cleans up configuration
 func (u *UnInstaller) Execute(ctx context.Context) error {
+	if u.config.IsContainerdShared() {
+		u.logger.Info("containerd is shared with workloads outside Kubernetes, removing only the Kubernetes namespace")
+		if err := removeKubernetesNamespace(u.logger); err != nil {
+			return fmt.Errorf("failed to remove containerd namespace %s: %w", kubernetesNamespace, err)
+		}
+		return nil
+	}
+
 	u.logger.Info("Uninstalling containerd")
 
 	// Step 1: Stop containerd services
@@ -50,12 +63,20 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 		return fmt.Errorf("failed to cleanup containerd files: %w", err)
 	}
 
+	if err := provenance.Remove(provenanceComponent); err != nil {
+		u.logger.Debugf("Failed to remove containerd provenance record: %v", err)
+	}
+
 	u.logger.Info("Containerd uninstalled successfully")
 	return nil
 }
 
 // IsCompleted checks if containerd has been completely removed
 func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
+	if u.config.IsContainerdShared() {
+		return kubernetesNamespaceEmpty()
+	}
+
 	// Check if any containerd binaries still exist
 	for _, binary := range containerdBinaries {
 		if utils.BinaryExists(binary) {
@@ -76,6 +97,19 @@ func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 	return true
 }
 
+// Plan describes the containerd removal for dry runs
+func (u *UnInstaller) Plan(ctx context.Context) []string {
+	if u.config.IsContainerdShared() {
+		return []string{fmt.Sprintf("remove the Kubernetes containers and images in containerd namespace %s, keeping containerd", kubernetesNamespace)}
+	}
+	return []string{
+		"stop containerd",
+		fmt.Sprintf("remove %s from %s", strings.Join(containerdBinaries, ", "), systemBinDir),
+		"remove " + containerdServiceFile,
+		fmt.Sprintf("remove %s and %s", containerdDataDir, defaultContainerdConfigDir),
+	}
+}
+
 // stopContainerdServices stops and disables all containerd-related services
 func (u *UnInstaller) stopContainerdServices() error {
 	u.logger.Info("Stopping and disabling containerd service")
@@ -0,0 +1,20 @@
+package gpu
+
+const (
+	// Package of the NVIDIA container toolkit, named the same in NVIDIA's apt and rpm repositories
+	toolkitPackage = "nvidia-container-toolkit"
+
+	// nvidiaVendorID is the PCI vendor ID of NVIDIA devices
+	nvidiaVendorID = "0x10de"
+
+	// pciDevicesDir lists the PCI devices of the machine
+	pciDevicesDir = "/sys/bus/pci/devices"
+
+	// runtimeClassManifest lets pods select the nvidia containerd runtime handler
+	runtimeClassManifest = `apiVersion: node.k8s.io/v1
+kind: RuntimeClass
+metadata:
+  name: %[1]s
+handler: %[1]s
+`
+)
@@ -0,0 +1,235 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Installer sets up NVIDIA GPUs for pods: it installs or checks the driver, installs the NVIDIA container toolkit,
+// adds the nvidia runtime to containerd and creates the nvidia RuntimeClass pods select it with
+type Installer struct {
+	config        *config.Config
+	logger        *logrus.Logger
+	pciDevicesDir string
+	detectManager func() (packages.Manager, error)
+	listGPUs      func() ([]string, error)
+}
+
+// NewInstaller creates a new GPU Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config:        config.GetConfig(),
+		logger:        logger,
+		pciDevicesDir: pciDevicesDir,
+		detectManager: packages.DetectManager,
+		listGPUs:      listGPUs,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "GPU_Installer"
+}
+
+// Validate checks the machine has an NVIDIA GPU and that its driver is loaded or can be installed
+func (i *Installer) Validate(_ context.Context) error {
+	if !i.config.IsGPUEnabled() {
+		return nil
+	}
+	if !hasNvidiaDevice(i.pciDevicesDir) {
+		return fmt.Errorf("node.gpu.enabled is set, but no NVIDIA PCI device was found in %s", i.pciDevicesDir)
+	}
+	if _, err := i.listGPUs(); err != nil && len(i.config.Node.GPU.DriverPackages) == 0 {
+		return fmt.Errorf("no NVIDIA driver is loaded (%w); install the driver or set node.gpu.driverPackages to let the agent install it", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when GPUs are disabled, or the driver is loaded, the toolkit is installed and
+// containerd has the nvidia runtime
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	if !i.config.IsGPUEnabled() {
+		return true
+	}
+	if _, err := i.listGPUs(); err != nil {
+		return false
+	}
+	return fsutil.FileExists(containerd.NvidiaContainerRuntimePath) && containerd.HasGPURuntime()
+}
+
+// Plan describes the GPU setup for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	if !i.config.IsGPUEnabled() {
+		return nil
+	}
+	var actions []string
+	if _, err := i.listGPUs(); err != nil {
+		actions = append(actions, fmt.Sprintf("install NVIDIA driver packages %s and load the nvidia module", strings.Join(i.config.Node.GPU.DriverPackages, ", ")))
+	}
+	if !fsutil.FileExists(containerd.NvidiaContainerRuntimePath) {
+		actions = append(actions, "install package "+toolkitPackage)
+	}
+	if !containerd.HasGPURuntime() {
+		actions = append(actions, "add the nvidia runtime to the containerd configuration and restart containerd")
+	}
+	return append(actions, fmt.Sprintf("apply RuntimeClass %s to the cluster", config.GPURuntimeClass))
+}
+
+// Execute installs the driver when it is missing, the NVIDIA container toolkit and the nvidia runtime
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.IsGPUEnabled() {
+		return nil
+	}
+	i.logger.Info("Setting up NVIDIA GPUs")
+
+	manager, managerErr := i.detectManager()
+	if err := i.ensureDriver(ctx, manager, managerErr); err != nil {
+		return err
+	}
+	if !fsutil.FileExists(containerd.NvidiaContainerRuntimePath) {
+		if managerErr != nil {
+			return fmt.Errorf("cannot install %s: %w", toolkitPackage, managerErr)
+		}
+		if err := i.installPackages(ctx, manager, []string{toolkitPackage}); err != nil {
+			return err
+		}
+	}
+
+	if !containerd.HasGPURuntime() {
+		i.logger.Info("Adding the nvidia runtime to containerd")
+		if err := containerd.RepairConfig(i.logger); err != nil {
+			return fmt.Errorf("failed to add the nvidia runtime to containerd: %w", err)
+		}
+	}
+
+	// The RuntimeClass is shared by all GPU nodes; pods can still use the runtime once it exists, so only warn
+	if err := i.applyRuntimeClass(ctx); err != nil {
+		i.logger.Warnf("Failed to apply RuntimeClass %s, create it so pods can select the nvidia runtime: %v", config.GPURuntimeClass, err)
+	}
+
+	i.logger.Info("NVIDIA GPU setup completed")
+	return nil
+}
+
+// ensureDriver installs the configured driver packages and loads the driver when no driver is loaded
+func (i *Installer) ensureDriver(ctx context.Context, manager packages.Manager, managerErr error) error {
+	if gpus, err := i.listGPUs(); err == nil {
+		i.logger.Infof("NVIDIA driver is loaded with %d GPU(s): %s", len(gpus), strings.Join(gpus, "; "))
+		return nil
+	}
+
+	driverPackages := i.config.Node.GPU.DriverPackages
+	if len(driverPackages) == 0 {
+		return fmt.Errorf("no NVIDIA driver is loaded and node.gpu.driverPackages is not set")
+	}
+	if managerErr != nil {
+		return fmt.Errorf("cannot install NVIDIA driver packages %s: %w", strings.Join(driverPackages, ", "), managerErr)
+	}
+	if err := i.installPackages(ctx, manager, driverPackages); err != nil {
+		return err
+	}
+	if err := sysutil.RunSystemCommand("modprobe", "nvidia"); err != nil {
+		i.logger.Warnf("Failed to load the nvidia module: %v", err)
+	}
+	if _, err := i.listGPUs(); err != nil {
+		return fmt.Errorf("NVIDIA driver packages %s are installed but the driver is not loaded, reboot the machine and run the agent again: %w",
+			strings.Join(driverPackages, ", "), err)
+	}
+	return nil
+}
+
+// installPackages installs packages from the offline package directory or the package repositories, and records
+// the ones that were absent so unbootstrap removes them again
+func (i *Installer) installPackages(ctx context.Context, manager packages.Manager, pkgs []string) error {
+	absent := slices.DeleteFunc(slices.Clone(pkgs), manager.IsInstalled)
+
+	if offlineDir := i.config.Packages.OfflineDir; offlineDir != "" {
+		files, err := packages.OfflinePackageFiles(offlineDir, manager)
+		if err != nil {
+			return err
+		}
+		i.logger.Infof("Installing %s from %s with %s", strings.Join(pkgs, ", "), offlineDir, manager.Name())
+		if err := manager.InstallFiles(ctx, files); err != nil {
+			return fmt.Errorf("failed to install packages from %s: %w", offlineDir, err)
+		}
+	} else {
+		i.logger.Infof("Installing %s with %s", strings.Join(pkgs, ", "), manager.Name())
+		if err := manager.Install(ctx, pkgs); err != nil {
+			return fmt.Errorf("failed to install packages %s, check the NVIDIA package repository is configured: %w", strings.Join(pkgs, ", "), err)
+		}
+	}
+
+	installed := slices.DeleteFunc(absent, func(pkg string) bool { return !manager.IsInstalled(pkg) })
+	if len(installed) > 0 {
+		if err := packages.RecordInstalled(manager.Name(), installed); err != nil {
+			i.logger.Warnf("Failed to record installed packages %s, unbootstrap will keep them: %v", strings.Join(installed, ", "), err)
+		}
+	}
+	if missing := slices.DeleteFunc(slices.Clone(pkgs), manager.IsInstalled); len(missing) > 0 {
+		return fmt.Errorf("packages %s are still missing after installation", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// applyRuntimeClass creates the nvidia RuntimeClass in the cluster
+func (i *Installer) applyRuntimeClass(ctx context.Context) error {
+	adminKubeconfig, err := kubelet.WriteAdminKubeconfig(ctx, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	manifestFile, err := fsutil.CreateTempFile("nvidia-runtimeclass-*.yaml", []byte(fmt.Sprintf(runtimeClassManifest, config.GPURuntimeClass)))
+	if err != nil {
+		return fmt.Errorf("failed to write RuntimeClass manifest: %w", err)
+	}
+	_ = manifestFile.Close()
+	defer fsutil.CleanupTempFile(manifestFile.Name())
+
+	if _, err := sysutil.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig, "apply", "-f", manifestFile.Name()); err != nil {
+		return fmt.Errorf("failed to apply RuntimeClass: %w", err)
+	}
+	return nil
+}
+
+// listGPUs returns the GPUs the loaded NVIDIA driver reports, failing when no driver is loaded
+func listGPUs() ([]string, error) {
+	output, err := sysutil.RunCommandWithOutput("nvidia-smi", "-L")
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %w", err)
+	}
+	var gpus []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "GPU ") {
+			gpus = append(gpus, line)
+		}
+	}
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("nvidia-smi lists no GPUs")
+	}
+	return gpus, nil
+}
+
+// hasNvidiaDevice reports whether any PCI device in dir is made by NVIDIA
+func hasNvidiaDevice(dir string) bool {
+	vendors, _ := filepath.Glob(filepath.Join(dir, "*", "vendor"))
+	for _, vendor := range vendors {
+		if data, err := os.ReadFile(vendor); err == nil && strings.TrimSpace(string(data)) == nvidiaVendorID {
+			return true
+		}
+	}
+	return false
+}
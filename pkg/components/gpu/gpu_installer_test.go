@@ -0,0 +1,148 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+)
+
+// fakePackageManager records installs and removals without touching the host
+type fakePackageManager struct {
+	installed []string
+}
+
+func (m *fakePackageManager) Name() string                                 { return "fake" }
+func (m *fakePackageManager) IsInstalled(pkg string) bool                  { return slices.Contains(m.installed, pkg) }
+func (m *fakePackageManager) Dependents(string) []string                   { return nil }
+func (m *fakePackageManager) FileExtension() string                        { return ".deb" }
+func (m *fakePackageManager) InstallFiles(context.Context, []string) error { return nil }
+
+func (m *fakePackageManager) Install(_ context.Context, pkgs []string) error {
+	m.installed = append(m.installed, pkgs...)
+	return nil
+}
+
+func (m *fakePackageManager) Remove(_ context.Context, pkgs []string) error {
+	m.installed = slices.DeleteFunc(m.installed, func(pkg string) bool { return slices.Contains(pkgs, pkg) })
+	return nil
+}
+
+// useTempInstallRecord points the installed packages record to a temporary directory
+func useTempInstallRecord(t *testing.T) {
+	t.Helper()
+	original := packages.InstalledFilePath
+	packages.InstalledFilePath = filepath.Join(t.TempDir(), "installed-packages.json")
+	t.Cleanup(func() { packages.InstalledFilePath = original })
+}
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestHasNvidiaDevice(t *testing.T) {
+	dir := t.TempDir()
+	writeVendor := func(device, vendor string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, device), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, device, "vendor"), []byte(vendor+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeVendor("0000:00:02.0", "0x8086")
+	if hasNvidiaDevice(dir) {
+		t.Error("hasNvidiaDevice() = true with an Intel device only")
+	}
+	writeVendor("0000:01:00.0", "0x10de")
+	if !hasNvidiaDevice(dir) {
+		t.Error("hasNvidiaDevice() = false with an NVIDIA device")
+	}
+}
+
+func TestValidateRequiresDriverOrDriverPackages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "0000:01:00.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "0000:01:00.0", "vendor"), []byte("0x10de\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{GPU: &config.GPUConfig{Enabled: true}}}
+	installer := &Installer{
+		config:        cfg,
+		logger:        testLogger(),
+		pciDevicesDir: dir,
+		listGPUs:      func() ([]string, error) { return nil, errors.New("nvidia-smi not found") },
+	}
+	if err := installer.Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "node.gpu.driverPackages") {
+		t.Errorf("Validate() without driver = %v, want node.gpu.driverPackages hint", err)
+	}
+
+	cfg.Node.GPU.DriverPackages = []string{"nvidia-headless-550-server"}
+	if err := installer.Validate(context.Background()); err != nil {
+		t.Errorf("Validate() with driver packages unexpected error = %v", err)
+	}
+
+	installer.pciDevicesDir = t.TempDir()
+	if err := installer.Validate(context.Background()); err == nil || !strings.Contains(err.Error(), "no NVIDIA PCI device") {
+		t.Errorf("Validate() without NVIDIA device = %v, want device error", err)
+	}
+}
+
+func TestInstallPackagesRecordsOnlyAbsentPackages(t *testing.T) {
+	useTempInstallRecord(t)
+	manager := &fakePackageManager{installed: []string{"nvidia-utils-550-server"}}
+	installer := &Installer{config: &config.Config{}, logger: testLogger()}
+
+	if err := installer.installPackages(context.Background(), manager, []string{"nvidia-utils-550-server", toolkitPackage}); err != nil {
+		t.Fatalf("installPackages() unexpected error = %v", err)
+	}
+	record, err := packages.LoadInstalled()
+	if err != nil || record == nil || !slices.Equal(record.Packages, []string{toolkitPackage}) {
+		t.Errorf("install record = %+v, %v, want only %s", record, err, toolkitPackage)
+	}
+}
+
+func TestUnInstallerRemovesOnlyGPUPackages(t *testing.T) {
+	useTempInstallRecord(t)
+	if err := packages.RecordInstalled("fake", []string{"jq", toolkitPackage, "nvidia-headless-550-server"}); err != nil {
+		t.Fatal(err)
+	}
+	manager := &fakePackageManager{installed: []string{"jq", toolkitPackage, "nvidia-headless-550-server"}}
+	uninstaller := &UnInstaller{
+		config:        &config.Config{Node: config.NodeConfig{GPU: &config.GPUConfig{Enabled: true, DriverPackages: []string{"nvidia-headless-550-server"}}}},
+		logger:        testLogger(),
+		detectManager: func() (packages.Manager, error) { return manager, nil },
+	}
+
+	if uninstaller.IsCompleted(context.Background()) {
+		t.Fatal("IsCompleted() = true with GPU packages recorded")
+	}
+	if err := uninstaller.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error = %v", err)
+	}
+	if !slices.Equal(manager.installed, []string{"jq"}) {
+		t.Errorf("installed packages after Execute() = %v, want [jq]", manager.installed)
+	}
+	if record, _ := packages.LoadInstalled(); record == nil || !slices.Equal(record.Packages, []string{"jq"}) {
+		t.Errorf("install record after Execute() = %+v, want [jq] left for the package uninstaller", record)
+	}
+	if !uninstaller.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = false after Execute()")
+	}
+}
@@ -0,0 +1,83 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+)
+
+// UnInstaller removes the NVIDIA container toolkit and driver packages the GPU installer installed, before
+// containerd is removed. Packages found present at bootstrap are kept, as is the RuntimeClass other GPU nodes use.
+type UnInstaller struct {
+	config        *config.Config
+	logger        *logrus.Logger
+	detectManager func() (packages.Manager, error)
+}
+
+// NewUnInstaller creates a new GPU UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config:        config.GetConfig(),
+		logger:        logger,
+		detectManager: packages.DetectManager,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "GPU_UnInstaller"
+}
+
+// IsCompleted returns true when no GPU packages installed by the agent are recorded
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return len(u.installedPackages()) == 0
+}
+
+// Plan describes the GPU packages Execute would remove, for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	installed := u.installedPackages()
+	if len(installed) == 0 {
+		return nil
+	}
+	return []string{"remove packages " + strings.Join(installed, ", ")}
+}
+
+// Execute removes the recorded GPU packages
+func (u *UnInstaller) Execute(ctx context.Context) error {
+	installed := u.installedPackages()
+	if len(installed) == 0 {
+		return nil
+	}
+	manager, err := u.detectManager()
+	if err != nil {
+		return fmt.Errorf("cannot remove GPU packages %s: %w", strings.Join(installed, ", "), err)
+	}
+
+	remove := slices.DeleteFunc(slices.Clone(installed), func(pkg string) bool { return !manager.IsInstalled(pkg) })
+	if len(remove) > 0 {
+		u.logger.Infof("Removing GPU packages installed by the agent with %s: %s", manager.Name(), strings.Join(remove, ", "))
+		if err := manager.Remove(ctx, remove); err != nil {
+			return fmt.Errorf("failed to remove GPU packages %s: %w", strings.Join(remove, ", "), err)
+		}
+	}
+	return packages.ForgetInstalled(installed)
+}
+
+// installedPackages returns the GPU packages the agent recorded installing
+func (u *UnInstaller) installedPackages() []string {
+	record, err := packages.LoadInstalled()
+	if err != nil || record == nil {
+		return nil
+	}
+	gpuPackages := []string{toolkitPackage}
+	if u.config.Node.GPU != nil {
+		gpuPackages = append(gpuPackages, u.config.Node.GPU.DriverPackages...)
+	}
+	return slices.DeleteFunc(slices.Clone(record.Packages), func(pkg string) bool { return !slices.Contains(gpuPackages, pkg) })
+}
@@ -17,12 +17,14 @@ const (
 	// Repository files (these might be used externally, keeping uppercase for now)
 	KubernetesRepoList = "/etc/apt/sources.list.d/kubernetes.list"
 	KubernetesKeyring  = "/etc/apt/keyrings/kubernetes-apt-keyring.gpg"
+
+	// Component name used for provenance records
+	provenanceComponent = "kubernetes"
 )
 
 var (
-	kubernetesFileName           = "kubernetes-node-linux-%s.tar.gz"
-	defaultKubernetesURLTemplate = "https://acs-mirror.azureedge.net/kubernetes/v%s/binaries/kubernetes-node-linux-%s.tar.gz"
-	kubernetesTarPath            = "kubernetes/node/bin/"
+	kubernetesFileName = "kubernetes-node-linux-%s.tar.gz"
+	kubernetesTarPath  = "kubernetes/node/bin/"
 )
 
 var kubeBinariesPaths = []string{
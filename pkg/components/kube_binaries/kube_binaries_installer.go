@@ -7,6 +7,9 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -29,7 +32,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Infof("Installing Kube Binaries of version %s", i.config.GetKubernetesVersion())
 
 	// Download and install Kubernetes binaries
-	if err := i.installKubeBinaries(); err != nil {
+	if err := i.installKubeBinaries(ctx); err != nil {
 		return fmt.Errorf("failed to install Kubernetes: %w", err)
 	}
 
@@ -37,7 +40,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) installKubeBinaries() error {
+func (i *Installer) installKubeBinaries(ctx context.Context) error {
 	// Clean up any corrupted installations before proceeding
 	i.logger.Info("Cleaning up corrupted Kubernetes installation files to start fresh")
 	if err := i.cleanupExistingInstallation(); err != nil {
@@ -51,11 +54,14 @@ func (i *Installer) installKubeBinaries() error {
 		return fmt.Errorf("failed to construct Kubernetes download URL: %w", err)
 	}
 
-	// Download the Kubernetes tar file into tmp directory
-	tempFile := fmt.Sprintf("/tmp/%s", fileName)
-	// Clean up any existing Kubernetes temp files from /tmp directory to avoid conflicts
+	// Download the Kubernetes tar file into the staging directory
+	tempFile, err := utils.StagingPath(i.config.Paths.StagingDir, fileName)
+	if err != nil {
+		return err
+	}
+	// Clean up any existing Kubernetes temp files from the staging directory to avoid conflicts
 	if err := utils.RunSystemCommand("bash", "-c", fmt.Sprintf("rm -f %s", tempFile)); err != nil {
-		logrus.Warnf("Failed to clean up existing Kubernetes temp files from /tmp: %s", err)
+		logrus.Warnf("Failed to clean up existing Kubernetes temp files from the staging directory: %s", err)
 	}
 	defer func() {
 		if err := utils.RunCleanupCommand(tempFile); err != nil {
@@ -65,13 +71,13 @@ func (i *Installer) installKubeBinaries() error {
 
 	// Download Kube binaries with validation
 	i.logger.Infof("Downloading Kube binaries from %s into %s", url, tempFile)
-	if err := utils.DownloadFile(url, tempFile); err != nil {
+	if err := downloadcache.New(i.config, i.logger).FetchVerified(ctx, url, tempFile, i.config.Kubernetes.Checksum); err != nil {
 		return fmt.Errorf("failed to download Kube binaries from %s: %w", url, err)
 	}
 
 	// Extract Kubernetes binaries directly to binDir, stripping the 'kubernetes/node/bin/' prefix
 	i.logger.Infof("Extracting Kubernetes binaries to %s", binDir)
-	if err := utils.RunSystemCommand("tar", "-C", binDir, "--strip-components=3", "-xzf", tempFile, kubernetesTarPath); err != nil {
+	if err := utils.RunSystemCommandContext(ctx, "tar", "-C", binDir, "--strip-components=3", "-xzf", tempFile, kubernetesTarPath); err != nil {
 		return fmt.Errorf("failed to extract Kubernetes binaries: %w", err)
 	}
 
@@ -83,6 +89,9 @@ func (i *Installer) installKubeBinaries() error {
 		}
 	}
 
+	if err := provenance.RecordInstall(provenanceComponent, i.config.GetKubernetesVersion(), url, tempFile); err != nil {
+		i.logger.Warnf("Failed to record Kubernetes binaries provenance: %v", err)
+	}
 	return nil
 }
 
@@ -100,11 +109,24 @@ func (i *Installer) Validate(ctx context.Context) error {
 	// Verify network connectivity for download (basic check)
 	kubernetesVersion := i.config.GetKubernetesVersion()
 	if kubernetesVersion == "" {
-		return fmt.Errorf("kubernetes version not specified")
+		return fmt.Errorf("kubernetes version not specified and not resolved from the cluster, the cluster preflight could not read the cluster's version")
 	}
 	return nil
 }
 
+// Plan describes the Kube binaries installation for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	_, url, err := i.constructKubeBinariesDownloadURL()
+	if err != nil {
+		url = "the Kubernetes release"
+	}
+	return []string{
+		"stop running kubelet processes",
+		fmt.Sprintf("download Kubernetes %s from %s", i.config.GetKubernetesVersion(), url),
+		"replace " + strings.Join(kubeBinariesPaths, ", "),
+	}
+}
+
 // canSkipKubernetesInstallation checks if all Kube binaries are installed with the correct version
 func (i *Installer) canSkipKubernetesInstallation() bool {
 	for _, binaryPath := range kubeBinariesPaths {
@@ -126,6 +148,12 @@ func (i *Installer) canSkipKubernetesInstallation() bool {
 
 // isKubeletVersionCorrect checks if the installed kubelet version matches the expected version
 func (i *Installer) isKubeletVersionCorrect() bool {
+	expected := i.config.GetKubernetesVersion()
+	if expected == "" {
+		// An unresolved "auto" version matches any kubelet
+		i.logger.Debugf("Expected kubelet version is not resolved")
+		return false
+	}
 	output, err := utils.RunCommandWithOutput(kubeletPath, "--version")
 	if err != nil {
 		i.logger.Debugf("Failed to get kubelet version: %v", err)
@@ -133,7 +161,7 @@ func (i *Installer) isKubeletVersionCorrect() bool {
 	}
 
 	// Check if version output contains expected version
-	return strings.Contains(string(output), i.config.GetKubernetesVersion())
+	return strings.Contains(string(output), expected)
 }
 
 // cleanupExistingInstallation removes any existing Kubernetes installation that may be corrupted
@@ -180,7 +208,7 @@ func (i *Installer) getKubernetesURLTemplate() string {
 		return i.config.Kubernetes.URLTemplate
 	}
 	// Default URL template for Kubernetes binaries
-	return defaultKubernetesURLTemplate
+	return defaults.Get().URLs.Kubernetes
 }
 
 // GetName returns the step name
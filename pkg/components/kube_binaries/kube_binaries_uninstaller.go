@@ -2,8 +2,10 @@ package kube_binaries
 
 import (
 	"context"
+	"strings"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -40,6 +42,10 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 		}
 	}
 
+	if err := provenance.Remove(provenanceComponent); err != nil {
+		u.logger.Debugf("Failed to remove Kubernetes binaries provenance record: %v", err)
+	}
+
 	u.logger.Info("Kubernetes binaries removal completed")
 	return nil
 }
@@ -48,3 +54,8 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 	return !utils.BinaryExists(kubeletBinary)
 }
+
+// Plan describes the Kube binaries removal for dry runs
+func (u *UnInstaller) Plan(ctx context.Context) []string {
+	return []string{"remove " + strings.Join(kubeBinariesPaths, ", ")}
+}
@@ -0,0 +1,41 @@
+package kube_proxy
+
+import "time"
+
+const (
+	// kube-proxy binary, extracted with the other binaries of the Kubernetes node archive
+	kubeProxyBinaryPath = "/usr/local/bin/kube-proxy"
+
+	// Configuration and kubeconfig kube-proxy runs with
+	kubeProxyDir            = "/var/lib/kube-proxy"
+	kubeProxyConfigPath     = "/var/lib/kube-proxy/config.yaml"
+	kubeProxyKubeconfigPath = "/var/lib/kube-proxy/kubeconfig"
+	kubeProxyTokenPath      = "/var/lib/kube-proxy/token"
+
+	kubeProxyServiceName = "kube-proxy"
+	kubeProxyServicePath = "/etc/systemd/system/kube-proxy.service"
+
+	// Kernel modules of ipvs mode, loaded again at boot
+	ipvsModulesPath = "/etc/modules-load.d/kube-proxy-ipvs.conf"
+
+	// In-cluster objects shared by every node running kube-proxy through the agent
+	kubeProxyNamespace      = "kube-system"
+	kubeProxyServiceAccount = "aks-flex-node-kube-proxy"
+
+	// legacyTokenSecret held the non-expiring service account token of earlier versions, deleted to revoke it
+	legacyTokenSecret = "aks-flex-node-kube-proxy-token"
+
+	// Pods of the cluster kube-proxy DaemonSet, which must not run next to the agent's kube-proxy
+	daemonSetPodSelector = "component=kube-proxy"
+
+	// nftables mode is enabled by default from this Kubernetes minor version
+	minNFTablesMinor = 31
+
+	// kube-proxy authenticates with a bound service account token, renewed by a bootstrap once half of its
+	// lifetime passed. client-go reloads the token file, so kube-proxy picks up a renewed token while running.
+	tokenLifetime    = 48 * time.Hour
+	tokenRenewBefore = 24 * time.Hour
+)
+
+// ipvsModules are the kernel modules kube-proxy needs in ipvs mode
+var ipvsModules = []string{"ip_vs", "ip_vs_rr", "ip_vs_wrr", "ip_vs_sh", "nf_conntrack"}
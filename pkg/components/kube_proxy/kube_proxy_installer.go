@@ -0,0 +1,313 @@
+package kube_proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Installer runs kube-proxy as a systemd service, so Services work on nodes the cluster kube-proxy DaemonSet
+// does not schedule onto
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	// clusterCIDR is resolved once, IsCompleted and Execute of a bootstrap both need it
+	clusterCIDR *string
+}
+
+// NewInstaller creates a new kube-proxy Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "KubeProxy_Installer"
+}
+
+// Validate checks the Kubernetes node archive brought kube-proxy and the host supports the proxy mode
+func (i *Installer) Validate(_ context.Context) error {
+	if !i.config.KubeProxy.Enabled {
+		return nil
+	}
+	if !fsutil.FileExists(kubeProxyBinaryPath) {
+		return fmt.Errorf("kube-proxy binary %s not found, the Kubernetes node archive of %s does not include it",
+			kubeProxyBinaryPath, i.config.GetKubernetesVersion())
+	}
+
+	switch i.config.KubeProxy.Mode {
+	case config.KubeProxyModeIPVS:
+		if !sysutil.BinaryExists("ipset") {
+			return fmt.Errorf("kubeProxy.mode ipvs requires the ipset command, add ipset to packages.additional")
+		}
+	case config.KubeProxyModeNFTables:
+		minor, err := compat.MinorVersion(i.config.GetKubernetesVersion())
+		if err != nil {
+			return fmt.Errorf("failed to check the Kubernetes version supports kubeProxy.mode nftables: %w", err)
+		}
+		if minor < minNFTablesMinor {
+			return fmt.Errorf("kubeProxy.mode nftables requires Kubernetes 1.%d or newer, the node runs %s",
+				minNFTablesMinor, i.config.GetKubernetesVersion())
+		}
+	}
+	return nil
+}
+
+// IsCompleted returns true when kube-proxy is disabled, or runs the current configuration with a token that is
+// not due for renewal
+func (i *Installer) IsCompleted(ctx context.Context) bool {
+	if !i.config.KubeProxy.Enabled {
+		return true
+	}
+	if !fsutil.FileExists(kubeProxyKubeconfigPath) || tokenDue(time.Now()) {
+		return false
+	}
+	for path, want := range map[string]string{
+		kubeProxyServicePath: renderServiceUnit(),
+		kubeProxyConfigPath:  renderConfig(i.config.KubeProxy.Mode, i.config.GetNodeName(), i.resolveClusterCIDR(ctx)),
+	} {
+		existing, err := os.ReadFile(path)
+		if err != nil || string(existing) != want {
+			return false
+		}
+	}
+	return sysutil.IsServiceActive(kubeProxyServiceName)
+}
+
+// Plan describes the kube-proxy installation for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	if !i.config.KubeProxy.Enabled {
+		return nil
+	}
+	actions := []string{
+		fmt.Sprintf("apply service account %s bound to system:node-proxier in namespace %s", kubeProxyServiceAccount, kubeProxyNamespace),
+		fmt.Sprintf("request a service account token valid for %s and write it to %s", tokenLifetime, kubeProxyTokenPath),
+		fmt.Sprintf("write %s, %s and %s", kubeProxyKubeconfigPath, kubeProxyConfigPath, kubeProxyServicePath),
+	}
+	if i.config.KubeProxy.Mode == config.KubeProxyModeIPVS {
+		actions = append(actions, fmt.Sprintf("load kernel modules %s and write %s", strings.Join(ipvsModules, ", "), ipvsModulesPath))
+	}
+	return append(actions, fmt.Sprintf("enable and restart kube-proxy in %s mode", i.config.KubeProxy.Mode))
+}
+
+// Execute creates the kube-proxy credentials, writes its configuration and (re)starts the service
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.KubeProxy.Enabled {
+		return nil
+	}
+	i.logger.Infof("Installing kube-proxy in %s mode", i.config.KubeProxy.Mode)
+
+	adminKubeconfig, err := kubelet.WriteAdminKubeconfig(ctx, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	if err := i.applyClusterResources(adminKubeconfig); err != nil {
+		return err
+	}
+	i.warnDaemonSetPod(adminKubeconfig)
+
+	if err := sysutil.RunSystemCommand("mkdir", "-p", kubeProxyDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", kubeProxyDir, err)
+	}
+	if err := i.createKubeconfig(ctx, adminKubeconfig); err != nil {
+		return err
+	}
+	if i.config.KubeProxy.Mode == config.KubeProxyModeIPVS {
+		if err := loadIPVSModules(); err != nil {
+			return err
+		}
+	}
+
+	proxyConfig := renderConfig(i.config.KubeProxy.Mode, i.config.GetNodeName(), i.resolveClusterCIDR(ctx))
+	if err := fsutil.WriteFileAtomicSystem(kubeProxyConfigPath, []byte(proxyConfig), 0o644); err != nil {
+		return fmt.Errorf("failed to write kube-proxy configuration: %w", err)
+	}
+	if err := fsutil.WriteFileAtomicSystem(kubeProxyServicePath, []byte(renderServiceUnit()), 0o644); err != nil {
+		return fmt.Errorf("failed to write kube-proxy service file: %w", err)
+	}
+
+	if err := sysutil.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	if err := sysutil.EnableAndStartService(kubeProxyServiceName); err != nil {
+		return fmt.Errorf("failed to enable and start kube-proxy: %w", err)
+	}
+	// Restart kube-proxy to pick up a changed configuration or mode
+	if err := sysutil.RestartService(kubeProxyServiceName); err != nil {
+		return fmt.Errorf("failed to restart kube-proxy: %w", err)
+	}
+
+	i.logger.Infof("kube-proxy is running in %s mode", i.config.KubeProxy.Mode)
+	return nil
+}
+
+// applyClusterResources applies the kube-proxy service account and role binding to the cluster and deletes the
+// non-expiring token secret of earlier versions
+func (i *Installer) applyClusterResources(adminKubeconfig string) error {
+	manifestFile, err := fsutil.CreateTempFile("kube-proxy-*.yaml", []byte(clusterResourcesManifest))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary kube-proxy manifest: %w", err)
+	}
+	_ = manifestFile.Close()
+	defer fsutil.CleanupTempFile(manifestFile.Name())
+
+	if _, err := sysutil.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig, "apply", "-f", manifestFile.Name()); err != nil {
+		return fmt.Errorf("failed to apply kube-proxy cluster resources: %w", err)
+	}
+	if _, err := sysutil.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig, "delete", "secret", legacyTokenSecret,
+		"-n", kubeProxyNamespace, "--ignore-not-found"); err != nil {
+		i.logger.Warnf("Failed to delete the non-expiring kube-proxy token secret %s/%s: %v", kubeProxyNamespace, legacyTokenSecret, err)
+	}
+	return nil
+}
+
+// warnDaemonSetPod warns when the cluster kube-proxy DaemonSet also runs on this node, as both would program
+// the same rules and the second one cannot bind the health check port
+func (i *Installer) warnDaemonSetPod(adminKubeconfig string) {
+	output, err := sysutil.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig, "get", "pods", "-n", kubeProxyNamespace,
+		"-l", daemonSetPodSelector, "--field-selector", "spec.nodeName="+i.config.GetNodeName(), "-o", "name")
+	if err != nil {
+		i.logger.Debugf("Failed to look for kube-proxy DaemonSet pods on this node: %v", err)
+		return
+	}
+	if pods := strings.TrimSpace(output); pods != "" {
+		i.logger.Warnf("The cluster kube-proxy DaemonSet runs on this node (%s); disable kubeProxy.enabled or keep the DaemonSet off flex nodes",
+			strings.Join(strings.Fields(pods), ", "))
+	}
+}
+
+// createKubeconfig requests a bound token of the kube-proxy service account and writes it with the kubeconfig
+// reading it
+func (i *Installer) createKubeconfig(ctx context.Context, adminKubeconfig string) error {
+	adminData, err := sysutil.RunCommandWithOutput("cat", adminKubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster credentials: %w", err)
+	}
+	serverURL, caCertData, err := utils.ExtractClusterInfo([]byte(adminData))
+	if err != nil {
+		return fmt.Errorf("failed to extract cluster info from kubeconfig: %w", err)
+	}
+
+	if err := requestToken(ctx, adminKubeconfig); err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomicSystem(kubeProxyKubeconfigPath, []byte(renderKubeconfig(serverURL, caCertData)), 0o600); err != nil {
+		return fmt.Errorf("failed to write kube-proxy kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// RenewToken requests a new token for the kube-proxy the agent runs once its token is due for renewal. kube-proxy
+// reads the renewed token file without a restart. It returns whether the token was renewed.
+func RenewToken(ctx context.Context, cfg *config.Config, logger *logrus.Logger) (bool, error) {
+	if !cfg.KubeProxy.Enabled || !fsutil.FileExists(kubeProxyKubeconfigPath) || !tokenDue(time.Now()) {
+		return false, nil
+	}
+	adminKubeconfig, err := kubelet.WriteAdminKubeconfig(ctx, logger)
+	if err != nil {
+		return false, fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	if err := requestToken(ctx, adminKubeconfig); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// requestToken requests a bound token of the kube-proxy service account and writes it to the token file
+func requestToken(ctx context.Context, adminKubeconfig string) error {
+	token, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", adminKubeconfig, "create", "token", kubeProxyServiceAccount,
+		"-n", kubeProxyNamespace, "--duration="+tokenLifetime.String())
+	if err != nil {
+		return fmt.Errorf("failed to request a token for service account %s/%s: %w", kubeProxyNamespace, kubeProxyServiceAccount, err)
+	}
+	if err := fsutil.WriteFileAtomicSystem(kubeProxyTokenPath, []byte(strings.TrimSpace(token)), 0o600); err != nil {
+		return fmt.Errorf("failed to write kube-proxy token: %w", err)
+	}
+	return nil
+}
+
+// tokenDue reports whether the kube-proxy token is missing or expires within tokenRenewBefore. The API server
+// may shorten the requested lifetime, so the expiry is read from the token.
+func tokenDue(now time.Time) bool {
+	token, err := sysutil.RunCommandWithOutput("cat", kubeProxyTokenPath)
+	if err != nil {
+		return true
+	}
+	expiry, err := tokenExpiry(strings.TrimSpace(token))
+	return err != nil || expiry.Sub(now) < tokenRenewBefore
+}
+
+// tokenExpiry returns the expiry of a service account token from the exp claim of the JWT
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("the token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode the token claims: %w", err)
+	}
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse the token claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("the token does not expire")
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// resolveClusterCIDR returns the pod CIDR of the cluster, or the pod CIDR of the node when the cluster spec is
+// not available
+func (i *Installer) resolveClusterCIDR(ctx context.Context) string {
+	if i.clusterCIDR != nil {
+		return *i.clusterCIDR
+	}
+	cidr := i.config.GetPodCIDR()
+	clusterSpec, err := spec.NewCollector(i.logger).Collect(ctx)
+	switch {
+	case err != nil:
+		i.logger.Debugf("Unable to read the cluster pod CIDR, using the node pod CIDR for kube-proxy: %v", err)
+	case clusterSpec.PodCIDR != "":
+		cidr = clusterSpec.PodCIDR
+	}
+	i.clusterCIDR = &cidr
+	return cidr
+}
+
+// loadIPVSModules loads the kernel modules of ipvs mode and has them loaded at boot
+func loadIPVSModules() error {
+	for _, module := range ipvsModules {
+		if err := sysutil.RunSystemCommand("modprobe", module); err != nil {
+			return fmt.Errorf("failed to load kernel module %s for kube-proxy ipvs mode: %w", module, err)
+		}
+	}
+	if err := fsutil.WriteFileAtomicSystem(ipvsModulesPath, []byte(strings.Join(ipvsModules, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ipvsModulesPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package kube_proxy
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/internal/golden"
+)
+
+func TestRenderConfig(t *testing.T) {
+	golden.Assert(t, "kube-proxy-config-ipvs", renderConfig("ipvs", "edge-node-01", "10.244.0.0/16"))
+	golden.Assert(t, "kube-proxy-config-without-cluster-cidr", renderConfig("iptables", "edge-node-01", ""))
+}
+
+func TestRenderServiceUnit(t *testing.T) {
+	golden.Assert(t, "kube-proxy-service", renderServiceUnit())
+}
+
+func TestRenderKubeconfig(t *testing.T) {
+	golden.Assert(t, "kube-proxy-kubeconfig", renderKubeconfig("https://cluster.hcp.eastus.azmk8s.io:443", "Y2EtZGF0YQ=="))
+}
+
+func TestTokenExpiry(t *testing.T) {
+	claims := func(payload string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "bound token", token: claims(`{"aud":["https://kubernetes.default.svc"],"exp":1767225600}`), want: time.Unix(1767225600, 0)},
+		{name: "legacy token without expiry", token: claims(`{"iss":"kubernetes/serviceaccount"}`), wantErr: "does not expire"},
+		{name: "not a JWT", token: "abcdef", wantErr: "not a JWT"},
+		{name: "malformed claims", token: "a.!!!.c", wantErr: "failed to decode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenExpiry(tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("tokenExpiry() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenExpiry() unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("tokenExpiry() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
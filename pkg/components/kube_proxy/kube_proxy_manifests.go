@@ -0,0 +1,82 @@
+package kube_proxy
+
+import "fmt"
+
+// clusterResourcesManifest holds the service account kube-proxy authenticates as and its binding to
+// system:node-proxier, the role the API server bootstraps for kube-proxy
+const clusterResourcesManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: aks-flex-node-kube-proxy
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: aks-flex-node-kube-proxy
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: system:node-proxier
+subjects:
+- kind: ServiceAccount
+  name: aks-flex-node-kube-proxy
+  namespace: kube-system
+`
+
+// renderConfig renders the KubeProxyConfiguration of the node. Without a cluster CIDR kube-proxy cannot tell
+// pod traffic from other traffic, so it is only left out when the pod CIDR of the cluster is unknown.
+func renderConfig(mode, nodeName, clusterCIDR string) string {
+	var cidr string
+	if clusterCIDR != "" {
+		cidr = fmt.Sprintf("clusterCIDR: %s\n", clusterCIDR)
+	}
+	return fmt.Sprintf(`apiVersion: kubeproxy.config.k8s.io/v1alpha1
+kind: KubeProxyConfiguration
+clientConnection:
+  kubeconfig: %s
+hostnameOverride: %s
+mode: %s
+%smetricsBindAddress: 127.0.0.1:10249
+`, kubeProxyKubeconfigPath, nodeName, mode, cidr)
+}
+
+// renderServiceUnit renders the systemd unit running kube-proxy
+func renderServiceUnit() string {
+	return fmt.Sprintf(`[Unit]
+Description=Kubernetes network proxy
+Documentation=https://kubernetes.io/docs/reference/command-line-tools-reference/kube-proxy/
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=%s --config=%s
+Restart=always
+RestartSec=5s
+
+[Install]
+WantedBy=multi-user.target
+`, kubeProxyBinaryPath, kubeProxyConfigPath)
+}
+
+// renderKubeconfig renders the kubeconfig kube-proxy authenticates with, reading its token from the token file
+func renderKubeconfig(serverURL, caCertData string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: %s
+  name: cluster
+contexts:
+- context:
+    cluster: cluster
+    user: kube-proxy
+  name: kube-proxy
+current-context: kube-proxy
+users:
+- name: kube-proxy
+  user:
+    tokenFile: %s
+`, caCertData, serverURL, kubeProxyTokenPath)
+}
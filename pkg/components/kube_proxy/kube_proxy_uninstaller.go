@@ -0,0 +1,84 @@
+package kube_proxy
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller stops the kube-proxy the agent runs and removes its rules and files.
+// The in-cluster objects are shared with other nodes and are left in place.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new kube-proxy UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *UnInstaller) GetName() string {
+	return "KubeProxy_UnInstaller"
+}
+
+// Execute stops kube-proxy, removes the Service rules it programmed and its files
+func (u *UnInstaller) Execute(_ context.Context) error {
+	u.logger.Info("Removing kube-proxy")
+
+	// Only a kube-proxy the agent set up is stopped and its rules removed
+	if fsutil.FileExists(kubeProxyServicePath) {
+		if err := sysutil.StopService(kubeProxyServiceName); err != nil {
+			u.logger.Warnf("Failed to stop kube-proxy: %v", err)
+		}
+		if err := sysutil.DisableService(kubeProxyServiceName); err != nil {
+			u.logger.Warnf("Failed to disable kube-proxy: %v", err)
+		}
+		// kube-proxy removes the iptables, ipvs and nftables rules of every mode it knows
+		if err := sysutil.RunSystemCommand(kubeProxyBinaryPath, "--cleanup"); err != nil {
+			u.logger.Warnf("Failed to clean up kube-proxy rules: %v", err)
+		}
+	}
+
+	for _, path := range []string{kubeProxyServicePath, ipvsModulesPath, kubeProxyBinaryPath} {
+		if err := fsutil.RunCleanupCommand(path); err != nil {
+			u.logger.Debugf("Failed to remove %s: %v (may not exist)", path, err)
+		}
+	}
+	if errs := fsutil.RemoveDirectories([]string{kubeProxyDir}, u.logger); len(errs) > 0 {
+		u.logger.Debugf("Failed to remove kube-proxy directory %s: %v", kubeProxyDir, errs)
+	}
+	if err := sysutil.ReloadSystemd(); err != nil {
+		u.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+
+	u.logger.Info("kube-proxy removed successfully")
+	return nil
+}
+
+// IsCompleted returns true when neither the kube-proxy service nor its kubeconfig is present
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return !fsutil.FileExists(kubeProxyServicePath) && !fsutil.FileExists(kubeProxyKubeconfigPath)
+}
+
+// Plan describes the kube-proxy removal for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{
+		"stop and disable kube-proxy",
+		"remove the Service rules of kube-proxy",
+		"remove " + kubeProxyServicePath + ", " + kubeProxyBinaryPath + " and " + kubeProxyDir,
+	}
+}
+
+// SecretFiles returns the kube-proxy files holding credentials
+func SecretFiles() []string {
+	return []string{kubeProxyKubeconfigPath, kubeProxyTokenPath}
+}
@@ -0,0 +1,26 @@
+package kube_vip
+
+import "time"
+
+const (
+	// Static pod manifest picked up by kubelet from its --pod-manifest-path
+	kubeVIPManifestPath = "/etc/kubernetes/manifests/kube-vip.yaml"
+
+	// Kubeconfig with the kube-vip service account token, mounted into the static pod
+	kubeVIPConfigDir      = "/etc/kube-vip"
+	kubeVIPKubeconfigPath = "/etc/kube-vip/kubeconfig"
+
+	// In-cluster objects shared by every node running kube-vip
+	kubeVIPNamespace         = "kube-system"
+	kubeVIPServiceAccount    = "kube-vip"
+	kubeVIPTokenSecret       = "kube-vip-token"
+	kubeVIPConfigMap         = "kubevip"
+	kubeVIPLoadBalancerClass = "kube-vip.io/kube-vip-class"
+
+	// Waiting for the token controller to populate the service account token secret
+	tokenPollInterval = 2 * time.Second
+	tokenPollTimeout  = 60 * time.Second
+
+	// Routing table read to find the interface of the default route
+	procNetRoutePath = "/proc/net/route"
+)
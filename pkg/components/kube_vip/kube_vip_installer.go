@@ -0,0 +1,238 @@
+package kube_vip
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Installer installs kube-vip as a static pod so Services of type LoadBalancer get addresses
+// from a local range at sites without an Azure load balancer
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new kube-vip Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "KubeVIP_Installer"
+}
+
+// Validate checks the interface VIPs are announced on exists on this machine
+func (i *Installer) Validate(_ context.Context) error {
+	if !i.config.KubeVIP.Enabled {
+		return nil
+	}
+	interfaceName, err := i.getInterface()
+	if err != nil {
+		return err
+	}
+	if _, err := net.InterfaceByName(interfaceName); err != nil {
+		return fmt.Errorf("kube-vip interface %s not found: %w", interfaceName, err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when kube-vip is disabled or its static pod manifest is up to date
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	if !i.config.KubeVIP.Enabled {
+		return true
+	}
+	if !utils.FileExists(kubeVIPKubeconfigPath) {
+		return false
+	}
+	manifest, err := i.renderManifest()
+	if err != nil {
+		return false
+	}
+	existing, err := utils.RunCommandWithOutput("cat", kubeVIPManifestPath)
+	return err == nil && existing == manifest
+}
+
+// Plan describes the kube-vip installation for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	if !i.config.KubeVIP.Enabled {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("apply the kube-vip service account, cloud provider and address pool %s to namespace %s", i.config.KubeVIP.AddressRange, kubeVIPNamespace),
+		"write " + kubeVIPKubeconfigPath,
+		fmt.Sprintf("write static pod %s running %s", kubeVIPManifestPath, i.getImage()),
+	}
+}
+
+// Execute creates the shared in-cluster objects, writes the kube-vip kubeconfig and installs the static pod
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.KubeVIP.Enabled {
+		return nil
+	}
+	i.logger.Infof("Installing kube-vip %s for LoadBalancer address range %s", i.getImage(), i.config.KubeVIP.AddressRange)
+
+	adminKubeconfig, err := kubelet.WriteAdminKubeconfig(ctx, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	defer utils.CleanupTempFile(adminKubeconfig)
+
+	if err := i.applyClusterResources(adminKubeconfig); err != nil {
+		return err
+	}
+
+	if err := i.createKubeconfig(ctx, adminKubeconfig); err != nil {
+		return err
+	}
+
+	manifest, err := i.renderManifest()
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomicSystem(kubeVIPManifestPath, []byte(manifest), 0o644); err != nil {
+		return fmt.Errorf("failed to write kube-vip static pod manifest: %w", err)
+	}
+
+	i.logger.Infof("kube-vip static pod manifest written to %s", kubeVIPManifestPath)
+	return nil
+}
+
+// applyClusterResources applies the kube-vip RBAC, cloud provider and address pool to the cluster
+func (i *Installer) applyClusterResources(adminKubeconfig string) error {
+	manifestFile, err := utils.CreateTempFile("kube-vip-*.yaml", []byte(renderClusterResources()))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary kube-vip manifest: %w", err)
+	}
+	_ = manifestFile.Close()
+	defer utils.CleanupTempFile(manifestFile.Name())
+
+	if _, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig, "apply", "-f", manifestFile.Name()); err != nil {
+		return fmt.Errorf("failed to apply kube-vip cluster resources: %w", err)
+	}
+
+	// Patch only this node's pool key so ranges configured by other sites are preserved
+	patch, err := json.Marshal(map[string]map[string]string{
+		"data": {addressPoolKey(i.config.KubeVIP.ServiceNamespace): normalizeAddressRange(i.config.KubeVIP.AddressRange)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build kube-vip address pool patch: %w", err)
+	}
+	if _, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", adminKubeconfig, "patch", "configmap", kubeVIPConfigMap,
+		"-n", kubeVIPNamespace, "--type", "merge", "-p", string(patch)); err != nil {
+		return fmt.Errorf("failed to configure kube-vip address pool: %w", err)
+	}
+	return nil
+}
+
+// createKubeconfig writes a kubeconfig authenticating as the kube-vip service account
+func (i *Installer) createKubeconfig(ctx context.Context, adminKubeconfig string) error {
+	adminData, err := utils.RunCommandWithOutput("cat", adminKubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster credentials: %w", err)
+	}
+	serverURL, caCertData, err := utils.ExtractClusterInfo([]byte(adminData))
+	if err != nil {
+		return fmt.Errorf("failed to extract cluster info from kubeconfig: %w", err)
+	}
+
+	token, err := i.waitForToken(ctx, adminKubeconfig)
+	if err != nil {
+		return err
+	}
+
+	if err := utils.RunSystemCommand("mkdir", "-p", kubeVIPConfigDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", kubeVIPConfigDir, err)
+	}
+	if err := utils.WriteFileAtomicSystem(kubeVIPKubeconfigPath, []byte(renderKubeconfig(serverURL, caCertData, token)), 0o600); err != nil {
+		return fmt.Errorf("failed to write kube-vip kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// waitForToken waits for the token controller to populate the kube-vip service account token secret
+func (i *Installer) waitForToken(ctx context.Context, adminKubeconfig string) (string, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, tokenPollTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(tokenPollInterval)
+	defer ticker.Stop()
+
+	for {
+		output, err := utils.RunCommandContext(waitCtx, "kubectl", "--kubeconfig", adminKubeconfig, "get", "secret", kubeVIPTokenSecret,
+			"-n", kubeVIPNamespace, "-o", "jsonpath={.data.token}")
+		if err == nil && strings.TrimSpace(output) != "" {
+			token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(output))
+			if err != nil {
+				return "", fmt.Errorf("failed to decode kube-vip service account token: %w", err)
+			}
+			return string(token), nil
+		}
+		select {
+		case <-waitCtx.Done():
+			return "", fmt.Errorf("timeout waiting for service account token in secret %s/%s", kubeVIPNamespace, kubeVIPTokenSecret)
+		case <-ticker.C:
+		}
+	}
+}
+
+// renderManifest renders the static pod manifest for this node's configuration
+func (i *Installer) renderManifest() (string, error) {
+	interfaceName, err := i.getInterface()
+	if err != nil {
+		return "", err
+	}
+	return renderStaticPodManifest(i.getImage(), interfaceName, i.config.GetNodeName(), i.config.KubeVIP.AddressRange), nil
+}
+
+// getImage returns the configured kube-vip image, or the upstream image for the configured version
+func (i *Installer) getImage() string {
+	if i.config.KubeVIP.Image != "" {
+		return i.config.KubeVIP.Image
+	}
+	return fmt.Sprintf(defaults.Get().Images.KubeVIP, i.config.KubeVIP.Version)
+}
+
+// getInterface returns the configured interface, or the interface of the default route
+func (i *Installer) getInterface() (string, error) {
+	if i.config.KubeVIP.Interface != "" {
+		return i.config.KubeVIP.Interface, nil
+	}
+	routeTable, err := os.ReadFile(procNetRoutePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	interfaceName := defaultRouteInterface(string(routeTable))
+	if interfaceName == "" {
+		return "", fmt.Errorf("no default route found, set kubeVip.interface explicitly")
+	}
+	return interfaceName, nil
+}
+
+// defaultRouteInterface returns the interface of the IPv4 default route from /proc/net/route content
+func defaultRouteInterface(routeTable string) string {
+	for _, line := range strings.Split(routeTable, "\n") {
+		fields := strings.Fields(line)
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if len(fields) >= 8 && fields[1] == "00000000" && fields[7] == "00000000" {
+			return fields[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package kube_vip
+
+import (
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/internal/golden"
+)
+
+func TestRenderStaticPodManifest(t *testing.T) {
+	manifest := renderStaticPodManifest("ghcr.io/kube-vip/kube-vip:v0.8.9", "eth1", "edge-node-01",
+		"192.168.10.200-192.168.10.220, 192.168.11.10-192.168.11.20")
+	golden.Assert(t, "kube-vip-static-pod", manifest)
+}
+
+func TestDefaultRouteInterface(t *testing.T) {
+	tests := []struct {
+		name       string
+		routeTable string
+		want       string
+	}{
+		{
+			name: "default route on second interface",
+			routeTable: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0010A8C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth1	00000000	010AA8C0	0003	0	0	100	00000000	0	0	0
+`,
+			want: "eth1",
+		},
+		{
+			name: "no default route",
+			routeTable: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0010A8C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+`,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultRouteInterface(tt.routeTable); got != tt.want {
+				t.Errorf("defaultRouteInterface() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressPoolKey(t *testing.T) {
+	if got, want := addressPoolKey(""), "range-global"; got != want {
+		t.Errorf("addressPoolKey(\"\") = %q, want %q", got, want)
+	}
+	if got, want := addressPoolKey("store-42"), "range-store-42"; got != want {
+		t.Errorf("addressPoolKey(\"store-42\") = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package kube_vip
+
+import (
+	"fmt"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+)
+
+// clusterResourcesManifest holds the in-cluster objects kube-vip depends on: the service accounts and RBAC
+// for the kube-vip static pods and the cloud provider, the token secret the static pods authenticate with,
+// the address pool ConfigMap and the kube-vip cloud provider that assigns addresses from the pool.
+// Both controllers only handle Services that set spec.loadBalancerClass, leaving all other Services to AKS.
+const clusterResourcesManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: kube-vip
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: kube-vip-token
+  namespace: kube-system
+  annotations:
+    kubernetes.io/service-account.name: kube-vip
+type: kubernetes.io/service-account-token
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:kube-vip-role
+rules:
+- apiGroups: [""]
+  resources: ["services/status"]
+  verbs: ["update"]
+- apiGroups: [""]
+  resources: ["services", "endpoints"]
+  verbs: ["list", "get", "watch", "update"]
+- apiGroups: [""]
+  resources: ["nodes"]
+  verbs: ["list", "get", "watch", "update", "patch"]
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["list"]
+- apiGroups: ["coordination.k8s.io"]
+  resources: ["leases"]
+  verbs: ["list", "get", "watch", "update", "create"]
+- apiGroups: ["discovery.k8s.io"]
+  resources: ["endpointslices"]
+  verbs: ["list", "get", "watch", "update"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:kube-vip-binding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: system:kube-vip-role
+subjects:
+- kind: ServiceAccount
+  name: kube-vip
+  namespace: kube-system
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: kube-vip-cloud-controller
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:kube-vip-cloud-controller-role
+rules:
+- apiGroups: ["coordination.k8s.io"]
+  resources: ["leases"]
+  verbs: ["get", "create", "update", "list", "put"]
+- apiGroups: [""]
+  resources: ["configmaps", "endpoints", "events", "services/status", "leases"]
+  verbs: ["*"]
+- apiGroups: [""]
+  resources: ["nodes", "services"]
+  verbs: ["list", "get", "watch", "update"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: system:kube-vip-cloud-controller-binding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: system:kube-vip-cloud-controller-role
+subjects:
+- kind: ServiceAccount
+  name: kube-vip-cloud-controller
+  namespace: kube-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kubevip
+  namespace: kube-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: kube-vip-cloud-provider
+  namespace: kube-system
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: kube-vip
+      component: kube-vip-cloud-provider
+  template:
+    metadata:
+      labels:
+        app: kube-vip
+        component: kube-vip-cloud-provider
+    spec:
+      serviceAccountName: kube-vip-cloud-controller
+      nodeSelector:
+        kubernetes.io/os: linux
+      containers:
+      - name: kube-vip-cloud-provider
+        image: %s
+        imagePullPolicy: IfNotPresent
+        command:
+        - /kube-vip-cloud-provider
+        - --leader-elect-resource-name=kube-vip-cloud-controller
+        env:
+        - name: KUBEVIP_ENABLE_LOADBALANCERCLASS
+          value: "true"
+`
+
+// renderClusterResources renders the in-cluster objects shared by every node running kube-vip
+func renderClusterResources() string {
+	return fmt.Sprintf(clusterResourcesManifest, defaults.Get().Images.KubeVIPCloudProvider)
+}
+
+// renderStaticPodManifest renders the kube-vip static pod announcing Service addresses over ARP on the given interface.
+// The address range is recorded as an annotation so a range change rewrites the manifest and is detected as drift.
+func renderStaticPodManifest(image, interfaceName, nodeName, addressRange string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Pod
+metadata:
+  name: kube-vip
+  namespace: kube-system
+  labels:
+    app.kubernetes.io/name: kube-vip
+    app.kubernetes.io/managed-by: aks-flex-node
+  annotations:
+    kubernetes.azure.com/flex-node-address-range: "%s"
+spec:
+  hostNetwork: true
+  priorityClassName: system-node-critical
+  containers:
+  - name: kube-vip
+    image: %s
+    imagePullPolicy: IfNotPresent
+    args:
+    - manager
+    env:
+    - name: vip_arp
+      value: "true"
+    - name: vip_interface
+      value: %s
+    - name: vip_nodename
+      value: %s
+    - name: svc_enable
+      value: "true"
+    - name: svc_election
+      value: "true"
+    - name: lb_class_only
+      value: "true"
+    - name: lb_class_name
+      value: %s
+    securityContext:
+      capabilities:
+        add:
+        - NET_ADMIN
+        - NET_RAW
+    volumeMounts:
+    - name: kubeconfig
+      mountPath: /etc/kubernetes/admin.conf
+      readOnly: true
+  volumes:
+  - name: kubeconfig
+    hostPath:
+      path: %s
+      type: File
+`, normalizeAddressRange(addressRange), image, interfaceName, nodeName, kubeVIPLoadBalancerClass, kubeVIPKubeconfigPath)
+}
+
+// renderKubeconfig renders the kubeconfig the kube-vip static pod authenticates with
+func renderKubeconfig(serverURL, caCertData, token string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: %s
+  name: cluster
+contexts:
+- context:
+    cluster: cluster
+    user: kube-vip
+  name: kube-vip
+current-context: kube-vip
+users:
+- name: kube-vip
+  user:
+    token: %s
+`, caCertData, serverURL, token)
+}
+
+// normalizeAddressRange strips whitespace around the comma-separated ranges, as expected by the cloud provider
+func normalizeAddressRange(addressRange string) string {
+	ranges := strings.Split(addressRange, ",")
+	for i, r := range ranges {
+		ranges[i] = strings.TrimSpace(r)
+	}
+	return strings.Join(ranges, ",")
+}
+
+// addressPoolKey returns the kubevip ConfigMap key for the address range, scoped to a namespace when configured
+func addressPoolKey(serviceNamespace string) string {
+	if serviceNamespace == "" {
+		return "range-global"
+	}
+	return "range-" + serviceNamespace
+}
@@ -0,0 +1,60 @@
+package kube_vip
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// UnInstaller removes the kube-vip static pod from this node.
+// The in-cluster objects are shared with other nodes and are left in place.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new kube-vip UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *UnInstaller) GetName() string {
+	return "KubeVIP_UnInstaller"
+}
+
+// Execute removes the kube-vip static pod manifest and kubeconfig
+func (u *UnInstaller) Execute(_ context.Context) error {
+	u.logger.Info("Removing kube-vip static pod")
+
+	if err := utils.RunCleanupCommand(kubeVIPManifestPath); err != nil {
+		u.logger.Debugf("Failed to remove kube-vip manifest %s: %v (may not exist)", kubeVIPManifestPath, err)
+	}
+	if errs := utils.RemoveDirectories([]string{kubeVIPConfigDir}, u.logger); len(errs) > 0 {
+		u.logger.Debugf("Failed to remove kube-vip config directory %s: %v", kubeVIPConfigDir, errs)
+	}
+
+	u.logger.Info("kube-vip removed successfully")
+	return nil
+}
+
+// IsCompleted returns true when neither the kube-vip manifest nor its kubeconfig is present
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return !utils.FileExists(kubeVIPManifestPath) && !utils.FileExists(kubeVIPKubeconfigPath)
+}
+
+// Plan describes the kube-vip removal for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{"remove " + kubeVIPManifestPath, "remove " + kubeVIPConfigDir}
+}
+
+// SecretFiles returns the kube-vip files holding credentials
+func SecretFiles() []string {
+	return []string{kubeVIPKubeconfigPath}
+}
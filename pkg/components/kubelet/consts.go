@@ -2,7 +2,6 @@ package kubelet
 
 const (
 	// System directories
-	etcDefaultDir     = "/etc/default"
 	kubeletServiceDir = "/etc/systemd/system/kubelet.service.d"
 	etcKubernetesDir  = "/etc/kubernetes"
 
@@ -23,6 +22,34 @@ const (
 	kubeletVarDir              = "/var/lib/kubelet"
 	KubeletKubeconfigPath      = "/var/lib/kubelet/kubeconfig"
 	kubeletTokenScriptPath     = "/var/lib/kubelet/token.sh"
+	kubeletClientSecretPath    = "/var/lib/kubelet/client-secret" // service principal secret the token script reads
+
+	// Kubelet CPU and memory manager checkpoints, invalid once the manager policy changes
+	kubeletCPUManagerStatePath    = "/var/lib/kubelet/cpu_manager_state"
+	kubeletMemoryManagerStatePath = "/var/lib/kubelet/memory_manager_state"
+	sysfsRoot                     = "/sys"
+	procSwapsPath                 = "/proc/swaps"
+
+	// TLS bootstrap: the bootstrap kubeconfig carries the exec credential used only to request
+	// a client certificate, which kubelet then references from KubeletKubeconfigPath
+	KubeletBootstrapKubeconfigPath = "/var/lib/kubelet/bootstrap-kubeconfig"
+	KubeletClientCertPath          = "/var/lib/kubelet/pki/kubelet-client-current.pem"
+	KubeletServingCertPath         = "/var/lib/kubelet/pki/kubelet-server-current.pem"
+	kubeletPKIDir                  = "/var/lib/kubelet/pki"
+
+	// TPM key protection: the PKI directory is a tmpfs unsealed before kubelet starts and sealed when it changes
+	tpmDevicePath              = "/dev/tpmrm0"
+	kubeletKeySealScriptPath   = "/var/lib/kubelet/pki-seal.sh"
+	kubeletKeyProtectionConfig = "/etc/systemd/system/kubelet.service.d/10-key-protection.conf"
+	keySealPathUnit            = "kubelet-pki-seal.path"
+	keySealServiceUnit         = "kubelet-pki-seal.service"
+	keySealPathUnitPath        = "/etc/systemd/system/kubelet-pki-seal.path"
+	keySealServiceUnitPath     = "/etc/systemd/system/kubelet-pki-seal.service"
+	kubeletSealedPKIDir        = "/var/lib/aks-flex-node/kubelet-pki"
+	sealedPKIArchive           = "pki.enc"
+
+	// Adoption state for kubelet installations not created by the agent
+	kubeletAdoptionStatePath = "/var/lib/aks-flex-node/kubelet-adoption.json"
 
 	// Azure resource identifiers
 	aksServiceResourceID = "6dae42f8-4368-4678-94ff-3960e28e3630"
@@ -0,0 +1,307 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// AdoptionState records an existing kubelet installation that the agent adopted instead of replacing
+type AdoptionState struct {
+	AdoptedAt      time.Time         `json:"adoptedAt"`
+	KubeconfigPath string            `json:"kubeconfigPath"`
+	ServerURL      string            `json:"serverURL"`
+	NodeLabels     map[string]string `json:"nodeLabels,omitempty"`
+}
+
+// Adopter detects a healthy kubelet already joined to the target cluster and adopts it
+type Adopter struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewAdopter creates a new kubelet Adopter
+func NewAdopter(logger *logrus.Logger) *Adopter {
+	return &Adopter{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (a *Adopter) GetName() string {
+	return "KubeletAdopter"
+}
+
+// Validate validates prerequisites for kubelet adoption
+func (a *Adopter) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when adoption is disabled and no kubelet is adopted, or when the adopted kubelet
+// still runs against the adopted cluster. The adoption is verified on every run, so the installer takes over a
+// kubelet that stopped or was pointed elsewhere instead of skipping it for good.
+func (a *Adopter) IsCompleted(ctx context.Context) bool {
+	if !IsAdopted() {
+		return !a.config.Node.Kubelet.AdoptExisting
+	}
+	return a.config.Node.Kubelet.AdoptExisting && a.adoptionLost() == ""
+}
+
+// Execute adopts an existing kubelet when it is healthy and pointed at the target cluster, and releases an
+// adoption that no longer holds. Any kubelet that does not qualify is left to the regular installer, which
+// replaces it.
+func (a *Adopter) Execute(ctx context.Context) error {
+	if IsAdopted() {
+		reason := "node.kubelet.adoptExisting is disabled"
+		if a.config.Node.Kubelet.AdoptExisting {
+			reason = a.adoptionLost()
+		}
+		if reason == "" {
+			return nil
+		}
+		a.logger.Warnf("Releasing the adopted kubelet, %s; the agent manages its configuration from now on", reason)
+		if err := utils.RunSystemCommand("rm", "-f", kubeletAdoptionStatePath); err != nil {
+			return fmt.Errorf("failed to remove kubelet adoption state: %w", err)
+		}
+		if !a.config.Node.Kubelet.AdoptExisting {
+			return nil
+		}
+	}
+
+	a.logger.Info("Checking for an existing kubelet installation to adopt")
+
+	if !utils.IsServiceActive("kubelet") {
+		a.logger.Info("No running kubelet found, proceeding with a fresh kubelet installation")
+		return nil
+	}
+
+	kubeconfigPath, serverURL, err := a.findExistingKubeconfig()
+	if err != nil {
+		a.logger.Warnf("Existing kubelet is running but cannot be adopted: %v", err)
+		return nil
+	}
+
+	targetServerURL, err := a.getTargetServerURL(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to determine target cluster API server: %w", err)
+	}
+	if !sameServer(serverURL, targetServerURL) {
+		a.logger.Warnf("Existing kubelet points at %s instead of target cluster %s, it will be replaced", serverURL, targetServerURL)
+		return nil
+	}
+
+	if ready := a.isNodeReady(kubeconfigPath); !ready {
+		a.logger.Warn("Existing kubelet is joined to the target cluster but the node is not Ready, it will be replaced")
+		return nil
+	}
+
+	state := &AdoptionState{
+		AdoptedAt:      time.Now(),
+		KubeconfigPath: kubeconfigPath,
+		ServerURL:      serverURL,
+		NodeLabels:     readExistingNodeLabels(),
+	}
+	a.importSettings(state)
+
+	// The configured labels and taints the node lacks are the deltas the agent manages from now on; the labels and
+	// taints the node already has are never removed. The node is only adopted once they are applied.
+	sync, err := syncNode(ctx, a.config, kubeconfigPath, func(string) bool { return false }, a.logger)
+	if err != nil {
+		return fmt.Errorf("failed to apply the configured labels and taints to the adopted node: %w", err)
+	}
+	if !sync.IsEmpty() {
+		a.logger.Infof("Applied the configuration to the adopted node: %s", sync)
+	}
+
+	if err := saveAdoptionState(state); err != nil {
+		return fmt.Errorf("failed to persist kubelet adoption state: %w", err)
+	}
+
+	a.logger.Warnf("Adopted existing healthy kubelet (kubeconfig: %s); its configuration and container runtime are preserved", kubeconfigPath)
+	return nil
+}
+
+// adoptionLost returns why the adopted kubelet no longer qualifies for adoption, "" while it does
+func (a *Adopter) adoptionLost() string {
+	state, err := LoadAdoptionState()
+	if err != nil {
+		return err.Error()
+	}
+	serverURL := ""
+	if data, err := utils.RunCommandWithOutput("cat", state.KubeconfigPath); err == nil {
+		serverURL, _ = utils.ExtractServerURL([]byte(data))
+	}
+	return verifyAdoption(state, utils.IsServiceActive("kubelet"), serverURL)
+}
+
+// verifyAdoption returns why an adopted kubelet, running or not and whose kubeconfig now points at serverURL,
+// no longer qualifies for adoption, "" while it does
+func verifyAdoption(state *AdoptionState, kubeletActive bool, serverURL string) string {
+	switch {
+	case !kubeletActive:
+		return "kubelet is no longer running"
+	case serverURL == "":
+		return fmt.Sprintf("its kubeconfig %s is no longer readable", state.KubeconfigPath)
+	case !sameServer(serverURL, state.ServerURL):
+		return fmt.Sprintf("it now points at %s instead of the adopted cluster %s", serverURL, state.ServerURL)
+	}
+	return ""
+}
+
+// sameServer reports whether two API server URLs name the same server
+func sameServer(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
+
+// findExistingKubeconfig locates the kubeconfig used by the running kubelet and returns its API server URL
+func (a *Adopter) findExistingKubeconfig() (string, string, error) {
+	for _, path := range []string{KubeletKubeconfigPath, kubeletKubeConfig} {
+		if !utils.FileExists(path) {
+			continue
+		}
+		data, err := utils.RunCommandWithOutput("cat", path)
+		if err != nil {
+			a.logger.Debugf("Failed to read kubeconfig %s: %v", path, err)
+			continue
+		}
+		serverURL, err := utils.ExtractServerURL([]byte(data))
+		if err != nil {
+			a.logger.Debugf("Failed to parse kubeconfig %s: %v", path, err)
+			continue
+		}
+		return path, serverURL, nil
+	}
+	return "", "", fmt.Errorf("no readable kubelet kubeconfig found")
+}
+
+// getTargetServerURL resolves the API server URL of the target cluster from its admin credentials
+func (a *Adopter) getTargetServerURL(ctx context.Context) (string, error) {
+	installer := NewInstaller(a.logger)
+	if err := installer.setUpClients(); err != nil {
+		return "", err
+	}
+	kubeconfig, err := installer.getClusterCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	return utils.ExtractServerURL(kubeconfig)
+}
+
+// isNodeReady checks whether the node backing this kubelet reports Ready using the kubelet's own credentials
+func (a *Adopter) isNodeReady(kubeconfigPath string) bool {
+	nodeName := a.config.GetNodeName()
+	if nodeName == "" {
+		a.logger.Warn("Failed to determine node name")
+		return false
+	}
+
+	output, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubeconfigPath,
+		"get", "node", nodeName,
+		"-o", "jsonpath={.status.conditions[?(@.type==\"Ready\")].status}")
+	if err != nil {
+		a.logger.Debugf("Failed to query node readiness: %v, output: %s", err, output)
+		return false
+	}
+	return strings.TrimSpace(output) == "True"
+}
+
+// importSettings merges the node labels of the adopted kubelet into the active configuration. Labels set explicitly in
+// the agent configuration take precedence and are applied to the node as deltas.
+func (a *Adopter) importSettings(state *AdoptionState) {
+	if a.config.Node.Labels == nil {
+		a.config.Node.Labels = make(map[string]string)
+	}
+
+	keys := make([]string, 0, len(state.NodeLabels))
+	for key := range state.NodeLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		existing := state.NodeLabels[key]
+		desired, ok := a.config.Node.Labels[key]
+		switch {
+		case !ok:
+			a.config.Node.Labels[key] = existing
+			a.logger.Debugf("Imported node label %s=%s from existing kubelet", key, existing)
+		case desired != existing:
+			a.logger.Warnf("Node label %s of the existing kubelet is %s, the configured %s is applied instead", key, existing, desired)
+		}
+	}
+}
+
+// readExistingNodeLabels parses node labels from the kubelet defaults file, if one exists
+func readExistingNodeLabels() map[string]string {
+	labels := make(map[string]string)
+	data, err := os.ReadFile(kubeletDefaultsPath)
+	if err != nil {
+		return labels
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), "KUBELET_NODE_LABELS=")
+		if !found {
+			continue
+		}
+		for _, pair := range strings.Split(strings.Trim(value, `"`), ",") {
+			key, val, ok := strings.Cut(pair, "=")
+			if ok && key != "" {
+				labels[key] = val
+			}
+		}
+	}
+	return labels
+}
+
+// NodeKubeconfigPath returns the kubeconfig the node is read and labeled with: the adopted kubelet's own
+// kubeconfig, or the one the agent configured
+func NodeKubeconfigPath() string {
+	if IsAdopted() {
+		if state, err := LoadAdoptionState(); err == nil && state.KubeconfigPath != "" {
+			return state.KubeconfigPath
+		}
+	}
+	return KubeletKubeconfigPath
+}
+
+// IsAdopted reports whether the agent has adopted an existing kubelet installation
+func IsAdopted() bool {
+	return utils.FileExists(kubeletAdoptionStatePath)
+}
+
+// LoadAdoptionState reads the persisted kubelet adoption state
+func LoadAdoptionState() (*AdoptionState, error) {
+	data, err := utils.RunCommandWithOutput("cat", kubeletAdoptionStatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubelet adoption state: %w", err)
+	}
+	state := &AdoptionState{}
+	if err := json.Unmarshal([]byte(data), state); err != nil {
+		return nil, fmt.Errorf("failed to parse kubelet adoption state: %w", err)
+	}
+	return state, nil
+}
+
+// saveAdoptionState persists the kubelet adoption state
+func saveAdoptionState(state *AdoptionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal adoption state: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(kubeletAdoptionStatePath)); err != nil {
+		return fmt.Errorf("failed to create agent state directory: %w", err)
+	}
+	return utils.WriteFileAtomicSystem(kubeletAdoptionStatePath, data, 0o600)
+}
@@ -0,0 +1,32 @@
+package kubelet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyAdoption(t *testing.T) {
+	state := &AdoptionState{KubeconfigPath: "/etc/kubernetes/kubelet.conf", ServerURL: "https://cluster.hcp.eastus.azmk8s.io:443"}
+
+	tests := []struct {
+		name          string
+		kubeletActive bool
+		serverURL     string
+		want          string
+	}{
+		{name: "adoption holds", kubeletActive: true, serverURL: "https://cluster.hcp.eastus.azmk8s.io:443"},
+		{name: "trailing slash and case", kubeletActive: true, serverURL: "https://CLUSTER.hcp.eastus.azmk8s.io:443/"},
+		{name: "kubelet stopped", serverURL: "https://cluster.hcp.eastus.azmk8s.io:443", want: "no longer running"},
+		{name: "kubeconfig unreadable", kubeletActive: true, want: "/etc/kubernetes/kubelet.conf is no longer readable"},
+		{name: "other cluster", kubeletActive: true, serverURL: "https://other.hcp.eastus.azmk8s.io:443", want: "now points at https://other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifyAdoption(state, tt.kubeletActive, tt.serverURL)
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("verifyAdoption() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
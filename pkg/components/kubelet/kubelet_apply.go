@@ -0,0 +1,133 @@
+package kubelet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// stagedSuffix marks a rendered kubelet file written next to its target, waiting to be switched into place
+const stagedSuffix = ".staged"
+
+// stagedFile is a rendered kubelet file and the path it is switched to
+type stagedFile struct {
+	path    string
+	content []byte
+	perm    os.FileMode
+}
+
+// configApply collects the rendered kubelet files of one configuration and applies them together,
+// so kubelet never starts against a mix of old and new files
+type configApply struct {
+	logger *logrus.Logger
+	files  []stagedFile
+	checks []func() error // run against the staged files before kubelet is stopped
+}
+
+// newConfigApply creates an empty configApply
+func newConfigApply(logger *logrus.Logger) *configApply {
+	return &configApply{logger: logger}
+}
+
+// stage adds a rendered file, replacing a file staged earlier for the same path
+func (a *configApply) stage(path string, content []byte, perm os.FileMode) {
+	a.files = slices.DeleteFunc(a.files, func(f stagedFile) bool { return f.path == path })
+	a.files = append(a.files, stagedFile{path: path, content: content, perm: perm})
+}
+
+// check adds a check of the staged files, a failing check leaves the current configuration untouched
+func (a *configApply) check(check func() error) {
+	a.checks = append(a.checks, check)
+}
+
+// apply switches the staged files into place in an ordered sequence:
+//  1. every file is written next to its target and the checks run, a failure leaves the current configuration untouched
+//  2. kubelet is stopped
+//  3. whileStopped runs and stale files that are not replaced are removed
+//  4. every staged file is renamed over its target, which is atomic within a directory
+//  5. systemd is reloaded and kubelet is restarted if it was running before
+func (a *configApply) apply(stale []string, whileStopped func() error) error {
+	for _, file := range a.files {
+		if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(file.path)); err != nil {
+			a.discard()
+			return fmt.Errorf("failed to create directory for %s: %w", file.path, err)
+		}
+		if err := utils.WriteFileAtomicSystem(file.path+stagedSuffix, file.content, file.perm); err != nil {
+			a.discard()
+			return fmt.Errorf("failed to stage %s: %w", file.path, err)
+		}
+	}
+
+	for _, check := range a.checks {
+		if err := check(); err != nil {
+			a.discard()
+			return err
+		}
+	}
+
+	wasRunning := utils.IsServiceActive("kubelet")
+	if wasRunning {
+		a.logger.Info("Stopping kubelet to switch its configuration")
+		if err := utils.StopService("kubelet"); err != nil {
+			a.discard()
+			return fmt.Errorf("failed to stop kubelet before switching its configuration: %w", err)
+		}
+	}
+
+	if whileStopped != nil {
+		if err := whileStopped(); err != nil {
+			a.discard()
+			// The previous configuration is still in place, bring kubelet back on it
+			if wasRunning {
+				if restartErr := utils.RestartService("kubelet"); restartErr != nil {
+					a.logger.Warnf("Failed to restart kubelet with its previous configuration: %v", restartErr)
+				}
+			}
+			return err
+		}
+	}
+	for _, path := range stale {
+		if a.isStaged(path) {
+			continue
+		}
+		if err := utils.RunCleanupCommand(path); err != nil {
+			a.logger.Warnf("Failed to remove stale kubelet file %s: %v", path, err)
+		}
+	}
+
+	for _, file := range a.files {
+		if err := utils.RunSystemCommand("mv", "-f", file.path+stagedSuffix, file.path); err != nil {
+			return fmt.Errorf("failed to switch %s into place: %w", file.path, err)
+		}
+	}
+	a.logger.Infof("Switched %d kubelet configuration file(s) into place", len(a.files))
+
+	if err := utils.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd after switching kubelet configuration: %w", err)
+	}
+	if wasRunning {
+		if err := utils.RestartService("kubelet"); err != nil {
+			return fmt.Errorf("failed to restart kubelet with its new configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// isStaged checks if a file is staged for the path
+func (a *configApply) isStaged(path string) bool {
+	return slices.ContainsFunc(a.files, func(f stagedFile) bool { return f.path == path })
+}
+
+// discard removes the staged files after a failed apply
+func (a *configApply) discard() {
+	for _, file := range a.files {
+		if err := utils.RunCleanupCommand(file.path + stagedSuffix); err != nil {
+			a.logger.Debugf("Failed to remove staged file %s: %v", file.path+stagedSuffix, err)
+		}
+	}
+}
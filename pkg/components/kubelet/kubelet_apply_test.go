@@ -0,0 +1,173 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// fakeRunner records the commands it runs and answers systemctl is-active for kubelet
+type fakeRunner struct {
+	kubeletActive bool
+	failOn        string
+	commands      []string
+}
+
+func (r *fakeRunner) Run(_ context.Context, cmd utils.Command) (*utils.CommandResult, error) {
+	line := strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+	r.commands = append(r.commands, line)
+	if r.failOn != "" && strings.HasPrefix(line, r.failOn) {
+		return &utils.CommandResult{ExitCode: 1}, errors.New("command failed")
+	}
+	if line == "systemctl is-active kubelet" {
+		if r.kubeletActive {
+			return &utils.CommandResult{Output: "active\n"}, nil
+		}
+		return &utils.CommandResult{Output: "inactive\n", ExitCode: 3}, errors.New("exit status 3")
+	}
+	return &utils.CommandResult{}, nil
+}
+
+// index returns the position of the first recorded command starting with prefix, or -1
+func (r *fakeRunner) index(prefix string) int {
+	return slices.IndexFunc(r.commands, func(c string) bool { return strings.HasPrefix(c, prefix) })
+}
+
+// lastIndex returns the position of the last recorded command starting with prefix, or -1
+func (r *fakeRunner) lastIndex(prefix string) int {
+	for i := len(r.commands) - 1; i >= 0; i-- {
+		if strings.HasPrefix(r.commands[i], prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+func newTestApply() *configApply {
+	apply := newConfigApply(logrus.New())
+	apply.stage(kubeletDefaultsPath, []byte("KUBELET_FLAGS=old"), 0o644)
+	apply.stage(KubeletBootstrapKubeconfigPath, []byte("apiVersion: v1"), 0o600)
+	apply.stage(kubeletDefaultsPath, []byte("KUBELET_FLAGS=new"), 0o644)
+	return apply
+}
+
+func TestConfigApplyOrder(t *testing.T) {
+	runner := &fakeRunner{kubeletActive: true}
+	defer utils.SetCommandRunner(runner)()
+
+	apply := newTestApply()
+	if len(apply.files) != 2 {
+		t.Fatalf("staged %d files, want 2 after restaging the defaults file", len(apply.files))
+	}
+
+	stoppedDuringReset := false
+	err := apply.apply([]string{kubeletDefaultsPath, kubeletConfigPath}, func() error {
+		stoppedDuringReset = runner.index("systemctl stop kubelet") >= 0
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("apply() unexpected error: %v", err)
+	}
+	if !stoppedDuringReset {
+		t.Error("whileStopped ran before kubelet was stopped")
+	}
+
+	stop := runner.index("systemctl stop kubelet")
+	lastStage := runner.lastIndex("mv " + KubeletBootstrapKubeconfigPath + ".staged.tmp")
+	firstSwitch := runner.index("mv -f ")
+	lastSwitch := runner.lastIndex("mv -f ")
+	removeStale := runner.index("rm -f " + kubeletConfigPath)
+	reload := runner.index("systemctl daemon-reload")
+	restart := runner.index("systemctl restart kubelet")
+
+	switch {
+	case lastStage < 0 || stop < 0 || firstSwitch < 0 || reload < 0 || restart < 0:
+		t.Fatalf("missing commands in sequence:\n%s", strings.Join(runner.commands, "\n"))
+	case lastStage > stop:
+		t.Error("kubelet was stopped before every file was staged")
+	case removeStale < stop || removeStale > firstSwitch:
+		t.Error("stale files were not removed between stopping kubelet and the switch")
+	case firstSwitch < stop:
+		t.Error("files were switched into place while kubelet was running")
+	case reload < lastSwitch || restart < reload:
+		t.Error("kubelet was restarted before every file was switched and systemd reloaded")
+	}
+	if runner.index("rm -f "+kubeletDefaultsPath) >= 0 {
+		t.Error("a restaged file was removed as stale instead of being replaced atomically")
+	}
+	if !slices.Contains(runner.commands, "mv -f "+kubeletDefaultsPath+".staged "+kubeletDefaultsPath) {
+		t.Errorf("defaults file was not switched into place:\n%s", strings.Join(runner.commands, "\n"))
+	}
+}
+
+func TestConfigApplyKubeletStopped(t *testing.T) {
+	runner := &fakeRunner{}
+	defer utils.SetCommandRunner(runner)()
+
+	if err := newTestApply().apply(nil, nil); err != nil {
+		t.Fatalf("apply() unexpected error: %v", err)
+	}
+	if runner.index("systemctl stop kubelet") >= 0 || runner.index("systemctl restart kubelet") >= 0 {
+		t.Errorf("kubelet that was not running was stopped or restarted:\n%s", strings.Join(runner.commands, "\n"))
+	}
+	if runner.index("mv -f ") < 0 {
+		t.Error("files were not switched into place")
+	}
+}
+
+func TestConfigApplyStagingFailure(t *testing.T) {
+	runner := &fakeRunner{kubeletActive: true, failOn: "chmod 600 " + KubeletBootstrapKubeconfigPath}
+	defer utils.SetCommandRunner(runner)()
+
+	if err := newTestApply().apply(nil, nil); err == nil {
+		t.Fatal("apply() expected an error when staging fails")
+	}
+	if runner.index("systemctl") >= 0 {
+		t.Errorf("kubelet was touched after a staging failure:\n%s", strings.Join(runner.commands, "\n"))
+	}
+	if runner.index("mv -f ") >= 0 {
+		t.Error("files were switched into place after a staging failure")
+	}
+	if runner.index("rm -f "+KubeletBootstrapKubeconfigPath+".staged") < 0 {
+		t.Error("staged files were not discarded after a staging failure")
+	}
+}
+
+func TestConfigApplyResetFailure(t *testing.T) {
+	runner := &fakeRunner{kubeletActive: true}
+	defer utils.SetCommandRunner(runner)()
+
+	err := newTestApply().apply(nil, func() error { return errors.New("reset failed") })
+	if err == nil {
+		t.Fatal("apply() expected an error when whileStopped fails")
+	}
+	if runner.index("mv -f ") >= 0 {
+		t.Errorf("configuration was switched after whileStopped failed:\n%s", strings.Join(runner.commands, "\n"))
+	}
+	if runner.index("systemctl restart kubelet") < 0 {
+		t.Error("kubelet was not restarted on its previous configuration")
+	}
+}
+
+func TestConfigApplyCheckFailure(t *testing.T) {
+	runner := &fakeRunner{kubeletActive: true}
+	defer utils.SetCommandRunner(runner)()
+
+	apply := newTestApply()
+	apply.check(func() error { return errors.New("credential rejected") })
+	if err := apply.apply(nil, nil); err == nil {
+		t.Fatal("apply() expected an error when a check fails")
+	}
+	if runner.index("systemctl") >= 0 || runner.index("mv -f ") >= 0 {
+		t.Errorf("configuration was switched after a failed check:\n%s", strings.Join(runner.commands, "\n"))
+	}
+	if runner.index("rm -f "+KubeletBootstrapKubeconfigPath+".staged") < 0 {
+		t.Error("staged files were not discarded after a failed check")
+	}
+}
@@ -0,0 +1,56 @@
+package kubelet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// bootstrapCheckTimeout bounds the authenticated request made with the staged bootstrap kubeconfig,
+// which includes fetching a token through the token script
+const bootstrapCheckTimeout = time.Minute
+
+// verifyBootstrapCredential makes an authenticated request with the staged bootstrap kubeconfig and token script
+// before they are switched into place, so a credential the API server rejects fails the kubelet step instead of
+// leaving the node NotReady without a certificate. The request asks whether the credential may create the
+// certificate signing request kubelet sends in TLS bootstrap, which an unauthenticated request cannot answer.
+// An unreachable API server is only logged: kubelet retries until it is reachable and preflight reports it.
+func verifyBootstrapCredential(ctx context.Context, kubeconfig []byte, logger *logrus.Logger) error {
+	// The kubeconfig runs the token script at its final path, point it at the staged one
+	staged := bytes.ReplaceAll(kubeconfig, []byte("command: "+kubeletTokenScriptPath), []byte("command: "+kubeletTokenScriptPath+stagedSuffix))
+	file, err := fsutil.CreateTempFile("bootstrap-kubeconfig-*", staged)
+	if err != nil {
+		return fmt.Errorf("failed to write bootstrap kubeconfig for verification: %w", err)
+	}
+	_ = file.Close()
+	defer fsutil.CleanupTempFile(file.Name())
+
+	checkCtx, cancel := context.WithTimeout(ctx, bootstrapCheckTimeout)
+	defer cancel()
+	output, err := sysutil.RunCommandContext(checkCtx, "kubectl", "--kubeconfig", file.Name(),
+		"auth", "can-i", "create", "certificatesigningrequests.certificates.k8s.io")
+	answer := strings.TrimSpace(output)
+	switch {
+	case answer == "yes":
+		logger.Info("Verified the bootstrap credential with the API server")
+		return nil
+	case answer == "no":
+		return fmt.Errorf("bootstrap credential is not allowed to create certificate signing requests, " +
+			"bind its identity to the system:node-bootstrapper cluster role")
+	case err == nil:
+		return nil
+	case strings.Contains(err.Error(), "getting credentials"):
+		return fmt.Errorf("bootstrap token script failed to get a token: %w", err)
+	case credentialRejection(err.Error()) != "" || strings.Contains(err.Error(), "Forbidden"):
+		return fmt.Errorf("API server rejected the bootstrap credential: %w", err)
+	}
+	logger.Warnf("Could not verify the bootstrap credential, the API server may be unreachable: %v", err)
+	return nil
+}
@@ -0,0 +1,67 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// fakeCanIRunner answers kubectl auth can-i with output and err, recording the kubeconfig it was given
+type fakeCanIRunner struct {
+	output     string
+	err        error
+	kubeconfig string
+}
+
+func (f *fakeCanIRunner) Run(_ context.Context, cmd sysutil.Command) (*sysutil.CommandResult, error) {
+	if cmd.Name != "kubectl" || len(cmd.Args) < 2 {
+		return nil, errors.New("unexpected command " + cmd.Name)
+	}
+	data, _ := os.ReadFile(cmd.Args[1])
+	f.kubeconfig = string(data)
+	result := &sysutil.CommandResult{Output: f.output}
+	if f.err != nil {
+		return result, &sysutil.CommandError{Name: cmd.Name, Output: f.output, Err: f.err}
+	}
+	return result, nil
+}
+
+func TestVerifyBootstrapCredential(t *testing.T) {
+	kubeconfig := []byte("users:\n- name: arc-user\n  user:\n    exec:\n      command: " + kubeletTokenScriptPath + "\n")
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantErr string
+	}{
+		{name: "allowed", output: "yes\n"},
+		{name: "not allowed", output: "no\n", err: errors.New("exit status 1"), wantErr: "system:node-bootstrapper"},
+		{name: "rejected", output: "error: You must be logged in to the server (Unauthorized)\n", err: errors.New("exit status 1"), wantErr: "rejected"},
+		{name: "token script failed", output: "Unable to connect to the server: getting credentials: exec: executable failed with exit code 1\n",
+			err: errors.New("exit status 1"), wantErr: "token script"},
+		{name: "unreachable", output: "Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout\n", err: errors.New("exit status 1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeCanIRunner{output: tt.output, err: tt.err}
+			t.Cleanup(sysutil.SetCommandRunner(runner))
+
+			err := verifyBootstrapCredential(context.Background(), kubeconfig, logrus.New())
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("verifyBootstrapCredential() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("verifyBootstrapCredential() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !strings.Contains(runner.kubeconfig, "command: "+kubeletTokenScriptPath+stagedSuffix) {
+				t.Errorf("verification kubeconfig does not run the staged token script:\n%s", runner.kubeconfig)
+			}
+		})
+	}
+}
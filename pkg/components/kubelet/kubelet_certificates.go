@@ -0,0 +1,122 @@
+package kubelet
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// rotationDeadline is the share of its validity after which a certificate is overdue for rotation. Kubelet rotates
+// its certificates at a random point between 70 and 90 percent of their validity.
+const rotationDeadline = 0.9
+
+// Paths of the kubelet certificates, replaced in tests
+var (
+	clientCertPath  = KubeletClientCertPath
+	servingCertPath = KubeletServingCertPath
+)
+
+// CertificateExpiry is the validity of a kubelet certificate
+type CertificateExpiry struct {
+	NotBefore       time.Time `json:"notBefore"`
+	NotAfter        time.Time `json:"notAfter"`
+	DaysRemaining   int       `json:"daysRemaining"`             // Whole days until the certificate expires, negative once it expired
+	Expired         bool      `json:"expired,omitempty"`         // The certificate expired
+	RotationOverdue bool      `json:"rotationOverdue,omitempty"` // Kubelet did not rotate the certificate although 90% of its validity passed
+}
+
+// Certificates holds the validity of the certificates kubelet rotates
+type Certificates struct {
+	Client  *CertificateExpiry `json:"client,omitempty"`  // Client certificate kubelet authenticates to the API server with
+	Serving *CertificateExpiry `json:"serving,omitempty"` // Serving certificate issued through a CSR, with node.kubelet.serverTLSBootstrap
+}
+
+// ReadCertificates reads the validity of the kubelet client and serving certificates. It returns nil when kubelet
+// has neither yet.
+func ReadCertificates(now time.Time) (*Certificates, error) {
+	client, err := readCertificateExpiry(clientCertPath, now)
+	if err != nil {
+		return nil, err
+	}
+	serving, err := readCertificateExpiry(servingCertPath, now)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil && serving == nil {
+		return nil, nil
+	}
+	return &Certificates{Client: client, Serving: serving}, nil
+}
+
+// readCertificateExpiry reads the validity of the certificate in a PEM file, nil when the file does not exist.
+// Kubelet writes its certificates readable by root only, the service user reads them through sudo.
+func readCertificateExpiry(path string, now time.Time) (*CertificateExpiry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if os.IsPermission(err) {
+		var output string
+		if output, err = sysutil.RunCommandWithOutput("cat", path); err == nil {
+			data = []byte(output)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubelet certificate %s: %w", path, err)
+	}
+	expiry, err := parseCertificateExpiry(data, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubelet certificate %s: %w", path, err)
+	}
+	return expiry, nil
+}
+
+// parseCertificateExpiry returns the validity of the first certificate of a PEM bundle. Kubelet writes its
+// certificates bundled with their key.
+func parseCertificateExpiry(data []byte, now time.Time) (*CertificateExpiry, error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		validity := cert.NotAfter.Sub(cert.NotBefore)
+		deadline := cert.NotBefore.Add(time.Duration(float64(validity) * rotationDeadline))
+		return &CertificateExpiry{
+			NotBefore:       cert.NotBefore.UTC(),
+			NotAfter:        cert.NotAfter.UTC(),
+			DaysRemaining:   int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+			Expired:         !now.Before(cert.NotAfter),
+			RotationOverdue: now.After(deadline),
+		}, nil
+	}
+	return nil, fmt.Errorf("no certificate found")
+}
+
+// ResetServingCertificate discards the kubelet serving certificate and restarts kubelet, which then requests a
+// new one through a certificate signing request
+func ResetServingCertificate(logger *logrus.Logger) error {
+	files, err := filepath.Glob(filepath.Join(kubeletPKIDir, "kubelet-server-*.pem"))
+	if err != nil {
+		return fmt.Errorf("failed to list kubelet serving certificates: %w", err)
+	}
+	logger.Infof("Removing the kubelet serving certificates %v", files)
+	if fileErrors := fsutil.RemoveFiles(files, logger); len(fileErrors) > 0 {
+		return fmt.Errorf("failed to remove kubelet serving certificates: %v", fileErrors[0])
+	}
+	if err := sysutil.RestartService("kubelet"); err != nil {
+		return fmt.Errorf("failed to restart kubelet to request a serving certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package kubelet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCertificatePEM creates a certificate valid from notBefore to notAfter, bundled with its key like kubelet
+// writes it
+func newCertificatePEM(t *testing.T, notBefore, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "system:node:test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+}
+
+func TestParseCertificateExpiry(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		notBefore   time.Time
+		notAfter    time.Time
+		wantDays    int
+		wantExpired bool
+		wantOverdue bool
+	}{
+		{name: "fresh", notBefore: now.AddDate(0, 0, -10), notAfter: now.AddDate(0, 0, 355), wantDays: 355},
+		{name: "rotation due", notBefore: now.AddDate(0, 0, -300), notAfter: now.AddDate(0, 0, 65), wantDays: 65},
+		{name: "rotation overdue", notBefore: now.AddDate(0, 0, -340), notAfter: now.AddDate(0, 0, 25), wantDays: 25, wantOverdue: true},
+		{name: "expired", notBefore: now.AddDate(0, 0, -366), notAfter: now.Add(-time.Hour), wantDays: -1, wantExpired: true, wantOverdue: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiry, err := parseCertificateExpiry(newCertificatePEM(t, tt.notBefore, tt.notAfter), now)
+			if err != nil {
+				t.Fatalf("parseCertificateExpiry() unexpected error = %v", err)
+			}
+			if expiry.DaysRemaining != tt.wantDays || expiry.Expired != tt.wantExpired || expiry.RotationOverdue != tt.wantOverdue {
+				t.Errorf("parseCertificateExpiry() = %+v, want %d days remaining, expired %t, rotation overdue %t",
+					expiry, tt.wantDays, tt.wantExpired, tt.wantOverdue)
+			}
+		})
+	}
+
+	if _, err := parseCertificateExpiry([]byte("not a certificate"), now); err == nil {
+		t.Error("parseCertificateExpiry() expected an error without a certificate")
+	}
+}
+
+func TestReadCertificates(t *testing.T) {
+	dir := t.TempDir()
+	originalClient, originalServing := clientCertPath, servingCertPath
+	defer func() { clientCertPath, servingCertPath = originalClient, originalServing }()
+	clientCertPath = filepath.Join(dir, "kubelet-client-current.pem")
+	servingCertPath = filepath.Join(dir, "kubelet-server-current.pem")
+	now := time.Now()
+
+	certs, err := ReadCertificates(now)
+	if err != nil || certs != nil {
+		t.Fatalf("ReadCertificates() without certificates = %+v, %v, want nil", certs, err)
+	}
+
+	if err := os.WriteFile(clientCertPath, newCertificatePEM(t, now.AddDate(0, 0, -1), now.AddDate(1, 0, 0)), 0o600); err != nil {
+		t.Fatalf("failed to write client certificate: %v", err)
+	}
+	certs, err = ReadCertificates(now)
+	if err != nil {
+		t.Fatalf("ReadCertificates() unexpected error = %v", err)
+	}
+	if certs.Client == nil || certs.Serving != nil {
+		t.Errorf("ReadCertificates() = %+v, want only the client certificate", certs)
+	}
+}
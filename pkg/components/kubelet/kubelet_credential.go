@@ -0,0 +1,156 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// credentialUnauthorized is the rejection the API server answers a client certificate it no longer accepts with, for
+// example after the cluster rotated its CAs or the node's certificate signing request was purged
+const credentialUnauthorized = "Unauthorized"
+
+// credentialTLSRejections are the TLS alerts the API server sends when it refuses the client certificate during the
+// handshake. An API server certificate kubelet does not trust, expired or signed by an unknown authority, is not a
+// rejection of the client certificate: the cluster CA check reports it and only a re-bootstrap fixes it.
+var credentialTLSRejections = []string{
+	"tls: bad certificate",
+	"tls: expired certificate",
+	"tls: certificate required",
+}
+
+// kubeletUnauthorizedPattern matches the Unauthorized errors of the kubelet API client in its journal, as in
+// `failed to list *v1.Node: Unauthorized` or `"Failed to update lease" err="Unauthorized"`. Other components logging
+// to the kubelet journal, such as image pulls answered with "401 Unauthorized" by a registry, do not match.
+var kubeletUnauthorizedPattern = regexp.MustCompile(`(err="|: )Unauthorized("|$)`)
+
+// credentialJournalWindow and credentialJournalThreshold decide when rejections in the kubelet journal are persistent
+// rather than a single failed request, such as one sent while the API server was rotating its own certificate
+const (
+	credentialJournalWindow    = "-10min"
+	credentialJournalThreshold = 5
+)
+
+// credentialKubeconfigPath is the kubeconfig the client certificate is checked with, replaced in tests
+var credentialKubeconfigPath = KubeletKubeconfigPath
+
+// credentialRejection returns the credential rejection in the output of a kubectl request to the API server, empty
+// when there is none
+func credentialRejection(output string) string {
+	if strings.Contains(output, "("+credentialUnauthorized+")") {
+		return credentialUnauthorized
+	}
+	return tlsRejection(output)
+}
+
+// journalCredentialRejection returns the credential rejection in a kubelet journal line, empty when there is none.
+// TLS alerts only count when the line names apiServer, kubelet also connects to other TLS endpoints.
+func journalCredentialRejection(line, apiServer string) string {
+	if kubeletUnauthorizedPattern.MatchString(strings.TrimSpace(line)) {
+		return credentialUnauthorized
+	}
+	if apiServer == "" || !strings.Contains(line, apiServer) {
+		return ""
+	}
+	return tlsRejection(line)
+}
+
+// tlsRejection returns the TLS alert the peer rejected the client certificate with in output, empty when there is none
+func tlsRejection(output string) string {
+	for _, rejection := range credentialTLSRejections {
+		if strings.Contains(output, "remote error: "+rejection) {
+			return rejection
+		}
+	}
+	return ""
+}
+
+// credentialAPIServer returns the host of the API server in the kubelet kubeconfig, empty when it cannot be read
+func credentialAPIServer() string {
+	data, err := utils.RunCommandWithOutput("cat", credentialKubeconfigPath)
+	if err != nil {
+		return ""
+	}
+	serverURL, err := utils.ExtractServerURL([]byte(data))
+	if err != nil {
+		return ""
+	}
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// CheckClientCredential reports why the API server rejects the kubelet client certificate, empty when it is accepted
+// or kubelet has no client certificate yet. The node is read with the kubelet kubeconfig and the recent kubelet
+// journal is searched for repeated rejections; network failures are not rejections and are ignored.
+func CheckClientCredential(ctx context.Context, nodeName string) string {
+	if !utils.FileExists(credentialKubeconfigPath) {
+		return ""
+	}
+	_, err := utils.RunCommandContext(ctx, "kubectl", "--kubeconfig", credentialKubeconfigPath, "get", "node", nodeName, "-o", "name")
+	if err != nil {
+		if rejection := credentialRejection(err.Error()); rejection != "" {
+			return fmt.Sprintf("API server rejected the kubelet client certificate: %s", rejection)
+		}
+	}
+
+	apiServer := credentialAPIServer()
+	journal, err := utils.RunCommandContext(ctx, "journalctl", "-u", "kubelet", "--since", credentialJournalWindow, "--no-pager", "-o", "cat")
+	if err != nil {
+		return ""
+	}
+	counts := map[string]int{}
+	for _, line := range strings.Split(journal, "\n") {
+		if rejection := journalCredentialRejection(line, apiServer); rejection != "" {
+			counts[rejection]++
+		}
+	}
+	for _, rejection := range append([]string{credentialUnauthorized}, credentialTLSRejections...) {
+		if counts[rejection] >= credentialJournalThreshold {
+			return fmt.Sprintf("kubelet logged %d %q errors in the last %s", counts[rejection], rejection, strings.TrimPrefix(credentialJournalWindow, "-"))
+		}
+	}
+	return ""
+}
+
+// ResetClientCredential discards the kubelet client certificate and restarts kubelet, which requests a new one
+// through TLS bootstrap with the credential of the configured authentication method in the bootstrap kubeconfig
+func ResetClientCredential(logger *logrus.Logger) error {
+	if !utils.FileExists(KubeletBootstrapKubeconfigPath) {
+		return fmt.Errorf("bootstrap kubeconfig %s not found, re-bootstrap the node to restore it", KubeletBootstrapKubeconfigPath)
+	}
+	if err := utils.StopService("kubelet"); err != nil {
+		return fmt.Errorf("failed to stop kubelet: %w", err)
+	}
+
+	// Without a client certificate in the PKI directory the TPM unseal step restores the sealed one, drop it as well
+	files := []string{KubeletKubeconfigPath, filepath.Join(kubeletSealedPKIDir, sealedPKIArchive)}
+	entries, err := os.ReadDir(kubeletPKIDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", kubeletPKIDir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(kubeletPKIDir, entry.Name()))
+		}
+	}
+	logger.Infof("Removing the kubelet kubeconfig and the files of the kubelet PKI directory %s", kubeletPKIDir)
+	if fileErrors := utils.RemoveFiles(files, logger); len(fileErrors) > 0 {
+		return fmt.Errorf("failed to remove kubelet client credentials: %v", fileErrors[0])
+	}
+
+	if err := utils.RestartService("kubelet"); err != nil {
+		return fmt.Errorf("failed to start kubelet for TLS bootstrap: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// fakeCredentialRunner answers the node probe with probeErr, the kubelet journal query with journal and the read of
+// the kubelet kubeconfig with kubeconfig
+type fakeCredentialRunner struct {
+	probeErr   error
+	journal    string
+	kubeconfig string
+}
+
+func (f *fakeCredentialRunner) Run(_ context.Context, cmd utils.Command) (*utils.CommandResult, error) {
+	switch cmd.Name {
+	case "kubectl":
+		return &utils.CommandResult{}, f.probeErr
+	case "journalctl":
+		return &utils.CommandResult{Output: f.journal}, nil
+	case "cat":
+		if f.kubeconfig == "" {
+			return nil, errors.New("cat: no such file")
+		}
+		return &utils.CommandResult{Output: f.kubeconfig}, nil
+	}
+	return nil, errors.New("unexpected command " + cmd.Name)
+}
+
+func TestCheckClientCredential(t *testing.T) {
+	original := credentialKubeconfigPath
+	credentialKubeconfigPath = filepath.Join(t.TempDir(), "kubeconfig")
+	t.Cleanup(func() { credentialKubeconfigPath = original })
+	if got := CheckClientCredential(context.Background(), "edge-node-1"); got != "" {
+		t.Errorf("CheckClientCredential() without a kubelet kubeconfig = %q, want no rejection", got)
+	}
+	if err := os.WriteFile(credentialKubeconfigPath, []byte("apiVersion: v1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := strings.Repeat("E1016 reflector.go:150] failed to list *v1.Node: Unauthorized\n", credentialJournalThreshold)
+	imagePulls := strings.Repeat(`E1016 kuberuntime_manager.go:1274] "Unhandled Error" err="failed to pull image: unexpected status from HEAD request: 401 Unauthorized"`+"\n", credentialJournalThreshold)
+	tlsAlerts := strings.Repeat(`E1016 controller.go:145] "Failed to ensure lease exists" err="Get \"https://cluster.hcp.eastus.azmk8s.io:443/apis\": remote error: tls: bad certificate"`+"\n", credentialJournalThreshold)
+	kubeconfig := "clusters:\n- cluster:\n    server: https://cluster.hcp.eastus.azmk8s.io:443\n"
+	tests := []struct {
+		name   string
+		runner *fakeCredentialRunner
+		want   string
+	}{
+		{name: "accepted", runner: &fakeCredentialRunner{}},
+		{name: "probe rejected", runner: &fakeCredentialRunner{probeErr: errors.New("error: You must be logged in to the server (Unauthorized)")}, want: "API server rejected"},
+		{name: "network failure", runner: &fakeCredentialRunner{probeErr: errors.New("dial tcp 10.0.0.1:443: i/o timeout")}},
+		{name: "journal rejections", runner: &fakeCredentialRunner{journal: rejected}, want: "kubelet logged 5"},
+		{name: "single journal rejection", runner: &fakeCredentialRunner{journal: "E1016 reflector.go:150] failed to list *v1.Node: Unauthorized\n"}},
+		{name: "registry rejections", runner: &fakeCredentialRunner{journal: imagePulls}},
+		{name: "API server TLS alerts", runner: &fakeCredentialRunner{journal: tlsAlerts, kubeconfig: kubeconfig}, want: "tls: bad certificate"},
+		{name: "TLS alerts without the API server", runner: &fakeCredentialRunner{journal: tlsAlerts}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(utils.SetCommandRunner(tt.runner))
+			got := CheckClientCredential(context.Background(), "edge-node-1")
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("CheckClientCredential() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialRejection(t *testing.T) {
+	tests := map[string]string{
+		"error: You must be logged in to the server (Unauthorized)":                          "Unauthorized",
+		"Unable to connect to the server: remote error: tls: bad certificate":                "tls: bad certificate",
+		"Unable to connect to the server: x509: certificate has expired or is not yet valid": "",
+		"x509: certificate signed by unknown authority":                                      "",
+		"dial tcp 10.0.0.1:443: connect: connection refused":                                 "",
+	}
+	for output, want := range tests {
+		if got := credentialRejection(output); got != want {
+			t.Errorf("credentialRejection(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestJournalCredentialRejection(t *testing.T) {
+	const apiServer = "cluster.hcp.eastus.azmk8s.io:443"
+	tests := map[string]string{
+		`E1016 reflector.go:150] failed to list *v1.Node: Unauthorized`:                                                                         "Unauthorized",
+		`E1016 reflector.go:158] "Unhandled Error" err="failed to list *v1.Pod: Unauthorized" logger="UnhandledError"`:                          "Unauthorized",
+		`E1016 controller.go:145] "Failed to update lease" err="Unauthorized"`:                                                                  "Unauthorized",
+		`E1016 remote_image.go:180] "PullImage from image service failed" err="unexpected status: 401 Unauthorized"`:                            "",
+		`E1016 controller.go:145] "Failed" err="Get \"https://cluster.hcp.eastus.azmk8s.io:443/apis\": remote error: tls: expired certificate"`: "tls: expired certificate",
+		`E1016 pull.go:80] "Failed" err="Get \"https://registry.example.com/v2/\": remote error: tls: bad certificate"`:                         "",
+		`E1016 controller.go:145] "Failed" err="x509: certificate has expired or is not yet valid"`:                                             "",
+	}
+	for line, want := range tests {
+		if got := journalCredentialRejection(line, apiServer); got != want {
+			t.Errorf("journalCredentialRejection(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Drainer cordons and drains the node and deletes it from the cluster before unbootstrap stops kubelet,
+// so its pods are rescheduled elsewhere instead of being orphaned on a node that never returns
+type Drainer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewDrainer creates a new Drainer
+func NewDrainer(logger *logrus.Logger) *Drainer {
+	return &Drainer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (d *Drainer) GetName() string {
+	return "NodeDrain"
+}
+
+// IsCompleted returns true when draining is disabled or kubelet never registered the node
+func (d *Drainer) IsCompleted(_ context.Context) bool {
+	return !d.config.IsDrainEnabled() || (!IsAdopted() && !fsutil.FileExists(KubeletKubeconfigPath))
+}
+
+// Plan describes the drain for dry runs
+func (d *Drainer) Plan(_ context.Context) []string {
+	nodeName := d.config.GetNodeName()
+	return []string{
+		fmt.Sprintf("cordon node %s and evict its pods, waiting up to %s", nodeName, d.config.GetDrainTimeout()),
+		fmt.Sprintf("delete node %s from the cluster once drained", nodeName),
+	}
+}
+
+// Execute cordons and drains the node, then deletes the Node object. A cluster that cannot be reached is
+// skipped with a warning so a disconnected machine can still be cleaned up; a drain that does not complete
+// fails the step and leaves the node in the cluster.
+func (d *Drainer) Execute(ctx context.Context) error {
+	nodeName := d.config.GetNodeName()
+	if nodeName == "" {
+		return fmt.Errorf("failed to determine node name")
+	}
+
+	kubeconfigPath, err := WriteAdminKubeconfig(ctx, d.logger)
+	if err != nil {
+		d.logger.Warnf("Skipping drain of node %s, cluster credentials are not available: %v", nodeName, err)
+		return nil
+	}
+	defer fsutil.CleanupTempFile(kubeconfigPath)
+
+	output, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "get", "node", nodeName, "--ignore-not-found", "-o", "name")
+	if err != nil {
+		d.logger.Warnf("Skipping drain of node %s, failed to query the cluster: %v", nodeName, err)
+		return nil
+	}
+	if strings.TrimSpace(output) == "" {
+		d.logger.Infof("Node %s is not registered in the cluster, nothing to drain", nodeName)
+		return nil
+	}
+
+	d.logger.Infof("Cordoning and draining node %s", nodeName)
+	if err := sysutil.RunSystemCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "cordon", nodeName); err != nil {
+		return fmt.Errorf("failed to cordon node %s: %w", nodeName, err)
+	}
+	// kubectl enforces the drain timeout itself, the command may outlast the default command timeout
+	timeout := d.config.GetDrainTimeout()
+	drain := sysutil.Command{Name: "kubectl", Args: drainArgs(kubeconfigPath, nodeName, d.config.Node.Drain, timeout), Stream: true, Timeout: timeout + time.Minute}
+	if _, err := sysutil.GetCommandRunner().Run(ctx, drain); err != nil {
+		return fmt.Errorf("failed to drain node %s, it stays cordoned in the cluster; set node.drain.force to delete unmanaged pods "+
+			"or check the PodDisruptionBudgets blocking evictions: %w", nodeName, err)
+	}
+
+	d.logger.Infof("Node %s drained, deleting it from the cluster", nodeName)
+	if err := sysutil.RunSystemCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "delete", "node", nodeName, "--ignore-not-found"); err != nil {
+		return fmt.Errorf("failed to delete node %s: %w", nodeName, err)
+	}
+	return nil
+}
+
+// drainArgs returns the kubectl arguments draining the node. DaemonSet pods are left alone as their controller
+// ignores cordons, and emptyDir data is deleted with its pods as the node is going away.
+func drainArgs(kubeconfigPath, nodeName string, drain *config.DrainConfig, timeout time.Duration) []string {
+	args := []string{"--kubeconfig", kubeconfigPath, "drain", nodeName,
+		"--ignore-daemonsets", "--delete-emptydir-data", fmt.Sprintf("--timeout=%s", timeout)}
+	if drain == nil {
+		return args
+	}
+	if drain.GracePeriod != "" {
+		if gracePeriod, err := time.ParseDuration(drain.GracePeriod); err == nil {
+			args = append(args, fmt.Sprintf("--grace-period=%d", int(gracePeriod.Seconds())))
+		}
+	}
+	if drain.Force {
+		args = append(args, "--force")
+	}
+	return args
+}
@@ -0,0 +1,29 @@
+package kubelet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestDrainArgs(t *testing.T) {
+	base := []string{"--kubeconfig", "/tmp/admin", "drain", "edge-node-1", "--ignore-daemonsets", "--delete-emptydir-data", "--timeout=5m0s"}
+	tests := []struct {
+		name  string
+		drain *config.DrainConfig
+		want  []string
+	}{
+		{name: "defaults", drain: nil, want: base},
+		{name: "grace period", drain: &config.DrainConfig{GracePeriod: "90s"}, want: append(append([]string{}, base...), "--grace-period=90")},
+		{name: "force", drain: &config.DrainConfig{Force: true}, want: append(append([]string{}, base...), "--force")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := drainArgs("/tmp/admin", "edge-node-1", tt.drain, 5*time.Minute); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("drainArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,24 +2,36 @@ package kubelet
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"maps"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/sirupsen/logrus"
 
 	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/clusterca"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/ca_trust"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/discovery"
+	"go.goms.io/aks/AKSFlexNode/pkg/resolvconf"
+	"go.goms.io/aks/AKSFlexNode/pkg/secrets"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
 )
 
 // Installer handles kubelet installation and configuration
 type Installer struct {
-	config   *config.Config
-	logger   *logrus.Logger
-	mcClient *armcontainerservice.ManagedClustersClient
+	config        *config.Config
+	logger        *logrus.Logger
+	mcClient      *armcontainerservice.ManagedClustersClient
+	keyProtection string // key protection level resolved against the TPM of this machine
+	resolvConf    string // resolv.conf for pods, detected from the host DNS stack
 }
 
 // NewInstaller creates a new kubelet Installer
@@ -37,6 +49,11 @@ func (i *Installer) GetName() string {
 
 // Execute installs and configures kubelet service
 func (i *Installer) Execute(ctx context.Context) error {
+	if IsAdopted() {
+		i.logger.Info("Kubelet was adopted from an existing installation, preserving its configuration")
+		return nil
+	}
+
 	i.logger.Info("Installing and configuring kubelet")
 	// Set up mc client for getting cluster info
 	if err := i.setUpClients(); err != nil {
@@ -62,88 +79,157 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 // Validate validates prerequisites for kubelet installation
 func (i *Installer) Validate(_ context.Context) error {
 	i.logger.Debug("Validating prerequisites for kubelet installation")
-	// No specific prerequisites for kubelet configuration
-	return nil
-}
 
-// configure configures kubelet service with systemd unit file and default settings
-func (i *Installer) configure(ctx context.Context) error {
-	i.logger.Info("Configuring kubelet")
+	kubeletConfig := i.config.Node.Kubelet
+	if IsAdopted() {
+		return nil
+	}
+
+	// Pods need a resolv.conf with name servers they can reach
+	i.configureResolvConf()
+
+	// TPM key protection needs a TPM and the tools to seal with it
+	if mode := kubeletConfig.KeyProtection; mode == config.KeyProtectionTPM || mode == config.KeyProtectionAuto {
+		protection, warning, err := resolveKeyProtection(mode, detectTPM(tpmDevicePath))
+		if err != nil {
+			return err
+		}
+		if warning != "" {
+			i.logger.Warn(warning)
+		}
+		i.keyProtection = protection
+	}
+
+	// Swap needs a kubelet and kernel that can account for it
+	if kubeletConfig.SwapBehavior != "" {
+		warnings, err := validateSwap(kubeletConfig.SwapBehavior, i.config.GetKubernetesVersion(), sysfsRoot, procSwapsPath)
+		if err != nil {
+			return fmt.Errorf("invalid kubelet swap configuration: %w", err)
+		}
+		for _, warning := range warnings {
+			i.logger.Warn(warning)
+		}
+	}
 
-	// Clean up any existing stale configuration files
-	if err := i.cleanupExistingConfiguration(); err != nil {
-		i.logger.Warnf("Failed to cleanup existing kubelet configuration: %v", err)
-		// Continue anyway - we'll overwrite the files
+	// Resource manager settings must fit the CPUs and NUMA nodes of this machine
+	if !hasResourceManagerSettings(kubeletConfig) {
+		return nil
 	}
+	topology, err := detectHardwareTopology(sysfsRoot)
+	if err != nil {
+		return fmt.Errorf("failed to detect hardware topology: %w", err)
+	}
+	i.logger.Debugf("Detected %d online CPUs and NUMA nodes %v", len(topology.OnlineCPUs), topology.NUMANodes)
 
-	// Ensure required packages are installed
-	if err := i.ensureRequiredPackages(); err != nil {
-		return fmt.Errorf("failed to install required packages: %w", err)
+	warnings, err := validateResourceManagers(kubeletConfig, topology)
+	if err != nil {
+		return fmt.Errorf("invalid kubelet resource manager configuration: %w", err)
+	}
+	for _, warning := range warnings {
+		i.logger.Warn(warning)
 	}
+	return nil
+}
+
+// Plan describes the kubelet configuration for dry runs. It runs after Validate resolved the key protection.
+func (i *Installer) Plan(_ context.Context) []string {
+	if IsAdopted() {
+		return []string{"keep the configuration of the adopted kubelet"}
+	}
+	files := []string{kubeletDefaultsPath, kubeletServicePath, kubeletContainerdConfig, kubeletTLSBootstrapConfig, kubeletTokenScriptPath}
+	if !i.config.IsARCEnabled() && i.config.IsSPConfigured() {
+		files = append(files, kubeletClientSecretPath)
+	}
+	if i.config.Node.Kubelet.SwapBehavior != "" {
+		files = append(files, kubeletConfigPath)
+	}
+	if i.keyProtection == config.KeyProtectionTPM {
+		files = append(files, kubeletKeySealScriptPath, keySealServiceUnitPath, keySealPathUnitPath, kubeletKeyProtectionConfig)
+	}
+	return []string{
+		"fetch the cluster CA and API server from the cluster admin credentials",
+		"write " + KubeletBootstrapKubeconfigPath,
+		"write " + strings.Join(files, ", "),
+		"stop kubelet while switching to the new configuration",
+	}
+}
+
+// configure configures kubelet service with systemd unit file and default settings.
+// The files are rendered and staged first, then switched into place together while kubelet is stopped,
+// so kubelet never starts against a partially written configuration.
+func (i *Installer) configure(ctx context.Context) error {
+	i.logger.Info("Configuring kubelet")
 
 	// Create required directories
 	if err := i.createRequiredDirectories(); err != nil {
 		return fmt.Errorf("failed to create required directories: %w", err)
 	}
 
+	apply := newConfigApply(i.logger)
+
+	// Create kubelet configuration file for settings without a command line flag
+	i.createKubeletConfigFile(apply)
+
 	// Create kubelet defaults file
-	if err := i.createKubeletDefaultsFile(); err != nil {
-		return err
-	}
+	i.createKubeletDefaultsFile(apply)
 
 	// Create token script for exec credential authentication (Arc or Service Principal)
-	if err := i.createTokenScript(); err != nil {
+	if err := i.createTokenScript(ctx, apply); err != nil {
 		return err
 	}
 
-	// Create kubeconfig with exec credential provider
-	if err := i.createKubeconfigWithExecCredential(ctx); err != nil {
+	// Create bootstrap kubeconfig with exec credential provider, used only for TLS bootstrap
+	if err := i.createBootstrapKubeconfig(ctx, apply); err != nil {
 		return err
 	}
 
 	// Create kubelet containerd configuration
-	if err := i.createKubeletContainerdConfig(); err != nil {
-		return err
-	}
+	i.createKubeletContainerdConfig(apply)
 
 	// Create kubelet TLS bootstrap configuration
-	if err := i.createKubeletTLSBootstrapConfig(); err != nil {
-		return err
-	}
+	i.createKubeletTLSBootstrapConfig(apply)
+
+	// Keep the kubelet client key in memory and sealed to the TPM when requested
+	i.createKeyProtectionConfig(apply)
 
 	// Create main kubelet service
-	if err := i.createKubeletServiceFile(); err != nil {
-		return err
-	}
+	i.createKubeletServiceFile(apply)
 
-	return nil
+	return apply.apply(i.staleConfigurationFiles(), i.resetKubeletState)
 }
 
-// cleanupExistingConfiguration removes any existing kubelet configuration that may be corrupted
-func (i *Installer) cleanupExistingConfiguration() error {
-	i.logger.Debug("Cleaning up existing kubelet configuration files")
+// resetKubeletState drops kubelet state that conflicts with the new configuration. It runs while kubelet is stopped.
+func (i *Installer) resetKubeletState() error {
+	// Drop CPU and memory manager checkpoints that would keep kubelet from starting after a policy change
+	if err := i.removeStaleManagerState(kubeletCPUManagerStatePath, i.config.Node.Kubelet.CPUManagerPolicy, "none"); err != nil {
+		return fmt.Errorf("failed to reset kubelet CPU manager state: %w", err)
+	}
+	if err := i.removeStaleManagerState(kubeletMemoryManagerStatePath, i.config.Node.Kubelet.MemoryManagerPolicy, "None"); err != nil {
+		return fmt.Errorf("failed to reset kubelet memory manager state: %w", err)
+	}
+
+	// Drop a kubeconfig left over from the exec-credential-only setup so kubelet requests a client certificate
+	return i.removeLegacyKubeconfig(KubeletKubeconfigPath)
+}
 
-	// List of files to clean up
-	kubeconfigPath := filepath.Join(i.config.Paths.Kubernetes.ConfigDir, "kubeconfig")
-	filesToClean := []string{
+// staleConfigurationFiles lists the kubelet configuration files a previous configuration may have left behind.
+// Files that are not part of the new configuration are removed when it is switched into place.
+func (i *Installer) staleConfigurationFiles() []string {
+	return []string{
 		kubeletDefaultsPath,
 		kubeletServicePath,
 		kubeletContainerdConfig,
 		kubeletTLSBootstrapConfig,
-		kubeconfigPath,
+		kubeletConfigPath,
+		filepath.Join(i.config.Paths.Kubernetes.ConfigDir, "kubeconfig"),
 		kubeletTokenScriptPath,
+		kubeletClientSecretPath,
+		KubeletBootstrapKubeconfigPath,
+		kubeletKeyProtectionConfig,
+		kubeletKeySealScriptPath,
+		keySealPathUnitPath,
+		keySealServiceUnitPath,
 	}
-
-	for _, file := range filesToClean {
-		if utils.FileExists(file) {
-			i.logger.Debugf("Removing existing kubelet config file: %s", file)
-			if err := utils.RunCleanupCommand(file); err != nil {
-				i.logger.Warnf("Failed to remove %s: %v", file, err)
-			}
-		}
-	}
-
-	return nil
 }
 
 // createRequiredDirectories creates directories that kubelet expects to exist
@@ -167,38 +253,28 @@ func (i *Installer) createRequiredDirectories() error {
 	return nil
 }
 
-// ensureRequiredPackages installs packages required by kubelet (jq for token script, iptables for service)
-func (i *Installer) ensureRequiredPackages() error {
-	packages := []string{"jq", "iptables"}
-
-	i.logger.Info("Checking for required kubelet packages")
+// createKubeletDefaultsFile stages the kubelet defaults configuration file
+func (i *Installer) createKubeletDefaultsFile(apply *configApply) {
+	apply.stage(kubeletDefaultsPath, []byte(i.renderKubeletDefaults()), 0o644)
+}
 
-	for _, pkg := range packages {
-		if err := utils.RunSystemCommand("which", pkg); err != nil {
-			i.logger.Infof("Installing %s...", pkg)
-			if err := utils.RunSystemCommand("apt", "install", "-y", pkg); err != nil {
-				return fmt.Errorf("failed to install %s: %w", pkg, err)
-			}
-			i.logger.Infof("Successfully installed %s", pkg)
-		} else {
-			i.logger.Debugf("%s is already installed", pkg)
-		}
+// createKubeletConfigFile stages the kubelet configuration file when swap is enabled.
+// Without swap every setting is passed as a flag and no configuration file is used.
+func (i *Installer) createKubeletConfigFile(apply *configApply) {
+	swapBehavior := i.config.Node.Kubelet.SwapBehavior
+	if swapBehavior == "" {
+		return
 	}
 
-	i.logger.Info("All required kubelet packages are available")
-	return nil
+	i.logger.Infof("Enabling kubelet swap support with swap behavior %s", swapBehavior)
+	apply.stage(kubeletConfigPath, []byte(renderKubeletConfigFile(swapBehavior)), 0o644)
 }
 
-// createKubeletDefaultsFile creates the kubelet defaults configuration file
-func (i *Installer) createKubeletDefaultsFile() error {
-	// Create kubelet default config
-	labels := make([]string, 0, len(i.config.Node.Labels))
-	for key, value := range i.config.Node.Labels {
-		labels = append(labels, fmt.Sprintf("%s=%s", key, value))
-	}
-
-	kubeletDefaults := fmt.Sprintf(`KUBELET_NODE_LABELS="%s"
-KUBELET_CONFIG_FILE_FLAGS=""
+// renderKubeletDefaults renders the kubelet defaults file content.
+// Map-based settings are rendered in sorted key order so the output is stable across runs.
+func (i *Installer) renderKubeletDefaults() string {
+	return fmt.Sprintf(`KUBELET_NODE_LABELS="%s"
+KUBELET_CONFIG_FILE_FLAGS="%s"
 KUBELET_FLAGS="\
   --v=%d \
   --address=0.0.0.0 \
@@ -221,66 +297,177 @@ KUBELET_FLAGS="\
   --pod-max-pids=-1  \
   --protect-kernel-defaults=true  \
   --read-only-port=0  \
-  --resolv-conf=/run/systemd/resolve/resolv.conf  \
+  --resolv-conf=%s  \
   --streaming-connection-idle-timeout=4h  \
   --tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_RSA_WITH_AES_256_GCM_SHA384,TLS_RSA_WITH_AES_128_GCM_SHA256 \
-  "`,
-		strings.Join(labels, ","),
+%s  "`,
+		mapToKeyValuePairs(i.nodeLabels(), ","),
+		i.kubeletConfigFileFlags(),
 		i.config.Node.Kubelet.Verbosity,
-		i.config.Node.Kubelet.DNSServiceIP,
+		i.config.ClusterDNS(),
 		mapToEvictionThresholds(i.config.Node.Kubelet.EvictionHard, ","),
 		mapToKeyValuePairs(i.config.Node.Kubelet.KubeReserved, ","),
 		i.config.Node.Kubelet.ImageGCHighThreshold,
 		i.config.Node.Kubelet.ImageGCLowThreshold,
 		i.config.Node.MaxPods,
-		i.config.Containerd.PauseImage)
+		i.config.Containerd.PauseImage,
+		i.kubeletResolvConf(),
+		i.optionalKubeletFlags())
+}
 
-	// Ensure /etc/default directory exists
-	if err := utils.RunSystemCommand("mkdir", "-p", etcDefaultDir); err != nil {
-		return fmt.Errorf("failed to create %s directory: %w", etcDefaultDir, err)
+// nodeLabels returns the labels kubelet registers the node with: node.labels and, with node.fleetLabels,
+// the discovered fleet labels. Labels set in node.labels take precedence over discovered ones.
+func (i *Installer) nodeLabels() map[string]string {
+	if !i.config.Node.FleetLabels {
+		return i.config.Node.Labels
 	}
+	labels := discovery.FleetLabels(i.config, discovery.Detect("/"))
+	maps.Copy(labels, i.config.Node.Labels)
+	return labels
+}
 
-	// Write kubelet defaults file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(kubeletDefaultsPath, []byte(kubeletDefaults), 0o644); err != nil {
-		return fmt.Errorf("failed to create kubelet defaults file: %w", err)
+// configureResolvConf picks the resolv.conf kubelet hands to pods and warns about setups pods may not resolve names with
+func (i *Installer) configureResolvConf() {
+	if override := i.config.Node.Kubelet.ResolvConf; override != "" {
+		_, warnings := resolvconf.Check("/", override)
+		for _, warning := range warnings {
+			i.logger.Warnf("Configured node.kubelet.resolvConf: %s", warning)
+		}
+		return
 	}
 
-	return nil
+	detected := resolvconf.Detect("/")
+	i.resolvConf = detected.Path
+	i.logger.Infof("Detected %s DNS stack, kubelet uses %s (name servers: %s)",
+		detected.Stack, detected.Path, strings.Join(detected.Nameservers, ", "))
+	for _, warning := range detected.Warnings {
+		i.logger.Warnf("DNS preflight: %s", warning)
+	}
 }
 
-// createSystemdDropInFile creates a systemd drop-in file with the given content
-func (i *Installer) createSystemdDropInFile(filePath, content, description string) error {
-	// Ensure kubelet service.d directory exists
-	if err := utils.RunSystemCommand("mkdir", "-p", kubeletServiceDir); err != nil {
-		return fmt.Errorf("failed to create %s directory: %w", kubeletServiceDir, err)
+// kubeletResolvConf returns the resolv.conf kubelet hands to pods: the configured override,
+// the one detected from the host DNS stack, or the systemd-resolved upstream file
+func (i *Installer) kubeletResolvConf() string {
+	if override := i.config.Node.Kubelet.ResolvConf; override != "" {
+		return override
 	}
+	if i.resolvConf != "" {
+		return i.resolvConf
+	}
+	return resolvconf.ResolvedPath
+}
 
-	// Write config file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(filePath, []byte(content), 0o644); err != nil {
-		return fmt.Errorf("failed to create %s: %w", description, err)
+// kubeletConfigFileFlags returns the flag pointing kubelet at its configuration file, if one is written
+func (i *Installer) kubeletConfigFileFlags() string {
+	if i.config.Node.Kubelet.SwapBehavior == "" {
+		return ""
 	}
+	return fmt.Sprintf("--config=%s", kubeletConfigPath)
+}
 
-	return nil
+// optionalKubeletFlags renders kubelet flags that are only passed when configured, one per line
+func (i *Installer) optionalKubeletFlags() string {
+	var flags []string
+
+	// Only pass --hostname-override when configured so kubelet keeps using the system hostname otherwise
+	if i.config.Node.HostnameOverride != "" {
+		flags = append(flags, fmt.Sprintf("--hostname-override=%s", i.config.GetNodeName()))
+	}
+
+	// An external cloud controller manager initializes the node, kubelet only registers it
+	if i.config.Node.Kubelet.CloudProvider != "" {
+		flags = append(flags, fmt.Sprintf("--cloud-provider=%s", i.config.Node.Kubelet.CloudProvider))
+	}
+	if i.config.Node.Kubelet.ProviderID != "" {
+		flags = append(flags, fmt.Sprintf("--provider-id=%s", i.config.Node.Kubelet.ProviderID))
+	}
+	if len(i.config.Node.Taints) > 0 {
+		flags = append(flags, fmt.Sprintf("--register-with-taints=%s", strings.Join(i.config.Node.Taints, ",")))
+	}
+	// Caps the pod sandboxes by CPU count on small machines, maxPods still applies
+	if i.config.Node.PodsPerCore > 0 {
+		flags = append(flags, fmt.Sprintf("--pods-per-core=%d", i.config.Node.PodsPerCore))
+	}
+
+	// Lower API client rate limits keep many nodes behind one egress IP within the API server limits
+	if i.config.Node.Kubelet.KubeAPIQPS > 0 {
+		flags = append(flags, fmt.Sprintf("--kube-api-qps=%d", i.config.Node.Kubelet.KubeAPIQPS))
+	}
+	if i.config.Node.Kubelet.KubeAPIBurst > 0 {
+		flags = append(flags, fmt.Sprintf("--kube-api-burst=%d", i.config.Node.Kubelet.KubeAPIBurst))
+	}
+
+	// CPU pinning and NUMA alignment for latency-sensitive workloads
+	kubeletConfig := i.config.Node.Kubelet
+	if kubeletConfig.CPUManagerPolicy != "" {
+		flags = append(flags, fmt.Sprintf("--cpu-manager-policy=%s", kubeletConfig.CPUManagerPolicy))
+	}
+	if kubeletConfig.TopologyManagerPolicy != "" {
+		flags = append(flags, fmt.Sprintf("--topology-manager-policy=%s", kubeletConfig.TopologyManagerPolicy))
+	}
+	if kubeletConfig.ReservedSystemCPUs != "" {
+		flags = append(flags, fmt.Sprintf("--reserved-system-cpus=%s", kubeletConfig.ReservedSystemCPUs))
+	}
+	if kubeletConfig.MemoryManagerPolicy != "" {
+		flags = append(flags, fmt.Sprintf("--memory-manager-policy=%s", kubeletConfig.MemoryManagerPolicy))
+	}
+	if kubeletConfig.ReservedMemory != "" {
+		flags = append(flags, fmt.Sprintf("--reserved-memory=%s", kubeletConfig.ReservedMemory))
+	}
+
+	// Operator supplied gates and flags come last so they take precedence over generated flags
+	if len(kubeletConfig.FeatureGates) > 0 {
+		flags = append(flags, fmt.Sprintf("--feature-gates=%s", featureGatesToPairs(kubeletConfig.FeatureGates)))
+	}
+	flags = append(flags, kubeletConfig.ExtraFlags...)
+
+	var rendered strings.Builder
+	for _, flag := range flags {
+		fmt.Fprintf(&rendered, "  %s \\\n", flag)
+	}
+	return rendered.String()
 }
 
-// createKubeletContainerdConfig creates the kubelet containerd configuration
-func (i *Installer) createKubeletContainerdConfig() error {
+// createKubeletContainerdConfig stages the kubelet containerd configuration
+func (i *Installer) createKubeletContainerdConfig(apply *configApply) {
 	containerdConf := `[Service]
 Environment=KUBELET_CONTAINERD_FLAGS="--runtime-request-timeout=15m --container-runtime-endpoint=unix:///run/containerd/containerd.sock"`
 
-	return i.createSystemdDropInFile(kubeletContainerdConfig, containerdConf, "kubelet containerd config file")
+	apply.stage(kubeletContainerdConfig, []byte(containerdConf), 0o644)
 }
 
-// createKubeletTLSBootstrapConfig creates the kubelet TLS bootstrap configuration
-func (i *Installer) createKubeletTLSBootstrapConfig() error {
-	tlsBootstrapConf := `[Service]
-Environment=KUBELET_TLS_BOOTSTRAP_FLAGS="--kubeconfig /var/lib/kubelet/kubeconfig"`
+// createKubeletTLSBootstrapConfig stages the kubelet TLS bootstrap configuration.
+// Kubelet uses the bootstrap kubeconfig to request a client certificate and writes the
+// resulting cert-based kubeconfig to KubeletKubeconfigPath.
+func (i *Installer) createKubeletTLSBootstrapConfig(apply *configApply) {
+	flags := "--rotate-certificates"
+	// The serving certificate is then requested from the cluster like the client certificate
+	if i.config.Node.Kubelet.ServerTLSBootstrap {
+		flags += " --rotate-server-certificates"
+	}
+	tlsBootstrapConf := fmt.Sprintf(`[Service]
+Environment=KUBELET_TLS_BOOTSTRAP_FLAGS="--bootstrap-kubeconfig %s --kubeconfig %s %s"`,
+		KubeletBootstrapKubeconfigPath, KubeletKubeconfigPath, flags)
 
-	return i.createSystemdDropInFile(kubeletTLSBootstrapConfig, tlsBootstrapConf, "kubelet TLS bootstrap config file")
+	apply.stage(kubeletTLSBootstrapConfig, []byte(tlsBootstrapConf), 0o644)
 }
 
-// createKubeletServiceFile creates the main kubelet systemd service file
-func (i *Installer) createKubeletServiceFile() error {
+// createKeyProtectionConfig stages the key sealing script and the units that run it when the kubelet
+// client key is protected by the TPM
+func (i *Installer) createKeyProtectionConfig(apply *configApply) {
+	if i.keyProtection != config.KeyProtectionTPM {
+		return
+	}
+
+	i.logger.Info("Protecting the kubelet client key with the TPM")
+	apply.stage(kubeletKeySealScriptPath, []byte(renderKeySealScript()), 0o755)
+	apply.stage(keySealServiceUnitPath, []byte(renderKeySealServiceUnit()), 0o644)
+	apply.stage(keySealPathUnitPath, []byte(renderKeySealPathUnit()), 0o644)
+	apply.stage(kubeletKeyProtectionConfig, []byte(renderKeyProtectionDropIn()), 0o644)
+}
+
+// createKubeletServiceFile stages the main kubelet systemd service file
+func (i *Installer) createKubeletServiceFile(apply *configApply) {
 	kubeletService := `[Unit]
 Description=Kubelet
 ConditionPathExists=/usr/local/bin/kubelet
@@ -305,27 +492,26 @@ ExecStart=/usr/local/bin/kubelet \
 [Install]
 WantedBy=multi-user.target`
 
-	// Write kubelet service file atomically with proper permissions
-	if err := utils.WriteFileAtomicSystem(kubeletServicePath, []byte(kubeletService), 0o644); err != nil {
-		return fmt.Errorf("failed to create kubelet service file: %w", err)
-	}
-
-	return nil
+	apply.stage(kubeletServicePath, []byte(kubeletService), 0o644)
 }
 
-// createTokenScript creates either Arc or Service Principal token script based on configuration
-func (i *Installer) createTokenScript() error {
+// createTokenScript stages the Arc, Service Principal or Workload Identity token script based on configuration
+func (i *Installer) createTokenScript(ctx context.Context, apply *configApply) error {
 	if i.config.IsARCEnabled() {
-		return i.createArcTokenScript()
+		i.createArcTokenScript(apply)
+		return nil
 	} else if i.config.IsSPConfigured() {
-		return i.createServicePrincipalTokenScript()
+		return i.createServicePrincipalTokenScript(ctx, apply)
+	} else if i.config.IsWorkloadIdentityConfigured() {
+		i.createWorkloadIdentityTokenScript(apply)
+		return nil
 	} else {
-		return fmt.Errorf("no valid authentication method configured - either Arc must be enabled or Service Principal must be configured")
+		return fmt.Errorf("no valid authentication method configured - either Arc must be enabled or Service Principal or Workload Identity must be configured")
 	}
 }
 
 // createArcTokenScript creates the Arc token script for exec credential authentication
-func (i *Installer) createArcTokenScript() error {
+func (i *Installer) createArcTokenScript(apply *configApply) {
 	// Arc HIMDS token script using proven Www-Authenticate challenge approach
 	tokenScript := fmt.Sprintf(`#!/bin/bash
 
@@ -357,25 +543,38 @@ fi
 
 curl -s -H Metadata:true -H "Authorization: Basic $CHALLENGE_TOKEN" $TOKEN_URL | jq "$EXECCREDENTIAL"`, aksServiceResourceID)
 
-	return i.writeTokenScript(tokenScript)
+	apply.stage(kubeletTokenScriptPath, []byte(tokenScript), 0o755)
 }
 
-// createServicePrincipalTokenScript creates the Service Principal token script
-func (i *Installer) createServicePrincipalTokenScript() error {
+// createServicePrincipalTokenScript creates the Service Principal token script, requesting tokens from the
+// Entra ID authority of the configured Azure cloud. The secret is kept in its own root-only file rather than
+// in the script, and curl reads it from there so it does not show up in the process list. A secret referenced
+// from Key Vault is fetched again, so a re-bootstrap hands kubelet the rotated secret.
+func (i *Installer) createServicePrincipalTokenScript(ctx context.Context, apply *configApply) error {
 	sp := i.config.Azure.ServicePrincipal
+	clientSecret, err := secrets.ClientSecret(ctx, i.config)
+	if err != nil {
+		return fmt.Errorf("failed to get the service principal secret: %w", err)
+	}
 	tokenScript := fmt.Sprintf(`#!/bin/bash
 
 # Get Azure AD token using Service Principal credentials for direct AKS authentication
 
 CLIENT_ID="%s"
-CLIENT_SECRET="%s"
 TENANT_ID="%s"
+# The staged script verified before the switch to a new configuration reads the staged secret
+CLIENT_SECRET_FILE="%s${0#%s}"
+
+if [ ! -r "$CLIENT_SECRET_FILE" ]; then
+    echo "Failed to read client secret from $CLIENT_SECRET_FILE"
+    exit 255
+fi
 
 TOKEN_RESPONSE=$(curl -s -X POST \
-  "https://login.microsoftonline.com/${TENANT_ID}/oauth2/v2.0/token" \
+  "%s" \
   -H "Content-Type: application/x-www-form-urlencoded" \
   -d "client_id=${CLIENT_ID}" \
-  -d "client_secret=${CLIENT_SECRET}" \
+  --data-urlencode "client_secret@${CLIENT_SECRET_FILE}" \
   -d "scope=%s/.default" \
   -d "grant_type=client_credentials")
 
@@ -406,33 +605,76 @@ cat <<EOF
     "token": "${ACCESS_TOKEN}"
   }
 }
-EOF`, sp.ClientID, sp.ClientSecret, sp.TenantID, aksServiceResourceID)
+EOF`, sp.ClientID, sp.TenantID, kubeletClientSecretPath, kubeletTokenScriptPath,
+		auth.Cloud(i.config).TokenEndpoint("${TENANT_ID}"), aksServiceResourceID)
 
-	return i.writeTokenScript(tokenScript)
+	apply.stage(kubeletClientSecretPath, []byte(clientSecret), 0o600)
+	apply.stage(kubeletTokenScriptPath, []byte(tokenScript), 0o755)
+	return nil
 }
 
-// writeTokenScript helper method to write the token script with proper permissions
-func (i *Installer) writeTokenScript(tokenScript string) error {
-	// Ensure /var/lib/kubelet directory exists
-	if err := utils.RunSystemCommand("mkdir", "-p", kubeletVarDir); err != nil {
-		return fmt.Errorf("failed to create kubelet var directory: %w", err)
-	}
+// createWorkloadIdentityTokenScript creates the Workload Identity token script, exchanging the federated token
+// in the token file for an Entra ID token of the configured Azure cloud. The file is read on every call,
+// so tokens rotated by the identity provider are picked up without reconfiguring kubelet.
+func (i *Installer) createWorkloadIdentityTokenScript(apply *configApply) {
+	wi := i.config.Azure.WorkloadIdentity
+	tokenScript := fmt.Sprintf(`#!/bin/bash
 
-	// Write token script atomically with executable permissions
-	if err := utils.WriteFileAtomicSystem(kubeletTokenScriptPath, []byte(tokenScript), 0o755); err != nil {
-		return fmt.Errorf("failed to create token script: %w", err)
-	}
+# Get Azure AD token by exchanging a federated token (client assertion) for direct AKS authentication
 
-	// Ensure the script has executable permissions (explicit chmod as backup)
-	if err := utils.RunSystemCommand("chmod", "755", kubeletTokenScriptPath); err != nil {
-		return fmt.Errorf("failed to set executable permissions on token script: %w", err)
-	}
+CLIENT_ID="%s"
+TENANT_ID="%s"
+TOKEN_FILE="%s"
 
-	return nil
+CLIENT_ASSERTION=$(cat "$TOKEN_FILE")
+if [ $? -ne 0 ] || [ -z "$CLIENT_ASSERTION" ]; then
+    echo "Failed to read federated token from $TOKEN_FILE"
+    exit 255
+fi
+
+TOKEN_RESPONSE=$(curl -s -X POST \
+  "%s" \
+  -H "Content-Type: application/x-www-form-urlencoded" \
+  -d "client_id=${CLIENT_ID}" \
+  -d "client_assertion_type=urn:ietf:params:oauth:client-assertion-type:jwt-bearer" \
+  -d "client_assertion=${CLIENT_ASSERTION}" \
+  -d "scope=%s/.default" \
+  -d "grant_type=client_credentials")
+
+if [ $? -ne 0 ]; then
+    echo "Failed to get token from Azure AD"
+    exit 255
+fi
+
+ACCESS_TOKEN=$(echo "$TOKEN_RESPONSE" | jq -r '.access_token')
+if [ "$ACCESS_TOKEN" == "null" ] || [ -z "$ACCESS_TOKEN" ]; then
+    echo "Failed to extract access token from response: $TOKEN_RESPONSE"
+    exit 255
+fi
+
+EXPIRES_IN=$(echo "$TOKEN_RESPONSE" | jq -r '.expires_in')
+EXPIRY_TIME=$(date -d "+${EXPIRES_IN} seconds" --iso-8601=seconds)
+
+# Return in ExecCredential format
+cat <<EOF
+{
+  "kind": "ExecCredential",
+  "apiVersion": "client.authentication.k8s.io/v1beta1",
+  "spec": {
+    "interactive": false
+  },
+  "status": {
+    "expirationTimestamp": "${EXPIRY_TIME}",
+    "token": "${ACCESS_TOKEN}"
+  }
+}
+EOF`, wi.ClientID, wi.TenantID, wi.TokenFile, auth.Cloud(i.config).TokenEndpoint("${TENANT_ID}"), aksServiceResourceID)
+
+	apply.stage(kubeletTokenScriptPath, []byte(tokenScript), 0o755)
 }
 
-// createKubeconfigWithExecCredential creates kubeconfig with exec credential provider for authentication
-func (i *Installer) createKubeconfigWithExecCredential(ctx context.Context) error {
+// createBootstrapKubeconfig stages the bootstrap kubeconfig with exec credential provider for TLS bootstrap
+func (i *Installer) createBootstrapKubeconfig(ctx context.Context, apply *configApply) error {
 	kubeconfig, err := i.getClusterCredentials(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster credentials: %w", err)
@@ -442,6 +684,7 @@ func (i *Installer) createKubeconfigWithExecCredential(ctx context.Context) erro
 	if err != nil {
 		return fmt.Errorf("failed to extract cluster info from kubeconfig: %w", err)
 	}
+	i.cacheClusterCA(serverURL, caCertData)
 
 	// Create cluster configuration based on whether we have CA cert
 	var clusterConfig string
@@ -494,11 +737,48 @@ users:
 		contextName,
 		userName)
 
-	// Write kubeconfig file to the correct location for kubelet
-	if err := utils.WriteFileAtomicSystem(KubeletKubeconfigPath, []byte(kubeconfigContent), 0o600); err != nil {
-		return fmt.Errorf("failed to create kubeconfig file: %w", err)
+	// Stage bootstrap kubeconfig file for the location referenced by --bootstrap-kubeconfig
+	apply.stage(KubeletBootstrapKubeconfigPath, []byte(kubeconfigContent), 0o600)
+	apply.check(func() error { return verifyBootstrapCredential(ctx, []byte(kubeconfigContent), i.logger) })
+	return nil
+}
+
+// cacheClusterCA caches the cluster CA so the API server chain can be verified against it in preflight and status
+func (i *Installer) cacheClusterCA(serverURL, caCertData string) {
+	caData, err := base64.StdEncoding.DecodeString(caCertData)
+	if err != nil {
+		i.logger.Warnf("Not caching cluster CA, failed to decode kubeconfig CA data: %v", err)
+		return
+	}
+	previous, err := clusterca.Save(serverURL, caData)
+	if err != nil {
+		i.logger.Warnf("Failed to cache cluster CA: %v", err)
+		return
+	}
+	if previous != "" {
+		i.logger.Warnf("Cluster CA changed from %s, the cluster CA was rotated", previous)
+	}
+}
+
+// removeLegacyKubeconfig removes a kubelet kubeconfig that authenticates with the token script
+// instead of a client certificate, so that kubelet performs TLS bootstrap on its next start
+func (i *Installer) removeLegacyKubeconfig(kubeconfigPath string) error {
+	if !utils.FileExists(kubeconfigPath) {
+		return nil
 	}
 
+	kubeconfigData, err := utils.RunCommandWithOutput("cat", kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing kubelet kubeconfig: %w", err)
+	}
+	if !strings.Contains(kubeconfigData, kubeletTokenScriptPath) {
+		return nil
+	}
+
+	i.logger.Info("Removing exec credential kubelet kubeconfig so kubelet performs TLS bootstrap")
+	if err := utils.RunCleanupCommand(kubeconfigPath); err != nil {
+		return fmt.Errorf("failed to remove legacy kubelet kubeconfig: %w", err)
+	}
 	return nil
 }
 
@@ -508,7 +788,7 @@ func (i *Installer) setUpClients() error {
 		return fmt.Errorf("failed to get authentication credential: %w", err)
 	}
 	clusterSubID := i.config.GetTargetClusterSubscriptionID()
-	clientFactory, err := armcontainerservice.NewClientFactory(clusterSubID, cred, nil)
+	clientFactory, err := armcontainerservice.NewClientFactory(clusterSubID, cred, auth.ClientOptions(i.config))
 	if err != nil {
 		return fmt.Errorf("failed to create Azure Container Service client factory: %w", err)
 	}
@@ -524,8 +804,17 @@ func (i *Installer) getClusterCredentials(ctx context.Context) ([]byte, error) {
 	i.logger.Infof("Fetching cluster credentials for cluster %s in resource group %s using Azure SDK",
 		clusterName, clusterResourceGroup)
 
-	// Get cluster admin credentials using the Azure SDK
-	resp, err := i.mcClient.ListClusterAdminCredentials(ctx, clusterResourceGroup, clusterName, nil)
+	// Get cluster admin credentials using the Azure SDK, retrying throttling, server and network errors
+	policy := cfg.GetRetryPolicy()
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		i.logger.Warnf("Fetching cluster credentials failed (attempt %d/%d), retrying in %v: %v", attempt, policy.Attempts, delay.Round(time.Millisecond), err)
+	}
+	var resp armcontainerservice.ManagedClustersClientListClusterAdminCredentialsResponse
+	err := retry.Do(ctx, policy, func(ctx context.Context) error {
+		var err error
+		resp, err = i.mcClient.ListClusterAdminCredentials(ctx, clusterResourceGroup, clusterName, nil)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster admin credentials for %s in resource group %s: %w", clusterName, clusterResourceGroup, err)
 	}
@@ -546,23 +835,130 @@ func (i *Installer) getClusterCredentials(ctx context.Context) ([]byte, error) {
 	}
 
 	// The Value field is already []byte containing the kubeconfig data, no decoding needed
+	if cfg.TrustedCA.InjectKubeconfig {
+		return ca_trust.InjectKubeconfig(kubeconfig.Value, cfg.TrustedCA.Bundles)
+	}
 	return kubeconfig.Value, nil
 }
 
-// mapToKeyValuePairs converts a map to key=value pairs joined by separator
+// bootstrapCredentialPaths are the bootstrap kubeconfig, the token script and the service principal secret it reads,
+// replaced in tests
+var bootstrapCredentialPaths = []string{KubeletBootstrapKubeconfigPath, kubeletTokenScriptPath, kubeletClientSecretPath}
+
+// RemoveBootstrapCredentials waits for kubelet to obtain its client certificate through TLS bootstrap
+// and then removes the bootstrap kubeconfig, the token script and its client secret, which are no longer needed after join
+func RemoveBootstrapCredentials(ctx context.Context, timeout time.Duration, logger *logrus.Logger) error {
+	logger.Debugf("Waiting for kubelet client certificate %s (timeout: %v)", clientCertPath, timeout)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for !utils.FileExists(clientCertPath) {
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("timeout waiting for kubelet client certificate %s", clientCertPath)
+		case <-ticker.C:
+		}
+	}
+
+	logger.Info("Kubelet client certificate issued, removing bootstrap credentials")
+	if fileErrors := utils.RemoveFiles(bootstrapCredentialPaths, logger); len(fileErrors) > 0 {
+		return fmt.Errorf("failed to remove bootstrap credentials: %v", fileErrors)
+	}
+	return nil
+}
+
+// ApplyNodeAnnotations waits for the node to register and applies the configured initial annotations,
+// which external cloud controller managers may require to initialize the node
+func ApplyNodeAnnotations(ctx context.Context, cfg *config.Config, timeout time.Duration, logger *logrus.Logger) error {
+	if len(cfg.Node.Annotations) == 0 {
+		return nil
+	}
+	nodeName := cfg.GetNodeName()
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if _, err := utils.RunCommandContext(waitCtx, "kubectl", "--kubeconfig", KubeletKubeconfigPath, "get", "node", nodeName); err == nil {
+			break
+		}
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("timeout waiting for node %s to register", nodeName)
+		case <-ticker.C:
+		}
+	}
+
+	args := []string{"--kubeconfig", KubeletKubeconfigPath, "annotate", "node", nodeName, "--overwrite"}
+	for _, key := range slices.Sorted(maps.Keys(cfg.Node.Annotations)) {
+		args = append(args, fmt.Sprintf("%s=%s", key, cfg.Node.Annotations[key]))
+	}
+	if _, err := utils.RunCommandContext(ctx, "kubectl", args...); err != nil {
+		return fmt.Errorf("failed to annotate node %s: %w", nodeName, err)
+	}
+	logger.Infof("Applied %d annotation(s) to node %s", len(cfg.Node.Annotations), nodeName)
+	return nil
+}
+
+// ApplyNodeLabels sets and removes labels on the registered node without waiting for a re-bootstrap.
+// Kubelet only applies its --node-labels when the node registers.
+func ApplyNodeLabels(cfg *config.Config, labels map[string]string, removed []string, logger *logrus.Logger) error {
+	return applyNodeLabels(cfg, NodeKubeconfigPath(), labels, removed, logger)
+}
+
+// applyNodeLabels sets and removes labels on the registered node like ApplyNodeLabels, with kubeconfig
+func applyNodeLabels(cfg *config.Config, kubeconfig string, labels map[string]string, removed []string, logger *logrus.Logger) error {
+	if len(labels) == 0 && len(removed) == 0 {
+		return nil
+	}
+	nodeName := cfg.GetNodeName()
+
+	args := []string{"--kubeconfig", kubeconfig, "label", "node", nodeName, "--overwrite"}
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		args = append(args, fmt.Sprintf("%s=%s", key, labels[key]))
+	}
+	for _, key := range removed {
+		args = append(args, key+"-")
+	}
+	if _, err := utils.RunCommandWithOutput("kubectl", args...); err != nil {
+		return fmt.Errorf("failed to label node %s: %w", nodeName, err)
+	}
+	logger.Infof("Applied %d label(s) to node %s and removed %d", len(labels), nodeName, len(removed))
+	return nil
+}
+
+// mapToKeyValuePairs converts a map to key=value pairs sorted by key and joined by separator
 func mapToKeyValuePairs(m map[string]string, separator string) string {
 	pairs := make([]string, 0, len(m))
-	for k, v := range m {
-		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, m[k]))
 	}
 	return strings.Join(pairs, separator)
 }
 
-// mapToEvictionThresholds converts a map to key<value pairs for kubelet eviction thresholds
+// featureGatesToPairs joins the Name=bool feature gates into comma separated pairs sorted by name
+func featureGatesToPairs(gates []string) string {
+	name := func(gate string) string {
+		name, _, _ := strings.Cut(gate, "=")
+		return name
+	}
+	return strings.Join(slices.SortedFunc(slices.Values(gates), func(a, b string) int {
+		return strings.Compare(name(a), name(b))
+	}), ",")
+}
+
+// mapToEvictionThresholds converts a map to key<value pairs sorted by key for kubelet eviction thresholds
 func mapToEvictionThresholds(m map[string]string, separator string) string {
 	pairs := make([]string, 0, len(m))
-	for k, v := range m {
-		pairs = append(pairs, fmt.Sprintf("%s<%s", k, v))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, fmt.Sprintf("%s<%s", k, m[k]))
 	}
 	return strings.Join(pairs, separator)
 }
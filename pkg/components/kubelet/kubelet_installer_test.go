@@ -0,0 +1,229 @@
+package kubelet
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/internal/golden"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderKubeletDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		modify func(cfg *config.Config)
+	}{
+		{
+			name:   "default configuration",
+			golden: "kubelet-defaults",
+		},
+		{
+			name:   "map settings and optional flags",
+			golden: "kubelet-defaults-full",
+			modify: func(cfg *config.Config) {
+				cfg.Node.Labels = map[string]string{
+					"zone":                         "edge-1",
+					"app.example.com/tier":         "frontend",
+					"kubernetes.azure.com/managed": "true",
+					"env":                          "prod",
+				}
+				cfg.Node.Kubelet.KubeReserved = map[string]string{
+					"memory":            "1Gi",
+					"cpu":               "100m",
+					"ephemeral-storage": "1Gi",
+					"pid":               "1000",
+				}
+				cfg.Node.Kubelet.EvictionHard = map[string]string{
+					"nodefs.available":   "10%",
+					"memory.available":   "750Mi",
+					"imagefs.available":  "15%",
+					"nodefs.inodesFree":  "5%",
+					"imagefs.inodesFree": "5%",
+				}
+				cfg.Node.HostnameOverride = "Edge-Node-01"
+				cfg.Node.Taints = []string{"edge.example.com/site=store-42:NoSchedule"}
+				cfg.Node.PodsPerCore = 10
+				cfg.Node.Kubelet.KubeAPIQPS = 10
+				cfg.Node.Kubelet.KubeAPIBurst = 20
+				cfg.Node.Kubelet.CloudProvider = "external"
+				cfg.Node.Kubelet.ProviderID = "edge://store-42/node-01"
+				cfg.Node.Kubelet.CPUManagerPolicy = "static"
+				cfg.Node.Kubelet.TopologyManagerPolicy = "single-numa-node"
+				cfg.Node.Kubelet.ReservedSystemCPUs = "0-1"
+				cfg.Node.Kubelet.MemoryManagerPolicy = "Static"
+				cfg.Node.Kubelet.ReservedMemory = "0:memory=1Gi"
+				cfg.Node.Kubelet.FeatureGates = []string{"KubeletTracing=true", "GracefulNodeShutdown=false"}
+				cfg.Node.Kubelet.ExtraFlags = []string{"--image-pull-progress-deadline=5m", "--serialize-image-pulls=false"}
+			},
+		},
+		{
+			name:   "swap enabled",
+			golden: "kubelet-defaults-swap",
+			modify: func(cfg *config.Config) {
+				cfg.Node.Kubelet.SwapBehavior = "LimitedSwap"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Containerd.PauseImage = "mcr.microsoft.com/oss/kubernetes/pause:3.6"
+			cfg.SetDefaults()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+			installer := &Installer{config: cfg, logger: logrus.New()}
+
+			// Rendering repeatedly must produce identical output regardless of map iteration order
+			rendered := installer.renderKubeletDefaults()
+			for range 20 {
+				if again := installer.renderKubeletDefaults(); again != rendered {
+					t.Fatalf("renderKubeletDefaults() is not deterministic:\n%s\nvs\n%s", rendered, again)
+				}
+			}
+			golden.Assert(t, tt.golden, rendered)
+		})
+	}
+}
+
+func TestCreateWorkloadIdentityTokenScript(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	cfg.Azure.WorkloadIdentity = &config.WorkloadIdentityConfig{
+		TenantID:  "22222222-2222-2222-2222-222222222222",
+		ClientID:  "11111111-1111-1111-1111-111111111111",
+		TokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+	}
+	installer := &Installer{config: cfg, logger: logrus.New()}
+
+	apply := newConfigApply(installer.logger)
+	if err := installer.createTokenScript(context.Background(), apply); err != nil {
+		t.Fatalf("createTokenScript() error = %v", err)
+	}
+	if len(apply.files) != 1 || apply.files[0].path != kubeletTokenScriptPath || apply.files[0].perm != 0o755 {
+		t.Fatalf("createTokenScript() staged %+v, want the executable token script", apply.files)
+	}
+	golden.Assert(t, "token-script-workload-identity", string(apply.files[0].content))
+}
+
+func TestCreateServicePrincipalTokenScript(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SetDefaults()
+	cfg.Azure.ServicePrincipal = &config.ServicePrincipalConfig{
+		TenantID:     "22222222-2222-2222-2222-222222222222",
+		ClientID:     "11111111-1111-1111-1111-111111111111",
+		ClientSecret: "s3cr$t&value",
+	}
+	installer := &Installer{config: cfg, logger: logrus.New()}
+
+	apply := newConfigApply(installer.logger)
+	if err := installer.createTokenScript(context.Background(), apply); err != nil {
+		t.Fatalf("createTokenScript() error = %v", err)
+	}
+	if len(apply.files) != 2 {
+		t.Fatalf("createTokenScript() staged %d files, want the client secret and the token script", len(apply.files))
+	}
+	secret, script := apply.files[0], apply.files[1]
+	if secret.path != kubeletClientSecretPath || secret.perm != 0o600 || string(secret.content) != "s3cr$t&value" {
+		t.Errorf("createTokenScript() staged secret %s with mode %o, want %s readable by root only", secret.path, secret.perm, kubeletClientSecretPath)
+	}
+	if script.path != kubeletTokenScriptPath || script.perm != 0o755 {
+		t.Fatalf("createTokenScript() staged %s with mode %o, want the executable token script", script.path, script.perm)
+	}
+	if strings.Contains(string(script.content), "s3cr$t&value") {
+		t.Errorf("token script contains the client secret:\n%s", script.content)
+	}
+	golden.Assert(t, "token-script-service-principal", string(script.content))
+}
+
+func TestMapRenderersSortKeys(t *testing.T) {
+	m := map[string]string{"c": "3", "a": "1", "b": "2"}
+	if got, want := mapToKeyValuePairs(m, ","), "a=1,b=2,c=3"; got != want {
+		t.Errorf("mapToKeyValuePairs() = %q, want %q", got, want)
+	}
+	if got, want := mapToEvictionThresholds(m, ","), "a<1,b<2,c<3"; got != want {
+		t.Errorf("mapToEvictionThresholds() = %q, want %q", got, want)
+	}
+}
+
+func TestRemoveBootstrapCredentials(t *testing.T) {
+	dir := t.TempDir()
+	originalCert, originalCredentials := clientCertPath, bootstrapCredentialPaths
+	defer func() { clientCertPath, bootstrapCredentialPaths = originalCert, originalCredentials }()
+	clientCertPath = filepath.Join(dir, "kubelet-client-current.pem")
+	bootstrapCredentialPaths = []string{
+		filepath.Join(dir, "bootstrap-kubeconfig"),
+		filepath.Join(dir, "token.sh"),
+		filepath.Join(dir, "client-secret"),
+	}
+	for _, path := range bootstrapCredentialPaths {
+		if err := os.WriteFile(path, []byte("credential"), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	err := RemoveBootstrapCredentials(context.Background(), 10*time.Millisecond, logger)
+	if err == nil || !strings.Contains(err.Error(), "timeout waiting for kubelet client certificate") {
+		t.Fatalf("RemoveBootstrapCredentials() without a client certificate error = %v, want a timeout", err)
+	}
+	for _, path := range bootstrapCredentialPaths {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s removed before kubelet had a client certificate: %v", filepath.Base(path), err)
+		}
+	}
+
+	if err := os.WriteFile(clientCertPath, []byte("certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write client certificate: %v", err)
+	}
+	if err := RemoveBootstrapCredentials(context.Background(), time.Second, logger); err != nil {
+		t.Fatalf("RemoveBootstrapCredentials() unexpected error = %v", err)
+	}
+	for _, path := range bootstrapCredentialPaths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s kept after kubelet obtained its client certificate", filepath.Base(path))
+		}
+	}
+}
+
+func TestRemoveLegacyKubeconfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		kubeconfig string
+		wantKept   bool
+	}{
+		{name: "exec credential kubeconfig", kubeconfig: "users:\n- user:\n    exec:\n      command: " + kubeletTokenScriptPath + "\n", wantKept: false},
+		{name: "client certificate kubeconfig", kubeconfig: "users:\n- user:\n    client-certificate: " + KubeletClientCertPath + "\n", wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			if err := os.WriteFile(path, []byte(tt.kubeconfig), 0o600); err != nil {
+				t.Fatalf("failed to write kubeconfig: %v", err)
+			}
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+
+			if err := (&Installer{logger: logger}).removeLegacyKubeconfig(path); err != nil {
+				t.Fatalf("removeLegacyKubeconfig() unexpected error = %v", err)
+			}
+			if _, err := os.Stat(path); (err == nil) != tt.wantKept {
+				t.Errorf("kubeconfig kept = %v, want %v", err == nil, tt.wantKept)
+			}
+		})
+	}
+
+	if err := (&Installer{logger: logrus.New()}).removeLegacyKubeconfig(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("removeLegacyKubeconfig() without a kubeconfig error = %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package kubelet
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// tpmTools are the commands the key sealing script needs
+var tpmTools = []string{"tpm2_createprimary", "tpm2_create", "tpm2_load", "tpm2_unseal", "openssl", "mountpoint"}
+
+// lookPath is replaced in tests
+var lookPath = exec.LookPath
+
+// detectTPM reports why the TPM cannot protect the kubelet client key, or nil when it can
+func detectTPM(devicePath string) error {
+	if !utils.FileExists(devicePath) {
+		return fmt.Errorf("TPM resource manager %s not found", devicePath)
+	}
+	var missing []string
+	for _, tool := range tpmTools {
+		if _, err := lookPath(tool); err != nil {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s (install tpm2-tools and openssl)", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// resolveKeyProtection returns the key protection level to configure for the requested mode.
+// In auto mode a machine without a usable TPM falls back to file protection with a warning.
+func resolveKeyProtection(mode string, tpmErr error) (string, string, error) {
+	switch mode {
+	case config.KeyProtectionTPM:
+		if tpmErr != nil {
+			return "", "", fmt.Errorf("node.kubelet.keyProtection is tpm but the TPM is not usable: %w", tpmErr)
+		}
+		return config.KeyProtectionTPM, "", nil
+	case config.KeyProtectionAuto:
+		if tpmErr != nil {
+			return config.KeyProtectionFile, fmt.Sprintf("TPM is not usable, storing the kubelet client key as a file: %v", tpmErr), nil
+		}
+		return config.KeyProtectionTPM, "", nil
+	default:
+		return config.KeyProtectionFile, "", nil
+	}
+}
+
+// KeyProtectionLevel reports how the kubelet client key is currently protected: tpm when the PKI directory
+// is held in memory and sealed to the TPM, file when the key is stored on disk, and empty before kubelet has a key
+func KeyProtectionLevel() string {
+	if !utils.FileExists(KubeletClientCertPath) {
+		return ""
+	}
+	if utils.FileExists(kubeletKeyProtectionConfig) && isMountPoint(kubeletPKIDir) &&
+		utils.FileExists(filepath.Join(kubeletSealedPKIDir, sealedPKIArchive)) {
+		return config.KeyProtectionTPM
+	}
+	return config.KeyProtectionFile
+}
+
+// renderKeySealScript renders the script that keeps the kubelet PKI directory in memory and stores it
+// encrypted under a key sealed to this machine's TPM. Kubelet cannot use a TPM-resident key directly,
+// so the key is held on a tmpfs while kubelet runs and only the sealed copy ever reaches the disk.
+func renderKeySealScript() string {
+	return fmt.Sprintf(`#!/bin/bash
+# Keep the kubelet client key in memory and store it sealed to this machine's TPM.
+# A copy of the sealed files is useless on any other machine.
+set -euo pipefail
+
+PKI_DIR=%[1]s
+SEALED_DIR=%[2]s
+ARCHIVE="$SEALED_DIR/%[3]s"
+
+work=$(mktemp -d /run/kubelet-pki-seal.XXXXXX)
+trap 'rm -rf "$work"' EXIT
+
+primary() {
+    tpm2_createprimary -Q -C o -g sha256 -G ecc -c "$work/primary.ctx"
+}
+
+case "${1:-}" in
+unseal)
+    mkdir -p "$PKI_DIR"
+    if ! mountpoint -q "$PKI_DIR"; then
+        # Move keys written before key protection was enabled into memory and remove them from disk
+        cp -a "$PKI_DIR"/. "$work"/
+        find "$PKI_DIR" -type f -exec shred -u {} +
+        mount -t tmpfs -o mode=0700,size=4m tmpfs "$PKI_DIR"
+        cp -a "$work"/. "$PKI_DIR"/
+    fi
+    if [ -e "$PKI_DIR/kubelet-client-current.pem" ] || [ ! -f "$ARCHIVE" ]; then
+        exit 0
+    fi
+    primary
+    tpm2_load -Q -C "$work/primary.ctx" -u "$SEALED_DIR/seal.pub" -r "$SEALED_DIR/seal.priv" -c "$work/seal.ctx"
+    tpm2_unseal -c "$work/seal.ctx" -o "$work/key"
+    openssl enc -d -aes-256-cbc -pbkdf2 -pass file:"$work/key" -in "$ARCHIVE" | tar -C "$PKI_DIR" -xf -
+    ;;
+seal)
+    if [ ! -e "$PKI_DIR/kubelet-client-current.pem" ]; then
+        exit 0
+    fi
+    mkdir -p -m 0700 "$SEALED_DIR"
+    primary
+    openssl rand -hex 32 > "$work/key"
+    tpm2_create -Q -C "$work/primary.ctx" -i "$work/key" -u "$work/seal.pub" -r "$work/seal.priv"
+    tar -C "$PKI_DIR" -cf - . | openssl enc -aes-256-cbc -pbkdf2 -pass file:"$work/key" -out "$work/pki.enc"
+    for file in seal.pub seal.priv; do
+        install -m 0600 "$work/$file" "$SEALED_DIR/$file.new"
+    done
+    install -m 0600 "$work/pki.enc" "$ARCHIVE.new"
+    mv "$SEALED_DIR/seal.pub.new" "$SEALED_DIR/seal.pub"
+    mv "$SEALED_DIR/seal.priv.new" "$SEALED_DIR/seal.priv"
+    mv "$ARCHIVE.new" "$ARCHIVE"
+    ;;
+*)
+    echo "usage: $0 seal|unseal" >&2
+    exit 2
+    ;;
+esac
+`, kubeletPKIDir, kubeletSealedPKIDir, sealedPKIArchive)
+}
+
+// renderKeyProtectionDropIn renders the kubelet drop-in that unseals the PKI directory before kubelet
+// starts and seals it again when kubelet stops; the path unit reseals it whenever kubelet rotates its certificate
+func renderKeyProtectionDropIn() string {
+	return fmt.Sprintf(`[Unit]
+Wants=%[1]s
+[Service]
+ExecStartPre=%[2]s unseal
+ExecStopPost=%[2]s seal`, keySealPathUnit, kubeletKeySealScriptPath)
+}
+
+// renderKeySealPathUnit renders the path unit that triggers sealing when the kubelet PKI directory changes
+func renderKeySealPathUnit() string {
+	return fmt.Sprintf(`[Unit]
+Description=Seal rotated kubelet client certificates to the TPM
+[Path]
+PathChanged=%s
+Unit=%s`, kubeletPKIDir, keySealServiceUnit)
+}
+
+// renderKeySealServiceUnit renders the oneshot service that seals the kubelet PKI directory
+func renderKeySealServiceUnit() string {
+	return fmt.Sprintf(`[Unit]
+Description=Seal the kubelet client key to the TPM
+[Service]
+Type=oneshot
+ExecStart=%s seal`, kubeletKeySealScriptPath)
+}
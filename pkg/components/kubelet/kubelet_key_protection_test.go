@@ -0,0 +1,88 @@
+package kubelet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestDetectTPM(t *testing.T) {
+	restore := lookPath
+	defer func() { lookPath = restore }()
+
+	device := filepath.Join(t.TempDir(), "tpmrm0")
+	if err := detectTPM(device); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("detectTPM() without a device = %v, want a not found error", err)
+	}
+
+	if err := os.WriteFile(device, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lookPath = func(command string) (string, error) {
+		if command == "tpm2_unseal" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + command, nil
+	}
+	if err := detectTPM(device); err == nil || !strings.Contains(err.Error(), "missing tpm2_unseal") {
+		t.Errorf("detectTPM() without tpm2_unseal = %v, want a missing tool error", err)
+	}
+
+	lookPath = func(command string) (string, error) { return "/usr/bin/" + command, nil }
+	if err := detectTPM(device); err != nil {
+		t.Errorf("detectTPM() unexpected error: %v", err)
+	}
+}
+
+func TestResolveKeyProtection(t *testing.T) {
+	noTPM := errors.New("TPM resource manager /dev/tpmrm0 not found")
+
+	tests := []struct {
+		name        string
+		mode        string
+		tpmErr      error
+		want        string
+		wantWarning bool
+		wantErr     bool
+	}{
+		{name: "default", mode: "", tpmErr: noTPM, want: config.KeyProtectionFile},
+		{name: "file", mode: config.KeyProtectionFile, want: config.KeyProtectionFile},
+		{name: "tpm available", mode: config.KeyProtectionTPM, want: config.KeyProtectionTPM},
+		{name: "tpm required but missing", mode: config.KeyProtectionTPM, tpmErr: noTPM, wantErr: true},
+		{name: "auto with tpm", mode: config.KeyProtectionAuto, want: config.KeyProtectionTPM},
+		{name: "auto falls back", mode: config.KeyProtectionAuto, tpmErr: noTPM, want: config.KeyProtectionFile, wantWarning: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warning, err := resolveKeyProtection(tt.mode, tt.tpmErr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveKeyProtection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveKeyProtection() = %q, want %q", got, tt.want)
+			}
+			if (warning != "") != tt.wantWarning {
+				t.Errorf("resolveKeyProtection() warning = %q, wantWarning %v", warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRenderKeySealScript(t *testing.T) {
+	script := renderKeySealScript()
+	for _, want := range []string{
+		"PKI_DIR=" + kubeletPKIDir,
+		"SEALED_DIR=" + kubeletSealedPKIDir,
+		"mount -t tmpfs",
+		"tpm2_unseal",
+		"tpm2_create -Q",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("seal script missing %q", want)
+		}
+	}
+}
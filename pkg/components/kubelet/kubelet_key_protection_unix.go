@@ -0,0 +1,24 @@
+//go:build !windows
+
+package kubelet
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// isMountPoint reports whether path is on a different device than its parent directory
+func isMountPoint(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	parent, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	parentStat, parentOK := parent.Sys().(*syscall.Stat_t)
+	return ok && parentOK && stat.Dev != parentStat.Dev
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package kubelet
+
+// isMountPoint reports false, the in-memory kubelet PKI directory of TPM key protection only exists on Linux
+func isMountPoint(_ string) bool {
+	return false
+}
@@ -0,0 +1,108 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// NodeNameChecker detects an existing node with the same name in the target cluster before registration
+type NodeNameChecker struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewNodeNameChecker creates a new NodeNameChecker
+func NewNodeNameChecker(logger *logrus.Logger) *NodeNameChecker {
+	return &NodeNameChecker{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *NodeNameChecker) GetName() string {
+	return "NodeNameCheck"
+}
+
+// Validate validates prerequisites for the node name check
+func (c *NodeNameChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when this machine has already registered with the cluster,
+// in which case a node with the same name is expected to be this machine itself
+func (c *NodeNameChecker) IsCompleted(ctx context.Context) bool {
+	return IsAdopted() || utils.FileExists(KubeletKubeconfigPath)
+}
+
+// Execute checks the target cluster for a Ready node with the same name.
+// The check is skipped when the cluster credentials needed to query nodes are not available.
+func (c *NodeNameChecker) Execute(ctx context.Context) error {
+	nodeName := c.config.GetNodeName()
+	if nodeName == "" {
+		return fmt.Errorf("failed to determine node name")
+	}
+	c.logger.Infof("Checking target cluster for an existing node named %s", nodeName)
+
+	kubeconfigPath, err := WriteAdminKubeconfig(ctx, c.logger)
+	if err != nil {
+		c.logger.Warnf("Skipping duplicate node name check, cluster credentials are not available: %v", err)
+		return nil
+	}
+	defer utils.CleanupTempFile(kubeconfigPath)
+
+	output, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubeconfigPath,
+		"get", "node", nodeName, "--ignore-not-found",
+		"-o", "jsonpath={.status.conditions[?(@.type==\"Ready\")].status}")
+	if err != nil {
+		c.logger.Warnf("Skipping duplicate node name check, failed to query nodes: %v", err)
+		return nil
+	}
+
+	readyStatus := strings.TrimSpace(output)
+	if readyStatus == "" {
+		c.logger.Infof("No existing node named %s found", nodeName)
+		return nil
+	}
+	if readyStatus != "True" {
+		c.logger.Warnf("A node named %s already exists in the cluster but is not Ready, this machine will take it over", nodeName)
+		return nil
+	}
+
+	if !c.config.Node.ReplaceExisting {
+		return fmt.Errorf("a Ready node named %s already exists in the cluster; "+
+			"set node.hostnameOverride to register under a different name or rerun with --replace-node", nodeName)
+	}
+
+	c.logger.Warnf("Deleting existing Ready node %s so this machine can register in its place", nodeName)
+	if err := utils.RunSystemCommand("kubectl", "--kubeconfig", kubeconfigPath, "delete", "node", nodeName); err != nil {
+		return fmt.Errorf("failed to delete existing node %s: %w", nodeName, err)
+	}
+	return nil
+}
+
+// WriteAdminKubeconfig writes the target cluster admin credentials to a temporary file and returns its path.
+// Callers must remove the file with utils.CleanupTempFile once done.
+func WriteAdminKubeconfig(ctx context.Context, logger *logrus.Logger) (string, error) {
+	installer := NewInstaller(logger)
+	if err := installer.setUpClients(); err != nil {
+		return "", err
+	}
+	kubeconfig, err := installer.getClusterCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tempFile, err := utils.CreateTempFile("aks-flex-node-admin-*.kubeconfig", kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary kubeconfig: %w", err)
+	}
+	_ = tempFile.Close()
+	return tempFile.Name(), nil
+}
@@ -0,0 +1,175 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Taint is a node taint
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// ParseTaint parses a taint in the kubelet --register-with-taints format, key[=value]:Effect
+func ParseTaint(taint string) (Taint, error) {
+	keyValue, effect, ok := strings.Cut(taint, ":")
+	if !ok || effect == "" {
+		return Taint{}, fmt.Errorf("invalid taint %q, expected key[=value]:Effect", taint)
+	}
+	key, value, _ := strings.Cut(keyValue, "=")
+	return Taint{Key: key, Value: value, Effect: effect}, nil
+}
+
+// String renders the taint as key[=value]:Effect
+func (t Taint) String() string {
+	if t.Value == "" {
+		return t.Key + ":" + t.Effect
+	}
+	return t.Key + "=" + t.Value + ":" + t.Effect
+}
+
+// NodeSync holds the label and taint changes that bring the Node object in line with the configuration
+type NodeSync struct {
+	SetLabels    map[string]string
+	RemoveLabels []string
+	SetTaints    []Taint
+	RemoveTaints []Taint
+}
+
+// IsEmpty returns true when the Node object is already in line with the configuration
+func (s NodeSync) IsEmpty() bool {
+	return len(s.SetLabels) == 0 && len(s.RemoveLabels) == 0 && len(s.SetTaints) == 0 && len(s.RemoveTaints) == 0
+}
+
+// String summarizes the changes for logging
+func (s NodeSync) String() string {
+	var changes []string
+	for _, key := range slices.Sorted(maps.Keys(s.SetLabels)) {
+		changes = append(changes, fmt.Sprintf("label %s=%s", key, s.SetLabels[key]))
+	}
+	for _, key := range s.RemoveLabels {
+		changes = append(changes, "remove label "+key)
+	}
+	for _, taint := range s.SetTaints {
+		changes = append(changes, "taint "+taint.String())
+	}
+	for _, taint := range s.RemoveTaints {
+		changes = append(changes, "remove taint "+taint.String())
+	}
+	return strings.Join(changes, ", ")
+}
+
+// PlanNodeSync compares the labels and taints of the Node object with the configured ones. Configured labels and
+// taints missing from the node or set to another value are set. Labels and taints on the node that are not
+// configured are only removed when managed returns true for their key, so labels and taints set by others stay.
+// A taint is identified by its key and effect.
+func PlanNodeSync(labels map[string]string, taints []Taint, nodeLabels map[string]string, nodeTaints []Taint, managed func(key string) bool) NodeSync {
+	sync := NodeSync{SetLabels: map[string]string{}}
+	for key, value := range labels {
+		if current, ok := nodeLabels[key]; !ok || current != value {
+			sync.SetLabels[key] = value
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(nodeLabels)) {
+		if _, ok := labels[key]; !ok && managed(key) {
+			sync.RemoveLabels = append(sync.RemoveLabels, key)
+		}
+	}
+
+	sameTaint := func(a, b Taint) bool { return a.Key == b.Key && a.Effect == b.Effect }
+	for _, taint := range taints {
+		if !slices.Contains(nodeTaints, taint) {
+			sync.SetTaints = append(sync.SetTaints, taint)
+		}
+	}
+	for _, taint := range nodeTaints {
+		configured := slices.ContainsFunc(taints, func(t Taint) bool { return sameTaint(t, taint) })
+		if !configured && managed(taint.Key) {
+			sync.RemoveTaints = append(sync.RemoveTaints, taint)
+		}
+	}
+	return sync
+}
+
+// nodeLabelsAndTaints holds the fields of the node object the label and taint reconciliation reads
+type nodeLabelsAndTaints struct {
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Taints []Taint `json:"taints"`
+	} `json:"spec"`
+}
+
+// SyncNode sets the labels of node.labels and the taints of node.taints on the registered Node object and
+// removes the labels and taints managed returns true for that are no longer configured. Kubelet only applies
+// them when the node registers. Labels are set with the kubelet credentials. The NodeRestriction admission
+// plugin keeps kubelet from changing its taints, so taints are changed with the cluster admin credentials.
+func SyncNode(ctx context.Context, cfg *config.Config, managed func(key string) bool, logger *logrus.Logger) (NodeSync, error) {
+	return syncNode(ctx, cfg, NodeKubeconfigPath(), managed, logger)
+}
+
+// syncNode syncs the labels and taints of the node like SyncNode, reading and labeling the node with kubeconfig
+func syncNode(ctx context.Context, cfg *config.Config, kubeconfig string, managed func(key string) bool, logger *logrus.Logger) (NodeSync, error) {
+	nodeName := cfg.GetNodeName()
+	output, err := sysutil.RunCommandWithOutput("kubectl", "--kubeconfig", kubeconfig, "get", "node", nodeName, "-o", "json")
+	if err != nil {
+		return NodeSync{}, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+	var node nodeLabelsAndTaints
+	if err := json.Unmarshal([]byte(output), &node); err != nil {
+		return NodeSync{}, fmt.Errorf("failed to parse node %s: %w", nodeName, err)
+	}
+
+	// The taints were validated when the configuration was loaded
+	var taints []Taint
+	for _, entry := range cfg.Node.Taints {
+		taint, err := ParseTaint(entry)
+		if err != nil {
+			return NodeSync{}, err
+		}
+		taints = append(taints, taint)
+	}
+
+	sync := PlanNodeSync(cfg.Node.Labels, taints, node.Metadata.Labels, node.Spec.Taints, managed)
+	if sync.IsEmpty() {
+		return sync, nil
+	}
+
+	if err := applyNodeLabels(cfg, kubeconfig, sync.SetLabels, sync.RemoveLabels, logger); err != nil {
+		return NodeSync{}, err
+	}
+	if len(sync.SetTaints) == 0 && len(sync.RemoveTaints) == 0 {
+		return sync, nil
+	}
+
+	adminKubeconfig, err := WriteAdminKubeconfig(ctx, logger)
+	if err != nil {
+		return NodeSync{}, fmt.Errorf("failed to get cluster credentials to taint node %s: %w", nodeName, err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	args := []string{"--kubeconfig", adminKubeconfig, "taint", "node", nodeName, "--overwrite"}
+	for _, taint := range sync.SetTaints {
+		args = append(args, taint.String())
+	}
+	for _, taint := range sync.RemoveTaints {
+		args = append(args, taint.Key+":"+taint.Effect+"-")
+	}
+	if _, err := sysutil.RunCommandContext(ctx, "kubectl", args...); err != nil {
+		return NodeSync{}, fmt.Errorf("failed to taint node %s: %w", nodeName, err)
+	}
+	return sync, nil
+}
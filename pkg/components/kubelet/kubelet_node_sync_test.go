@@ -0,0 +1,73 @@
+package kubelet
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTaint(t *testing.T) {
+	tests := []struct {
+		taint   string
+		want    Taint
+		wantErr bool
+	}{
+		{taint: "dedicated=edge:NoSchedule", want: Taint{Key: "dedicated", Value: "edge", Effect: "NoSchedule"}},
+		{taint: "example.com/maintenance:NoExecute", want: Taint{Key: "example.com/maintenance", Effect: "NoExecute"}},
+		{taint: "dedicated=edge", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTaint(tt.taint)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseTaint(%q) error = %v, wantErr %t", tt.taint, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseTaint(%q) = %+v, want %+v", tt.taint, got, tt.want)
+		}
+		if err == nil && got.String() != tt.taint {
+			t.Errorf("Taint.String() = %q, want %q", got.String(), tt.taint)
+		}
+	}
+}
+
+func TestPlanNodeSync(t *testing.T) {
+	managed := func(key string) bool { return strings.HasPrefix(key, "example.com/") }
+	labels := map[string]string{"example.com/site": "berlin", "tier": "edge"}
+	taints := []Taint{
+		{Key: "example.com/dedicated", Value: "edge", Effect: "NoSchedule"},
+		{Key: "example.com/gpu", Effect: "NoSchedule"},
+	}
+	nodeLabels := map[string]string{
+		"example.com/site":       "munich",
+		"example.com/old":        "true",
+		"kubernetes.io/hostname": "edge-1",
+		"team":                   "platform",
+	}
+	nodeTaints := []Taint{
+		{Key: "example.com/dedicated", Value: "core", Effect: "NoSchedule"},
+		{Key: "example.com/gpu", Effect: "NoSchedule"},
+		{Key: "example.com/gpu", Effect: "NoExecute"},
+		{Key: "node.kubernetes.io/unreachable", Effect: "NoExecute"},
+	}
+
+	sync := PlanNodeSync(labels, taints, nodeLabels, nodeTaints, managed)
+
+	if want := map[string]string{"example.com/site": "berlin", "tier": "edge"}; !maps.Equal(sync.SetLabels, want) {
+		t.Errorf("SetLabels = %v, want %v", sync.SetLabels, want)
+	}
+	if want := []string{"example.com/old"}; !slices.Equal(sync.RemoveLabels, want) {
+		t.Errorf("RemoveLabels = %v, want %v", sync.RemoveLabels, want)
+	}
+	if want := []Taint{{Key: "example.com/dedicated", Value: "edge", Effect: "NoSchedule"}}; !slices.Equal(sync.SetTaints, want) {
+		t.Errorf("SetTaints = %v, want %v", sync.SetTaints, want)
+	}
+	if want := []Taint{{Key: "example.com/gpu", Effect: "NoExecute"}}; !slices.Equal(sync.RemoveTaints, want) {
+		t.Errorf("RemoveTaints = %v, want %v", sync.RemoveTaints, want)
+	}
+
+	inSync := PlanNodeSync(labels, taints, labels, taints, managed)
+	if !inSync.IsEmpty() {
+		t.Errorf("PlanNodeSync() for a node in sync = %s, want no changes", inSync)
+	}
+}
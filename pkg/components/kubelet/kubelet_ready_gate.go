@@ -0,0 +1,133 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// readyPollInterval is how often the gate reads the Ready condition, replaced in tests
+var readyPollInterval = 5 * time.Second
+
+// readyGateJournalLines is the number of kubelet journal lines included when the node never turns Ready
+const readyGateJournalLines = 20
+
+// ReadyGate holds bootstrap back until the node reports Ready, so bootstrap does not succeed with a node
+// that registered but cannot run pods, for example because the CNI is not initialized
+type ReadyGate struct {
+	config  *config.Config
+	logger  *logrus.Logger
+	confDir string // CNI configuration directory reported when the node never turns Ready
+}
+
+// NewReadyGate creates a new ReadyGate
+func NewReadyGate(logger *logrus.Logger) *ReadyGate {
+	return &ReadyGate{
+		config:  config.GetConfig(),
+		logger:  logger,
+		confDir: defaults.Get().Paths.CNIConfDir,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (g *ReadyGate) GetName() string {
+	return "NodeReadyGate"
+}
+
+// Validate validates prerequisites for the readiness gate
+func (g *ReadyGate) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so readiness is checked on every bootstrap
+func (g *ReadyGate) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute waits up to node.readyTimeout for the node Ready condition, failing with diagnostics when it
+// never turns True. Without node.readyTimeout bootstrap does not wait.
+func (g *ReadyGate) Execute(ctx context.Context) error {
+	if g.config.Node.ReadyTimeout == "" {
+		g.logger.Debug("node.readyTimeout is not set, not waiting for the node to become Ready")
+		return nil
+	}
+	// The timeout was validated when the configuration was loaded
+	timeout, err := time.ParseDuration(g.config.Node.ReadyTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid node.readyTimeout %s: %w", g.config.Node.ReadyTimeout, err)
+	}
+	nodeName := g.config.GetNodeName()
+	g.logger.Infof("Waiting up to %s for node %s to become Ready", timeout, nodeName)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var condition string
+	for {
+		// Kubelet has no kubeconfig until its client certificate is issued, reading the node fails until then
+		output, err := utils.RunCommandContext(waitCtx, "kubectl", "--kubeconfig", KubeletKubeconfigPath, "get", "node", nodeName,
+			"-o", `jsonpath={range .status.conditions[?(@.type=="Ready")]}{.status}|{.reason}|{.message}{end}`)
+		if err == nil {
+			condition = strings.TrimSpace(output)
+			if status, _, _ := strings.Cut(condition, "|"); status == "True" {
+				g.logger.Infof("Node %s is Ready after %s", nodeName, time.Since(start).Round(time.Second))
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for node %s to become Ready: %w", nodeName, ctx.Err())
+		case <-waitCtx.Done():
+			return fmt.Errorf("node %s did not become Ready within %s:\n%s", nodeName, timeout, g.diagnostics(ctx, condition))
+		case <-ticker.C:
+		}
+	}
+}
+
+// diagnostics describes why the node may not be Ready: its Ready condition, the CNI configuration
+// kubelet needs for NetworkReady and the end of the kubelet journal
+func (g *ReadyGate) diagnostics(ctx context.Context, condition string) string {
+	var lines []string
+	if status, rest, ok := strings.Cut(condition, "|"); ok {
+		reason, message, _ := strings.Cut(rest, "|")
+		lines = append(lines, fmt.Sprintf("  Ready condition: %s, reason %s: %s", status, reason, message))
+	} else {
+		lines = append(lines, "  Ready condition: not reported, the node may not have registered")
+	}
+
+	entries, err := os.ReadDir(g.confDir)
+	switch {
+	case err != nil:
+		lines = append(lines, fmt.Sprintf("  CNI configuration: cannot read %s: %v", g.confDir, err))
+	case len(entries) == 0:
+		lines = append(lines, fmt.Sprintf("  CNI configuration: %s is empty, the network plugin is not initialized", g.confDir))
+	default:
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		lines = append(lines, fmt.Sprintf("  CNI configuration in %s: %s", g.confDir, strings.Join(names, ", ")))
+	}
+
+	journal, err := utils.RunCommandContext(ctx, "journalctl", "-u", "kubelet", "-n", fmt.Sprint(readyGateJournalLines), "--no-pager", "-o", "cat")
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("  kubelet journal: unavailable: %v", err))
+	} else {
+		lines = append(lines, fmt.Sprintf("  last %d kubelet journal lines:", readyGateJournalLines))
+		for _, line := range strings.Split(strings.TrimSpace(journal), "\n") {
+			lines = append(lines, "    "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,99 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// fakeReadyRunner answers the Ready condition queries with successive conditions, the last one repeats
+type fakeReadyRunner struct {
+	conditions []string
+	journal    string
+}
+
+func (f *fakeReadyRunner) Run(_ context.Context, cmd utils.Command) (*utils.CommandResult, error) {
+	switch cmd.Name {
+	case "kubectl":
+		condition := f.conditions[0]
+		if len(f.conditions) > 1 {
+			f.conditions = f.conditions[1:]
+		}
+		if condition == "" {
+			return nil, errors.New(`nodes "edge-node-1" not found`)
+		}
+		return &utils.CommandResult{Output: condition}, nil
+	case "journalctl":
+		return &utils.CommandResult{Output: f.journal}, nil
+	}
+	return nil, errors.New("unexpected command " + cmd.Name)
+}
+
+func newTestReadyGate(t *testing.T, runner *fakeReadyRunner, readyTimeout string) *ReadyGate {
+	t.Helper()
+	original := readyPollInterval
+	readyPollInterval = time.Millisecond
+	t.Cleanup(func() { readyPollInterval = original })
+	t.Cleanup(utils.SetCommandRunner(runner))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	cfg.Node.HostnameOverride = "edge-node-1"
+	cfg.Node.ReadyTimeout = readyTimeout
+	return &ReadyGate{config: cfg, logger: logger, confDir: t.TempDir()}
+}
+
+func TestReadyGateWaitsForReady(t *testing.T) {
+	runner := &fakeReadyRunner{conditions: []string{"", "False|KubeletNotReady|container runtime network not ready", "True|KubeletReady|kubelet is posting ready status"}}
+	gate := newTestReadyGate(t, runner, "5s")
+
+	if err := gate.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if len(runner.conditions) != 1 {
+		t.Errorf("Execute() returned before the node turned Ready, %d conditions left", len(runner.conditions))
+	}
+}
+
+func TestReadyGateReportsDiagnostics(t *testing.T) {
+	runner := &fakeReadyRunner{
+		conditions: []string{"False|KubeletNotReady|container runtime network not ready: NetworkReady=false reason:NetworkPluginNotReady"},
+		journal:    "E1016 kubelet.go:2900] \"Container runtime network not ready\" networkReady=\"NetworkReady=false\"\n",
+	}
+	gate := newTestReadyGate(t, runner, "20ms")
+
+	err := gate.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Execute() succeeded for a node that never turned Ready")
+	}
+	for _, want := range []string{"did not become Ready within 20ms", "reason KubeletNotReady", "is empty, the network plugin is not initialized", "Container runtime network not ready"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Execute() error = %v, want it to contain %q", err, want)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(gate.confDir, "05-cilium.conflist"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := gate.diagnostics(context.Background(), ""); !strings.Contains(got, "not reported") || !strings.Contains(got, "05-cilium.conflist") {
+		t.Errorf("diagnostics() = %q, want the missing condition and the CNI configuration listed", got)
+	}
+}
+
+func TestReadyGateDisabledWithoutTimeout(t *testing.T) {
+	gate := newTestReadyGate(t, &fakeReadyRunner{}, "")
+	if err := gate.Execute(context.Background()); err != nil {
+		t.Errorf("Execute() without node.readyTimeout error = %v, want nil", err)
+	}
+}
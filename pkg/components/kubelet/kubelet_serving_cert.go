@@ -0,0 +1,185 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// servingSignerName is the signer of kubelet serving certificates requested with --rotate-server-certificates
+const servingSignerName = "kubernetes.io/kubelet-serving"
+
+// servingCSRWait bounds how long bootstrap waits for kubelet to request its serving certificate, and
+// servingCSRPollInterval is how often the requests are listed meanwhile
+var (
+	servingCSRWait         = time.Minute
+	servingCSRPollInterval = 5 * time.Second
+)
+
+// csrList holds the fields of certificate signing requests the serving certificate check reads
+type csrList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			SignerName string `json:"signerName"`
+			Username   string `json:"username"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []struct {
+				Type string `json:"type"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// parsePendingServingCSRs returns the names of the serving certificate signing requests of the node that are
+// neither approved nor denied
+func parsePendingServingCSRs(data []byte, nodeName string) ([]string, error) {
+	var list csrList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate signing requests: %w", err)
+	}
+	var pending []string
+	for _, csr := range list.Items {
+		if csr.Spec.SignerName != servingSignerName || csr.Spec.Username != "system:node:"+nodeName {
+			continue
+		}
+		if len(csr.Status.Conditions) == 0 {
+			pending = append(pending, csr.Metadata.Name)
+		}
+	}
+	return pending, nil
+}
+
+// PendingServingCSRs lists the node's serving certificate signing requests waiting for approval with the kubelet
+// credentials, which may read certificate signing requests. It returns nothing before kubelet has its client certificate.
+func PendingServingCSRs(ctx context.Context, nodeName string) ([]string, error) {
+	if !fsutil.FileExists(KubeletKubeconfigPath) {
+		return nil, nil
+	}
+	output, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", KubeletKubeconfigPath, "get", "csr", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificate signing requests: %w", err)
+	}
+	return parsePendingServingCSRs([]byte(output), nodeName)
+}
+
+// ApproveServingCSRs approves the node's pending serving certificate signing requests with the cluster credentials
+// and returns their names. Only requests kubelet of this node made for the kubelet serving signer are approved.
+func ApproveServingCSRs(ctx context.Context, nodeName string, logger *logrus.Logger) ([]string, error) {
+	pending, err := PendingServingCSRs(ctx, nodeName)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	adminKubeconfig, err := WriteAdminKubeconfig(ctx, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	if _, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", adminKubeconfig,
+		"auth", "can-i", "update", "certificatesigningrequests/approval"); err != nil {
+		return nil, fmt.Errorf("the cluster credentials may not approve certificate signing requests: %w", err)
+	}
+	for _, name := range pending {
+		if _, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", adminKubeconfig, "certificate", "approve", name); err != nil {
+			return nil, fmt.Errorf("failed to approve certificate signing request %s: %w", name, err)
+		}
+	}
+	return pending, nil
+}
+
+// ServingCertChecker checks that kubelet received its serving certificate after TLS bootstrap. With
+// node.kubelet.serverTLSBootstrap the request waits for approval, and until it is approved metrics-server
+// and kubectl logs cannot reach the kubelet API of the node.
+type ServingCertChecker struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewServingCertChecker creates a new ServingCertChecker
+func NewServingCertChecker(logger *logrus.Logger) *ServingCertChecker {
+	return &ServingCertChecker{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *ServingCertChecker) GetName() string {
+	return "KubeletServingCertCheck"
+}
+
+// Validate validates prerequisites for the serving certificate check
+func (c *ServingCertChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when kubelet self-signs its serving certificate or already has one from the cluster
+func (c *ServingCertChecker) IsCompleted(_ context.Context) bool {
+	return !c.config.Node.Kubelet.ServerTLSBootstrap || fsutil.FileExists(KubeletServingCertPath)
+}
+
+// Execute waits for kubelet to request its serving certificate and approves the request when
+// node.kubelet.approveServingCSR is set. Pending requests are reported, not failed: the node runs pods
+// without the serving certificate, and a cluster approver may still approve it.
+func (c *ServingCertChecker) Execute(ctx context.Context) error {
+	if !c.config.Node.Kubelet.ServerTLSBootstrap {
+		return nil
+	}
+	nodeName := c.config.GetNodeName()
+
+	waitCtx, cancel := context.WithTimeout(ctx, servingCSRWait)
+	defer cancel()
+	ticker := time.NewTicker(servingCSRPollInterval)
+	defer ticker.Stop()
+
+	var pending []string
+	for {
+		var err error
+		if pending, err = PendingServingCSRs(waitCtx, nodeName); err != nil {
+			c.logger.Debugf("Serving certificate requests not listed yet: %v", err)
+		}
+		if len(pending) > 0 || fsutil.FileExists(KubeletServingCertPath) {
+			break
+		}
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.logger.Warnf("Kubelet requested no serving certificate within %s, check the kubelet journal", servingCSRWait)
+			return nil
+		case <-ticker.C:
+		}
+	}
+	if len(pending) == 0 {
+		c.logger.Info("Kubelet has its serving certificate")
+		return nil
+	}
+
+	if !c.config.Node.Kubelet.ApproveServingCSR {
+		c.logger.Warnf("Kubelet serving certificate requests %s are waiting for approval; until one is approved metrics-server "+
+			"cannot scrape the node. Approve them with kubectl certificate approve or set node.kubelet.approveServingCSR",
+			strings.Join(pending, ", "))
+		return nil
+	}
+	approved, err := ApproveServingCSRs(ctx, nodeName, c.logger)
+	if err != nil {
+		c.logger.Warnf("Failed to approve kubelet serving certificate requests %s: %v", strings.Join(pending, ", "), err)
+		return nil
+	}
+	c.logger.Infof("Approved kubelet serving certificate requests %s", strings.Join(approved, ", "))
+	return nil
+}
@@ -0,0 +1,32 @@
+package kubelet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePendingServingCSRs(t *testing.T) {
+	csrs := `{"items": [
+  {"metadata": {"name": "csr-serving-pending"},
+   "spec": {"signerName": "kubernetes.io/kubelet-serving", "username": "system:node:edge-node-1"}, "status": {}},
+  {"metadata": {"name": "csr-serving-approved"},
+   "spec": {"signerName": "kubernetes.io/kubelet-serving", "username": "system:node:edge-node-1"},
+   "status": {"conditions": [{"type": "Approved"}]}},
+  {"metadata": {"name": "csr-client-pending"},
+   "spec": {"signerName": "kubernetes.io/kube-apiserver-client-kubelet", "username": "system:node:edge-node-1"}, "status": {}},
+  {"metadata": {"name": "csr-other-node"},
+   "spec": {"signerName": "kubernetes.io/kubelet-serving", "username": "system:node:edge-node-2"}, "status": {}}
+]}`
+
+	pending, err := parsePendingServingCSRs([]byte(csrs), "edge-node-1")
+	if err != nil {
+		t.Fatalf("parsePendingServingCSRs() error = %v", err)
+	}
+	if want := []string{"csr-serving-pending"}; !reflect.DeepEqual(pending, want) {
+		t.Errorf("parsePendingServingCSRs() = %v, want %v", pending, want)
+	}
+
+	if _, err := parsePendingServingCSRs([]byte("not json"), "edge-node-1"); err == nil {
+		t.Error("parsePendingServingCSRs() of invalid output succeeded, want an error")
+	}
+}
@@ -0,0 +1,53 @@
+package kubelet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// minSwapKubernetesMinor is the first Kubernetes minor version with the NodeSwap feature enabled by default
+// and the NoSwap and LimitedSwap behaviors
+const minSwapKubernetesMinor = 30
+
+// validateSwap checks that the kubelet version and host support the configured swap behavior.
+// It returns warnings for settings that are valid but have no effect on this machine.
+func validateSwap(swapBehavior, kubernetesVersion, sysfsRoot, procSwapsPath string) ([]string, error) {
+	minor, err := compat.MinorVersion(kubernetesVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check swap support for kubernetes.version %q: %w", kubernetesVersion, err)
+	}
+	if minor < minSwapKubernetesMinor {
+		return nil, fmt.Errorf("swapBehavior requires kubelet 1.%d or newer, configured version is %s", minSwapKubernetesMinor, kubernetesVersion)
+	}
+
+	// Kubelet only limits container swap usage through cgroup v2
+	if swapBehavior == "LimitedSwap" && !utils.FileExists(filepath.Join(sysfsRoot, "fs/cgroup/cgroup.controllers")) {
+		return nil, fmt.Errorf("swapBehavior LimitedSwap requires cgroup v2")
+	}
+
+	var warnings []string
+	swaps, err := os.ReadFile(procSwapsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read active swap devices: %w", err)
+	}
+	// The first line of /proc/swaps is a header
+	if lines := strings.Split(strings.TrimSpace(string(swaps)), "\n"); len(lines) < 2 {
+		warnings = append(warnings, fmt.Sprintf("swapBehavior %s is configured but no swap device is active on this machine", swapBehavior))
+	}
+	return warnings, nil
+}
+
+// renderKubeletConfigFile renders the kubelet configuration file for settings that have no command line flag
+func renderKubeletConfigFile(swapBehavior string) string {
+	return fmt.Sprintf(`apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+failSwapOn: false
+memorySwap:
+  swapBehavior: %s
+`, swapBehavior)
+}
@@ -0,0 +1,99 @@
+package kubelet
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSwap(t *testing.T) {
+	const swapsHeader = "Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"
+	const activeSwap = swapsHeader + "/swap.img\t\t\t\tfile\t\t4194300\t\t0\t\t-2\n"
+
+	tests := []struct {
+		name              string
+		swapBehavior      string
+		kubernetesVersion string
+		cgroupV2          bool
+		swaps             string
+		wantErr           string
+		wantWarnings      int
+	}{
+		{
+			name:              "limited swap on cgroup v2",
+			swapBehavior:      "LimitedSwap",
+			kubernetesVersion: "1.32.7",
+			cgroupV2:          true,
+			swaps:             activeSwap,
+		},
+		{
+			name:              "no swap behavior on cgroup v1",
+			swapBehavior:      "NoSwap",
+			kubernetesVersion: "1.31.0",
+			swaps:             activeSwap,
+		},
+		{
+			name:              "kubelet too old",
+			swapBehavior:      "NoSwap",
+			kubernetesVersion: "1.29.4",
+			cgroupV2:          true,
+			swaps:             activeSwap,
+			wantErr:           "requires kubelet 1.30 or newer",
+		},
+		{
+			name:              "unparseable kubelet version",
+			swapBehavior:      "NoSwap",
+			kubernetesVersion: "latest",
+			swaps:             activeSwap,
+			wantErr:           "cannot check swap support",
+		},
+		{
+			name:              "limited swap on cgroup v1",
+			swapBehavior:      "LimitedSwap",
+			kubernetesVersion: "1.32.7",
+			swaps:             activeSwap,
+			wantErr:           "requires cgroup v2",
+		},
+		{
+			name:              "no active swap device warns",
+			swapBehavior:      "LimitedSwap",
+			kubernetesVersion: "1.32.7",
+			cgroupV2:          true,
+			swaps:             swapsHeader,
+			wantWarnings:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tt.cgroupV2 {
+				writeSysfsFile(t, root, "fs/cgroup/cgroup.controllers", "cpu memory pids\n")
+			}
+			writeSysfsFile(t, root, "swaps", tt.swaps)
+
+			warnings, err := validateSwap(tt.swapBehavior, tt.kubernetesVersion, root, filepath.Join(root, "swaps"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateSwap() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateSwap() unexpected error: %v", err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("validateSwap() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestRenderKubeletConfigFile(t *testing.T) {
+	rendered := renderKubeletConfigFile("LimitedSwap")
+	for _, want := range []string{"kind: KubeletConfiguration", "failSwapOn: false", "swapBehavior: LimitedSwap"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("renderKubeletConfigFile() missing %q:\n%s", want, rendered)
+		}
+	}
+}
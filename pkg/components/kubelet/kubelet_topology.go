@@ -0,0 +1,130 @@
+package kubelet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// hardwareTopology describes the CPUs and NUMA nodes detected on this machine
+type hardwareTopology struct {
+	OnlineCPUs []int
+	NUMANodes  []int
+}
+
+// detectHardwareTopology reads the online CPUs and NUMA nodes from sysfs.
+// Kernels without NUMA support expose no node directory, which is treated as a single NUMA node.
+func detectHardwareTopology(sysfsRoot string) (*hardwareTopology, error) {
+	cpuData, err := os.ReadFile(filepath.Join(sysfsRoot, "devices/system/cpu/online"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read online CPUs: %w", err)
+	}
+	onlineCPUs, err := utils.ParseCPUSet(string(cpuData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse online CPUs: %w", err)
+	}
+
+	numaNodes := []int{0}
+	if nodeData, err := os.ReadFile(filepath.Join(sysfsRoot, "devices/system/node/online")); err == nil {
+		if numaNodes, err = utils.ParseCPUSet(string(nodeData)); err != nil {
+			return nil, fmt.Errorf("failed to parse online NUMA nodes: %w", err)
+		}
+	}
+
+	return &hardwareTopology{OnlineCPUs: onlineCPUs, NUMANodes: numaNodes}, nil
+}
+
+// hasResourceManagerSettings reports whether any CPU, topology or memory manager setting is configured
+func hasResourceManagerSettings(kubeletConfig config.KubeletConfig) bool {
+	return kubeletConfig.CPUManagerPolicy != "" || kubeletConfig.TopologyManagerPolicy != "" ||
+		kubeletConfig.ReservedSystemCPUs != "" || kubeletConfig.MemoryManagerPolicy != ""
+}
+
+// validateResourceManagers checks the kubelet resource manager settings against the detected hardware.
+// It returns warnings for settings that are valid but have no effect on this machine.
+func validateResourceManagers(kubeletConfig config.KubeletConfig, topology *hardwareTopology) ([]string, error) {
+	var warnings []string
+
+	if kubeletConfig.ReservedSystemCPUs != "" {
+		reserved, err := utils.ParseCPUSet(kubeletConfig.ReservedSystemCPUs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reservedSystemCPUs: %w", err)
+		}
+		for _, cpu := range reserved {
+			if !slices.Contains(topology.OnlineCPUs, cpu) {
+				return nil, fmt.Errorf("reservedSystemCPUs %s includes CPU %d, which is not online on this machine (%d CPUs online)",
+					kubeletConfig.ReservedSystemCPUs, cpu, len(topology.OnlineCPUs))
+			}
+		}
+		if len(reserved) >= len(topology.OnlineCPUs) {
+			return nil, fmt.Errorf("reservedSystemCPUs %s reserves all %d online CPUs, leaving none for pods",
+				kubeletConfig.ReservedSystemCPUs, len(topology.OnlineCPUs))
+		}
+	}
+
+	switch kubeletConfig.TopologyManagerPolicy {
+	case "restricted", "single-numa-node":
+		if len(topology.NUMANodes) == 1 {
+			warnings = append(warnings, fmt.Sprintf(
+				"topologyManagerPolicy %s has no effect on a machine with a single NUMA node", kubeletConfig.TopologyManagerPolicy))
+		}
+		if kubeletConfig.CPUManagerPolicy != "static" {
+			warnings = append(warnings, fmt.Sprintf(
+				"topologyManagerPolicy %s only aligns exclusive CPUs when cpuManagerPolicy is static", kubeletConfig.TopologyManagerPolicy))
+		}
+	}
+
+	if kubeletConfig.ReservedMemory != "" {
+		for _, entry := range strings.Split(kubeletConfig.ReservedMemory, ";") {
+			nodeID, _, _ := strings.Cut(strings.TrimSpace(entry), ":")
+			node, err := strconv.Atoi(nodeID)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reservedMemory entry %q", entry)
+			}
+			if !slices.Contains(topology.NUMANodes, node) {
+				return nil, fmt.Errorf("reservedMemory references NUMA node %d, but this machine has NUMA nodes %v", node, topology.NUMANodes)
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// managerState is the subset of the kubelet CPU and memory manager checkpoint files the agent inspects
+type managerState struct {
+	PolicyName string `json:"policyName"`
+}
+
+// removeStaleManagerState removes a kubelet CPU or memory manager checkpoint recorded with a different policy.
+// Kubelet refuses to start when the configured policy does not match its checkpoint.
+func (i *Installer) removeStaleManagerState(statePath, desiredPolicy, defaultPolicy string) error {
+	if !utils.FileExists(statePath) {
+		return nil
+	}
+	if desiredPolicy == "" {
+		desiredPolicy = defaultPolicy
+	}
+
+	data, err := utils.RunCommandWithOutput("cat", statePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", statePath, err)
+	}
+	state := managerState{}
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		i.logger.Warnf("Removing unreadable kubelet manager state %s: %v", statePath, err)
+		return utils.RunCleanupCommand(statePath)
+	}
+	if strings.EqualFold(state.PolicyName, desiredPolicy) {
+		return nil
+	}
+
+	i.logger.Infof("Removing kubelet manager state %s recorded with policy %s, configured policy is %s", statePath, state.PolicyName, desiredPolicy)
+	return utils.RunCleanupCommand(statePath)
+}
@@ -0,0 +1,117 @@
+package kubelet
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func writeSysfsFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(fullPath), err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", fullPath, err)
+	}
+}
+
+func TestDetectHardwareTopology(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsFile(t, root, "devices/system/cpu/online", "0-7\n")
+	writeSysfsFile(t, root, "devices/system/node/online", "0-1\n")
+
+	topology, err := detectHardwareTopology(root)
+	if err != nil {
+		t.Fatalf("detectHardwareTopology() error = %v", err)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7}; !reflect.DeepEqual(topology.OnlineCPUs, want) {
+		t.Errorf("OnlineCPUs = %v, want %v", topology.OnlineCPUs, want)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(topology.NUMANodes, want) {
+		t.Errorf("NUMANodes = %v, want %v", topology.NUMANodes, want)
+	}
+
+	// Without NUMA support in the kernel the machine is a single NUMA node
+	noNUMARoot := t.TempDir()
+	writeSysfsFile(t, noNUMARoot, "devices/system/cpu/online", "0-3\n")
+	topology, err = detectHardwareTopology(noNUMARoot)
+	if err != nil {
+		t.Fatalf("detectHardwareTopology() without NUMA error = %v", err)
+	}
+	if want := []int{0}; !reflect.DeepEqual(topology.NUMANodes, want) {
+		t.Errorf("NUMANodes without NUMA = %v, want %v", topology.NUMANodes, want)
+	}
+}
+
+func TestValidateResourceManagers(t *testing.T) {
+	dualSocket := &hardwareTopology{OnlineCPUs: []int{0, 1, 2, 3, 4, 5, 6, 7}, NUMANodes: []int{0, 1}}
+	singleSocket := &hardwareTopology{OnlineCPUs: []int{0, 1, 2, 3}, NUMANodes: []int{0}}
+
+	tests := []struct {
+		name         string
+		kubelet      config.KubeletConfig
+		topology     *hardwareTopology
+		wantErr      string
+		wantWarnings int
+	}{
+		{
+			name: "pinned CPUs on dual socket machine",
+			kubelet: config.KubeletConfig{
+				CPUManagerPolicy:      "static",
+				TopologyManagerPolicy: "single-numa-node",
+				ReservedSystemCPUs:    "0,4",
+				MemoryManagerPolicy:   "Static",
+				ReservedMemory:        "0:memory=1Gi;1:memory=1Gi",
+			},
+			topology: dualSocket,
+		},
+		{
+			name:     "reserved CPU not online",
+			kubelet:  config.KubeletConfig{CPUManagerPolicy: "static", ReservedSystemCPUs: "0-1,12"},
+			topology: dualSocket,
+			wantErr:  "includes CPU 12",
+		},
+		{
+			name:     "all CPUs reserved",
+			kubelet:  config.KubeletConfig{CPUManagerPolicy: "static", ReservedSystemCPUs: "0-3"},
+			topology: singleSocket,
+			wantErr:  "leaving none for pods",
+		},
+		{
+			name:     "reserved memory on missing NUMA node",
+			kubelet:  config.KubeletConfig{MemoryManagerPolicy: "Static", ReservedMemory: "1:memory=1Gi"},
+			topology: singleSocket,
+			wantErr:  "NUMA node 1",
+		},
+		{
+			name:         "NUMA policy on single NUMA node without static CPU manager warns",
+			kubelet:      config.KubeletConfig{TopologyManagerPolicy: "restricted"},
+			topology:     singleSocket,
+			wantWarnings: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := validateResourceManagers(tt.kubelet, tt.topology)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateResourceManagers() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateResourceManagers() unexpected error = %v", err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("validateResourceManagers() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -2,9 +2,14 @@ package kubelet
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
 )
 
 // UnInstaller handles kubelet cleanup operations
@@ -35,6 +40,18 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 		}
 	}
 
+	// Stop resealing and release the in-memory PKI directory of TPM key protection
+	if utils.FileExists(keySealPathUnitPath) {
+		if err := utils.StopService(keySealPathUnit); err != nil {
+			u.logger.Warnf("Failed to stop %s: %v (continuing)", keySealPathUnit, err)
+		}
+	}
+	if isMountPoint(kubeletPKIDir) {
+		if err := utils.RunSystemCommand("umount", kubeletPKIDir); err != nil {
+			u.logger.Warnf("Failed to unmount %s: %v (continuing)", kubeletPKIDir, err)
+		}
+	}
+
 	// Remove kubelet configuration files
 	kubeletFiles := []string{
 		kubeletDefaultsPath,
@@ -43,7 +60,13 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 		kubeletConfigPath,
 		kubeletKubeConfig,
 		kubeletBootstrapKubeConfig,
+		KubeletBootstrapKubeconfigPath,
 		kubeletTokenScriptPath,
+		kubeletClientSecretPath,
+		kubeletAdoptionStatePath,
+		kubeletKeySealScriptPath,
+		keySealPathUnitPath,
+		keySealServiceUnitPath,
 	}
 
 	// Remove kubelet configuration directories
@@ -52,6 +75,7 @@ func (u *UnInstaller) Execute(ctx context.Context) error {
 		kubeletVarDir,          // /var/lib/kubelet
 		kubeletManifestsDir,    // Static pod manifests (kubelet-specific)
 		kubeletVolumePluginDir, // Volume plugins (kubelet-specific)
+		kubeletSealedPKIDir,    // TPM-sealed kubelet client key
 	}
 
 	// Remove individual files
@@ -84,6 +108,7 @@ func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 		kubeletConfigPath,
 		kubeletKubeConfig,
 		kubeletBootstrapKubeConfig,
+		KubeletBootstrapKubeconfigPath,
 		kubeletTokenScriptPath, // Check token script cleanup
 	}
 
@@ -95,3 +120,38 @@ func (u *UnInstaller) IsCompleted(ctx context.Context) bool {
 
 	return true
 }
+
+// Plan describes the kubelet cleanup for dry runs
+func (u *UnInstaller) Plan(ctx context.Context) []string {
+	return []string{
+		"stop kubelet",
+		fmt.Sprintf("remove %s, %s and the kubelet kubeconfigs and token script", kubeletDefaultsPath, kubeletServicePath),
+		fmt.Sprintf("remove %s, %s, %s and %s", kubeletServiceDir, kubeletVarDir, kubeletManifestsDir, kubeletVolumePluginDir),
+		"reload systemd",
+	}
+}
+
+// SecretFiles returns the kubelet files holding credentials: the service principal secret the token script reads,
+// the kubeconfigs and the kubelet client and serving certificates and keys. The root-only PKI directory is listed
+// through sudo; when it cannot be listed, the files found elsewhere are returned with the error.
+func SecretFiles() ([]string, error) {
+	files := []string{
+		kubeletClientSecretPath,
+		kubeletTokenScriptPath,
+		KubeletBootstrapKubeconfigPath,
+		KubeletKubeconfigPath,
+		kubeletKubeConfig,
+		kubeletBootstrapKubeConfig,
+	}
+	names, err := fsutil.ListFilesSystem(kubeletPKIDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return files, fmt.Errorf("failed to list the kubelet certificates and keys: %w", err)
+	}
+	for _, name := range names {
+		files = append(files, filepath.Join(kubeletPKIDir, name))
+	}
+	return files, nil
+}
@@ -0,0 +1,198 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// upgradeCordonAnnotation marks a node the agent cordoned for a Kubernetes upgrade, so the upgrade only
+// uncordons nodes it cordoned itself and leaves nodes an administrator cordoned alone
+const upgradeCordonAnnotation = "kubernetes.azure.com/flex-node-upgrade-cordoned"
+
+// kubeProxyServiceName is the kube-proxy service restarted with kubelet when kubeProxy.enabled is set
+const kubeProxyServiceName = "kube-proxy"
+
+// UpgradeDrainer cordons and drains the node before a Kubernetes upgrade replaces the binaries and restarts
+// kubelet. Unlike Drainer it keeps the node in the cluster.
+type UpgradeDrainer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUpgradeDrainer creates a new UpgradeDrainer
+func NewUpgradeDrainer(logger *logrus.Logger) *UpgradeDrainer {
+	return &UpgradeDrainer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (d *UpgradeDrainer) GetName() string {
+	return "NodeUpgradeDrain"
+}
+
+// Validate validates prerequisites for the drain
+func (d *UpgradeDrainer) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when draining is disabled, the upgrade then restarts kubelet under running pods
+func (d *UpgradeDrainer) IsCompleted(_ context.Context) bool {
+	return !d.config.IsDrainEnabled()
+}
+
+// Execute cordons the node, marking it as cordoned for the upgrade unless it was cordoned already, and evicts
+// its pods. A drain that does not complete fails the step and leaves the node cordoned.
+func (d *UpgradeDrainer) Execute(ctx context.Context) error {
+	nodeName := d.config.GetNodeName()
+	kubeconfigPath, err := WriteAdminKubeconfig(ctx, d.logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials to drain node %s: %w", nodeName, err)
+	}
+	defer fsutil.CleanupTempFile(kubeconfigPath)
+
+	unschedulable, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "get", "node", nodeName,
+		"-o", "jsonpath={.spec.unschedulable}")
+	if err != nil {
+		return fmt.Errorf("failed to read node %s: %w", nodeName, err)
+	}
+	if strings.TrimSpace(unschedulable) == "true" {
+		d.logger.Infof("Node %s is already cordoned, it stays cordoned after the upgrade", nodeName)
+	} else {
+		d.logger.Infof("Cordoning node %s for the Kubernetes upgrade", nodeName)
+		if err := sysutil.RunSystemCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "annotate", "node", nodeName,
+			"--overwrite", upgradeCordonAnnotation+"=true"); err != nil {
+			return fmt.Errorf("failed to annotate node %s: %w", nodeName, err)
+		}
+		if err := sysutil.RunSystemCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "cordon", nodeName); err != nil {
+			return fmt.Errorf("failed to cordon node %s: %w", nodeName, err)
+		}
+	}
+
+	// kubectl enforces the drain timeout itself, the command may outlast the default command timeout
+	timeout := d.config.GetDrainTimeout()
+	drain := sysutil.Command{Name: "kubectl", Args: drainArgs(kubeconfigPath, nodeName, d.config.Node.Drain, timeout), Stream: true, Timeout: timeout + time.Minute}
+	if _, err := sysutil.GetCommandRunner().Run(ctx, drain); err != nil {
+		return fmt.Errorf("failed to drain node %s for the Kubernetes upgrade; set node.drain.force to delete unmanaged pods "+
+			"or check the PodDisruptionBudgets blocking evictions: %w", nodeName, err)
+	}
+	d.logger.Infof("Node %s drained", nodeName)
+	return nil
+}
+
+// Restarter restarts kubelet, and kube-proxy when the agent runs it, so they run the upgraded binaries
+type Restarter struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewRestarter creates a new Restarter
+func NewRestarter(logger *logrus.Logger) *Restarter {
+	return &Restarter{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (r *Restarter) GetName() string {
+	return "KubeletRestart"
+}
+
+// Validate validates prerequisites for the restart
+func (r *Restarter) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false, as the running kubelet cannot tell whether its binary was replaced
+func (r *Restarter) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute restarts kubelet and waits for it to run, then restarts kube-proxy
+func (r *Restarter) Execute(ctx context.Context) error {
+	r.logger.Info("Restarting kubelet on the upgraded binaries")
+	if err := sysutil.RestartService("kubelet"); err != nil {
+		return fmt.Errorf("failed to restart kubelet: %w", err)
+	}
+	if err := sysutil.WaitForService(ctx, "kubelet", 30*time.Second, r.logger); err != nil {
+		return fmt.Errorf("kubelet failed to start after the upgrade: %w", err)
+	}
+	if r.config.KubeProxy.Enabled {
+		if err := sysutil.RestartService(kubeProxyServiceName); err != nil {
+			return fmt.Errorf("failed to restart kube-proxy: %w", err)
+		}
+	}
+	return nil
+}
+
+// Uncordoner uncordons the node after a Kubernetes upgrade when the upgrade cordoned it
+type Uncordoner struct {
+	logger *logrus.Logger
+}
+
+// NewUncordoner creates a new Uncordoner
+func NewUncordoner(logger *logrus.Logger) *Uncordoner {
+	return &Uncordoner{
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *Uncordoner) GetName() string {
+	return "NodeUncordon"
+}
+
+// Validate validates prerequisites for the uncordon
+func (u *Uncordoner) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so the cordon mark is checked after every upgrade
+func (u *Uncordoner) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute uncordons the node
+func (u *Uncordoner) Execute(ctx context.Context) error {
+	return UncordonAfterUpgrade(ctx, u.logger)
+}
+
+// UncordonAfterUpgrade uncordons the node when a Kubernetes upgrade cordoned it and removes the cordon mark.
+// A node an administrator cordoned stays cordoned.
+func UncordonAfterUpgrade(ctx context.Context, logger *logrus.Logger) error {
+	nodeName := config.GetConfig().GetNodeName()
+	kubeconfigPath, err := WriteAdminKubeconfig(ctx, logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials to uncordon node %s: %w", nodeName, err)
+	}
+	defer fsutil.CleanupTempFile(kubeconfigPath)
+
+	mark, err := sysutil.RunCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "get", "node", nodeName,
+		"-o", "jsonpath={.metadata.annotations."+strings.ReplaceAll(upgradeCordonAnnotation, ".", `\.`)+"}")
+	if err != nil {
+		return fmt.Errorf("failed to read node %s: %w", nodeName, err)
+	}
+	if strings.TrimSpace(mark) != "true" {
+		return nil
+	}
+
+	if err := sysutil.RunSystemCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "uncordon", nodeName); err != nil {
+		return fmt.Errorf("failed to uncordon node %s: %w", nodeName, err)
+	}
+	if err := sysutil.RunSystemCommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "annotate", "node", nodeName,
+		upgradeCordonAnnotation+"-"); err != nil {
+		return fmt.Errorf("failed to remove the upgrade cordon mark of node %s: %w", nodeName, err)
+	}
+	logger.Infof("Node %s uncordoned", nodeName)
+	return nil
+}
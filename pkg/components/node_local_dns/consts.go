@@ -0,0 +1,18 @@
+package node_local_dns
+
+const (
+	// Static pod manifest picked up by kubelet from its --pod-manifest-path
+	nodeLocalDNSManifestPath = "/etc/kubernetes/manifests/node-local-dns.yaml"
+
+	// Corefile of the cache, mounted into the static pod
+	nodeLocalDNSConfigDir   = "/etc/node-local-dns"
+	nodeLocalDNSCorefile    = "/etc/node-local-dns/Corefile"
+	nodeLocalDNSMountedConf = "/etc/coredns/Corefile"
+
+	// Dummy interface the cache creates to hold its link-local address
+	nodeLocalDNSInterface = "nodelocaldns"
+
+	// Ports of the cache health check and metrics
+	healthPort  = 8080
+	metricsPort = 9253
+)
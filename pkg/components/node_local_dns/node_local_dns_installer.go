@@ -0,0 +1,100 @@
+package node_local_dns
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Installer runs a DNS cache on the node as a static pod, pods reach it through the link-local address kubelet
+// passes as their DNS server
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new node-local DNS Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "NodeLocalDNS_Installer"
+}
+
+// Validate has nothing to check beyond the configuration validation
+func (i *Installer) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when the cache runs the current configuration, or is disabled and not deployed
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	if !i.config.NodeLocalDNS.Enabled {
+		return !fsutil.FileExists(nodeLocalDNSManifestPath)
+	}
+	for path, want := range map[string]string{
+		nodeLocalDNSCorefile:     i.renderCorefile(),
+		nodeLocalDNSManifestPath: i.renderManifest(),
+	} {
+		existing, err := os.ReadFile(path)
+		if err != nil || string(existing) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Plan describes the node-local DNS deployment for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	if !i.config.NodeLocalDNS.Enabled {
+		return []string{"remove " + nodeLocalDNSManifestPath + " (nodeLocalDns is disabled)"}
+	}
+	return []string{
+		fmt.Sprintf("write %s forwarding cluster names to %s", nodeLocalDNSCorefile, i.config.Node.Kubelet.DNSServiceIP),
+		fmt.Sprintf("write static pod %s listening on %s", nodeLocalDNSManifestPath, i.config.NodeLocalDNS.LocalIP),
+	}
+}
+
+// Execute writes the cache configuration and its static pod manifest. When the cache is disabled a manifest
+// left by an earlier bootstrap is removed, as kubelet no longer points pods at it.
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.NodeLocalDNS.Enabled {
+		return NewUnInstaller(i.logger).Execute(ctx)
+	}
+	i.logger.Infof("Deploying node-local DNS cache on %s", i.config.NodeLocalDNS.LocalIP)
+
+	if err := sysutil.RunSystemCommand("mkdir", "-p", nodeLocalDNSConfigDir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", nodeLocalDNSConfigDir, err)
+	}
+	// The Corefile goes first, so the pod never starts without its configuration
+	if err := fsutil.WriteFileAtomicSystem(nodeLocalDNSCorefile, []byte(i.renderCorefile()), 0o644); err != nil {
+		return fmt.Errorf("failed to write node-local DNS Corefile: %w", err)
+	}
+	if err := sysutil.RunSystemCommand("mkdir", "-p", "/etc/kubernetes/manifests"); err != nil {
+		return fmt.Errorf("failed to create static pod manifest directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomicSystem(nodeLocalDNSManifestPath, []byte(i.renderManifest()), 0o644); err != nil {
+		return fmt.Errorf("failed to write node-local DNS static pod manifest: %w", err)
+	}
+
+	i.logger.Infof("node-local DNS cache deployed, kubelet passes %s to pods as their DNS server", i.config.ClusterDNS())
+	return nil
+}
+
+func (i *Installer) renderCorefile() string {
+	return renderCorefile(i.config.NodeLocalDNS.LocalIP, i.config.Node.Kubelet.DNSServiceIP)
+}
+
+func (i *Installer) renderManifest() string {
+	return renderStaticPodManifest(i.config.NodeLocalDNS.Image, i.config.NodeLocalDNS.LocalIP)
+}
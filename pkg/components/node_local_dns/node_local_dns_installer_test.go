@@ -0,0 +1,16 @@
+package node_local_dns
+
+import (
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/internal/golden"
+)
+
+func TestRenderCorefile(t *testing.T) {
+	golden.Assert(t, "Corefile", renderCorefile("169.254.20.10", "10.0.0.10"))
+}
+
+func TestRenderStaticPodManifest(t *testing.T) {
+	manifest := renderStaticPodManifest("registry.k8s.io/dns/k8s-dns-node-cache:1.23.1", "169.254.20.10")
+	golden.Assert(t, "node-local-dns-static-pod", manifest)
+}
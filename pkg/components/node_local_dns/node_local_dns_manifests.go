@@ -0,0 +1,107 @@
+package node_local_dns
+
+import "fmt"
+
+// renderCorefile renders the Corefile of the cache. Cluster names and reverse lookups are forwarded to the
+// cluster DNS service, other names to the upstream servers of the node. Cached external answers are served
+// stale while the upstream servers cannot be reached, so pods keep resolving them through a WAN outage.
+func renderCorefile(localIP, dnsServiceIP string) string {
+	var zones string
+	for _, zone := range []string{"cluster.local", "in-addr.arpa", "ip6.arpa"} {
+		// Only one server block can serve the health check on its address
+		health := ""
+		if zone == "cluster.local" {
+			health = fmt.Sprintf("\n    health %s:%d", localIP, healthPort)
+		}
+		zones += fmt.Sprintf(`%[1]s:53 {
+    errors
+    cache {
+        success 9984 30
+        denial 9984 5
+    }
+    reload
+    loop
+    bind %[2]s
+    forward . %[3]s {
+        force_tcp
+    }
+    prometheus :%[4]d%[5]s
+}
+`, zone, localIP, dnsServiceIP, metricsPort, health)
+	}
+	return zones + fmt.Sprintf(`.:53 {
+    errors
+    cache {
+        success 9984 30
+        denial 9984 5
+        serve_stale
+    }
+    reload
+    loop
+    bind %s
+    forward . /etc/resolv.conf
+    prometheus :%d
+}
+`, localIP, metricsPort)
+}
+
+// renderStaticPodManifest renders the node-local-dns static pod. It runs on the host network with the DNS
+// configuration of the node, so it does not depend on the cluster DNS it caches.
+func renderStaticPodManifest(image, localIP string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Pod
+metadata:
+  name: node-local-dns
+  namespace: kube-system
+  labels:
+    k8s-app: node-local-dns
+    app.kubernetes.io/managed-by: aks-flex-node
+spec:
+  hostNetwork: true
+  dnsPolicy: Default
+  priorityClassName: system-node-critical
+  containers:
+  - name: node-cache
+    image: %[1]s
+    imagePullPolicy: IfNotPresent
+    args:
+    - -localip
+    - %[2]s
+    - -conf
+    - %[3]s
+    - -interfacename
+    - %[4]s
+    - -health-port
+    - "%[5]d"
+    resources:
+      requests:
+        cpu: 25m
+        memory: 5Mi
+    securityContext:
+      capabilities:
+        add:
+        - NET_ADMIN
+    livenessProbe:
+      httpGet:
+        host: %[2]s
+        path: /health
+        port: %[5]d
+      initialDelaySeconds: 60
+      timeoutSeconds: 5
+    volumeMounts:
+    - name: xtables-lock
+      mountPath: /run/xtables.lock
+    - name: config
+      mountPath: %[3]s
+      readOnly: true
+  volumes:
+  - name: xtables-lock
+    hostPath:
+      path: /run/xtables.lock
+      type: FileOrCreate
+  - name: config
+    hostPath:
+      path: %[6]s
+      type: File
+`, image, localIP, nodeLocalDNSMountedConf, nodeLocalDNSInterface, healthPort, nodeLocalDNSCorefile)
+}
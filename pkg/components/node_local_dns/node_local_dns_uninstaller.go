@@ -0,0 +1,60 @@
+package node_local_dns
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller removes the node-local DNS cache from this node
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new node-local DNS UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *UnInstaller) GetName() string {
+	return "NodeLocalDNS_UnInstaller"
+}
+
+// Execute removes the static pod manifest, the Corefile and the interface the cache created
+func (u *UnInstaller) Execute(_ context.Context) error {
+	u.logger.Info("Removing node-local DNS cache")
+
+	if err := fsutil.RunCleanupCommand(nodeLocalDNSManifestPath); err != nil {
+		u.logger.Debugf("Failed to remove node-local DNS manifest %s: %v (may not exist)", nodeLocalDNSManifestPath, err)
+	}
+	if errs := fsutil.RemoveDirectories([]string{nodeLocalDNSConfigDir}, u.logger); len(errs) > 0 {
+		u.logger.Debugf("Failed to remove node-local DNS config directory %s: %v", nodeLocalDNSConfigDir, errs)
+	}
+	// The cache removes its interface and iptables rules on a clean shutdown, which kubelet may not get to
+	// once the manifest is gone
+	if err := sysutil.RunSystemCommand("ip", "link", "delete", nodeLocalDNSInterface); err != nil {
+		u.logger.Debugf("Failed to delete interface %s: %v (may not exist)", nodeLocalDNSInterface, err)
+	}
+
+	u.logger.Info("node-local DNS cache removed successfully")
+	return nil
+}
+
+// IsCompleted returns true when neither the manifest nor the Corefile of the cache is present
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return !fsutil.FileExists(nodeLocalDNSManifestPath) && !fsutil.FileExists(nodeLocalDNSCorefile)
+}
+
+// Plan describes the node-local DNS removal for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{"remove " + nodeLocalDNSManifestPath, "remove " + nodeLocalDNSConfigDir, "delete interface " + nodeLocalDNSInterface}
+}
@@ -1,14 +1,50 @@
 package npd
 
+import (
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
 // NPD binary paths to check and manage
 const (
 	npdBinaryPath  = "/usr/bin/node-problem-detector"
-	npdConfigPath  = "/etc/node-problem-detector/kernel-monitor.json"
+	npdConfigDir   = "/etc/node-problem-detector"
+	npdServiceName = "node-problem-detector"
 	npdServicePath = "/etc/systemd/system/node-problem-detector.service"
-	tempDir        = "/tmp/npd"
+
+	// Custom plugin monitor surfacing agent-specific problems as node conditions
+	npdCustomPluginConfigPath  = "/etc/node-problem-detector/aks-flex-node-monitor.json"
+	customPluginSource         = "aks-flex-node-custom-plugin-monitor"
+	customPluginInvokeInterval = "5m"
+	customPluginTimeout        = "1m"
+	defaultAgentBinaryPath     = "/usr/local/bin/aks-flex-node"
+
+	// Report the kubelet client certificate once rotation has left it this close to expiry
+	certificateExpiryThreshold = 7 * 24 * time.Hour
+
+	// Component name used for provenance records
+	provenanceComponent = "node-problem-detector"
 )
 
-var (
-	npdFileName    = "npd-%s.tar.gz"
-	npdDownloadURL = "https://github.com/kubernetes/node-problem-detector/releases/download/%s/node-problem-detector-%s-linux_%s.tar.gz"
+var npdFileName = "npd-%s.tar.gz"
+
+// monitor is a monitor configuration shipped in the config directory of the NPD release
+type monitor struct {
+	file string // Configuration file name in the release and in npdConfigDir
+	flag string // NPD flag taking the monitor configurations of its kind
+}
+
+// Flags of the monitor kinds, each taking a comma-separated list of configuration files
+const (
+	systemLogMonitorFlag    = "--config.system-log-monitor"
+	systemStatsMonitorFlag  = "--config.system-stats-monitor"
+	customPluginMonitorFlag = "--config.custom-plugin-monitor"
 )
+
+// monitors are the release monitors npd.monitors selects, by name
+var monitors = map[string]monitor{
+	config.NPDMonitorKernel:      {file: "kernel-monitor.json", flag: systemLogMonitorFlag},
+	config.NPDMonitorSystemd:     {file: "systemd-monitor.json", flag: systemLogMonitorFlag},
+	config.NPDMonitorSystemStats: {file: "system-stats-monitor.json", flag: systemStatsMonitorFlag},
+}
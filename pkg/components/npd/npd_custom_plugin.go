@@ -0,0 +1,210 @@
+package npd
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// Exit codes understood by the NPD custom plugin monitor
+const (
+	CheckOK      = 0
+	CheckNonOK   = 1
+	CheckUnknown = 2
+)
+
+// Problems the agent reports to NPD, each backed by a node condition
+const (
+	ProblemBootstrap   = "bootstrap"
+	ProblemCertificate = "certificate"
+	ProblemArc         = "arc"
+)
+
+// CheckResult is the outcome of a problem check, printed to NPD as the condition message
+type CheckResult struct {
+	Status  int
+	Message string
+}
+
+// customPluginRule describes a node condition and how the agent checks it
+type customPluginRule struct {
+	problem       string
+	conditionType string
+	okReason      string
+	okMessage     string
+	problemReason string
+}
+
+var customPluginRules = []customPluginRule{
+	{
+		problem:       ProblemBootstrap,
+		conditionType: "FlexNodeBootstrapProblem",
+		okReason:      "FlexNodeBootstrapSucceeded",
+		okMessage:     "flex node bootstrap is healthy",
+		problemReason: "FlexNodeBootstrapFailing",
+	},
+	{
+		problem:       ProblemCertificate,
+		conditionType: "FlexNodeCertificateProblem",
+		okReason:      "KubeletClientCertificateValid",
+		okMessage:     "kubelet client certificate is valid",
+		problemReason: "KubeletClientCertificateExpiring",
+	},
+	{
+		problem:       ProblemArc,
+		conditionType: "FlexNodeArcProblem",
+		okReason:      "ArcAgentConnected",
+		okMessage:     "Azure Arc agent is connected",
+		problemReason: "ArcAgentDisconnected",
+	},
+}
+
+// RunCheck evaluates a single agent-specific problem for the NPD custom plugin monitor
+func RunCheck(ctx context.Context, problem string) CheckResult {
+	switch problem {
+	case ProblemBootstrap:
+		failure, err := status.LoadBootstrapFailure()
+		if err != nil {
+			return CheckResult{Status: CheckUnknown, Message: err.Error()}
+		}
+		return checkBootstrapFailure(failure)
+	case ProblemCertificate:
+		certData, err := os.ReadFile(kubelet.KubeletClientCertPath)
+		if err != nil {
+			return CheckResult{Status: CheckUnknown, Message: fmt.Sprintf("failed to read kubelet client certificate: %v", err)}
+		}
+		return checkCertificateExpiry(certData, time.Now(), certificateExpiryThreshold)
+	case ProblemArc:
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		arcStatus, err := status.NewCollector(nil, logger).CollectArcStatus(ctx)
+		if err != nil {
+			return CheckResult{Status: CheckUnknown, Message: err.Error()}
+		}
+		return checkArcStatus(arcStatus)
+	default:
+		return CheckResult{Status: CheckUnknown, Message: fmt.Sprintf("unknown problem %q", problem)}
+	}
+}
+
+func checkBootstrapFailure(failure *status.BootstrapFailure) CheckResult {
+	if failure == nil {
+		return CheckResult{Status: CheckOK, Message: "flex node bootstrap is healthy"}
+	}
+	message := fmt.Sprintf("bootstrap failed %d consecutive time(s) since %s: %s",
+		failure.ConsecutiveFailures, failure.FirstFailedAt.Format(time.RFC3339), failure.Error)
+	if failure.Class != "" {
+		message = fmt.Sprintf("%s bootstrap failure: %s", failure.Class, message)
+	}
+	return CheckResult{Status: CheckNonOK, Message: message}
+}
+
+func checkCertificateExpiry(certData []byte, now time.Time, threshold time.Duration) CheckResult {
+	// The kubelet client PEM bundles the certificate with its key, so look for the first certificate block
+	for block, rest := pem.Decode(certData); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return CheckResult{Status: CheckUnknown, Message: fmt.Sprintf("failed to parse kubelet client certificate: %v", err)}
+		}
+
+		remaining := cert.NotAfter.Sub(now)
+		expiry := cert.NotAfter.UTC().Format(time.RFC3339)
+		if remaining <= 0 {
+			return CheckResult{Status: CheckNonOK, Message: fmt.Sprintf("kubelet client certificate expired at %s", expiry)}
+		}
+		if remaining < threshold {
+			return CheckResult{Status: CheckNonOK, Message: fmt.Sprintf("kubelet client certificate expires at %s and has not been rotated", expiry)}
+		}
+		return CheckResult{Status: CheckOK, Message: fmt.Sprintf("kubelet client certificate is valid until %s", expiry)}
+	}
+	return CheckResult{Status: CheckUnknown, Message: "no certificate found in kubelet client certificate file"}
+}
+
+func checkArcStatus(arcStatus status.ArcStatus) CheckResult {
+	if !arcStatus.Connected {
+		return CheckResult{Status: CheckNonOK, Message: "Azure Arc agent is disconnected"}
+	}
+	return CheckResult{Status: CheckOK, Message: "Azure Arc agent is connected"}
+}
+
+// NPD custom plugin monitor configuration format
+type customPluginMonitorConfig struct {
+	Plugin           string                  `json:"plugin"`
+	PluginConfig     customPluginConfig      `json:"pluginConfig"`
+	Source           string                  `json:"source"`
+	MetricsReporting bool                    `json:"metricsReporting"`
+	Conditions       []customPluginCondition `json:"conditions"`
+	Rules            []customPluginRuleEntry `json:"rules"`
+}
+
+type customPluginConfig struct {
+	InvokeInterval  string `json:"invoke_interval"`
+	Timeout         string `json:"timeout"`
+	MaxOutputLength int    `json:"max_output_length"`
+	Concurrency     int    `json:"concurrency"`
+}
+
+type customPluginCondition struct {
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+type customPluginRuleEntry struct {
+	Type      string   `json:"type"`
+	Condition string   `json:"condition"`
+	Reason    string   `json:"reason"`
+	Path      string   `json:"path"`
+	Args      []string `json:"args"`
+	Timeout   string   `json:"timeout"`
+}
+
+// buildCustomPluginConfig renders the NPD custom plugin monitor config invoking the agent binary for each check.
+// The Arc check is only included when Arc registration is enabled.
+func buildCustomPluginConfig(agentBinary string, arcEnabled bool) ([]byte, error) {
+	monitorConfig := customPluginMonitorConfig{
+		Plugin: "custom",
+		PluginConfig: customPluginConfig{
+			InvokeInterval:  customPluginInvokeInterval,
+			Timeout:         customPluginTimeout,
+			MaxOutputLength: 256,
+			Concurrency:     1,
+		},
+		Source:           customPluginSource,
+		MetricsReporting: true,
+	}
+
+	for _, rule := range customPluginRules {
+		if rule.problem == ProblemArc && !arcEnabled {
+			continue
+		}
+		monitorConfig.Conditions = append(monitorConfig.Conditions, customPluginCondition{
+			Type:    rule.conditionType,
+			Reason:  rule.okReason,
+			Message: rule.okMessage,
+		})
+		monitorConfig.Rules = append(monitorConfig.Rules, customPluginRuleEntry{
+			Type:      "permanent",
+			Condition: rule.conditionType,
+			Reason:    rule.problemReason,
+			Path:      agentBinary,
+			Args:      []string{"npd-check", rule.problem},
+			Timeout:   customPluginTimeout,
+		})
+	}
+
+	return json.MarshalIndent(monitorConfig, "", "  ")
+}
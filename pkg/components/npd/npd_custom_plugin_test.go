@@ -0,0 +1,141 @@
+package npd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+func newTestCertificatePEM(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "system:node:test"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	// Mirror the kubelet client PEM layout which bundles the certificate and key
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...)
+}
+
+func TestCheckCertificateExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	threshold := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name       string
+		certData   []byte
+		wantStatus int
+	}{
+		{
+			name:       "valid certificate",
+			certData:   newTestCertificatePEM(t, now.Add(30*24*time.Hour)),
+			wantStatus: CheckOK,
+		},
+		{
+			name:       "certificate expiring within threshold",
+			certData:   newTestCertificatePEM(t, now.Add(2*24*time.Hour)),
+			wantStatus: CheckNonOK,
+		},
+		{
+			name:       "expired certificate",
+			certData:   newTestCertificatePEM(t, now.Add(-time.Hour)),
+			wantStatus: CheckNonOK,
+		},
+		{
+			name:       "no certificate",
+			certData:   []byte("not a pem file"),
+			wantStatus: CheckUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkCertificateExpiry(tt.certData, now, threshold)
+			if result.Status != tt.wantStatus {
+				t.Errorf("checkCertificateExpiry() status = %d, want %d (message: %s)", result.Status, tt.wantStatus, result.Message)
+			}
+		})
+	}
+}
+
+func TestCheckBootstrapFailure(t *testing.T) {
+	if result := checkBootstrapFailure(nil); result.Status != CheckOK {
+		t.Errorf("checkBootstrapFailure(nil) status = %d, want %d", result.Status, CheckOK)
+	}
+
+	failure := &status.BootstrapFailure{Error: "kubelet failed to start", ConsecutiveFailures: 3}
+	if result := checkBootstrapFailure(failure); result.Status != CheckNonOK {
+		t.Errorf("checkBootstrapFailure() status = %d, want %d", result.Status, CheckNonOK)
+	}
+}
+
+func TestBuildCustomPluginConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		arcEnabled     bool
+		wantConditions []string
+	}{
+		{
+			name:           "Arc enabled",
+			arcEnabled:     true,
+			wantConditions: []string{"FlexNodeBootstrapProblem", "FlexNodeCertificateProblem", "FlexNodeArcProblem"},
+		},
+		{
+			name:           "Arc disabled skips Arc check",
+			arcEnabled:     false,
+			wantConditions: []string{"FlexNodeBootstrapProblem", "FlexNodeCertificateProblem"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := buildCustomPluginConfig("/usr/local/bin/aks-flex-node", tt.arcEnabled)
+			if err != nil {
+				t.Fatalf("buildCustomPluginConfig() error = %v", err)
+			}
+
+			var monitorConfig customPluginMonitorConfig
+			if err := json.Unmarshal(data, &monitorConfig); err != nil {
+				t.Fatalf("failed to parse generated config: %v", err)
+			}
+			if monitorConfig.Plugin != "custom" {
+				t.Errorf("plugin = %q, want custom", monitorConfig.Plugin)
+			}
+			if len(monitorConfig.Rules) != len(tt.wantConditions) || len(monitorConfig.Conditions) != len(tt.wantConditions) {
+				t.Fatalf("got %d rules and %d conditions, want %d", len(monitorConfig.Rules), len(monitorConfig.Conditions), len(tt.wantConditions))
+			}
+			for idx, want := range tt.wantConditions {
+				rule := monitorConfig.Rules[idx]
+				if rule.Condition != want || monitorConfig.Conditions[idx].Type != want {
+					t.Errorf("rule %d condition = %q, want %q", idx, rule.Condition, want)
+				}
+				if rule.Path != "/usr/local/bin/aks-flex-node" || len(rule.Args) != 2 || rule.Args[0] != "npd-check" {
+					t.Errorf("rule %d invokes %s %v, want agent npd-check", idx, rule.Path, rule.Args)
+				}
+			}
+		})
+	}
+}
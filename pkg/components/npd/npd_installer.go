@@ -3,12 +3,16 @@ package npd
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -37,7 +41,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	}
 
 	// Install NPD
-	if err := i.installNpd(); err != nil {
+	if err := i.installNpd(ctx); err != nil {
 		return fmt.Errorf("NPD installation failed: %w", err)
 	}
 
@@ -50,14 +54,19 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) installNpd() error {
+func (i *Installer) installNpd(ctx context.Context) error {
 	// construct download URL
 	npdFileName, npdDownloadURL, err := i.getNpdDownloadURL()
 	if err != nil {
 		return fmt.Errorf("failed to construct NPD download URL: %w", err)
 	}
 
-	// Clean up any existing NPD files from /tmp directory to avoid conflicts
+	tempDir, err := utils.StagingPath(i.config.Paths.StagingDir, "npd")
+	if err != nil {
+		return err
+	}
+
+	// Clean up any existing NPD files from the staging directory to avoid conflicts
 	if err := utils.RunSystemCommand("bash", "-c", fmt.Sprintf("rm -rf %s", tempDir)); err != nil {
 		return fmt.Errorf("failed to clean up existing NPD temp directory %s: %w", tempDir, err)
 	}
@@ -75,18 +84,17 @@ func (i *Installer) installNpd() error {
 
 	i.logger.Debugf("Downloading NPD from %s to %s", npdDownloadURL, tempFile)
 
-	if err := utils.DownloadFile(npdDownloadURL, tempFile); err != nil {
+	if err := downloadcache.New(i.config, i.logger).FetchVerified(ctx, npdDownloadURL, tempFile, i.config.Npd.Checksum); err != nil {
 		return fmt.Errorf("failed to download NPD archive from %s: %w", npdDownloadURL, err)
 	}
 
 	// Extract NPD binary from tar.gz archive
 	i.logger.Info("Extracting NPD binary from archive")
-	if err := utils.RunSystemCommand("tar", "-xzf", tempFile, "-C", tempDir); err != nil {
+	if err := utils.RunSystemCommandContext(ctx, "tar", "-xzf", tempFile, "-C", tempDir); err != nil {
 		return fmt.Errorf("failed to extract NPD archive: %w", err)
 	}
 
 	tempNpdPath := filepath.Join(tempDir, "bin/node-problem-detector")
-	tempNpdConfig := filepath.Join(tempDir, "config/kernel-monitor.json")
 
 	// Verify extracted binary
 	if output, err := utils.RunCommandWithOutput("file", tempNpdPath); err != nil {
@@ -105,9 +113,17 @@ func (i *Installer) installNpd() error {
 		return fmt.Errorf("failed to install NPD to %s: %w", npdBinaryPath, err)
 	}
 
-	i.logger.Infof("Installing NPD configuration to %s", npdConfigPath)
-	if err := utils.RunSystemCommand("install", "-D", "-m", "0644", tempNpdConfig, npdConfigPath); err != nil {
-		return fmt.Errorf("failed to install NPD configuration to %s: %w", npdConfigPath, err)
+	for _, name := range i.config.Npd.Monitors {
+		configPath := monitorConfigPath(name)
+		i.logger.Infof("Installing NPD %s monitor configuration to %s", name, configPath)
+		tempConfig := filepath.Join(tempDir, "config", monitors[name].file)
+		if err := utils.RunSystemCommand("install", "-D", "-m", "0644", tempConfig, configPath); err != nil {
+			return fmt.Errorf("failed to install NPD %s monitor configuration to %s: %w", name, configPath, err)
+		}
+	}
+
+	if err := provenance.RecordInstall(provenanceComponent, i.getNpdVersion(), npdDownloadURL, tempFile); err != nil {
+		i.logger.Warnf("Failed to record NPD provenance: %v", err)
 	}
 
 	i.logger.Infof("Node Problem Detector version %s installed successfully", i.config.Npd.Version)
@@ -115,6 +131,11 @@ func (i *Installer) installNpd() error {
 }
 
 func (i *Installer) configure() error {
+	// Install the custom plugin monitor for agent-specific problems
+	if err := i.createCustomPluginConfig(); err != nil {
+		return err
+	}
+
 	// Create NPD systemd service
 	if err := i.createNpdServiceFile(); err != nil {
 		return err
@@ -124,9 +145,14 @@ func (i *Installer) configure() error {
 }
 
 func (i *Installer) createNpdServiceFile() error {
-	kubeConfigData, err := utils.RunCommandWithOutput("cat", kubelet.KubeletKubeconfigPath)
+	// The cert-based kubelet kubeconfig only exists after TLS bootstrap, so prefer the bootstrap kubeconfig for the server
+	kubeConfigPath := kubelet.KubeletBootstrapKubeconfigPath
+	if !utils.FileExists(kubeConfigPath) {
+		kubeConfigPath = kubelet.KubeletKubeconfigPath
+	}
+	kubeConfigData, err := utils.RunCommandWithOutput("cat", kubeConfigPath)
 	if err != nil {
-		return fmt.Errorf("failed to read kubelet kubeconfig file: %w", err)
+		return fmt.Errorf("failed to read kubelet kubeconfig file %s: %w", kubeConfigPath, err)
 	}
 
 	serverURL, _, err := utils.ExtractClusterInfo([]byte(kubeConfigData))
@@ -134,8 +160,8 @@ func (i *Installer) createNpdServiceFile() error {
 		return fmt.Errorf("failed to extract cluster info: %w", err)
 	}
 
-	cmd := fmt.Sprintf("%s --apiserver-override=\"%s?inClusterConfig=false&auth=%s\" --config.system-log-monitor=%s",
-		npdBinaryPath, serverURL, kubelet.KubeletKubeconfigPath, npdConfigPath)
+	cmd := fmt.Sprintf("%s --apiserver-override=\"%s?inClusterConfig=false&auth=%s\" %s",
+		npdBinaryPath, serverURL, kubelet.KubeletKubeconfigPath, monitorFlags(i.config.Npd.Monitors, i.config.Npd.CustomPluginConfigs))
 
 	npdService := `[Unit]
 Description=Node Problem Detector
@@ -159,6 +185,51 @@ WantedBy=multi-user.target
 	return nil
 }
 
+// createCustomPluginConfig writes the NPD custom plugin monitor config that runs the agent's problem checks
+func (i *Installer) createCustomPluginConfig() error {
+	agentBinary, err := os.Executable()
+	if err != nil {
+		i.logger.Warnf("Failed to determine agent binary path, using %s: %v", defaultAgentBinaryPath, err)
+		agentBinary = defaultAgentBinaryPath
+	}
+
+	monitorConfig, err := buildCustomPluginConfig(agentBinary, i.config.IsARCEnabled())
+	if err != nil {
+		return fmt.Errorf("failed to build NPD custom plugin config: %w", err)
+	}
+
+	if err := utils.WriteFileAtomicSystem(npdCustomPluginConfigPath, monitorConfig, 0644); err != nil {
+		return fmt.Errorf("failed to create NPD custom plugin config: %w", err)
+	}
+
+	i.logger.Infof("Created NPD custom plugin config at %s", npdCustomPluginConfigPath)
+	return nil
+}
+
+// monitorConfigPath returns where the configuration of a release monitor is installed
+func monitorConfigPath(name string) string {
+	return filepath.Join(npdConfigDir, monitors[name].file)
+}
+
+// monitorFlags renders the NPD flags running the selected release monitors, the flex node checks and the
+// custom plugin monitor configurations
+func monitorFlags(selected, customPluginConfigs []string) string {
+	configs := map[string][]string{
+		customPluginMonitorFlag: append([]string{npdCustomPluginConfigPath}, customPluginConfigs...),
+	}
+	for _, name := range selected {
+		configs[monitors[name].flag] = append(configs[monitors[name].flag], monitorConfigPath(name))
+	}
+
+	var flags []string
+	for _, flag := range []string{systemLogMonitorFlag, systemStatsMonitorFlag, customPluginMonitorFlag} {
+		if len(configs[flag]) > 0 {
+			flags = append(flags, flag+"="+strings.Join(configs[flag], ","))
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
 func (i *Installer) IsCompleted(ctx context.Context) bool {
 	// Check if NPD binary exists
 	if !utils.FileExists(npdBinaryPath) {
@@ -166,15 +237,65 @@ func (i *Installer) IsCompleted(ctx context.Context) bool {
 	}
 
 	// Verify it's the correct version and functional
-	return i.isNpdVersionCorrect()
+	if !i.isNpdVersionCorrect() {
+		return false
+	}
+
+	// Changed monitors are installed again, as is an NPD that stopped or keeps crashing
+	if !i.isServiceConfigured() {
+		return false
+	}
+	if !utils.IsServiceActive(npdServiceName) {
+		i.logger.Debugf("%s is not running", npdServiceName)
+		return false
+	}
+	return true
+}
+
+// isServiceConfigured checks the NPD service runs the configured monitors and their configurations are installed
+func (i *Installer) isServiceConfigured() bool {
+	unit, err := os.ReadFile(npdServicePath)
+	if err != nil || !strings.Contains(string(unit), monitorFlags(i.config.Npd.Monitors, i.config.Npd.CustomPluginConfigs)) {
+		i.logger.Debugf("%s does not run the configured NPD monitors", npdServicePath)
+		return false
+	}
+	for _, name := range i.config.Npd.Monitors {
+		if !utils.FileExists(monitorConfigPath(name)) {
+			i.logger.Debugf("NPD %s monitor configuration %s is missing", name, monitorConfigPath(name))
+			return false
+		}
+	}
+	return utils.FileExists(npdCustomPluginConfigPath)
 }
 
 // Validate validates prerequisites before installing NPD
 func (i *Installer) Validate(ctx context.Context) error {
-
+	for _, path := range i.config.Npd.CustomPluginConfigs {
+		if !utils.FileExists(path) {
+			return fmt.Errorf("NPD custom plugin monitor configuration %s from npd.customPluginConfigs does not exist", path)
+		}
+	}
 	return nil
 }
 
+// Plan describes the NPD installation for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	_, url, err := i.getNpdDownloadURL()
+	if err != nil {
+		url = "the NPD release"
+	}
+	configs := []string{npdCustomPluginConfigPath, npdServicePath}
+	for _, name := range i.config.Npd.Monitors {
+		configs = append(configs, monitorConfigPath(name))
+	}
+	return []string{
+		"stop node-problem-detector",
+		fmt.Sprintf("download Node Problem Detector %s from %s", i.getNpdVersion(), url),
+		"replace " + npdBinaryPath,
+		"write " + strings.Join(configs, ", "),
+	}
+}
+
 // isNpdVersionCorrect checks if the installed NPD version matches the expected version
 func (i *Installer) isNpdVersionCorrect() bool {
 	output, err := utils.RunCommandWithOutput(npdBinaryPath, "--version")
@@ -196,6 +317,13 @@ func (i *Installer) isNpdVersionCorrect() bool {
 func (i *Installer) cleanupExistingInstallation() error {
 	i.logger.Debugf("Removing existing NPD binary at %s", npdBinaryPath)
 
+	// Stop the service first, so systemd does not restart NPD while its files are replaced
+	if utils.ServiceExists(npdServiceName) {
+		if err := utils.StopService(npdServiceName); err != nil {
+			i.logger.Debugf("Failed to stop %s: %v", npdServiceName, err)
+		}
+	}
+
 	// Try to stop any processes that might be using NPD (best effort)
 	if err := utils.RunSystemCommand("pkill", "-f", "node-problem-detector"); err != nil {
 		i.logger.Debugf("No NPD processes found to kill (or pkill failed): %v", err)
@@ -206,9 +334,15 @@ func (i *Installer) cleanupExistingInstallation() error {
 		return fmt.Errorf("failed to remove existing NPD binary at %s: %w", npdBinaryPath, err)
 	}
 
-	// Remove the configuration
-	if err := utils.RunCleanupCommand(npdConfigPath); err != nil {
-		return fmt.Errorf("failed to remove existing NPD configuration at %s: %w", npdConfigPath, err)
+	// Remove the configuration of every release monitor, so deselected monitors are gone
+	for name := range monitors {
+		if err := utils.RunCleanupCommand(monitorConfigPath(name)); err != nil {
+			return fmt.Errorf("failed to remove existing NPD configuration at %s: %w", monitorConfigPath(name), err)
+		}
+	}
+
+	if err := utils.RunCleanupCommand(npdCustomPluginConfigPath); err != nil {
+		return fmt.Errorf("failed to remove existing NPD custom plugin configuration at %s: %w", npdCustomPluginConfigPath, err)
 	}
 
 	i.logger.Debugf("Successfully cleaned up existing NPD installation")
@@ -222,7 +356,7 @@ func (i *Installer) getNpdDownloadURL() (string, string, error) {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
 	// Construct the download URL based on the version
-	downloadURL := fmt.Sprintf(npdDownloadURL, npdVersion, npdVersion, arch)
+	downloadURL := fmt.Sprintf(defaults.Get().URLs.NPD, npdVersion, npdVersion, arch)
 	fileName := fmt.Sprintf(npdFileName, npdVersion)
 
 	return fileName, downloadURL, nil
@@ -230,7 +364,7 @@ func (i *Installer) getNpdDownloadURL() (string, string, error) {
 
 func (i *Installer) getNpdVersion() string {
 	if i.config.Npd.Version == "" {
-		return "v1.31.1" // default version
+		return defaults.Get().Versions.NPD
 	}
 	return i.config.Npd.Version
 }
@@ -0,0 +1,44 @@
+package npd
+
+import (
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestMonitorFlags(t *testing.T) {
+	tests := []struct {
+		name                string
+		monitors            []string
+		customPluginConfigs []string
+		want                string
+	}{
+		{
+			name:     "kernel monitor",
+			monitors: []string{config.NPDMonitorKernel},
+			want: "--config.system-log-monitor=/etc/node-problem-detector/kernel-monitor.json " +
+				"--config.custom-plugin-monitor=/etc/node-problem-detector/aks-flex-node-monitor.json",
+		},
+		{
+			name:                "all monitors and a custom plugin",
+			monitors:            []string{config.NPDMonitorSystemStats, config.NPDMonitorKernel, config.NPDMonitorSystemd},
+			customPluginConfigs: []string{"/etc/site/ntp-monitor.json"},
+			want: "--config.system-log-monitor=/etc/node-problem-detector/kernel-monitor.json,/etc/node-problem-detector/systemd-monitor.json " +
+				"--config.system-stats-monitor=/etc/node-problem-detector/system-stats-monitor.json " +
+				"--config.custom-plugin-monitor=/etc/node-problem-detector/aks-flex-node-monitor.json,/etc/site/ntp-monitor.json",
+		},
+		{
+			name:     "only the flex node checks",
+			monitors: []string{},
+			want:     "--config.custom-plugin-monitor=/etc/node-problem-detector/aks-flex-node-monitor.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := monitorFlags(tt.monitors, tt.customPluginConfigs); got != tt.want {
+				t.Errorf("monitorFlags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package npd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -27,13 +29,39 @@ func (nu *UnInstaller) GetName() string {
 func (nu *UnInstaller) Execute(ctx context.Context) error {
 	nu.logger.Info("Uninstalling Node Problem Detector")
 
+	if utils.ServiceExists(npdServiceName) {
+		if err := utils.StopService(npdServiceName); err != nil {
+			nu.logger.Warnf("Failed to stop %s: %v", npdServiceName, err)
+		}
+		if err := utils.DisableService(npdServiceName); err != nil {
+			nu.logger.Warnf("Failed to disable %s: %v", npdServiceName, err)
+		}
+	}
+
 	// Remove npd binary
 	if err := utils.RunCleanupCommand(npdBinaryPath); err != nil {
 		nu.logger.Debugf("Failed to remove binary %s: %v (may not exist)", npdBinaryPath, err)
 	}
 
-	if err := utils.RunCleanupCommand(npdConfigPath); err != nil {
-		nu.logger.Debugf("Failed to remove config %s: %v (may not exist)", npdConfigPath, err)
+	for name := range monitors {
+		if err := utils.RunCleanupCommand(monitorConfigPath(name)); err != nil {
+			nu.logger.Debugf("Failed to remove config %s: %v (may not exist)", monitorConfigPath(name), err)
+		}
+	}
+
+	if err := utils.RunCleanupCommand(npdCustomPluginConfigPath); err != nil {
+		nu.logger.Debugf("Failed to remove custom plugin config %s: %v (may not exist)", npdCustomPluginConfigPath, err)
+	}
+
+	if err := utils.RunCleanupCommand(npdServicePath); err != nil {
+		nu.logger.Debugf("Failed to remove service file %s: %v (may not exist)", npdServicePath, err)
+	}
+	if err := utils.ReloadSystemd(); err != nil {
+		nu.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+
+	if err := provenance.Remove(provenanceComponent); err != nil {
+		nu.logger.Debugf("Failed to remove NPD provenance record: %v", err)
 	}
 
 	nu.logger.Info("Node Problem Detector uninstalled successfully")
@@ -42,8 +70,16 @@ func (nu *UnInstaller) Execute(ctx context.Context) error {
 
 func (nu *UnInstaller) IsCompleted(ctx context.Context) bool {
 	// Check if NPD is uninstalled
-	if !utils.FileExists(npdBinaryPath) && !utils.FileExists(npdConfigPath) {
+	if !utils.FileExists(npdBinaryPath) && !utils.FileExists(npdServicePath) {
 		return true
 	}
 	return false
 }
+
+// Plan describes the NPD removal for dry runs
+func (nu *UnInstaller) Plan(ctx context.Context) []string {
+	return []string{
+		"stop and disable node-problem-detector",
+		fmt.Sprintf("remove %s, %s, %s and the monitor configurations in %s", npdBinaryPath, npdServicePath, npdCustomPluginConfigPath, npdConfigDir),
+	}
+}
@@ -7,6 +7,9 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -39,7 +42,7 @@ func (i *Installer) Execute(ctx context.Context) error {
 	}
 
 	// Install runc
-	if err := i.installRunc(); err != nil {
+	if err := i.installRunc(ctx); err != nil {
 		return fmt.Errorf("runc installation failed: %w", err)
 	}
 
@@ -47,18 +50,21 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-func (i *Installer) installRunc() error {
+func (i *Installer) installRunc(ctx context.Context) error {
 	// Construct download URL
 	runcFileName, runcDownloadURL, err := i.constructRuncDownloadURL()
 	if err != nil {
 		return fmt.Errorf("failed to construct runc download URL: %w", err)
 	}
 
-	tempFile := fmt.Sprintf("/tmp/%s", runcFileName)
+	tempFile, err := utils.StagingPath(i.config.Paths.StagingDir, runcFileName)
+	if err != nil {
+		return err
+	}
 
-	// Clean up any existing runc temp files from /tmp directory to avoid conflicts
+	// Clean up any existing runc temp files from the staging directory to avoid conflicts
 	if err := utils.RunSystemCommand("bash", "-c", fmt.Sprintf("rm -f %s", tempFile)); err != nil {
-		logrus.Warnf("Failed to clean up existing runc temp files from /tmp: %s", err)
+		logrus.Warnf("Failed to clean up existing runc temp files from the staging directory: %s", err)
 	}
 	defer func() {
 		if err := utils.RunCleanupCommand(tempFile); err != nil {
@@ -68,7 +74,7 @@ func (i *Installer) installRunc() error {
 
 	i.logger.Infof("Downloading runc from %s into %s", runcDownloadURL, tempFile)
 
-	if err := utils.DownloadFile(runcDownloadURL, tempFile); err != nil {
+	if err := downloadcache.New(i.config, i.logger).FetchVerified(ctx, runcDownloadURL, tempFile, i.config.Runc.Checksum); err != nil {
 		return fmt.Errorf("failed to download runc from %s: %w", runcDownloadURL, err)
 	}
 
@@ -77,6 +83,10 @@ func (i *Installer) installRunc() error {
 	if err := utils.RunSystemCommand("install", "-m", "0555", tempFile, runcBinaryPath); err != nil {
 		return fmt.Errorf("failed to install runc to %s: %w", runcBinaryPath, err)
 	}
+
+	if err := provenance.RecordInstall(provenanceComponent, i.getRuncVersion(), runcDownloadURL, tempFile); err != nil {
+		i.logger.Warnf("Failed to record runc provenance: %v", err)
+	}
 	return nil
 }
 
@@ -88,7 +98,7 @@ func (i *Installer) constructRuncDownloadURL() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get architecture: %w", err)
 	}
-	url := fmt.Sprintf(runcDownloadURL, runcVersion, arch)
+	url := fmt.Sprintf(defaults.Get().URLs.Runc, runcVersion, arch)
 	fileName := fmt.Sprintf(runcFileName, arch)
 	i.logger.Infof("Constructed runc download URL: %s", url)
 	return fileName, url, nil
@@ -110,6 +120,18 @@ func (i *Installer) Validate(ctx context.Context) error {
 	return nil
 }
 
+// Plan describes the runc installation for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	_, url, err := i.constructRuncDownloadURL()
+	if err != nil {
+		url = "the runc release"
+	}
+	return []string{
+		fmt.Sprintf("download runc %s from %s", i.getRuncVersion(), url),
+		"replace " + runcBinaryPath,
+	}
+}
+
 // isRuncVersionCorrect checks if the installed runc version matches the expected version
 func (i *Installer) isRuncVersionCorrect() bool {
 	output, err := utils.RunCommandWithOutput(runcBinaryPath, "--version")
@@ -147,7 +169,7 @@ func (i *Installer) cleanupExistingInstallation() error {
 
 func (i *Installer) getRuncVersion() string {
 	if i.config.Runc.Version == "" {
-		return "1.1.12" // default version
+		return defaults.Get().Versions.Runc
 	}
 	return i.config.Runc.Version
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -36,6 +37,10 @@ func (ru *UnInstaller) Execute(ctx context.Context) error {
 		ru.logger.Debugf("Failed to remove binary %s: %v (may not exist)", runcBinaryPath, err)
 	}
 
+	if err := provenance.Remove(provenanceComponent); err != nil {
+		ru.logger.Debugf("Failed to remove runc provenance record: %v", err)
+	}
+
 	ru.logger.Info("Runc uninstalled successfully")
 	return nil
 }
@@ -45,3 +50,8 @@ func (ru *UnInstaller) IsCompleted(ctx context.Context) bool {
 	_, err := utils.RunCommandWithOutput("which", "runc")
 	return err != nil // runc not found
 }
+
+// Plan describes the runc removal for dry runs
+func (ru *UnInstaller) Plan(ctx context.Context) []string {
+	return []string{"remove " + runcBinaryPath}
+}
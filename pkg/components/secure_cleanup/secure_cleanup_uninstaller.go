@@ -0,0 +1,128 @@
+package secure_cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_proxy"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kube_vip"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+)
+
+// UnInstaller shreds credential-bearing files before the component uninstallers remove their directories,
+// and verifies none of them remain
+type UnInstaller struct {
+	logger      *logrus.Logger
+	secretFiles func() ([]string, error)
+	lstat       func(path string) (fs.FileMode, error)
+	shred       func(path string) (bool, error)
+	details     []string
+}
+
+// NewUnInstaller creates a new secure cleanup unInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		logger:      logger,
+		secretFiles: secretFiles,
+		lstat:       fsutil.LstatSystem,
+		shred:       utils.ShredFile,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *UnInstaller) GetName() string {
+	return "SecureCleanup"
+}
+
+// Execute shreds every credential file that exists and fails if any of them may still be present afterwards:
+// files that remain, files that could not be checked and directories that could not be listed
+func (u *UnInstaller) Execute(_ context.Context) error {
+	u.logger.Info("Shredding credential files")
+	u.details = nil
+
+	files, listErr := u.secretFiles()
+	for _, path := range files {
+		mode, err := u.lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		// Symlinks such as kubelet-client-current.pem point at files shredded on their own
+		if err == nil && mode&fs.ModeSymlink != 0 {
+			if err := utils.RunCleanupCommand(path); err != nil {
+				u.logger.Warnf("Failed to remove symlink %s: %v", path, err)
+				continue
+			}
+			u.details = append(u.details, "removed symlink "+path)
+			continue
+		}
+
+		// A file that could not be checked is shredded anyway, shred runs through sudo
+		shredded, err := u.shred(path)
+		switch {
+		case err != nil:
+			u.logger.Warnf("Failed to shred %s: %v", path, err)
+		case shredded:
+			u.details = append(u.details, "shredded "+path)
+		default:
+			u.details = append(u.details, "removed "+path+" (shred not available)")
+		}
+	}
+
+	var remaining []string
+	for _, path := range files {
+		if _, err := u.lstat(path); !errors.Is(err, fs.ErrNotExist) {
+			if err != nil {
+				u.logger.Warnf("Failed to check %s: %v", path, err)
+			}
+			remaining = append(remaining, path)
+		}
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("credential files still present after cleanup or not checkable: %s", strings.Join(remaining, ", "))
+	}
+	if listErr != nil {
+		return fmt.Errorf("credential files may remain: %w", listErr)
+	}
+
+	u.details = append(u.details, fmt.Sprintf("verified %d credential file location(s) are clear", len(files)))
+	return nil
+}
+
+// IsCompleted always returns false so every unbootstrap report includes the verification
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Plan lists the credential files Execute would shred, for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	var actions []string
+	files, err := u.secretFiles()
+	if err != nil {
+		actions = append(actions, err.Error())
+	}
+	for _, path := range files {
+		if _, err := u.lstat(path); !errors.Is(err, fs.ErrNotExist) {
+			actions = append(actions, "shred "+path)
+		}
+	}
+	return actions
+}
+
+// Details returns the files handled by the last Execute, for the unbootstrap report
+func (u *UnInstaller) Details() []string {
+	return u.details
+}
+
+// secretFiles returns the credential-bearing files written by the agent's components
+func secretFiles() ([]string, error) {
+	files, err := kubelet.SecretFiles()
+	files = append(files, kube_proxy.SecretFiles()...)
+	return append(files, kube_vip.SecretFiles()...), err
+}
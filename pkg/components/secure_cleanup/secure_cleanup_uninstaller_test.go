@@ -0,0 +1,117 @@
+package secure_cleanup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+)
+
+func newTestUnInstaller(files []string, shred func(string) (bool, error)) *UnInstaller {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &UnInstaller{
+		logger:      logger,
+		secretFiles: func() ([]string, error) { return files, nil },
+		lstat:       fsutil.LstatSystem,
+		shred:       shred,
+	}
+}
+
+func TestSecureCleanupShredsAndVerifies(t *testing.T) {
+	dir := t.TempDir()
+	tokenScript := filepath.Join(dir, "token.sh")
+	clientCert := filepath.Join(dir, "kubelet-client-2025-01-01.pem")
+	currentCert := filepath.Join(dir, "kubelet-client-current.pem")
+	missing := filepath.Join(dir, "bootstrap-kubeconfig")
+	for _, path := range []string{tokenScript, clientCert} {
+		if err := os.WriteFile(path, []byte("secret"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(clientCert, currentCert); err != nil {
+		t.Fatal(err)
+	}
+
+	var shredded []string
+	u := newTestUnInstaller([]string{tokenScript, missing, currentCert, clientCert}, func(path string) (bool, error) {
+		shredded = append(shredded, path)
+		return true, os.Remove(path)
+	})
+
+	if err := u.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if want := []string{tokenScript, clientCert}; !slices.Equal(shredded, want) {
+		t.Errorf("shredded files = %v, want %v", shredded, want)
+	}
+	details := u.Details()
+	if want := "removed symlink " + currentCert; !slices.Contains(details, want) {
+		t.Errorf("Details() = %v, want entry %q", details, want)
+	}
+	if last := details[len(details)-1]; !strings.HasPrefix(last, "verified 4 credential file location(s)") {
+		t.Errorf("Details() last entry = %q, want verification summary", last)
+	}
+}
+
+func TestSecureCleanupFailsWhenFilesRemain(t *testing.T) {
+	tokenScript := filepath.Join(t.TempDir(), "token.sh")
+	if err := os.WriteFile(tokenScript, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newTestUnInstaller([]string{tokenScript}, func(string) (bool, error) {
+		return false, errors.New("permission denied")
+	})
+
+	err := u.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), tokenScript) {
+		t.Errorf("Execute() error = %v, want error naming %s", err, tokenScript)
+	}
+}
+
+func TestSecureCleanupFailsWhenFilesCannotBeChecked(t *testing.T) {
+	clientKey := "/var/lib/kubelet/pki/kubelet-client.key"
+	var shredded []string
+	u := newTestUnInstaller([]string{clientKey}, func(path string) (bool, error) {
+		shredded = append(shredded, path)
+		return true, nil
+	})
+	u.lstat = func(string) (fs.FileMode, error) {
+		return 0, errors.New("sudo: a password is required")
+	}
+
+	err := u.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), clientKey) {
+		t.Errorf("Execute() error = %v, want error naming %s", err, clientKey)
+	}
+	if !slices.Equal(shredded, []string{clientKey}) {
+		t.Errorf("shredded files = %v, want the file that could not be checked shredded anyway", shredded)
+	}
+}
+
+func TestSecureCleanupFailsWhenDirectoryCannotBeListed(t *testing.T) {
+	u := newTestUnInstaller(nil, func(string) (bool, error) { return true, nil })
+	u.secretFiles = func() ([]string, error) {
+		return nil, errors.New("failed to list the kubelet certificates and keys: permission denied")
+	}
+
+	err := u.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "kubelet certificates") {
+		t.Errorf("Execute() error = %v, want the listing failure", err)
+	}
+	for _, detail := range u.Details() {
+		if strings.HasPrefix(detail, "verified") {
+			t.Errorf("Details() = %v, want no verification summary", u.Details())
+		}
+	}
+}
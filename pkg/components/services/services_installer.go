@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/egress"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -56,16 +58,37 @@ func (i *Installer) Execute(ctx context.Context) error {
 
 	// Wait for kubelet to start and validate it's running properly
 	i.logger.Info("Waiting for kubelet to start...")
-	if err := utils.WaitForService("kubelet", 30*time.Second, i.logger); err != nil {
+	if err := utils.WaitForService(ctx, "kubelet", 30*time.Second, i.logger); err != nil {
 		return fmt.Errorf("kubelet failed to start properly: %w", err)
 	}
 
+	// Initial node annotations can only be applied once kubelet has registered the node
+	if err := kubelet.ApplyNodeAnnotations(ctx, i.config, 2*time.Minute, i.logger); err != nil {
+		return fmt.Errorf("failed to apply node annotations: %w", err)
+	}
+
+	// Record the egress IP and warn when too many nodes share it for the kubelet API client rate limits
+	egress.Check(ctx, i.config, i.logger)
+
+	// Bootstrap credentials are only needed until kubelet has its client certificate
+	if i.config.Node.Kubelet.RemoveBootstrapKubeconfig {
+		if err := kubelet.RemoveBootstrapCredentials(ctx, 2*time.Minute, i.logger); err != nil {
+			i.logger.Warnf("Failed to remove kubelet bootstrap credentials: %v", err)
+		}
+	}
+
 	i.logger.Info("Enabling and starting node-problem-detector service")
 	if err := utils.EnableAndStartService("node-problem-detector"); err != nil {
 		i.logger.Errorf("Failed to enable and start node-problem-detector: %v", err)
 		return fmt.Errorf("failed to enable and start node-problem-detector: %w", err)
 	}
 
+	// Restart node-problem-detector to pick up changed monitors
+	if err := utils.RestartService("node-problem-detector"); err != nil {
+		i.logger.Errorf("Failed to restart node-problem-detector: %v", err)
+		return fmt.Errorf("failed to restart node-problem-detector: %w", err)
+	}
+
 	i.logger.Info("All services enabled and started successfully")
 	return nil
 }
@@ -83,6 +106,20 @@ func (i *Installer) Validate(ctx context.Context) error {
 	return nil
 }
 
+// Plan describes the services started for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	actions := []string{
+		"reload systemd",
+		"enable and restart containerd",
+		"enable and start kubelet and wait for it to run",
+		"apply the initial node annotations",
+	}
+	if i.config.Node.Kubelet.RemoveBootstrapKubeconfig {
+		actions = append(actions, "remove the kubelet bootstrap credentials once kubelet has its client certificate")
+	}
+	return append(actions, "enable and restart node-problem-detector")
+}
+
 // GetName returns the step name
 func (i *Installer) GetName() string {
 	return "ServicesEnabled"
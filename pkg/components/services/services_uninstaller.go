@@ -4,14 +4,16 @@ import (
 	"context"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
 // UnInstaller handles stopping and disabling system services
 type UnInstaller struct {
-	config *config.Config
-	logger *logrus.Logger
+	config                 *config.Config
+	logger                 *logrus.Logger
+	preserveAdoptedKubelet bool
 }
 
 // NewUnInstaller creates a new services unInstaller
@@ -22,6 +24,16 @@ func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
 	}
 }
 
+// NewPreBootstrapUnInstaller creates a services unInstaller used to stop services before bootstrap.
+// Unlike a regular unInstaller it leaves an adopted kubelet running.
+func NewPreBootstrapUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config:                 config.GetConfig(),
+		logger:                 logger,
+		preserveAdoptedKubelet: true,
+	}
+}
+
 // GetName returns the cleanup step name
 func (su *UnInstaller) GetName() string {
 	return "ServicesDisabled"
@@ -29,6 +41,11 @@ func (su *UnInstaller) GetName() string {
 
 // Execute stops and disables services
 func (su *UnInstaller) Execute(ctx context.Context) error {
+	if su.preserveAdoptedKubelet && kubelet.IsAdopted() {
+		su.logger.Info("Kubelet was adopted from an existing installation, leaving services running")
+		return nil
+	}
+
 	su.logger.Info("Stopping and disabling services")
 
 	// Stop and disable kubelet
@@ -42,8 +59,10 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 		}
 	}
 
-	// Stop and disable containerd
-	if utils.ServiceExists("containerd") {
+	// Stop and disable containerd, unless workloads outside Kubernetes still use it
+	if su.config.IsContainerdShared() {
+		su.logger.Info("containerd is shared with workloads outside Kubernetes, leaving it running")
+	} else if utils.ServiceExists("containerd") {
 		su.logger.Info("Stopping and disabling containerd service")
 		if err := utils.StopService("containerd"); err != nil {
 			su.logger.Warnf("Failed to stop containerd: %v", err)
@@ -59,6 +78,23 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 
 // IsCompleted checks if services have been stopped and disabled
 func (su *UnInstaller) IsCompleted(ctx context.Context) bool {
+	if su.preserveAdoptedKubelet && kubelet.IsAdopted() {
+		return true
+	}
 	// Services are considered Executeed if they are not active
+	if su.config.IsContainerdShared() {
+		return !utils.IsServiceActive("kubelet")
+	}
 	return !utils.IsServiceActive("containerd") && !utils.IsServiceActive("kubelet")
 }
+
+// Plan describes the services stopped for dry runs
+func (su *UnInstaller) Plan(ctx context.Context) []string {
+	if su.preserveAdoptedKubelet && kubelet.IsAdopted() {
+		return nil
+	}
+	if su.config.IsContainerdShared() {
+		return []string{"stop and disable kubelet, leaving the shared containerd running"}
+	}
+	return []string{"stop and disable kubelet", "stop and disable containerd"}
+}
@@ -0,0 +1,22 @@
+package storage
+
+const (
+	fstabPath     = "/etc/fstab"
+	mountInfoPath = "/proc/self/mountinfo"
+
+	// Markers delimiting the fstab entries the agent manages, everything outside the block is left untouched
+	fstabBeginMarker = "# BEGIN aks-flex-node storage, do not edit"
+	fstabEndMarker   = "# END aks-flex-node storage"
+)
+
+// dataDir is a data directory placed on the data disk, and the service unit that must not start without it
+type dataDir struct {
+	name   string // Directory below the storage root
+	target string // Directory the data is bind mounted over
+	dropIn string // systemd drop-in making the service require the mount
+}
+
+var dataDirs = []dataDir{
+	{"containerd", "/var/lib/containerd", "/etc/systemd/system/containerd.service.d/10-aks-flex-node-storage.conf"},
+	{"kubelet", "/var/lib/kubelet", "/etc/systemd/system/kubelet.service.d/10-aks-flex-node-storage.conf"},
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// fstabEntries returns the fstab entries mounting the data disk and bind mounting the data directories from
+// root. deviceSpec is empty when the data lives on a directory of an already mounted filesystem.
+func fstabEntries(deviceSpec, filesystem, root string) []string {
+	var entries []string
+	if deviceSpec != "" {
+		// nofail keeps a missing disk from blocking the boot, the services then refuse to start without it
+		entries = append(entries, fmt.Sprintf("%s %s %s defaults,nofail 0 2", deviceSpec, root, filesystem))
+	}
+	for _, dir := range dataDirs {
+		entries = append(entries, fmt.Sprintf("%s %s none bind,x-systemd.requires-mounts-for=%s 0 0",
+			filepath.Join(root, dir.name), dir.target, root))
+	}
+	return entries
+}
+
+// renderFstab returns the fstab content with its managed block replaced by the given entries. The block is
+// appended at the end of the file and dropped entirely when there are no entries.
+func renderFstab(current string, entries []string) string {
+	var out strings.Builder
+	inBlock := false
+	for _, line := range strings.SplitAfter(current, "\n") {
+		switch strings.TrimSpace(line) {
+		case fstabBeginMarker:
+			inBlock = true
+			continue
+		case fstabEndMarker:
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			out.WriteString(line)
+		}
+	}
+	if len(entries) == 0 {
+		return out.String()
+	}
+	if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+	out.WriteString(fstabBeginMarker + "\n")
+	for _, entry := range entries {
+		out.WriteString(entry + "\n")
+	}
+	out.WriteString(fstabEndMarker + "\n")
+	return out.String()
+}
+
+// managedMountPoints returns the mount points of the managed fstab block, in the order they are mounted
+func managedMountPoints(fstab string) []string {
+	var mountPoints []string
+	inBlock := false
+	for _, line := range strings.Split(fstab, "\n") {
+		switch strings.TrimSpace(line) {
+		case fstabBeginMarker:
+			inBlock = true
+			continue
+		case fstabEndMarker:
+			inBlock = false
+			continue
+		}
+		if fields := strings.Fields(line); inBlock && len(fields) >= 2 {
+			mountPoints = append(mountPoints, fields[1])
+		}
+	}
+	return mountPoints
+}
+
+// parseMountPoints returns the mount points of /proc/self/mountinfo, whose fifth field is the mount point
+// with spaces and other special characters escaped in octal
+func parseMountPoints(mountInfo string) map[string]bool {
+	mountPoints := make(map[string]bool)
+	for _, line := range strings.Split(mountInfo, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 5 {
+			mountPoints[strings.ReplaceAll(fields[4], `\040`, " ")] = true
+		}
+	}
+	return mountPoints
+}
+
+// renderDropIn renders the systemd drop-in keeping a service from starting before its data directory is mounted
+func renderDropIn(target string) string {
+	return fmt.Sprintf(`# Data directory on the storage of the aks-flex-node configuration
+[Unit]
+RequiresMountsFor=%s
+`, target)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFstabEntries(t *testing.T) {
+	entries := fstabEntries("UUID=1234", "xfs", "/mnt/data")
+	want := []string{
+		"UUID=1234 /mnt/data xfs defaults,nofail 0 2",
+		"/mnt/data/containerd /var/lib/containerd none bind,x-systemd.requires-mounts-for=/mnt/data 0 0",
+		"/mnt/data/kubelet /var/lib/kubelet none bind,x-systemd.requires-mounts-for=/mnt/data 0 0",
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("fstabEntries() = %q, want %q", entries, want)
+	}
+
+	// A directory of a mounted filesystem only needs the bind mounts
+	if entries := fstabEntries("", "", "/srv/flex"); len(entries) != 2 || !strings.HasPrefix(entries[0], "/srv/flex/containerd ") {
+		t.Errorf("fstabEntries() without a device = %q, want the two bind mounts", entries)
+	}
+}
+
+func TestRenderFstab(t *testing.T) {
+	current := "UUID=root / ext4 defaults 0 1\n/swapfile none swap sw 0 0"
+	entries := fstabEntries("UUID=1234", "ext4", "/mnt/data")
+
+	rendered := renderFstab(current, entries)
+	if !strings.HasPrefix(rendered, current+"\n"+fstabBeginMarker+"\n") {
+		t.Errorf("renderFstab() did not keep the existing entries ahead of the block:\n%s", rendered)
+	}
+	if again := renderFstab(rendered, entries); again != rendered {
+		t.Errorf("renderFstab() is not idempotent:\n%s\nvs\n%s", again, rendered)
+	}
+	if got := managedMountPoints(rendered); !slices.Equal(got, []string{"/mnt/data", "/var/lib/containerd", "/var/lib/kubelet"}) {
+		t.Errorf("managedMountPoints() = %q", got)
+	}
+
+	// Changed entries replace the block instead of adding a second one
+	changed := renderFstab(rendered, fstabEntries("UUID=5678", "ext4", "/mnt/data"))
+	if strings.Count(changed, fstabBeginMarker) != 1 || strings.Contains(changed, "UUID=1234") {
+		t.Errorf("renderFstab() did not replace the block:\n%s", changed)
+	}
+
+	removed := renderFstab(rendered, nil)
+	if removed != current+"\n" {
+		t.Errorf("renderFstab() without entries = %q, want the original entries", removed)
+	}
+	if got := managedMountPoints(removed); len(got) != 0 {
+		t.Errorf("managedMountPoints() after removal = %q, want none", got)
+	}
+}
+
+func TestParseMountPoints(t *testing.T) {
+	mountInfo := `22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+98 22 259:3 / /mnt/data rw,relatime shared:45 - ext4 /dev/nvme1n1 rw
+99 22 259:3 /kubelet /var/lib/kubelet rw,relatime shared:45 - ext4 /dev/nvme1n1 rw
+100 22 0:52 / /mnt/my\040disk rw,relatime shared:46 - tmpfs tmpfs rw
+`
+	mountPoints := parseMountPoints(mountInfo)
+	for _, path := range []string{"/", "/mnt/data", "/var/lib/kubelet", "/mnt/my disk"} {
+		if !mountPoints[path] {
+			t.Errorf("parseMountPoints() is missing %s", path)
+		}
+	}
+	if mountPoints["/var/lib/containerd"] {
+		t.Error("parseMountPoints() reports /var/lib/containerd, which is not mounted")
+	}
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Installer places the containerd and kubelet data directories on a data disk or a directory of another
+// filesystem. The directories are bind mounted, so containerd and kubelet keep their default paths.
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new storage Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "Storage_Installer"
+}
+
+// Validate checks the device or directory exists, the device has a filesystem or may be formatted, and the data
+// directories do not already hold data on the root disk
+func (i *Installer) Validate(_ context.Context) error {
+	if !i.config.IsStorageConfigured() {
+		return nil
+	}
+	storage := i.config.Storage
+
+	if storage.Device != "" {
+		if !fsutil.FileExists(storage.Device) {
+			return fmt.Errorf("storage.device %s not found", storage.Device)
+		}
+		if filesystemType(storage.Device) == "" {
+			if partitions := blkidValue(storage.Device, "PTTYPE"); partitions != "" {
+				return fmt.Errorf("storage.device %s has a %s partition table, set a partition of it", storage.Device, partitions)
+			}
+			if !storage.Format {
+				return fmt.Errorf("storage.device %s has no filesystem, set storage.format to create a %s filesystem on it",
+					storage.Device, storage.Filesystem)
+			}
+		}
+	} else if !fsutil.DirectoryExists(storage.Path) {
+		return fmt.Errorf("storage.path %s does not exist", storage.Path)
+	}
+
+	// Bind mounting over existing data would hide it, and containerd and kubelet would start over
+	root := i.config.StorageRoot()
+	for _, dir := range dataDirs {
+		if isBound(dir.target, filepath.Join(root, dir.name)) {
+			continue
+		}
+		if entries, err := os.ReadDir(dir.target); err == nil && len(entries) > 0 {
+			return fmt.Errorf("%s already holds data on the root disk, run unbootstrap before moving it to %s", dir.target, root)
+		}
+	}
+	return nil
+}
+
+// IsCompleted returns true when the data disk is mounted, the data directories are bind mounted from it and the
+// mounts are in /etc/fstab
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	if !i.config.IsStorageConfigured() {
+		return true
+	}
+	root := i.config.StorageRoot()
+	if i.config.Storage.Device != "" && !isMounted(root) {
+		return false
+	}
+	for _, dir := range dataDirs {
+		if !isBound(dir.target, filepath.Join(root, dir.name)) {
+			return false
+		}
+		existing, err := os.ReadFile(dir.dropIn)
+		if err != nil || string(existing) != renderDropIn(dir.target) {
+			return false
+		}
+	}
+	fstab, err := os.ReadFile(fstabPath)
+	if err != nil {
+		return false
+	}
+	entries, err := i.fstabEntries()
+	return err == nil && renderFstab(string(fstab), entries) == string(fstab)
+}
+
+// Plan describes the data disk setup for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	if !i.config.IsStorageConfigured() {
+		return nil
+	}
+	storage := i.config.Storage
+	root := i.config.StorageRoot()
+	var actions []string
+	if storage.Device != "" {
+		if storage.Format && filesystemType(storage.Device) == "" {
+			actions = append(actions, fmt.Sprintf("create a %s filesystem on %s", storage.Filesystem, storage.Device))
+		}
+		actions = append(actions, fmt.Sprintf("mount %s at %s", storage.Device, root))
+	}
+	for _, dir := range dataDirs {
+		actions = append(actions, fmt.Sprintf("bind mount %s over %s", filepath.Join(root, dir.name), dir.target))
+	}
+	return append(actions,
+		"add the mounts to "+fstabPath,
+		"make containerd and kubelet require their data directory mounts")
+}
+
+// Execute formats and mounts the data disk when one is configured, bind mounts the data directories, persists
+// the mounts in /etc/fstab and keeps containerd and kubelet from starting without them
+func (i *Installer) Execute(_ context.Context) error {
+	if !i.config.IsStorageConfigured() {
+		return nil
+	}
+	root := i.config.StorageRoot()
+
+	if err := i.mountDevice(); err != nil {
+		return err
+	}
+
+	for _, dir := range dataDirs {
+		source := filepath.Join(root, dir.name)
+		if err := sysutil.RunSystemCommand("mkdir", "-p", source, dir.target); err != nil {
+			return fmt.Errorf("failed to create %s and %s: %w", source, dir.target, err)
+		}
+		if !isBound(dir.target, source) {
+			if err := sysutil.RunSystemCommand("mount", "--bind", source, dir.target); err != nil {
+				return fmt.Errorf("failed to bind mount %s over %s: %w", source, dir.target, err)
+			}
+			i.logger.Infof("Bind mounted %s over %s", source, dir.target)
+		}
+		if err := sysutil.RunSystemCommand("mkdir", "-p", filepath.Dir(dir.dropIn)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(dir.dropIn), err)
+		}
+		if err := fsutil.WriteFileAtomicSystem(dir.dropIn, []byte(renderDropIn(dir.target)), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dir.dropIn, err)
+		}
+	}
+
+	entries, err := i.fstabEntries()
+	if err != nil {
+		return err
+	}
+	fstab, err := os.ReadFile(fstabPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", fstabPath, err)
+	}
+	if updated := renderFstab(string(fstab), entries); updated != string(fstab) {
+		if err := fsutil.WriteFileAtomicSystem(fstabPath, []byte(updated), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", fstabPath, err)
+		}
+	}
+
+	// systemd generates mount units from /etc/fstab and reads the new drop-ins
+	if err := sysutil.ReloadSystemd(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+
+	i.logger.Infof("containerd and kubelet data is stored below %s", root)
+	return nil
+}
+
+// mountDevice creates a filesystem on the data disk when it has none and storage.format is set, then mounts it
+func (i *Installer) mountDevice() error {
+	storage := i.config.Storage
+	if storage.Device == "" {
+		return nil
+	}
+	fsType := filesystemType(storage.Device)
+	if fsType == "" {
+		i.logger.Infof("Creating a %s filesystem on %s", storage.Filesystem, storage.Device)
+		if err := sysutil.RunSystemCommand("mkfs."+storage.Filesystem, "-q", storage.Device); err != nil {
+			return fmt.Errorf("failed to create a %s filesystem on %s: %w", storage.Filesystem, storage.Device, err)
+		}
+		fsType = storage.Filesystem
+	}
+
+	if err := sysutil.RunSystemCommand("mkdir", "-p", storage.MountPath); err != nil {
+		return fmt.Errorf("failed to create %s: %w", storage.MountPath, err)
+	}
+	if isMounted(storage.MountPath) {
+		return nil
+	}
+	if err := sysutil.RunSystemCommand("mount", "-t", fsType, storage.Device, storage.MountPath); err != nil {
+		return fmt.Errorf("failed to mount %s at %s: %w", storage.Device, storage.MountPath, err)
+	}
+	i.logger.Infof("Mounted %s at %s", storage.Device, storage.MountPath)
+	return nil
+}
+
+// fstabEntries returns the fstab entries of the configured storage, referencing the data disk by the UUID of
+// its filesystem, as device names can change between boots
+func (i *Installer) fstabEntries() ([]string, error) {
+	storage := i.config.Storage
+	if storage.Device == "" {
+		return fstabEntries("", "", storage.Path), nil
+	}
+	fsType := filesystemType(storage.Device)
+	if fsType == "" {
+		return nil, fmt.Errorf("storage.device %s has no filesystem", storage.Device)
+	}
+	deviceSpec := storage.Device
+	if uuid := blkidValue(storage.Device, "UUID"); uuid != "" {
+		deviceSpec = "UUID=" + uuid
+	}
+	return fstabEntries(deviceSpec, fsType, storage.MountPath), nil
+}
+
+// filesystemType returns the filesystem on a device, empty when it has none
+func filesystemType(device string) string {
+	return blkidValue(device, "TYPE")
+}
+
+// blkidValue returns a tag blkid reports for a device, empty when the device does not have it
+func blkidValue(device, tag string) string {
+	output, err := sysutil.RunCommandWithOutput("blkid", "-p", "-s", tag, "-o", "value", device)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// isMounted returns true when a filesystem is mounted at path
+func isMounted(path string) bool {
+	mountInfo, err := os.ReadFile(mountInfoPath)
+	return err == nil && parseMountPoints(string(mountInfo))[filepath.Clean(path)]
+}
+
+// isBound returns true when target shows the directory source, as it does once source is bind mounted over it
+func isBound(target, source string) bool {
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		return false
+	}
+	sourceInfo, err := os.Stat(source)
+	return err == nil && os.SameFile(targetInfo, sourceInfo)
+}
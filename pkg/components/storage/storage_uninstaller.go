@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller unmounts the data directories and the data disk and removes their fstab entries. It runs after
+// containerd and kubelet are removed and leaves the filesystem of the data disk in place.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new storage UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *UnInstaller) GetName() string {
+	return "Storage_UnInstaller"
+}
+
+// Execute unmounts the mounts of the managed fstab block in reverse order, then removes the block and the
+// service drop-ins
+func (u *UnInstaller) Execute(_ context.Context) error {
+	fstab, err := os.ReadFile(fstabPath)
+	if err != nil && !os.IsNotExist(err) {
+		u.logger.Warnf("Failed to read %s: %v", fstabPath, err)
+	}
+
+	mountPoints := managedMountPoints(string(fstab))
+	slices.Reverse(mountPoints)
+	for _, mountPoint := range mountPoints {
+		if !isMounted(mountPoint) {
+			continue
+		}
+		if err := sysutil.RunSystemCommand("umount", mountPoint); err != nil {
+			u.logger.Warnf("Failed to unmount %s: %v (continuing)", mountPoint, err)
+			continue
+		}
+		u.logger.Infof("Unmounted %s", mountPoint)
+	}
+
+	if updated := renderFstab(string(fstab), nil); len(fstab) > 0 && updated != string(fstab) {
+		if err := fsutil.WriteFileAtomicSystem(fstabPath, []byte(updated), 0o644); err != nil {
+			u.logger.Warnf("Failed to remove the storage entries from %s: %v", fstabPath, err)
+		}
+	}
+	for _, dir := range dataDirs {
+		if err := fsutil.RunCleanupCommand(dir.dropIn); err != nil {
+			u.logger.Debugf("Failed to remove %s: %v (may not exist)", dir.dropIn, err)
+		}
+	}
+	if err := sysutil.ReloadSystemd(); err != nil {
+		u.logger.Warnf("Failed to reload systemd: %v", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when neither the managed fstab block nor a service drop-in is left
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	for _, dir := range dataDirs {
+		if fsutil.FileExists(dir.dropIn) {
+			return false
+		}
+	}
+	fstab, err := os.ReadFile(fstabPath)
+	return err != nil || len(managedMountPoints(string(fstab))) == 0
+}
+
+// Plan describes the storage cleanup for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{"unmount the data directories and the data disk", "remove the storage entries from " + fstabPath}
+}
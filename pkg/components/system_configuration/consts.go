@@ -1,11 +1,31 @@
 package system_configuration
 
+import (
+	"path/filepath"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
 const (
 	// System directories
-	sysctlDir = "/etc/sysctl.d"
+	sysctlDir      = "/etc/sysctl.d"
+	modulesLoadDir = "/etc/modules-load.d"
+	procSysDir     = "/proc/sys"
 
 	// Configuration file paths
 	sysctlConfigPath = "/etc/sysctl.d/999-sysctl-aks.conf"
+	modulesLoadPath  = "/etc/modules-load.d/aks-flex-node.conf"
 	resolvConfPath   = "/etc/resolv.conf"
-	resolvConfSource = "/run/systemd/resolve/resolv.conf"
+	fstabPath        = "/etc/fstab"
+	procSwapsPath    = "/proc/swaps"
+
+	// fstabDisabledPrefix marks the swap entries of /etc/fstab system.swap disable commented out
+	fstabDisabledPrefix = "# disabled by aks-flex-node: "
 )
+
+// requiredKernelModules are loaded on every node: overlay for containerd, br_netfilter for bridged pod
+// traffic and nf_conntrack for the connection tracking limit
+var requiredKernelModules = []string{"overlay", "br_netfilter", "nf_conntrack"}
+
+// backupPath records the host settings the agent replaced, so unbootstrap can restore them
+var backupPath = filepath.Join(config.AgentStateDir, "system-backup.json")
@@ -0,0 +1,69 @@
+package system_configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// systemBackup holds the host settings as they were before the agent first changed them
+type systemBackup struct {
+	Sysctls       map[string]string `json:"sysctls,omitempty"`       // Previous value of every kernel parameter the agent set
+	Fstab         string            `json:"fstab,omitempty"`         // /etc/fstab before its swap entries were commented out
+	SwapTurnedOff bool              `json:"swapTurnedOff,omitempty"` // Swap was active and turned off with swapoff
+}
+
+// hasSwapChanges returns true when the agent turned swap off or edited /etc/fstab
+func (b *systemBackup) hasSwapChanges() bool {
+	return b.Fstab != "" || b.SwapTurnedOff
+}
+
+// loadBackup reads the recorded host settings, an empty record when none was written yet
+func loadBackup() (*systemBackup, error) {
+	backup := &systemBackup{Sysctls: map[string]string{}}
+	data, err := os.ReadFile(backupPath)
+	if os.IsNotExist(err) {
+		return backup, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system settings backup: %w", err)
+	}
+	if err := json.Unmarshal(data, backup); err != nil {
+		return nil, fmt.Errorf("failed to parse system settings backup %s: %w", backupPath, err)
+	}
+	if backup.Sysctls == nil {
+		backup.Sysctls = map[string]string{}
+	}
+	return backup, nil
+}
+
+// saveBackup persists the recorded host settings
+func saveBackup(backup *systemBackup) error {
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal system settings backup: %w", err)
+	}
+	if err := sysutil.RunSystemCommand("mkdir", "-p", filepath.Dir(backupPath)); err != nil {
+		return fmt.Errorf("failed to create agent state directory: %w", err)
+	}
+	return fsutil.WriteFileAtomicSystem(backupPath, data, 0o600)
+}
+
+// readSysctl returns the current value of a kernel parameter
+func readSysctl(key string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(procSysDir, strings.ReplaceAll(key, ".", "/")))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeSysctl sets a kernel parameter
+func writeSysctl(key, value string) error {
+	return sysutil.RunSystemCommand("sysctl", "-w", key+"="+value)
+}
@@ -3,9 +3,13 @@ package system_configuration
 import (
 	"context"
 	"fmt"
+	"os"
+	"runtime"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/resolvconf"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -23,15 +27,31 @@ func NewInstaller(logger *logrus.Logger) *Installer {
 	}
 }
 
-// Execute configures system settings including sysctl and resolv.conf
+// Execute configures system settings including kernel modules, sysctl, swap and resolv.conf. The values
+// it replaces are recorded first, so unbootstrap can restore them.
 func (i *Installer) Execute(ctx context.Context) error {
 	i.logger.Info("Configuring system settings")
 
+	backup, err := loadBackup()
+	if err != nil {
+		return err
+	}
+
+	// Load kernel modules first, their parameters only exist once they are loaded
+	if err := i.configureKernelModules(); err != nil {
+		return fmt.Errorf("failed to configure kernel modules: %w", err)
+	}
+
 	// Configure sysctl settings
-	if err := i.configureSysctl(); err != nil {
+	if err := i.configureSysctl(backup); err != nil {
 		return fmt.Errorf("failed to configure sysctl settings: %w", err)
 	}
 
+	// Configure swap
+	if err := i.configureSwap(backup); err != nil {
+		return fmt.Errorf("failed to configure swap: %w", err)
+	}
+
 	// Configure resolv.conf
 	if err := i.configureResolvConf(); err != nil {
 		return fmt.Errorf("failed to configure resolv.conf: %w", err)
@@ -41,10 +61,38 @@ func (i *Installer) Execute(ctx context.Context) error {
 	return nil
 }
 
-// IsCompleted checks if system configuration has been applied
+// IsCompleted checks if the current system configuration has been applied
 func (i *Installer) IsCompleted(ctx context.Context) bool {
-	return utils.FileExists(sysctlConfigPath) &&
-		utils.FileExists(resolvConfPath)
+	if !utils.FileExists(resolvConfPath) {
+		return false
+	}
+	for path, want := range map[string]string{
+		sysctlConfigPath: renderSysctlConfig(sysctlSettings(i.config, runtime.NumCPU())),
+		modulesLoadPath:  renderModulesLoad(kernelModules(i.config)),
+	} {
+		existing, err := os.ReadFile(path)
+		if err != nil || string(existing) != want {
+			return false
+		}
+	}
+
+	backup, err := loadBackup()
+	if err != nil {
+		return false
+	}
+	if i.config.System.Swap != config.SwapPolicyDisable {
+		return !backup.hasSwapChanges()
+	}
+	swaps, err := os.ReadFile(procSwapsPath)
+	if err != nil || activeSwapDevices(string(swaps)) > 0 {
+		return false
+	}
+	fstab, err := os.ReadFile(fstabPath)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	_, changed := disableFstabSwap(string(fstab))
+	return !changed
 }
 
 // Validate validates the system configuration installation
@@ -52,38 +100,76 @@ func (i *Installer) Validate(ctx context.Context) error {
 	return nil
 }
 
-// configureSysctl creates and applies sysctl configuration for Kubernetes
-func (i *Installer) configureSysctl() error {
-	sysctlConfig := `# Kubernetes sysctl settings
-net.bridge.bridge-nf-call-iptables = 1
-net.bridge.bridge-nf-call-ip6tables = 1
-net.ipv4.ip_forward = 1
-vm.overcommit_memory = 1
-kernel.panic = 10
-kernel.panic_on_oops = 1
-# Disable swap permanently - required for kubelet
-vm.swappiness = 0`
-
-	// Create sysctl directory if it doesn't exist
-	if err := utils.RunSystemCommand("mkdir", "-p", sysctlDir); err != nil {
-		return fmt.Errorf("failed to create sysctl directory: %w", err)
+// Plan describes the system configuration for dry runs
+func (i *Installer) Plan(ctx context.Context) []string {
+	actions := []string{
+		fmt.Sprintf("load kernel modules %s and write %s", strings.Join(kernelModules(i.config), ", "), modulesLoadPath),
+		"record the current kernel parameters in " + backupPath,
+		"write " + sysctlConfigPath,
+		"apply sysctl settings",
+	}
+	if i.config.System.Swap == config.SwapPolicyDisable {
+		actions = append(actions, "turn swap off and comment out the swap entries of "+fstabPath)
 	}
+	return append(actions, "point "+resolvConfPath+" at the resolver the host DNS stack maintains")
+}
 
-	// Create temporary file with sysctl configuration
-	tempFile, err := utils.CreateTempFile("sysctl-aks-*.conf", []byte(sysctlConfig))
-	if err != nil {
-		return fmt.Errorf("failed to create temporary sysctl config file: %w", err)
+// configureKernelModules loads the kernel modules and has them loaded at boot
+func (i *Installer) configureKernelModules() error {
+	modules := kernelModules(i.config)
+	for _, module := range modules {
+		if err := utils.RunSystemCommand("modprobe", module); err != nil {
+			return fmt.Errorf("failed to load kernel module %s: %w", module, err)
+		}
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", modulesLoadDir); err != nil {
+		return fmt.Errorf("failed to create modules-load directory: %w", err)
 	}
-	defer utils.CleanupTempFile(tempFile.Name())
+	if err := utils.WriteFileAtomicSystem(modulesLoadPath, []byte(renderModulesLoad(modules)), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", modulesLoadPath, err)
+	}
+	return nil
+}
 
-	// Copy to final location
-	if err := utils.RunSystemCommand("cp", tempFile.Name(), sysctlConfigPath); err != nil {
-		return fmt.Errorf("failed to install sysctl config file: %w", err)
+// configureSysctl records the current value of the kernel parameters the agent sets for the first time,
+// restores the ones it no longer sets, then creates and applies the sysctl configuration for Kubernetes
+func (i *Installer) configureSysctl(backup *systemBackup) error {
+	settings := sysctlSettings(i.config, runtime.NumCPU())
+
+	managed := make(map[string]bool, len(settings))
+	for _, setting := range settings {
+		managed[setting.key] = true
+		if _, recorded := backup.Sysctls[setting.key]; recorded {
+			continue
+		}
+		previous, err := readSysctl(setting.key)
+		if err != nil {
+			i.logger.Debugf("Not recording kernel parameter %s: %v", setting.key, err)
+			continue
+		}
+		backup.Sysctls[setting.key] = previous
+	}
+	// Parameters removed from system.sysctls get their previous value back
+	for key, previous := range backup.Sysctls {
+		if managed[key] {
+			continue
+		}
+		if err := writeSysctl(key, previous); err != nil {
+			i.logger.Warnf("Failed to restore kernel parameter %s to %q: %v", key, previous, err)
+			continue
+		}
+		delete(backup.Sysctls, key)
+	}
+	if err := saveBackup(backup); err != nil {
+		return err
 	}
 
-	// Set proper permissions
-	if err := utils.RunSystemCommand("chmod", "644", sysctlConfigPath); err != nil {
-		return fmt.Errorf("failed to set sysctl config file permissions: %w", err)
+	// Create sysctl directory if it doesn't exist
+	if err := utils.RunSystemCommand("mkdir", "-p", sysctlDir); err != nil {
+		return fmt.Errorf("failed to create sysctl directory: %w", err)
+	}
+	if err := utils.WriteFileAtomicSystem(sysctlConfigPath, []byte(renderSysctlConfig(settings)), 0o644); err != nil {
+		return fmt.Errorf("failed to install sysctl config file: %w", err)
 	}
 
 	// Apply sysctl settings
@@ -95,17 +181,79 @@ vm.swappiness = 0`
 	return nil
 }
 
-// configureResolvConf configures DNS resolution
+// configureSwap turns swap off now and at boot with system.swap disable. With system.swap keep, swap the
+// agent turned off on an earlier bootstrap is turned back on.
+func (i *Installer) configureSwap(backup *systemBackup) error {
+	if i.config.System.Swap != config.SwapPolicyDisable {
+		if backup.hasSwapChanges() {
+			i.logger.Info("system.swap is no longer disable, restoring swap")
+			restoreSwap(backup, i.logger)
+			return saveBackup(backup)
+		}
+		return nil
+	}
+
+	fstab, err := os.ReadFile(fstabPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", fstabPath, err)
+	}
+	if disabled, changed := disableFstabSwap(string(fstab)); changed {
+		if backup.Fstab == "" {
+			backup.Fstab = string(fstab)
+		}
+		if err := saveBackup(backup); err != nil {
+			return err
+		}
+		if err := utils.WriteFileAtomicSystem(fstabPath, []byte(disabled), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", fstabPath, err)
+		}
+		// systemd generates swap units from /etc/fstab
+		if err := utils.ReloadSystemd(); err != nil {
+			return fmt.Errorf("failed to reload systemd: %w", err)
+		}
+		i.logger.Infof("Commented out the swap entries of %s", fstabPath)
+	}
+
+	swaps, err := os.ReadFile(procSwapsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read active swap devices: %w", err)
+	}
+	if activeSwapDevices(string(swaps)) > 0 {
+		backup.SwapTurnedOff = true
+		if err := saveBackup(backup); err != nil {
+			return err
+		}
+		if err := utils.RunSystemCommand("swapoff", "-a"); err != nil {
+			return fmt.Errorf("failed to turn swap off: %w", err)
+		}
+		i.logger.Info("Turned swap off")
+	}
+	return nil
+}
+
+// configureResolvConf configures DNS resolution. Hosts running systemd-resolved use its upstream resolv.conf,
+// and a /etc/resolv.conf symlink to a missing file is pointed at the file the host DNS stack maintains.
 func (i *Installer) configureResolvConf() error {
-	// Check if systemd-resolved is managing DNS
-	if utils.FileExists(resolvConfSource) {
+	detected := resolvconf.Detect("/")
+
+	switch {
+	case detected.Stack == resolvconf.StackResolved:
 		// Create symlink to systemd-resolved configuration
-		if err := utils.RunSystemCommand("ln", "-sf", resolvConfSource, resolvConfPath); err != nil {
+		if err := utils.RunSystemCommand("ln", "-sf", resolvconf.ResolvedPath, resolvConfPath); err != nil {
 			return fmt.Errorf("failed to configure resolv.conf symlink: %w", err)
 		}
 		i.logger.Info("Configured resolv.conf to use systemd-resolved")
-	} else {
-		i.logger.Info("systemd-resolved not available, using existing resolv.conf")
+	case detected.BrokenLink != "" && detected.Path != resolvConfPath:
+		i.logger.Warnf("%s is a symlink to missing file %s, pointing it at %s maintained by %s",
+			resolvConfPath, detected.BrokenLink, detected.Path, detected.Stack)
+		if err := utils.RunSystemCommand("ln", "-sf", detected.Path, resolvConfPath); err != nil {
+			return fmt.Errorf("failed to repair resolv.conf symlink: %w", err)
+		}
+	case detected.BrokenLink != "":
+		i.logger.Warnf("%s is a symlink to missing file %s and no DNS stack maintains a replacement, "+
+			"configure DNS on the host or set node.kubelet.resolvConf", resolvConfPath, detected.BrokenLink)
+	default:
+		i.logger.Infof("Using existing resolv.conf managed by the %s DNS stack", detected.Stack)
 	}
 
 	return nil
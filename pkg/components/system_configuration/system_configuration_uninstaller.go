@@ -2,9 +2,13 @@ package system_configuration
 
 import (
 	"context"
+	"maps"
+	"slices"
 
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/hostsfile"
+	"go.goms.io/aks/AKSFlexNode/pkg/resolvconf"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
@@ -36,24 +40,39 @@ func (su *UnInstaller) Execute(ctx context.Context) error {
 		su.logger.WithError(err).Warn("Failed to cleanup sysctl configuration")
 	}
 
+	// Stop loading the kernel modules at boot, the loaded ones stay loaded until a reboot
+	if err := utils.RunCleanupCommand(modulesLoadPath); err != nil {
+		su.logger.WithError(err).Warn("Failed to remove the kernel modules configuration")
+	}
+
 	// Cleanup resolv.conf configuration
 	if err := su.cleanupResolvConf(); err != nil {
 		su.logger.WithError(err).Warn("Failed to cleanup resolv.conf configuration")
 	}
 
+	// Remove the API server entry the cluster preflight added to /etc/hosts
+	if changed, err := hostsfile.Remove(); err != nil {
+		su.logger.WithError(err).Warn("Failed to remove the API server hosts entry")
+	} else if changed {
+		su.logger.Infof("Removed the API server entry from %s", hostsfile.Path)
+	}
+
 	// Reload sysctl to apply changes
 	if err := utils.RunSystemCommand("sysctl", "--system"); err != nil {
 		su.logger.WithError(err).Warn("Failed to reload sysctl settings")
 	}
 
+	// Removing the sysctl file does not reset the kernel parameters, restore the recorded values and swap
+	su.restoreBackup()
+
 	su.logger.Info("System configuration cleanup completed")
 	return nil
 }
 
 // IsCompleted checks if system configuration has been removed
 func (su *UnInstaller) IsCompleted(ctx context.Context) bool {
-	// Check if sysctl config exists
-	if utils.FileExists(sysctlConfigPath) {
+	// Check if sysctl config or the backup of the replaced settings exists
+	if utils.FileExists(sysctlConfigPath) || utils.FileExists(modulesLoadPath) || utils.FileExists(backupPath) {
 		return false
 	}
 	// Note: We don't check resolv.conf as it may have been restored to original state
@@ -61,6 +80,17 @@ func (su *UnInstaller) IsCompleted(ctx context.Context) bool {
 	return true
 }
 
+// Plan describes the system configuration cleanup for dry runs
+func (su *UnInstaller) Plan(ctx context.Context) []string {
+	return []string{
+		"remove " + sysctlConfigPath + " and " + modulesLoadPath,
+		"restore the kernel parameters and swap recorded in " + backupPath,
+		"remove the " + resolvConfPath + " symlink to systemd-resolved",
+		"remove the API server entry from " + hostsfile.Path,
+		"reload sysctl settings",
+	}
+}
+
 // cleanupSysctlConfig removes the sysctl configuration
 func (su *UnInstaller) cleanupSysctlConfig() error {
 	if utils.FileExists(sysctlConfigPath) {
@@ -78,7 +108,7 @@ func (su *UnInstaller) cleanupResolvConf() error {
 	if utils.FileExists(resolvConfPath) {
 		// Get link target
 		output, err := utils.RunCommandWithOutput("readlink", resolvConfPath)
-		if err == nil && output == resolvConfSource {
+		if err == nil && output == resolvconf.ResolvedPath {
 			// This is the symlink we created, remove it
 			if err := utils.RunCleanupCommand(resolvConfPath); err != nil {
 				return err
@@ -90,3 +120,49 @@ func (su *UnInstaller) cleanupResolvConf() error {
 	}
 	return nil
 }
+
+// restoreBackup sets the kernel parameters back to their recorded values and turns swap back on, then removes
+// the record. Values that cannot be restored are logged, a reboot resets them.
+func (su *UnInstaller) restoreBackup() {
+	backup, err := loadBackup()
+	if err != nil {
+		su.logger.WithError(err).Warn("Failed to read the system settings backup, kernel parameters keep their values until a reboot")
+		return
+	}
+	for _, key := range slices.Sorted(maps.Keys(backup.Sysctls)) {
+		if err := writeSysctl(key, backup.Sysctls[key]); err != nil {
+			su.logger.Warnf("Failed to restore kernel parameter %s to %q: %v", key, backup.Sysctls[key], err)
+		}
+	}
+	if len(backup.Sysctls) > 0 {
+		su.logger.Infof("Restored %d kernel parameters", len(backup.Sysctls))
+	}
+	restoreSwap(backup, su.logger)
+
+	if err := utils.RunCleanupCommand(backupPath); err != nil {
+		su.logger.WithError(err).Warn("Failed to remove the system settings backup")
+	}
+}
+
+// restoreSwap puts back the swap entries of /etc/fstab and turns swap back on, as the agent found them
+func restoreSwap(backup *systemBackup, logger *logrus.Logger) {
+	if backup.Fstab != "" {
+		if err := utils.WriteFileAtomicSystem(fstabPath, []byte(backup.Fstab), 0o644); err != nil {
+			logger.WithError(err).Warnf("Failed to restore %s", fstabPath)
+		} else {
+			backup.Fstab = ""
+			if err := utils.ReloadSystemd(); err != nil {
+				logger.WithError(err).Warn("Failed to reload systemd")
+			}
+			logger.Infof("Restored the swap entries of %s", fstabPath)
+		}
+	}
+	if backup.SwapTurnedOff {
+		if err := utils.RunSystemCommand("swapon", "-a"); err != nil {
+			logger.WithError(err).Warn("Failed to turn swap back on")
+		} else {
+			backup.SwapTurnedOff = false
+			logger.Info("Turned swap back on")
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package system_configuration
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// sysctlSetting is a kernel parameter and the value the agent sets it to
+type sysctlSetting struct {
+	key   string
+	value string
+}
+
+// conntrackMaxPerCore and conntrackMin follow the kube-proxy defaults, so a bootstrap never lowers the
+// connection tracking limit kube-proxy raised
+const (
+	conntrackMaxPerCore = 32768
+	conntrackMin        = 131072
+)
+
+// sysctlSettings returns the kernel parameters of the node in the order they are written: the agent defaults,
+// then system.sysctls, which override a default of the same name
+func sysctlSettings(cfg *config.Config, numCPU int) []sysctlSetting {
+	var settings []sysctlSetting
+	// Bridged traffic only has to pass iptables for kube-proxy, which Cilium replaces in eBPF
+	if !cfg.CNI.KubeProxyReplacement {
+		settings = append(settings,
+			sysctlSetting{"net.bridge.bridge-nf-call-iptables", "1"},
+			sysctlSetting{"net.bridge.bridge-nf-call-ip6tables", "1"})
+	}
+	settings = append(settings,
+		sysctlSetting{"net.ipv4.ip_forward", "1"},
+		sysctlSetting{"vm.overcommit_memory", "1"},
+		sysctlSetting{"kernel.panic", "10"},
+		sysctlSetting{"kernel.panic_on_oops", "1"},
+		// Every pod watching its configuration or logs takes inotify watches and instances
+		sysctlSetting{"fs.inotify.max_user_watches", "524288"},
+		sysctlSetting{"fs.inotify.max_user_instances", "8192"},
+		sysctlSetting{"net.netfilter.nf_conntrack_max", fmt.Sprint(max(conntrackMin, conntrackMaxPerCore*numCPU))})
+	// Keep the kernel default swappiness when kubelet is configured to run with swap
+	if cfg.Node.Kubelet.SwapBehavior == "" {
+		settings = append(settings, sysctlSetting{"vm.swappiness", "0"})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(cfg.System.Sysctls)) {
+		value := strings.TrimSpace(cfg.System.Sysctls[key])
+		if i := slices.IndexFunc(settings, func(s sysctlSetting) bool { return s.key == key }); i >= 0 {
+			settings[i].value = value
+			continue
+		}
+		settings = append(settings, sysctlSetting{key, value})
+	}
+	return settings
+}
+
+// renderSysctlConfig renders the sysctl.d file of the kernel parameters
+func renderSysctlConfig(settings []sysctlSetting) string {
+	var b strings.Builder
+	b.WriteString("# Kubernetes sysctl settings, managed by aks-flex-node\n")
+	for _, setting := range settings {
+		fmt.Fprintf(&b, "%s = %s\n", setting.key, setting.value)
+	}
+	return b.String()
+}
+
+// kernelModules returns the required kernel modules followed by system.kernelModules, without duplicates
+func kernelModules(cfg *config.Config) []string {
+	modules := slices.Clone(requiredKernelModules)
+	for _, module := range cfg.System.KernelModules {
+		if !slices.Contains(modules, module) {
+			modules = append(modules, module)
+		}
+	}
+	return modules
+}
+
+// renderModulesLoad renders the modules-load.d file loading the kernel modules at boot
+func renderModulesLoad(modules []string) string {
+	return "# Kernel modules of Kubernetes, managed by aks-flex-node\n" + strings.Join(modules, "\n") + "\n"
+}
+
+// activeSwapDevices returns the number of swap devices in /proc/swaps, whose first line is a header
+func activeSwapDevices(procSwaps string) int {
+	return len(strings.Split(strings.TrimSpace(procSwaps), "\n")) - 1
+}
+
+// disableFstabSwap comments out the swap entries of an fstab, so swap stays off after a reboot. It returns
+// whether any entry was commented out.
+func disableFstabSwap(fstab string) (string, bool) {
+	lines := strings.Split(fstab, "\n")
+	changed := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || fields[2] != "swap" {
+			continue
+		}
+		lines[i] = fstabDisabledPrefix + line
+		changed = true
+	}
+	return strings.Join(lines, "\n"), changed
+}
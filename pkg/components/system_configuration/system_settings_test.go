@@ -0,0 +1,106 @@
+package system_configuration
+
+import (
+	"slices"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestSysctlSettings(t *testing.T) {
+	cfg := &config.Config{
+		System: config.SystemConfig{
+			Sysctls: map[string]string{
+				"fs.inotify.max_user_watches": "1048576",
+				"fs.file-max":                 " 2097152 ",
+			},
+		},
+	}
+	cfg.CNI.KubeProxyReplacement = true
+
+	values := map[string]string{}
+	var keys []string
+	for _, setting := range sysctlSettings(cfg, 8) {
+		values[setting.key] = setting.value
+		keys = append(keys, setting.key)
+	}
+
+	if _, ok := values["net.bridge.bridge-nf-call-iptables"]; ok {
+		t.Error("bridge-nf-call-iptables is set with the kube-proxy replacement")
+	}
+	if got := values["fs.inotify.max_user_watches"]; got != "1048576" {
+		t.Errorf("fs.inotify.max_user_watches = %q, want the system.sysctls override 1048576", got)
+	}
+	if got := values["fs.file-max"]; got != "2097152" {
+		t.Errorf("fs.file-max = %q, want 2097152", got)
+	}
+	if got := values["net.netfilter.nf_conntrack_max"]; got != "262144" {
+		t.Errorf("net.netfilter.nf_conntrack_max = %q, want 262144 for 8 CPUs", got)
+	}
+	if got := values["vm.swappiness"]; got != "0" {
+		t.Errorf("vm.swappiness = %q, want 0 without swapBehavior", got)
+	}
+	if keys[len(keys)-1] != "fs.file-max" {
+		t.Errorf("keys = %v, want system.sysctls additions after the defaults", keys)
+	}
+	if len(keys) != len(values) {
+		t.Errorf("keys = %v, want every key once", keys)
+	}
+
+	cfg.Node.Kubelet.SwapBehavior = "LimitedSwap"
+	for _, setting := range sysctlSettings(cfg, 1) {
+		switch setting.key {
+		case "vm.swappiness":
+			t.Error("vm.swappiness is set with swapBehavior")
+		case "net.netfilter.nf_conntrack_max":
+			if setting.value != "131072" {
+				t.Errorf("net.netfilter.nf_conntrack_max = %q, want the 131072 minimum", setting.value)
+			}
+		}
+	}
+}
+
+func TestKernelModules(t *testing.T) {
+	cfg := &config.Config{System: config.SystemConfig{KernelModules: []string{"ip_vs", "overlay", "wireguard"}}}
+	want := []string{"overlay", "br_netfilter", "nf_conntrack", "ip_vs", "wireguard"}
+	if got := kernelModules(cfg); !slices.Equal(got, want) {
+		t.Errorf("kernelModules() = %v, want %v", got, want)
+	}
+}
+
+func TestDisableFstabSwap(t *testing.T) {
+	fstab := `# /etc/fstab
+UUID=1234 / ext4 defaults 0 1
+/swap.img none swap sw 0 0
+# /dev/sdb1 none swap sw 0 0
+UUID=5678 none swap defaults,pri=10 0 0
+`
+	want := `# /etc/fstab
+UUID=1234 / ext4 defaults 0 1
+# disabled by aks-flex-node: /swap.img none swap sw 0 0
+# /dev/sdb1 none swap sw 0 0
+# disabled by aks-flex-node: UUID=5678 none swap defaults,pri=10 0 0
+`
+	got, changed := disableFstabSwap(fstab)
+	if !changed || got != want {
+		t.Errorf("disableFstabSwap() = %q, %v, want %q, true", got, changed, want)
+	}
+	if _, changed := disableFstabSwap(got); changed {
+		t.Error("disableFstabSwap() changed an fstab without active swap entries")
+	}
+}
+
+func TestActiveSwapDevices(t *testing.T) {
+	tests := []struct {
+		swaps string
+		want  int
+	}{
+		{"Filename\tType\tSize\tUsed\tPriority\n", 0},
+		{"Filename\tType\tSize\tUsed\tPriority\n/swap.img\tfile\t2097148\t0\t-2\n", 1},
+	}
+	for _, tt := range tests {
+		if got := activeSwapDevices(tt.swaps); got != tt.want {
+			t.Errorf("activeSwapDevices(%q) = %d, want %d", tt.swaps, got, tt.want)
+		}
+	}
+}
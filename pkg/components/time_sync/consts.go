@@ -0,0 +1,12 @@
+package time_sync
+
+const (
+	// chrony configuration files, /etc/chrony on Debian and Ubuntu, /etc on rpm based distributions
+	chronyDebianConfigPath = "/etc/chrony/chrony.conf"
+	chronyRPMConfigPath    = "/etc/chrony.conf"
+	chronyDebianDropInPath = "/etc/chrony/conf.d/aks-flex-node.conf"
+	chronyRPMDropInPath    = "/etc/chrony.d/aks-flex-node.conf"
+
+	// systemd-timesyncd reads its drop-ins without an include
+	timesyncdDropInPath = "/etc/systemd/timesyncd.conf.d/aks-flex-node.conf"
+)
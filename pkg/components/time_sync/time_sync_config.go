@@ -0,0 +1,72 @@
+package time_sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+)
+
+// chronyPaths returns the chrony configuration file of the distribution and the drop-in the agent writes
+func chronyPaths() (string, string) {
+	if fsutil.FileExists(chronyDebianConfigPath) {
+		return chronyDebianConfigPath, chronyDebianDropInPath
+	}
+	return chronyRPMConfigPath, chronyRPMDropInPath
+}
+
+// renderChronyDropIn renders the chrony drop-in with the NTP servers
+func renderChronyDropIn(servers []string) string {
+	var b strings.Builder
+	b.WriteString("# NTP servers of timeSync.servers, managed by aks-flex-node\n")
+	for _, server := range servers {
+		fmt.Fprintf(&b, "server %s iburst\n", server)
+	}
+	return b.String()
+}
+
+// renderTimesyncdDropIn renders the systemd-timesyncd drop-in with the NTP servers
+func renderTimesyncdDropIn(servers []string) string {
+	return "# NTP servers of timeSync.servers, managed by aks-flex-node\n[Time]\nNTP=" + strings.Join(servers, " ") + "\n"
+}
+
+// includeLine is the line that makes chrony read the drop-in
+func includeLine(dropIn string) string {
+	return "include " + dropIn
+}
+
+// ensureInclude returns the chrony configuration with an include of the drop-in appended, unchanged when a
+// confdir of the configuration already reads the drop-in directory or the include is present
+func ensureInclude(chronyConf, dropIn string) (string, bool) {
+	for _, line := range strings.Split(chronyConf, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "confdir":
+			if slices.Contains(fields[1:], filepath.Dir(dropIn)) {
+				return chronyConf, false
+			}
+		case "include":
+			if fields[1] == dropIn {
+				return chronyConf, false
+			}
+		}
+	}
+	if chronyConf != "" && !strings.HasSuffix(chronyConf, "\n") {
+		chronyConf += "\n"
+	}
+	return chronyConf + includeLine(dropIn) + "\n", true
+}
+
+// removeInclude returns the chrony configuration without the include of the drop-in
+func removeInclude(chronyConf, dropIn string) (string, bool) {
+	lines := strings.Split(chronyConf, "\n")
+	kept := slices.DeleteFunc(slices.Clone(lines), func(line string) bool {
+		return strings.TrimSpace(line) == includeLine(dropIn)
+	})
+	return strings.Join(kept, "\n"), len(kept) != len(lines)
+}
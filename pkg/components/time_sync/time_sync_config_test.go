@@ -0,0 +1,69 @@
+package time_sync
+
+import "testing"
+
+func TestRenderDropIns(t *testing.T) {
+	servers := []string{"ntp1.contoso.local", "10.0.0.1"}
+	if got, want := renderChronyDropIn(servers), `# NTP servers of timeSync.servers, managed by aks-flex-node
+server ntp1.contoso.local iburst
+server 10.0.0.1 iburst
+`; got != want {
+		t.Errorf("renderChronyDropIn() = %q, want %q", got, want)
+	}
+	if got, want := renderTimesyncdDropIn(servers), `# NTP servers of timeSync.servers, managed by aks-flex-node
+[Time]
+NTP=ntp1.contoso.local 10.0.0.1
+`; got != want {
+		t.Errorf("renderTimesyncdDropIn() = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureInclude(t *testing.T) {
+	tests := []struct {
+		name        string
+		chronyConf  string
+		dropIn      string
+		wantChanged bool
+		want        string
+	}{
+		{
+			name:       "confdir reads the drop-in directory",
+			chronyConf: "pool ntp.ubuntu.com iburst maxsources 4\nconfdir /etc/chrony/conf.d\n",
+			dropIn:     chronyDebianDropInPath,
+			want:       "pool ntp.ubuntu.com iburst maxsources 4\nconfdir /etc/chrony/conf.d\n",
+		},
+		{
+			name:        "include appended",
+			chronyConf:  "pool 2.rhel.pool.ntp.org iburst\ndriftfile /var/lib/chrony/drift",
+			dropIn:      chronyRPMDropInPath,
+			wantChanged: true,
+			want:        "pool 2.rhel.pool.ntp.org iburst\ndriftfile /var/lib/chrony/drift\ninclude /etc/chrony.d/aks-flex-node.conf\n",
+		},
+		{
+			name:       "include present",
+			chronyConf: "pool 2.rhel.pool.ntp.org iburst\ninclude /etc/chrony.d/aks-flex-node.conf\n",
+			dropIn:     chronyRPMDropInPath,
+			want:       "pool 2.rhel.pool.ntp.org iburst\ninclude /etc/chrony.d/aks-flex-node.conf\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := ensureInclude(tt.chronyConf, tt.dropIn)
+			if got != tt.want || changed != tt.wantChanged {
+				t.Errorf("ensureInclude() = %q, %v, want %q, %v", got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestRemoveInclude(t *testing.T) {
+	original := "pool 2.rhel.pool.ntp.org iburst\ndriftfile /var/lib/chrony/drift\n"
+	included, _ := ensureInclude(original, chronyRPMDropInPath)
+	got, removed := removeInclude(included, chronyRPMDropInPath)
+	if !removed || got != original {
+		t.Errorf("removeInclude() = %q, %v, want %q, true", got, removed, original)
+	}
+	if _, removed := removeInclude(original, chronyRPMDropInPath); removed {
+		t.Error("removeInclude() changed a configuration without the include")
+	}
+}
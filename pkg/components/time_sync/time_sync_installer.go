@@ -0,0 +1,160 @@
+package time_sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/timesync"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Installer makes sure a time synchronization service runs, as kubelets with a skewed clock fail TLS
+// authentication, and points it at the NTP servers of isolated networks
+type Installer struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewInstaller creates a new time synchronization Installer
+func NewInstaller(logger *logrus.Logger) *Installer {
+	return &Installer{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (i *Installer) GetName() string {
+	return "TimeSync_Installer"
+}
+
+// Validate checks a service the agent can configure is installed. The package preflight installs chrony when
+// neither chrony nor systemd-timesyncd is.
+func (i *Installer) Validate(_ context.Context) error {
+	if !i.config.TimeSync.Enabled {
+		return nil
+	}
+	if name, _ := timesync.ActiveService(); name == timesync.NTPd && len(i.config.TimeSync.Servers) > 0 {
+		return fmt.Errorf("ntpd synchronizes the clock, configure its servers in ntpd instead of timeSync.servers or replace it with chrony")
+	}
+	if name, _ := timesync.InstalledService(); name == "" {
+		return fmt.Errorf("neither chrony nor systemd-timesyncd is installed, add chrony to packages.additional")
+	}
+	return nil
+}
+
+// IsCompleted returns true when a time synchronization service runs with the configured servers, or when
+// time synchronization is not managed and no configuration of the agent is left
+func (i *Installer) IsCompleted(_ context.Context) bool {
+	if !i.config.TimeSync.Enabled {
+		return NewUnInstaller(i.logger).IsCompleted(context.Background())
+	}
+	if active, _ := timesync.ActiveService(); active == "" {
+		return false
+	}
+	if len(i.config.TimeSync.Servers) == 0 {
+		return true
+	}
+	name, _ := timesync.InstalledService()
+	changed, err := i.configure(name, true)
+	return err == nil && !changed
+}
+
+// Plan describes the time synchronization setup for dry runs
+func (i *Installer) Plan(_ context.Context) []string {
+	if !i.config.TimeSync.Enabled {
+		return nil
+	}
+	if active, _ := timesync.ActiveService(); active != "" && len(i.config.TimeSync.Servers) == 0 {
+		return []string{"keep " + active + " synchronizing the clock"}
+	}
+	name, unit := timesync.InstalledService()
+	if name == "" {
+		name, unit = timesync.Chrony, "chronyd"
+	}
+	var actions []string
+	if len(i.config.TimeSync.Servers) > 0 {
+		actions = append(actions, fmt.Sprintf("point %s at NTP servers %s", name, strings.Join(i.config.TimeSync.Servers, ", ")))
+	}
+	return append(actions, "enable and restart "+unit)
+}
+
+// Execute configures the NTP servers of the installed time synchronization service and (re)starts it
+func (i *Installer) Execute(ctx context.Context) error {
+	if !i.config.TimeSync.Enabled {
+		return NewUnInstaller(i.logger).Execute(ctx)
+	}
+
+	active, activeUnit := timesync.ActiveService()
+	if active != "" && len(i.config.TimeSync.Servers) == 0 {
+		i.logger.Infof("%s (%s) synchronizes the clock", active, activeUnit)
+		return nil
+	}
+
+	name, unit := timesync.InstalledService()
+	if name == "" {
+		return fmt.Errorf("neither chrony nor systemd-timesyncd is installed")
+	}
+	changed, err := i.configure(name, false)
+	if err != nil {
+		return err
+	}
+
+	if err := sysutil.EnableAndStartService(unit); err != nil {
+		return fmt.Errorf("failed to enable and start %s: %w", unit, err)
+	}
+	// Restart the service to pick up changed servers
+	if changed {
+		if err := sysutil.RestartService(unit); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", unit, err)
+		}
+	}
+
+	i.logger.Infof("%s synchronizes the clock", name)
+	return nil
+}
+
+// configure writes the drop-in with the NTP servers of the service and, for chrony, includes it in the
+// configuration. It returns whether a file changed; with dryRun it only compares them.
+func (i *Installer) configure(name string, dryRun bool) (bool, error) {
+	files := map[string]string{}
+	switch name {
+	case timesync.Chrony:
+		chronyConf, dropIn := chronyPaths()
+		existing, err := os.ReadFile(chronyConf)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", chronyConf, err)
+		}
+		if included, changed := ensureInclude(string(existing), dropIn); changed {
+			files[chronyConf] = included
+		}
+		files[dropIn] = renderChronyDropIn(i.config.TimeSync.Servers)
+	case timesync.Timesyncd:
+		files[timesyncdDropInPath] = renderTimesyncdDropIn(i.config.TimeSync.Servers)
+	}
+
+	changed := false
+	for path, want := range files {
+		if existing, err := os.ReadFile(path); err == nil && string(existing) == want {
+			continue
+		}
+		changed = true
+		if dryRun {
+			continue
+		}
+		if err := sysutil.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+			return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := fsutil.WriteFileAtomicSystem(path, []byte(want), 0o644); err != nil {
+			return false, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,83 @@
+package time_sync
+
+import (
+	"context"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/timesync"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller removes the NTP servers the agent configured. The time synchronization service keeps running,
+// chrony is removed with the other packages the agent installed.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new time synchronization UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (u *UnInstaller) GetName() string {
+	return "TimeSync_UnInstaller"
+}
+
+// Execute removes the drop-ins with the NTP servers and the chrony include, then restarts the service
+func (u *UnInstaller) Execute(_ context.Context) error {
+	changed := false
+	for _, path := range []string{chronyDebianDropInPath, chronyRPMDropInPath, timesyncdDropInPath} {
+		if !fsutil.FileExists(path) {
+			continue
+		}
+		if err := fsutil.RunCleanupCommand(path); err != nil {
+			u.logger.Warnf("Failed to remove %s: %v", path, err)
+			continue
+		}
+		changed = true
+	}
+	for chronyConf, dropIn := range map[string]string{chronyDebianConfigPath: chronyDebianDropInPath, chronyRPMConfigPath: chronyRPMDropInPath} {
+		existing, err := os.ReadFile(chronyConf)
+		if err != nil {
+			continue
+		}
+		if kept, removed := removeInclude(string(existing), dropIn); removed {
+			if err := fsutil.WriteFileAtomicSystem(chronyConf, []byte(kept), 0o644); err != nil {
+				u.logger.Warnf("Failed to remove the include of %s from %s: %v", dropIn, chronyConf, err)
+				continue
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	// Let the service go back to the servers of the distribution
+	if _, unit := timesync.ActiveService(); unit != "" {
+		if err := sysutil.RestartService(unit); err != nil {
+			u.logger.Warnf("Failed to restart %s: %v", unit, err)
+		}
+	}
+	u.logger.Info("Removed the NTP servers of timeSync.servers")
+	return nil
+}
+
+// IsCompleted returns true when no drop-in of the agent is left
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	return !fsutil.FileExists(chronyDebianDropInPath) && !fsutil.FileExists(chronyRPMDropInPath) && !fsutil.FileExists(timesyncdDropInPath)
+}
+
+// Plan describes the time synchronization cleanup for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{"remove the NTP server drop-ins of chrony and systemd-timesyncd and restart the time synchronization service"}
+}
@@ -0,0 +1,38 @@
+package windows_node
+
+import "go.goms.io/aks/AKSFlexNode/pkg/platform"
+
+const (
+	containerdService = "containerd"
+	kubeletService    = "kubelet"
+
+	// containerdDataDir is the containerd root directory holding images and snapshots
+	containerdDataDir = `C:\ProgramData\containerd`
+
+	// kubernetesTarPath is the directory of the node binaries in the Kubernetes node archive
+	kubernetesTarPath = "kubernetes/node/bin/"
+
+	// aksServiceResourceID is the AKS AAD server application the kubelet token is requested for
+	aksServiceResourceID = "6dae42f8-4368-4678-94ff-3960e28e3630"
+
+	// Component names used for provenance records, shared with the Linux installers
+	containerdProvenanceComponent = "containerd"
+	kubernetesProvenanceComponent = "kubernetes"
+)
+
+// paths are the Windows paths of the node components. They are joined with backslashes rather than
+// filepath.Join, so the rendered configuration is the same wherever the agent is built and tested.
+var paths = platform.ForWindows().Paths
+
+var (
+	containerdPath    = paths.ContainerdDir + `\containerd.exe`
+	containerdLogPath = paths.ContainerdDir + `\containerd.log`
+
+	kubeletPath                = paths.BinDir + `\kubelet.exe`
+	kubeletConfigPath          = paths.KubernetesDir + `\config.yaml`
+	kubeletKubeconfigPath      = paths.KubernetesDir + `\kubeconfig`
+	kubeletBootstrapKubeconfig = paths.KubernetesDir + `\bootstrap-kubeconfig`
+	kubeletTokenScriptPath     = paths.KubernetesDir + `\token.ps1`
+	kubeletClientSecretPath    = paths.KubernetesDir + `\client-secret`
+	kubeletCertDir             = paths.KubeletDir + `\pki`
+)
@@ -0,0 +1,122 @@
+package windows_node
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// ContainerdInstaller installs containerd from its Windows release archive and runs it as a Windows service
+type ContainerdInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewContainerdInstaller creates a new Windows containerd installer
+func NewContainerdInstaller(logger *logrus.Logger) *ContainerdInstaller {
+	return &ContainerdInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *ContainerdInstaller) GetName() string {
+	return "WindowsContainerd_Installer"
+}
+
+// Validate checks that the archive tool shipped with Windows is available
+func (i *ContainerdInstaller) Validate(_ context.Context) error {
+	if _, err := exec.LookPath("tar.exe"); err != nil {
+		return fmt.Errorf("tar.exe is required to extract the containerd release, it ships with Windows 10 1803 and Windows Server 2019 or later: %w", err)
+	}
+	return nil
+}
+
+// IsCompleted returns true when containerd is installed, configured and running
+func (i *ContainerdInstaller) IsCompleted(_ context.Context) bool {
+	return fsutil.FileExists(containerdPath) && fsutil.FileExists(paths.ContainerdConfig) &&
+		sysutil.IsServiceActive(containerdService)
+}
+
+// Plan describes the containerd installation for dry runs
+func (i *ContainerdInstaller) Plan(_ context.Context) []string {
+	return []string{
+		fmt.Sprintf("download containerd %s from %s", i.version(), i.downloadURL()),
+		"extract containerd into " + paths.ContainerdDir,
+		fmt.Sprintf("write %s with sandbox image %s", paths.ContainerdConfig, i.pauseImage()),
+		fmt.Sprintf("register and start the %s service", containerdService),
+	}
+}
+
+// Execute downloads containerd, writes its configuration and starts the service
+func (i *ContainerdInstaller) Execute(ctx context.Context) error {
+	i.logger.Infof("Installing containerd %s for Windows", i.version())
+	if err := createDirectories(paths.ContainerdDir); err != nil {
+		return err
+	}
+
+	url := i.downloadURL()
+	archive, err := downloadArchive(ctx, i.config, i.logger, url, i.config.Containerd.Checksum)
+	if err != nil {
+		return err
+	}
+	defer fsutil.CleanupTempFile(archive)
+
+	// The archive holds the binaries below bin/
+	if err := sysutil.RunSystemCommandContext(ctx, "tar.exe", "-xzf", archive, "-C", paths.ContainerdDir, "--strip-components=1"); err != nil {
+		return fmt.Errorf("failed to extract containerd: %w", err)
+	}
+	if err := provenance.RecordInstall(containerdProvenanceComponent, i.version(), url, archive); err != nil {
+		i.logger.Warnf("Failed to record containerd provenance: %v", err)
+	}
+
+	defaultConfig, err := sysutil.RunCommandWithOutput(containerdPath, "config", "default")
+	if err != nil {
+		return fmt.Errorf("failed to generate the default containerd configuration: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(paths.ContainerdConfig, []byte(renderContainerdConfig(defaultConfig, i.pauseImage())), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", paths.ContainerdConfig, err)
+	}
+
+	if !sysutil.ServiceExists(containerdService) {
+		if err := sysutil.RunSystemCommand(containerdPath, "--register-service",
+			"--config", paths.ContainerdConfig, "--log-file", containerdLogPath); err != nil {
+			return fmt.Errorf("failed to register the %s service: %w", containerdService, err)
+		}
+	}
+	if err := startService(containerdService); err != nil {
+		return fmt.Errorf("failed to start %s: %w", containerdService, err)
+	}
+
+	i.logger.Infof("containerd %s installed and running", i.version())
+	return nil
+}
+
+// downloadURL returns the URL of the containerd Windows release archive
+func (i *ContainerdInstaller) downloadURL() string {
+	return fmt.Sprintf(defaults.Get().URLs.ContainerdWindows, i.version(), i.version(), runtime.GOARCH)
+}
+
+func (i *ContainerdInstaller) version() string {
+	if i.config.Containerd.Version != "" {
+		return i.config.Containerd.Version
+	}
+	return defaults.Get().Versions.Containerd
+}
+
+func (i *ContainerdInstaller) pauseImage() string {
+	if i.config.Containerd.PauseImage != "" {
+		return i.config.Containerd.PauseImage
+	}
+	return defaults.Get().Images.Pause
+}
@@ -0,0 +1,173 @@
+package windows_node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/secrets"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// KubeletInstaller installs kubelet from the Kubernetes Windows node archive and runs it as a Windows service
+// joining the cluster through TLS bootstrap. Only service principal authentication is supported on Windows.
+type KubeletInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewKubeletInstaller creates a new Windows kubelet installer
+func NewKubeletInstaller(logger *logrus.Logger) *KubeletInstaller {
+	return &KubeletInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the step name
+func (i *KubeletInstaller) GetName() string {
+	return "WindowsKubelet_Installer"
+}
+
+// Validate checks that kubelet can authenticate on Windows and the Kubernetes version is known
+func (i *KubeletInstaller) Validate(_ context.Context) error {
+	switch {
+	case i.config.IsARCEnabled():
+		return fmt.Errorf("authentication with Azure Arc is not supported on Windows nodes yet, disable azure.arc and configure azure.servicePrincipal")
+	case i.config.IsWorkloadIdentityConfigured():
+		return fmt.Errorf("workload identity authentication is not supported on Windows nodes yet, configure azure.servicePrincipal")
+	case !i.config.IsSPConfigured():
+		return fmt.Errorf("a service principal is required on Windows nodes, configure azure.servicePrincipal")
+	}
+	if i.config.GetKubernetesVersion() == "" {
+		return fmt.Errorf("kubernetes version not specified and not resolved from the cluster, the cluster preflight could not read the cluster's version")
+	}
+	return nil
+}
+
+// IsCompleted returns true when kubelet is installed, configured and running
+func (i *KubeletInstaller) IsCompleted(_ context.Context) bool {
+	return fsutil.FileExists(kubeletPath) && fsutil.FileExists(kubeletConfigPath) &&
+		fsutil.FileExists(kubeletBootstrapKubeconfig) && sysutil.IsServiceActive(kubeletService)
+}
+
+// Plan describes the kubelet installation for dry runs
+func (i *KubeletInstaller) Plan(_ context.Context) []string {
+	return []string{
+		fmt.Sprintf("download Kubernetes %s from %s", i.config.GetKubernetesVersion(), i.downloadURL()),
+		"extract kubelet.exe and kubectl.exe into " + paths.BinDir,
+		fmt.Sprintf("write %s, %s, %s and %s", kubeletClientSecretPath, kubeletTokenScriptPath, kubeletBootstrapKubeconfig, kubeletConfigPath),
+		fmt.Sprintf("register and start the %s service", kubeletService),
+	}
+}
+
+// Execute downloads kubelet, writes its credentials and configuration and starts the service
+func (i *KubeletInstaller) Execute(ctx context.Context) error {
+	version := i.config.GetKubernetesVersion()
+	i.logger.Infof("Installing kubelet %s for Windows", version)
+	if err := createDirectories(paths.BinDir, paths.KubernetesDir, paths.KubeletDir, kubeletCertDir); err != nil {
+		return err
+	}
+
+	url := i.downloadURL()
+	archive, err := downloadArchive(ctx, i.config, i.logger, url, i.config.Kubernetes.Checksum)
+	if err != nil {
+		return err
+	}
+	defer fsutil.CleanupTempFile(archive)
+
+	if err := sysutil.RunSystemCommandContext(ctx, "tar.exe", "-xzf", archive, "-C", paths.BinDir, "--strip-components=3",
+		kubernetesTarPath+"kubelet.exe", kubernetesTarPath+"kubectl.exe"); err != nil {
+		return fmt.Errorf("failed to extract kubelet: %w", err)
+	}
+	if err := provenance.RecordInstall(kubernetesProvenanceComponent, version, url, archive); err != nil {
+		i.logger.Warnf("Failed to record Kubernetes binaries provenance: %v", err)
+	}
+
+	if err := i.writeCredentials(ctx); err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(kubeletConfigPath, []byte(renderKubeletConfig(i.config)), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", kubeletConfigPath, err)
+	}
+
+	if err := i.registerService(); err != nil {
+		return err
+	}
+	if err := startService(kubeletService); err != nil {
+		return fmt.Errorf("failed to start %s: %w", kubeletService, err)
+	}
+
+	i.logger.Infof("kubelet %s installed and running", version)
+	return nil
+}
+
+// writeCredentials writes the token script and the bootstrap kubeconfig pointing at the cluster API server
+func (i *KubeletInstaller) writeCredentials(ctx context.Context) error {
+	adminKubeconfig, err := kubelet.WriteAdminKubeconfig(ctx, i.logger)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster credentials: %w", err)
+	}
+	defer fsutil.CleanupTempFile(adminKubeconfig)
+
+	kubeconfigData, err := os.ReadFile(adminKubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster credentials: %w", err)
+	}
+	serverURL, caCertData, err := utils.ExtractClusterInfo(kubeconfigData)
+	if err != nil {
+		return fmt.Errorf("failed to extract cluster info from kubeconfig: %w", err)
+	}
+
+	sp := i.config.Azure.ServicePrincipal
+	clientSecret, err := secrets.ClientSecret(ctx, i.config)
+	if err != nil {
+		return fmt.Errorf("failed to get the service principal secret: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(kubeletClientSecretPath, []byte(clientSecret), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", kubeletClientSecretPath, err)
+	}
+	// File modes do not apply on Windows, the secret would inherit read access for all users
+	if err := sysutil.RunSystemCommand("icacls.exe", kubeletClientSecretPath, "/inheritance:r",
+		"/grant:r", "SYSTEM:(F)", "Administrators:(F)"); err != nil {
+		return fmt.Errorf("failed to restrict access to %s: %w", kubeletClientSecretPath, err)
+	}
+	tokenScript := renderTokenScript(auth.Cloud(i.config).TokenEndpoint(sp.TenantID), sp.ClientID)
+	if err := fsutil.WriteFileAtomic(kubeletTokenScriptPath, []byte(tokenScript), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", kubeletTokenScriptPath, err)
+	}
+	bootstrapKubeconfig := renderBootstrapKubeconfig(serverURL, caCertData, i.config.Azure.TargetCluster.Name)
+	if err := fsutil.WriteFileAtomic(kubeletBootstrapKubeconfig, []byte(bootstrapKubeconfig), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", kubeletBootstrapKubeconfig, err)
+	}
+	return nil
+}
+
+// registerService registers the kubelet service, or updates its command line when it is registered already.
+// It depends on containerd so the service control manager starts containerd first.
+func (i *KubeletInstaller) registerService() error {
+	command := "create"
+	if sysutil.ServiceExists(kubeletService) {
+		command = "config"
+	}
+	if err := sysutil.RunSystemCommand("sc.exe", command, kubeletService,
+		"binPath=", kubeletCommandLine(i.config), "start=", "auto", "depend=", containerdService); err != nil {
+		return fmt.Errorf("failed to register the %s service: %w", kubeletService, err)
+	}
+	return nil
+}
+
+// downloadURL returns the URL of the Kubernetes Windows node archive
+func (i *KubeletInstaller) downloadURL() string {
+	return fmt.Sprintf(defaults.Get().URLs.KubernetesWindows, i.config.GetKubernetesVersion(), runtime.GOARCH)
+}
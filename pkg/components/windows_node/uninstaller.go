@@ -0,0 +1,102 @@
+package windows_node
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// UnInstaller stops and unregisters the kubelet and containerd services and removes their files.
+// A shared containerd, see containerd.shared, is left in place.
+type UnInstaller struct {
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewUnInstaller creates a new Windows node UnInstaller
+func NewUnInstaller(logger *logrus.Logger) *UnInstaller {
+	return &UnInstaller{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *UnInstaller) GetName() string {
+	return "WindowsNode_UnInstaller"
+}
+
+// IsCompleted returns true when no service is registered and no directory is left
+func (u *UnInstaller) IsCompleted(_ context.Context) bool {
+	for _, service := range u.services() {
+		if sysutil.ServiceExists(service) {
+			return false
+		}
+	}
+	for _, dir := range u.directories() {
+		if fsutil.DirectoryExists(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// Plan describes the removal for dry runs
+func (u *UnInstaller) Plan(_ context.Context) []string {
+	return []string{
+		fmt.Sprintf("stop and unregister the %v services", u.services()),
+		fmt.Sprintf("remove %v", u.directories()),
+	}
+}
+
+// Execute stops kubelet before containerd, unregisters both and removes their directories
+func (u *UnInstaller) Execute(_ context.Context) error {
+	for _, service := range u.services() {
+		if !sysutil.ServiceExists(service) {
+			continue
+		}
+		if sysutil.IsServiceActive(service) {
+			if err := sysutil.StopService(service); err != nil {
+				return fmt.Errorf("failed to stop %s: %w", service, err)
+			}
+		}
+		if err := sysutil.RunSystemCommand("sc.exe", "delete", service); err != nil {
+			return fmt.Errorf("failed to unregister the %s service: %w", service, err)
+		}
+		u.logger.Infof("Removed the %s service", service)
+	}
+
+	var failed []error
+	for _, dir := range u.directories() {
+		if err := os.RemoveAll(dir); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove node directories: %v", failed)
+	}
+	return nil
+}
+
+// services returns the services to remove, kubelet first as it depends on containerd
+func (u *UnInstaller) services() []string {
+	if u.config.Containerd.Shared {
+		return []string{kubeletService}
+	}
+	return []string{kubeletService, containerdService}
+}
+
+// directories returns the directories to remove
+func (u *UnInstaller) directories() []string {
+	dirs := []string{paths.BinDir, paths.KubeletDir}
+	if !u.config.Containerd.Shared {
+		dirs = append(dirs, paths.ContainerdDir, containerdDataDir)
+	}
+	return dirs
+}
@@ -0,0 +1,182 @@
+package windows_node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/downloadcache"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// sandboxImagePattern matches the sandbox image setting of the containerd CRI plugin
+var sandboxImagePattern = regexp.MustCompile(`(?m)^(\s*)sandbox_image = ".*"$`)
+
+// downloadArchive downloads a release archive into the staging directory and returns its path.
+// Callers remove the archive with fsutil.CleanupTempFile once it is extracted.
+func downloadArchive(ctx context.Context, cfg *config.Config, logger *logrus.Logger, url string, checksum *config.ChecksumConfig) (string, error) {
+	archive, err := fsutil.StagingPath(cfg.Paths.StagingDir, path.Base(url))
+	if err != nil {
+		return "", err
+	}
+	logger.Infof("Downloading %s into %s", url, archive)
+	if err := downloadcache.New(cfg, logger).FetchVerified(ctx, url, archive, checksum); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return archive, nil
+}
+
+// createDirectories creates the directories the components are installed into
+func createDirectories(dirs ...string) error {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// startService starts a registered service, restarting it when it already runs so it picks up new configuration
+func startService(name string) error {
+	if sysutil.IsServiceActive(name) {
+		return sysutil.RestartService(name)
+	}
+	return sysutil.EnableAndStartService(name)
+}
+
+// renderContainerdConfig sets the sandbox image in the default containerd configuration
+func renderContainerdConfig(defaultConfig, pauseImage string) string {
+	return sandboxImagePattern.ReplaceAllString(defaultConfig, fmt.Sprintf(`${1}sandbox_image = "%s"`, pauseImage))
+}
+
+// renderTokenScript renders the PowerShell exec credential script requesting Entra ID tokens for AKS with the
+// service principal credentials. The secret is read from its own file, so the script holds none.
+func renderTokenScript(tokenEndpoint, clientID string) string {
+	return fmt.Sprintf(`$ErrorActionPreference = "Stop"
+
+# Get an Entra ID token using Service Principal credentials for direct AKS authentication
+$response = Invoke-RestMethod -Method Post -Uri %s -ContentType "application/x-www-form-urlencoded" -Body @{
+    client_id     = %s
+    client_secret = (Get-Content -Raw -LiteralPath %s)
+    scope         = "%s/.default"
+    grant_type    = "client_credentials"
+}
+$expiry = (Get-Date).ToUniversalTime().AddSeconds($response.expires_in).ToString("yyyy-MM-ddTHH:mm:ssZ")
+
+# Return in ExecCredential format
+@{
+    kind       = "ExecCredential"
+    apiVersion = "client.authentication.k8s.io/v1beta1"
+    spec       = @{ interactive = $false }
+    status     = @{ expirationTimestamp = $expiry; token = $response.access_token }
+} | ConvertTo-Json -Compress
+`, psQuote(tokenEndpoint), psQuote(clientID), psQuote(kubeletClientSecretPath), aksServiceResourceID)
+}
+
+// psQuote quotes s as a PowerShell string that is not expanded
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// renderBootstrapKubeconfig renders the kubeconfig kubelet performs TLS bootstrap with, authenticating
+// with the token script
+func renderBootstrapKubeconfig(serverURL, caCertData, clusterName string) string {
+	cluster := fmt.Sprintf("    insecure-skip-tls-verify: true\n    server: %s", serverURL)
+	if caCertData != "" {
+		cluster = fmt.Sprintf("    certificate-authority-data: %s\n    server: %s", caCertData, serverURL)
+	}
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+%s
+  name: %s
+contexts:
+- context:
+    cluster: %s
+    user: sp-user
+  name: sp-context
+current-context: sp-context
+users:
+- name: sp-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: powershell.exe
+      args:
+      - -NoProfile
+      - -NonInteractive
+      - -ExecutionPolicy
+      - Bypass
+      - -File
+      - '%s'
+      provideClusterInfo: false
+`, cluster, clusterName, clusterName, kubeletTokenScriptPath)
+}
+
+// renderKubeletConfig renders the kubelet configuration file. Windows has no cgroups, so kubelet neither
+// creates QoS cgroups nor enforces node allocatable.
+func renderKubeletConfig(cfg *config.Config) string {
+	return fmt.Sprintf(`apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+authentication:
+  anonymous:
+    enabled: false
+  webhook:
+    enabled: true
+authorization:
+  mode: Webhook
+cgroupsPerQOS: false
+enforceNodeAllocatable: []
+clusterDNS:
+- %s
+clusterDomain: cluster.local
+containerRuntimeEndpoint: %s
+maxPods: %d
+resolvConf: ""
+rotateCertificates: true
+`, cfg.Node.Kubelet.DNSServiceIP, paths.ContainerdEndpoint, cfg.Node.MaxPods)
+}
+
+// kubeletCommandLine returns the command line the kubelet service runs
+func kubeletCommandLine(cfg *config.Config) string {
+	args := []string{
+		kubeletPath,
+		"--windows-service",
+		"--config=" + kubeletConfigPath,
+		"--bootstrap-kubeconfig=" + kubeletBootstrapKubeconfig,
+		"--kubeconfig=" + kubeletKubeconfigPath,
+		"--cert-dir=" + kubeletCertDir,
+		"--root-dir=" + paths.KubeletDir,
+		fmt.Sprintf("--v=%d", cfg.Node.Kubelet.Verbosity),
+	}
+	// Only pass --hostname-override when configured so kubelet keeps using the computer name otherwise
+	if cfg.Node.HostnameOverride != "" {
+		args = append(args, "--hostname-override="+cfg.GetNodeName())
+	}
+	if labels := sortedPairs(cfg.Node.Labels); labels != "" {
+		args = append(args, "--node-labels="+labels)
+	}
+	if len(cfg.Node.Taints) > 0 {
+		args = append(args, "--register-with-taints="+strings.Join(cfg.Node.Taints, ","))
+	}
+	return strings.Join(args, " ")
+}
+
+// sortedPairs renders a map as comma separated key=value pairs in sorted key order
+func sortedPairs(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for key, value := range m {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,78 @@
+package windows_node
+
+import (
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRenderContainerdConfig(t *testing.T) {
+	defaultConfig := "[plugins.\"io.containerd.grpc.v1.cri\"]\n    sandbox_image = \"registry.k8s.io/pause:3.8\"\n"
+	want := "[plugins.\"io.containerd.grpc.v1.cri\"]\n    sandbox_image = \"mcr.microsoft.com/oss/kubernetes/pause:3.6\"\n"
+	if got := renderContainerdConfig(defaultConfig, "mcr.microsoft.com/oss/kubernetes/pause:3.6"); got != want {
+		t.Errorf("renderContainerdConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTokenScript(t *testing.T) {
+	script := renderTokenScript("https://login.microsoftonline.com/tenant/oauth2/v2.0/token", "it's $client")
+	for _, want := range []string{
+		"-Uri 'https://login.microsoftonline.com/tenant/oauth2/v2.0/token'",
+		// Single quotes keep PowerShell from expanding the values
+		"client_id     = 'it''s $client'",
+		`client_secret = (Get-Content -Raw -LiteralPath 'C:\k\client-secret')`,
+		`scope         = "` + aksServiceResourceID + `/.default"`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("token script is missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestRenderBootstrapKubeconfig(t *testing.T) {
+	kubeconfig := renderBootstrapKubeconfig("https://cluster:443", "Q0E=", "cluster")
+	for _, want := range []string{
+		"    certificate-authority-data: Q0E=\n    server: https://cluster:443\n  name: cluster",
+		`      - 'C:\k\token.ps1'`,
+	} {
+		if !strings.Contains(kubeconfig, want) {
+			t.Errorf("bootstrap kubeconfig is missing %q:\n%s", want, kubeconfig)
+		}
+	}
+	if insecure := renderBootstrapKubeconfig("https://cluster:443", "", "cluster"); !strings.Contains(insecure, "insecure-skip-tls-verify: true") {
+		t.Errorf("bootstrap kubeconfig without CA should skip TLS verification:\n%s", insecure)
+	}
+}
+
+func TestKubeletCommandLine(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Node.Kubelet.Verbosity = 2
+	cfg.Node.Labels = map[string]string{"zone": "edge", "app": "pos"}
+	cfg.Node.Taints = []string{"os=windows:NoSchedule"}
+
+	want := `C:\k\kubelet.exe --windows-service --config=C:\k\config.yaml --bootstrap-kubeconfig=C:\k\bootstrap-kubeconfig ` +
+		`--kubeconfig=C:\k\kubeconfig --cert-dir=C:\var\lib\kubelet\pki --root-dir=C:\var\lib\kubelet --v=2 ` +
+		`--node-labels=app=pos,zone=edge --register-with-taints=os=windows:NoSchedule`
+	if got := kubeletCommandLine(cfg); got != want {
+		t.Errorf("kubeletCommandLine() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderKubeletConfig(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Node.Kubelet.DNSServiceIP = "10.0.0.10"
+	cfg.Node.MaxPods = 30
+
+	rendered := renderKubeletConfig(cfg)
+	for _, want := range []string{
+		"cgroupsPerQOS: false",
+		"clusterDNS:\n- 10.0.0.10",
+		"containerRuntimeEndpoint: npipe:////./pipe/containerd-containerd",
+		"maxPods: 30",
+	} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("kubelet config is missing %q:\n%s", want, rendered)
+		}
+	}
+}
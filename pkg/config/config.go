@@ -1,22 +1,54 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/viper"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/maintenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
 )
 
 const (
 	// Default configuration values
 	defaultConfigPath = "/etc/aks-flex-node/config.json"
-	defaultLogDir     = "/var/log/aks-flex-node"
 	defaultLogLevel   = "info"
-	defaultAzureCloud = "AzurePublicCloud"
+	defaultLogFormat  = "text"
+	defaultAzureCloud = CloudAzurePublic
 
 	// Environment variable prefix
 	envPrefix = "AKS_NODE_CONTROLLER"
+
+	// DefaultPodCIDR is the subnet the bridge CNI assigns pod IPs from when node.podCIDR is not set
+	DefaultPodCIDR = "10.244.0.0/16"
+
+	// GPURuntimeClass is the RuntimeClass and containerd runtime handler of pods using NVIDIA GPUs
+	GPURuntimeClass = "nvidia"
+
+	// DefaultServiceCIDR is the AKS default service CIDR, assumed when node.serviceCIDR is not set
+	DefaultServiceCIDR = "10.0.0.0/16"
+
+	// DefaultParallelSteps is how many bootstrap steps run at once when agent.parallelSteps is not set
+	DefaultParallelSteps = 3
+
+	// maxParallelSteps bounds agent.parallelSteps, few steps can run concurrently
+	maxParallelSteps = 8
+
+	// DefaultDrainTimeout bounds the drain of unbootstrap when node.drain.timeout is not set
+	DefaultDrainTimeout = 5 * time.Minute
 )
 
 // Singleton instance for configuration
@@ -50,9 +82,16 @@ func LoadConfig(configPath string) (*Config, error) {
 	v.AutomaticEnv()
 	v.SetEnvPrefix(envPrefix)
 
-	// Load the specified config file
-	v.SetConfigFile(configPath)
-	if err := v.ReadInConfig(); err != nil {
+	// Load the specified config file, upgraded to the current schema version
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file at %s: %w", configPath, err)
+	}
+	migration, err := MigrateDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file at %s: %w", configPath, err)
+	}
+	if err := v.ReadConfig(bytes.NewReader(migration.Data)); err != nil {
 		return nil, fmt.Errorf("failed to read config file at %s: %w", configPath, err)
 	}
 
@@ -62,6 +101,18 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
+	config.migratedFrom = migration.FromVersion
+
+	// Merge the node pool profile over the node settings, before defaults and validation apply to them
+	if err := config.applyProfile(); err != nil {
+		return nil, err
+	}
+
+	// Resolve secrets referenced from the environment, files or standard input
+	if err := config.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
 	// Set defaults for any missing values
 	config.SetDefaults()
 
@@ -72,6 +123,14 @@ func LoadConfig(configPath string) (*Config, error) {
 
 	populateTargetClusterInfoFromConfig(config)
 
+	// Name the Arc machine and the node after the machine identity so a reimaged device keeps its names
+	if err := config.applyMachineIdentity(); err != nil {
+		return nil, err
+	}
+
+	// Retry downloads and Azure API calls as configured
+	retry.SetDefault(config.GetRetryPolicy())
+
 	// Set the singleton instance
 	configMutex.Lock()
 	defer configMutex.Unlock()
@@ -89,6 +148,13 @@ func (c *Config) SetDefaults() {
 	c.setContainerdDefaults()
 	c.setRuncDefaults()
 	c.setNpdDefaults()
+	c.setKubeVIPDefaults()
+	c.setKubeProxyDefaults()
+	c.setNodeLocalDNSDefaults()
+	c.setSystemDefaults()
+	c.setStorageDefaults()
+	c.setHealthCheckDefaults()
+	c.setDownloadCacheDefaults()
 }
 
 func (c *Config) setAzureCloudDefaults() {
@@ -106,6 +172,12 @@ func (c *Config) setAgentDefaults() {
 	if c.Agent.LogDir == "" {
 		c.Agent.LogDir = defaultLogDir
 	}
+	if c.Agent.LogFormat == "" {
+		c.Agent.LogFormat = defaultLogFormat
+	}
+	if c.Agent.UpdateCheck != nil && c.Agent.UpdateCheck.Interval == "" {
+		c.Agent.UpdateCheck.Interval = "6h"
+	}
 }
 
 func (c *Config) setPathDefaults() {
@@ -125,6 +197,10 @@ func (c *Config) setPathDefaults() {
 	if c.Paths.Kubernetes.KubeletDir == "" {
 		c.Paths.Kubernetes.KubeletDir = "/var/lib/kubelet"
 	}
+	// Stage artifacts under the agent state directory, hardened hosts often mount /tmp noexec
+	if c.Paths.StagingDir == "" {
+		c.Paths.StagingDir = filepath.Join(AgentStateDir, "tmp")
+	}
 }
 
 func (c *Config) setNodeDefaults() {
@@ -139,7 +215,18 @@ func (c *Config) setNodeDefaults() {
 	}
 	// Mark node as unmanaged by cloud controller manager by default, otherwise ccm will delete this node if node is not ready
 	// doc: https://cloud-provider-azure.sigs.k8s.io/topics/cross-resource-group-nodes/#unmanaged-nodes
-	c.Node.Labels["kubernetes.azure.com/managed"] = "false"
+	// Nodes handed to an external cloud controller manager must stay managed so it can initialize them
+	if !c.IsExternalCloudProvider() {
+		c.Node.Labels["kubernetes.azure.com/managed"] = "false"
+	}
+	// Label GPU nodes like AKS GPU node pools, so GPU workloads and the NVIDIA device plugin select them
+	if c.IsGPUEnabled() {
+		for key, value := range GPUNodeLabels {
+			if _, ok := c.Node.Labels[key]; !ok {
+				c.Node.Labels[key] = value
+			}
+		}
+	}
 
 	// Set default kubelet configuration if not provided
 	if c.Node.Kubelet.Verbosity == 0 {
@@ -151,11 +238,12 @@ func (c *Config) setNodeDefaults() {
 	if c.Node.Kubelet.ImageGCLowThreshold == 0 {
 		c.Node.Kubelet.ImageGCLowThreshold = 80 // stop GC when disk usage < 80%
 	}
-	// Set default DNS service IP if not provided
-	// Note: This default assumes the standard AKS service CIDR (10.0.0.0/16)
-	// Clusters with custom service CIDRs should specify this value explicitly
+	// Derive the DNS service IP from the service CIDR like AKS does, 10.0.0.10 for the default 10.0.0.0/16.
+	// An invalid service CIDR leaves it unset for Validate to report.
 	if c.Node.Kubelet.DNSServiceIP == "" {
-		c.Node.Kubelet.DNSServiceIP = "10.0.0.10"
+		if dnsServiceIP, err := ClusterDNSIP(c.GetServiceCIDR()); err == nil {
+			c.Node.Kubelet.DNSServiceIP = dnsServiceIP
+		}
 	}
 	// Initialize default kubelet resource reservations if not provided
 	if c.Node.Kubelet.KubeReserved == nil {
@@ -175,14 +263,85 @@ func (c *Config) setContainerdDefaults() {
 func (c *Config) setRuncDefaults() {
 	// Set default runc configuration if not provided
 	if c.Runc.Version == "" {
-		c.Runc.Version = "1.1.12"
+		c.Runc.Version = defaults.Get().Versions.Runc
 	}
 }
 
 func (c *Config) setNpdDefaults() {
 	// Set default NPD configuration if not provided
 	if c.Npd.Version == "" {
-		c.Npd.Version = "v1.35.1"
+		c.Npd.Version = defaults.Get().Versions.NPD
+	}
+	// An empty list runs only the flex node checks, an unset one keeps the kernel monitor
+	if c.Npd.Monitors == nil {
+		c.Npd.Monitors = []string{NPDMonitorKernel}
+	}
+}
+
+func (c *Config) setKubeVIPDefaults() {
+	// Set default kube-vip configuration if not provided
+	if c.KubeVIP.Version == "" {
+		c.KubeVIP.Version = defaults.Get().Versions.KubeVIP
+	}
+}
+
+func (c *Config) setKubeProxyDefaults() {
+	// Set default kube-proxy configuration if not provided
+	if c.KubeProxy.Mode == "" {
+		c.KubeProxy.Mode = KubeProxyModeIPTables
+	}
+}
+
+func (c *Config) setNodeLocalDNSDefaults() {
+	// Set default node-local DNS cache configuration if not provided
+	if c.NodeLocalDNS.LocalIP == "" {
+		c.NodeLocalDNS.LocalIP = DefaultNodeLocalDNSIP
+	}
+	if c.NodeLocalDNS.Image == "" {
+		c.NodeLocalDNS.Image = defaults.Get().Images.NodeLocalDNS
+	}
+}
+
+func (c *Config) setSystemDefaults() {
+	// Leave swap alone unless asked to turn it off
+	if c.System.Swap == "" {
+		c.System.Swap = SwapPolicyKeep
+	}
+}
+
+func (c *Config) setStorageDefaults() {
+	// Set default data disk settings if a device is configured
+	if c.Storage.Device == "" {
+		return
+	}
+	if c.Storage.MountPath == "" {
+		c.Storage.MountPath = DefaultStorageMountPath
+	}
+	if c.Storage.Filesystem == "" {
+		c.Storage.Filesystem = DefaultStorageFilesystem
+	}
+}
+
+func (c *Config) setHealthCheckDefaults() {
+	// Set default health check settings if not provided
+	for i := range c.HealthChecks {
+		check := &c.HealthChecks[i]
+		if check.Timeout == "" {
+			check.Timeout = "10s"
+		}
+		if check.Action == "" {
+			check.Action = HealthCheckActionReport
+		}
+		if check.URL != "" && check.ExpectedStatus == 0 {
+			check.ExpectedStatus = 200
+		}
+	}
+}
+
+func (c *Config) setDownloadCacheDefaults() {
+	// Set default download cache size if not provided
+	if c.DownloadCache.MaxSizeMB == 0 {
+		c.DownloadCache.MaxSizeMB = 2048
 	}
 }
 
@@ -202,7 +361,157 @@ func validateAzureResourceID(resourceID string) error {
 	return nil
 }
 
+// nodeNamePattern matches a valid Kubernetes node name (RFC 1123 DNS subdomain)
+var nodeNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// namespacePattern matches a valid Kubernetes namespace name (RFC 1123 DNS label)
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// packageNamePattern matches a valid deb or rpm package name
+var packageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9+._-]*$`)
+
+// registryHostPattern matches a registry host with an optional port
+var registryHostPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9.]*[A-Za-z0-9])?(:[0-9]{1,5})?$`)
+
+// managedPrefixPattern matches the start of a label or taint key
+var managedPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_./]*$`)
+
+// nodeTaintPattern matches a taint in the kubelet --register-with-taints format
+var nodeTaintPattern = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_./]*(=[-A-Za-z0-9_.]*)?:(NoSchedule|PreferNoSchedule|NoExecute)$`)
+
+// labelValuePattern matches a valid Kubernetes label value
+var labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+
+// sha256Pattern matches a hex-encoded SHA-256
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// healthCheckNamePattern matches a health check name, which is also used as a key in node status
+var healthCheckNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// minUpdateCheckInterval keeps a fleet of nodes from polling the release channel too often
+const minUpdateCheckInterval = 5 * time.Minute
+
+// maxHealthCheckTimeout keeps a probe within the one minute status collection interval
+const maxHealthCheckTimeout = 30 * time.Second
+
 // validLogLevels defines the allowed logging levels for the agent
+// reservedMemoryPattern matches one NUMA node entry of the kubelet --reserved-memory flag, e.g. 0:memory=1Gi,hugepages-2Mi=512Mi
+var reservedMemoryPattern = regexp.MustCompile(`^[0-9]+:[a-z0-9.-]+=[0-9]+(\.[0-9]+)?[A-Za-z]*(,[a-z0-9.-]+=[0-9]+(\.[0-9]+)?[A-Za-z]*)*$`)
+
+// guidPattern matches a GUID such as an SMBIOS system UUID
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// smbiosUUIDPath exposes the SMBIOS system UUID, readable by root only; replaced in tests
+var smbiosUUIDPath = "/sys/class/dmi/id/product_uuid"
+
+// placeholderSMBIOSUUIDs are system UUIDs firmware reports when the vendor did not set one, shared by many devices
+var placeholderSMBIOSUUIDs = map[string]bool{
+	"00000000-0000-0000-0000-000000000000": true,
+	"ffffffff-ffff-ffff-ffff-ffffffffffff": true,
+	"03000200-0400-0500-0006-000700080009": true,
+}
+
+var validMachineIDSources = map[string]bool{
+	MachineIDSourceHostname: true,
+	MachineIDSourceSMBIOS:   true,
+	MachineIDSourceConfig:   true,
+}
+
+// featureGatePattern matches a kubelet feature gate setting such as KubeletTracing=true. Feature gates are a list
+// rather than a map because the configuration loader lowercases map keys, which would break the CamelCase names.
+var featureGatePattern = regexp.MustCompile(`^([A-Z][A-Za-z0-9]*)=(true|false)$`)
+
+// kubeletFlagPattern matches a kubelet flag in --name or --name=value form. Values cannot contain
+// whitespace, quotes, backslashes or shell expansions as flags are rendered into a quoted environment file.
+// sysctlKeyPattern matches kernel parameter names, dot-separated as in /etc/sysctl.d
+var sysctlKeyPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[A-Za-z0-9_-]+)+$`)
+
+// kernelModulePattern matches kernel module names as modprobe takes them
+var kernelModulePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var kubeletFlagPattern = regexp.MustCompile(`^--([a-z0-9][a-z0-9-]*)(=[^\s"'\\$` + "`" + `]*)?$`)
+
+// deniedKubeletFlags are kubelet flags node.kubelet.extraFlags must not set, either because they secure
+// the kubelet API and its credentials or because the agent manages them. The value names the setting to
+// use instead, if there is one.
+var deniedKubeletFlags = map[string]string{
+	"anonymous-auth":               "",
+	"authentication-token-webhook": "",
+	"authorization-mode":           "",
+	"client-ca-file":               "",
+	"tls-cert-file":                "",
+	"tls-private-key-file":         "",
+	"tls-cipher-suites":            "",
+	"tls-min-version":              "",
+	"rotate-certificates":          "",
+	"rotate-server-certificates":   "node.kubelet.serverTLSBootstrap",
+	"cert-dir":                     "",
+	"kubeconfig":                   "",
+	"bootstrap-kubeconfig":         "",
+	"read-only-port":               "",
+	"protect-kernel-defaults":      "",
+	"container-runtime-endpoint":   "",
+	"cgroup-driver":                "",
+	"config":                       "",
+	"feature-gates":                "node.kubelet.featureGates",
+	"max-pods":                     "node.maxPods",
+	"hostname-override":            "node.hostnameOverride",
+	"node-labels":                  "node.labels",
+	"register-with-taints":         "node.taints",
+}
+
+var validCPUManagerPolicies = map[string]bool{
+	"none":   true,
+	"static": true,
+}
+
+var validTopologyManagerPolicies = map[string]bool{
+	"none":             true,
+	"best-effort":      true,
+	"restricted":       true,
+	"single-numa-node": true,
+}
+
+var validMemoryManagerPolicies = map[string]bool{
+	"None":   true,
+	"Static": true,
+}
+
+var validHealthCheckActions = map[string]bool{
+	HealthCheckActionReport:    true,
+	HealthCheckActionRemediate: true,
+	HealthCheckActionGate:      true,
+}
+
+var validKeyProtections = map[string]bool{
+	KeyProtectionFile: true,
+	KeyProtectionTPM:  true,
+	KeyProtectionAuto: true,
+}
+
+var validIPTablesBackends = map[string]bool{
+	IPTablesBackendNFT:    true,
+	IPTablesBackendLegacy: true,
+}
+
+// GPUNodeLabels are the labels of nodes with node.gpu.enabled, matching those of AKS GPU node pools
+var GPUNodeLabels = map[string]string{
+	"kubernetes.azure.com/accelerator": "nvidia",
+	"nvidia.com/gpu.present":           "true",
+}
+
+var validCNIModes = map[string]bool{
+	CNIModeBridge:   true,
+	CNIModeCilium:   true,
+	CNIModeAzureCNI: true,
+	CNIModeNone:     true,
+}
+
+var validSwapBehaviors = map[string]bool{
+	"NoSwap":      true,
+	"LimitedSwap": true,
+}
+
 var validLogLevels = map[string]bool{
 	"debug":   true,
 	"info":    true,
@@ -210,12 +519,14 @@ var validLogLevels = map[string]bool{
 	"error":   true,
 }
 
-// validAzureClouds defines the supported Azure cloud environments
-// Currently only Azure Public Cloud is supported
-var validAzureClouds = map[string]bool{
-	"AzurePublicCloud": true,
+var validLogFormats = map[string]bool{
+	"text": true,
+	"json": true,
 }
 
+// validAzureClouds lists the supported Azure cloud environments, public cloud first
+var validAzureClouds = []string{CloudAzurePublic, CloudAzureChina, CloudAzureUSGovernment}
+
 // Validate validates the configuration and ensures all required fields are set
 func (c *Config) Validate() error {
 	// Validate required Azure configuration (core requirements for Arc discovery)
@@ -237,16 +548,1079 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid azure.targetCluster.resourceId: %w", err)
 	}
 
+	// Validate the API server hosts entry
+	if ip := c.Azure.TargetCluster.APIServerIP; ip != "" && net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid azure.targetCluster.apiServerIP: %s. Must be an IP address", ip)
+	}
+	if fqdn := c.Azure.TargetCluster.APIServerFQDN; fqdn != "" {
+		if c.Azure.TargetCluster.APIServerIP == "" {
+			return fmt.Errorf("azure.targetCluster.apiServerFQDN requires azure.targetCluster.apiServerIP")
+		}
+		if len(fqdn) > 253 || !nodeNamePattern.MatchString(strings.ToLower(fqdn)) {
+			return fmt.Errorf("invalid azure.targetCluster.apiServerFQDN: %s. Must be a DNS name", fqdn)
+		}
+	}
+
+	if err := c.validateMachineIdentity(); err != nil {
+		return err
+	}
+
+	if err := c.validateAuthentication(); err != nil {
+		return err
+	}
+
+	if err := c.validateKeyVault(); err != nil {
+		return err
+	}
+
 	// Validate Azure cloud
-	if !validAzureClouds[c.Azure.Cloud] {
-		return fmt.Errorf("invalid azure.cloud: %s. Valid values are: AzurePublicCloud", c.Azure.Cloud)
+	if !slices.Contains(validAzureClouds, c.Azure.Cloud) {
+		return fmt.Errorf("invalid azure.cloud: %s. Valid values are: %s", c.Azure.Cloud, strings.Join(validAzureClouds, ", "))
 	}
 
 	// Validate log level
 	if !validLogLevels[c.Agent.LogLevel] {
 		return fmt.Errorf("invalid agent.logLevel: %s. Valid values are: debug, info, warning, error", c.Agent.LogLevel)
 	}
+	if c.Agent.LogFormat != "" && !validLogFormats[c.Agent.LogFormat] {
+		return fmt.Errorf("invalid agent.logFormat: %s. Valid values are: text, json", c.Agent.LogFormat)
+	}
+
+	// Validate maintenance window schedule
+	if mw := c.Agent.MaintenanceWindow; mw != nil {
+		if _, err := maintenance.NewWindow(mw.Schedule, mw.Duration, mw.TimeZone); err != nil {
+			return fmt.Errorf("invalid agent.maintenanceWindow: %w", err)
+		}
+	}
+
+	// Validate metrics endpoint address
+	if c.Agent.MetricsAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Agent.MetricsAddress); err != nil {
+			return fmt.Errorf("invalid agent.metricsAddress: %s. Expected format: host:port", c.Agent.MetricsAddress)
+		}
+	}
+
+	// Validate diagnostics endpoint address, which shows the configuration and must not leave the machine
+	if c.Agent.DiagnosticsAddress != "" {
+		host, _, err := net.SplitHostPort(c.Agent.DiagnosticsAddress)
+		if err != nil {
+			return fmt.Errorf("invalid agent.diagnosticsAddress: %s. Expected format: host:port", c.Agent.DiagnosticsAddress)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("invalid agent.diagnosticsAddress: %s. Must listen on a loopback address such as 127.0.0.1", c.Agent.DiagnosticsAddress)
+		}
+	}
+
+	// Validate update check channel
+	if uc := c.Agent.UpdateCheck; uc != nil {
+		u, err := url.Parse(uc.ManifestURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid agent.updateCheck.manifestUrl: %s. Must be an http or https URL", uc.ManifestURL)
+		}
+		if interval, err := time.ParseDuration(uc.Interval); err != nil || interval < minUpdateCheckInterval {
+			return fmt.Errorf("invalid agent.updateCheck.interval: %s. Must be a duration of at least %s", uc.Interval, minUpdateCheckInterval)
+		}
+	}
+
+	// Validate egress IP endpoint
+	if endpoint := c.Agent.EgressIPEndpoint; endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid agent.egressIPEndpoint: %s. Must be an http or https URL", endpoint)
+		}
+	}
+
+	// Validate kubelet API client rate limits
+	if c.Node.Kubelet.KubeAPIQPS < 0 || c.Node.Kubelet.KubeAPIBurst < 0 {
+		return fmt.Errorf("invalid node.kubelet.kubeAPIQPS %d or kubeAPIBurst %d. Must not be negative",
+			c.Node.Kubelet.KubeAPIQPS, c.Node.Kubelet.KubeAPIBurst)
+	}
+	if c.Node.Kubelet.KubeAPIQPS > 0 && c.Node.Kubelet.KubeAPIBurst > 0 && c.Node.Kubelet.KubeAPIBurst < c.Node.Kubelet.KubeAPIQPS {
+		return fmt.Errorf("invalid node.kubelet.kubeAPIBurst: %d. Must not be lower than kubeAPIQPS %d",
+			c.Node.Kubelet.KubeAPIBurst, c.Node.Kubelet.KubeAPIQPS)
+	}
+
+	// Validate kubelet cloud provider
+	if c.Node.Kubelet.CloudProvider != "" && !c.IsExternalCloudProvider() {
+		return fmt.Errorf("invalid node.kubelet.cloudProvider: %s. Valid values are: external", c.Node.Kubelet.CloudProvider)
+	}
+	if c.Node.Kubelet.ProviderID != "" && !c.IsExternalCloudProvider() {
+		return fmt.Errorf("node.kubelet.providerID requires node.kubelet.cloudProvider to be external")
+	}
+
+	// Validate node taints format
+	for _, taint := range c.Node.Taints {
+		if !nodeTaintPattern.MatchString(taint) {
+			return fmt.Errorf("invalid node.taints entry: %s. Expected format: key[=value]:NoSchedule|PreferNoSchedule|NoExecute", taint)
+		}
+	}
+
+	// Validate the label and taint key prefixes the agent owns
+	for _, prefix := range c.Node.ManagedPrefixes {
+		if !managedPrefixPattern.MatchString(prefix) {
+			return fmt.Errorf("invalid node.managedPrefixes entry: %q. Must be the start of a label key, e.g. example.com/", prefix)
+		}
+	}
+
+	if err := c.validateDrain(); err != nil {
+		return err
+	}
+	if c.Node.ReadyTimeout != "" {
+		if d, err := time.ParseDuration(c.Node.ReadyTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid node.readyTimeout: %s. Must be a positive duration such as 5m", c.Node.ReadyTimeout)
+		}
+	}
+
+	if !labelValuePattern.MatchString(c.Node.SiteID) {
+		return fmt.Errorf("invalid node.siteId: %s. Must be a valid label value of at most 63 characters", c.Node.SiteID)
+	}
+
+	// Validate CPU, topology and memory manager settings
+	if err := c.validateKubeletResourceManagers(); err != nil {
+		return err
+	}
+	if err := c.validateKubeletPassthrough(); err != nil {
+		return err
+	}
+	if c.Node.Kubelet.KeyProtection != "" && !validKeyProtections[c.Node.Kubelet.KeyProtection] {
+		return fmt.Errorf("invalid node.kubelet.keyProtection: %s. Valid values are: file, tpm, auto", c.Node.Kubelet.KeyProtection)
+	}
+	if c.Node.Kubelet.ResolvConf != "" && !filepath.IsAbs(c.Node.Kubelet.ResolvConf) {
+		return fmt.Errorf("invalid node.kubelet.resolvConf: %s. Must be an absolute path", c.Node.Kubelet.ResolvConf)
+	}
+
+	if c.Node.IPTablesBackend != "" && !validIPTablesBackends[c.Node.IPTablesBackend] {
+		return fmt.Errorf("invalid node.iptablesBackend: %s. Valid values are: nft, legacy", c.Node.IPTablesBackend)
+	}
+
+	// Validate containerd and pod sandbox limits
+	if err := c.validateContainerd(); err != nil {
+		return err
+	}
+
+	// Validate the CNI mode and its pod subnet
+	if err := c.validateCNI(); err != nil {
+		return err
+	}
+	if err := c.validateServiceCIDR(); err != nil {
+		return err
+	}
+
+	// Validate host package settings
+	if c.Packages.OfflineDir != "" && !filepath.IsAbs(c.Packages.OfflineDir) {
+		return fmt.Errorf("invalid packages.offlineDir: %s. Must be an absolute path", c.Packages.OfflineDir)
+	}
+	for _, pkg := range c.Packages.Additional {
+		if !packageNamePattern.MatchString(pkg) {
+			return fmt.Errorf("invalid packages.additional entry: %s. Must be a package name", pkg)
+		}
+	}
+	if c.Node.GPU != nil {
+		for _, pkg := range c.Node.GPU.DriverPackages {
+			if !packageNamePattern.MatchString(pkg) {
+				return fmt.Errorf("invalid node.gpu.driverPackages entry: %s. Must be a package name", pkg)
+			}
+		}
+	}
+
+	for _, bundle := range c.TrustedCA.Bundles {
+		if !filepath.IsAbs(bundle) {
+			return fmt.Errorf("invalid trustedCA.bundles entry: %s. Must be an absolute path", bundle)
+		}
+	}
+	if c.TrustedCA.InjectKubeconfig && len(c.TrustedCA.Bundles) == 0 {
+		return fmt.Errorf("trustedCA.injectKubeconfig requires trustedCA.bundles")
+	}
+
+	if c.Paths.StagingDir != "" && !filepath.IsAbs(c.Paths.StagingDir) {
+		return fmt.Errorf("invalid paths.stagingDir: %s. Must be an absolute path", c.Paths.StagingDir)
+	}
+
+	// Validate orchestration API settings
+	if err := c.validateAPI(); err != nil {
+		return err
+	}
+
+	// Validate daemon health endpoint settings
+	if healthz := c.Agent.Healthz; healthz != nil {
+		if healthz.SocketPath != "" && !filepath.IsAbs(healthz.SocketPath) {
+			return fmt.Errorf("invalid agent.healthz.socketPath: %s. Must be an absolute path", healthz.SocketPath)
+		}
+		if healthz.Address != "" {
+			if _, _, err := net.SplitHostPort(healthz.Address); err != nil {
+				return fmt.Errorf("invalid agent.healthz.address: %s. Must be host:port", healthz.Address)
+			}
+		}
+	}
+
+	// Validate retry settings
+	if err := c.validateRetry(); err != nil {
+		return err
+	}
+	if c.Agent.ParallelSteps < 0 || c.Agent.ParallelSteps > maxParallelSteps {
+		return fmt.Errorf("invalid agent.parallelSteps: %d. Must be between 1 and %d", c.Agent.ParallelSteps, maxParallelSteps)
+	}
+
+	// Validate download cache settings
+	if c.DownloadCache.MaxSizeMB < 0 {
+		return fmt.Errorf("invalid downloadCache.maxSizeMB: %d. Must not be negative", c.DownloadCache.MaxSizeMB)
+	}
+
+	// Validate the checksums component downloads are verified with
+	if err := c.validateChecksums(); err != nil {
+		return err
+	}
+
+	// Validate kube-vip load balancer settings
+	if err := c.validateKubeVIP(); err != nil {
+		return err
+	}
+
+	// Validate the Node Problem Detector monitors
+	if err := c.validateNPD(); err != nil {
+		return err
+	}
+
+	// Validate kube-proxy settings
+	if err := c.validateKubeProxy(); err != nil {
+		return err
+	}
+
+	// Validate node-local DNS cache settings
+	if err := c.validateNodeLocalDNS(); err != nil {
+		return err
+	}
+
+	// Validate host system settings
+	if err := c.validateSystem(); err != nil {
+		return err
+	}
+
+	// Validate time synchronization settings
+	if err := c.validateTimeSync(); err != nil {
+		return err
+	}
+
+	// Validate data disk settings
+	if err := c.validateStorage(); err != nil {
+		return err
+	}
+
+	// Validate the Kubernetes upgrade policy
+	if err := c.validateAutoUpgrade(); err != nil {
+		return err
+	}
+
+	// Validate maintenance windows and actions
+	if err := c.validateMaintenance(); err != nil {
+		return err
+	}
+
+	// Validate node pool profiles
+	if err := c.validateProfiles(); err != nil {
+		return err
+	}
+
+	// Validate custom health checks
+	if err := c.validateHealthChecks(); err != nil {
+		return err
+	}
 
+	// Validate hostname override is usable as a node name
+	if c.Node.HostnameOverride != "" &&
+		(len(c.Node.HostnameOverride) > 253 || !nodeNamePattern.MatchString(strings.ToLower(c.Node.HostnameOverride))) {
+		return fmt.Errorf("invalid node.hostnameOverride: %s. Must be a valid DNS subdomain name", c.Node.HostnameOverride)
+	}
+
+	return nil
+}
+
+// ClusterDNSIP returns the cluster DNS service IP AKS assigns in a service CIDR, its tenth address
+func ClusterDNSIP(serviceCIDR string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(serviceCIDR)
+	if err != nil {
+		return "", fmt.Errorf("invalid service CIDR %s: %w", serviceCIDR, err)
+	}
+	ip := slices.Clone(ipNet.IP)
+	ip[len(ip)-1] += 10
+	if ip[len(ip)-1] < 10 || !ipNet.Contains(ip) {
+		return "", fmt.Errorf("service CIDR %s is too small to hold the cluster DNS IP", serviceCIDR)
+	}
+	return ip.String(), nil
+}
+
+// validateServiceCIDR validates node.serviceCIDR and that the cluster DNS IP lies within it
+func (c *Config) validateServiceCIDR() error {
+	if c.Node.ServiceCIDR != "" {
+		if _, _, err := net.ParseCIDR(c.Node.ServiceCIDR); err != nil {
+			return fmt.Errorf("invalid node.serviceCIDR: %s. Must be a CIDR such as %s", c.Node.ServiceCIDR, DefaultServiceCIDR)
+		}
+	}
+	dnsServiceIP := c.Node.Kubelet.DNSServiceIP
+	if dnsServiceIP == "" {
+		return nil
+	}
+	ip := net.ParseIP(dnsServiceIP)
+	if ip == nil {
+		return fmt.Errorf("invalid node.kubelet.dnsServiceIP: %s. Must be an IP address", dnsServiceIP)
+	}
+	// Configurations predating node.serviceCIDR set a DNS IP of a custom service CIDR alone
+	if c.Node.ServiceCIDR != "" {
+		_, ipNet, _ := net.ParseCIDR(c.Node.ServiceCIDR)
+		if !ipNet.Contains(ip) {
+			return fmt.Errorf("node.kubelet.dnsServiceIP %s is outside node.serviceCIDR %s", dnsServiceIP, c.Node.ServiceCIDR)
+		}
+	}
+	return nil
+}
+
+// validateCNI validates the CNI mode and the pod subnet of the modes that assign pod IPs from node.podCIDR
+func (c *Config) validateCNI() error {
+	if c.Node.PodCIDR != "" && c.CNI.PodCIDR != "" && c.Node.PodCIDR != c.CNI.PodCIDR {
+		return fmt.Errorf("node.podCIDR %s and cni.podCIDR %s differ, set only node.podCIDR", c.Node.PodCIDR, c.CNI.PodCIDR)
+	}
+	if c.CNI.Mode != "" && !validCNIModes[c.CNI.Mode] {
+		return fmt.Errorf("invalid cni.mode: %s. Valid values are: bridge, cilium, azure-cni, none", c.CNI.Mode)
+	}
+	if c.CNI.Gateway != "" && c.GetCNIMode() != CNIModeBridge {
+		return fmt.Errorf("cni.gateway is only used with cni.mode bridge, but cni.mode is %s", c.GetCNIMode())
+	}
+	switch c.GetCNIMode() {
+	case CNIModeBridge:
+		return c.validatePodCIDR()
+	case CNIModeCilium:
+		_, err := c.parsePodCIDR()
+		return err
+	}
+	return nil
+}
+
+// parsePodCIDR parses the pod subnet, which must be an IPv4 subnet of at least /30
+func (c *Config) parsePodCIDR() (*net.IPNet, error) {
+	podCIDR := c.GetPodCIDR()
+	ip, ipNet, err := net.ParseCIDR(podCIDR)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid %s: %s. Must be an IPv4 CIDR such as %s", c.podCIDRField(), podCIDR, DefaultPodCIDR)
+	}
+	if prefixLength, _ := ipNet.Mask.Size(); prefixLength > 30 {
+		return nil, fmt.Errorf("invalid %s: %s. Must be a /30 or larger subnet", c.podCIDRField(), podCIDR)
+	}
+	return ipNet, nil
+}
+
+// validatePodCIDR validates the bridge pod subnet and gateway and that the subnet has an IP for each of
+// node.maxPods pods, as pods beyond the subnet's capacity stay in ContainerCreating waiting for an IP
+func (c *Config) validatePodCIDR() error {
+	ipNet, err := c.parsePodCIDR()
+	if err != nil {
+		return err
+	}
+	podCIDR := c.GetPodCIDR()
+	prefixLength, _ := ipNet.Mask.Size()
+
+	if c.CNI.Gateway != "" {
+		gateway := net.ParseIP(c.CNI.Gateway).To4()
+		if gateway == nil || !ipNet.Contains(gateway) || gateway.Equal(ipNet.IP.To4()) || gateway.Equal(broadcastAddress(ipNet)) {
+			return fmt.Errorf("invalid cni.gateway: %s. Must be a host address within %s %s", c.CNI.Gateway, c.podCIDRField(), podCIDR)
+		}
+	}
+
+	if capacity := podIPCapacity(prefixLength); c.Node.MaxPods > capacity {
+		return fmt.Errorf("node.maxPods %d exceeds the %d pod IPs of %s %s, so pods beyond them would be stuck in ContainerCreating. "+
+			"Set node.maxPods to at most %d or use a /%d or larger %s",
+			c.Node.MaxPods, capacity, c.podCIDRField(), podCIDR, capacity, prefixLengthFor(c.Node.MaxPods), c.podCIDRField())
+	}
+	return nil
+}
+
+// broadcastAddress returns the last address of an IPv4 subnet
+func broadcastAddress(ipNet *net.IPNet) net.IP {
+	ip := ipNet.IP.To4()
+	broadcast := make(net.IP, len(ip))
+	for i := range ip {
+		broadcast[i] = ip[i] | ^ipNet.Mask[i]
+	}
+	return broadcast
+}
+
+// podIPCapacity returns the number of pod IPs host-local IPAM hands out from an IPv4 subnet of the given prefix length.
+// The network, broadcast and bridge gateway addresses are not assigned to pods.
+func podIPCapacity(prefixLength int) int {
+	return 1<<(32-prefixLength) - 3
+}
+
+// prefixLengthFor returns the longest prefix length of a subnet holding maxPods pod IPs
+func prefixLengthFor(maxPods int) int {
+	prefixLength := 30
+	for prefixLength > 1 && podIPCapacity(prefixLength) < maxPods {
+		prefixLength--
+	}
+	return prefixLength
+}
+
+// validateAPI validates the orchestration API listeners, a TCP listener requires mutual TLS
+func (c *Config) validateAPI() error {
+	api := c.Agent.API
+	if api == nil {
+		return nil
+	}
+	if api.SocketPath != "" && !filepath.IsAbs(api.SocketPath) {
+		return fmt.Errorf("invalid agent.api.socketPath: %s. Must be an absolute path", api.SocketPath)
+	}
+	if api.Address == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(api.Address); err != nil {
+		return fmt.Errorf("invalid agent.api.address: %s. Must be host:port", api.Address)
+	}
+	if api.TLSCertFile == "" || api.TLSKeyFile == "" || api.ClientCAFile == "" {
+		return fmt.Errorf("agent.api.address requires agent.api.tlsCertFile, agent.api.tlsKeyFile and agent.api.clientCAFile for mutual TLS")
+	}
+	return nil
+}
+
+// validateContainerd validates the containerd download, garbage collection and pod limits
+func (c *Config) validateContainerd() error {
+	if c.Containerd.MaxConcurrentDownloads < 0 {
+		return fmt.Errorf("invalid containerd.maxConcurrentDownloads: %d. Must not be negative", c.Containerd.MaxConcurrentDownloads)
+	}
+	if c.Node.PodsPerCore < 0 {
+		return fmt.Errorf("invalid node.podsPerCore: %d. Must not be negative", c.Node.PodsPerCore)
+	}
+
+	if err := c.validateRegistries(); err != nil {
+		return err
+	}
+	if err := c.validateContainerdPatch(); err != nil {
+		return err
+	}
+
+	gc := c.Containerd.GC
+	if gc == nil {
+		return nil
+	}
+	// containerd refuses to start with a pause threshold above 50%
+	if gc.PauseThreshold < 0 || gc.PauseThreshold > 0.5 {
+		return fmt.Errorf("invalid containerd.gc.pauseThreshold: %g. Must be between 0 and 0.5", gc.PauseThreshold)
+	}
+	if gc.DeletionThreshold < 0 || gc.MutationThreshold < 0 {
+		return fmt.Errorf("invalid containerd.gc thresholds: deletionThreshold and mutationThreshold must not be negative")
+	}
+	for _, delay := range []struct{ name, value string }{
+		{"scheduleDelay", gc.ScheduleDelay},
+		{"startupDelay", gc.StartupDelay},
+	} {
+		if delay.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(delay.value); err != nil || d < 0 {
+			return fmt.Errorf("invalid containerd.gc.%s: %s. Must be a duration such as 100ms", delay.name, delay.value)
+		}
+	}
+	return nil
+}
+
+// validateContainerdPatch validates the TOML merged into and imported by the containerd configuration
+func (c *Config) validateContainerdPatch() error {
+	if c.Containerd.ConfigPatch != "" {
+		var patch map[string]any
+		if err := toml.Unmarshal([]byte(c.Containerd.ConfigPatch), &patch); err != nil {
+			return fmt.Errorf("invalid containerd.configPatch: %w", err)
+		}
+		// The agent renders a version 2 configuration, a patch cannot change how containerd parses it
+		if _, ok := patch["version"]; ok {
+			return fmt.Errorf("invalid containerd.configPatch: version cannot be patched")
+		}
+	}
+	for i, path := range c.Containerd.ConfigImports {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("invalid containerd.configImports[%d]: %q. Must be an absolute path", i, path)
+		}
+		if _, err := filepath.Match(path, ""); err != nil {
+			return fmt.Errorf("invalid containerd.configImports[%d]: %q: %w", i, path, err)
+		}
+	}
+	return nil
+}
+
+// validateRegistries validates the registries containerd pulls images from
+func (c *Config) validateRegistries() error {
+	seen := map[string]bool{}
+	for i, registry := range c.Containerd.Registries {
+		field := fmt.Sprintf("containerd.registries[%d]", i)
+		if registry.Host != DefaultRegistryHost && !registryHostPattern.MatchString(registry.Host) {
+			return fmt.Errorf("invalid %s.host: %q. Must be a registry host such as registry.example.com:5000, without scheme or path, or %s",
+				field, registry.Host, DefaultRegistryHost)
+		}
+		if seen[registry.Host] {
+			return fmt.Errorf("invalid %s.host: %s is configured more than once", field, registry.Host)
+		}
+		seen[registry.Host] = true
+
+		for _, mirror := range registry.Mirrors {
+			u, err := url.Parse(mirror)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return fmt.Errorf("invalid %s.mirrors entry: %q. Must be an http or https URL", field, mirror)
+			}
+		}
+		if registry.Host == DefaultRegistryHost && len(registry.Mirrors) == 0 {
+			return fmt.Errorf("invalid %s: %s needs mirrors", field, DefaultRegistryHost)
+		}
+		if registry.CAFile != "" && !filepath.IsAbs(registry.CAFile) {
+			return fmt.Errorf("invalid %s.caFile: %s. Must be an absolute path", field, registry.CAFile)
+		}
+		if registry.Insecure && registry.CAFile != "" {
+			return fmt.Errorf("invalid %s: insecure and caFile are mutually exclusive", field)
+		}
+		if (registry.Username == "") != (registry.Password == "") {
+			return fmt.Errorf("invalid %s: username and password must be set together", field)
+		}
+		if registry.Username != "" && registry.Host == DefaultRegistryHost {
+			return fmt.Errorf("invalid %s: credentials need a registry host, not %s", field, DefaultRegistryHost)
+		}
+	}
+	return nil
+}
+
+// validateKubeletResourceManagers validates the kubelet CPU, topology, memory manager and swap settings.
+// Checks against the detected hardware happen when kubelet is configured.
+func (c *Config) validateKubeletResourceManagers() error {
+	kubelet := c.Node.Kubelet
+
+	if kubelet.CPUManagerPolicy != "" && !validCPUManagerPolicies[kubelet.CPUManagerPolicy] {
+		return fmt.Errorf("invalid node.kubelet.cpuManagerPolicy: %s. Valid values are: none, static", kubelet.CPUManagerPolicy)
+	}
+	if kubelet.TopologyManagerPolicy != "" && !validTopologyManagerPolicies[kubelet.TopologyManagerPolicy] {
+		return fmt.Errorf("invalid node.kubelet.topologyManagerPolicy: %s. Valid values are: none, best-effort, restricted, single-numa-node",
+			kubelet.TopologyManagerPolicy)
+	}
+	if kubelet.MemoryManagerPolicy != "" && !validMemoryManagerPolicies[kubelet.MemoryManagerPolicy] {
+		return fmt.Errorf("invalid node.kubelet.memoryManagerPolicy: %s. Valid values are: None, Static", kubelet.MemoryManagerPolicy)
+	}
+
+	if kubelet.ReservedSystemCPUs != "" {
+		if _, err := utils.ParseCPUSet(kubelet.ReservedSystemCPUs); err != nil {
+			return fmt.Errorf("invalid node.kubelet.reservedSystemCPUs: %w", err)
+		}
+	}
+	// The static CPU manager only hands out exclusive CPUs once some are reserved for the system
+	if kubelet.CPUManagerPolicy == "static" && kubelet.ReservedSystemCPUs == "" && kubelet.KubeReserved["cpu"] == "" {
+		return fmt.Errorf("node.kubelet.cpuManagerPolicy static requires node.kubelet.reservedSystemCPUs or node.kubelet.kubeReserved.cpu")
+	}
+
+	if kubelet.ReservedMemory != "" {
+		if kubelet.MemoryManagerPolicy != "Static" {
+			return fmt.Errorf("node.kubelet.reservedMemory requires node.kubelet.memoryManagerPolicy to be Static")
+		}
+		for _, entry := range strings.Split(kubelet.ReservedMemory, ";") {
+			if !reservedMemoryPattern.MatchString(strings.TrimSpace(entry)) {
+				return fmt.Errorf("invalid node.kubelet.reservedMemory entry: %s. Expected format: <numa-node>:memory=<quantity>", entry)
+			}
+		}
+	}
+	if kubelet.MemoryManagerPolicy == "Static" && kubelet.ReservedMemory == "" {
+		return fmt.Errorf("node.kubelet.memoryManagerPolicy Static requires node.kubelet.reservedMemory")
+	}
+
+	if kubelet.SwapBehavior != "" && !validSwapBehaviors[kubelet.SwapBehavior] {
+		return fmt.Errorf("invalid node.kubelet.swapBehavior: %s. Valid values are: NoSwap, LimitedSwap", kubelet.SwapBehavior)
+	}
+
+	return nil
+}
+
+// validateMachineIdentity validates the machine identity source. An identity other than the hostname names
+// both the Arc machine and the node, so it cannot be combined with names configured explicitly.
+func (c *Config) validateMachineIdentity() error {
+	arc := c.Azure.Arc
+	if arc == nil {
+		return nil
+	}
+	if arc.MachineIDSource != "" && !validMachineIDSources[arc.MachineIDSource] {
+		return fmt.Errorf("invalid azure.arc.machineIdSource: %s. Valid values are: hostname, smbios, config", arc.MachineIDSource)
+	}
+	if arc.MachineID != "" && arc.MachineIDSource != MachineIDSourceConfig {
+		return fmt.Errorf("azure.arc.machineId requires azure.arc.machineIdSource to be config")
+	}
+	if arc.MachineIDSource == "" || arc.MachineIDSource == MachineIDSourceHostname {
+		return nil
+	}
+
+	if arc.MachineName != "" {
+		return fmt.Errorf("azure.arc.machineName cannot be combined with azure.arc.machineIdSource %s, which names the Arc machine", arc.MachineIDSource)
+	}
+	if c.Node.HostnameOverride != "" {
+		return fmt.Errorf("node.hostnameOverride cannot be combined with azure.arc.machineIdSource %s, which names the node", arc.MachineIDSource)
+	}
+	if arc.MachineIDSource == MachineIDSourceConfig && !guidPattern.MatchString(arc.MachineID) {
+		return fmt.Errorf("invalid azure.arc.machineId: %q. azure.arc.machineIdSource config requires a GUID", arc.MachineID)
+	}
+	return nil
+}
+
+// validateAuthentication validates the authentication methods. A service principal and a workload identity
+// both sign in as an Entra ID application, so only one of them may be configured.
+func (c *Config) validateAuthentication() error {
+	wi := c.Azure.WorkloadIdentity
+	if wi == nil {
+		return nil
+	}
+	if c.Azure.ServicePrincipal != nil {
+		return fmt.Errorf("azure.servicePrincipal and azure.workloadIdentity are mutually exclusive, configure one of them")
+	}
+	if wi.TenantID == "" || wi.ClientID == "" || wi.TokenFile == "" {
+		return fmt.Errorf("azure.workloadIdentity requires tenantId, clientId and tokenFile")
+	}
+	if !guidPattern.MatchString(wi.ClientID) {
+		return fmt.Errorf("invalid azure.workloadIdentity.clientId: %q. Must be a GUID", wi.ClientID)
+	}
+	if !filepath.IsAbs(wi.TokenFile) {
+		return fmt.Errorf("invalid azure.workloadIdentity.tokenFile: %s. Must be an absolute path", wi.TokenFile)
+	}
+	return nil
+}
+
+// validateKeyVault validates the Key Vault secrets are fetched from
+func (c *Config) validateKeyVault() error {
+	kv := c.Azure.KeyVault
+	if kv == nil {
+		return nil
+	}
+	vaultURL, err := url.Parse(kv.VaultURL)
+	if err != nil || vaultURL.Scheme != "https" || vaultURL.Host == "" || strings.Trim(vaultURL.Path, "/") != "" {
+		return fmt.Errorf("invalid azure.keyVault.vaultUrl: %q. Must be the https URL of the vault, e.g. https://flex-secrets.vault.azure.net", kv.VaultURL)
+	}
+	if kv.ClientID != "" && !guidPattern.MatchString(kv.ClientID) {
+		return fmt.Errorf("invalid azure.keyVault.clientId: %q. Must be a GUID", kv.ClientID)
+	}
+	return nil
+}
+
+// applyMachineIdentity sets the Arc machine name and the node hostname override to the machine identity
+func (c *Config) applyMachineIdentity() error {
+	if c.Azure.Arc == nil {
+		return nil
+	}
+	var machineID string
+	switch c.Azure.Arc.MachineIDSource {
+	case MachineIDSourceConfig:
+		machineID = c.Azure.Arc.MachineID
+	case MachineIDSourceSMBIOS:
+		id, err := readSMBIOSUUID()
+		if err != nil {
+			return err
+		}
+		machineID = id
+	default:
+		return nil
+	}
+	// Node names must be lowercase, use the same form for the Arc machine
+	machineID = strings.ToLower(machineID)
+	c.Azure.Arc.MachineName = machineID
+	c.Node.HostnameOverride = machineID
+	return nil
+}
+
+// readSMBIOSUUID reads the SMBIOS system UUID, rejecting placeholders that do not identify a device
+func readSMBIOSUUID() (string, error) {
+	output, err := utils.RunCommandWithOutput("cat", smbiosUUIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the SMBIOS system UUID from %s for azure.arc.machineIdSource smbios: %w", smbiosUUIDPath, err)
+	}
+	uuid := strings.ToLower(strings.TrimSpace(output))
+	if !guidPattern.MatchString(uuid) {
+		return "", fmt.Errorf("SMBIOS system UUID %q in %s is not a GUID, use azure.arc.machineIdSource config instead", uuid, smbiosUUIDPath)
+	}
+	if placeholderSMBIOSUUIDs[uuid] {
+		return "", fmt.Errorf("SMBIOS system UUID %s is a placeholder the firmware vendor did not replace, "+
+			"use azure.arc.machineIdSource config instead", uuid)
+	}
+	return uuid, nil
+}
+
+// validateKubeletPassthrough validates the feature gates and extra flags handed to kubelet unchanged.
+// Extra flags may not weaken kubelet authentication or TLS, nor override flags the agent manages.
+func (c *Config) validateKubeletPassthrough() error {
+	gates := make(map[string]bool)
+	for _, gate := range c.Node.Kubelet.FeatureGates {
+		match := featureGatePattern.FindStringSubmatch(gate)
+		if match == nil {
+			return fmt.Errorf("invalid node.kubelet.featureGates entry: %s. Expected format: Name=true or Name=false with a CamelCase name, such as KubeletTracing=true", gate)
+		}
+		if gates[match[1]] {
+			return fmt.Errorf("node.kubelet.featureGates sets %s more than once", match[1])
+		}
+		gates[match[1]] = true
+	}
+
+	for _, flag := range c.Node.Kubelet.ExtraFlags {
+		match := kubeletFlagPattern.FindStringSubmatch(flag)
+		if match == nil {
+			return fmt.Errorf("invalid node.kubelet.extraFlags entry: %s. Expected format: --name or --name=value without spaces or quotes", flag)
+		}
+		if setting, denied := deniedKubeletFlags[match[1]]; denied {
+			if setting != "" {
+				return fmt.Errorf("node.kubelet.extraFlags must not set --%s, use %s instead", match[1], setting)
+			}
+			return fmt.Errorf("node.kubelet.extraFlags must not set --%s, which the agent manages", match[1])
+		}
+	}
+	if c.Node.Kubelet.ApproveServingCSR && !c.Node.Kubelet.ServerTLSBootstrap {
+		return fmt.Errorf("node.kubelet.approveServingCSR requires node.kubelet.serverTLSBootstrap")
+	}
+	return nil
+}
+
+// validateDrain validates how unbootstrap drains the node
+// validateAutoUpgrade validates kubernetes.autoUpgrade, which upgrades to the version the cluster runs and so
+// cannot be combined with a pinned kubernetes.version
+func (c *Config) validateAutoUpgrade() error {
+	switch c.Kubernetes.AutoUpgrade {
+	case "", AutoUpgradeNone:
+		return nil
+	case AutoUpgradePatch, AutoUpgradeMinor:
+	default:
+		return fmt.Errorf("invalid kubernetes.autoUpgrade: %s. Valid values are: %s, %s, %s",
+			c.Kubernetes.AutoUpgrade, AutoUpgradeNone, AutoUpgradePatch, AutoUpgradeMinor)
+	}
+	if !c.IsKubernetesVersionAuto() {
+		return fmt.Errorf("kubernetes.autoUpgrade %s requires kubernetes.version auto, the node would be upgraded away from %s",
+			c.Kubernetes.AutoUpgrade, c.Kubernetes.Version)
+	}
+	return nil
+}
+
+func (c *Config) validateMaintenance() error {
+	m := c.Maintenance
+	var durations []time.Duration
+	if mw := c.Agent.MaintenanceWindow; mw != nil {
+		// Validated with the agent settings
+		if window, err := maintenance.NewWindow(mw.Schedule, mw.Duration, mw.TimeZone); err == nil {
+			durations = append(durations, window.Duration())
+		}
+	}
+	for i, mw := range m.Windows {
+		window, err := maintenance.NewWindow(mw.Schedule, mw.Duration, mw.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid maintenance.windows[%d]: %w", i, err)
+		}
+		durations = append(durations, window.Duration())
+	}
+
+	if m.MaxDisruption != "" {
+		maxDisruption, err := time.ParseDuration(m.MaxDisruption)
+		if err != nil || maxDisruption <= 0 {
+			return fmt.Errorf("invalid maintenance.maxDisruption: %s. Must be a positive duration such as 1h", m.MaxDisruption)
+		}
+		if len(durations) == 0 {
+			return fmt.Errorf("maintenance.maxDisruption requires maintenance.windows or agent.maintenanceWindow")
+		}
+		for _, d := range durations {
+			if maxDisruption > d {
+				return fmt.Errorf("maintenance.maxDisruption %s exceeds a maintenance window of %s, actions would never start in it", maxDisruption, d)
+			}
+		}
+	}
+
+	for _, list := range []struct {
+		name    string
+		actions []string
+	}{{"allow", m.Allow}, {"deny", m.Deny}} {
+		for _, action := range list.actions {
+			if !slices.Contains(MaintenanceActions, action) {
+				return fmt.Errorf("invalid maintenance.%s action: %s. Valid values are: %s", list.name, action, strings.Join(MaintenanceActions, ", "))
+			}
+		}
+	}
+	for _, action := range m.Deny {
+		if slices.Contains(m.Allow, action) {
+			return fmt.Errorf("maintenance action %s is both allowed and denied", action)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateDrain() error {
+	drain := c.Node.Drain
+	if drain == nil {
+		return nil
+	}
+	if drain.GracePeriod != "" {
+		if d, err := time.ParseDuration(drain.GracePeriod); err != nil || d < 0 {
+			return fmt.Errorf("invalid node.drain.gracePeriod: %s. Must be a duration such as 30s", drain.GracePeriod)
+		}
+	}
+	if drain.Timeout != "" {
+		if d, err := time.ParseDuration(drain.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid node.drain.timeout: %s. Must be a positive duration such as 10m", drain.Timeout)
+		}
+	}
+	return nil
+}
+
+// maxRetryAttempts keeps a permanently failing download or API call from holding up the daemon for hours
+const maxRetryAttempts = 20
+
+// validateRetry validates how downloads and Azure API calls are retried
+func (c *Config) validateRetry() error {
+	rc := c.Agent.Retry
+	if rc == nil {
+		return nil
+	}
+	if rc.Attempts < 0 || rc.Attempts > maxRetryAttempts {
+		return fmt.Errorf("invalid agent.retry.attempts: %d. Must be between 1 and %d", rc.Attempts, maxRetryAttempts)
+	}
+	for _, field := range []struct{ name, value string }{
+		{"initialDelay", rc.InitialDelay},
+		{"maxDelay", rc.MaxDelay},
+		{"attemptTimeout", rc.AttemptTimeout},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(field.value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid agent.retry.%s: %s. Must be a positive duration", field.name, field.value)
+		}
+	}
+	if policy := c.GetRetryPolicy(); policy.InitialDelay > policy.MaxDelay {
+		return fmt.Errorf("invalid agent.retry.initialDelay: %s. Must not exceed maxDelay %s", policy.InitialDelay, policy.MaxDelay)
+	}
+	return nil
+}
+
+// validateChecksums validates the checksums pinned for component downloads
+func (c *Config) validateChecksums() error {
+	for _, component := range []struct {
+		name     string
+		checksum *ChecksumConfig
+	}{
+		{"containerd", c.Containerd.Checksum},
+		{"kubernetes", c.Kubernetes.Checksum},
+		{"cni", c.CNI.Checksum},
+		{"runc", c.Runc.Checksum},
+		{"npd", c.Npd.Checksum},
+	} {
+		checksum := component.checksum
+		if checksum == nil {
+			continue
+		}
+		switch {
+		case checksum.SHA256 == "" && checksum.URL == "":
+			return fmt.Errorf("%s.checksum requires sha256 or url", component.name)
+		case checksum.SHA256 != "" && checksum.URL != "":
+			return fmt.Errorf("%s.checksum.sha256 cannot be combined with %s.checksum.url", component.name, component.name)
+		case checksum.SHA256 != "" && !sha256Pattern.MatchString(checksum.SHA256):
+			return fmt.Errorf("invalid %s.checksum.sha256: %s. Must be 64 hexadecimal characters", component.name, checksum.SHA256)
+		case checksum.URL != "" && !strings.HasPrefix(checksum.URL, "https://") && !strings.HasPrefix(checksum.URL, "http://"):
+			return fmt.Errorf("invalid %s.checksum.url: %s. Must be an HTTP(S) URL", component.name, checksum.URL)
+		}
+	}
+	return nil
+}
+
+// validateKubeVIP validates the kube-vip load balancer settings when it is enabled
+func (c *Config) validateKubeVIP() error {
+	if !c.KubeVIP.Enabled {
+		return nil
+	}
+	if c.KubeVIP.AddressRange == "" {
+		return fmt.Errorf("kubeVip.addressRange is required when kubeVip is enabled")
+	}
+	for _, addressRange := range strings.Split(c.KubeVIP.AddressRange, ",") {
+		if err := validateIPv4Range(strings.TrimSpace(addressRange)); err != nil {
+			return fmt.Errorf("invalid kubeVip.addressRange entry %s: %w", addressRange, err)
+		}
+	}
+	if c.KubeVIP.ServiceNamespace != "" && !namespacePattern.MatchString(c.KubeVIP.ServiceNamespace) {
+		return fmt.Errorf("invalid kubeVip.serviceNamespace: %s. Must be a valid namespace name", c.KubeVIP.ServiceNamespace)
+	}
+	return nil
+}
+
+// validateNPD validates the monitors Node Problem Detector runs
+func (c *Config) validateNPD() error {
+	valid := []string{NPDMonitorKernel, NPDMonitorSystemd, NPDMonitorSystemStats}
+	for i, monitor := range c.Npd.Monitors {
+		if !slices.Contains(valid, monitor) {
+			return fmt.Errorf("invalid npd.monitors entry: %s. Valid monitors are: %s", monitor, strings.Join(valid, ", "))
+		}
+		if slices.Contains(c.Npd.Monitors[:i], monitor) {
+			return fmt.Errorf("duplicate npd.monitors entry: %s", monitor)
+		}
+	}
+	for i, path := range c.Npd.CustomPluginConfigs {
+		if !filepath.IsAbs(path) || strings.Contains(path, ",") {
+			return fmt.Errorf("invalid npd.customPluginConfigs[%d]: %q. Must be an absolute path without commas", i, path)
+		}
+	}
+	return nil
+}
+
+// validateKubeProxy validates the kube-proxy settings when the agent runs kube-proxy
+func (c *Config) validateKubeProxy() error {
+	if !c.KubeProxy.Enabled {
+		return nil
+	}
+	if c.CNI.KubeProxyReplacement {
+		return fmt.Errorf("kubeProxy.enabled cannot be combined with cni.kubeProxyReplacement, as Cilium replaces kube-proxy")
+	}
+	switch c.KubeProxy.Mode {
+	case "", KubeProxyModeIPTables, KubeProxyModeIPVS, KubeProxyModeNFTables:
+		return nil
+	default:
+		return fmt.Errorf("invalid kubeProxy.mode: %s. Valid modes are: %s, %s, %s",
+			c.KubeProxy.Mode, KubeProxyModeIPTables, KubeProxyModeIPVS, KubeProxyModeNFTables)
+	}
+}
+
+// validateNodeLocalDNS validates the node-local DNS cache settings when it is enabled
+func (c *Config) validateNodeLocalDNS() error {
+	if !c.NodeLocalDNS.Enabled {
+		return nil
+	}
+	// The cache must not take an address pods or nodes can otherwise reach
+	ip := net.ParseIP(c.NodeLocalDNS.LocalIP)
+	if ip == nil || ip.To4() == nil || !ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("invalid nodeLocalDns.localIP: %s. Must be a link-local IPv4 address such as %s", c.NodeLocalDNS.LocalIP, DefaultNodeLocalDNSIP)
+	}
+	if c.Node.Kubelet.DNSServiceIP == "" {
+		return fmt.Errorf("nodeLocalDns requires node.kubelet.dnsServiceIP, the cluster DNS service the cache forwards to")
+	}
+	return nil
+}
+
+// validateSystem validates the host kernel parameters, swap policy and kernel modules
+func (c *Config) validateSystem() error {
+	for key, value := range c.System.Sysctls {
+		if !sysctlKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid system.sysctls key: %s. Must be a kernel parameter name such as fs.file-max", key)
+		}
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("invalid system.sysctls value of %s: %q. Must be a non-empty single line", key, value)
+		}
+	}
+	switch c.System.Swap {
+	case "", SwapPolicyKeep:
+	case SwapPolicyDisable:
+		if c.Node.Kubelet.SwapBehavior != "" {
+			return fmt.Errorf("system.swap disable cannot be combined with node.kubelet.swapBehavior, which runs kubelet with swap")
+		}
+	default:
+		return fmt.Errorf("invalid system.swap: %s. Valid values are: %s, %s", c.System.Swap, SwapPolicyKeep, SwapPolicyDisable)
+	}
+	for _, module := range c.System.KernelModules {
+		if !kernelModulePattern.MatchString(module) {
+			return fmt.Errorf("invalid system.kernelModules entry: %q. Must be a kernel module name", module)
+		}
+	}
+	return nil
+}
+
+// validateTimeSync validates the NTP servers and the drift the status file warns about
+func (c *Config) validateTimeSync() error {
+	if len(c.TimeSync.Servers) > 0 && !c.TimeSync.Enabled {
+		return fmt.Errorf("timeSync.servers requires timeSync.enabled, the agent only configures the time synchronization service it manages")
+	}
+	for _, server := range c.TimeSync.Servers {
+		if net.ParseIP(server) == nil && !nodeNamePattern.MatchString(strings.ToLower(server)) {
+			return fmt.Errorf("invalid timeSync.servers entry: %q. Must be a host name or IP address", server)
+		}
+	}
+	if c.TimeSync.MaxDrift != "" {
+		if d, err := time.ParseDuration(c.TimeSync.MaxDrift); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeSync.maxDrift: %s. Must be a positive duration such as 500ms", c.TimeSync.MaxDrift)
+		}
+	}
+	return nil
+}
+
+// validateStorage validates the data disk or directory the containerd and kubelet data is placed on
+func (c *Config) validateStorage() error {
+	storage := c.Storage
+	if storage.Device != "" && storage.Path != "" {
+		return fmt.Errorf("storage.device and storage.path cannot be combined, set the device or the directory holding the data")
+	}
+	if storage.Device != "" && !strings.HasPrefix(filepath.Clean(storage.Device), "/dev/") {
+		return fmt.Errorf("invalid storage.device: %s. Must be a block device below /dev", storage.Device)
+	}
+	if (storage.Format || storage.MountPath != "") && storage.Device == "" {
+		return fmt.Errorf("storage.format and storage.mountPath require storage.device")
+	}
+	switch storage.Filesystem {
+	case "", "ext4", "xfs":
+	default:
+		return fmt.Errorf("invalid storage.filesystem: %s. Valid values are: ext4, xfs", storage.Filesystem)
+	}
+	for name, path := range map[string]string{"storage.mountPath": storage.MountPath, "storage.path": storage.Path} {
+		if path == "" {
+			continue
+		}
+		clean := filepath.Clean(path)
+		if !filepath.IsAbs(path) || clean == "/" {
+			return fmt.Errorf("invalid %s: %s. Must be an absolute path other than /", name, path)
+		}
+		// The data directories are bind mounted from below the path, which cannot be inside them
+		for _, dataDir := range []string{"/var/lib/containerd", "/var/lib/kubelet"} {
+			if clean == dataDir || strings.HasPrefix(clean, dataDir+"/") {
+				return fmt.Errorf("invalid %s: %s. Must not be inside %s", name, path, dataDir)
+			}
+		}
+	}
+	return nil
+}
+
+// validateHealthChecks validates the custom health probes
+func (c *Config) validateHealthChecks() error {
+	names := make(map[string]bool)
+	for _, check := range c.HealthChecks {
+		if !healthCheckNamePattern.MatchString(check.Name) {
+			return fmt.Errorf("invalid healthChecks name: %q. Must be 1-63 letters, digits, '.', '_' or '-'", check.Name)
+		}
+		if names[check.Name] {
+			return fmt.Errorf("duplicate healthChecks name: %s", check.Name)
+		}
+		names[check.Name] = true
+
+		if (len(check.Command) == 0) == (check.URL == "") {
+			return fmt.Errorf("healthChecks %s: exactly one of command or url must be set", check.Name)
+		}
+		if len(check.Command) > 0 && !filepath.IsAbs(check.Command[0]) {
+			return fmt.Errorf("healthChecks %s: command must start with an absolute path, got %s", check.Name, check.Command[0])
+		}
+		if check.URL != "" {
+			u, err := url.Parse(check.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("healthChecks %s: invalid url %s. Must be an http or https URL", check.Name, check.URL)
+			}
+			if check.ExpectedStatus < 100 || check.ExpectedStatus > 599 {
+				return fmt.Errorf("healthChecks %s: invalid expectedStatus %d", check.Name, check.ExpectedStatus)
+			}
+		}
+		timeout, err := time.ParseDuration(check.Timeout)
+		if err != nil || timeout <= 0 || timeout > maxHealthCheckTimeout {
+			return fmt.Errorf("healthChecks %s: invalid timeout %s. Must be a duration up to %s", check.Name, check.Timeout, maxHealthCheckTimeout)
+		}
+		if !validHealthCheckActions[check.Action] {
+			return fmt.Errorf("healthChecks %s: invalid action %s. Valid values are: report, remediate, gate", check.Name, check.Action)
+		}
+	}
+	return nil
+}
+
+// validateIPv4Range validates an inclusive IPv4 address range in start-end format
+func validateIPv4Range(addressRange string) error {
+	start, end, found := strings.Cut(addressRange, "-")
+	if !found {
+		return fmt.Errorf("expected format <start-ip>-<end-ip>")
+	}
+	startIP := net.ParseIP(strings.TrimSpace(start)).To4()
+	endIP := net.ParseIP(strings.TrimSpace(end)).To4()
+	if startIP == nil || endIP == nil {
+		return fmt.Errorf("range bounds must be IPv4 addresses")
+	}
+	if bytes.Compare(startIP, endIP) > 0 {
+		return fmt.Errorf("range start %s is after range end %s", startIP, endIP)
+	}
 	return nil
 }
 
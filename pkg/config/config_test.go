@@ -3,8 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetDefaults(t *testing.T) {
@@ -20,6 +22,7 @@ func TestSetDefaults(t *testing.T) {
 				return c.Azure.Cloud == "AzurePublicCloud" &&
 					c.Agent.LogLevel == "info" &&
 					c.Agent.LogDir == "/var/log/aks-flex-node" &&
+					c.Agent.LogFormat == "text" &&
 					c.Paths.Kubernetes.ConfigDir == "/etc/kubernetes" &&
 					c.Node.MaxPods == 110 &&
 					c.Runc.Version == "1.1.12"
@@ -57,6 +60,54 @@ func TestSetDefaults(t *testing.T) {
 					c.Node.Kubelet.EvictionHard != nil
 			},
 		},
+		{
+			name:   "DNS service IP defaults to the AKS cluster DNS IP",
+			config: &Config{},
+			want: func(c *Config) bool {
+				return c.Node.Kubelet.DNSServiceIP == "10.0.0.10"
+			},
+		},
+		{
+			name: "DNS service IP is derived from the service CIDR",
+			config: &Config{
+				Node: NodeConfig{ServiceCIDR: "172.20.0.0/16"},
+			},
+			want: func(c *Config) bool {
+				return c.Node.Kubelet.DNSServiceIP == "172.20.0.10"
+			},
+		},
+		{
+			name: "GPU nodes get the accelerator labels",
+			config: &Config{
+				Node: NodeConfig{
+					Labels: map[string]string{"nvidia.com/gpu.present": "false"},
+					GPU:    &GPUConfig{Enabled: true},
+				},
+			},
+			want: func(c *Config) bool {
+				return c.Node.Labels["kubernetes.azure.com/accelerator"] == "nvidia" &&
+					c.Node.Labels["nvidia.com/gpu.present"] == "false" // preserved
+			},
+		},
+		{
+			name:   "node is marked unmanaged by default",
+			config: &Config{},
+			want: func(c *Config) bool {
+				return c.Node.Labels["kubernetes.azure.com/managed"] == "false"
+			},
+		},
+		{
+			name: "node is left managed with external cloud provider",
+			config: &Config{
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{CloudProvider: "external"},
+				},
+			},
+			want: func(c *Config) bool {
+				_, ok := c.Node.Labels["kubernetes.azure.com/managed"]
+				return !ok
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,7 +128,2316 @@ func TestValidate(t *testing.T) {
 		errMsg  string
 	}{
 		{
-			name: "valid config passes",
+			name: "valid config passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing subscription ID fails",
+			config: &Config{
+				Azure: AzureConfig{
+					TenantID: "12345678-1234-1234-1234-123456789012",
+					Cloud:    "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "azure.subscriptionId is required",
+		},
+		{
+			name: "missing tenant ID fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "azure.tenantId is required",
+		},
+		{
+			name: "missing target cluster location fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "azure.targetCluster.location is required",
+		},
+		{
+			name: "missing target cluster resource ID fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						Location: "eastus",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "azure.targetCluster.resourceId is required",
+		},
+		{
+			name: "invalid resource ID format fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "invalid-resource-id",
+						Location:   "eastus",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid azure.targetCluster.resourceId:",
+		},
+		{
+			name: "invalid azure cloud fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "InvalidCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid azure.cloud: InvalidCloud. Valid values are: AzurePublicCloud",
+		},
+		{
+			name: "invalid log level fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "invalid",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid agent.logLevel: invalid. Valid values are: debug, info, warning, error",
+		},
+		{
+			name: "invalid hostname override fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					HostnameOverride: "edge_node_01",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.hostnameOverride: edge_node_01",
+		},
+		{
+			name: "invalid cloud provider fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{CloudProvider: "azure"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.cloudProvider: azure",
+		},
+		{
+			name: "external cloud provider with taints passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Taints:  []string{"edge=true:NoSchedule", "dedicated:NoExecute"},
+					Kubelet: KubeletConfig{CloudProvider: "external", ProviderID: "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.HybridCompute/machines/edge-01"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid taint fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Taints: []string{"edge=true"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.taints entry: edge=true",
+		},
+		{
+			name: "invalid node managed prefix fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					ManagedPrefixes: []string{" example.com/"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.managedPrefixes entry",
+		},
+		{
+			name: "invalid topology manager policy fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						TopologyManagerPolicy: "numa",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.topologyManagerPolicy: numa",
+		},
+		{
+			name: "invalid reserved system CPUs fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						CPUManagerPolicy:   "static",
+						ReservedSystemCPUs: "0-a",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.reservedSystemCPUs",
+		},
+		{
+			name: "static CPU manager without reservation fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						CPUManagerPolicy: "static",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "cpuManagerPolicy static requires",
+		},
+		{
+			name: "static memory manager without reserved memory fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						MemoryManagerPolicy: "Static",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "memoryManagerPolicy Static requires node.kubelet.reservedMemory",
+		},
+		{
+			name: "invalid reserved memory fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						MemoryManagerPolicy: "Static",
+						ReservedMemory:      "memory=1Gi",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.reservedMemory entry",
+		},
+		{
+			name: "valid topology aware config passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						CPUManagerPolicy:      "static",
+						TopologyManagerPolicy: "single-numa-node",
+						ReservedSystemCPUs:    "0-1",
+						MemoryManagerPolicy:   "Static",
+						ReservedMemory:        "0:memory=1Gi;1:memory=1Gi",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "kube-vip without address range fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				KubeVIP: KubeVIPConfig{
+					Enabled: true,
+				},
+			},
+			wantErr: true,
+			errMsg:  "kubeVip.addressRange is required",
+		},
+		{
+			name: "kube-vip with CIDR address range fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				KubeVIP: KubeVIPConfig{
+					Enabled:      true,
+					AddressRange: "192.168.10.0/24",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid kubeVip.addressRange entry",
+		},
+		{
+			name: "kube-vip with reversed address range fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				KubeVIP: KubeVIPConfig{
+					Enabled:      true,
+					AddressRange: "192.168.10.220-192.168.10.200",
+				},
+			},
+			wantErr: true,
+			errMsg:  "is after range end",
+		},
+		{
+			name: "kube-vip with invalid service namespace fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				KubeVIP: KubeVIPConfig{
+					Enabled:          true,
+					AddressRange:     "192.168.10.200-192.168.10.220",
+					ServiceNamespace: "Edge_Site",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid kubeVip.serviceNamespace",
+		},
+		{
+			name: "valid kube-vip config passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				KubeVIP: KubeVIPConfig{
+					Enabled:          true,
+					Interface:        "eth1",
+					AddressRange:     "192.168.10.200-192.168.10.220, 192.168.11.10-192.168.11.10",
+					ServiceNamespace: "store-42",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid maintenance window schedule fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					MaintenanceWindow: &MaintenanceWindowConfig{
+						Schedule: "0 25 * * *",
+						Duration: "4h",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid agent.maintenanceWindow",
+		},
+		{
+			name: "valid maintenance window passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					MaintenanceWindow: &MaintenanceWindowConfig{
+						Schedule: "0 2 * * sat,sun",
+						Duration: "4h",
+						TimeZone: "UTC",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative offline package directory fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Packages: PackagesConfig{
+					OfflineDir: "packages",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid packages.offlineDir",
+		},
+		{
+			name: "invalid additional package fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Packages: PackagesConfig{
+					Additional: []string{"socat conntrack"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid packages.additional entry",
+		},
+		{
+			name: "health check with command and url fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				HealthChecks: []HealthCheckConfig{
+					{
+						Name:           "vpn",
+						Command:        []string{"/usr/local/bin/check-vpn"},
+						URL:            "http://127.0.0.1:8080/healthz",
+						ExpectedStatus: 200,
+						Timeout:        "5s",
+						Action:         "gate",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "exactly one of command or url must be set",
+		},
+		{
+			name: "valid health checks pass",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				HealthChecks: []HealthCheckConfig{
+					{
+						Name:    "data-mount",
+						Command: []string{"/usr/bin/mountpoint", "-q", "/mnt/data"},
+						Timeout: "5s",
+						Action:  "remediate",
+					},
+					{
+						Name:           "vpn",
+						URL:            "http://127.0.0.1:8080/healthz",
+						ExpectedStatus: 204,
+						Timeout:        "10s",
+						Action:         "gate",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid swap behavior fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						SwapBehavior: "UnlimitedSwap",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.swapBehavior",
+		},
+		{
+			name: "invalid kubelet key protection fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						KeyProtection: "pkcs11",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.keyProtection",
+		},
+		{
+			name: "relative kubelet resolv.conf fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						ResolvConf: "resolv.conf",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.resolvConf",
+		},
+		{
+			name: "containerd gc pause threshold above half fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					GC: &ContainerdGCConfig{PauseThreshold: 0.8},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid containerd.gc.pauseThreshold",
+		},
+		{
+			name: "registry with scheme fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					Registries: []RegistryConfig{{Host: "https://registry.local", Mirrors: []string{"https://mirror.local"}}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid containerd.registries[0].host",
+		},
+		{
+			name: "registry mirror without scheme fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					Registries: []RegistryConfig{{Host: "docker.io", Mirrors: []string{"mirror.local:5000"}}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid containerd.registries[0].mirrors entry",
+		},
+		{
+			name: "registry username without password fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					Registries: []RegistryConfig{{Host: "registry.local:5000", Username: "puller"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "username and password must be set together",
+		},
+		{
+			name: "invalid kube-proxy mode fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				KubeProxy: KubeProxyConfig{
+					Enabled: true,
+					Mode:    "userspace",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid kubeProxy.mode",
+		},
+		{
+			name: "kube-proxy with cilium kube-proxy replacement fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				CNI: CNIConfig{
+					KubeProxyReplacement: true,
+				},
+				KubeProxy: KubeProxyConfig{
+					Enabled: true,
+				},
+			},
+			wantErr: true,
+			errMsg:  "cannot be combined with cni.kubeProxyReplacement",
+		},
+		{
+			name: "node-local dns on a routable address fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						DNSServiceIP: "10.0.0.10",
+					},
+				},
+				NodeLocalDNS: NodeLocalDNSConfig{
+					Enabled: true,
+					LocalIP: "10.0.0.53",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid nodeLocalDns.localIP",
+		},
+		{
+			name: "node-local dns without dns service ip fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				NodeLocalDNS: NodeLocalDNSConfig{
+					Enabled: true,
+					LocalIP: DefaultNodeLocalDNSIP,
+				},
+			},
+			wantErr: true,
+			errMsg:  "nodeLocalDns requires node.kubelet.dnsServiceIP",
+		},
+		{
+			name: "invalid sysctl key fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				System: SystemConfig{
+					Sysctls: map[string]string{"/proc/sys/fs/file-max": "1048576"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid system.sysctls key",
+		},
+		{
+			name: "swap disable with swapBehavior fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						SwapBehavior: "NoSwap",
+					},
+				},
+				System: SystemConfig{
+					Swap: SwapPolicyDisable,
+				},
+			},
+			wantErr: true,
+			errMsg:  "system.swap disable cannot be combined with node.kubelet.swapBehavior",
+		},
+		{
+			name: "ntp servers without managed time sync fail",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				TimeSync: TimeSyncConfig{
+					Servers: []string{"ntp.contoso.local"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "timeSync.servers requires timeSync.enabled",
+		},
+		{
+			name: "invalid time sync max drift fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				TimeSync: TimeSyncConfig{
+					MaxDrift: "1 second",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid timeSync.maxDrift",
+		},
+		{
+			name: "storage device and path fail",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Storage: StorageConfig{
+					Device: "/dev/nvme1n1",
+					Path:   "/srv/flex",
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage.device and storage.path cannot be combined",
+		},
+		{
+			name: "storage path inside kubelet directory fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Storage: StorageConfig{
+					Path: "/var/lib/kubelet/data",
+				},
+			},
+			wantErr: true,
+			errMsg:  "Must not be inside /var/lib/kubelet",
+		},
+		{
+			name: "non-loopback diagnostics address fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel:           "info",
+					DiagnosticsAddress: "0.0.0.0:10265",
+				},
+			},
+			wantErr: true,
+			errMsg:  "Must listen on a loopback address",
+		},
+		{
+			name: "unknown kubernetes auto-upgrade policy fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Kubernetes: KubernetesConfig{
+					AutoUpgrade: "major",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid kubernetes.autoUpgrade: major",
+		},
+		{
+			name: "kubernetes auto-upgrade with a pinned version fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Kubernetes: KubernetesConfig{
+					Version:     "1.31.2",
+					AutoUpgrade: AutoUpgradePatch,
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires kubernetes.version auto",
+		},
+		{
+			name: "maintenance policy is valid",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Maintenance: MaintenanceConfig{
+					Windows:       []MaintenanceWindowConfig{{Schedule: "0 2 * * sat,sun", Duration: "4h"}},
+					MaxDisruption: "1h",
+					Deny:          []string{MaintenanceActionUpgrade},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid maintenance window fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Maintenance: MaintenanceConfig{
+					Windows: []MaintenanceWindowConfig{{Schedule: "0 2 * *", Duration: "4h"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid maintenance.windows[0]",
+		},
+		{
+			name: "maintenance max disruption longer than a window fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Maintenance: MaintenanceConfig{
+					Windows:       []MaintenanceWindowConfig{{Schedule: "0 2 * * *", Duration: "1h"}},
+					MaxDisruption: "2h",
+				},
+			},
+			wantErr: true,
+			errMsg:  "exceeds a maintenance window",
+		},
+		{
+			name: "maintenance max disruption without a window fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Maintenance: MaintenanceConfig{
+					MaxDisruption: "1h",
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires maintenance.windows",
+		},
+		{
+			name: "unknown maintenance action fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Maintenance: MaintenanceConfig{
+					Allow: []string{"reboot"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid maintenance.allow action: reboot",
+		},
+		{
+			name: "maintenance action both allowed and denied fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Maintenance: MaintenanceConfig{
+					Allow: []string{MaintenanceActionRebootstrap},
+					Deny:  []string{MaintenanceActionRebootstrap},
+				},
+			},
+			wantErr: true,
+			errMsg:  "both allowed and denied",
+		},
+		{
+			name: "invalid profile hostname pattern fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Profiles: map[string]ProfileConfig{
+					"gpu": {Hostnames: []string{"gpu-[0-9"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid profiles.gpu.hostnames entry",
+		},
+		{
+			name: "invalid profile taint fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Profiles: map[string]ProfileConfig{
+					"gpu": {Taints: []string{"dedicated=gpu"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid profiles.gpu.taints entry",
+		},
+		{
+			name: "unknown npd monitor fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Npd: NPDConfig{
+					Monitors: []string{"kernel", "docker"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid npd.monitors entry: docker",
+		},
+		{
+			name: "relative npd custom plugin config fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Npd: NPDConfig{
+					CustomPluginConfigs: []string{"plugins/ntp.json"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid npd.customPluginConfigs[0]",
+		},
+		{
+			name: "containerd config patch that is not TOML fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					ConfigPatch: "[plugins\n",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid containerd.configPatch",
+		},
+		{
+			name: "containerd config patch of the version fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					ConfigPatch: "version = 3",
+				},
+			},
+			wantErr: true,
+			errMsg:  "version cannot be patched",
+		},
+		{
+			name: "relative containerd config import fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					ConfigImports: []string{"conf.d/*.toml"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid containerd.configImports[0]",
+		},
+		{
+			name: "invalid iptables backend fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					IPTablesBackend: "nftables",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.iptablesBackend",
+		},
+		{
+			name: "kubelet API burst below QPS fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{KubeAPIQPS: 20, KubeAPIBurst: 10},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.kubeAPIBurst",
+		},
+		{
+			name: "egress IP endpoint without scheme fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel:         "info",
+					EgressIPEndpoint: "ifconfig.example.com",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid agent.egressIPEndpoint",
+		},
+		{
+			name: "API server IP that is not an IP address fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID:  "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:    "eastus",
+						APIServerIP: "mycluster.privatelink.eastus.azmk8s.io",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid azure.targetCluster.apiServerIP",
+		},
+		{
+			name: "API server FQDN without IP fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID:    "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:      "eastus",
+						APIServerFQDN: "mycluster.privatelink.eastus.azmk8s.io",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires azure.targetCluster.apiServerIP",
+		},
+		{
+			name: "negative download cache size fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				DownloadCache: DownloadCacheConfig{MaxSizeMB: -1},
+			},
+			wantErr: true,
+			errMsg:  "invalid downloadCache.maxSizeMB",
+		},
+		{
+			name: "API address without mutual TLS fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					API:      &APIConfig{Address: "0.0.0.0:7443", TLSCertFile: "/etc/aks-flex-node/api.crt", TLSKeyFile: "/etc/aks-flex-node/api.key"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires agent.api.tlsCertFile",
+		},
+		{
+			name: "relative API socket path fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					API:      &APIConfig{SocketPath: "api.sock"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid agent.api.socketPath",
+		},
+		{
+			name: "relative staging directory fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Paths: PathsConfig{
+					StagingDir: "var/tmp",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid paths.stagingDir",
+		},
+		{
+			name: "maxPods beyond pod CIDR capacity fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					MaxPods: 110,
+				},
+				CNI: CNIConfig{
+					PodCIDR: "10.244.0.0/26",
+				},
+			},
+			wantErr: true,
+			errMsg:  "Set node.maxPods to at most 61 or use a /25 or larger cni.podCIDR",
+		},
+		{
+			name: "IPv6 pod CIDR fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				CNI: CNIConfig{
+					PodCIDR: "fd00::/64",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid cni.podCIDR",
+		},
+		{
+			name: "unknown CNI mode fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				CNI: CNIConfig{
+					Mode: "calico",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid cni.mode",
+		},
+		{
+			name: "bridge gateway within pod CIDR passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				CNI: CNIConfig{
+					PodCIDR: "172.16.0.0/24",
+					Gateway: "172.16.0.254",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "bridge gateway outside pod CIDR fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				CNI: CNIConfig{
+					PodCIDR: "172.16.0.0/24",
+					Gateway: "172.16.1.1",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid cni.gateway",
+		},
+		{
+			name: "gateway with cilium mode fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				CNI: CNIConfig{
+					Mode:    "cilium",
+					Gateway: "10.244.0.1",
+				},
+			},
+			wantErr: true,
+			errMsg:  "only used with cni.mode bridge",
+		},
+		{
+			name: "cilium mode does not limit maxPods by pod CIDR",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					MaxPods: 110,
+				},
+				CNI: CNIConfig{
+					Mode:    "cilium",
+					PodCIDR: "10.244.0.0/26",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "custom pod and service CIDRs pass",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					MaxPods:     110,
+					PodCIDR:     "172.16.0.0/24",
+					ServiceCIDR: "172.20.0.0/16",
+					Kubelet: KubeletConfig{
+						DNSServiceIP: "172.20.0.10",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "node pod CIDR too small for maxPods names node.podCIDR",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					MaxPods: 110,
+					PodCIDR: "172.16.0.0/26",
+				},
+			},
+			wantErr: true,
+			errMsg:  "larger node.podCIDR",
+		},
+		{
+			name: "node and CNI pod CIDRs differ fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					PodCIDR: "172.16.0.0/24",
+				},
+				CNI: CNIConfig{
+					PodCIDR: "10.244.0.0/16",
+				},
+			},
+			wantErr: true,
+			errMsg:  "node.podCIDR 172.16.0.0/24 and cni.podCIDR 10.244.0.0/16 differ",
+		},
+		{
+			name: "invalid service CIDR fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					ServiceCIDR: "172.20.0.0",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.serviceCIDR",
+		},
+		{
+			name: "DNS service IP outside service CIDR fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					ServiceCIDR: "172.20.0.0/16",
+					Kubelet: KubeletConfig{
+						DNSServiceIP: "10.0.0.10",
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "outside node.serviceCIDR",
+		},
+		{
+			name: "invalid GPU driver package fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					GPU: &GPUConfig{Enabled: true, DriverPackages: []string{"nvidia-driver; reboot"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.gpu.driverPackages entry",
+		},
+		{
+			name: "trusted CA bundles pass",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				TrustedCA: TrustedCAConfig{
+					Bundles:          []string{"/etc/flex/proxy-ca.pem"},
+					InjectKubeconfig: true,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative trusted CA bundle fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				TrustedCA: TrustedCAConfig{
+					Bundles: []string{"proxy-ca.pem"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid trustedCA.bundles entry",
+		},
+		{
+			name: "kubeconfig injection without bundles fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				TrustedCA: TrustedCAConfig{
+					InjectKubeconfig: true,
+				},
+			},
+			wantErr: true,
+			errMsg:  "trustedCA.injectKubeconfig requires trustedCA.bundles",
+		},
+		{
+			name: "serving CSR approval without server TLS bootstrap fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						ApproveServingCSR: true,
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "node.kubelet.approveServingCSR requires node.kubelet.serverTLSBootstrap",
+		},
+		{
+			name: "rotate server certificates extra flag fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						ExtraFlags: []string{"--rotate-server-certificates"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "use node.kubelet.serverTLSBootstrap instead",
+		},
+		{
+			name: "drain settings pass",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Drain: &DrainConfig{GracePeriod: "30s", Timeout: "10m", Force: true},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid drain timeout fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Drain: &DrainConfig{Timeout: "0s"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.drain.timeout",
+		},
+		{
+			name: "too many parallel steps fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel:      "info",
+					ParallelSteps: 20,
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid agent.parallelSteps",
+		},
+		{
+			name: "kubelet feature gates and extra flags pass",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						FeatureGates: []string{"KubeletTracing=true"},
+						ExtraFlags:   []string{"--image-pull-progress-deadline=5m", "--serialize-image-pulls=false"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "extra flag weakening kubelet authentication fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						ExtraFlags: []string{"--anonymous-auth=true"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "must not set --anonymous-auth",
+		},
+		{
+			name: "extra flag with a dedicated setting fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						ExtraFlags: []string{"--max-pods=500"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "use node.maxPods instead",
+		},
+		{
+			name: "malformed extra flag fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						ExtraFlags: []string{"--v=2 --anonymous-auth=true"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.extraFlags entry",
+		},
+		{
+			name: "invalid feature gate name fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					Kubelet: KubeletConfig{
+						FeatureGates: []string{"kubelet-tracing=true"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.kubelet.featureGates entry",
+		},
+		{
+			name: "invalid node ready timeout fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					ReadyTimeout: "5",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid node.readyTimeout",
+		},
+		{
+			name: "machine identity from config passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+					Arc: &ArcConfig{
+						MachineIDSource: "config",
+						MachineID:       "4C4C4544-0035-4710-8031-B4C04F564433",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid machine identity source fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+					Arc: &ArcConfig{
+						MachineIDSource: "serial",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid azure.arc.machineIdSource",
+		},
+		{
+			name: "machine identity from config without a GUID fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+					Arc: &ArcConfig{
+						MachineIDSource: "config",
+						MachineID:       "store-42",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires a GUID",
+		},
+		{
+			name: "machine identity with hostname override fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+					Arc: &ArcConfig{
+						MachineIDSource: "smbios",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					HostnameOverride: "edge-node-1",
+				},
+			},
+			wantErr: true,
+			errMsg:  "node.hostnameOverride cannot be combined",
+		},
+		{
+			name: "machine ID without config source fails",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
@@ -87,6 +2447,28 @@ func TestValidate(t *testing.T) {
 						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
 						Location:   "eastus",
 					},
+					Arc: &ArcConfig{
+						MachineID: "4C4C4544-0035-4710-8031-B4C04F564433",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires azure.arc.machineIdSource to be config",
+		},
+		{
+			name: "sovereign cloud passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzureChinaCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "chinanorth3",
+					},
 				},
 				Agent: AgentConfig{
 					LogLevel: "info",
@@ -95,37 +2477,72 @@ func TestValidate(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "missing subscription ID fails",
+			name: "invalid site ID fails",
 			config: &Config{
 				Azure: AzureConfig{
-					TenantID: "12345678-1234-1234-1234-123456789012",
-					Cloud:    "AzurePublicCloud",
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
 					TargetCluster: &TargetClusterConfig{
 						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
 						Location:   "eastus",
 					},
 				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Node: NodeConfig{
+					SiteID: "store 42/berlin",
+				},
 			},
 			wantErr: true,
-			errMsg:  "azure.subscriptionId is required",
+			errMsg:  "invalid node.siteId",
 		},
 		{
-			name: "missing tenant ID fails",
+			name: "checksum URL passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Runc: RuntimeConfig{
+					Checksum: &ChecksumConfig{URL: "https://github.com/opencontainers/runc/releases/download/v1.1.12/runc.sha256sum"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid checksum fails",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
 					Cloud:          "AzurePublicCloud",
 					TargetCluster: &TargetClusterConfig{
 						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
 						Location:   "eastus",
 					},
 				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+				Containerd: ContainerdConfig{
+					Checksum: &ChecksumConfig{SHA256: "deadbeef"},
+				},
 			},
 			wantErr: true,
-			errMsg:  "azure.tenantId is required",
+			errMsg:  "invalid containerd.checksum.sha256",
 		},
 		{
-			name: "missing target cluster location fails",
+			name: "invalid healthz address fails",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
@@ -133,61 +2550,154 @@ func TestValidate(t *testing.T) {
 					Cloud:          "AzurePublicCloud",
 					TargetCluster: &TargetClusterConfig{
 						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
 					},
 				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					Healthz:  &HealthzConfig{Address: "10264"},
+				},
 			},
 			wantErr: true,
-			errMsg:  "azure.targetCluster.location is required",
+			errMsg:  "invalid agent.healthz.address",
 		},
 		{
-			name: "missing target cluster resource ID fails",
+			name: "retry settings pass",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
 					TenantID:       "12345678-1234-1234-1234-123456789012",
 					Cloud:          "AzurePublicCloud",
 					TargetCluster: &TargetClusterConfig{
-						Location: "eastus",
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					Retry:    &RetryConfig{Attempts: 8, InitialDelay: "2s", MaxDelay: "1m", AttemptTimeout: "5m"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "retry delay above maximum fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
 					},
 				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+					Retry:    &RetryConfig{InitialDelay: "2m"},
+				},
 			},
 			wantErr: true,
-			errMsg:  "azure.targetCluster.resourceId is required",
+			errMsg:  "invalid agent.retry.initialDelay",
 		},
 		{
-			name: "invalid resource ID format fails",
+			name: "unknown log format fails",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
 					TenantID:       "12345678-1234-1234-1234-123456789012",
 					Cloud:          "AzurePublicCloud",
 					TargetCluster: &TargetClusterConfig{
-						ResourceID: "invalid-resource-id",
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel:  "info",
+					LogFormat: "logfmt",
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid agent.logFormat",
+		},
+		{
+			name: "workload identity passes",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					WorkloadIdentity: &WorkloadIdentityConfig{
+						TenantID:  "12345678-1234-1234-1234-123456789012",
+						ClientID:  "87654321-4321-4321-4321-210987654321",
+						TokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+					},
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "workload identity with service principal fails",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					ServicePrincipal: &ServicePrincipalConfig{
+						TenantID:     "12345678-1234-1234-1234-123456789012",
+						ClientID:     "87654321-4321-4321-4321-210987654321",
+						ClientSecret: "secret",
+					},
+					WorkloadIdentity: &WorkloadIdentityConfig{
+						TenantID:  "12345678-1234-1234-1234-123456789012",
+						ClientID:  "87654321-4321-4321-4321-210987654321",
+						TokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+					},
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
 						Location:   "eastus",
 					},
 				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
 			},
 			wantErr: true,
-			errMsg:  "invalid azure.targetCluster.resourceId:",
+			errMsg:  "mutually exclusive",
 		},
 		{
-			name: "invalid azure cloud fails",
+			name: "workload identity with relative token file fails",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
 					TenantID:       "12345678-1234-1234-1234-123456789012",
-					Cloud:          "InvalidCloud",
+					Cloud:          "AzurePublicCloud",
+					WorkloadIdentity: &WorkloadIdentityConfig{
+						TenantID:  "12345678-1234-1234-1234-123456789012",
+						ClientID:  "87654321-4321-4321-4321-210987654321",
+						TokenFile: "azure-identity-token",
+					},
 					TargetCluster: &TargetClusterConfig{
 						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
 						Location:   "eastus",
 					},
 				},
+				Agent: AgentConfig{
+					LogLevel: "info",
+				},
 			},
 			wantErr: true,
-			errMsg:  "invalid azure.cloud: InvalidCloud. Valid values are: AzurePublicCloud",
+			errMsg:  "invalid azure.workloadIdentity.tokenFile",
 		},
 		{
-			name: "invalid log level fails",
+			name: "too frequent update check fails",
 			config: &Config{
 				Azure: AzureConfig{
 					SubscriptionID: "12345678-1234-1234-1234-123456789012",
@@ -199,11 +2709,38 @@ func TestValidate(t *testing.T) {
 					},
 				},
 				Agent: AgentConfig{
-					LogLevel: "invalid",
+					LogLevel: "info",
+					UpdateCheck: &UpdateCheckConfig{
+						ManifestURL: "https://releases.example.com/aks-flex-node/latest.json",
+						Interval:    "1m",
+					},
 				},
 			},
 			wantErr: true,
-			errMsg:  "invalid agent.logLevel: invalid. Valid values are: debug, info, warning, error",
+			errMsg:  "invalid agent.updateCheck.interval",
+		},
+		{
+			name: "metrics address and update check pass",
+			config: &Config{
+				Azure: AzureConfig{
+					SubscriptionID: "12345678-1234-1234-1234-123456789012",
+					TenantID:       "12345678-1234-1234-1234-123456789012",
+					Cloud:          "AzurePublicCloud",
+					TargetCluster: &TargetClusterConfig{
+						ResourceID: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+						Location:   "eastus",
+					},
+				},
+				Agent: AgentConfig{
+					LogLevel:       "info",
+					MetricsAddress: "127.0.0.1:9464",
+					UpdateCheck: &UpdateCheckConfig{
+						ManifestURL: "https://releases.example.com/aks-flex-node/latest.json",
+						Interval:    "6h",
+					},
+				},
+			},
+			wantErr: false,
 		},
 		{
 			name: "valid arc config passes",
@@ -250,6 +2787,43 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestClusterDNSIP(t *testing.T) {
+	tests := []struct {
+		serviceCIDR string
+		want        string
+		wantErr     bool
+	}{
+		{serviceCIDR: "10.0.0.0/16", want: "10.0.0.10"},
+		{serviceCIDR: "192.168.4.0/22", want: "192.168.4.10"},
+		{serviceCIDR: "fd00:10::/108", want: "fd00:10::a"},
+		{serviceCIDR: "10.0.0.0/29", wantErr: true},
+		{serviceCIDR: "10.0.0.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ClusterDNSIP(tt.serviceCIDR)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ClusterDNSIP(%s) = %q, %v, want %q, error %v", tt.serviceCIDR, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetDrainTimeout(t *testing.T) {
+	tests := []struct {
+		drain *DrainConfig
+		want  time.Duration
+	}{
+		{drain: nil, want: DefaultDrainTimeout},
+		{drain: &DrainConfig{}, want: DefaultDrainTimeout},
+		{drain: &DrainConfig{Timeout: "15m"}, want: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Node: NodeConfig{Drain: tt.drain}}
+		if got := cfg.GetDrainTimeout(); got != tt.want {
+			t.Errorf("GetDrainTimeout() with %+v = %s, want %s", tt.drain, got, tt.want)
+		}
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	// Create a temporary directory for test config files
 	tempDir, err := os.MkdirTemp("", "aks-config-test-*")
@@ -339,6 +2913,38 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFeatureGates(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	configJSON := `{
+		"azure": {
+			"subscriptionId": "12345678-1234-1234-1234-123456789012",
+			"tenantId": "12345678-1234-1234-1234-123456789012",
+			"cloud": "AzurePublicCloud",
+			"targetCluster": {
+				"resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				"location": "eastus"
+			}
+		},
+		"node": {
+			"kubelet": {
+				"featureGates": ["KubeletTracing=true", "GracefulNodeShutdown=false"]
+			}
+		}
+	}`
+	if err := os.WriteFile(configFile, []byte(configJSON), 0o644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
+	}
+	want := []string{"KubeletTracing=true", "GracefulNodeShutdown=false"}
+	if !reflect.DeepEqual(cfg.Node.Kubelet.FeatureGates, want) {
+		t.Errorf("Node.Kubelet.FeatureGates = %v, want %v with the case of the names kept", cfg.Node.Kubelet.FeatureGates, want)
+	}
+}
+
 func TestValidateAzureResourceID(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -463,3 +3069,96 @@ func TestPopulateTargetClusterInfoFromConfig(t *testing.T) {
 		t.Errorf("Expected ResourceID %s, got %s", expected.ResourceID, config.Azure.TargetCluster.ResourceID)
 	}
 }
+
+func TestApplyMachineIdentity(t *testing.T) {
+	original := smbiosUUIDPath
+	t.Cleanup(func() { smbiosUUIDPath = original })
+
+	tests := []struct {
+		name     string
+		source   string
+		smbios   string
+		wantName string
+		wantErr  string
+	}{
+		{name: "hostname keeps the configured names", source: MachineIDSourceHostname},
+		{name: "config", source: MachineIDSourceConfig, wantName: "4c4c4544-0035-4710-8031-b4c04f564433"},
+		{name: "smbios", source: MachineIDSourceSMBIOS, smbios: "8F3B2D4C-1A2B-4C5D-9E8F-0A1B2C3D4E5F\n", wantName: "8f3b2d4c-1a2b-4c5d-9e8f-0a1b2c3d4e5f"},
+		{name: "smbios placeholder", source: MachineIDSourceSMBIOS, smbios: "03000200-0400-0500-0006-000700080009\n", wantErr: "placeholder"},
+		{name: "smbios not a GUID", source: MachineIDSourceSMBIOS, smbios: "Not Settable\n", wantErr: "not a GUID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smbiosUUIDPath = filepath.Join(t.TempDir(), "product_uuid")
+			if err := os.WriteFile(smbiosUUIDPath, []byte(tt.smbios), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &Config{Azure: AzureConfig{Arc: &ArcConfig{MachineIDSource: tt.source}}}
+			if tt.source == MachineIDSourceConfig {
+				cfg.Azure.Arc.MachineID = "4C4C4544-0035-4710-8031-B4C04F564433"
+			}
+
+			err := cfg.applyMachineIdentity()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyMachineIdentity() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyMachineIdentity() unexpected error: %v", err)
+			}
+			if cfg.Azure.Arc.MachineName != tt.wantName || cfg.Node.HostnameOverride != tt.wantName {
+				t.Errorf("names = %q/%q, want %q for both the Arc machine and the node",
+					cfg.Azure.Arc.MachineName, cfg.Node.HostnameOverride, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestKeepResolvedKubernetesVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		reloaded string
+		want     string
+	}{
+		{name: "auto stays auto", previous: "auto", reloaded: "auto", want: "1.32.7"},
+		{name: "omitted stays omitted", previous: "", reloaded: "", want: "1.32.7"},
+		{name: "auto becomes omitted", previous: "auto", reloaded: "", want: "1.32.7"},
+		{name: "auto becomes pinned", previous: "auto", reloaded: "1.31.2", want: "1.31.2"},
+		{name: "pinned becomes auto", previous: "1.31.2", reloaded: "auto", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := &Config{Kubernetes: KubernetesConfig{Version: tt.previous}}
+			if previous.IsKubernetesVersionAuto() {
+				previous.SetResolvedKubernetesVersion("1.32.7")
+			}
+			reloaded := &Config{Kubernetes: KubernetesConfig{Version: tt.reloaded}}
+
+			reloaded.KeepResolvedKubernetesVersion(previous)
+			if got := reloaded.GetKubernetesVersion(); got != tt.want {
+				t.Errorf("GetKubernetesVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsKubernetesVersionAuto(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "", want: true},
+		{version: "auto", want: true},
+		{version: "AUTO", want: true},
+		{version: "1.32.7", want: false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Kubernetes: KubernetesConfig{Version: tt.version}}
+		if got := cfg.IsKubernetesVersionAuto(); got != tt.want {
+			t.Errorf("IsKubernetesVersionAuto() for version %q = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Diff returns the settings that differ between two configurations as JSON paths, either a top-level section
+// such as cni or a setting within a section such as node.maxPods. Secrets are compared but never returned.
+func Diff(previous, current *Config) ([]string, error) {
+	before, err := settings(previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := settings(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, section := range unionKeys(before, after) {
+		if reflect.DeepEqual(before[section], after[section]) {
+			continue
+		}
+		beforeSection, beforeOK := before[section].(map[string]any)
+		afterSection, afterOK := after[section].(map[string]any)
+		if !beforeOK || !afterOK {
+			changed = append(changed, section)
+			continue
+		}
+		for _, key := range unionKeys(beforeSection, afterSection) {
+			if !reflect.DeepEqual(beforeSection[key], afterSection[key]) {
+				changed = append(changed, section+"."+key)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// SettingIn returns true when a setting Diff returned is one of the given sections or settings, or within them
+func SettingIn(setting string, paths ...string) bool {
+	return slices.ContainsFunc(paths, func(path string) bool {
+		return setting == path || strings.HasPrefix(setting, path+".")
+	})
+}
+
+// settings returns the configuration as generic JSON values
+func settings(cfg *Config) (map[string]any, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare configurations: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to compare configurations: %w", err)
+	}
+	return values, nil
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys(a, b map[string]any) []string {
+	keys := maps.Clone(a)
+	maps.Copy(keys, b)
+	return slices.Sorted(maps.Keys(keys))
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	previous := &Config{
+		Node:       NodeConfig{MaxPods: 110, Labels: map[string]string{"site": "a"}},
+		Containerd: ContainerdConfig{Version: "1.7.20"},
+		Agent:      AgentConfig{LogLevel: "info"},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{name: "unchanged", modify: func(*Config) {}},
+		{name: "setting", modify: func(cfg *Config) { cfg.Node.MaxPods = 50 }, want: []string{"node.maxPods"}},
+		{name: "label", modify: func(cfg *Config) { cfg.Node.Labels = map[string]string{"site": "b"} }, want: []string{"node.labels"}},
+		{
+			name: "several sections",
+			modify: func(cfg *Config) {
+				cfg.Containerd.Version = "2.0.0"
+				cfg.Agent.LogLevel = "debug"
+			},
+			want: []string{"agent.logLevel", "containerd.version"},
+		},
+		{
+			name:   "section added",
+			modify: func(cfg *Config) { cfg.HealthChecks = []HealthCheckConfig{{Name: "disk"}} },
+			want:   []string{"healthChecks"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := *previous
+			current.Node.Labels = map[string]string{"site": "a"}
+			tt.modify(&current)
+			changed, err := Diff(previous, &current)
+			if err != nil {
+				t.Fatalf("Diff() unexpected error = %v", err)
+			}
+			if !slices.Equal(changed, tt.want) {
+				t.Errorf("Diff() = %v, want %v", changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingIn(t *testing.T) {
+	if !SettingIn("node.maxPods", "containerd", "node") || !SettingIn("node.maxPods", "node.maxPods") {
+		t.Error("SettingIn() = false for a setting within the sections")
+	}
+	if SettingIn("node.maxPods", "node.labels", "nodes") {
+		t.Error("SettingIn() = true for a setting outside the sections")
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+)
+
+// CurrentSchemaVersion is the configuration schema version of this agent. Configuration files without
+// schemaVersion are version 1.
+const CurrentSchemaVersion = 2
+
+// schemaVersionKey is the top-level setting holding the schema version of a configuration file
+const schemaVersionKey = "schemaVersion"
+
+// migration upgrades a configuration document by one schema version
+type migration struct {
+	description string
+	apply       func(doc map[string]any) error
+}
+
+// migrations upgrade schema version i+1 to i+2, in order. Append a migration and bump CurrentSchemaVersion
+// when a setting is renamed or moved, so existing configuration files keep loading.
+var migrations = []migration{
+	{description: "move cni.podCIDR to node.podCIDR", apply: migratePodCIDR},
+}
+
+// Migration is the outcome of upgrading a configuration document to the current schema version
+type Migration struct {
+	FromVersion int      // Schema version of the original document
+	Applied     []string // Descriptions of the migrations applied, in order
+	Data        []byte   // The upgraded document
+	BackupPath  string   // Where MigrateFile kept the original file
+}
+
+// Migrated returns true when the document was not at the current schema version
+func (m *Migration) Migrated() bool {
+	return m.FromVersion != CurrentSchemaVersion
+}
+
+// MigrateDocument upgrades a JSON configuration document to the current schema version. Documents of a newer
+// schema version than this agent supports are rejected, as their settings may mean something else.
+func MigrateDocument(data []byte) (*Migration, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // keep numbers as written
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("config must be a JSON object")
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("config schemaVersion %d is newer than the version %d this agent supports, upgrade the agent",
+			version, CurrentSchemaVersion)
+	}
+
+	result := &Migration{FromVersion: version}
+	for _, m := range migrations[version-1:] {
+		if err := m.apply(doc); err != nil {
+			return nil, fmt.Errorf("failed to %s: %w", m.description, err)
+		}
+		result.Applied = append(result.Applied, m.description)
+	}
+	if key, _ := lookup(doc, schemaVersionKey); key != "" {
+		delete(doc, key)
+	}
+	doc[schemaVersionKey] = CurrentSchemaVersion
+
+	if result.Data, err = json.MarshalIndent(doc, "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to encode migrated config: %w", err)
+	}
+	result.Data = append(result.Data, '\n')
+	return result, nil
+}
+
+// MigrateFile upgrades the configuration file at path to the current schema version in place. The original
+// file is kept next to it with a .v<version>.bak suffix. Files at the current schema version are left untouched.
+func MigrateFile(path string) (*Migration, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file at %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file at %s: %w", path, err)
+	}
+	result, err := MigrateDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Migrated() {
+		return result, nil
+	}
+
+	result.BackupPath = fmt.Sprintf("%s.v%d.bak", path, result.FromVersion)
+	if err := fsutil.WriteFileAtomic(result.BackupPath, data, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to back up config to %s: %w", result.BackupPath, err)
+	}
+	if err := fsutil.WriteFileAtomic(path, result.Data, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write migrated config to %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// schemaVersion returns the schema version of a configuration document
+func schemaVersion(doc map[string]any) (int, error) {
+	_, value := lookup(doc, schemaVersionKey)
+	if value == nil {
+		return 1, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("config schemaVersion must be an integer, got %v", value)
+	}
+	version, err := strconv.Atoi(number.String())
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("config schemaVersion must be a positive integer, got %s", number)
+	}
+	return version, nil
+}
+
+// lookup returns the key and value of a setting in a configuration object. Keys match case-insensitively, as
+// they do when the configuration is loaded.
+func lookup(object map[string]any, key string) (string, any) {
+	if value, ok := object[key]; ok {
+		return key, value
+	}
+	for k, value := range object {
+		if strings.EqualFold(k, key) {
+			return k, value
+		}
+	}
+	return "", nil
+}
+
+// section returns the configuration object at key, creating it when create is set
+func section(doc map[string]any, key string, create bool) (map[string]any, error) {
+	_, value := lookup(doc, key)
+	if value == nil {
+		if !create {
+			return nil, nil
+		}
+		object := map[string]any{}
+		doc[key] = object
+		return object, nil
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", key)
+	}
+	return object, nil
+}
+
+// migratePodCIDR moves the pod subnet from cni.podCIDR, its original place, to node.podCIDR next to the
+// other node addressing settings
+func migratePodCIDR(doc map[string]any) error {
+	cni, err := section(doc, "cni", false)
+	if err != nil || cni == nil {
+		return err
+	}
+	cniKey, podCIDR := lookup(cni, "podCIDR")
+	if cniKey == "" {
+		return nil
+	}
+	if podCIDR == nil || podCIDR == "" {
+		delete(cni, cniKey)
+		return nil
+	}
+	node, err := section(doc, "node", true)
+	if err != nil {
+		return err
+	}
+	nodeKey, existing := lookup(node, "podCIDR")
+	if existing != nil && existing != "" && existing != podCIDR {
+		return fmt.Errorf("node.podCIDR %v and cni.podCIDR %v differ, set only node.podCIDR", existing, podCIDR)
+	}
+	if nodeKey == "" {
+		nodeKey = "podCIDR"
+	}
+	node[nodeKey] = podCIDR
+	delete(cni, cniKey)
+	return nil
+}
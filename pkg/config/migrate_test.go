@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateDocument(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		wantFrom    int
+		wantApplied int
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "file without schema version moves cni.podCIDR",
+			doc:         `{"cni": {"podCIDR": "10.50.0.0/16", "mode": "bridge"}, "node": {"maxPods": 30}}`,
+			wantFrom:    1,
+			wantApplied: 1,
+			want: `{
+  "cni": {
+    "mode": "bridge"
+  },
+  "node": {
+    "maxPods": 30,
+    "podCIDR": "10.50.0.0/16"
+  },
+  "schemaVersion": 2
+}
+`,
+		},
+		{
+			name:        "keys match case-insensitively",
+			doc:         `{"CNI": {"PodCIDR": "10.50.0.0/16"}}`,
+			wantFrom:    1,
+			wantApplied: 1,
+			want: `{
+  "CNI": {},
+  "node": {
+    "podCIDR": "10.50.0.0/16"
+  },
+  "schemaVersion": 2
+}
+`,
+		},
+		{
+			name:        "matching node.podCIDR is kept",
+			doc:         `{"cni": {"podCIDR": "10.50.0.0/16"}, "node": {"podCIDR": "10.50.0.0/16"}}`,
+			wantFrom:    1,
+			wantApplied: 1,
+			want: `{
+  "cni": {},
+  "node": {
+    "podCIDR": "10.50.0.0/16"
+  },
+  "schemaVersion": 2
+}
+`,
+		},
+		{
+			name:    "differing pod subnets are rejected",
+			doc:     `{"cni": {"podCIDR": "10.50.0.0/16"}, "node": {"podCIDR": "10.60.0.0/16"}}`,
+			wantErr: "differ",
+		},
+		{
+			name:     "current schema version is unchanged",
+			doc:      `{"schemaVersion": 2, "node": {"podCIDR": "10.50.0.0/16"}}`,
+			wantFrom: 2,
+			want: `{
+  "node": {
+    "podCIDR": "10.50.0.0/16"
+  },
+  "schemaVersion": 2
+}
+`,
+		},
+		{
+			name:    "newer schema version is rejected",
+			doc:     `{"schemaVersion": 3}`,
+			wantErr: "upgrade the agent",
+		},
+		{
+			name:    "non-integer schema version is rejected",
+			doc:     `{"schemaVersion": "2"}`,
+			wantErr: "must be an integer",
+		},
+		{
+			name:    "invalid JSON is rejected",
+			doc:     `{"cni": `,
+			wantErr: "failed to parse config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration, err := MigrateDocument([]byte(tt.doc))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MigrateDocument() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MigrateDocument() unexpected error = %v", err)
+			}
+			if migration.FromVersion != tt.wantFrom {
+				t.Errorf("FromVersion = %d, want %d", migration.FromVersion, tt.wantFrom)
+			}
+			if len(migration.Applied) != tt.wantApplied {
+				t.Errorf("Applied = %v, want %d migrations", migration.Applied, tt.wantApplied)
+			}
+			if string(migration.Data) != tt.want {
+				t.Errorf("Data =\n%s\nwant\n%s", migration.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	original := `{"cni": {"podCIDR": "10.50.0.0/16"}}`
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	migration, err := MigrateFile(path)
+	if err != nil {
+		t.Fatalf("MigrateFile() unexpected error = %v", err)
+	}
+	if migration.BackupPath != path+".v1.bak" {
+		t.Errorf("BackupPath = %s, want %s.v1.bak", migration.BackupPath, path)
+	}
+	if backup, err := os.ReadFile(migration.BackupPath); err != nil || string(backup) != original {
+		t.Errorf("backup = %q, %v, want the original file", backup, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat migrated config: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("migrated config mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	again, err := MigrateFile(path)
+	if err != nil {
+		t.Fatalf("second MigrateFile() unexpected error = %v", err)
+	}
+	if again.Migrated() || again.BackupPath != "" {
+		t.Errorf("second MigrateFile() migrated the current schema version again")
+	}
+}
+
+func TestLoadConfigMigratesInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	configJSON := `{
+		"azure": {
+			"subscriptionId": "12345678-1234-1234-1234-123456789012",
+			"tenantId": "12345678-1234-1234-1234-123456789012",
+			"targetCluster": {
+				"resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				"location": "eastus"
+			}
+		},
+		"cni": {"podCIDR": "10.50.0.0/16"}
+	}`
+	if err := os.WriteFile(path, []byte(configJSON), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
+	}
+	if cfg.Node.PodCIDR != "10.50.0.0/16" || cfg.CNI.PodCIDR != "" {
+		t.Errorf("node.podCIDR = %q, cni.podCIDR = %q, want the pod subnet moved to node.podCIDR", cfg.Node.PodCIDR, cfg.CNI.PodCIDR)
+	}
+	if cfg.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", cfg.SchemaVersion, CurrentSchemaVersion)
+	}
+	if version, ok := cfg.NeedsMigration(); !ok || version != 1 {
+		t.Errorf("NeedsMigration() = %d, %t, want 1, true", version, ok)
+	}
+	if data, _ := os.ReadFile(path); string(data) != configJSON {
+		t.Errorf("LoadConfig() rewrote the config file")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package config
+
+const (
+	// AgentStateDir is the directory where the agent persists state across runs
+	AgentStateDir = "/var/lib/aks-flex-node"
+
+	defaultLogDir = "/var/log/aks-flex-node"
+)
@@ -0,0 +1,10 @@
+//go:build windows
+
+package config
+
+const (
+	// AgentStateDir is the directory where the agent persists state across runs
+	AgentStateDir = `C:\ProgramData\aks-flex-node`
+
+	defaultLogDir = `C:\ProgramData\aks-flex-node\logs`
+)
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	selectedProfile   string
+	selectedProfileMu sync.RWMutex
+)
+
+// SelectProfile selects the node pool profile LoadConfig merges over the node settings. Without a selected
+// profile, the profile whose hostnames match the machine's hostname is merged, if any.
+func SelectProfile(name string) {
+	selectedProfileMu.Lock()
+	defer selectedProfileMu.Unlock()
+	selectedProfile = strings.ToLower(name)
+}
+
+// SelectedProfile returns the node pool profile selected with SelectProfile
+func SelectedProfile() string {
+	selectedProfileMu.RLock()
+	defer selectedProfileMu.RUnlock()
+	return selectedProfile
+}
+
+// Profile returns the node pool profile merged over the node settings, or "" when none was
+func (cfg *Config) Profile() string {
+	return cfg.profile
+}
+
+// applyProfile merges the selected profile, or the one matching the machine's hostname, over the node settings
+func (cfg *Config) applyProfile() error {
+	if len(cfg.Profiles) == 0 && SelectedProfile() == "" {
+		return nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname to select a node pool profile: %w", err)
+	}
+	name, err := cfg.matchProfile(SelectedProfile(), hostname)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		cfg.mergeProfile(name)
+	}
+	return nil
+}
+
+// matchProfile returns the profile named by selected or, without one, the profile whose hostnames match hostname.
+// It returns "" when no profile matches.
+func (cfg *Config) matchProfile(selected, hostname string) (string, error) {
+	if selected != "" {
+		if _, ok := cfg.Profiles[selected]; !ok {
+			return "", fmt.Errorf("node pool profile %s is not defined in profiles", selected)
+		}
+		return selected, nil
+	}
+
+	var matched []string
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		if slices.ContainsFunc(cfg.Profiles[name].Hostnames, func(pattern string) bool {
+			ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(hostname))
+			return ok
+		}) {
+			matched = append(matched, name)
+		}
+	}
+	if len(matched) > 1 {
+		return "", fmt.Errorf("hostname %s matches node pool profiles %s, select one with --profile", hostname, strings.Join(matched, ", "))
+	}
+	if len(matched) == 1 {
+		return matched[0], nil
+	}
+	return "", nil
+}
+
+// mergeProfile merges the named profile over the node settings
+func (cfg *Config) mergeProfile(name string) {
+	profile := cfg.Profiles[name]
+	cfg.profile = name
+
+	if len(profile.Labels) > 0 {
+		labels := maps.Clone(cfg.Node.Labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		maps.Copy(labels, profile.Labels)
+		cfg.Node.Labels = labels
+	}
+	if len(profile.Taints) > 0 {
+		taints := slices.DeleteFunc(slices.Clone(cfg.Node.Taints), func(taint string) bool {
+			return slices.ContainsFunc(profile.Taints, func(override string) bool {
+				return taintIdentity(taint) == taintIdentity(override)
+			})
+		})
+		cfg.Node.Taints = append(taints, profile.Taints...)
+	}
+	if profile.MaxPods > 0 {
+		cfg.Node.MaxPods = profile.MaxPods
+	}
+	if profile.GPU != nil {
+		gpu := *profile.GPU
+		cfg.Node.GPU = &gpu
+	}
+}
+
+// taintIdentity returns the key and effect of a taint in key[=value]:Effect format, which identify it on a node
+func taintIdentity(taint string) string {
+	keyValue, effect, _ := strings.Cut(taint, ":")
+	key, _, _ := strings.Cut(keyValue, "=")
+	return key + ":" + effect
+}
+
+// validateProfiles validates every profile, so a mistake surfaces on all machines rather than only where it is selected
+func (c *Config) validateProfiles() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Profiles)) {
+		profile := c.Profiles[name]
+		for _, pattern := range profile.Hostnames {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid profiles.%s.hostnames entry: %s: %w", name, pattern, err)
+			}
+		}
+		for _, taint := range profile.Taints {
+			if !nodeTaintPattern.MatchString(taint) {
+				return fmt.Errorf("invalid profiles.%s.taints entry: %s. Expected format: key[=value]:NoSchedule|PreferNoSchedule|NoExecute", name, taint)
+			}
+		}
+		if profile.MaxPods < 0 {
+			return fmt.Errorf("invalid profiles.%s.maxPods: %d. Must not be negative", name, profile.MaxPods)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchProfile(t *testing.T) {
+	cfg := &Config{
+		Profiles: map[string]ProfileConfig{
+			"gpu":   {Hostnames: []string{"gpu-*"}},
+			"store": {Hostnames: []string{"store-??", "kiosk-*"}},
+			"lab":   {Hostnames: []string{"*-lab"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		selected string
+		hostname string
+		want     string
+		wantErr  string
+	}{
+		{name: "selected profile", selected: "store", hostname: "gpu-01", want: "store"},
+		{name: "undefined selected profile", selected: "edge", hostname: "gpu-01", wantErr: "edge is not defined"},
+		{name: "matching hostname", hostname: "GPU-01", want: "gpu"},
+		{name: "second pattern", hostname: "kiosk-7", want: "store"},
+		{name: "no matching profile", hostname: "edge-01"},
+		{name: "several matching profiles", hostname: "gpu-lab", wantErr: "matches node pool profiles gpu, lab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.matchProfile(tt.selected, tt.hostname)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("matchProfile() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matchProfile() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("matchProfile(%q, %q) = %q, want %q", tt.selected, tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeProfile(t *testing.T) {
+	cfg := &Config{
+		Node: NodeConfig{
+			MaxPods: 110,
+			Labels:  map[string]string{"tier": "standard", "site": "west"},
+			Taints:  []string{"dedicated=edge:NoSchedule", "zone=west:PreferNoSchedule"},
+		},
+		Profiles: map[string]ProfileConfig{
+			"gpu": {
+				Labels:  map[string]string{"tier": "gpu"},
+				Taints:  []string{"dedicated=gpu:NoSchedule"},
+				MaxPods: 30,
+				GPU:     &GPUConfig{Enabled: true},
+			},
+		},
+	}
+	cfg.mergeProfile("gpu")
+
+	if cfg.Profile() != "gpu" {
+		t.Errorf("Profile() = %q, want gpu", cfg.Profile())
+	}
+	if want := map[string]string{"tier": "gpu", "site": "west"}; !reflect.DeepEqual(cfg.Node.Labels, want) {
+		t.Errorf("Node.Labels = %v, want %v", cfg.Node.Labels, want)
+	}
+	if want := []string{"zone=west:PreferNoSchedule", "dedicated=gpu:NoSchedule"}; !reflect.DeepEqual(cfg.Node.Taints, want) {
+		t.Errorf("Node.Taints = %v, want %v", cfg.Node.Taints, want)
+	}
+	if cfg.Node.MaxPods != 30 {
+		t.Errorf("Node.MaxPods = %d, want 30", cfg.Node.MaxPods)
+	}
+	if !cfg.IsGPUEnabled() {
+		t.Error("IsGPUEnabled() = false, want the profile's GPU setup")
+	}
+}
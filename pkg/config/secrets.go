@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Kinds of secret references, written as ${env:NAME}, ${file:/path}, ${stdin} or ${keyvault:name} in place of
+// a secret
+const (
+	secretFromEnv      = "env"
+	secretFromFile     = "file"
+	secretFromStdin    = "stdin"
+	secretFromKeyVault = "keyvault"
+)
+
+// keyVaultSecretPattern matches a Key Vault secret name, optionally followed by a secret version
+var keyVaultSecretPattern = regexp.MustCompile(`^[0-9A-Za-z-]{1,127}(/[0-9A-Za-z]+)?$`)
+
+// KeyVaultResolver fetches the named secret, optionally name/version, from the Key Vault of the configuration
+type KeyVaultResolver func(cfg *Config, name string) (string, error)
+
+// keyVaultResolver resolves ${keyvault:name} references. The agent sets it at startup, as fetching secrets
+// needs the Azure credentials, which build on the configuration.
+var (
+	keyVaultResolver   KeyVaultResolver
+	keyVaultResolverMu sync.RWMutex
+)
+
+// SetKeyVaultResolver sets how ${keyvault:name} references are fetched
+func SetKeyVaultResolver(resolver KeyVaultResolver) {
+	keyVaultResolverMu.Lock()
+	defer keyVaultResolverMu.Unlock()
+	keyVaultResolver = resolver
+}
+
+// KeyVaultSecretName returns the secret name of a ${keyvault:name} reference, and whether reference is one
+func KeyVaultSecretName(reference string) (string, bool) {
+	if !isSecretReference(reference) {
+		return "", false
+	}
+	kind, name, _ := strings.Cut(reference[2:len(reference)-1], ":")
+	if kind != secretFromKeyVault {
+		return "", false
+	}
+	return name, true
+}
+
+// readStdinSecret reads the secret piped to the agent. Standard input can be read once, so the secret is kept
+// for configuration reloads. Replaced in tests.
+var readStdinSecret = sync.OnceValues(func() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read from standard input: %w", err)
+	}
+	return line, nil
+})
+
+// resolveSecrets replaces references to secrets kept outside the configuration file with their values, so
+// secrets do not have to be written into config.json. The values are only held in memory.
+func (c *Config) resolveSecrets() error {
+	if c.Azure.ServicePrincipal != nil {
+		reference := c.Azure.ServicePrincipal.ClientSecret
+		secret, err := c.resolveSecret("azure.servicePrincipal.clientSecret", reference)
+		if err != nil {
+			return err
+		}
+		if isSecretReference(reference) {
+			c.clientSecretReference = reference
+		}
+		c.Azure.ServicePrincipal.ClientSecret = secret
+	}
+	for i := range c.Containerd.Registries {
+		registry := &c.Containerd.Registries[i]
+		secret, err := c.resolveSecret(fmt.Sprintf("containerd.registries[%d].password", i), registry.Password)
+		if err != nil {
+			return err
+		}
+		registry.Password = secret
+	}
+	return nil
+}
+
+// redactedSecret replaces secrets in configurations shown outside the agent
+const redactedSecret = "***REDACTED***"
+
+// Redacted returns a copy of the configuration safe to show outside the agent: the service principal secret and
+// registry passwords are replaced by the reference they were resolved from, or redacted, and so are the
+// passwords of URLs
+func (c *Config) Redacted() (*Config, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+	var settings any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+	if data, err = json.Marshal(redactURLs(settings)); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+	redacted := &Config{}
+	if err := json.Unmarshal(data, redacted); err != nil {
+		return nil, fmt.Errorf("failed to copy configuration: %w", err)
+	}
+
+	if sp := redacted.Azure.ServicePrincipal; sp != nil && sp.ClientSecret != "" {
+		sp.ClientSecret = redactedSecret
+		if c.clientSecretReference != "" {
+			sp.ClientSecret = c.clientSecretReference
+		}
+	}
+	for i := range redacted.Containerd.Registries {
+		if registry := &redacted.Containerd.Registries[i]; registry.Password != "" && !isSecretReference(registry.Password) {
+			registry.Password = redactedSecret
+		}
+	}
+	return redacted, nil
+}
+
+// redactURLs redacts the passwords of the URLs among the decoded JSON settings
+func redactURLs(settings any) any {
+	switch value := settings.(type) {
+	case map[string]any:
+		for key, nested := range value {
+			value[key] = redactURLs(nested)
+		}
+	case []any:
+		for i, nested := range value {
+			value[i] = redactURLs(nested)
+		}
+	case string:
+		return redactURL(value)
+	}
+	return settings
+}
+
+// redactURL redacts the password of a URL with user information
+func redactURL(address string) string {
+	u, err := url.Parse(address)
+	if !strings.Contains(address, "://") || err != nil || u.User == nil {
+		return address
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedSecret)
+	}
+	return u.String()
+}
+
+// isSecretReference returns true when value references a secret rather than holding it
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}")
+}
+
+// resolveSecret returns the secret value references, or value itself when it is not a reference
+func (c *Config) resolveSecret(field, value string) (string, error) {
+	if !isSecretReference(value) {
+		return value, nil
+	}
+	kind, source, _ := strings.Cut(value[2:len(value)-1], ":")
+
+	var secret string
+	switch kind {
+	case secretFromEnv:
+		if source == "" {
+			return "", fmt.Errorf("%s references an environment variable without a name", field)
+		}
+		secret = os.Getenv(source)
+		if secret == "" {
+			return "", fmt.Errorf("%s references environment variable %s, which is not set", field, source)
+		}
+	case secretFromFile:
+		if !filepath.IsAbs(source) {
+			return "", fmt.Errorf("%s references file %q, which must be an absolute path", field, source)
+		}
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("%s references file %s, which cannot be read: %w", field, source, err)
+		}
+		secret = string(data)
+	case secretFromStdin:
+		if source != "" {
+			return "", fmt.Errorf("%s references standard input as %s, use ${stdin}", field, value)
+		}
+		line, err := readStdinSecret()
+		if err != nil {
+			return "", fmt.Errorf("%s references standard input: %w", field, err)
+		}
+		secret = line
+	case secretFromKeyVault:
+		if c.Azure.KeyVault == nil {
+			return "", fmt.Errorf("%s references Key Vault secret %s, which requires azure.keyVault", field, source)
+		}
+		if !keyVaultSecretPattern.MatchString(source) {
+			return "", fmt.Errorf("%s references invalid Key Vault secret %q. Use ${keyvault:name} or ${keyvault:name/version}", field, source)
+		}
+		if err := c.validateKeyVault(); err != nil {
+			return "", err
+		}
+		keyVaultResolverMu.RLock()
+		resolver := keyVaultResolver
+		keyVaultResolverMu.RUnlock()
+		if resolver == nil {
+			return "", fmt.Errorf("%s references Key Vault secret %s, which this command cannot fetch", field, source)
+		}
+		fetched, err := resolver(c, source)
+		if err != nil {
+			return "", fmt.Errorf("%s references Key Vault secret %s: %w", field, source, err)
+		}
+		secret = fetched
+	default:
+		return "", fmt.Errorf("%s has unsupported secret reference %s. Valid references are: ${env:NAME}, ${file:/path}, ${stdin}, ${keyvault:name}",
+			field, value)
+	}
+
+	// Files and pipes usually end in a newline that is not part of the secret
+	secret = strings.TrimRight(secret, "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s references %s, which is empty", field, value)
+	}
+	return secret, nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "sp-secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+	t.Setenv("AKS_FLEX_NODE_TEST_SECRET", "from-env")
+
+	originalStdin := readStdinSecret
+	defer func() { readStdinSecret = originalStdin }()
+	readStdinSecret = func() (string, error) { return "from-stdin\n", nil }
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain value is kept", value: "plain-secret", want: "plain-secret"},
+		{name: "dollar without reference is kept", value: "$ecret}", want: "$ecret}"},
+		{name: "environment variable", value: "${env:AKS_FLEX_NODE_TEST_SECRET}", want: "from-env"},
+		{name: "file without trailing newline", value: "${file:" + secretFile + "}", want: "from-file"},
+		{name: "standard input", value: "${stdin}", want: "from-stdin"},
+		{name: "unset environment variable", value: "${env:AKS_FLEX_NODE_TEST_UNSET}", wantErr: "not set"},
+		{name: "relative file", value: "${file:secret}", wantErr: "absolute path"},
+		{name: "missing file", value: "${file:/nonexistent/secret}", wantErr: "cannot be read"},
+		{name: "empty file", value: "${file:" + emptyFile + "}", wantErr: "empty"},
+		{name: "unsupported reference", value: "${vault:sp}", wantErr: "unsupported secret reference"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Config{}).resolveSecret("azure.servicePrincipal.clientSecret", tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveSecret() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveSecret() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigResolvesSecrets(t *testing.T) {
+	t.Setenv("AKS_FLEX_NODE_TEST_SP_SECRET", "resolved-secret")
+	path := filepath.Join(t.TempDir(), "config.json")
+	configJSON := `{
+		"azure": {
+			"subscriptionId": "12345678-1234-1234-1234-123456789012",
+			"tenantId": "12345678-1234-1234-1234-123456789012",
+			"servicePrincipal": {
+				"tenantId": "12345678-1234-1234-1234-123456789012",
+				"clientId": "12345678-1234-1234-1234-123456789012",
+				"clientSecret": "${env:AKS_FLEX_NODE_TEST_SP_SECRET}"
+			},
+			"targetCluster": {
+				"resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				"location": "eastus"
+			}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(configJSON), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error = %v", err)
+	}
+	if cfg.Azure.ServicePrincipal.ClientSecret != "resolved-secret" {
+		t.Errorf("clientSecret = %q, want the value of the referenced environment variable", cfg.Azure.ServicePrincipal.ClientSecret)
+	}
+
+	// Redacting shows the reference the secret was resolved from instead of the secret
+	redacted, err := cfg.Redacted()
+	if err != nil {
+		t.Fatalf("Redacted() unexpected error = %v", err)
+	}
+	if got := redacted.Azure.ServicePrincipal.ClientSecret; got != "${env:AKS_FLEX_NODE_TEST_SP_SECRET}" {
+		t.Errorf("Redacted() clientSecret = %q, want the secret reference", got)
+	}
+	if cfg.Azure.ServicePrincipal.ClientSecret != "resolved-secret" {
+		t.Errorf("Redacted() changed the clientSecret of the configuration")
+	}
+}
+
+func TestResolveKeyVaultSecret(t *testing.T) {
+	defer SetKeyVaultResolver(nil)
+	SetKeyVaultResolver(func(cfg *Config, name string) (string, error) {
+		return "vault:" + cfg.Azure.KeyVault.VaultURL + ":" + name, nil
+	})
+	vault := &KeyVaultConfig{VaultURL: "https://flex-secrets.vault.azure.net"}
+
+	tests := []struct {
+		name     string
+		keyVault *KeyVaultConfig
+		value    string
+		want     string
+		wantErr  string
+	}{
+		{name: "secret", keyVault: vault, value: "${keyvault:sp-secret}", want: "vault:https://flex-secrets.vault.azure.net:sp-secret"},
+		{name: "secret version", keyVault: vault, value: "${keyvault:sp-secret/0123abcd}", want: "vault:https://flex-secrets.vault.azure.net:sp-secret/0123abcd"},
+		{name: "without azure.keyVault", value: "${keyvault:sp-secret}", wantErr: "requires azure.keyVault"},
+		{name: "invalid secret name", keyVault: vault, value: "${keyvault:sp_secret}", wantErr: "invalid Key Vault secret"},
+		{name: "invalid vault URL", keyVault: &KeyVaultConfig{VaultURL: "http://flex-secrets.vault.azure.net"}, value: "${keyvault:sp-secret}", wantErr: "invalid azure.keyVault.vaultUrl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Azure: AzureConfig{KeyVault: tt.keyVault, ServicePrincipal: &ServicePrincipalConfig{ClientSecret: tt.value}}}
+			err := cfg.resolveSecrets()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveSecrets() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveSecrets() unexpected error = %v", err)
+			}
+			if cfg.Azure.ServicePrincipal.ClientSecret != tt.want {
+				t.Errorf("clientSecret = %q, want %q", cfg.Azure.ServicePrincipal.ClientSecret, tt.want)
+			}
+			if cfg.ClientSecretReference() != tt.value {
+				t.Errorf("ClientSecretReference() = %q, want %q", cfg.ClientSecretReference(), tt.value)
+			}
+			if name, ok := KeyVaultSecretName(cfg.ClientSecretReference()); !ok || "${keyvault:"+name+"}" != tt.value {
+				t.Errorf("KeyVaultSecretName() = %q, %t", name, ok)
+			}
+		})
+	}
+}
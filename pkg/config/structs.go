@@ -1,19 +1,47 @@
 package config
 
-import "os"
+import (
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/maintenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
+)
 
 // Config represents the complete agent configuration structure.
 // It contains Azure-specific settings and agent operational settings.
 type Config struct {
-	Azure      AzureConfig      `json:"azure"`
-	Agent      AgentConfig      `json:"agent"`
-	Containerd ContainerdConfig `json:"containerd"`
-	Kubernetes KubernetesConfig `json:"kubernetes"`
-	CNI        CNIConfig        `json:"cni"`
-	Runc       RuntimeConfig    `json:"runc"`
-	Node       NodeConfig       `json:"node"`
-	Paths      PathsConfig      `json:"paths"`
-	Npd        NPDConfig        `json:"npd"`
+	SchemaVersion int                 `json:"schemaVersion"` // Configuration schema version, older files are upgraded when loaded (default: 1)
+	Azure         AzureConfig         `json:"azure"`
+	Agent         AgentConfig         `json:"agent"`
+	Containerd    ContainerdConfig    `json:"containerd"`
+	Kubernetes    KubernetesConfig    `json:"kubernetes"`
+	CNI           CNIConfig           `json:"cni"`
+	Runc          RuntimeConfig       `json:"runc"`
+	Node          NodeConfig          `json:"node"`
+	Paths         PathsConfig         `json:"paths"`
+	Npd           NPDConfig           `json:"npd"`
+	KubeVIP       KubeVIPConfig       `json:"kubeVip"`
+	KubeProxy     KubeProxyConfig     `json:"kubeProxy"`
+	NodeLocalDNS  NodeLocalDNSConfig  `json:"nodeLocalDns"`
+	System        SystemConfig        `json:"system"`
+	TimeSync      TimeSyncConfig      `json:"timeSync"`
+	Storage       StorageConfig       `json:"storage"`
+	Packages      PackagesConfig      `json:"packages"`
+	DownloadCache DownloadCacheConfig `json:"downloadCache"`
+	TrustedCA     TrustedCAConfig     `json:"trustedCA"`
+	Maintenance   MaintenanceConfig   `json:"maintenance"`
+
+	HealthChecks []HealthCheckConfig `json:"healthChecks,omitempty"` // Site-specific probes reported in node status
+
+	Profiles map[string]ProfileConfig `json:"profiles,omitempty"` // Node pool profiles merged over the node settings, by lowercase name
+
+	resolvedKubernetesVersion string // Kubernetes version selected for kubernetes.version "auto"
+	migratedFrom              int    // Schema version of the loaded file, before it was upgraded
+	clientSecretReference     string // Reference the service principal secret was resolved from
+	profile                   string // Node pool profile merged over the node settings
 }
 
 // AzureConfig holds Azure-specific configuration required for connecting to Azure services.
@@ -21,12 +49,29 @@ type Config struct {
 type AzureConfig struct {
 	SubscriptionID   string                  `json:"subscriptionId"`             // Azure subscription ID
 	TenantID         string                  `json:"tenantId"`                   // Azure tenant ID
-	Cloud            string                  `json:"cloud"`                      // Azure cloud environment (defaults to AzurePublicCloud)
+	Cloud            string                  `json:"cloud"`                      // AzurePublicCloud, AzureChinaCloud or AzureUSGovernment (defaults to AzurePublicCloud)
 	ServicePrincipal *ServicePrincipalConfig `json:"servicePrincipal,omitempty"` // Optional service principal authentication
+	WorkloadIdentity *WorkloadIdentityConfig `json:"workloadIdentity,omitempty"` // Optional federated credential authentication
 	Arc              *ArcConfig              `json:"arc"`                        // Azure Arc machine configuration
 	TargetCluster    *TargetClusterConfig    `json:"targetCluster"`              // Target AKS cluster configuration
+	KeyVault         *KeyVaultConfig         `json:"keyVault,omitempty"`         // Key Vault ${keyvault:name} secret references are fetched from
+}
+
+// KeyVaultConfig holds the Azure Key Vault secrets referenced as ${keyvault:name} are fetched from when the
+// configuration is loaded. The agent reads them with the managed identity of the machine, the Arc identity of
+// a connected machine or the identity of an Azure VM, which needs the Key Vault Secrets User role.
+type KeyVaultConfig struct {
+	VaultURL string `json:"vaultUrl"` // Vault URL, e.g. https://flex-secrets.vault.azure.net
+	ClientID string `json:"clientId"` // Client ID of a user-assigned managed identity (default: the system-assigned or Arc identity)
 }
 
+// Azure cloud environments of azure.cloud
+const (
+	CloudAzurePublic       = "AzurePublicCloud"
+	CloudAzureChina        = "AzureChinaCloud"
+	CloudAzureUSGovernment = "AzureUSGovernment"
+)
+
 // ServicePrincipalConfig holds Azure service principal authentication configuration.
 // When provided, service principal authentication will be used instead of Azure CLI.
 type ServicePrincipalConfig struct {
@@ -35,10 +80,22 @@ type ServicePrincipalConfig struct {
 	ClientSecret string `json:"clientSecret"` // Azure AD application client secret
 }
 
+// WorkloadIdentityConfig holds federated credential (workload identity) authentication configuration.
+// The Entra ID application trusts tokens an external identity provider issues to this machine; the agent
+// reads the token from TokenFile and exchanges it as a client assertion, so no secret is stored on the machine.
+// When provided, it is used instead of Azure CLI.
+type WorkloadIdentityConfig struct {
+	TenantID  string `json:"tenantId"`  // Azure AD tenant ID
+	ClientID  string `json:"clientId"`  // Azure AD application (client) ID with the federated credential
+	TokenFile string `json:"tokenFile"` // Absolute path of the federated token, kept current by the identity provider
+}
+
 // TargetClusterConfig holds configuration for the target AKS cluster the ARC machine will connect to.
 type TargetClusterConfig struct {
-	ResourceID        string `json:"resourceId"` // Full resource ID of the target AKS cluster
-	Location          string `json:"location"`   // Azure region of the cluster (e.g., "eastus", "westus2")
+	ResourceID        string `json:"resourceId"`    // Full resource ID of the target AKS cluster
+	Location          string `json:"location"`      // Azure region of the cluster (e.g., "eastus", "westus2")
+	APIServerIP       string `json:"apiServerIP"`   // Private IP of the API server, mapped to its FQDN in /etc/hosts for sites without private DNS
+	APIServerFQDN     string `json:"apiServerFQDN"` // FQDN mapped to apiServerIP (default: the cluster's private FQDN)
 	Name              string // will be populated from ResourceID
 	ResourceGroup     string // will be populated from ResourceID
 	SubscriptionID    string // will be populated from ResourceID
@@ -47,58 +104,264 @@ type TargetClusterConfig struct {
 
 // ArcConfig holds Azure Arc machine configuration for registering the machine with Azure Arc.
 type ArcConfig struct {
-	Enabled       bool              `json:"enabled"`       // Whether to enable Azure Arc registration
-	MachineName   string            `json:"machineName"`   // Name for the Arc machine resource
-	Tags          map[string]string `json:"tags"`          // Tags to apply to the Arc machine
-	ResourceGroup string            `json:"resourceGroup"` // Azure resource group for Arc machine
-	Location      string            `json:"location"`      // Azure region for Arc machine
+	Enabled        bool              `json:"enabled"`        // Whether to enable Azure Arc registration
+	MachineName    string            `json:"machineName"`    // Name for the Arc machine resource
+	Tags           map[string]string `json:"tags"`           // Tags to apply to the Arc machine
+	ResourceGroup  string            `json:"resourceGroup"`  // Azure resource group for Arc machine
+	Location       string            `json:"location"`       // Azure region for Arc machine
+	SiteTags       bool              `json:"siteTags"`       // Merge site settings from aks-flex-node-* tags on the Arc machine into the agent configuration
+	ReportProgress bool              `json:"reportProgress"` // Report the bootstrap phase, step and last error in aks-flex-node-bootstrap-* tags of the Arc machine
+
+	MachineIDSource string `json:"machineIdSource"` // hostname, smbios or config: identity naming the Arc machine and the node (default: hostname)
+	MachineID       string `json:"machineId"`       // Machine identity GUID provisioned by the operator, required with machineIdSource config
 }
 
+// Sources of the machine identity that names the Arc machine and the node
+const (
+	MachineIDSourceHostname = "hostname" // the system hostname, which reimaging may change
+	MachineIDSourceSMBIOS   = "smbios"   // the SMBIOS system UUID in the device firmware, which survives reimaging
+	MachineIDSourceConfig   = "config"   // the GUID in azure.arc.machineId
+)
+
 // AgentConfig holds agent-specific operational configuration.
 type AgentConfig struct {
-	LogLevel string `json:"logLevel"` // Logging level: debug, info, warning, error
-	LogDir   string `json:"logDir"`   // Directory for log files
+	LogLevel           string                   `json:"logLevel"`                    // Logging level: debug, info, warning, error
+	LogDir             string                   `json:"logDir"`                      // Directory for log files
+	LogFormat          string                   `json:"logFormat"`                   // Log output format: text or json (default: text)
+	MaintenanceWindow  *MaintenanceWindowConfig `json:"maintenanceWindow,omitempty"` // Restrict disruptive daemon actions to this window
+	MetricsAddress     string                   `json:"metricsAddress"`              // host:port to serve Prometheus metrics on (default: disabled)
+	DiagnosticsAddress string                   `json:"diagnosticsAddress"`          // Loopback host:port to serve the read-only diagnostics endpoint on in daemon mode (default: disabled)
+	UpdateCheck        *UpdateCheckConfig       `json:"updateCheck,omitempty"`       // Report when a newer agent is published on a release channel
+	EgressIPEndpoint   string                   `json:"egressIPEndpoint"`            // URL answering with the caller's public IP in plain text; enables the shared egress IP check
+	API                *APIConfig               `json:"api,omitempty"`               // Listeners of the orchestration API served by the serve command
+	Healthz            *HealthzConfig           `json:"healthz,omitempty"`           // Where the daemon reports its own health for external watchdogs
+	Retry              *RetryConfig             `json:"retry,omitempty"`             // How downloads and Azure API calls are retried
+	ParallelSteps      int                      `json:"parallelSteps"`               // Bootstrap steps run at once where their dependencies allow, such as the runc, containerd and Kubernetes downloads (default: 3, 1 runs them one at a time)
+	SkipPreflight      bool                     `json:"skipPreflight"`               // Bootstrap even when critical host preflight checks fail, which are then only logged
+}
+
+// RetryConfig defines how downloads and Azure API calls are retried when they fail with a transient error.
+// The delay between attempts starts at initialDelay and doubles up to maxDelay, with random jitter.
+type RetryConfig struct {
+	Attempts       int    `json:"attempts"`       // Total attempts including the first (default: 5)
+	InitialDelay   string `json:"initialDelay"`   // Delay before the first retry (default: 5s)
+	MaxDelay       string `json:"maxDelay"`       // Upper bound of the delay between attempts (default: 30s)
+	AttemptTimeout string `json:"attemptTimeout"` // Bound on a single attempt (default: the timeout of the operation)
+}
+
+// APIConfig defines where the serve command exposes the orchestration API.
+// The Unix socket is always served; the TCP address additionally serves it over mutual TLS.
+type APIConfig struct {
+	SocketPath   string `json:"socketPath"`   // Unix socket for local clients (default: /run/aks-flex-node/api.sock)
+	Address      string `json:"address"`      // host:port for remote orchestrators (default: disabled)
+	TLSCertFile  string `json:"tlsCertFile"`  // Server certificate presented on the TCP address
+	TLSKeyFile   string `json:"tlsKeyFile"`   // Private key of the server certificate
+	ClientCAFile string `json:"clientCAFile"` // CA bundle that client certificates must be issued by
+}
+
+// HealthzConfig defines where the agent daemon serves /healthz and /readyz, which report whether its loop is
+// running and keeps the node status current. The Unix socket is served unless the endpoint is disabled.
+type HealthzConfig struct {
+	Disabled   bool   `json:"disabled"`   // Do not serve the daemon health
+	SocketPath string `json:"socketPath"` // Unix socket for local watchdogs (default: /run/aks-flex-node/healthz.sock)
+	Address    string `json:"address"`    // host:port to also serve on over plain HTTP, e.g. 127.0.0.1:10264 (default: disabled)
+}
+
+// UpdateCheckConfig defines the release channel manifest the daemon compares the running agent version against.
+type UpdateCheckConfig struct {
+	ManifestURL string `json:"manifestUrl"` // HTTP(S) URL of the channel manifest, e.g. the "latest" channel
+	Interval    string `json:"interval"`    // How often to check (default: 6h)
+}
+
+// MaintenanceWindowConfig defines a recurring window in which the daemon may take disruptive actions
+// such as re-bootstrapping the node. Monitoring continues outside the window.
+type MaintenanceWindowConfig struct {
+	Schedule string `json:"schedule"` // Cron expression for window starts, e.g. "0 2 * * sat,sun"
+	Duration string `json:"duration"` // How long each window stays open, e.g. "4h"
+	TimeZone string `json:"timeZone"` // IANA time zone the schedule is evaluated in (default: UTC)
+}
+
+// MaintenanceConfig restricts when and which disruptive actions the daemon takes. Monitoring continues at all times.
+type MaintenanceConfig struct {
+	Windows       []MaintenanceWindowConfig `json:"windows,omitempty"`       // Disruptive actions run while any window or agent.maintenanceWindow is open (default: at any time)
+	MaxDisruption string                    `json:"maxDisruption,omitempty"` // Actions only start when the open window stays open this long, e.g. "1h"
+	Allow         []string                  `json:"allow,omitempty"`         // Disruptive actions the daemon may take (default: all)
+	Deny          []string                  `json:"deny,omitempty"`          // Disruptive actions the daemon never takes
+}
+
+// Disruptive daemon actions governed by the maintenance settings
+const (
+	MaintenanceActionRebootstrap      = "rebootstrap"      // re-bootstrap a node that drifted from its configuration
+	MaintenanceActionUpgrade          = "upgrade"          // upgrade kubelet to the cluster's Kubernetes version
+	MaintenanceActionContainerdRepair = "containerdRepair" // restore the containerd configuration and restart containerd
+	MaintenanceActionReconfigure      = "reconfigure"      // restart components for a changed configuration
+	MaintenanceActionCertificateReset = "certificateReset" // reset a kubelet certificate kubelet did not rotate
+)
+
+// MaintenanceActions lists the disruptive daemon actions governed by the maintenance settings
+var MaintenanceActions = []string{
+	MaintenanceActionRebootstrap,
+	MaintenanceActionUpgrade,
+	MaintenanceActionContainerdRepair,
+	MaintenanceActionReconfigure,
+	MaintenanceActionCertificateReset,
 }
 
 // KubernetesConfig holds configuration settings for Kubernetes components.
 type KubernetesConfig struct {
-	Version     string `json:"version"`
-	URLTemplate string `json:"urlTemplate"`
+	Version     string          `json:"version"` // Kubernetes version of kubelet, "auto" or empty to follow the cluster's current version
+	URLTemplate string          `json:"urlTemplate"`
+	Checksum    *ChecksumConfig `json:"checksum,omitempty"`    // Verifies the Kubernetes node binaries archive
+	AutoUpgrade string          `json:"autoUpgrade,omitempty"` // How the daemon follows cluster upgrades: none (default), patch or minor
 }
 
+// KubernetesVersionAuto selects the cluster's current Kubernetes version for kubelet during the cluster preflight
+const KubernetesVersionAuto = "auto"
+
+// Policies of kubernetes.autoUpgrade
+const (
+	AutoUpgradeNone  = "none"  // re-bootstrap the node when the cluster version changes
+	AutoUpgradePatch = "patch" // drain and upgrade the node to new patch versions of its minor version
+	AutoUpgradeMinor = "minor" // drain and upgrade the node to new patch and minor versions
+)
+
 // RuntimeConfig holds configuration settings for the container runtime (runc).
 type RuntimeConfig struct {
-	Version string `json:"version"`
-	URL     string `json:"url"`
+	Version  string          `json:"version"`
+	URL      string          `json:"url"`
+	Checksum *ChecksumConfig `json:"checksum,omitempty"` // Verifies the runc binary
 }
 
 // ContainerdConfig holds configuration settings for the containerd runtime.
 type ContainerdConfig struct {
-	Version        string `json:"version"`
-	PauseImage     string `json:"pauseImage"`
-	MetricsAddress string `json:"metricsAddress"`
+	Version                string              `json:"version"`
+	PauseImage             string              `json:"pauseImage"`
+	MetricsAddress         string              `json:"metricsAddress"`
+	Shared                 bool                `json:"shared"`                  // Containerd also runs workloads outside Kubernetes, which unbootstrap leaves in place
+	MaxConcurrentDownloads int                 `json:"maxConcurrentDownloads"`  // Layers pulled at once per image, 0 keeps the containerd default of 3
+	GC                     *ContainerdGCConfig `json:"gc,omitempty"`            // Garbage collection tuning, containerd defaults when unset
+	Checksum               *ChecksumConfig     `json:"checksum,omitempty"`      // Verifies the containerd archive
+	Registries             []RegistryConfig    `json:"registries,omitempty"`    // Mirrors, TLS settings and credentials of image registries
+	ConfigPatch            string              `json:"configPatch,omitempty"`   // TOML merged over the generated config.toml, its values win
+	ConfigImports          []string            `json:"configImports,omitempty"` // Drop-in TOML files or globs config.toml imports, e.g. /etc/containerd/conf.d/*.toml
+}
+
+// DefaultRegistryHost is the registry host whose settings apply to registries without their own entry
+const DefaultRegistryHost = "_default"
+
+// RegistryConfig holds how containerd pulls images of a registry. It is rendered to the hosts.toml of the
+// registry in /etc/containerd/certs.d, credentials to the containerd configuration.
+type RegistryConfig struct {
+	Host     string   `json:"host"`     // Registry as written in image names, e.g. docker.io or registry.example.com:5000, or _default
+	Mirrors  []string `json:"mirrors"`  // Mirror endpoints tried in order before the registry, e.g. https://mirror.local:5000 or http://10.0.0.5:5000
+	Insecure bool     `json:"insecure"` // Skip TLS certificate verification of the registry and its mirrors
+	CAFile   string   `json:"caFile"`   // CA bundle the registry and mirror certificates are verified with
+	Username string   `json:"username"` // User of the registry
+	Password string   `json:"password"` // Password or token of the user, may be a secret reference such as ${file:/path}
+}
+
+// ContainerdGCConfig tunes the containerd garbage collection scheduler.
+// Zero values keep the containerd defaults.
+type ContainerdGCConfig struct {
+	PauseThreshold    float64 `json:"pauseThreshold"`    // Fraction of time collections may block the daemon (default: 0.02)
+	DeletionThreshold int     `json:"deletionThreshold"` // Deletions that trigger a collection (default: 0, never)
+	MutationThreshold int     `json:"mutationThreshold"` // Mutations that trigger a collection (default: 100)
+	ScheduleDelay     string  `json:"scheduleDelay"`     // Delay before a triggered collection (default: 0ms)
+	StartupDelay      string  `json:"startupDelay"`      // Delay of the first collection after containerd starts (default: 100ms)
 }
 
 // NodeConfig holds configuration settings for the Kubernetes node.
 type NodeConfig struct {
-	MaxPods int               `json:"maxPods"`
-	Labels  map[string]string `json:"labels"`
-	Kubelet KubeletConfig     `json:"kubelet"`
+	MaxPods          int               `json:"maxPods"`
+	PodsPerCore      int               `json:"podsPerCore"` // Pods per CPU core, capped by maxPods (default: 0, only maxPods applies)
+	PodCIDR          string            `json:"podCIDR"`     // IPv4 subnet the bridge CNI or the Cilium IPAM assigns pod IPs from, must hold maxPods pods with bridge (default: 10.244.0.0/16)
+	ServiceCIDR      string            `json:"serviceCIDR"` // Service CIDR of the cluster, the cluster DNS IP is derived from (default: 10.0.0.0/16)
+	Labels           map[string]string `json:"labels"`
+	Taints           []string          `json:"taints"`          // Taints to register the node with, in key=value:Effect format
+	Annotations      map[string]string `json:"annotations"`     // Annotations applied to the node after it registers
+	ManagedPrefixes  []string          `json:"managedPrefixes"` // Label and taint key prefixes the agent owns, unconfigured ones are removed from the node
+	Kubelet          KubeletConfig     `json:"kubelet"`
+	HostnameOverride string            `json:"hostnameOverride"` // Node name to register instead of the system hostname
+	ReplaceExisting  bool              `json:"replaceExisting"`  // Replace a Ready node with the same name already in the cluster
+	IPTablesBackend  string            `json:"iptablesBackend"`  // nft or legacy: iptables backend for kubelet and pods (default: detected)
+	ReadyTimeout     string            `json:"readyTimeout"`     // How long bootstrap waits for the node to report Ready before it fails, e.g. "5m" (default: not waited for)
+	SiteID           string            `json:"siteId"`           // Site the node runs at, applied as the aks.azure.com/flex-site fleet label
+	FleetLabels      bool              `json:"fleetLabels"`      // Apply and reconcile the discovered site, hardware profile and agent version labels
+	GPU              *GPUConfig        `json:"gpu,omitempty"`    // NVIDIA GPU setup for pods
+	Drain            *DrainConfig      `json:"drain,omitempty"`  // How unbootstrap drains the node before removing it from the cluster
 }
 
+// ProfileConfig holds the node settings of a node pool profile. The profile selected with --profile, or else the
+// one whose hostnames match the machine's hostname, is merged over the node settings when the configuration loads.
+type ProfileConfig struct {
+	Hostnames []string          `json:"hostnames,omitempty"` // Hostname patterns selecting the profile, e.g. "gpu-*"
+	Labels    map[string]string `json:"labels,omitempty"`    // Node labels added to node.labels, replacing labels with the same key
+	Taints    []string          `json:"taints,omitempty"`    // Taints added to node.taints, replacing taints with the same key and effect
+	MaxPods   int               `json:"maxPods,omitempty"`   // Replaces node.maxPods
+	GPU       *GPUConfig        `json:"gpu,omitempty"`       // Replaces node.gpu
+}
+
+// DrainConfig holds how unbootstrap drains the node while kubelet still runs: the node is cordoned, its pods
+// are evicted and the Node object is deleted once the drain completes
+type DrainConfig struct {
+	Disabled    bool   `json:"disabled"`    // Leave the node and its pods in the cluster on unbootstrap
+	GracePeriod string `json:"gracePeriod"` // Termination grace period of the evicted pods, e.g. "30s" (default: each pod's own)
+	Timeout     string `json:"timeout"`     // How long the drain may take before unbootstrap gives up on it, e.g. "10m" (default: 5m)
+	Force       bool   `json:"force"`       // Also delete pods no controller manages, which are not recreated elsewhere
+}
+
+// GPUConfig holds the NVIDIA GPU setup: the driver, the NVIDIA container toolkit and the containerd runtime pods
+// select with the nvidia RuntimeClass
+type GPUConfig struct {
+	Enabled        bool     `json:"enabled"`        // Set up the node for NVIDIA GPU workloads
+	DriverPackages []string `json:"driverPackages"` // Driver packages to install when no NVIDIA driver is loaded, e.g. nvidia-headless-550-server and nvidia-utils-550-server (default: the driver must be present)
+}
+
+// iptables backends
+const (
+	IPTablesBackendNFT    = "nft"    // iptables-nft, the nf_tables based iptables of modern distributions
+	IPTablesBackendLegacy = "legacy" // iptables-legacy, the x_tables based iptables
+)
+
 // KubeletConfig holds kubelet-specific configuration settings.
 type KubeletConfig struct {
-	KubeReserved         map[string]string `json:"kubeReserved"`
-	EvictionHard         map[string]string `json:"evictionHard"`
-	Verbosity            int               `json:"verbosity"`
-	ImageGCHighThreshold int               `json:"imageGCHighThreshold"`
-	ImageGCLowThreshold  int               `json:"imageGCLowThreshold"`
-	DNSServiceIP         string            `json:"dnsServiceIP"` // Cluster DNS service IP (default: 10.0.0.10 for AKS)
+	KubeReserved              map[string]string `json:"kubeReserved"`
+	EvictionHard              map[string]string `json:"evictionHard"`
+	Verbosity                 int               `json:"verbosity"`
+	ImageGCHighThreshold      int               `json:"imageGCHighThreshold"`
+	ImageGCLowThreshold       int               `json:"imageGCLowThreshold"`
+	DNSServiceIP              string            `json:"dnsServiceIP"`              // Cluster DNS service IP (default: tenth address of node.serviceCIDR, 10.0.0.10 for AKS)
+	AdoptExisting             bool              `json:"adoptExisting"`             // Adopt a healthy kubelet already joined to the target cluster instead of replacing it
+	RemoveBootstrapKubeconfig bool              `json:"removeBootstrapKubeconfig"` // Remove the bootstrap kubeconfig and token script once kubelet has its client certificate
+	CloudProvider             string            `json:"cloudProvider"`             // "external" to let a cloud controller manager initialize the node (default: unmanaged node)
+	ProviderID                string            `json:"providerID"`                // Node spec.providerID expected by the cloud controller manager
+	CPUManagerPolicy          string            `json:"cpuManagerPolicy"`          // none or static (exclusive CPUs for Guaranteed pods)
+	TopologyManagerPolicy     string            `json:"topologyManagerPolicy"`     // none, best-effort, restricted or single-numa-node
+	ReservedSystemCPUs        string            `json:"reservedSystemCPUs"`        // cpuset kept for system and kubelet daemons, e.g. "0-1"
+	MemoryManagerPolicy       string            `json:"memoryManagerPolicy"`       // None or Static (NUMA-aware memory for Guaranteed pods)
+	ReservedMemory            string            `json:"reservedMemory"`            // per-NUMA reservation for the Static memory manager, e.g. "0:memory=1Gi"
+	SwapBehavior              string            `json:"swapBehavior"`              // NoSwap or LimitedSwap to let kubelet run with swap enabled (default: kubelet refuses to start with swap)
+	KeyProtection             string            `json:"keyProtection"`             // file, tpm or auto: how the kubelet client key is protected at rest (default: file)
+	ResolvConf                string            `json:"resolvConf"`                // resolv.conf handed to pods (default: detected from the host DNS stack)
+	KubeAPIQPS                int               `json:"kubeAPIQPS"`                // Queries per second kubelet sends to the API server (default: kubelet's 50)
+	KubeAPIBurst              int               `json:"kubeAPIBurst"`              // Burst of queries kubelet sends to the API server (default: kubelet's 100)
+	FeatureGates              []string          `json:"featureGates"`              // Kubelet feature gates as Name=bool, e.g. "KubeletTracing=true"
+	ExtraFlags                []string          `json:"extraFlags"`                // Additional kubelet flags such as --image-pull-progress-deadline=5m, appended after the generated flags
+	ServerTLSBootstrap        bool              `json:"serverTLSBootstrap"`        // Request the kubelet serving certificate from the cluster instead of self-signing it, so metrics-server can verify it
+	ApproveServingCSR         bool              `json:"approveServingCSR"`         // Approve the node's pending serving certificate requests with the agent's cluster credentials (default: wait for a cluster approver)
 }
 
+// Kubelet client key protection levels
+const (
+	KeyProtectionFile = "file" // key stored as a plain file under /var/lib/kubelet/pki
+	KeyProtectionTPM  = "tpm"  // key kept in memory and stored sealed to the machine's TPM; bootstrap fails without a TPM
+	KeyProtectionAuto = "auto" // tpm when the machine has a usable TPM, file otherwise
+)
+
 // PathsConfig holds file system paths used by the agent for Kubernetes and CNI configurations.
 type PathsConfig struct {
 	Kubernetes KubernetesPathsConfig `json:"kubernetes"`
+	StagingDir string                `json:"stagingDir"` // Directory installers download and extract artifacts into, on a filesystem mounted without noexec (default: /var/lib/aks-flex-node/tmp)
 }
 
 // KubernetesPathsConfig holds file system paths related to Kubernetes components.
@@ -112,12 +375,203 @@ type KubernetesPathsConfig struct {
 
 // CNIPathsConfig holds file system paths related to CNI plugins and configurations.
 type CNIConfig struct {
-	Version string `json:"version"`
+	Version                 string          `json:"version"`
+	AllowUnsupportedNetwork bool            `json:"allowUnsupportedNetwork"` // Proceed even if the cluster network profile is incompatible with the node CNI
+	KubeProxyReplacement    bool            `json:"kubeProxyReplacement"`    // BYO Cilium replaces kube-proxy, so kube-proxy host setup is skipped and eBPF support is checked
+	Mode                    string          `json:"mode"`                    // bridge, cilium, azure-cni or none: how pod networking is set up (default: bridge)
+	PodCIDR                 string          `json:"podCIDR"`                 // Same as node.podCIDR, which is preferred
+	Gateway                 string          `json:"gateway"`                 // Bridge gateway address within node.podCIDR (default: first address of node.podCIDR)
+	CiliumVersion           string          `json:"ciliumVersion"`           // Cilium installed into the cluster with mode cilium (default: component defaults)
+	Checksum                *ChecksumConfig `json:"checksum,omitempty"`      // Verifies the CNI plugins archive
 }
 
+// CNI modes of cni.mode
+const (
+	CNIModeBridge   = "bridge"    // bridge and host-local IPAM written by the agent
+	CNIModeCilium   = "cilium"    // Cilium installed into a BYO CNI cluster with the cilium CLI, which writes the node CNI config
+	CNIModeAzureCNI = "azure-cni" // Azure CNI Overlay with pod IPs allocated by Azure CNS running on the node
+	CNIModeNone     = "none"      // CNI plugins only; the operator brings the CNI configuration
+)
+
 // NPDConfig holds configuration settings for the Node Problem Detector (NPD).
 type NPDConfig struct {
-	Version string `json:"version"`
+	Version             string          `json:"version"`
+	Checksum            *ChecksumConfig `json:"checksum,omitempty"`  // Verifies the Node Problem Detector archive
+	Monitors            []string        `json:"monitors"`            // Monitors of the NPD release to run: kernel, systemd, system-stats (default: kernel)
+	CustomPluginConfigs []string        `json:"customPluginConfigs"` // Custom plugin monitor configurations NPD runs next to the flex node checks
+}
+
+// Monitors of the NPD release that npd.monitors selects
+const (
+	NPDMonitorKernel      = "kernel"       // Kernel log problems such as OOM kills, hung tasks and filesystem errors
+	NPDMonitorSystemd     = "systemd"      // Restarts of kubelet, containerd and other units from the journal
+	NPDMonitorSystemStats = "system-stats" // CPU, memory and disk metrics, without node conditions
+)
+
+// ChecksumConfig pins the content of a downloaded component archive, which is verified before it is installed.
+// Set one of the fields; both apply to the archive of the configured version and the node's architecture.
+type ChecksumConfig struct {
+	SHA256 string `json:"sha256"` // Expected SHA-256 of the archive
+	URL    string `json:"url"`    // Checksum file in sha256sum format listing the archive, e.g. the .sha256sum published with a release
+}
+
+// PackagesConfig holds configuration for the host packages installed before bootstrap.
+type PackagesConfig struct {
+	OfflineDir string   `json:"offlineDir"` // Directory of .deb or .rpm files installed instead of using package repositories
+	Additional []string `json:"additional"` // Extra packages to install alongside the ones components require
+}
+
+// TrustedCAConfig holds the private CA certificates the node trusts, e.g. of a TLS-intercepting proxy or an
+// internal registry. They are installed into the system trust store used by containerd, Arc and the agent.
+type TrustedCAConfig struct {
+	Bundles          []string `json:"bundles"`          // PEM files of CA certificates to trust, as absolute paths
+	InjectKubeconfig bool     `json:"injectKubeconfig"` // Also trust the bundles for the API server, for proxies that intercept cluster traffic
+}
+
+// DownloadCacheConfig holds configuration for the cache of component archives kept across re-bootstraps.
+type DownloadCacheConfig struct {
+	Disabled  bool `json:"disabled"`  // Download components on every install instead of reusing cached archives
+	MaxSizeMB int  `json:"maxSizeMB"` // Size the cache is trimmed to, least recently used archives first
+}
+
+// KubeVIPConfig holds configuration for the optional kube-vip load balancer, which announces
+// addresses for Services of type LoadBalancer on the local network of sites without an Azure load balancer.
+type KubeVIPConfig struct {
+	Enabled          bool   `json:"enabled"`
+	Version          string `json:"version"`          // kube-vip image tag
+	Image            string `json:"image"`            // Full kube-vip image reference, overrides version (e.g. a local registry mirror)
+	Interface        string `json:"interface"`        // Network interface VIPs are announced on (default: interface of the default route)
+	AddressRange     string `json:"addressRange"`     // Comma-separated IPv4 ranges assigned to Services, e.g. 192.168.10.200-192.168.10.220
+	ServiceNamespace string `json:"serviceNamespace"` // Only assign the range to Services in this namespace (default: all namespaces)
+}
+
+// KubeProxyConfig holds the settings of the kube-proxy the agent runs on the node as a systemd service, for
+// clusters whose kube-proxy DaemonSet does not schedule onto flex nodes.
+type KubeProxyConfig struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode"` // iptables, ipvs or nftables (default: iptables)
+}
+
+// NodeLocalDNSConfig holds the settings of the DNS cache the agent runs on the node as a static pod. Pods then
+// resolve names through the cache on a link-local address instead of the cluster DNS service.
+type NodeLocalDNSConfig struct {
+	Enabled bool   `json:"enabled"`
+	Image   string `json:"image"`   // node-local-dns image (default: component defaults)
+	LocalIP string `json:"localIP"` // Link-local address the cache listens on and kubelet passes to pods (default: 169.254.20.10)
+}
+
+// DefaultNodeLocalDNSIP is the address the node-local DNS cache listens on, as in the upstream deployment
+const DefaultNodeLocalDNSIP = "169.254.20.10"
+
+// SystemConfig holds the host settings the agent applies besides the ones Kubernetes requires. The values they
+// replace are recorded when they are first applied and restored by unbootstrap.
+type SystemConfig struct {
+	Sysctls       map[string]string `json:"sysctls,omitempty"`       // Kernel parameters applied over the agent defaults, e.g. {"fs.file-max": "1048576"}
+	Swap          string            `json:"swap"`                    // keep or disable (default: keep, swap is left as it is)
+	KernelModules []string          `json:"kernelModules,omitempty"` // Modules loaded now and at boot besides the ones Kubernetes requires
+}
+
+// Swap policies of system.swap
+const (
+	SwapPolicyKeep    = "keep"
+	SwapPolicyDisable = "disable"
+)
+
+// TimeSyncConfig holds the settings of the time synchronization service, as kubelets with a skewed clock fail
+// TLS authentication. The clock offset is reported in the status file whether or not the agent manages the service.
+type TimeSyncConfig struct {
+	Enabled  bool     `json:"enabled"`           // Make sure chrony or systemd-timesyncd runs, installing chrony when neither is installed
+	Servers  []string `json:"servers,omitempty"` // NTP servers for isolated networks (default: the servers of the distribution)
+	MaxDrift string   `json:"maxDrift"`          // Clock offset above which the status file carries a warning (default: 1s)
+}
+
+// DefaultTimeSyncMaxDrift is the clock offset above which the status file carries a warning by default
+const DefaultTimeSyncMaxDrift = time.Second
+
+// GetTimeSyncMaxDrift returns the clock offset above which the status file carries a warning
+func (cfg *Config) GetTimeSyncMaxDrift() time.Duration {
+	if cfg.TimeSync.MaxDrift != "" {
+		if d, err := time.ParseDuration(cfg.TimeSync.MaxDrift); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultTimeSyncMaxDrift
+}
+
+// StorageConfig places the containerd and kubelet data directories on a data disk, or on a directory of
+// another mounted filesystem, bind mounted over /var/lib/containerd and /var/lib/kubelet
+type StorageConfig struct {
+	Device     string `json:"device,omitempty"`     // Block device or partition holding the data, e.g. /dev/disk/by-id/nvme-data-part1
+	Format     bool   `json:"format,omitempty"`     // Create a filesystem on device when it has none, an existing filesystem is never formatted
+	Filesystem string `json:"filesystem,omitempty"` // ext4 or xfs, the filesystem format creates (default: ext4)
+	MountPath  string `json:"mountPath,omitempty"`  // Where device is mounted (default: /mnt/aks-flex-node-data)
+	Path       string `json:"path,omitempty"`       // Directory on an already mounted filesystem holding the data, instead of device
+}
+
+// Default mount point and filesystem of storage.device
+const (
+	DefaultStorageMountPath  = "/mnt/aks-flex-node-data"
+	DefaultStorageFilesystem = "ext4"
+)
+
+// IsStorageConfigured returns true when the containerd and kubelet data directories are placed on another disk
+func (cfg *Config) IsStorageConfigured() bool {
+	return cfg.Storage.Device != "" || cfg.Storage.Path != ""
+}
+
+// StorageRoot returns the directory holding the containerd and kubelet data directories, empty when the data
+// stays on the root disk
+func (cfg *Config) StorageRoot() string {
+	if cfg.Storage.Device != "" {
+		return cfg.Storage.MountPath
+	}
+	return cfg.Storage.Path
+}
+
+// ClusterDNS returns the DNS server kubelet configures pods with: the node-local DNS cache when it is enabled,
+// otherwise the cluster DNS service
+func (cfg *Config) ClusterDNS() string {
+	if cfg.NodeLocalDNS.Enabled {
+		return cfg.NodeLocalDNS.LocalIP
+	}
+	return cfg.Node.Kubelet.DNSServiceIP
+}
+
+// Proxy modes of kubeProxy.mode
+const (
+	KubeProxyModeIPTables = "iptables"
+	KubeProxyModeIPVS     = "ipvs"
+	KubeProxyModeNFTables = "nftables"
+)
+
+// Health check actions taken when a check fails
+const (
+	HealthCheckActionReport    = "report"    // only surface the result in node status
+	HealthCheckActionRemediate = "remediate" // re-bootstrap the node
+	HealthCheckActionGate      = "gate"      // hold off re-bootstrapping until the check passes
+)
+
+// HealthCheckConfig defines a custom health probe for a site-specific dependency such as a VPN tunnel
+// or a local storage mount. Exactly one of Command or URL must be set.
+type HealthCheckConfig struct {
+	Name           string   `json:"name"`
+	Command        []string `json:"command"`        // Executable and arguments, healthy when it exits 0
+	URL            string   `json:"url"`            // HTTP(S) endpoint, healthy when it answers with ExpectedStatus
+	ExpectedStatus int      `json:"expectedStatus"` // Expected HTTP status code (default: 200)
+	Timeout        string   `json:"timeout"`        // How long the probe may take (default: 10s)
+	Action         string   `json:"action"`         // report, remediate or gate (default: report)
+}
+
+// NeedsMigration returns the schema version of the loaded configuration file when it is older than the
+// current one, and whether it is
+func (cfg *Config) NeedsMigration() (int, bool) {
+	return cfg.migratedFrom, cfg.migratedFrom != 0 && cfg.migratedFrom != CurrentSchemaVersion
+}
+
+// ClientSecretReference returns the reference the service principal secret was resolved from, such as
+// ${keyvault:name}, or an empty string when the secret is written in the configuration file
+func (cfg *Config) ClientSecretReference() string {
+	return cfg.clientSecretReference
 }
 
 // IsSPConfigured checks if service principal credentials are provided in the configuration
@@ -128,6 +582,14 @@ func (cfg *Config) IsSPConfigured() bool {
 		cfg.Azure.ServicePrincipal.TenantID != ""
 }
 
+// IsWorkloadIdentityConfigured checks if federated credential authentication is provided in the configuration
+func (cfg *Config) IsWorkloadIdentityConfigured() bool {
+	return cfg.Azure.WorkloadIdentity != nil &&
+		cfg.Azure.WorkloadIdentity.ClientID != "" &&
+		cfg.Azure.WorkloadIdentity.TokenFile != "" &&
+		cfg.Azure.WorkloadIdentity.TenantID != ""
+}
+
 // GetArcMachineName returns the Arc machine name from configuration or defaults to the system hostname
 func (cfg *Config) GetArcMachineName() string {
 	if cfg.Azure.Arc != nil && cfg.Azure.Arc.MachineName != "" {
@@ -140,6 +602,86 @@ func (cfg *Config) GetArcMachineName() string {
 	return ""
 }
 
+// IsExternalCloudProvider checks if the node is initialized by an external cloud controller manager
+func (cfg *Config) IsExternalCloudProvider() bool {
+	return cfg.Node.Kubelet.CloudProvider == "external"
+}
+
+// IsGPUEnabled returns true when the node is set up for NVIDIA GPU workloads
+func (cfg *Config) IsGPUEnabled() bool {
+	return cfg.Node.GPU != nil && cfg.Node.GPU.Enabled
+}
+
+// IsDrainEnabled returns true unless node.drain.disabled leaves the node in the cluster on unbootstrap
+func (cfg *Config) IsDrainEnabled() bool {
+	return cfg.Node.Drain == nil || !cfg.Node.Drain.Disabled
+}
+
+// GetDrainTimeout returns how long unbootstrap waits for the node to drain
+func (cfg *Config) GetDrainTimeout() time.Duration {
+	if cfg.Node.Drain != nil && cfg.Node.Drain.Timeout != "" {
+		if d, err := time.ParseDuration(cfg.Node.Drain.Timeout); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultDrainTimeout
+}
+
+// IsManagedNodeKey returns true when a label or taint key starts with one of node.managedPrefixes, so the agent
+// removes it from the node unless it is configured
+func (cfg *Config) IsManagedNodeKey(key string) bool {
+	return slices.ContainsFunc(cfg.Node.ManagedPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// GetNodeName returns the name the node registers with, from the hostname override or the system hostname
+func (cfg *Config) GetNodeName() string {
+	if cfg.Node.HostnameOverride != "" {
+		return strings.ToLower(cfg.Node.HostnameOverride)
+	}
+	hostname, err := os.Hostname()
+	if err == nil {
+		return strings.ToLower(hostname)
+	}
+	return ""
+}
+
+// GetPodCIDR returns the subnet the bridge CNI or the Cilium IPAM assigns pod IPs from
+func (cfg *Config) GetPodCIDR() string {
+	if cfg.Node.PodCIDR != "" {
+		return cfg.Node.PodCIDR
+	}
+	if cfg.CNI.PodCIDR != "" {
+		return cfg.CNI.PodCIDR
+	}
+	return DefaultPodCIDR
+}
+
+// podCIDRField returns the name of the setting the pod subnet is taken from, for error messages
+func (cfg *Config) podCIDRField() string {
+	if cfg.Node.PodCIDR == "" && cfg.CNI.PodCIDR != "" {
+		return "cni.podCIDR"
+	}
+	return "node.podCIDR"
+}
+
+// GetServiceCIDR returns the service CIDR of the cluster
+func (cfg *Config) GetServiceCIDR() string {
+	if cfg.Node.ServiceCIDR != "" {
+		return cfg.Node.ServiceCIDR
+	}
+	return DefaultServiceCIDR
+}
+
+// GetCNIMode returns how pod networking is set up on the node
+func (cfg *Config) GetCNIMode() string {
+	if cfg.CNI.Mode != "" {
+		return cfg.CNI.Mode
+	}
+	return CNIModeBridge
+}
+
 // GetTargetClusterName returns the target AKS cluster name from configuration
 func (cfg *Config) GetTargetClusterName() string {
 	if cfg.Azure.TargetCluster != nil && cfg.Azure.TargetCluster.Name != "" {
@@ -215,12 +757,105 @@ func (cfg *Config) GetTenantID() string {
 	return cfg.Azure.TenantID
 }
 
-// GetKubernetesVersion returns the Kubernetes version from configuration
+// GetKubernetesVersion returns the Kubernetes version from configuration.
+// For kubernetes.version "auto" or omitted it returns the version selected from the cluster, empty until one was selected.
 func (cfg *Config) GetKubernetesVersion() string {
+	if cfg.IsKubernetesVersionAuto() {
+		return cfg.resolvedKubernetesVersion
+	}
 	return cfg.Kubernetes.Version
 }
 
+// SetResolvedKubernetesVersion records the Kubernetes version selected for kubernetes.version "auto"
+func (cfg *Config) SetResolvedKubernetesVersion(version string) {
+	cfg.resolvedKubernetesVersion = version
+}
+
+// KeepResolvedKubernetesVersion carries the version previous resolved for kubernetes.version "auto" over to a
+// reloaded configuration that still selects the version from the cluster, so the running node keeps its version
+func (cfg *Config) KeepResolvedKubernetesVersion(previous *Config) {
+	if previous != nil && cfg.IsKubernetesVersionAuto() && previous.IsKubernetesVersionAuto() {
+		cfg.resolvedKubernetesVersion = previous.resolvedKubernetesVersion
+	}
+}
+
+// IsKubernetesVersionAuto checks if the kubelet version is selected from the cluster's current version,
+// which is the case when kubernetes.version is "auto" or omitted
+func (cfg *Config) IsKubernetesVersionAuto() bool {
+	return cfg.Kubernetes.Version == "" || strings.EqualFold(cfg.Kubernetes.Version, KubernetesVersionAuto)
+}
+
+// IsAutoUpgradeEnabled checks if the daemon drains and upgrades the node when the cluster's Kubernetes version
+// changes, instead of re-bootstrapping it
+func (cfg *Config) IsAutoUpgradeEnabled() bool {
+	return cfg.Kubernetes.AutoUpgrade != "" && cfg.Kubernetes.AutoUpgrade != AutoUpgradeNone
+}
+
+// MaintenancePolicy returns the policy deciding when the daemon may take disruptive actions, from
+// agent.maintenanceWindow and the maintenance settings
+func (cfg *Config) MaintenancePolicy() *maintenance.Policy {
+	windowConfigs := cfg.Maintenance.Windows
+	if cfg.Agent.MaintenanceWindow != nil {
+		windowConfigs = append([]MaintenanceWindowConfig{*cfg.Agent.MaintenanceWindow}, windowConfigs...)
+	}
+	// The windows were validated when the configuration was loaded
+	var windows []*maintenance.Window
+	for _, mw := range windowConfigs {
+		if window, err := maintenance.NewWindow(mw.Schedule, mw.Duration, mw.TimeZone); err == nil {
+			windows = append(windows, window)
+		}
+	}
+	maxDisruption, _ := time.ParseDuration(cfg.Maintenance.MaxDisruption)
+	return maintenance.NewPolicy(windows, maxDisruption, cfg.Maintenance.Allow, cfg.Maintenance.Deny)
+}
+
 // IsARCEnabled checks if Azure Arc registration is enabled in the configuration
 func (cfg *Config) IsARCEnabled() bool {
 	return cfg.Azure.Arc != nil && cfg.Azure.Arc.Enabled
 }
+
+// IsSiteTagsEnabled checks if site settings are inherited from the tags of the Arc machine
+func (cfg *Config) IsSiteTagsEnabled() bool {
+	return cfg.IsARCEnabled() && cfg.Azure.Arc.SiteTags
+}
+
+// IsProgressReportingEnabled checks if bootstrap progress is reported in the tags of the Arc machine
+func (cfg *Config) IsProgressReportingEnabled() bool {
+	return cfg.IsARCEnabled() && cfg.Azure.Arc.ReportProgress
+}
+
+// IsContainerdShared checks if containerd also runs workloads outside Kubernetes that must be left in place
+func (cfg *Config) IsContainerdShared() bool {
+	return cfg != nil && cfg.Containerd.Shared
+}
+
+// GetParallelSteps returns how many bootstrap steps may run at once
+func (cfg *Config) GetParallelSteps() int {
+	if cfg == nil || cfg.Agent.ParallelSteps <= 0 {
+		return DefaultParallelSteps
+	}
+	return cfg.Agent.ParallelSteps
+}
+
+// GetRetryPolicy returns the policy downloads and Azure API calls are retried with, from agent.retry and the
+// retry defaults. Durations that do not parse are left at their defaults, Validate reports them.
+func (cfg *Config) GetRetryPolicy() retry.Policy {
+	policy := retry.Policy{Attempts: retry.DefaultAttempts, InitialDelay: retry.DefaultInitialDelay, MaxDelay: retry.DefaultMaxDelay}
+	rc := cfg.Agent.Retry
+	if rc == nil {
+		return policy
+	}
+	if rc.Attempts > 0 {
+		policy.Attempts = rc.Attempts
+	}
+	if d, err := time.ParseDuration(rc.InitialDelay); err == nil {
+		policy.InitialDelay = d
+	}
+	if d, err := time.ParseDuration(rc.MaxDelay); err == nil {
+		policy.MaxDelay = d
+	}
+	if d, err := time.ParseDuration(rc.AttemptTimeout); err == nil {
+		policy.AttemptTimeout = d
+	}
+	return policy
+}
@@ -0,0 +1,170 @@
+package defaults
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Path is the on-disk file whose values override the defaults compiled into the agent.
+// Only the fields present in the file are overridden, so a hotfix names just the broken value.
+var Path = "/etc/aks-flex-node/defaults.json"
+
+//go:embed defaults.json
+var embedded []byte
+
+// Manifest holds the component defaults used when the agent configuration does not set a value
+type Manifest struct {
+	Versions Versions `json:"versions"`
+	URLs     URLs     `json:"urls"`
+	Images   Images   `json:"images"`
+	Paths    Paths    `json:"paths"`
+
+	// Overridden reports whether the defaults file on disk was applied
+	Overridden bool `json:"-"`
+}
+
+// Versions are the component versions installed by default
+type Versions struct {
+	Containerd string `json:"containerd"`
+	Runc       string `json:"runc"`
+	CNI        string `json:"cni"`
+	NPD        string `json:"npd"`
+	KubeVIP    string `json:"kubeVip"`
+	Cilium     string `json:"cilium"`    // Cilium installed into the cluster with cni.mode cilium
+	CiliumCLI  string `json:"ciliumCli"` // cilium CLI installing it
+	AzureCNI   string `json:"azureCni"`
+}
+
+// URLs are the download URL templates of the components, filled in with the version and architecture with fmt verbs
+type URLs struct {
+	Containerd        string `json:"containerd"`        // version, version, architecture
+	Runc              string `json:"runc"`              // version, architecture
+	CNI               string `json:"cni"`               // version, architecture, version
+	NPD               string `json:"npd"`               // version, version, architecture
+	Kubernetes        string `json:"kubernetes"`        // version, architecture
+	CiliumCLI         string `json:"ciliumCli"`         // version, architecture
+	AzureCNI          string `json:"azureCni"`          // version, architecture, version
+	ContainerdWindows string `json:"containerdWindows"` // version, version, architecture
+	KubernetesWindows string `json:"kubernetesWindows"` // version, architecture
+}
+
+// Images are the container images the agent configures
+type Images struct {
+	Pause                string `json:"pause"`
+	KubeVIP              string `json:"kubeVip"` // kube-vip version
+	KubeVIPCloudProvider string `json:"kubeVipCloudProvider"`
+	NodeLocalDNS         string `json:"nodeLocalDns"`
+	Verify               string `json:"verify"` // runs the smoke test pod of the verify command
+}
+
+// Paths are the host directories the components are installed into
+type Paths struct {
+	CNIBinDir  string `json:"cniBinDir"`
+	CNIConfDir string `json:"cniConfDir"`
+}
+
+var (
+	loadOnce sync.Once
+	manifest *Manifest
+	loadErr  error
+)
+
+// Get returns the component defaults, falling back to the compiled-in defaults when the defaults file is invalid
+func Get() *Manifest {
+	m, _ := Load()
+	return m
+}
+
+// Load returns the component defaults and the error of applying the defaults file, if any.
+// The file is read once per process; the agent picks up changes to it on restart.
+func Load() (*Manifest, error) {
+	loadOnce.Do(func() {
+		manifest, loadErr = load(Path)
+	})
+	return manifest, loadErr
+}
+
+// load decodes the compiled-in defaults and applies the defaults file at path on top of them
+func load(path string) (*Manifest, error) {
+	base := &Manifest{}
+	if err := json.Unmarshal(embedded, base); err != nil {
+		panic(fmt.Sprintf("invalid embedded defaults: %v", err))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return base, nil
+		}
+		return base, fmt.Errorf("failed to read defaults file %s: %w", path, err)
+	}
+
+	overridden := *base
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&overridden); err != nil {
+		return base, fmt.Errorf("failed to parse defaults file %s: %w", path, err)
+	}
+	if err := overridden.validate(base); err != nil {
+		return base, fmt.Errorf("invalid defaults file %s: %w", path, err)
+	}
+	overridden.Overridden = true
+	return &overridden, nil
+}
+
+// validate rejects overrides that would break the installers: empty values, URL templates
+// taking a different number of values than the compiled-in template and relative paths
+func (m *Manifest) validate(base *Manifest) error {
+	for _, field := range []struct {
+		name, value, base string
+	}{
+		{"versions.containerd", m.Versions.Containerd, base.Versions.Containerd},
+		{"versions.runc", m.Versions.Runc, base.Versions.Runc},
+		{"versions.cni", m.Versions.CNI, base.Versions.CNI},
+		{"versions.npd", m.Versions.NPD, base.Versions.NPD},
+		{"versions.kubeVip", m.Versions.KubeVIP, base.Versions.KubeVIP},
+		{"versions.cilium", m.Versions.Cilium, base.Versions.Cilium},
+		{"versions.ciliumCli", m.Versions.CiliumCLI, base.Versions.CiliumCLI},
+		{"versions.azureCni", m.Versions.AzureCNI, base.Versions.AzureCNI},
+		{"urls.containerd", m.URLs.Containerd, base.URLs.Containerd},
+		{"urls.runc", m.URLs.Runc, base.URLs.Runc},
+		{"urls.cni", m.URLs.CNI, base.URLs.CNI},
+		{"urls.npd", m.URLs.NPD, base.URLs.NPD},
+		{"urls.kubernetes", m.URLs.Kubernetes, base.URLs.Kubernetes},
+		{"urls.ciliumCli", m.URLs.CiliumCLI, base.URLs.CiliumCLI},
+		{"urls.azureCni", m.URLs.AzureCNI, base.URLs.AzureCNI},
+		{"urls.containerdWindows", m.URLs.ContainerdWindows, base.URLs.ContainerdWindows},
+		{"urls.kubernetesWindows", m.URLs.KubernetesWindows, base.URLs.KubernetesWindows},
+		{"images.pause", m.Images.Pause, base.Images.Pause},
+		{"images.kubeVip", m.Images.KubeVIP, base.Images.KubeVIP},
+		{"images.kubeVipCloudProvider", m.Images.KubeVIPCloudProvider, base.Images.KubeVIPCloudProvider},
+		{"images.nodeLocalDns", m.Images.NodeLocalDNS, base.Images.NodeLocalDNS},
+		{"images.verify", m.Images.Verify, base.Images.Verify},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			return fmt.Errorf("%s must not be empty", field.name)
+		}
+		want := strings.Count(field.base, "%s")
+		if strings.Count(field.value, "%s") != want || strings.Count(field.value, "%") != want {
+			return fmt.Errorf("%s must contain exactly %d %%s placeholders like %q", field.name, want, field.base)
+		}
+	}
+	for _, url := range []string{m.URLs.Containerd, m.URLs.Runc, m.URLs.CNI, m.URLs.NPD, m.URLs.Kubernetes, m.URLs.CiliumCLI, m.URLs.AzureCNI,
+		m.URLs.ContainerdWindows, m.URLs.KubernetesWindows} {
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return fmt.Errorf("download URL %s must be an HTTP(S) URL", url)
+		}
+	}
+	for _, path := range []string{m.Paths.CNIBinDir, m.Paths.CNIConfDir} {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("path %q must be absolute", path)
+		}
+	}
+	return nil
+}
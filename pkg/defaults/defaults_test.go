@@ -0,0 +1,71 @@
+package defaults
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDefaultsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write defaults file: %v", err)
+	}
+	return path
+}
+
+func TestLoadWithoutDefaultsFile(t *testing.T) {
+	m, err := load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if m.Overridden || m.Versions.CNI == "" || m.Paths.CNIBinDir != "/opt/cni/bin" {
+		t.Errorf("load() = %+v, want the compiled-in defaults", m)
+	}
+}
+
+func TestLoadOverridesOnlyGivenFields(t *testing.T) {
+	mirror := "https://mirror.example.com/cni/v%s/cni-plugins-linux-%s-v%s.tgz"
+	path := writeDefaultsFile(t, `{"urls": {"cni": "`+mirror+`"}, "versions": {"runc": "1.2.5"}}`)
+
+	m, err := load(path)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	base, _ := load(filepath.Join(t.TempDir(), "missing.json"))
+	if !m.Overridden || m.URLs.CNI != mirror || m.Versions.Runc != "1.2.5" {
+		t.Errorf("load() = %+v, want the CNI URL and runc version overridden", m)
+	}
+	if m.URLs.Containerd != base.URLs.Containerd || m.Versions.CNI != base.Versions.CNI {
+		t.Errorf("load() changed defaults missing from the file: %+v", m)
+	}
+}
+
+func TestLoadRejectsInvalidDefaultsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "malformed JSON", content: `{"urls": `, wantErr: "failed to parse"},
+		{name: "unknown field", content: `{"urls": {"kubelet": "https://example.com"}}`, wantErr: "unknown field"},
+		{name: "missing placeholder", content: `{"urls": {"runc": "https://example.com/runc.amd64"}}`, wantErr: "urls.runc must contain exactly 2 %s placeholders"},
+		{name: "other verb", content: `{"urls": {"runc": "https://example.com/v%d/runc.%s"}}`, wantErr: "urls.runc must contain exactly 2 %s placeholders"},
+		{name: "not a URL", content: `{"urls": {"runc": "/mnt/v%s/runc.%s"}}`, wantErr: "must be an HTTP(S) URL"},
+		{name: "empty version", content: `{"versions": {"npd": ""}}`, wantErr: "versions.npd must not be empty"},
+		{name: "relative path", content: `{"paths": {"cniBinDir": "opt/cni/bin"}}`, wantErr: "must be absolute"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := load(writeDefaultsFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("load() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if m == nil || m.Overridden {
+				t.Errorf("load() = %+v, want the compiled-in defaults alongside the error", m)
+			}
+		})
+	}
+}
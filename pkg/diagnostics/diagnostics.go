@@ -0,0 +1,142 @@
+// Package diagnostics serves a read-only view of the daemon over local HTTP, so troubleshooting and edge
+// orchestration tools on the node can read the health, node status, configuration and last bootstrap of the
+// agent without parsing its logs.
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/healthz"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// Bootstrap is the outcome of the last bootstrap of the agent
+type Bootstrap struct {
+	FinishedAt time.Time                     `json:"finishedAt"`
+	Result     *bootstrapper.ExecutionResult `json:"result,omitempty"`
+	Error      string                        `json:"error,omitempty"`
+}
+
+// Server keeps what the diagnostics endpoint serves. The daemon updates it as the configuration is reloaded
+// and the node is bootstrapped again.
+type Server struct {
+	tracker    *healthz.Tracker
+	statusPath string
+
+	mu        sync.Mutex
+	config    *config.Config
+	bootstrap *Bootstrap
+}
+
+// NewServer creates a Server serving the health of tracker, the node status in statusPath and cfg
+func NewServer(tracker *healthz.Tracker, statusPath string, cfg *config.Config) *Server {
+	return &Server{
+		tracker:    tracker,
+		statusPath: statusPath,
+		config:     cfg,
+	}
+}
+
+// SetConfig sets the configuration served after a reload
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = cfg
+}
+
+// RecordBootstrap records the outcome of a bootstrap that finished now
+func (s *Server) RecordBootstrap(result *bootstrapper.ExecutionResult, err error) {
+	bootstrap := &Bootstrap{FinishedAt: time.Now().UTC(), Result: result}
+	if err != nil {
+		bootstrap.Error = err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bootstrap = bootstrap
+}
+
+// Handler serves /healthz and /readyz like the daemon health endpoint, /status with the last collected node
+// status, /config with the configuration with secrets redacted and /steps with the last bootstrap. Every path
+// only answers GET.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	health := healthz.Handler(s.tracker)
+	mux.Handle("GET /healthz", health)
+	mux.Handle("GET /readyz", health)
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		nodeStatus, err := status.ReadStatus(s.statusPath)
+		if err != nil {
+			respondError(w, http.StatusServiceUnavailable, fmt.Errorf("node status not collected yet: %w", err))
+			return
+		}
+		respond(w, http.StatusOK, nodeStatus)
+	})
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, _ *http.Request) {
+		s.mu.Lock()
+		cfg := s.config
+		s.mu.Unlock()
+		redacted, err := cfg.Redacted()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+		respond(w, http.StatusOK, redacted)
+	})
+	mux.HandleFunc("GET /steps", func(w http.ResponseWriter, _ *http.Request) {
+		s.mu.Lock()
+		bootstrap := s.bootstrap
+		s.mu.Unlock()
+		if bootstrap == nil {
+			respondError(w, http.StatusNotFound, errors.New("the agent has not bootstrapped the node since it started"))
+			return
+		}
+		respond(w, http.StatusOK, bootstrap)
+	})
+	return mux
+}
+
+// Serve serves the diagnostics on address until the context is cancelled
+func (s *Server) Serve(ctx context.Context, address string, logger *logrus.Logger) error {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("Serving diagnostics on http://%s", address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve diagnostics on %s: %w", address, err)
+	}
+	return nil
+}
+
+// respond writes value as indented JSON with code
+func respond(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+// respondError writes err as a JSON error with code
+func respondError(w http.ResponseWriter, code int, err error) {
+	respond(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/sitetags"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Fleet labels applied to the node with node.fleetLabels, so scheduling policies can target sites,
+// hardware profiles and agent versions across the fleet
+const (
+	SiteLabel            = "aks.azure.com/flex-site"
+	HardwareProfileLabel = "aks.azure.com/hw-profile"
+	AgentVersionLabel    = "aks.azure.com/agent-version"
+)
+
+// FleetLabelKeys are the labels FleetLabels may set, in sorted order
+var FleetLabelKeys = []string{AgentVersionLabel, SiteLabel, HardwareProfileLabel}
+
+// Host files the hardware is read from, below the discovery root
+const (
+	DMIVendorPath  = "/sys/class/dmi/id/sys_vendor"
+	DMIProductPath = "/sys/class/dmi/id/product_name"
+	CPUOnlinePath  = "/sys/devices/system/cpu/online"
+	MemInfoPath    = "/proc/meminfo"
+	PCIDevicesPath = "/sys/bus/pci/devices"
+)
+
+// gpuVendors maps the PCI vendor IDs of accelerator vendors to their names. Display controllers of other vendors,
+// such as the BMC graphics of most servers, are not GPUs workloads can use.
+var gpuVendors = map[string]string{
+	"0x10de": "nvidia",
+	"0x1002": "amd",
+}
+
+// invalidLabelChars matches the runs of characters a label value cannot contain
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// Profile describes the hardware of the host
+type Profile struct {
+	Vendor    string   `json:"vendor,omitempty"`  // DMI system vendor
+	Product   string   `json:"product,omitempty"` // DMI product name
+	CPUs      int      `json:"cpus"`
+	MemoryGiB int      `json:"memoryGiB"`      // total memory reported by the kernel, rounded to GiB
+	GPUs      []string `json:"gpus,omitempty"` // vendor of each GPU, in PCI address order
+}
+
+// Detect reads the hardware profile from the host files below root, "/" outside of tests
+func Detect(root string) *Profile {
+	profile := &Profile{
+		Vendor:  readTrimmed(filepath.Join(root, DMIVendorPath)),
+		Product: readTrimmed(filepath.Join(root, DMIProductPath)),
+	}
+	if cpus, err := utils.ParseCPUSet(readTrimmed(filepath.Join(root, CPUOnlinePath))); err == nil {
+		profile.CPUs = len(cpus)
+	}
+
+	if memInfo, err := os.ReadFile(filepath.Join(root, MemInfoPath)); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(memInfo))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "MemTotal:" {
+				if kiB, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+					profile.MemoryGiB = int((kiB + 512*1024) / (1024 * 1024))
+				}
+				break
+			}
+		}
+	}
+
+	devices, _ := os.ReadDir(filepath.Join(root, PCIDevicesPath))
+	for _, device := range devices {
+		dir := filepath.Join(root, PCIDevicesPath, device.Name())
+		// PCI base class 0x03 is a display controller, which includes 3D controllers without a display output
+		if !strings.HasPrefix(readTrimmed(filepath.Join(dir, "class")), "0x03") {
+			continue
+		}
+		if vendor, ok := gpuVendors[readTrimmed(filepath.Join(dir, "vendor"))]; ok {
+			profile.GPUs = append(profile.GPUs, vendor)
+		}
+	}
+	return profile
+}
+
+// HardwareProfile returns the hardware profile as a node label value: the DMI product, the CPUs, the memory
+// and the GPUs, e.g. "poweredge-r650-32cpu-128gi-2xnvidia"
+func (p *Profile) HardwareProfile() string {
+	parts := []string{}
+	if product := labelValue(p.Product); product != "" {
+		parts = append(parts, product)
+	}
+	parts = append(parts, fmt.Sprintf("%dcpu", p.CPUs), fmt.Sprintf("%dgi", p.MemoryGiB))
+	vendors := slices.Compact(slices.Sorted(slices.Values(p.GPUs)))
+	for _, vendor := range vendors {
+		parts = append(parts, fmt.Sprintf("%dx%s", countOf(p.GPUs, vendor), vendor))
+	}
+	// Keep the sizes when a long product name does not fit the 63 characters of a label value
+	profile := strings.Join(parts, "-")
+	for len(profile) > 63 && len(parts) > 2 {
+		parts = parts[1:]
+		profile = strings.Join(parts, "-")
+	}
+	return profile
+}
+
+// FleetLabels returns the fleet labels of the node: the site from node.siteId or the site tag of the Arc machine,
+// the hardware profile and the agent version. Labels whose value is unknown are left out.
+func FleetLabels(cfg *config.Config, profile *Profile) map[string]string {
+	labels := map[string]string{
+		HardwareProfileLabel: profile.HardwareProfile(),
+	}
+	site := cfg.Node.SiteID
+	if site == "" {
+		site = cfg.Node.Labels[sitetags.SiteLabel]
+	}
+	if site != "" {
+		labels[SiteLabel] = site
+	}
+	if version := labelValue(buildinfo.Version); version != "" {
+		labels[AgentVersionLabel] = version
+	}
+	return labels
+}
+
+// labelValue turns free text such as a product name into a node label value
+func labelValue(text string) string {
+	value := strings.Trim(invalidLabelChars.ReplaceAllString(strings.ToLower(text), "-"), "-.")
+	if len(value) > 63 {
+		value = strings.TrimRight(value[:63], "-.")
+	}
+	return value
+}
+
+func countOf(values []string, value string) int {
+	count := 0
+	for _, v := range values {
+		if v == value {
+			count++
+		}
+	}
+	return count
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/sitetags"
+)
+
+// writeHostFiles creates the given host files below a temporary root and returns the root
+func writeHostFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(full), err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", full, err)
+		}
+	}
+	return root
+}
+
+func TestDetect(t *testing.T) {
+	root := writeHostFiles(t, map[string]string{
+		DMIVendorPath:  "Dell Inc.\n",
+		DMIProductPath: "PowerEdge R650\n",
+		CPUOnlinePath:  "0-31\n",
+		MemInfoPath:    "MemTotal:       131621404 kB\nMemFree:        120000000 kB\n",
+		// Two NVIDIA 3D controllers, the ASPEED BMC graphics and an Intel NIC
+		PCIDevicesPath + "/0000:17:00.0/class":  "0x030200\n",
+		PCIDevicesPath + "/0000:17:00.0/vendor": "0x10de\n",
+		PCIDevicesPath + "/0000:65:00.0/class":  "0x030200\n",
+		PCIDevicesPath + "/0000:65:00.0/vendor": "0x10de\n",
+		PCIDevicesPath + "/0000:03:00.0/class":  "0x030000\n",
+		PCIDevicesPath + "/0000:03:00.0/vendor": "0x1a03\n",
+		PCIDevicesPath + "/0000:31:00.0/class":  "0x020000\n",
+		PCIDevicesPath + "/0000:31:00.0/vendor": "0x8086\n",
+	})
+
+	profile := Detect(root)
+	if profile.Vendor != "Dell Inc." || profile.Product != "PowerEdge R650" {
+		t.Errorf("Detect() vendor and product = %q %q, want Dell Inc. PowerEdge R650", profile.Vendor, profile.Product)
+	}
+	if profile.CPUs != 32 || profile.MemoryGiB != 126 {
+		t.Errorf("Detect() = %d CPUs and %d GiB, want 32 CPUs and 126 GiB", profile.CPUs, profile.MemoryGiB)
+	}
+	if strings.Join(profile.GPUs, ",") != "nvidia,nvidia" {
+		t.Errorf("Detect() GPUs = %v, want two nvidia GPUs", profile.GPUs)
+	}
+	if got, want := profile.HardwareProfile(), "poweredge-r650-32cpu-126gi-2xnvidia"; got != want {
+		t.Errorf("HardwareProfile() = %q, want %q", got, want)
+	}
+
+	// A host without DMI or PCI information, such as a container, still has a profile
+	if got, want := Detect(t.TempDir()).HardwareProfile(), "0cpu-0gi"; got != want {
+		t.Errorf("HardwareProfile() of an empty root = %q, want %q", got, want)
+	}
+}
+
+func TestHardwareProfileLength(t *testing.T) {
+	profile := &Profile{
+		Product:   strings.Repeat("Very Long Product Name ", 5),
+		CPUs:      64,
+		MemoryGiB: 512,
+		GPUs:      []string{"amd", "nvidia", "amd"},
+	}
+	got := profile.HardwareProfile()
+	if want := "64cpu-512gi-2xamd-1xnvidia"; got != want {
+		t.Errorf("HardwareProfile() = %q, want %q", got, want)
+	}
+}
+
+func TestFleetLabels(t *testing.T) {
+	original := buildinfo.Version
+	t.Cleanup(func() { buildinfo.Version = original })
+	buildinfo.Version = "v0.9.2+dirty"
+
+	profile := &Profile{CPUs: 8, MemoryGiB: 32}
+	cfg := &config.Config{Node: config.NodeConfig{
+		Labels: map[string]string{sitetags.SiteLabel: "store-12"},
+	}}
+
+	labels := FleetLabels(cfg, profile)
+	want := map[string]string{
+		SiteLabel:            "store-12",
+		HardwareProfileLabel: "8cpu-32gi",
+		AgentVersionLabel:    "v0.9.2-dirty",
+	}
+	for key, value := range want {
+		if labels[key] != value {
+			t.Errorf("FleetLabels()[%s] = %q, want %q", key, labels[key], value)
+		}
+	}
+
+	// node.siteId takes precedence over the site tag of the Arc machine
+	cfg.Node.SiteID = "berlin-1"
+	if got := FleetLabels(cfg, profile)[SiteLabel]; got != "berlin-1" {
+		t.Errorf("FleetLabels() site = %q, want berlin-1", got)
+	}
+
+	// Without a site the label is left out rather than set empty
+	if _, ok := FleetLabels(&config.Config{}, profile)[SiteLabel]; ok {
+		t.Error("FleetLabels() set a site label without a site")
+	}
+}
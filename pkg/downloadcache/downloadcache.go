@@ -0,0 +1,307 @@
+package downloadcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/netutil"
+)
+
+// Dir is where component archives are cached across re-bootstraps
+var Dir = "/var/cache/aks-flex-node/downloads"
+
+// download and fetchChecksum are replaced in tests
+var (
+	download      = utils.DownloadFile
+	fetchChecksum = netutil.FetchChecksum
+)
+
+// Suffixes of the files kept per cached archive next to the archive itself
+const (
+	metadataSuffix = ".json"
+	partialSuffix  = ".partial"
+)
+
+// Entry describes a cached archive. Archives are stored under the SHA-256 of their URL and verified
+// against the SHA-256 of their content before every use, so a truncated or altered archive is fetched again.
+type Entry struct {
+	URL        string    `json:"url"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	FetchedAt  time.Time `json:"fetchedAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+
+	key string
+}
+
+// Cache serves component downloads from a size-bounded local cache
+type Cache struct {
+	dir      string
+	maxBytes int64
+	disabled bool
+	logger   *logrus.Logger
+}
+
+// New creates a download cache configured by the downloadCache settings
+func New(cfg *config.Config, logger *logrus.Logger) *Cache {
+	cache := &Cache{dir: Dir, logger: logger}
+	if cfg != nil {
+		cache.disabled = cfg.DownloadCache.Disabled
+		cache.maxBytes = int64(cfg.DownloadCache.MaxSizeMB) * 1024 * 1024
+	}
+	return cache
+}
+
+// Fetch places the archive at url in destination, downloading it only when no verified copy is cached.
+// Failing to use the cache never fails the install, the archive is then downloaded directly.
+func (c *Cache) Fetch(ctx context.Context, url, destination string) error {
+	if c.disabled || c.maxBytes <= 0 {
+		return download(ctx, url, destination)
+	}
+
+	key := urlKey(url)
+	if entry := c.lookup(key, url); entry != nil {
+		if err := copyFile(c.archivePath(key), destination); err == nil {
+			c.logger.Infof("Using cached download of %s (%d bytes, fetched %s)", url, entry.Size, entry.FetchedAt.Format(time.RFC3339))
+			entry.LastUsedAt = time.Now()
+			_ = c.writeEntry(entry)
+			return nil
+		}
+	}
+
+	if err := c.store(ctx, key, url); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		c.logger.Warnf("Failed to cache download of %s, downloading it directly: %v", url, err)
+		return download(ctx, url, destination)
+	}
+	c.evict(key)
+	if err := copyFile(c.archivePath(key), destination); err != nil {
+		// A concurrent install may have evicted the archive to fit its own into the cache
+		c.logger.Warnf("Failed to use the cached download of %s, downloading it directly: %v", url, err)
+		return download(ctx, url, destination)
+	}
+	return nil
+}
+
+// FetchVerified places the archive at url in destination like Fetch and verifies it against the checksum pinned
+// in the configuration, either directly or through a checksum file. An archive that does not match is dropped
+// from the cache and fails the install. Without a pinned checksum the archive is used unverified.
+func (c *Cache) FetchVerified(ctx context.Context, url, destination string, checksum *config.ChecksumConfig) error {
+	if checksum == nil {
+		return c.Fetch(ctx, url, destination)
+	}
+
+	expected := checksum.SHA256
+	if checksum.URL != "" {
+		sum, err := fetchChecksum(ctx, checksum.URL, archiveName(url))
+		if err != nil {
+			return fmt.Errorf("failed to get the checksum of %s from %s: %w", url, checksum.URL, err)
+		}
+		expected = sum
+	}
+
+	if err := c.Fetch(ctx, url, destination); err != nil {
+		return err
+	}
+	if err := netutil.VerifySHA256(destination, expected); err != nil {
+		c.remove(urlKey(url))
+		return fmt.Errorf("failed to verify download of %s: %w", url, err)
+	}
+	c.logger.Infof("Verified download of %s against SHA-256 %s", url, strings.ToLower(expected))
+	return nil
+}
+
+// lookup returns the cache entry for url when its archive matches the recorded checksum
+func (c *Cache) lookup(key, url string) *Entry {
+	entry, err := readEntry(c.dir, key)
+	if err != nil || entry.URL != url {
+		return nil
+	}
+	sum, _, err := hashFile(c.archivePath(key))
+	if err != nil || sum != entry.SHA256 {
+		c.logger.Warnf("Cached download of %s is corrupt, downloading it again", url)
+		c.remove(key)
+		return nil
+	}
+	return entry
+}
+
+// store downloads url into the cache and records its checksum
+func (c *Cache) store(ctx context.Context, key, url string) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", c.dir, err)
+	}
+	partial := c.archivePath(key) + partialSuffix
+	defer func() { _ = os.Remove(partial) }()
+
+	if err := download(ctx, url, partial); err != nil {
+		return err
+	}
+	sum, size, err := hashFile(partial)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, c.archivePath(key)); err != nil {
+		return fmt.Errorf("failed to move download into the cache: %w", err)
+	}
+	now := time.Now()
+	return c.writeEntry(&Entry{URL: url, SHA256: sum, Size: size, FetchedAt: now, LastUsedAt: now, key: key})
+}
+
+// evict removes the least recently used archives until the cache fits its size bound, keeping the archive just stored
+func (c *Cache) evict(keep string) {
+	entries, err := listEntries(c.dir)
+	if err != nil {
+		c.logger.Warnf("Failed to list download cache for eviction: %v", err)
+		return
+	}
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsedAt.Before(entries[j].LastUsedAt) })
+	for _, entry := range entries {
+		if total <= c.maxBytes {
+			return
+		}
+		if entry.key == keep {
+			continue
+		}
+		c.logger.Infof("Evicting cached download of %s (%d bytes)", entry.URL, entry.Size)
+		c.remove(entry.key)
+		total -= entry.Size
+	}
+}
+
+func (c *Cache) archivePath(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+func (c *Cache) writeEntry(entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	return os.WriteFile(c.archivePath(entry.key)+metadataSuffix, data, 0o644)
+}
+
+func (c *Cache) remove(key string) {
+	_ = os.Remove(c.archivePath(key))
+	_ = os.Remove(c.archivePath(key) + metadataSuffix)
+}
+
+// List returns the entries of the download cache
+func List() ([]*Entry, error) {
+	return listEntries(Dir)
+}
+
+func listEntries(dir string) ([]*Entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read download cache %s: %w", dir, err)
+	}
+	var entries []*Entry
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), metadataSuffix)
+		if !ok {
+			continue
+		}
+		if entry, err := readEntry(dir, key); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Purge removes every cached archive and returns how many archives and bytes were removed
+func Purge() (int, int64, error) {
+	entries, err := List()
+	if err != nil {
+		return 0, 0, err
+	}
+	var freed int64
+	for _, entry := range entries {
+		freed += entry.Size
+	}
+	if err := os.RemoveAll(Dir); err != nil {
+		return 0, 0, fmt.Errorf("failed to remove download cache %s: %w", Dir, err)
+	}
+	return len(entries), freed, nil
+}
+
+func readEntry(dir, key string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, key+metadataSuffix))
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{key: key}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cache entry %s: %w", key, err)
+	}
+	return entry, nil
+}
+
+// archiveName returns the file name of the archive at url, which checksum files list it by
+func archiveName(rawURL string) string {
+	if parsed, err := neturl.Parse(rawURL); err == nil {
+		return path.Base(parsed.Path)
+	}
+	return path.Base(rawURL)
+}
+
+func urlKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFile returns the SHA-256 and size of a file
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", destination, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to write file %s: %w", destination, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,167 @@
+package downloadcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/netutil"
+)
+
+// fakeDownloads serves archives of fixed content and counts the downloads per URL
+type fakeDownloads struct {
+	content map[string]string
+	count   map[string]int
+}
+
+func (f *fakeDownloads) download(_ context.Context, url, destination string) error {
+	f.count[url]++
+	return os.WriteFile(destination, []byte(f.content[url]), 0o644)
+}
+
+func newTestCache(t *testing.T, maxSizeMB int) (*Cache, *fakeDownloads) {
+	t.Helper()
+	Dir = filepath.Join(t.TempDir(), "downloads")
+	fake := &fakeDownloads{content: map[string]string{}, count: map[string]int{}}
+	original := download
+	download = fake.download
+	t.Cleanup(func() { download = original })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	cfg.DownloadCache.MaxSizeMB = maxSizeMB
+	return New(cfg, logger), fake
+}
+
+func fetch(t *testing.T, cache *Cache, url string) string {
+	t.Helper()
+	destination := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := cache.Fetch(context.Background(), url, destination); err != nil {
+		t.Fatalf("Fetch(%s) error = %v", url, err)
+	}
+	data, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatalf("failed to read fetched archive: %v", err)
+	}
+	return string(data)
+}
+
+func TestFetchReusesVerifiedArchive(t *testing.T) {
+	cache, fake := newTestCache(t, 1)
+	url := "https://example.com/containerd.tar.gz"
+	fake.content[url] = "containerd archive"
+
+	for range 2 {
+		if got := fetch(t, cache, url); got != "containerd archive" {
+			t.Fatalf("Fetch() content = %q", got)
+		}
+	}
+	if fake.count[url] != 1 {
+		t.Errorf("downloaded %d times, want 1", fake.count[url])
+	}
+
+	// A corrupted archive fails its checksum and is downloaded again
+	if err := os.WriteFile(filepath.Join(Dir, urlKey(url)), []byte("truncated"), 0o644); err != nil {
+		t.Fatalf("failed to corrupt cached archive: %v", err)
+	}
+	if got := fetch(t, cache, url); got != "containerd archive" {
+		t.Errorf("Fetch() after corruption content = %q", got)
+	}
+	if fake.count[url] != 2 {
+		t.Errorf("downloaded %d times after corruption, want 2", fake.count[url])
+	}
+}
+
+func TestFetchEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, fake := newTestCache(t, 1)
+	archive := strings.Repeat("x", 400*1024)
+	urls := []string{"https://example.com/a.tar.gz", "https://example.com/b.tar.gz", "https://example.com/c.tar.gz"}
+	for _, url := range urls {
+		fake.content[url] = archive
+	}
+
+	fetch(t, cache, urls[0])
+	fetch(t, cache, urls[1])
+	fetch(t, cache, urls[0]) // a is now more recently used than b
+	fetch(t, cache, urls[2]) // 1.2 MB exceeds the 1 MB bound
+
+	entries, err := List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	cached := map[string]bool{}
+	for _, entry := range entries {
+		cached[entry.URL] = true
+	}
+	if !cached[urls[0]] || cached[urls[1]] || !cached[urls[2]] {
+		t.Errorf("cached archives = %v, want b evicted", cached)
+	}
+}
+
+func TestDisabledCacheAndPurge(t *testing.T) {
+	cache, fake := newTestCache(t, 1)
+	url := "https://example.com/runc"
+	fake.content[url] = "runc"
+	fetch(t, cache, url)
+
+	archives, freed, err := Purge()
+	if err != nil || archives != 1 || freed != int64(len("runc")) {
+		t.Errorf("Purge() = %d, %d, %v, want 1 archive of 4 bytes", archives, freed, err)
+	}
+
+	cache.disabled = true
+	fetch(t, cache, url)
+	fetch(t, cache, url)
+	if fake.count[url] != 3 {
+		t.Errorf("downloaded %d times, want every fetch to download after purge and with the cache disabled", fake.count[url])
+	}
+	if entries, _ := List(); len(entries) != 0 {
+		t.Errorf("List() = %d entries, want none with the cache disabled", len(entries))
+	}
+}
+
+func TestFetchVerified(t *testing.T) {
+	cache, fake := newTestCache(t, 100)
+	url := "https://example.com/releases/containerd-1.7.20-linux-amd64.tar.gz"
+	fake.content[url] = "archive"
+	digest := sha256.Sum256([]byte("archive"))
+	sum := hex.EncodeToString(digest[:])
+
+	destination := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := cache.FetchVerified(context.Background(), url, destination, &config.ChecksumConfig{SHA256: strings.ToUpper(sum)}); err != nil {
+		t.Fatalf("FetchVerified() with a matching checksum error = %v", err)
+	}
+
+	// The checksum file is looked up by the archive's file name
+	original := fetchChecksum
+	t.Cleanup(func() { fetchChecksum = original })
+	fetchChecksum = func(_ context.Context, _, fileName string) (string, error) {
+		return netutil.ParseChecksumFile(sum+"  "+fileName+"\n", "containerd-1.7.20-linux-amd64.tar.gz")
+	}
+	if err := cache.FetchVerified(context.Background(), url, destination, &config.ChecksumConfig{URL: "https://example.com/sha256sums"}); err != nil {
+		t.Fatalf("FetchVerified() with a checksum file error = %v", err)
+	}
+
+	// A mismatch fails permanently and drops the cached archive so it is not served again
+	err := cache.FetchVerified(context.Background(), url, destination, &config.ChecksumConfig{SHA256: strings.Repeat("0", 64)})
+	if !errors.Is(err, netutil.ErrChecksumMismatch) || failure.Classify(err) != failure.ClassPermanent {
+		t.Fatalf("FetchVerified() with a wrong checksum error = %v, want a permanent checksum mismatch", err)
+	}
+	if entries, _ := List(); len(entries) != 0 {
+		t.Errorf("archive failing verification is still cached: %v", entries)
+	}
+	if fake.count[url] != 1 {
+		t.Errorf("archive downloaded %d times, want once before it failed verification", fake.count[url])
+	}
+}
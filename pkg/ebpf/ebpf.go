@@ -0,0 +1,189 @@
+package ebpf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Minimum kernel for the eBPF kube-proxy replacement of Cilium
+const (
+	MinKernelMajor = 5
+	MinKernelMinor = 4
+)
+
+// Kernel files the capabilities are read from, below the detection root
+const (
+	KernelReleasePath = "/proc/sys/kernel/osrelease"
+	KernelBTFPath     = "/sys/kernel/btf/vmlinux"
+	ProcConfigPath    = "/proc/config.gz"
+	ProcMountsPath    = "/proc/mounts"
+	BPFFSPath         = "/sys/fs/bpf"
+	CgroupPath        = "/sys/fs/cgroup"
+)
+
+// DatapathKernelOptions are the kernel options an eBPF datapath such as Cilium cannot run without
+var DatapathKernelOptions = []string{
+	"CONFIG_BPF",
+	"CONFIG_BPF_SYSCALL",
+	"CONFIG_BPF_JIT",
+	"CONFIG_NET_CLS_BPF",
+	"CONFIG_NET_SCH_INGRESS",
+	"CONFIG_CGROUP_BPF",
+}
+
+// TracingKernelOptions are the kernel options eBPF observability tools such as Hubble process
+// visibility, Tetragon or Pixie attach their programs with
+var TracingKernelOptions = []string{
+	"CONFIG_KPROBES",
+	"CONFIG_BPF_EVENTS",
+	"CONFIG_PERF_EVENTS",
+}
+
+// Report describes the kernel capabilities eBPF-based components rely on
+type Report struct {
+	KernelRelease        string   `json:"kernelRelease"`
+	KernelSupported      bool     `json:"kernelSupported"` // at least 5.4
+	BTF                  bool     `json:"btf"`             // kernel BTF is available, so CO-RE programs load without a toolchain on the node
+	Cgroup2              bool     `json:"cgroup2"`         // the unified cgroup hierarchy is mounted
+	BPFFilesystem        bool     `json:"bpfFilesystem"`   // bpffs is mounted, so pinned programs and maps survive restarts
+	MissingKernelOptions []string `json:"missingKernelOptions,omitempty"`
+	KernelConfigError    string   `json:"kernelConfigError,omitempty"` // why the kernel options could not be verified
+}
+
+// Detect reads the eBPF capabilities of the running kernel from the kernel files below root, "/" outside of tests
+func Detect(root string) *Report {
+	report := &Report{}
+	if release, err := os.ReadFile(filepath.Join(root, KernelReleasePath)); err == nil {
+		report.KernelRelease = strings.TrimSpace(string(release))
+	}
+	if major, minor, ok := ParseKernelRelease(report.KernelRelease); ok {
+		report.KernelSupported = major > MinKernelMajor || (major == MinKernelMajor && minor >= MinKernelMinor)
+	}
+
+	_, err := os.Stat(filepath.Join(root, KernelBTFPath))
+	report.BTF = err == nil
+
+	if mounts, err := os.ReadFile(filepath.Join(root, ProcMountsPath)); err == nil {
+		report.BPFFilesystem = mounted(mounts, BPFFSPath, "bpf")
+		report.Cgroup2 = mounted(mounts, CgroupPath, "cgroup2")
+	}
+
+	if kernelConfig, err := readKernelConfig(root, report.KernelRelease); err != nil {
+		report.KernelConfigError = err.Error()
+	} else {
+		report.MissingKernelOptions = missingKernelOptions(kernelConfig)
+	}
+	return report
+}
+
+// MissingDatapathOptions returns the missing kernel options an eBPF datapath cannot run without
+func (r *Report) MissingDatapathOptions() []string {
+	var missing []string
+	for _, option := range r.MissingKernelOptions {
+		if slices.Contains(DatapathKernelOptions, option) {
+			missing = append(missing, option)
+		}
+	}
+	return missing
+}
+
+// Warnings describes each capability the node lacks for eBPF-based components
+func (r *Report) Warnings() []string {
+	var warnings []string
+	if major, minor, ok := ParseKernelRelease(r.KernelRelease); !ok {
+		warnings = append(warnings, fmt.Sprintf("cannot determine the kernel version from release %q", r.KernelRelease))
+	} else if !r.KernelSupported {
+		warnings = append(warnings, fmt.Sprintf("kernel %d.%d is older than %d.%d, which eBPF datapaths such as Cilium require", major, minor, MinKernelMajor, MinKernelMinor))
+	}
+	if !r.BTF {
+		warnings = append(warnings, fmt.Sprintf("kernel BTF %s is missing, so CO-RE eBPF programs such as those of Cilium and Hubble cannot load", KernelBTFPath))
+	}
+	if !r.Cgroup2 {
+		warnings = append(warnings, fmt.Sprintf("cgroup v2 is not mounted at %s, which Cilium socket load balancing and cgroup eBPF programs attach to", CgroupPath))
+	}
+	if r.KernelConfigError != "" {
+		warnings = append(warnings, fmt.Sprintf("cannot verify the kernel configuration: %s", r.KernelConfigError))
+	} else if len(r.MissingKernelOptions) > 0 {
+		warnings = append(warnings, fmt.Sprintf("kernel is built without %s", strings.Join(r.MissingKernelOptions, ", ")))
+	}
+	if !r.BPFFilesystem {
+		warnings = append(warnings, fmt.Sprintf("the BPF filesystem is not mounted at %s, so pinned eBPF programs and maps do not survive a restart of the component that loaded them", BPFFSPath))
+	}
+	return warnings
+}
+
+// missingKernelOptions returns the datapath and tracing kernel options that are not built in or built as modules
+func missingKernelOptions(kernelConfig []byte) []string {
+	enabled := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(kernelConfig))
+	for scanner.Scan() {
+		if option, value, ok := strings.Cut(scanner.Text(), "="); ok && (value == "y" || value == "m") {
+			enabled[option] = true
+		}
+	}
+	var missing []string
+	for _, option := range slices.Concat(DatapathKernelOptions, TracingKernelOptions) {
+		if !enabled[option] {
+			missing = append(missing, option)
+		}
+	}
+	return missing
+}
+
+// readKernelConfig reads the build configuration of the running kernel from /boot or /proc/config.gz
+func readKernelConfig(root, release string) ([]byte, error) {
+	if release != "" {
+		if data, err := os.ReadFile(filepath.Join(root, "/boot", "config-"+release)); err == nil {
+			return data, nil
+		}
+	}
+
+	file, err := os.Open(filepath.Join(root, ProcConfigPath))
+	if err != nil {
+		return nil, fmt.Errorf("neither /boot/config-<release> nor %s is available", ProcConfigPath)
+	}
+	defer func() { _ = file.Close() }()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ProcConfigPath, err)
+	}
+	return io.ReadAll(reader)
+}
+
+// ParseKernelRelease returns the major and minor version of a kernel release such as 6.8.0-1015-azure
+func ParseKernelRelease(release string) (int, int, bool) {
+	parts := strings.SplitN(strings.TrimSpace(release), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minorDigits, _, _ := strings.Cut(parts[1], "-")
+	minor, err := strconv.Atoi(minorDigits)
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// mounted reports whether /proc/mounts lists a filesystem of the given type at path
+func mounted(mounts []byte, path, fsType string) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(mounts))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[1] == path && fields[2] == fsType {
+			return true
+		}
+	}
+	return false
+}
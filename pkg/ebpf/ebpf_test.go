@@ -0,0 +1,79 @@
+package ebpf
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeKernelFiles lays out kernel files below a temporary root
+func writeKernelFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(full), err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", full, err)
+		}
+	}
+	return root
+}
+
+func TestDetect(t *testing.T) {
+	kernelConfig := "CONFIG_BPF=y\nCONFIG_BPF_SYSCALL=y\nCONFIG_BPF_JIT=y\nCONFIG_NET_CLS_BPF=m\nCONFIG_NET_SCH_INGRESS=m\nCONFIG_CGROUP_BPF=y\n" +
+		"CONFIG_KPROBES=y\nCONFIG_BPF_EVENTS=y\nCONFIG_PERF_EVENTS=y\n"
+
+	capable := Detect(writeKernelFiles(t, map[string]string{
+		KernelReleasePath:               "6.8.0-1015-azure\n",
+		KernelBTFPath:                   "",
+		"/boot/config-6.8.0-1015-azure": kernelConfig,
+		ProcMountsPath:                  "cgroup2 /sys/fs/cgroup cgroup2 rw,nosuid 0 0\nbpf /sys/fs/bpf bpf rw,nosuid 0 0\n",
+	}))
+	if warnings := capable.Warnings(); len(warnings) > 0 {
+		t.Errorf("Detect() on a capable kernel warnings = %v", warnings)
+	}
+
+	limited := Detect(writeKernelFiles(t, map[string]string{
+		KernelReleasePath:                 "4.15.0-213-generic\n",
+		"/boot/config-4.15.0-213-generic": strings.Replace(kernelConfig, "CONFIG_KPROBES=y", "# CONFIG_KPROBES is not set", 1),
+		ProcMountsPath:                    "cgroup /sys/fs/cgroup/memory cgroup rw,memory 0 0\n",
+	}))
+	if limited.KernelSupported || limited.BTF || limited.Cgroup2 || limited.BPFFilesystem {
+		t.Errorf("Detect() on a limited kernel = %+v, want no capability", limited)
+	}
+	if !slices.Equal(limited.MissingKernelOptions, []string{"CONFIG_KPROBES"}) || len(limited.MissingDatapathOptions()) != 0 {
+		t.Errorf("Detect() missing options = %v, want only the tracing option CONFIG_KPROBES", limited.MissingKernelOptions)
+	}
+	if warnings := limited.Warnings(); len(warnings) != 5 {
+		t.Errorf("Warnings() = %v, want kernel, BTF, cgroup v2, kernel option and bpffs warnings", warnings)
+	}
+
+	unknown := Detect(writeKernelFiles(t, map[string]string{KernelReleasePath: "6.8.0-1015-azure\n"}))
+	if unknown.KernelConfigError == "" || unknown.MissingKernelOptions != nil {
+		t.Errorf("Detect() without a kernel configuration = %+v, want the configuration reported as unknown", unknown)
+	}
+}
+
+func TestParseKernelRelease(t *testing.T) {
+	tests := []struct {
+		release      string
+		major, minor int
+		ok           bool
+	}{
+		{release: "6.8.0-1015-azure", major: 6, minor: 8, ok: true},
+		{release: "5.15.153.1-microsoft-standard", major: 5, minor: 15, ok: true},
+		{release: "5.4-rc1", major: 5, minor: 4, ok: true},
+		{release: "unknown", ok: false},
+	}
+	for _, tt := range tests {
+		major, minor, ok := ParseKernelRelease(tt.release)
+		if major != tt.major || minor != tt.minor || ok != tt.ok {
+			t.Errorf("ParseKernelRelease(%q) = %d, %d, %v, want %d, %d, %v", tt.release, major, minor, ok, tt.major, tt.minor, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Annotation records the public IP the node reaches Azure and the API server from
+const Annotation = "kubernetes.azure.com/flex-node-egress-ip"
+
+// Flex nodes sharing one egress IP, see "Multiple Nodes Behind One NAT" in the usage guide
+const (
+	// SharedNodesLimit is how many nodes may share an egress IP with default kubelet API client rate limits
+	SharedNodesLimit = 10
+	// MaxSharedNodes is how many nodes may share an egress IP at all, with lowered rate limits
+	MaxSharedNodes = 50
+	// ReducedKubeAPIQPS is the kubelet API client QPS recommended above SharedNodesLimit
+	ReducedKubeAPIQPS = 10
+)
+
+const (
+	// maxResponseSize bounds how much of the egress IP endpoint response is read
+	maxResponseSize = 256
+	requestTimeout  = 10 * time.Second
+)
+
+// DetectIP asks the egress IP endpoint which public IP this machine's requests come from
+func DetectIP(ctx context.Context, endpoint string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create egress IP request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query egress IP endpoint %s: %w", endpoint, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query egress IP endpoint %s: HTTP status %d", endpoint, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read egress IP response: %w", err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(data)))
+	if ip == nil {
+		return "", fmt.Errorf("egress IP endpoint %s did not answer with an IP address", endpoint)
+	}
+	return ip.String(), nil
+}
+
+// Check records this node's egress IP on its node object and warns when more flex nodes share it
+// than the configured kubelet API client rate limits support. It only logs, as the node works regardless.
+func Check(ctx context.Context, cfg *config.Config, logger *logrus.Logger) {
+	if cfg.Agent.EgressIPEndpoint == "" {
+		return
+	}
+	ip, err := DetectIP(ctx, cfg.Agent.EgressIPEndpoint)
+	if err != nil {
+		logger.Warnf("Skipping shared egress IP check: %v", err)
+		return
+	}
+
+	nodeName := cfg.GetNodeName()
+	if _, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubelet.KubeletKubeconfigPath,
+		"annotate", "node", nodeName, "--overwrite", fmt.Sprintf("%s=%s", Annotation, ip)); err != nil {
+		logger.Warnf("Failed to record egress IP %s on node %s: %v", ip, nodeName, err)
+		return
+	}
+	output, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubelet.KubeletKubeconfigPath,
+		"get", "nodes", "-o", `jsonpath={range .items[*]}{.metadata.annotations.kubernetes\.azure\.com/flex-node-egress-ip}{"\n"}{end}`)
+	if err != nil {
+		logger.Warnf("Failed to list nodes sharing egress IP %s: %v", ip, err)
+		return
+	}
+
+	shared := countSharing(output, ip)
+	logger.Infof("Egress IP of node %s is %s, shared by %d flex node(s)", nodeName, ip, shared)
+	for _, warning := range sharingWarnings(shared, ip, cfg.Node.Kubelet.KubeAPIQPS) {
+		logger.Warnf("Shared egress IP: %s", warning)
+	}
+}
+
+// countSharing counts the nodes whose egress IP annotation, one per line, is ip
+func countSharing(annotations, ip string) int {
+	count := 0
+	for _, line := range strings.Split(annotations, "\n") {
+		if strings.TrimSpace(line) == ip {
+			count++
+		}
+	}
+	return count
+}
+
+// sharingWarnings returns warnings for the number of nodes sharing an egress IP and the configured kubelet QPS
+func sharingWarnings(shared int, ip string, kubeAPIQPS int) []string {
+	switch {
+	case shared > MaxSharedNodes:
+		return []string{fmt.Sprintf("%d flex nodes share egress IP %s, more than the %d supported; "+
+			"API server rate limits and audit logs cannot tell these nodes apart reliably, give sites their own egress IP",
+			shared, ip, MaxSharedNodes)}
+	case shared > SharedNodesLimit && (kubeAPIQPS == 0 || kubeAPIQPS > ReducedKubeAPIQPS):
+		return []string{fmt.Sprintf("%d flex nodes share egress IP %s, more than the %d supported with default rate limits; "+
+			"set node.kubelet.kubeAPIQPS to %d or lower on these nodes", shared, ip, SharedNodesLimit, ReducedKubeAPIQPS)}
+	}
+	return nil
+}
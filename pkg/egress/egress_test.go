@@ -0,0 +1,63 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDetectIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			_, _ = fmt.Fprint(w, "<html>captive portal</html>")
+			return
+		}
+		_, _ = fmt.Fprint(w, " 203.0.113.7\n")
+	}))
+	defer server.Close()
+
+	ip, err := DetectIP(context.Background(), server.URL)
+	if err != nil || ip != "203.0.113.7" {
+		t.Errorf("DetectIP() = %q, %v, want 203.0.113.7", ip, err)
+	}
+	if _, err := DetectIP(context.Background(), server.URL+"/bad"); err == nil {
+		t.Error("DetectIP() expected an error for a response without an IP address")
+	}
+}
+
+func TestSharingWarnings(t *testing.T) {
+	annotations := strings.Repeat("203.0.113.7\n", 12) + "198.51.100.1\n\n"
+	shared := countSharing(annotations, "203.0.113.7")
+	if shared != 12 {
+		t.Fatalf("countSharing() = %d, want 12", shared)
+	}
+
+	tests := []struct {
+		name       string
+		shared     int
+		kubeAPIQPS int
+		want       string
+	}{
+		{name: "within limit", shared: SharedNodesLimit},
+		{name: "above limit with default QPS", shared: shared, want: "set node.kubelet.kubeAPIQPS"},
+		{name: "above limit with reduced QPS", shared: shared, kubeAPIQPS: ReducedKubeAPIQPS},
+		{name: "beyond supported", shared: MaxSharedNodes + 1, kubeAPIQPS: 5, want: "more than the 50 supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := sharingWarnings(tt.shared, "203.0.113.7", tt.kubeAPIQPS)
+			if tt.want == "" {
+				if len(warnings) != 0 {
+					t.Errorf("sharingWarnings() = %v, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], tt.want) {
+				t.Errorf("sharingWarnings() = %v, want one containing %q", warnings, tt.want)
+			}
+		})
+	}
+}
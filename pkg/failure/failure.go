@@ -0,0 +1,95 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Class tells whether retrying an operation that failed may succeed
+type Class string
+
+// Failure classes
+const (
+	ClassTransient Class = "transient" // the cause may go away by itself, such as a network timeout or a throttled request
+	ClassPermanent Class = "permanent" // retrying fails the same way until an operator fixes the host or the configuration
+	ClassUnknown   Class = "unknown"   // the error carries no hint of its cause
+)
+
+// classifiedError attaches a failure class to an error
+type classifiedError struct {
+	class Class
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// WithClass marks err with a failure class, returning nil for a nil err
+func WithClass(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// Transient marks err as a failure that may succeed when retried
+func Transient(err error) error {
+	return WithClass(ClassTransient, err)
+}
+
+// Permanent marks err as a failure that needs an operator to fix before a retry can succeed
+func Permanent(err error) error {
+	return WithClass(ClassPermanent, err)
+}
+
+// Classify returns the failure class of err. An explicit class set with WithClass takes precedence,
+// otherwise Azure responses, network errors and deadlines are classified by their type.
+func Classify(err error) Class {
+	if err == nil {
+		return ClassUnknown
+	}
+
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+
+	var responseErr *azcore.ResponseError
+	if errors.As(err, &responseErr) {
+		return ClassifyHTTPStatus(responseErr.StatusCode)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTransient
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ClassTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ClassTransient
+	}
+	return ClassUnknown
+}
+
+// ClassifyHTTPStatus classifies a failed HTTP response: throttling and server errors are transient,
+// while client errors such as a missing permission or a missing resource are permanent
+func ClassifyHTTPStatus(statusCode int) Class {
+	switch {
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusTooManyRequests, statusCode >= 500:
+		return ClassTransient
+	case statusCode >= 400:
+		return ClassPermanent
+	}
+	return ClassUnknown
+}
@@ -0,0 +1,49 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{name: "nil", err: nil, want: ClassUnknown},
+		{name: "plain error", err: errors.New("boom"), want: ClassUnknown},
+		{name: "explicit class survives wrapping", err: fmt.Errorf("step failed: %w", Permanent(errors.New("kernel too old"))), want: ClassPermanent},
+		{name: "explicit class wins over the cause", err: Permanent(context.DeadlineExceeded), want: ClassPermanent},
+		{name: "deadline", err: fmt.Errorf("download: %w", context.DeadlineExceeded), want: ClassTransient},
+		{name: "DNS failure", err: &net.DNSError{Err: "no such host", Name: "mcr.microsoft.com"}, want: ClassTransient},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ClassTransient},
+		{name: "Azure throttling", err: &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}, want: ClassTransient},
+		{name: "Azure authorization", err: fmt.Errorf("role assignment: %w", &azcore.ResponseError{StatusCode: http.StatusForbidden}), want: ClassPermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithClassKeepsMessageAndCause(t *testing.T) {
+	cause := errors.New("registry unreachable")
+	err := Transient(cause)
+	if err.Error() != cause.Error() || !errors.Is(err, cause) {
+		t.Errorf("Transient() = %v, want the cause unchanged", err)
+	}
+	if WithClass(ClassPermanent, nil) != nil {
+		t.Error("WithClass(nil) is not nil")
+	}
+}
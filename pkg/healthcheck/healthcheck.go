@@ -0,0 +1,140 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// maxMessageLength bounds how much probe output is kept in node status
+const maxMessageLength = 256
+
+// Result is the outcome of a single custom health check
+type Result struct {
+	Name      string    `json:"name"`
+	Action    string    `json:"action"`
+	Healthy   bool      `json:"healthy"`
+	Message   string    `json:"message,omitempty"` // failure reason or probe output
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Run runs the health checks concurrently so slow probes do not add up, returning results in configuration order
+func Run(ctx context.Context, checks []config.HealthCheckConfig) []Result {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Filter returns the checks configured with the given action
+func Filter(checks []config.HealthCheckConfig, action string) []config.HealthCheckConfig {
+	var filtered []config.HealthCheckConfig
+	for _, check := range checks {
+		if check.Action == action {
+			filtered = append(filtered, check)
+		}
+	}
+	return filtered
+}
+
+// Failing returns the unhealthy results of checks configured with the given action
+func Failing(results []Result, action string) []Result {
+	var failing []Result
+	for _, result := range results {
+		if !result.Healthy && result.Action == action {
+			failing = append(failing, result)
+		}
+	}
+	return failing
+}
+
+// runCheck runs a single exec or HTTP health check
+func runCheck(ctx context.Context, check config.HealthCheckConfig) Result {
+	result := Result{
+		Name:      check.Name,
+		Action:    check.Action,
+		CheckedAt: time.Now().UTC(),
+	}
+
+	timeout, err := time.ParseDuration(check.Timeout)
+	if err != nil {
+		result.Message = fmt.Sprintf("invalid timeout %q: %v", check.Timeout, err)
+		return result
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if len(check.Command) > 0 {
+		result.Healthy, result.Message = runExec(checkCtx, check.Command)
+	} else {
+		result.Healthy, result.Message = runHTTP(checkCtx, check.URL, check.ExpectedStatus)
+	}
+	result.Message = truncate(result.Message)
+	return result
+}
+
+// runExec runs the probe command, which is healthy when it exits 0
+func runExec(ctx context.Context, command []string) (bool, string) {
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Do not wait on processes the probe spawned that still hold its output open after it was killed
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	message := strings.TrimSpace(output.String())
+	if ctx.Err() == context.DeadlineExceeded {
+		return false, "timed out"
+	}
+	if err != nil {
+		if message == "" {
+			return false, err.Error()
+		}
+		return false, fmt.Sprintf("%v: %s", err, message)
+	}
+	return true, message
+}
+
+// runHTTP sends a GET request to the probe endpoint, which is healthy when it answers with the expected status
+func runHTTP(ctx context.Context, url string, expectedStatus int) (bool, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Sprintf("invalid request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return false, "timed out"
+		}
+		return false, err.Error()
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != expectedStatus {
+		return false, fmt.Sprintf("got HTTP status %d, expected %d", resp.StatusCode, expectedStatus)
+	}
+	return true, fmt.Sprintf("HTTP status %d", resp.StatusCode)
+}
+
+// truncate shortens probe output so a chatty script does not bloat the status file
+func truncate(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+	return message[:maxMessageLength] + "..."
+}
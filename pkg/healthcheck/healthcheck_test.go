@@ -0,0 +1,106 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		check       config.HealthCheckConfig
+		wantHealthy bool
+		wantMessage string
+	}{
+		{
+			name:        "command exits zero",
+			check:       config.HealthCheckConfig{Command: []string{"/bin/sh", "-c", "echo mounted"}, Timeout: "5s"},
+			wantHealthy: true,
+			wantMessage: "mounted",
+		},
+		{
+			name:        "command exits non-zero",
+			check:       config.HealthCheckConfig{Command: []string{"/bin/sh", "-c", "echo tunnel down >&2; exit 3"}, Timeout: "5s"},
+			wantMessage: "exit status 3: tunnel down",
+		},
+		{
+			name:        "command times out",
+			check:       config.HealthCheckConfig{Command: []string{"/bin/sh", "-c", "sleep 5"}, Timeout: "100ms"},
+			wantMessage: "timed out",
+		},
+		{
+			name:        "missing command",
+			check:       config.HealthCheckConfig{Command: []string{"/nonexistent/check"}, Timeout: "5s"},
+			wantMessage: "no such file",
+		},
+		{
+			name:        "http expected status",
+			check:       config.HealthCheckConfig{URL: server.URL + "/up", ExpectedStatus: 204, Timeout: "5s"},
+			wantHealthy: true,
+			wantMessage: "HTTP status 204",
+		},
+		{
+			name:        "http unexpected status",
+			check:       config.HealthCheckConfig{URL: server.URL + "/down", ExpectedStatus: 204, Timeout: "5s"},
+			wantMessage: "got HTTP status 503, expected 204",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check.Name = "probe"
+			tt.check.Action = config.HealthCheckActionReport
+			results := Run(context.Background(), []config.HealthCheckConfig{tt.check})
+			if len(results) != 1 {
+				t.Fatalf("Run() returned %d results, want 1", len(results))
+			}
+			result := results[0]
+			if result.Name != "probe" || result.Action != config.HealthCheckActionReport {
+				t.Errorf("Run() result = %+v, want name and action from the check", result)
+			}
+			if result.Healthy != tt.wantHealthy {
+				t.Errorf("Run() healthy = %v, want %v (message %q)", result.Healthy, tt.wantHealthy, result.Message)
+			}
+			if !strings.Contains(result.Message, tt.wantMessage) {
+				t.Errorf("Run() message = %q, want it to contain %q", result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestFailing(t *testing.T) {
+	results := []Result{
+		{Name: "vpn", Action: config.HealthCheckActionGate, Healthy: false},
+		{Name: "storage", Action: config.HealthCheckActionGate, Healthy: true},
+		{Name: "mount", Action: config.HealthCheckActionRemediate, Healthy: false},
+	}
+
+	failing := Failing(results, config.HealthCheckActionGate)
+	if len(failing) != 1 || failing[0].Name != "vpn" {
+		t.Errorf("Failing() = %+v, want only the vpn result", failing)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("x", maxMessageLength+10)
+	if got := truncate(long); len(got) != maxMessageLength+len("...") {
+		t.Errorf("truncate() length = %d, want %d", len(got), maxMessageLength+len("..."))
+	}
+	if got := truncate("short"); got != "short" {
+		t.Errorf("truncate() = %q, want %q", got, "short")
+	}
+}
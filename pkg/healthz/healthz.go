@@ -0,0 +1,237 @@
+package healthz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSocketPath is the Unix socket the daemon reports its health on when no other socket is configured
+const DefaultSocketPath = "/run/aks-flex-node/healthz.sock"
+
+// Thresholds the daemon health is judged by
+const (
+	// IdleTimeout is how long the daemon loop may wait without waking up; its status timer fires every minute
+	IdleTimeout = 3 * time.Minute
+	// TaskTimeout is how long a single daemon task may run, long enough for a bootstrap over a slow link
+	TaskTimeout = time.Hour
+	// StatusTimeout is how old the last successful status collection may get, matching the staleness of the status file
+	StatusTimeout = 5 * time.Minute
+)
+
+// Remediation is the self-recovery state of the daemon
+type Remediation struct {
+	BootstrapFailures int    `json:"bootstrapFailures"`          // consecutive failed auto-bootstraps
+	BootstrapBlocked  string `json:"bootstrapBlocked,omitempty"` // permanent failure that stopped auto-bootstrap
+	CredentialResets  int    `json:"credentialResets"`           // kubelet client certificate resets since it was last accepted
+}
+
+// Report is the health of the daemon as served on /healthz and /readyz
+type Report struct {
+	Live    bool     `json:"live"`              // the daemon loop is running
+	Ready   bool     `json:"ready"`             // the daemon loop is running and keeps the node status current
+	Reasons []string `json:"reasons,omitempty"` // why the daemon is not live or not ready
+
+	LastLoop             time.Time   `json:"lastLoop"`
+	Task                 string      `json:"task,omitempty"` // daemon task running now
+	TaskStartedAt        *time.Time  `json:"taskStartedAt,omitempty"`
+	LastStatusCollection *time.Time  `json:"lastStatusCollection,omitempty"` // last successful status collection
+	LastStatusError      string      `json:"lastStatusError,omitempty"`
+	Remediation          Remediation `json:"remediation"`
+}
+
+// Tracker records the activity of the daemon loop. It is safe for concurrent use.
+type Tracker struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	lastLoop    time.Time
+	task        string
+	taskStarted time.Time
+	statusAt    time.Time
+	statusErr   string
+	remediation Remediation
+}
+
+// NewTracker creates a tracker for a daemon loop starting now
+func NewTracker() *Tracker {
+	return &Tracker{now: time.Now, lastLoop: time.Now()}
+}
+
+// Beat records that the daemon loop is running
+func (t *Tracker) Beat() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastLoop = t.now()
+}
+
+// Begin records that the daemon loop started a task
+func (t *Tracker) Begin(task string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastLoop = t.now()
+	t.task = task
+	t.taskStarted = t.lastLoop
+}
+
+// End records that the daemon loop finished its task
+func (t *Tracker) End() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastLoop = t.now()
+	t.task = ""
+}
+
+// StatusCollected records the outcome of a status collection
+func (t *Tracker) StatusCollected(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.statusErr = err.Error()
+		return
+	}
+	t.statusAt = t.now()
+	t.statusErr = ""
+}
+
+// SetRemediation records the self-recovery state of the daemon
+func (t *Tracker) SetRemediation(remediation Remediation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remediation = remediation
+}
+
+// Report judges the health of the daemon from its recorded activity
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	report := Report{Live: true, LastLoop: t.lastLoop, Task: t.task, LastStatusError: t.statusErr, Remediation: t.remediation}
+	if t.task != "" {
+		started := t.taskStarted
+		report.TaskStartedAt = &started
+		if running := now.Sub(started); running > TaskTimeout {
+			report.Live = false
+			report.Reasons = append(report.Reasons, fmt.Sprintf("task %s has been running for %s", t.task, running.Round(time.Second)))
+		}
+	} else if idle := now.Sub(t.lastLoop); idle > IdleTimeout {
+		report.Live = false
+		report.Reasons = append(report.Reasons, fmt.Sprintf("daemon loop has not run for %s", idle.Round(time.Second)))
+	}
+
+	report.Ready = report.Live
+	if !t.statusAt.IsZero() {
+		collected := t.statusAt
+		report.LastStatusCollection = &collected
+	}
+	switch {
+	case t.statusAt.IsZero():
+		report.Ready = false
+		report.Reasons = append(report.Reasons, "node status has not been collected yet")
+	case now.Sub(t.statusAt) > StatusTimeout:
+		report.Ready = false
+		report.Reasons = append(report.Reasons, fmt.Sprintf("node status was last collected %s ago", now.Sub(t.statusAt).Round(time.Second)))
+	}
+	if t.remediation.BootstrapBlocked != "" {
+		report.Ready = false
+		report.Reasons = append(report.Reasons, "auto-bootstrap stopped after a permanent failure: "+t.remediation.BootstrapBlocked)
+	}
+	return report
+}
+
+// Handler serves /healthz, answering 200 while the daemon loop runs, and /readyz, answering 200 while it also
+// keeps the node status current. Both answer 503 otherwise and describe the daemon health as JSON.
+func Handler(tracker *Tracker) http.Handler {
+	respond := func(w http.ResponseWriter, ok bool, report Report) {
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		report := tracker.Report()
+		respond(w, report.Live, report)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		report := tracker.Report()
+		respond(w, report.Ready, report)
+	})
+	return mux
+}
+
+// Serve serves the daemon health on the Unix socket and, when set, the TCP address until the context is cancelled.
+// The health carries no secrets, so any local user such as a monitoring agent may connect to the socket.
+func Serve(ctx context.Context, tracker *Tracker, socketPath, address string, logger *logrus.Logger) error {
+	var listeners []net.Listener
+	if socketPath != "" {
+		if err := os.MkdirAll(filepath.Dir(socketPath), 0o755); err != nil {
+			return fmt.Errorf("failed to create socket directory: %w", err)
+		}
+		// Remove the socket left behind by a previous run
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale socket %s: %w", socketPath, err)
+		}
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+		}
+		if err := os.Chmod(socketPath, 0o666); err != nil {
+			_ = listener.Close()
+			return fmt.Errorf("failed to open %s to local users: %w", socketPath, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if address != "" {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	server := &http.Server{
+		Handler:           Handler(tracker),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		logger.Infof("Serving daemon health on %s", listener.Addr())
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("failed to serve daemon health on %s: %w", listener.Addr(), err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	var firstErr error
+	for range listeners {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			_ = server.Close()
+		}
+	}
+	return firstErr
+}
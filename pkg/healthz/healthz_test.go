@@ -0,0 +1,89 @@
+package healthz
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTracker creates a tracker whose clock is advanced by the returned function
+func newTestTracker() (*Tracker, func(time.Duration)) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tracker := &Tracker{now: func() time.Time { return now }, lastLoop: now}
+	return tracker, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTrackerReport(t *testing.T) {
+	tracker, advance := newTestTracker()
+	if report := tracker.Report(); !report.Live || report.Ready {
+		t.Errorf("Report() before the first status collection = live %t, ready %t, want live and not ready", report.Live, report.Ready)
+	}
+
+	tracker.Begin("status")
+	tracker.StatusCollected(nil)
+	tracker.End()
+	if report := tracker.Report(); !report.Live || !report.Ready {
+		t.Errorf("Report() after a status collection = %+v, want live and ready", report)
+	}
+
+	// A long bootstrap keeps the daemon live, the status goes stale meanwhile
+	tracker.Begin("bootstrapCheck")
+	advance(20 * time.Minute)
+	report := tracker.Report()
+	if !report.Live || report.Ready || report.Task != "bootstrapCheck" {
+		t.Errorf("Report() during a long bootstrap = %+v, want live and not ready", report)
+	}
+	advance(TaskTimeout)
+	if report := tracker.Report(); report.Live {
+		t.Errorf("Report() of a task running past %s = live, want not live: %v", TaskTimeout, report.Reasons)
+	}
+	tracker.End()
+
+	// A loop that stops waking up is not live
+	tracker.StatusCollected(nil)
+	advance(IdleTimeout + time.Second)
+	report = tracker.Report()
+	if report.Live || report.Ready || !strings.Contains(strings.Join(report.Reasons, ";"), "has not run") {
+		t.Errorf("Report() of an idle loop = %+v, want neither live nor ready", report)
+	}
+
+	// A failed collection keeps the last successful one and reports the error
+	tracker.Beat()
+	tracker.StatusCollected(errors.New("kubectl timed out"))
+	report = tracker.Report()
+	if report.LastStatusError != "kubectl timed out" || report.LastStatusCollection == nil || !report.Ready {
+		t.Errorf("Report() after a failed collection = %+v, want the error and the last collection", report)
+	}
+
+	// A permanent bootstrap failure needs an operator
+	tracker.SetRemediation(Remediation{BootstrapFailures: 2, BootstrapBlocked: "preflight failed"})
+	if report := tracker.Report(); !report.Live || report.Ready {
+		t.Errorf("Report() with auto-bootstrap stopped = %+v, want live and not ready", report)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tracker, _ := newTestTracker()
+	handler := Handler(tracker)
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	if code := get("/healthz").Code; code != http.StatusOK {
+		t.Errorf("GET /healthz = %d, want 200", code)
+	}
+	readyz := get("/readyz")
+	if readyz.Code != http.StatusServiceUnavailable || !strings.Contains(readyz.Body.String(), "not been collected") {
+		t.Errorf("GET /readyz before a status collection = %d %s, want 503 with the reason", readyz.Code, readyz.Body)
+	}
+
+	tracker.StatusCollected(nil)
+	if code := get("/readyz").Code; code != http.StatusOK {
+		t.Errorf("GET /readyz after a status collection = %d, want 200", code)
+	}
+}
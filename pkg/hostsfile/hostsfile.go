@@ -0,0 +1,84 @@
+package hostsfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Path is the hosts file the agent manages entries in
+var Path = "/etc/hosts"
+
+// Markers delimiting the block of entries the agent manages, everything outside the block is left untouched
+const (
+	beginMarker = "# BEGIN aks-flex-node managed entries, do not edit"
+	endMarker   = "# END aks-flex-node managed entries"
+)
+
+// Entry maps a host name to an IP address
+type Entry struct {
+	IP       string
+	Hostname string
+}
+
+// Apply replaces the managed block of the hosts file with the given entries, removing the block when there are none.
+// It reports whether the file changed, and leaves it untouched when the managed block is already up to date.
+func Apply(entries []Entry) (bool, error) {
+	current, err := os.ReadFile(Path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", Path, err)
+	}
+	updated := Render(current, entries)
+	if bytes.Equal(current, updated) {
+		return false, nil
+	}
+	if err := utils.WriteFileAtomicSystem(Path, updated, 0o644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", Path, err)
+	}
+	return true, nil
+}
+
+// Remove removes the managed block from the hosts file
+func Remove() (bool, error) {
+	return Apply(nil)
+}
+
+// Render returns the hosts file content with its managed block replaced by the given entries.
+// The block is appended at the end of the file and dropped entirely when there are no entries.
+func Render(current []byte, entries []Entry) []byte {
+	var kept []string
+	inBlock := false
+	for _, line := range strings.SplitAfter(string(current), "\n") {
+		switch strings.TrimSpace(line) {
+		case beginMarker:
+			inBlock = true
+			continue
+		case endMarker:
+			inBlock = false
+			continue
+		}
+		if !inBlock && line != "" {
+			kept = append(kept, line)
+		}
+	}
+
+	var out strings.Builder
+	for _, line := range kept {
+		out.WriteString(line)
+	}
+	if len(entries) == 0 {
+		return []byte(out.String())
+	}
+	if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+	out.WriteString(beginMarker + "\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&out, "%s\t%s\n", entry.IP, entry.Hostname)
+	}
+	out.WriteString(endMarker + "\n")
+	return []byte(out.String())
+}
@@ -0,0 +1,60 @@
+package hostsfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const baseHosts = "127.0.0.1\tlocalhost\n::1\tip6-localhost\n"
+
+func TestRender(t *testing.T) {
+	entries := []Entry{{IP: "10.224.0.4", Hostname: "mycluster-abc123.privatelink.eastus.azmk8s.io"}}
+	managed := baseHosts + beginMarker + "\n10.224.0.4\tmycluster-abc123.privatelink.eastus.azmk8s.io\n" + endMarker + "\n"
+
+	tests := []struct {
+		name    string
+		current string
+		entries []Entry
+		want    string
+	}{
+		{name: "adds the block", current: baseHosts, entries: entries, want: managed},
+		{name: "keeps an up to date block", current: managed, entries: entries, want: managed},
+		{name: "terminates the last host line", current: "127.0.0.1\tlocalhost", entries: entries,
+			want: "127.0.0.1\tlocalhost\n" + beginMarker + "\n10.224.0.4\tmycluster-abc123.privatelink.eastus.azmk8s.io\n" + endMarker + "\n"},
+		{name: "replaces a stale block", entries: entries,
+			current: baseHosts + beginMarker + "\n10.224.0.9\told.privatelink.eastus.azmk8s.io\n" + endMarker + "\n", want: managed},
+		{name: "keeps host lines after the block", entries: entries,
+			current: beginMarker + "\n10.224.0.9\told\n" + endMarker + "\n" + baseHosts, want: managed},
+		{name: "removes the block", current: managed, want: baseHosts},
+		{name: "no block and no entries", current: baseHosts, want: baseHosts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Render([]byte(tt.current), tt.entries)); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	Path = filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(Path, []byte(baseHosts), 0o644); err != nil {
+		t.Fatalf("failed to write hosts file: %v", err)
+	}
+	entries := []Entry{{IP: "10.224.0.4", Hostname: "mycluster-abc123.privatelink.eastus.azmk8s.io"}}
+
+	if changed, err := Apply(entries); err != nil || !changed {
+		t.Fatalf("Apply() = %v, %v, want the file changed", changed, err)
+	}
+	if changed, err := Apply(entries); err != nil || changed {
+		t.Errorf("Apply() again = %v, %v, want no change", changed, err)
+	}
+	if changed, err := Remove(); err != nil || !changed {
+		t.Errorf("Remove() = %v, %v, want the file changed", changed, err)
+	}
+	if data, _ := os.ReadFile(Path); string(data) != baseHosts {
+		t.Errorf("hosts file after Remove() = %q, want %q", data, baseHosts)
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields the agent adds to log entries, named the same in text and JSON output so queries work on both
+const (
+	FieldStep      = "step"      // bootstrap or unbootstrap step that logged the entry
+	FieldComponent = "component" // component the step belongs to, e.g. containerd or kubelet
+	FieldDuration  = "duration"  // seconds the step has been running
+)
+
+// stepHook adds the step a logger belongs to, its component and how long it has been running to every entry
+// logged through the logger while the step runs. Fields set by the caller take precedence.
+type stepHook struct {
+	mu        sync.RWMutex
+	now       func() time.Time
+	step      string
+	component string
+	started   time.Time
+}
+
+func newStepHook() *stepHook {
+	return &stepHook{now: time.Now}
+}
+
+// Levels returns all levels, so warnings and errors of a step carry its fields too
+func (h *stepHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the step fields to the entry
+func (h *stepHook) Fire(entry *logrus.Entry) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.step == "" {
+		return nil
+	}
+	setIfMissing(entry.Data, FieldStep, h.step)
+	if h.component != "" {
+		setIfMissing(entry.Data, FieldComponent, h.component)
+	}
+	elapsed := h.now().Sub(h.started).Seconds()
+	setIfMissing(entry.Data, FieldDuration, math.Round(elapsed*1000)/1000)
+	return nil
+}
+
+func setIfMissing(data logrus.Fields, key string, value any) {
+	if _, ok := data[key]; !ok {
+		data[key] = value
+	}
+}
+
+// NewStepLogger returns a logger for a single step, writing like base with its level, formatter and hooks. Once
+// the step begins, its entries carry the step fields. Steps running at once each log through their own logger,
+// so every entry names the step that logged it.
+func NewStepLogger(base *logrus.Logger) *logrus.Logger {
+	// The step hook fires first, so the hooks of base see the step fields
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(newStepHook())
+	for level, levelHooks := range base.Hooks {
+		hooks[level] = append(hooks[level], levelHooks...)
+	}
+	stepLogger := &logrus.Logger{
+		Out:          base.Out,
+		Hooks:        hooks,
+		Formatter:    base.Formatter,
+		ReportCaller: base.ReportCaller,
+		Level:        base.GetLevel(),
+		ExitFunc:     base.ExitFunc,
+		BufferPool:   base.BufferPool,
+	}
+	return stepLogger
+}
+
+// BeginStep records that the step of a logger from NewStepLogger started, adding the step fields to the entries
+// of the logger until the returned function is called. Other loggers are left unchanged.
+func BeginStep(logger *logrus.Logger, step, component string) func() {
+	hook := findStepHook(logger)
+	if hook == nil {
+		return func() {}
+	}
+	hook.mu.Lock()
+	hook.step, hook.component, hook.started = step, component, hook.now()
+	hook.mu.Unlock()
+	return func() {
+		hook.mu.Lock()
+		defer hook.mu.Unlock()
+		hook.step, hook.component = "", ""
+	}
+}
+
+// findStepHook returns the step hook NewStepLogger installed on the logger, if any
+func findStepHook(logger *logrus.Logger) *stepHook {
+	for _, hook := range logger.Hooks[logrus.InfoLevel] {
+		if hook, ok := hook.(*stepHook); ok {
+			return hook
+		}
+	}
+	return nil
+}
@@ -2,13 +2,17 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	azlog "github.com/Azure/azure-sdk-for-go/sdk/azcore/log"
 	"github.com/sirupsen/logrus"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
@@ -32,6 +36,14 @@ const (
 	LogLevelError LogLevel = "error"
 )
 
+// Log output formats
+const (
+	// LogFormatText writes human readable lines, colored on terminals
+	LogFormatText = "text"
+	// LogFormatJSON writes one JSON object per entry for log shippers such as Azure Monitor or Loki
+	LogFormatJSON = "json"
+)
+
 // ValidLogLevels contains all supported log levels
 var ValidLogLevels = map[string]LogLevel{
 	"debug":   LogLevelDebug,
@@ -67,9 +79,9 @@ func ParseLogLevel(level string) (logrus.Level, error) {
 	}
 }
 
-// SetupLogger creates a logger with specified level and optional log directory
+// SetupLogger creates a logger with specified level, output format and optional log directory
 // For systemd services, it supports dual output to both journal (stdout) and file
-func SetupLogger(ctx context.Context, level, logDir string) context.Context {
+func SetupLogger(ctx context.Context, level, format, logDir string) context.Context {
 	logger := logrus.New()
 
 	// Set log level with proper validation
@@ -130,9 +142,33 @@ func SetupLogger(ctx context.Context, level, logDir string) context.Context {
 		}
 	}
 
+	// JSON entries carry their own timestamp for shippers reading the journal or the log file
+	if format == LogFormatJSON {
+		logger.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			CallerPrettyfier: func(f *runtime.Frame) (string, string) {
+				return "", fmt.Sprintf("%s:%d", filepath.Base(f.File), f.Line)
+			},
+		})
+	}
+
 	return context.WithValue(ctx, loggerContextKey, logger)
 }
 
+// EnableTrace raises the context logger to trace level and logs every external command, every file written
+// by the agent and every Azure SDK request and response, including ARM and IMDS calls. Command arguments
+// holding secrets are redacted; the Azure SDK redacts authorization headers itself.
+func EnableTrace(ctx context.Context) {
+	logger := GetLoggerFromContext(ctx)
+	logger.SetLevel(logrus.TraceLevel)
+	utils.EnableTracing(logger)
+
+	azlog.SetEvents(azlog.EventRequest, azlog.EventResponse, azlog.EventRetryPolicy)
+	azlog.SetListener(func(event azlog.Event, msg string) {
+		logger.Tracef("azure %s: %s", event, msg)
+	})
+}
+
 // isRunningUnderSystemd detects if the process is running under systemd
 func isRunningUnderSystemd() bool {
 	// Check if systemd is the init system (PID 1)
@@ -157,6 +193,20 @@ func setupLogFileWriter(logDir string) (io.Writer, error) {
 		return nil, fmt.Errorf("failed to create log file '%s': %w", logFilePath, err)
 	}
 
+	file, err := openLogFile(logFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	logFile := &reopenableFile{path: logFilePath, file: file}
+	openLogFilesMu.Lock()
+	openLogFiles = append(openLogFiles, logFile)
+	openLogFilesMu.Unlock()
+	return logFile, nil
+}
+
+// openLogFile opens an existing log file for appending, fixing its permissions if needed
+func openLogFile(logFilePath string) (*os.File, error) {
 	// Try to open log file for writing, handle permission issues
 	file, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
@@ -177,6 +227,56 @@ func setupLogFileWriter(logDir string) (io.Writer, error) {
 	return file, nil
 }
 
+// Log files opened by the agent, reopened on ReopenLogFiles
+var (
+	openLogFiles   []*reopenableFile
+	openLogFilesMu sync.Mutex
+)
+
+// reopenableFile is a log file writer that can switch to a fresh file after external log rotation
+type reopenableFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func (f *reopenableFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Write(p)
+}
+
+// reopen opens the log file path again, creating it if it was rotated away, and closes the previous file
+func (f *reopenableFile) reopen() error {
+	if err := createLogFileIfNotExists(f.path); err != nil {
+		return fmt.Errorf("failed to create log file '%s': %w", f.path, err)
+	}
+	file, err := openLogFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	previous := f.file
+	f.file = file
+	f.mu.Unlock()
+	return previous.Close()
+}
+
+// ReopenLogFiles reopens all log files opened by the agent so that externally rotated files are released
+func ReopenLogFiles() error {
+	openLogFilesMu.Lock()
+	defer openLogFilesMu.Unlock()
+
+	var reopenErrors []error
+	for _, logFile := range openLogFiles {
+		if err := logFile.reopen(); err != nil {
+			reopenErrors = append(reopenErrors, err)
+		}
+	}
+	return errors.Join(reopenErrors...)
+}
+
 // ensureLogDirectoryExists creates the log directory if it doesn't exist
 func ensureLogDirectoryExists(logDir string) error {
 	// Check if directory already exists
@@ -270,6 +370,8 @@ func GetLoggerFromContext(ctx context.Context) *logrus.Logger {
 func GetCurrentLogLevel(ctx context.Context) string {
 	logger := GetLoggerFromContext(ctx)
 	switch logger.GetLevel() {
+	case logrus.TraceLevel:
+		return "trace"
 	case logrus.DebugLevel:
 		return "debug"
 	case logrus.InfoLevel:
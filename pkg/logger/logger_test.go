@@ -2,8 +2,10 @@ package logger
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -43,7 +45,7 @@ func TestSetupLogger(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
-			ctx = SetupLogger(ctx, tt.level, tt.logDir)
+			ctx = SetupLogger(ctx, tt.level, LogFormatText, tt.logDir)
 
 			logger := GetLoggerFromContext(ctx)
 			if logger == nil {
@@ -129,7 +131,7 @@ func TestValidateLogLevel(t *testing.T) {
 
 func TestLogLevelHelpers(t *testing.T) {
 	tempDir := t.TempDir()
-	ctx := SetupLogger(context.Background(), "debug", tempDir)
+	ctx := SetupLogger(context.Background(), "debug", LogFormatText, tempDir)
 
 	helpers := NewLogLevelHelpers(ctx)
 
@@ -155,7 +157,7 @@ func TestSystemdDetection(t *testing.T) {
 }
 
 func TestGetCurrentLogLevel(t *testing.T) {
-	ctx := SetupLogger(context.Background(), "warning", "")
+	ctx := SetupLogger(context.Background(), "warning", LogFormatText, "")
 	level := GetCurrentLogLevel(ctx)
 
 	if level != "warning" {
@@ -165,14 +167,132 @@ func TestGetCurrentLogLevel(t *testing.T) {
 
 func TestIsDebugEnabled(t *testing.T) {
 	// Test with debug enabled
-	ctx := SetupLogger(context.Background(), "debug", "")
+	ctx := SetupLogger(context.Background(), "debug", LogFormatText, "")
 	if !IsDebugEnabled(ctx) {
 		t.Error("Debug should be enabled for debug level")
 	}
 
 	// Test with debug disabled
-	ctx = SetupLogger(context.Background(), "error", "")
+	ctx = SetupLogger(context.Background(), "error", LogFormatText, "")
 	if IsDebugEnabled(ctx) {
 		t.Error("Debug should be disabled for error level")
 	}
 }
+
+func TestReopenableFile(t *testing.T) {
+	logFilePath := filepath.Join(t.TempDir(), "aks-flex-node.log")
+	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	logFile := &reopenableFile{path: logFilePath, file: file}
+
+	if _, err := logFile.Write([]byte("before rotation\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// Simulate external rotation by moving the file away
+	rotatedPath := logFilePath + ".1"
+	if err := os.Rename(logFilePath, rotatedPath); err != nil {
+		t.Fatalf("failed to rotate log file: %v", err)
+	}
+	if err := logFile.reopen(); err != nil {
+		t.Fatalf("reopen() error = %v", err)
+	}
+	if _, err := logFile.Write([]byte("after rotation\n")); err != nil {
+		t.Fatalf("Write() after reopen error = %v", err)
+	}
+	defer func() {
+		_ = logFile.file.Close()
+	}()
+
+	rotated, err := os.ReadFile(rotatedPath)
+	if err != nil || string(rotated) != "before rotation\n" {
+		t.Errorf("rotated log file = %q (err %v), want %q", rotated, err, "before rotation\n")
+	}
+	current, err := os.ReadFile(logFilePath)
+	if err != nil || string(current) != "after rotation\n" {
+		t.Errorf("reopened log file = %q (err %v), want %q", current, err, "after rotation\n")
+	}
+}
+
+func TestJSONFormatWithStepFields(t *testing.T) {
+	logDir := t.TempDir()
+	logger := NewStepLogger(GetLoggerFromContext(SetupLogger(context.Background(), "info", LogFormatJSON, logDir)))
+
+	endStep := BeginStep(logger, "ContainerdInstaller", "containerd")
+	logger.Info("Installing containerd")
+	endStep()
+	logger.Info("Bootstrap finished")
+
+	data, err := os.ReadFile(filepath.Join(logDir, "aks-flex-node.log"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log file has %d lines, want 2: %s", len(lines), data)
+	}
+
+	var inStep, afterStep map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &inStep); err != nil {
+		t.Fatalf("entry is not JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &afterStep); err != nil {
+		t.Fatalf("entry is not JSON: %v", err)
+	}
+	if inStep["msg"] != "Installing containerd" || inStep[FieldStep] != "ContainerdInstaller" || inStep[FieldComponent] != "containerd" {
+		t.Errorf("entry logged during the step = %v, want its step and component", inStep)
+	}
+	if _, ok := inStep[FieldDuration].(float64); !ok {
+		t.Errorf("entry logged during the step has duration %v, want seconds", inStep[FieldDuration])
+	}
+	if _, ok := afterStep[FieldStep]; ok {
+		t.Errorf("entry logged after the step = %v, want no step fields", afterStep)
+	}
+	if file, _ := inStep["file"].(string); !strings.HasPrefix(file, "logger_test.go:") {
+		t.Errorf("entry file = %v, want the caller", inStep["file"])
+	}
+}
+
+func TestStepFieldsOfConcurrentSteps(t *testing.T) {
+	logDir := t.TempDir()
+	logger := GetLoggerFromContext(SetupLogger(context.Background(), "info", LogFormatJSON, logDir))
+	runcLogger, containerdLogger := NewStepLogger(logger), NewStepLogger(logger)
+
+	endRunc := BeginStep(runcLogger, "Runc_Installer", "runc")
+	endContainerd := BeginStep(containerdLogger, "ContainerdInstaller", "containerd")
+	runcLogger.Info("Downloading runc")
+	containerdLogger.Info("Downloading containerd")
+	logger.Info("Waiting for the downloads")
+	endRunc()
+	endContainerd()
+
+	data, err := os.ReadFile(filepath.Join(logDir, "aks-flex-node.log"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("log file has %d lines, want 3: %s", len(lines), data)
+	}
+
+	entries := make([]map[string]any, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatalf("entry is not JSON: %v", err)
+		}
+	}
+	if runc := entries[0]; runc[FieldStep] != "Runc_Installer" || runc[FieldComponent] != "runc" {
+		t.Errorf("entry logged by the runc step = %v, want only the runc step", runc)
+	}
+	if containerd := entries[1]; containerd[FieldStep] != "ContainerdInstaller" || containerd[FieldComponent] != "containerd" {
+		t.Errorf("entry logged by the containerd step = %v, want only the containerd step", containerd)
+	}
+	if _, ok := entries[0][FieldDuration].(float64); !ok {
+		t.Errorf("entry logged by the runc step has duration %v, want seconds", entries[0][FieldDuration])
+	}
+	if _, ok := entries[2][FieldStep]; ok {
+		t.Errorf("entry logged outside the steps = %v, want no step fields", entries[2])
+	}
+}
@@ -0,0 +1,66 @@
+package maintenance
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy decides whether the daemon may take a disruptive action: the action must be allowed and, when windows
+// are configured, a window must be open and stay open for the longest disruption the action may cause
+type Policy struct {
+	windows       []*Window
+	maxDisruption time.Duration
+	allow         map[string]bool
+	deny          map[string]bool
+}
+
+// NewPolicy creates a policy from its windows, the maximum disruption and the allowed and denied actions.
+// Without windows actions may run at any time, and without allowed actions every action not denied is allowed.
+func NewPolicy(windows []*Window, maxDisruption time.Duration, allow, deny []string) *Policy {
+	p := &Policy{
+		windows:       windows,
+		maxDisruption: maxDisruption,
+		allow:         make(map[string]bool),
+		deny:          make(map[string]bool),
+	}
+	for _, action := range allow {
+		p.allow[action] = true
+	}
+	for _, action := range deny {
+		p.deny[action] = true
+	}
+	return p
+}
+
+// Permits reports whether action may run at the given time and, if not, why
+func (p *Policy) Permits(action string, now time.Time) (bool, string) {
+	if p.deny[action] || (len(p.allow) > 0 && !p.allow[action]) {
+		return false, fmt.Sprintf("the maintenance policy does not allow %s", action)
+	}
+	if len(p.windows) == 0 {
+		return true, ""
+	}
+
+	var closesAt, nextOpen time.Time
+	for _, window := range p.windows {
+		if until := window.OpenUntil(now); !until.IsZero() {
+			if until.Sub(now) >= p.maxDisruption {
+				return true, ""
+			}
+			closesAt = until
+		}
+		if next := window.NextOpen(now); !next.IsZero() && (nextOpen.IsZero() || next.Before(nextOpen)) {
+			nextOpen = next
+		}
+	}
+
+	next := "no maintenance window opens within a year"
+	if !nextOpen.IsZero() {
+		next = "the next maintenance window opens at " + nextOpen.Format(time.RFC3339)
+	}
+	if !closesAt.IsZero() {
+		return false, fmt.Sprintf("the maintenance window closes at %s, within the maximum disruption of %s, %s",
+			closesAt.Format(time.RFC3339), p.maxDisruption, next)
+	}
+	return false, next
+}
@@ -0,0 +1,87 @@
+package maintenance
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicyPermits(t *testing.T) {
+	// Saturdays from 02:00 for 4 hours
+	window, err := NewWindow("0 2 * * sat", "4h", "UTC")
+	if err != nil {
+		t.Fatalf("NewWindow() unexpected error: %v", err)
+	}
+	saturday := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		policy     *Policy
+		action     string
+		now        time.Time
+		want       bool
+		wantReason string
+	}{
+		{
+			name:   "no windows",
+			policy: NewPolicy(nil, 0, nil, nil),
+			action: "rebootstrap",
+			now:    saturday.Add(-time.Hour),
+			want:   true,
+		},
+		{
+			name:   "inside window",
+			policy: NewPolicy([]*Window{window}, time.Hour, nil, nil),
+			action: "rebootstrap",
+			now:    saturday.Add(time.Hour),
+			want:   true,
+		},
+		{
+			name:       "outside window",
+			policy:     NewPolicy([]*Window{window}, 0, nil, nil),
+			action:     "rebootstrap",
+			now:        saturday.Add(-time.Hour),
+			wantReason: "the next maintenance window opens at 2025-03-01T02:00:00Z",
+		},
+		{
+			name:       "window closes within the maximum disruption",
+			policy:     NewPolicy([]*Window{window}, time.Hour, nil, nil),
+			action:     "rebootstrap",
+			now:        saturday.Add(3*time.Hour + 30*time.Minute),
+			wantReason: "closes at 2025-03-01T06:00:00Z",
+		},
+		{
+			name:       "denied action",
+			policy:     NewPolicy(nil, 0, nil, []string{"upgrade"}),
+			action:     "upgrade",
+			now:        saturday,
+			wantReason: "does not allow upgrade",
+		},
+		{
+			name:       "action missing from allow list",
+			policy:     NewPolicy(nil, 0, []string{"rebootstrap"}, nil),
+			action:     "containerdRepair",
+			now:        saturday,
+			wantReason: "does not allow containerdRepair",
+		},
+		{
+			name:   "allowed action",
+			policy: NewPolicy(nil, 0, []string{"rebootstrap"}, nil),
+			action: "rebootstrap",
+			now:    saturday,
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.policy.Permits(tt.action, tt.now)
+			if got != tt.want {
+				t.Errorf("Permits(%s, %s) = %v (%s), want %v", tt.action, tt.now, got, reason, tt.want)
+			}
+			if !strings.Contains(reason, tt.wantReason) {
+				t.Errorf("Permits(%s, %s) reason = %q, want it to contain %q", tt.action, tt.now, reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxWindowDuration bounds how long a window may stay open after it starts
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// nextOpenSearchLimit bounds how far ahead NextOpen searches for the next window start
+const nextOpenSearchLimit = 366 * 24 * time.Hour
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// Window is a recurring maintenance window: a cron schedule of window starts, each open for a fixed duration
+type Window struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+	// Standard cron semantics: when both day fields are restricted, a day matching either one matches
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+
+	duration time.Duration
+	location *time.Location
+}
+
+// NewWindow parses a five-field cron schedule (minute hour day-of-month month day-of-week),
+// the duration each window stays open and an IANA time zone name (empty means UTC)
+func NewWindow(schedule, duration, timeZone string) (*Window, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have 5 fields: minute hour day-of-month month day-of-week", schedule)
+	}
+
+	w := &Window{
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+	var err error
+	if w.minutes, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if w.hours, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if w.daysOfMonth, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	if w.months, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if w.daysOfWeek, err = parseField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+	// Both 0 and 7 mean Sunday
+	if w.daysOfWeek[7] {
+		w.daysOfWeek[0] = true
+	}
+
+	if w.duration, err = time.ParseDuration(duration); err != nil {
+		return nil, fmt.Errorf("invalid duration %q: %w", duration, err)
+	}
+	if w.duration < time.Minute || w.duration > maxWindowDuration {
+		return nil, fmt.Errorf("duration %s must be between 1m and %s", w.duration, maxWindowDuration)
+	}
+
+	if w.location, err = time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return w, nil
+}
+
+// IsOpen reports whether the window is open at the given time
+func (w *Window) IsOpen(now time.Time) bool {
+	return !w.OpenUntil(now).IsZero()
+}
+
+// OpenUntil returns when the window open at the given time closes, or the zero time if it is closed
+func (w *Window) OpenUntil(now time.Time) time.Time {
+	now = now.In(w.location)
+	// Check every minute a window could have started at and still be open, the latest start closes last
+	start := now.Truncate(time.Minute)
+	for t := start; now.Sub(t) < w.duration; t = t.Add(-time.Minute) {
+		if w.matches(t) {
+			return t.Add(w.duration)
+		}
+	}
+	return time.Time{}
+}
+
+// Duration returns how long each window stays open
+func (w *Window) Duration() time.Duration {
+	return w.duration
+}
+
+// NextOpen returns the start of the next window after the given time, or the zero time if none is found within a year
+func (w *Window) NextOpen(now time.Time) time.Time {
+	now = now.In(w.location)
+	start := now.Truncate(time.Minute).Add(time.Minute)
+	for t := start; t.Sub(now) <= nextOpenSearchLimit; t = t.Add(time.Minute) {
+		if w.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matches reports whether a window starts at the given minute
+func (w *Window) matches(t time.Time) bool {
+	if !w.minutes[t.Minute()] || !w.hours[t.Hour()] || !w.months[int(t.Month())] {
+		return false
+	}
+	dayOfMonth := w.daysOfMonth[t.Day()]
+	dayOfWeek := w.daysOfWeek[int(t.Weekday())]
+	switch {
+	case w.anyDayOfMonth && w.anyDayOfWeek:
+		return true
+	case w.anyDayOfMonth:
+		return dayOfWeek
+	case w.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// parseField parses a comma-separated cron field of values, ranges and steps, e.g. "1-5", "*/15" or "mon,wed"
+func parseField(field string, min, max int, names map[string]int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = parseValue(lowPart, min, max, names); err != nil {
+				return nil, err
+			}
+			high = low
+			if isRange {
+				if high, err = parseValue(highPart, min, max, names); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				high = max
+			}
+			if low > high {
+				return nil, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// parseValue parses a single cron value, accepting three-letter names where the field defines them
+func parseValue(value string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
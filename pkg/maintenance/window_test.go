@@ -0,0 +1,111 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWindowErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule string
+		duration string
+		timeZone string
+	}{
+		{name: "too few fields", schedule: "0 2 * *", duration: "4h"},
+		{name: "minute out of range", schedule: "60 2 * * *", duration: "4h"},
+		{name: "reversed range", schedule: "0 5-2 * * *", duration: "4h"},
+		{name: "zero step", schedule: "*/0 2 * * *", duration: "4h"},
+		{name: "unknown weekday name", schedule: "0 2 * * funday", duration: "4h"},
+		{name: "invalid duration", schedule: "0 2 * * *", duration: "four hours"},
+		{name: "duration too long", schedule: "0 2 * * *", duration: "200h"},
+		{name: "unknown time zone", schedule: "0 2 * * *", duration: "4h", timeZone: "Mars/Olympus_Mons"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWindow(tt.schedule, tt.duration, tt.timeZone); err == nil {
+				t.Errorf("NewWindow(%q, %q, %q) expected error but got none", tt.schedule, tt.duration, tt.timeZone)
+			}
+		})
+	}
+}
+
+func TestWindowIsOpen(t *testing.T) {
+	// Saturdays and Sundays from 02:00 for 4 hours
+	window, err := NewWindow("0 2 * * sat,sun", "4h", "UTC")
+	if err != nil {
+		t.Fatalf("NewWindow() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{name: "window start", now: time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC), want: true},    // Saturday
+		{name: "inside window", now: time.Date(2025, 3, 2, 5, 59, 30, 0, time.UTC), want: true}, // Sunday
+		{name: "window end is exclusive", now: time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC), want: false},
+		{name: "before window", now: time.Date(2025, 3, 1, 1, 59, 0, 0, time.UTC), want: false},
+		{name: "weekday", now: time.Date(2025, 3, 3, 3, 0, 0, 0, time.UTC), want: false}, // Monday
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := window.IsOpen(tt.now); got != tt.want {
+				t.Errorf("IsOpen(%s) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowAcrossMidnightInTimeZone(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	window, err := NewWindow("30 22 * * fri", "3h", "America/New_York")
+	if err != nil {
+		t.Fatalf("NewWindow() unexpected error: %v", err)
+	}
+
+	// Saturday 01:00 local time is inside the window that opened Friday 22:30
+	if now := time.Date(2025, 3, 1, 1, 0, 0, 0, location); !window.IsOpen(now) {
+		t.Errorf("IsOpen(%s) = false, want true", now)
+	}
+	// The same instant expressed in UTC must give the same answer
+	if now := time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC); !window.IsOpen(now) {
+		t.Errorf("IsOpen(%s) = false, want true", now)
+	}
+}
+
+func TestWindowNextOpen(t *testing.T) {
+	window, err := NewWindow("*/30 9-10 1,15 * *", "15m", "")
+	if err != nil {
+		t.Fatalf("NewWindow() unexpected error: %v", err)
+	}
+
+	now := time.Date(2025, 3, 1, 10, 45, 0, 0, time.UTC)
+	want := time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC)
+	if got := window.NextOpen(now); !got.Equal(want) {
+		t.Errorf("NextOpen(%s) = %s, want %s", now, got, want)
+	}
+}
+
+func TestWindowDayOfMonthOrDayOfWeek(t *testing.T) {
+	// Standard cron semantics: the 1st of the month or any Monday
+	window, err := NewWindow("0 0 1 * mon", "1h", "")
+	if err != nil {
+		t.Fatalf("NewWindow() unexpected error: %v", err)
+	}
+
+	if now := time.Date(2025, 3, 1, 0, 30, 0, 0, time.UTC); !window.IsOpen(now) { // Saturday the 1st
+		t.Errorf("IsOpen(%s) = false, want true", now)
+	}
+	if now := time.Date(2025, 3, 10, 0, 30, 0, 0, time.UTC); !window.IsOpen(now) { // Monday the 10th
+		t.Errorf("IsOpen(%s) = false, want true", now)
+	}
+	if now := time.Date(2025, 3, 11, 0, 30, 0, 0, time.UTC); window.IsOpen(now) { // Tuesday the 11th
+		t.Errorf("IsOpen(%s) = true, want false", now)
+	}
+}
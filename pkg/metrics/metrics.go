@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/readiness"
+	"go.goms.io/aks/AKSFlexNode/pkg/update"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// namespace prefixes every metric name exported by the agent
+const namespace = "aks_flex_node"
+
+// metricsPath is the HTTP path metrics are served on
+const metricsPath = "/metrics"
+
+// Family is a metric family in the Prometheus text exposition format
+type Family struct {
+	Name    string
+	Help    string
+	Type    string // gauge or counter
+	Samples []Sample
+}
+
+// Sample is a single labelled value of a metric family
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Gather collects the current agent metrics
+func Gather() []Family {
+	families := []Family{buildInfoFamily(buildinfo.Get())}
+	if result, err := update.LoadResult(); err == nil && result != nil {
+		families = append(families, updateFamily(result))
+	}
+	if history, err := readiness.Load(); err == nil {
+		if boot := history.Latest(); boot != nil {
+			families = append(families, readinessFamilies(boot)...)
+		}
+	}
+	families = append(families, commandFamilies(utils.GetCommandMetrics())...)
+	return families
+}
+
+// buildInfoFamily exposes the agent build metadata as labels, following the Prometheus build_info convention
+func buildInfoFamily(build buildinfo.Info) Family {
+	return Family{
+		Name: namespace + "_build_info",
+		Help: "Build metadata of the running agent, always 1.",
+		Type: "gauge",
+		Samples: []Sample{{
+			Labels: map[string]string{
+				"version":    build.Version,
+				"git_commit": build.GitCommit,
+				"build_time": build.BuildTime,
+				"go_version": build.GoVersion,
+				"platform":   build.Platform,
+			},
+			Value: 1,
+		}},
+	}
+}
+
+// updateFamily reports whether a newer agent is published on the configured release channel
+func updateFamily(result *update.Result) Family {
+	value := 0.0
+	if result.Outdated {
+		value = 1
+	}
+	return Family{
+		Name: namespace + "_update_available",
+		Help: "Whether a newer agent version is published on the configured release channel.",
+		Type: "gauge",
+		Samples: []Sample{{
+			Labels: map[string]string{"current_version": result.CurrentVersion, "latest_version": result.LatestVersion},
+			Value:  value,
+		}},
+	}
+}
+
+// readinessFamilies exposes the timeline of the last boot that reached Ready, for tracking provisioning objectives
+func readinessFamilies(boot *readiness.Boot) []Family {
+	bootToReady := Family{
+		Name:    namespace + "_boot_to_ready_seconds",
+		Help:    "Time from the last host boot to the node first reporting Ready.",
+		Type:    "gauge",
+		Samples: []Sample{{Value: boot.BootToReadySeconds}},
+	}
+	milestones := Family{
+		Name: namespace + "_boot_milestone_seconds",
+		Help: "Time from the last host boot to each milestone on the way to the node reporting Ready.",
+		Type: "gauge",
+	}
+	for _, milestone := range []struct {
+		name string
+		at   *time.Time
+	}{
+		{"agent_start", boot.AgentStartedAt},
+		{"bootstrap_start", boot.BootstrapStartedAt},
+		{"bootstrap_end", boot.BootstrapFinishedAt},
+		{"kubelet_start", boot.KubeletStartedAt},
+		{"ready", boot.ReadyAt},
+	} {
+		if milestone.at == nil {
+			continue
+		}
+		milestones.Samples = append(milestones.Samples, Sample{
+			Labels: map[string]string{"milestone": milestone.name},
+			Value:  milestone.at.Sub(boot.BootedAt).Seconds(),
+		})
+	}
+	return []Family{bootToReady, milestones}
+}
+
+// commandFamilies exposes execution statistics of the external commands the agent ran
+func commandFamilies(commandMetrics []utils.CommandMetric) []Family {
+	executions := Family{Name: namespace + "_command_executions_total", Help: "External commands run by the agent.", Type: "counter"}
+	failures := Family{Name: namespace + "_command_failures_total", Help: "External commands that failed.", Type: "counter"}
+	duration := Family{Name: namespace + "_command_duration_seconds_total", Help: "Time spent running external commands.", Type: "counter"}
+	maxDuration := Family{Name: namespace + "_command_duration_seconds_max", Help: "Longest single run of an external command.", Type: "gauge"}
+
+	for _, metric := range commandMetrics {
+		labels := map[string]string{"command": metric.Name}
+		executions.Samples = append(executions.Samples, Sample{Labels: labels, Value: float64(metric.Count)})
+		failures.Samples = append(failures.Samples, Sample{Labels: labels, Value: float64(metric.Failures)})
+		duration.Samples = append(duration.Samples, Sample{Labels: labels, Value: metric.TotalDuration.Seconds()})
+		maxDuration.Samples = append(maxDuration.Samples, Sample{Labels: labels, Value: metric.MaxDuration.Seconds()})
+	}
+	return []Family{executions, failures, duration, maxDuration}
+}
+
+// WriteText writes the metric families in the Prometheus text exposition format.
+// Labels are written in sorted order so the output is stable across scrapes.
+func WriteText(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, family.Help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			bw.WriteString(family.Name)
+			if len(sample.Labels) > 0 {
+				pairs := make([]string, 0, len(sample.Labels))
+				for _, key := range slices.Sorted(maps.Keys(sample.Labels)) {
+					pairs = append(pairs, fmt.Sprintf(`%s="%s"`, key, labelValueEscaper.Replace(sample.Labels[key])))
+				}
+				fmt.Fprintf(bw, "{%s}", strings.Join(pairs, ","))
+			}
+			fmt.Fprintf(bw, " %s\n", strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// labelValueEscaper escapes the characters the exposition format does not allow in a quoted label value
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler returns an HTTP handler serving the agent metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteText(w, Gather())
+	})
+}
+
+// Serve serves the agent metrics on the given address until the context is cancelled
+func Serve(ctx context.Context, address string, logger *logrus.Logger) error {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("Serving metrics on http://%s%s", address, metricsPath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics on %s: %w", address, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/readiness"
+	"go.goms.io/aks/AKSFlexNode/pkg/update"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+func TestWriteText(t *testing.T) {
+	families := []Family{
+		buildInfoFamily(buildinfo.Info{
+			Version:   "v0.4.2",
+			GitCommit: "f5d4f7f",
+			BuildTime: "2026-10-01T12:00:00Z",
+			GoVersion: "go1.24.4",
+			Platform:  "linux/arm64",
+		}),
+		updateFamily(&update.Result{CurrentVersion: "v0.4.2", LatestVersion: "v0.5.0", Outdated: true}),
+	}
+	bootedAt := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	agentStartedAt, readyAt := bootedAt.Add(30*time.Second), bootedAt.Add(150*time.Second)
+	families = append(families, readinessFamilies(&readiness.Boot{
+		BootedAt:           bootedAt,
+		AgentStartedAt:     &agentStartedAt,
+		ReadyAt:            &readyAt,
+		BootToReadySeconds: 150,
+	})...)
+	families = append(families, commandFamilies([]utils.CommandMetric{
+		{Name: "systemctl", Count: 12, Failures: 1, TotalDuration: 1500 * time.Millisecond, MaxDuration: 400 * time.Millisecond},
+	})...)
+
+	var out strings.Builder
+	if err := WriteText(&out, families); err != nil {
+		t.Fatalf("WriteText() unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"# TYPE aks_flex_node_build_info gauge\n",
+		`aks_flex_node_build_info{build_time="2026-10-01T12:00:00Z",git_commit="f5d4f7f",go_version="go1.24.4",platform="linux/arm64",version="v0.4.2"} 1` + "\n",
+		`aks_flex_node_update_available{current_version="v0.4.2",latest_version="v0.5.0"} 1` + "\n",
+		"aks_flex_node_boot_to_ready_seconds 150\n",
+		`aks_flex_node_boot_milestone_seconds{milestone="agent_start"} 30` + "\n",
+		`aks_flex_node_boot_milestone_seconds{milestone="ready"} 150` + "\n",
+		`aks_flex_node_command_executions_total{command="systemctl"} 12` + "\n",
+		`aks_flex_node_command_failures_total{command="systemctl"} 1` + "\n",
+		`aks_flex_node_command_duration_seconds_total{command="systemctl"} 1.5` + "\n",
+		`aks_flex_node_command_duration_seconds_max{command="systemctl"} 0.4` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteText() output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteTextEscapesLabelValues(t *testing.T) {
+	families := []Family{{
+		Name:    "test_metric",
+		Help:    "Test metric.",
+		Type:    "gauge",
+		Samples: []Sample{{Labels: map[string]string{"value": "a \"quoted\" C:\\path\nline"}, Value: 2}},
+	}}
+
+	var out strings.Builder
+	if err := WriteText(&out, families); err != nil {
+		t.Fatalf("WriteText() unexpected error: %v", err)
+	}
+	if want := `test_metric{value="a \"quoted\" C:\\path\nline"} 2` + "\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("WriteText() = %q, want it to end with %q", out.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", metricsPath, nil))
+
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	if !strings.Contains(recorder.Body.String(), "aks_flex_node_build_info{") {
+		t.Errorf("Handler() body missing build info:\n%s", recorder.Body.String())
+	}
+}
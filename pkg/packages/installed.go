@@ -0,0 +1,81 @@
+package packages
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// InstalledFilePath records the host packages the agent installed, as opposed to packages it found present,
+// so unbootstrap removes only those
+var InstalledFilePath = filepath.Join(config.AgentStateDir, "installed-packages.json")
+
+// InstallRecord lists the host packages the agent installed
+type InstallRecord struct {
+	Manager     string    `json:"manager"`  // package manager that installed them
+	Packages    []string  `json:"packages"` // in name order
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// RecordInstalled adds packages the agent installed to the install record
+func RecordInstalled(manager string, pkgs []string) error {
+	record, err := LoadInstalled()
+	if err != nil || record == nil {
+		record = &InstallRecord{}
+	}
+	record.Manager = manager
+	for _, pkg := range pkgs {
+		if !slices.Contains(record.Packages, pkg) {
+			record.Packages = append(record.Packages, pkg)
+		}
+	}
+	slices.Sort(record.Packages)
+	record.InstalledAt = time.Now().UTC()
+	return saveInstalled(record)
+}
+
+// ForgetInstalled removes packages from the install record, deleting the record once it is empty
+func ForgetInstalled(pkgs []string) error {
+	record, err := LoadInstalled()
+	if err != nil || record == nil {
+		return err
+	}
+	record.Packages = slices.DeleteFunc(record.Packages, func(pkg string) bool { return slices.Contains(pkgs, pkg) })
+	if len(record.Packages) == 0 {
+		return utils.RunCleanupCommand(InstalledFilePath)
+	}
+	return saveInstalled(record)
+}
+
+// LoadInstalled reads the install record, returning nil when the agent installed no packages
+func LoadInstalled() (*InstallRecord, error) {
+	data, err := os.ReadFile(InstalledFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read installed packages record: %w", err)
+	}
+	record := &InstallRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse installed packages record: %w", err)
+	}
+	return record, nil
+}
+
+func saveInstalled(record *InstallRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal installed packages record: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(InstalledFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", InstalledFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(InstalledFilePath, data, 0o644)
+}
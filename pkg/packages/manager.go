@@ -0,0 +1,181 @@
+package packages
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Manager installs host packages with the distribution package manager
+type Manager interface {
+	// Name returns the package manager command
+	Name() string
+	// IsInstalled reports whether a package is installed
+	IsInstalled(pkg string) bool
+	// Install installs packages from the configured repositories
+	Install(ctx context.Context, pkgs []string) error
+	// InstallFiles installs local package files, resolving dependencies between them
+	InstallFiles(ctx context.Context, files []string) error
+	// Remove removes installed packages
+	Remove(ctx context.Context, pkgs []string) error
+	// Dependents returns the installed packages that depend on a package
+	Dependents(pkg string) []string
+	// FileExtension returns the extension of the package files this manager installs
+	FileExtension() string
+}
+
+// DetectManager returns the package manager available on this machine
+func DetectManager() (Manager, error) {
+	if _, err := lookPath("apt-get"); err == nil {
+		return &aptManager{}, nil
+	}
+	// Azure Linux ships tdnf, other rpm based distributions dnf or yum
+	for _, name := range []string{"tdnf", "dnf", "yum"} {
+		if _, err := lookPath(name); err == nil {
+			return &rpmManager{command: name}, nil
+		}
+	}
+	// SUSE Linux Enterprise and openSUSE
+	if _, err := lookPath("zypper"); err == nil {
+		return &zypperManager{rpmManager{command: "zypper"}}, nil
+	}
+	return nil, fmt.Errorf("no supported package manager found (apt-get, tdnf, dnf, yum or zypper)")
+}
+
+// OfflinePackageFiles returns the package files in dir that the manager can install
+func OfflinePackageFiles(dir string, manager Manager) ([]string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("offline package directory %s is not accessible: %w", dir, err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"+manager.FileExtension()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offline packages in %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// aptManager installs .deb packages on Debian and Ubuntu
+type aptManager struct{}
+
+func (m *aptManager) Name() string {
+	return "apt-get"
+}
+
+func (m *aptManager) IsInstalled(pkg string) bool {
+	output, err := utils.RunCommandWithOutput("dpkg-query", "-W", "-f=${Status}", pkg)
+	return err == nil && output == "install ok installed"
+}
+
+func (m *aptManager) Install(ctx context.Context, pkgs []string) error {
+	args := append([]string{"install", "-y"}, pkgs...)
+	return utils.RunSystemCommandContext(ctx, "apt-get", args...)
+}
+
+func (m *aptManager) InstallFiles(ctx context.Context, files []string) error {
+	// apt-get treats absolute paths as local package files and resolves dependencies between them
+	args := append([]string{"install", "-y"}, files...)
+	return utils.RunSystemCommandContext(ctx, "apt-get", args...)
+}
+
+func (m *aptManager) Remove(ctx context.Context, pkgs []string) error {
+	args := append([]string{"purge", "-y"}, pkgs...)
+	return utils.RunSystemCommandContext(ctx, "apt-get", args...)
+}
+
+func (m *aptManager) Dependents(pkg string) []string {
+	output, err := utils.RunCommandWithOutput("apt-cache", "rdepends", "--installed", "--no-recommends", "--no-suggests", pkg)
+	if err != nil {
+		return nil
+	}
+	// The package name and a "Reverse Depends:" header precede the dependents, alternatives are prefixed with |
+	var dependents []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		if name := strings.TrimLeft(strings.TrimSpace(line), "|"); name != "" && !slices.Contains(dependents, name) {
+			dependents = append(dependents, name)
+		}
+	}
+	return dependents
+}
+
+func (m *aptManager) FileExtension() string {
+	return ".deb"
+}
+
+// rpmManager installs .rpm packages with tdnf, dnf or yum
+type rpmManager struct {
+	command string
+}
+
+func (m *rpmManager) Name() string {
+	return m.command
+}
+
+func (m *rpmManager) IsInstalled(pkg string) bool {
+	_, err := utils.RunCommandWithOutput("rpm", "-q", pkg)
+	return err == nil
+}
+
+func (m *rpmManager) Install(ctx context.Context, pkgs []string) error {
+	args := append([]string{"install", "-y"}, pkgs...)
+	return utils.RunSystemCommandContext(ctx, m.command, args...)
+}
+
+func (m *rpmManager) InstallFiles(ctx context.Context, files []string) error {
+	// Keep the install offline: resolve dependencies only from the given files
+	args := append([]string{"install", "-y", "--disablerepo=*"}, files...)
+	return utils.RunSystemCommandContext(ctx, m.command, args...)
+}
+
+func (m *rpmManager) Remove(ctx context.Context, pkgs []string) error {
+	args := append([]string{"remove", "-y"}, pkgs...)
+	return utils.RunSystemCommandContext(ctx, m.command, args...)
+}
+
+func (m *rpmManager) Dependents(pkg string) []string {
+	// rpm fails with "no package requires" when nothing depends on the package
+	output, err := utils.RunCommandWithOutput("rpm", "-q", "--whatrequires", pkg, "--qf", "%{NAME}\n")
+	if err != nil {
+		return nil
+	}
+	var dependents []string
+	for _, name := range strings.Fields(output) {
+		if !slices.Contains(dependents, name) {
+			dependents = append(dependents, name)
+		}
+	}
+	return dependents
+}
+
+func (m *rpmManager) FileExtension() string {
+	return ".rpm"
+}
+
+// zypperManager installs .rpm packages with zypper on SUSE distributions. It queries packages with rpm like
+// the other rpm based managers, but zypper takes its options before the command and prompts unless told not to.
+type zypperManager struct {
+	rpmManager
+}
+
+func (m *zypperManager) Install(ctx context.Context, pkgs []string) error {
+	args := append([]string{"--non-interactive", "install"}, pkgs...)
+	return utils.RunSystemCommandContext(ctx, m.command, args...)
+}
+
+func (m *zypperManager) InstallFiles(ctx context.Context, files []string) error {
+	// Keep the install offline: resolve dependencies only from the given files
+	args := append([]string{"--non-interactive", "--disable-repositories", "install"}, files...)
+	return utils.RunSystemCommandContext(ctx, m.command, args...)
+}
+
+func (m *zypperManager) Remove(ctx context.Context, pkgs []string) error {
+	args := append([]string{"--non-interactive", "remove"}, pkgs...)
+	return utils.RunSystemCommandContext(ctx, m.command, args...)
+}
@@ -0,0 +1,84 @@
+package packages
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+func TestDetectManager(t *testing.T) {
+	restore := lookPath
+	defer func() { lookPath = restore }()
+
+	tests := []struct {
+		name      string
+		available []string
+		want      string
+	}{
+		{name: "apt wins", available: []string{"apt-get", "zypper"}, want: "apt-get"},
+		{name: "Azure Linux", available: []string{"tdnf", "dnf"}, want: "tdnf"},
+		{name: "RHEL", available: []string{"dnf", "yum"}, want: "dnf"},
+		{name: "SLES", available: []string{"zypper"}, want: "zypper"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookPath = func(command string) (string, error) {
+				if slices.Contains(tt.available, command) {
+					return "/usr/bin/" + command, nil
+				}
+				return "", errors.New("not found")
+			}
+			manager, err := DetectManager()
+			if err != nil {
+				t.Fatalf("DetectManager() unexpected error: %v", err)
+			}
+			if manager.Name() != tt.want {
+				t.Errorf("DetectManager() = %s, want %s", manager.Name(), tt.want)
+			}
+		})
+	}
+
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	if _, err := DetectManager(); err == nil {
+		t.Error("DetectManager() without package manager should fail")
+	}
+}
+
+// recordingRunner records the command lines it runs
+type recordingRunner struct {
+	commands []string
+}
+
+func (r *recordingRunner) Run(_ context.Context, cmd sysutil.Command) (*sysutil.CommandResult, error) {
+	r.commands = append(r.commands, strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
+	return &sysutil.CommandResult{}, nil
+}
+
+func TestZypperManager(t *testing.T) {
+	runner := &recordingRunner{}
+	t.Cleanup(sysutil.SetCommandRunner(runner))
+
+	manager := &zypperManager{rpmManager{command: "zypper"}}
+	ctx := context.Background()
+	_ = manager.Install(ctx, []string{"jq", "socat"})
+	_ = manager.InstallFiles(ctx, []string{"/srv/jq.rpm"})
+	_ = manager.Remove(ctx, []string{"jq"})
+	_ = manager.IsInstalled("jq")
+
+	want := []string{
+		"zypper --non-interactive install jq socat",
+		"zypper --non-interactive --disable-repositories install /srv/jq.rpm",
+		"zypper --non-interactive remove jq",
+		"rpm -q jq",
+	}
+	if !slices.Equal(runner.commands, want) {
+		t.Errorf("commands = %q, want %q", runner.commands, want)
+	}
+	if manager.FileExtension() != ".rpm" {
+		t.Errorf("FileExtension() = %s, want .rpm", manager.FileExtension())
+	}
+}
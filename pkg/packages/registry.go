@@ -0,0 +1,98 @@
+package packages
+
+import (
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Requirement is a host package a component needs before it can be installed
+type Requirement struct {
+	Package string // Package name, identical on apt and rpm based distributions
+	Command string // Command the package provides; when empty the package manager is queried instead
+	Reason  string // Why the component needs the package, shown when it is missing
+}
+
+// registry declares the host packages each bootstrap component requires
+var registry = map[string][]Requirement{
+	"kubelet": {
+		{Package: "curl", Command: "curl", Reason: "the kubelet exec credential script requests tokens with it"},
+		{Package: "jq", Command: "jq", Reason: "the kubelet exec credential script parses tokens with it"},
+		{Package: "iptables", Command: "iptables", Reason: "kubelet programs its firewall chains with it"},
+	},
+	"cni": {
+		{Package: "tar", Command: "tar", Reason: "CNI plugin archives are extracted with it"},
+		{Package: "kmod", Command: "modprobe", Reason: "the br_netfilter module is loaded with it"},
+	},
+	"containerd": {
+		{Package: "tar", Command: "tar", Reason: "the containerd archive is extracted with it"},
+	},
+	"kube_binaries": {
+		{Package: "tar", Command: "tar", Reason: "the Kubernetes node archive is extracted with it"},
+	},
+	"npd": {
+		{Package: "tar", Command: "tar", Reason: "the Node Problem Detector archive is extracted with it"},
+	},
+}
+
+// lookPath is replaced in tests
+var lookPath = exec.LookPath
+
+// Requirements returns the requirements of all registered components, the requirements of optional components
+// the configuration enables and the additional packages, with each package listed once in name order
+func Requirements(additional []string, optional ...Requirement) []Requirement {
+	byPackage := make(map[string]Requirement)
+	var all []Requirement
+	for _, component := range slices.Sorted(maps.Keys(registry)) {
+		all = append(all, registry[component]...)
+	}
+	for _, req := range append(all, optional...) {
+		if existing, ok := byPackage[req.Package]; ok {
+			// Combine the reasons of every component that needs the package
+			existing.Reason += "; " + req.Reason
+			byPackage[req.Package] = existing
+			continue
+		}
+		byPackage[req.Package] = req
+	}
+	for _, pkg := range additional {
+		pkg = strings.TrimSpace(pkg)
+		if _, ok := byPackage[pkg]; !ok && pkg != "" {
+			byPackage[pkg] = Requirement{Package: pkg, Reason: "listed in packages.additional"}
+		}
+	}
+
+	requirements := make([]Requirement, 0, len(byPackage))
+	for _, pkg := range slices.Sorted(maps.Keys(byPackage)) {
+		requirements = append(requirements, byPackage[pkg])
+	}
+	return requirements
+}
+
+// Missing returns the requirements that are not satisfied on this machine.
+// Requirements without a command are checked with the package manager, and are reported missing when none is available.
+func Missing(requirements []Requirement, manager Manager) []Requirement {
+	var missing []Requirement
+	for _, req := range requirements {
+		if req.Command != "" {
+			if _, err := lookPath(req.Command); err != nil {
+				missing = append(missing, req)
+			}
+			continue
+		}
+		if manager == nil || !manager.IsInstalled(req.Package) {
+			missing = append(missing, req)
+		}
+	}
+	return missing
+}
+
+// PackageNames returns the package names of the requirements
+func PackageNames(requirements []Requirement) []string {
+	names := make([]string, 0, len(requirements))
+	for _, req := range requirements {
+		names = append(names, req.Package)
+	}
+	return names
+}
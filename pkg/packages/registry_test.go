@@ -0,0 +1,68 @@
+package packages
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type fakeManager struct {
+	installed map[string]bool
+}
+
+func (m *fakeManager) Name() string                                         { return "fake" }
+func (m *fakeManager) IsInstalled(pkg string) bool                          { return m.installed[pkg] }
+func (m *fakeManager) Install(_ context.Context, pkgs []string) error       { return nil }
+func (m *fakeManager) InstallFiles(_ context.Context, files []string) error { return nil }
+func (m *fakeManager) Remove(_ context.Context, pkgs []string) error        { return nil }
+func (m *fakeManager) Dependents(pkg string) []string                       { return nil }
+func (m *fakeManager) FileExtension() string                                { return ".deb" }
+
+func TestRequirementsDeduplicatesAndSorts(t *testing.T) {
+	requirements := Requirements([]string{"socat", "tar", " "})
+
+	names := PackageNames(requirements)
+	if want := []string{"curl", "iptables", "jq", "kmod", "socat", "tar"}; !slices.Equal(names, want) {
+		t.Errorf("Requirements() = %v, want %v", names, want)
+	}
+}
+
+func TestRequirementsOptional(t *testing.T) {
+	requirements := Requirements([]string{"chrony"}, Requirement{Package: "chrony", Command: "chronyd", Reason: "time synchronization"})
+
+	i := slices.IndexFunc(requirements, func(req Requirement) bool { return req.Package == "chrony" })
+	if i < 0 || requirements[i].Command != "chronyd" || requirements[i].Reason != "time synchronization" {
+		t.Errorf("Requirements() = %+v, want the optional chrony requirement once", requirements)
+	}
+	if names := PackageNames(requirements); !slices.IsSorted(names) {
+		t.Errorf("Requirements() = %v, want name order", names)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	restore := lookPath
+	defer func() { lookPath = restore }()
+	lookPath = func(command string) (string, error) {
+		if command == "tar" {
+			return "/usr/bin/tar", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	requirements := []Requirement{
+		{Package: "tar", Command: "tar"},
+		{Package: "jq", Command: "jq"},
+		{Package: "socat"},
+		{Package: "conntrack"},
+	}
+	manager := &fakeManager{installed: map[string]bool{"socat": true}}
+
+	if got, want := PackageNames(Missing(requirements, manager)), []string{"jq", "conntrack"}; !slices.Equal(got, want) {
+		t.Errorf("Missing() = %v, want %v", got, want)
+	}
+	// Without a package manager, packages without a command cannot be verified
+	if got, want := PackageNames(Missing(requirements, nil)), []string{"jq", "socat", "conntrack"}; !slices.Equal(got, want) {
+		t.Errorf("Missing() without manager = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package platform
+
+import (
+	"fmt"
+	"runtime"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Names of the supported platforms, matching runtime.GOOS
+const (
+	Linux   = "linux"
+	Windows = "windows"
+)
+
+// Platform is what the node components need to know about the host operating system: where kubelet and
+// containerd live, how services are managed and how host packages are installed
+type Platform struct {
+	Name     string
+	Paths    Paths
+	Services sysutil.ServiceManager
+	// Packages returns the host package manager, or an error on hosts without one
+	Packages func() (packages.Manager, error)
+}
+
+// Paths are the host paths of the node components
+type Paths struct {
+	BinDir             string // kubelet and kubectl
+	KubernetesDir      string // kubelet configuration and kubeconfigs
+	KubeletDir         string // kubelet root directory
+	ContainerdDir      string // containerd binaries
+	ContainerdConfig   string
+	ContainerdEndpoint string // CRI endpoint kubelet talks to containerd on
+}
+
+// IsWindows reports whether the platform is Windows
+func (p Platform) IsWindows() bool {
+	return p.Name == Windows
+}
+
+// ForLinux returns the Linux platform: systemd services, apt or rpm packages and the FHS paths
+func ForLinux() Platform {
+	return Platform{
+		Name: Linux,
+		Paths: Paths{
+			BinDir:             "/usr/local/bin",
+			KubernetesDir:      "/etc/kubernetes",
+			KubeletDir:         "/var/lib/kubelet",
+			ContainerdDir:      "/usr/bin",
+			ContainerdConfig:   "/etc/containerd/config.toml",
+			ContainerdEndpoint: "unix:///run/containerd/containerd.sock",
+		},
+		Services: sysutil.Systemd{},
+		Packages: packages.DetectManager,
+	}
+}
+
+// ForWindows returns the Windows platform: services of the service control manager and components installed
+// from release archives, as Windows has no package manager the agent can rely on
+func ForWindows() Platform {
+	return Platform{
+		Name: Windows,
+		Paths: Paths{
+			BinDir:             `C:\k`,
+			KubernetesDir:      `C:\k`,
+			KubeletDir:         `C:\var\lib\kubelet`,
+			ContainerdDir:      `C:\Program Files\containerd`,
+			ContainerdConfig:   `C:\Program Files\containerd\config.toml`,
+			ContainerdEndpoint: "npipe:////./pipe/containerd-containerd",
+		},
+		Services: sysutil.WindowsServices{},
+		Packages: func() (packages.Manager, error) {
+			return nil, fmt.Errorf("host packages cannot be installed on Windows, install them before bootstrapping")
+		},
+	}
+}
+
+// For returns the platform of the operating system goos
+func For(goos string) (Platform, error) {
+	switch goos {
+	case Linux:
+		return ForLinux(), nil
+	case Windows:
+		return ForWindows(), nil
+	}
+	return Platform{}, fmt.Errorf("unsupported operating system %s, the agent supports %s and %s", goos, Linux, Windows)
+}
+
+// Current returns the platform the agent runs on. Operating systems other than Windows are treated as Linux,
+// which they are in every supported deployment.
+func Current() Platform {
+	if runtime.GOOS == Windows {
+		return ForWindows()
+	}
+	return ForLinux()
+}
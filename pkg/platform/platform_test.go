@@ -0,0 +1,32 @@
+package platform
+
+import (
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+func TestFor(t *testing.T) {
+	linux, err := For("linux")
+	if err != nil || linux.IsWindows() || linux.Paths.KubeletDir != "/var/lib/kubelet" {
+		t.Errorf("For(linux) = %+v, %v", linux, err)
+	}
+	if _, ok := linux.Services.(sysutil.Systemd); !ok {
+		t.Errorf("Linux services = %T, want systemd", linux.Services)
+	}
+
+	windows, err := For("windows")
+	if err != nil || !windows.IsWindows() || windows.Paths.ContainerdEndpoint != "npipe:////./pipe/containerd-containerd" {
+		t.Errorf("For(windows) = %+v, %v", windows, err)
+	}
+	if _, ok := windows.Services.(sysutil.WindowsServices); !ok {
+		t.Errorf("Windows services = %T, want the service control manager", windows.Services)
+	}
+	if _, err := windows.Packages(); err == nil {
+		t.Error("Windows package manager should be unavailable")
+	}
+
+	if _, err := For("darwin"); err == nil {
+		t.Error("For(darwin) should fail")
+	}
+}
@@ -0,0 +1,258 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/clusterca"
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/hostsfile"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+)
+
+// ClusterChecker validates that the target cluster's SKU and features are compatible with the
+// configured node authentication method before any component is installed
+type ClusterChecker struct {
+	config     *config.Config
+	logger     *logrus.Logger
+	collector  *spec.Collector
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	verifyCA   func(ctx context.Context, cache *clusterca.Cache) *clusterca.Status
+	applyHosts func(entries []hostsfile.Entry) (bool, error)
+}
+
+// NewClusterChecker creates a new ClusterChecker
+func NewClusterChecker(logger *logrus.Logger) *ClusterChecker {
+	return &ClusterChecker{
+		config:     config.GetConfig(),
+		logger:     logger,
+		collector:  spec.NewCollector(logger),
+		lookupHost: net.DefaultResolver.LookupHost,
+		verifyCA:   clusterca.Verify,
+		applyHosts: hostsfile.Apply,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *ClusterChecker) GetName() string {
+	return "ClusterPreflight"
+}
+
+// Validate validates prerequisites for the cluster preflight check
+func (c *ClusterChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so the cluster is re-checked on every bootstrap
+func (c *ClusterChecker) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute fetches the managed cluster spec and blocks bootstrap on incompatible cluster features.
+// The check is skipped when the cluster spec cannot be retrieved.
+func (c *ClusterChecker) Execute(ctx context.Context) error {
+	clusterSpec, err := c.collector.Collect(ctx)
+	// The hosts entry must be in place before the private endpoint is resolved below
+	if hostsErr := c.applyAPIServerHostsEntry(clusterSpec); hostsErr != nil {
+		return hostsErr
+	}
+	if err != nil {
+		// Without the cluster spec there is no version to select for kubernetes.version "auto"
+		if c.config.IsKubernetesVersionAuto() {
+			return fmt.Errorf("failed to fetch managed cluster spec to select the kubernetes version: %w", err)
+		}
+		c.logger.Warnf("Skipping cluster preflight checks, failed to fetch managed cluster spec: %v", err)
+		return nil
+	}
+
+	if c.config.IsKubernetesVersionAuto() {
+		version, err := compat.SelectKubeletVersion(clusterSpec.KubernetesVersion)
+		if err != nil {
+			return fmt.Errorf("failed to select the kubernetes version: %w", err)
+		}
+		c.config.SetResolvedKubernetesVersion(version)
+		c.logger.Infof("Selected Kubernetes version %s to match cluster %s", version, clusterSpec.Name)
+	}
+
+	findings := checkClusterCompatibility(clusterSpec, c.config.IsARCEnabled())
+	if finding := c.checkPrivateEndpointResolution(ctx, clusterSpec); finding != nil {
+		findings = append(findings, *finding)
+	}
+	if finding := c.checkClusterCA(ctx); finding != nil {
+		findings = append(findings, *finding)
+	}
+
+	report := compat.Check(clusterSpec.KubernetesVersion, c.config.GetKubernetesVersion())
+	if err := compat.SaveReport(report); err != nil {
+		c.logger.Warnf("Failed to save compatibility report: %v", err)
+	}
+	findings = append(findings, versionSkewFindings(report)...)
+
+	for _, finding := range findings {
+		if finding.Severity == SeverityWarning {
+			c.logger.Warnf("Cluster preflight: %s", finding)
+		}
+	}
+	if err := errorFromFindings(findings); err != nil {
+		return err
+	}
+
+	c.logger.Infof("Cluster %s passed preflight checks", clusterSpec.Name)
+	return nil
+}
+
+// applyAPIServerHostsEntry maps the API server FQDN to the configured private IP in the hosts file.
+// A previously managed entry is removed when no IP is configured. clusterSpec is nil when it could not be fetched.
+func (c *ClusterChecker) applyAPIServerHostsEntry(clusterSpec *spec.ManagedClusterSpec) error {
+	var entries []hostsfile.Entry
+	if ip := c.config.Azure.TargetCluster.APIServerIP; ip != "" {
+		fqdn := c.config.Azure.TargetCluster.APIServerFQDN
+		if fqdn == "" && clusterSpec != nil {
+			fqdn = clusterSpec.PrivateFQDN
+		}
+		if fqdn == "" {
+			return fmt.Errorf("cannot map azure.targetCluster.apiServerIP %s to the API server: "+
+				"the cluster private FQDN is unknown, set azure.targetCluster.apiServerFQDN", ip)
+		}
+		entries = append(entries, hostsfile.Entry{IP: ip, Hostname: fqdn})
+	}
+
+	changed, err := c.applyHosts(entries)
+	if err != nil {
+		return fmt.Errorf("failed to update the API server entry in %s: %w", hostsfile.Path, err)
+	}
+	if changed {
+		if len(entries) == 0 {
+			c.logger.Infof("Removed the API server entry from %s", hostsfile.Path)
+		} else {
+			c.logger.Infof("Mapped API server %s to %s in %s", entries[0].Hostname, entries[0].IP, hostsfile.Path)
+		}
+	}
+	return nil
+}
+
+// checkPrivateEndpointResolution verifies this machine can resolve a private-only API server endpoint
+func (c *ClusterChecker) checkPrivateEndpointResolution(ctx context.Context, clusterSpec *spec.ManagedClusterSpec) *Finding {
+	if !clusterSpec.PrivateCluster || clusterSpec.PublicFQDNEnabled || clusterSpec.PrivateFQDN == "" {
+		return nil
+	}
+	if _, err := c.lookupHost(ctx, clusterSpec.PrivateFQDN); err != nil {
+		return &Finding{
+			Severity: SeverityError,
+			Check:    "PrivateCluster",
+			Message:  fmt.Sprintf("private API server endpoint %s cannot be resolved from this machine: %v", clusterSpec.PrivateFQDN, err),
+			Guidance: "Connect the machine to the cluster virtual network (VPN or ExpressRoute) and forward the privatelink DNS zone, " +
+				"or enable the public FQDN with 'az aks update --enable-public-fqdn'",
+		}
+	}
+	return nil
+}
+
+// checkClusterCA verifies the API server certificate chain against the cluster CA cached by the last bootstrap.
+// A mismatch is a warning: bootstrap fetches the current CA with the cluster credentials and caches it again.
+func (c *ClusterChecker) checkClusterCA(ctx context.Context) *Finding {
+	cache, err := clusterca.Load()
+	if err != nil {
+		c.logger.Warnf("Skipping cluster CA check: %v", err)
+		return nil
+	}
+	if cache == nil {
+		return nil
+	}
+	status := c.verifyCA(ctx, cache)
+	if status.Verified {
+		return nil
+	}
+	return &Finding{
+		Severity: SeverityWarning,
+		Check:    "ClusterCA",
+		Message:  status.Message,
+		Guidance: "If the cluster certificates were rotated with 'az aks rotate-certs', bootstrap caches the new CA. " +
+			"Otherwise check for a TLS-intercepting proxy between this machine and the API server",
+	}
+}
+
+// checkClusterCompatibility reports cluster features that conflict with how the agent authenticates the node
+func checkClusterCompatibility(clusterSpec *spec.ManagedClusterSpec, arcEnabled bool) []Finding {
+	var findings []Finding
+
+	if clusterSpec.SKUName != "" && !strings.EqualFold(clusterSpec.SKUName, "Base") {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "ClusterSKU",
+			Message:  fmt.Sprintf("cluster SKU %s has not been validated with flex nodes", clusterSpec.SKUName),
+			Guidance: "Node provisioning and policies of this SKU may reject or replace nodes that AKS does not manage",
+		})
+	}
+	if strings.EqualFold(clusterSpec.SKUTier, "Free") {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "ClusterTier",
+			Message:  "cluster uses the Free tier, which has no API server uptime SLA and reduced API server capacity",
+			Guidance: "Use the Standard tier ('az aks update --tier standard') for production flex node fleets",
+		})
+	}
+
+	if clusterSpec.LocalAccountsDisabled {
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Check:    "LocalAccounts",
+			Message:  "local accounts are disabled, so the agent cannot download the cluster admin credentials it uses to configure kubelet",
+			Guidance: "Re-enable local accounts with 'az aks update --enable-local-accounts'",
+		})
+	}
+
+	if !clusterSpec.AADManaged {
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Check:    "EntraIntegration",
+			Message:  "Microsoft Entra ID integration is not enabled, so the API server rejects the Entra tokens kubelet authenticates with",
+			Guidance: "Enable it with 'az aks update --enable-aad'",
+		})
+	} else if !clusterSpec.AzureRBACEnabled {
+		if arcEnabled {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Check:    "AzureRBAC",
+				Message:  "Azure RBAC for Kubernetes authorization is disabled, so the Azure roles granted to the Arc managed identity have no effect in the cluster",
+				Guidance: "Enable it with 'az aks update --enable-azure-rbac', or authenticate with a service principal bound through Kubernetes RBAC",
+			})
+		} else {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Check:    "AzureRBAC",
+				Message:  "Azure RBAC for Kubernetes authorization is disabled, so the service principal is authorized only through Kubernetes RBAC",
+				Guidance: "Make sure ClusterRoleBindings for the service principal object ID exist (see 'Configure RBAC Roles' in the usage guide)",
+			})
+		}
+	}
+
+	if len(clusterSpec.AuthorizedIPRanges) > 0 {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "AuthorizedIPRanges",
+			Message:  fmt.Sprintf("API server access is restricted to %s", strings.Join(clusterSpec.AuthorizedIPRanges, ", ")),
+			Guidance: "Make sure this machine's egress IP is within the authorized ranges",
+		})
+	}
+
+	return findings
+}
+
+// versionSkewFindings converts compatibility issues between the agent, kubelet and cluster versions into findings
+func versionSkewFindings(report *compat.Report) []Finding {
+	findings := make([]Finding, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		severity := SeverityWarning
+		if issue.Blocking {
+			severity = SeverityError
+		}
+		findings = append(findings, Finding{Severity: severity, Check: "VersionSkew", Message: issue.Message})
+	}
+	return findings
+}
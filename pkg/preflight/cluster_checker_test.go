@@ -0,0 +1,205 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/clusterca"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/hostsfile"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+)
+
+func compatibleSpec() *spec.ManagedClusterSpec {
+	return &spec.ManagedClusterSpec{
+		Name:             "test-cluster",
+		SKUName:          "Base",
+		SKUTier:          "Standard",
+		AADManaged:       true,
+		AzureRBACEnabled: true,
+	}
+}
+
+func TestCheckClusterCompatibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		modify       func(s *spec.ManagedClusterSpec)
+		arcEnabled   bool
+		wantChecks   map[string]Severity
+		wantBlocking bool
+	}{
+		{
+			name:       "compatible cluster",
+			arcEnabled: true,
+			wantChecks: map[string]Severity{},
+		},
+		{
+			name:         "local accounts disabled blocks",
+			modify:       func(s *spec.ManagedClusterSpec) { s.LocalAccountsDisabled = true },
+			arcEnabled:   true,
+			wantChecks:   map[string]Severity{"LocalAccounts": SeverityError},
+			wantBlocking: true,
+		},
+		{
+			name:         "cluster without Entra integration blocks",
+			modify:       func(s *spec.ManagedClusterSpec) { s.AADManaged = false; s.AzureRBACEnabled = false },
+			wantChecks:   map[string]Severity{"EntraIntegration": SeverityError},
+			wantBlocking: true,
+		},
+		{
+			name:         "Arc identity without Azure RBAC blocks",
+			modify:       func(s *spec.ManagedClusterSpec) { s.AzureRBACEnabled = false },
+			arcEnabled:   true,
+			wantChecks:   map[string]Severity{"AzureRBAC": SeverityError},
+			wantBlocking: true,
+		},
+		{
+			name:       "service principal without Azure RBAC warns",
+			modify:     func(s *spec.ManagedClusterSpec) { s.AzureRBACEnabled = false },
+			wantChecks: map[string]Severity{"AzureRBAC": SeverityWarning},
+		},
+		{
+			name: "free tier, unknown SKU and authorized IP ranges warn",
+			modify: func(s *spec.ManagedClusterSpec) {
+				s.SKUName = "Automatic"
+				s.SKUTier = "Free"
+				s.AuthorizedIPRanges = []string{"203.0.113.0/24"}
+			},
+			arcEnabled: true,
+			wantChecks: map[string]Severity{
+				"ClusterSKU":         SeverityWarning,
+				"ClusterTier":        SeverityWarning,
+				"AuthorizedIPRanges": SeverityWarning,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterSpec := compatibleSpec()
+			if tt.modify != nil {
+				tt.modify(clusterSpec)
+			}
+
+			findings := checkClusterCompatibility(clusterSpec, tt.arcEnabled)
+			got := make(map[string]Severity, len(findings))
+			for _, finding := range findings {
+				got[finding.Check] = finding.Severity
+			}
+			if len(got) != len(tt.wantChecks) {
+				t.Fatalf("checkClusterCompatibility() findings = %v, want %v", got, tt.wantChecks)
+			}
+			for check, severity := range tt.wantChecks {
+				if got[check] != severity {
+					t.Errorf("finding %s severity = %q, want %q", check, got[check], severity)
+				}
+			}
+			if err := errorFromFindings(findings); (err != nil) != tt.wantBlocking {
+				t.Errorf("errorFromFindings() error = %v, wantBlocking %v", err, tt.wantBlocking)
+			}
+		})
+	}
+}
+
+func TestCheckPrivateEndpointResolution(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	privateSpec := compatibleSpec()
+	privateSpec.PrivateCluster = true
+	privateSpec.PrivateFQDN = "test-cluster.privatelink.eastus.azmk8s.io"
+
+	checker := &ClusterChecker{
+		logger: logger,
+		lookupHost: func(_ context.Context, host string) ([]string, error) {
+			return nil, errors.New("no such host")
+		},
+	}
+	finding := checker.checkPrivateEndpointResolution(context.Background(), privateSpec)
+	if finding == nil || finding.Severity != SeverityError || !strings.Contains(finding.Message, privateSpec.PrivateFQDN) {
+		t.Fatalf("checkPrivateEndpointResolution() = %v, want blocking finding for %s", finding, privateSpec.PrivateFQDN)
+	}
+
+	privateSpec.PublicFQDNEnabled = true
+	if finding := checker.checkPrivateEndpointResolution(context.Background(), privateSpec); finding != nil {
+		t.Errorf("checkPrivateEndpointResolution() with public FQDN = %v, want nil", finding)
+	}
+}
+
+func TestApplyAPIServerHostsEntry(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var applied []hostsfile.Entry
+	cfg := &config.Config{Azure: config.AzureConfig{TargetCluster: &config.TargetClusterConfig{}}}
+	checker := &ClusterChecker{
+		config: cfg,
+		logger: logger,
+		applyHosts: func(entries []hostsfile.Entry) (bool, error) {
+			applied = entries
+			return true, nil
+		},
+	}
+	privateSpec := compatibleSpec()
+	privateSpec.PrivateFQDN = "test-cluster.privatelink.eastus.azmk8s.io"
+
+	// Without an IP a previously managed entry is removed
+	if err := checker.applyAPIServerHostsEntry(privateSpec); err != nil || len(applied) != 0 {
+		t.Fatalf("applyAPIServerHostsEntry() without IP = %v, applied %v, want the entry removed", err, applied)
+	}
+
+	cfg.Azure.TargetCluster.APIServerIP = "10.224.0.4"
+	if err := checker.applyAPIServerHostsEntry(privateSpec); err != nil ||
+		len(applied) != 1 || applied[0] != (hostsfile.Entry{IP: "10.224.0.4", Hostname: privateSpec.PrivateFQDN}) {
+		t.Fatalf("applyAPIServerHostsEntry() = %v, applied %v, want the private FQDN mapped", err, applied)
+	}
+
+	// Without the cluster spec the FQDN must be configured
+	if err := checker.applyAPIServerHostsEntry(nil); err == nil || !strings.Contains(err.Error(), "apiServerFQDN") {
+		t.Errorf("applyAPIServerHostsEntry() without spec = %v, want an error asking for apiServerFQDN", err)
+	}
+	cfg.Azure.TargetCluster.APIServerFQDN = "api.site.example.com"
+	if err := checker.applyAPIServerHostsEntry(nil); err != nil || applied[0].Hostname != "api.site.example.com" {
+		t.Errorf("applyAPIServerHostsEntry() with configured FQDN = %v, applied %v", err, applied)
+	}
+}
+
+func TestCheckClusterCA(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	clusterca.CacheFilePath = filepath.Join(t.TempDir(), "cluster-ca.json")
+
+	verified := false
+	checker := &ClusterChecker{
+		logger: logger,
+		verifyCA: func(_ context.Context, cache *clusterca.Cache) *clusterca.Status {
+			if verified {
+				return &clusterca.Status{Fingerprint: cache.Fingerprint, Verified: true}
+			}
+			return &clusterca.Status{Fingerprint: cache.Fingerprint, Message: "API server certificate does not verify"}
+		},
+	}
+	if finding := checker.checkClusterCA(context.Background()); finding != nil {
+		t.Fatalf("checkClusterCA() without a cached CA = %v, want nil", finding)
+	}
+
+	if err := os.WriteFile(clusterca.CacheFilePath, []byte(`{"server":"https://api:443","fingerprint":"AA:BB"}`), 0o644); err != nil {
+		t.Fatalf("failed to write cached CA: %v", err)
+	}
+	finding := checker.checkClusterCA(context.Background())
+	if finding == nil || finding.Severity != SeverityWarning || finding.Check != "ClusterCA" {
+		t.Fatalf("checkClusterCA() on mismatch = %v, want a ClusterCA warning", finding)
+	}
+
+	verified = true
+	if finding := checker.checkClusterCA(context.Background()); finding != nil {
+		t.Errorf("checkClusterCA() on a verified chain = %v, want nil", finding)
+	}
+}
@@ -0,0 +1,123 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/ebpf"
+)
+
+// EBPFChecker reports the kernel capabilities eBPF-based components such as Cilium, Hubble or eBPF monitoring
+// agents rely on. With a BYO Cilium that replaces kube-proxy, it also blocks bootstrap on kernels that cannot
+// run its datapath. Cilium loads CO-RE programs, which need the kernel BTF instead of a clang toolchain on the node.
+type EBPFChecker struct {
+	config *config.Config
+	logger *logrus.Logger
+	root   string // kernel files are read below root, "/" outside of tests
+}
+
+// NewEBPFChecker creates a new EBPFChecker
+func NewEBPFChecker(logger *logrus.Logger) *EBPFChecker {
+	return &EBPFChecker{
+		config: config.GetConfig(),
+		logger: logger,
+		root:   "/",
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *EBPFChecker) GetName() string {
+	return "EBPFPreflight"
+}
+
+// Validate validates prerequisites for the eBPF preflight check
+func (c *EBPFChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so the kernel is re-checked on every bootstrap
+func (c *EBPFChecker) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute warns about the eBPF capabilities the kernel lacks, and blocks bootstrap when
+// kube-proxy replacement is on and the kernel lacks what the Cilium datapath needs
+func (c *EBPFChecker) Execute(_ context.Context) error {
+	report := ebpf.Detect(c.root)
+	if !c.config.CNI.KubeProxyReplacement {
+		warnings := report.Warnings()
+		for _, warning := range warnings {
+			c.logger.Warnf("eBPF capability: %s", warning)
+		}
+		if len(warnings) == 0 {
+			c.logger.Info("Kernel supports eBPF-based components")
+		}
+		return nil
+	}
+
+	findings := c.check(report)
+	for _, finding := range findings {
+		if finding.Severity == SeverityWarning {
+			c.logger.Warnf("eBPF preflight: %s", finding)
+		}
+	}
+	if err := errorFromFindings(findings); err != nil {
+		return err
+	}
+	c.logger.Info("Kernel supports the eBPF kube-proxy replacement")
+	return nil
+}
+
+// check returns findings for kernel features the eBPF kube-proxy replacement is missing
+func (c *EBPFChecker) check(report *ebpf.Report) []Finding {
+	var findings []Finding
+
+	if major, minor, ok := ebpf.ParseKernelRelease(report.KernelRelease); !ok {
+		findings = append(findings, Finding{Severity: SeverityWarning, Check: "EBPFKernel",
+			Message: fmt.Sprintf("cannot parse kernel release %q", report.KernelRelease)})
+	} else if !report.KernelSupported {
+		findings = append(findings, Finding{Severity: SeverityError, Check: "EBPFKernel",
+			Message:  fmt.Sprintf("kernel %d.%d is older than %d.%d, the oldest kernel Cilium replaces kube-proxy on", major, minor, ebpf.MinKernelMajor, ebpf.MinKernelMinor),
+			Guidance: "Upgrade the kernel, or turn off cni.kubeProxyReplacement and run kube-proxy"})
+	}
+
+	if !report.BTF {
+		findings = append(findings, Finding{Severity: SeverityError, Check: "EBPFBTF",
+			Message:  fmt.Sprintf("kernel BTF %s is missing, so Cilium cannot load its CO-RE programs", ebpf.KernelBTFPath),
+			Guidance: "Use a kernel built with CONFIG_DEBUG_INFO_BTF=y, as the kernels of current Ubuntu and Azure Linux releases are"})
+	}
+
+	if report.KernelConfigError != "" {
+		findings = append(findings, Finding{Severity: SeverityWarning, Check: "EBPFKernelConfig",
+			Message: fmt.Sprintf("cannot verify the kernel configuration: %s", report.KernelConfigError)})
+	} else if missing := report.MissingDatapathOptions(); len(missing) > 0 {
+		findings = append(findings, Finding{Severity: SeverityError, Check: "EBPFKernelConfig",
+			Message:  fmt.Sprintf("kernel is built without %s", strings.Join(missing, ", ")),
+			Guidance: "Use a kernel with eBPF and BPF classifier support"})
+	}
+	if tracing := slices.DeleteFunc(slices.Clone(report.MissingKernelOptions), func(option string) bool {
+		return slices.Contains(ebpf.DatapathKernelOptions, option)
+	}); len(tracing) > 0 {
+		findings = append(findings, Finding{Severity: SeverityWarning, Check: "EBPFKernelConfig",
+			Message:  fmt.Sprintf("kernel is built without %s", strings.Join(tracing, ", ")),
+			Guidance: "Hubble process visibility and eBPF tracing tools will not work on this node"})
+	}
+
+	if !report.Cgroup2 {
+		findings = append(findings, Finding{Severity: SeverityWarning, Check: "EBPFCgroup",
+			Message:  fmt.Sprintf("cgroup v2 is not mounted at %s", ebpf.CgroupPath),
+			Guidance: "Cilium mounts its own cgroup v2 hierarchy for socket load balancing, which misses pods whose cgroups live only in the v1 hierarchy; boot with systemd.unified_cgroup_hierarchy=1"})
+	}
+
+	if !report.BPFFilesystem {
+		findings = append(findings, Finding{Severity: SeverityWarning, Check: "EBPFFilesystem",
+			Message:  fmt.Sprintf("the BPF filesystem is not mounted at %s", ebpf.BPFFSPath),
+			Guidance: "Cilium mounts it when it starts, but its programs and maps do not survive a Cilium restart until it is mounted on the host"})
+	}
+	return findings
+}
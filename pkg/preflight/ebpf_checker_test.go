@@ -0,0 +1,119 @@
+package preflight
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/ebpf"
+)
+
+const ebpfKernelConfig = "CONFIG_BPF=y\nCONFIG_BPF_SYSCALL=y\nCONFIG_BPF_JIT=y\nCONFIG_NET_CLS_BPF=m\nCONFIG_NET_SCH_INGRESS=m\nCONFIG_CGROUP_BPF=y\n"
+
+// writeKernelFiles lays out the kernel files the eBPF checker reads below a temporary root
+func writeKernelFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(full), err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", full, err)
+		}
+	}
+	return root
+}
+
+func TestEBPFChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "supported kernel",
+			files: map[string]string{
+				ebpf.KernelReleasePath:          "6.8.0-1015-azure\n",
+				ebpf.KernelBTFPath:              "",
+				"/boot/config-6.8.0-1015-azure": ebpfKernelConfig,
+				ebpf.ProcMountsPath:             "bpf /sys/fs/bpf bpf rw,nosuid,nodev,noexec,relatime 0 0\n",
+			},
+		},
+		{
+			name: "old kernel",
+			files: map[string]string{
+				ebpf.KernelReleasePath:            "4.15.0-213-generic\n",
+				ebpf.KernelBTFPath:                "",
+				"/boot/config-4.15.0-213-generic": ebpfKernelConfig,
+			},
+			wantErr: "kernel 4.15 is older than 5.4",
+		},
+		{
+			name: "no BTF",
+			files: map[string]string{
+				ebpf.KernelReleasePath:           "5.15.0-1064-azure\n",
+				"/boot/config-5.15.0-1064-azure": ebpfKernelConfig,
+			},
+			wantErr: "kernel BTF /sys/kernel/btf/vmlinux is missing",
+		},
+		{
+			name: "missing kernel options",
+			files: map[string]string{
+				ebpf.KernelReleasePath:           "5.15.0-1064-azure\n",
+				ebpf.KernelBTFPath:               "",
+				"/boot/config-5.15.0-1064-azure": "CONFIG_BPF=y\nCONFIG_BPF_SYSCALL=y\n# CONFIG_BPF_JIT is not set\nCONFIG_NET_CLS_BPF=m\nCONFIG_NET_SCH_INGRESS=m\nCONFIG_CGROUP_BPF=y\n",
+			},
+			wantErr: "kernel is built without CONFIG_BPF_JIT",
+		},
+		{
+			name: "unknown kernel configuration only warns",
+			files: map[string]string{
+				ebpf.KernelReleasePath: "6.8.0-1015-azure\n",
+				ebpf.KernelBTFPath:     "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.CNI.KubeProxyReplacement = true
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			checker := &EBPFChecker{config: cfg, logger: logger, root: writeKernelFiles(t, tt.files)}
+
+			err := checker.Execute(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEBPFCheckerOnlyWarnsWithKubeProxy(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	checker := &EBPFChecker{config: &config.Config{}, logger: logger, root: writeKernelFiles(t, map[string]string{
+		ebpf.KernelReleasePath: "4.15.0-213-generic\n",
+	})}
+	if checker.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = true, want the capabilities reported on every bootstrap")
+	}
+	if err := checker.Execute(context.Background()); err != nil {
+		t.Errorf("Execute() error = %v without kube-proxy replacement, want warnings only", err)
+	}
+}
@@ -0,0 +1,46 @@
+package preflight
+
+import (
+	"fmt"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+)
+
+// Severity indicates whether a preflight finding blocks bootstrap
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // bootstrap cannot succeed, it is blocked
+	SeverityWarning Severity = "warning" // bootstrap may succeed, the operator should double check
+)
+
+// Finding is a single preflight problem together with guidance on how to resolve it
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	Message  string   `json:"message"`
+	Guidance string   `json:"guidance,omitempty"`
+}
+
+func (f Finding) String() string {
+	if f.Guidance == "" {
+		return fmt.Sprintf("[%s] %s", f.Check, f.Message)
+	}
+	return fmt.Sprintf("[%s] %s. %s", f.Check, f.Message, f.Guidance)
+}
+
+// errorFromFindings returns an error listing all blocking findings, or nil if there are none
+func errorFromFindings(findings []Finding) error {
+	var blocking []string
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			blocking = append(blocking, "  - "+finding.String())
+		}
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+	// Blocking findings describe the host or cluster, which retrying does not change
+	return failure.Permanent(fmt.Errorf("preflight found %d blocking problem(s):\n%s", len(blocking), strings.Join(blocking, "\n")))
+}
@@ -0,0 +1,343 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Host check results
+const (
+	ResultPassed  = "passed"
+	ResultWarning = "warning"
+	ResultFailed  = "failed"
+)
+
+// Host checks, in the order they run
+const (
+	CheckKernelModules = "KernelModules"
+	CheckCgroupV2      = "CgroupV2"
+	CheckSwap          = "Swap"
+	CheckTimeSync      = "TimeSync"
+	CheckConnectivity  = "Connectivity"
+	CheckDiskSpace     = "DiskSpace"
+	CheckKubeletPort   = "KubeletPort"
+	CheckPrivileges    = "Privileges"
+)
+
+const (
+	// kubeletPort is the port the kubelet API listens on
+	kubeletPort = 10250
+
+	// minFreeDiskBytes is the free space below /var/lib the node needs, the minimum of the VM requirements
+	minFreeDiskBytes = 25 << 30
+
+	// reachTimeout bounds each connectivity probe
+	reachTimeout = 10 * time.Second
+)
+
+// requiredKernelModules are the modules containerd and pod networking need
+var requiredKernelModules = []string{"br_netfilter", "overlay"}
+
+// privilegedCommands are commands bootstrap runs through sudo, each granted by the sudoers rules of the service user
+var privilegedCommands = [][]string{
+	{"systemctl", "daemon-reload"},
+	{"mkdir", "-p", "/etc/kubernetes"},
+	{"modprobe", "br_netfilter"},
+	{"sysctl", "--system"},
+}
+
+// HostCheck is the outcome of one host preflight check
+type HostCheck struct {
+	Name     string `json:"name"`
+	Result   string `json:"result"`   // passed, warning or failed
+	Critical bool   `json:"critical"` // a failed critical check blocks bootstrap
+	Message  string `json:"message"`
+}
+
+// HostReport is the outcome of the host preflight checks
+type HostReport struct {
+	Passed bool        `json:"passed"` // no critical check failed
+	Checks []HostCheck `json:"checks"`
+}
+
+// HostChecker checks the machine can run a node before anything is installed: kernel modules, cgroups, swap,
+// clock, connectivity, disk space, the kubelet port and privileges
+type HostChecker struct {
+	config *config.Config
+	logger *logrus.Logger
+	root   string // /proc and /sys are read below root, "/" outside of tests
+
+	// Host access, replaced in tests
+	run           func(name string, args ...string) (string, error)
+	reach         func(ctx context.Context, endpoint string) error
+	listen        func(network, address string) (net.Listener, error)
+	geteuid       func() int
+	kubeletActive func() bool
+}
+
+// NewHostChecker creates a new HostChecker
+func NewHostChecker(logger *logrus.Logger) *HostChecker {
+	return &HostChecker{
+		config:        config.GetConfig(),
+		logger:        logger,
+		root:          "/",
+		run:           sysutil.RunCommandWithOutput,
+		reach:         reachEndpoint,
+		listen:        net.Listen,
+		geteuid:       os.Geteuid,
+		kubeletActive: func() bool { return sysutil.IsServiceActive("kubelet") },
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *HostChecker) GetName() string {
+	return "HostPreflight"
+}
+
+// Validate validates prerequisites for the host preflight checks
+func (c *HostChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so the host is re-checked on every bootstrap
+func (c *HostChecker) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Plan describes the host checks for dry runs
+func (c *HostChecker) Plan(_ context.Context) []string {
+	return []string{"check kernel modules, cgroup v2, swap, time sync, connectivity, disk space, port 10250 and privileges"}
+}
+
+// Execute blocks bootstrap when a critical host check fails, unless agent.skipPreflight is set
+func (c *HostChecker) Execute(ctx context.Context) error {
+	report := c.Run(ctx)
+	var findings []Finding
+	for _, check := range report.Checks {
+		switch {
+		case check.Result == ResultPassed:
+			c.logger.Debugf("Host preflight %s passed: %s", check.Name, check.Message)
+		case check.Result == ResultFailed && check.Critical && !c.config.Agent.SkipPreflight:
+			findings = append(findings, Finding{Severity: SeverityError, Check: check.Name, Message: check.Message,
+				Guidance: "Fix the host or bootstrap with --skip-preflight"})
+		default:
+			c.logger.Warnf("Host preflight: [%s] %s", check.Name, check.Message)
+		}
+	}
+	if err := errorFromFindings(findings); err != nil {
+		return err
+	}
+	if !report.Passed {
+		c.logger.Warn("Critical host preflight checks failed, continuing as agent.skipPreflight is set")
+		return nil
+	}
+	c.logger.Info("Host passed preflight checks")
+	return nil
+}
+
+// Run runs all host checks
+func (c *HostChecker) Run(ctx context.Context) *HostReport {
+	checks := []struct {
+		name     string
+		critical bool
+		check    func() (result, message string)
+	}{
+		{CheckKernelModules, true, c.checkKernelModules},
+		{CheckCgroupV2, false, c.checkCgroupV2},
+		{CheckSwap, true, c.checkSwap},
+		{CheckTimeSync, false, c.checkTimeSync},
+		{CheckConnectivity, true, func() (string, string) { return c.checkConnectivity(ctx) }},
+		{CheckDiskSpace, true, c.checkDiskSpace},
+		{CheckKubeletPort, true, c.checkKubeletPort},
+		{CheckPrivileges, true, c.checkPrivileges},
+	}
+
+	report := &HostReport{Passed: true}
+	for _, check := range checks {
+		result, message := check.check()
+		report.Checks = append(report.Checks, HostCheck{Name: check.name, Result: result, Critical: check.critical, Message: message})
+		if check.critical && result == ResultFailed {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// checkKernelModules checks the required modules are loaded, built in or can be loaded
+func (c *HostChecker) checkKernelModules() (string, string) {
+	var missing []string
+	for _, module := range requiredKernelModules {
+		if fsutil.DirectoryExists(filepath.Join(c.root, "sys/module", module)) {
+			continue
+		}
+		if _, err := c.run("modprobe", "--dry-run", module); err != nil {
+			missing = append(missing, module)
+		}
+	}
+	if len(missing) > 0 {
+		return ResultFailed, fmt.Sprintf("kernel module(s) %s are neither loaded nor available, install the kernel modules package of the running kernel",
+			strings.Join(missing, ", "))
+	}
+	return ResultPassed, fmt.Sprintf("kernel modules %s are available", strings.Join(requiredKernelModules, ", "))
+}
+
+// checkCgroupV2 checks the unified cgroup hierarchy is mounted
+func (c *HostChecker) checkCgroupV2() (string, string) {
+	if fsutil.FileExists(filepath.Join(c.root, "sys/fs/cgroup/cgroup.controllers")) {
+		return ResultPassed, "cgroup v2 is mounted"
+	}
+	return ResultWarning, "the host uses cgroup v1, which kubelet only keeps in maintenance; swapBehavior LimitedSwap needs cgroup v2"
+}
+
+// checkSwap checks no swap device is active unless kubelet is configured to run with swap
+func (c *HostChecker) checkSwap() (string, string) {
+	swaps, err := os.ReadFile(filepath.Join(c.root, "proc/swaps"))
+	if err != nil {
+		return ResultWarning, fmt.Sprintf("cannot read active swap devices: %v", err)
+	}
+	// The first line of /proc/swaps is a header
+	devices := len(strings.Split(strings.TrimSpace(string(swaps)), "\n")) - 1
+	switch {
+	case devices == 0:
+		return ResultPassed, "swap is off"
+	case c.config.Node.Kubelet.SwapBehavior != "":
+		return ResultPassed, fmt.Sprintf("swap is on and kubelet runs with swapBehavior %s", c.config.Node.Kubelet.SwapBehavior)
+	case c.config.System.Swap == config.SwapPolicyDisable:
+		return ResultPassed, fmt.Sprintf("%d swap device(s) are active and bootstrap turns them off with system.swap disable", devices)
+	}
+	return ResultFailed, fmt.Sprintf("%d swap device(s) are active and kubelet refuses to start with swap, "+
+		"turn swap off with swapoff -a and remove it from /etc/fstab, set system.swap to disable, or set node.kubelet.swapBehavior", devices)
+}
+
+// checkTimeSync checks the clock is synchronized, as tokens and certificates are rejected with a skewed clock
+func (c *HostChecker) checkTimeSync() (string, string) {
+	output, err := c.run("timedatectl", "show", "--property=NTPSynchronized", "--value")
+	if err != nil {
+		return ResultWarning, fmt.Sprintf("cannot determine whether the clock is synchronized: %v", err)
+	}
+	if strings.TrimSpace(output) != "yes" {
+		return ResultWarning, "the clock is not synchronized with NTP, a skewed clock makes Entra ID tokens and certificates fail validation"
+	}
+	return ResultPassed, "the clock is synchronized with NTP"
+}
+
+// checkConnectivity checks the Azure endpoints and the download hosts can be reached over HTTPS
+func (c *HostChecker) checkConnectivity(ctx context.Context) (string, string) {
+	endpoints := c.endpoints()
+	var failed []string
+	for _, endpoint := range endpoints {
+		if err := c.reach(ctx, endpoint); err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", endpoint, err))
+		}
+	}
+	if len(failed) > 0 {
+		return ResultFailed, "cannot reach " + strings.Join(failed, ", ")
+	}
+	return ResultPassed, "reached " + strings.Join(endpoints, ", ")
+}
+
+// endpoints returns the endpoints bootstrap talks to: Entra ID, Azure Resource Manager, the configured API
+// server and the hosts of the containerd and Kubernetes downloads
+func (c *HostChecker) endpoints() []string {
+	cloud := auth.Cloud(c.config)
+	endpoints := []string{cloud.AuthorityHost(), cloud.ResourceManagerEndpoint() + "/"}
+	if target := c.config.Azure.TargetCluster; target != nil && target.APIServerFQDN != "" {
+		endpoints = append(endpoints, "https://"+target.APIServerFQDN+"/")
+	}
+	for _, template := range []string{defaults.Get().URLs.Containerd, defaults.Get().URLs.Kubernetes} {
+		if parsed, err := url.Parse(template); err == nil && parsed.Host != "" {
+			if endpoint := parsed.Scheme + "://" + parsed.Host + "/"; !slices.Contains(endpoints, endpoint) {
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	return endpoints
+}
+
+// reachEndpoint sends a HEAD request to endpoint. Any HTTP response proves the endpoint is reachable.
+func reachEndpoint(ctx context.Context, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, reachTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// checkDiskSpace checks the filesystem holding /var/lib, where containerd and kubelet keep images and pods,
+// has the minimum free space
+func (c *HostChecker) checkDiskSpace() (string, string) {
+	dir := filepath.Join(c.root, "var/lib")
+	output, err := c.run("df", "--output=avail", "-B1", dir)
+	if err != nil {
+		return ResultWarning, fmt.Sprintf("cannot determine the free space below %s: %v", dir, err)
+	}
+	// df prints a header line before the value
+	fields := strings.Fields(output)
+	if len(fields) < 2 {
+		return ResultWarning, fmt.Sprintf("cannot parse the free space below %s from df output %q", dir, output)
+	}
+	free, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return ResultWarning, fmt.Sprintf("cannot parse the free space below %s from df output %q", dir, output)
+	}
+	if free < minFreeDiskBytes {
+		return ResultFailed, fmt.Sprintf("%.1f GiB are free below /var/lib, the node needs at least %d GiB", float64(free)/(1<<30), minFreeDiskBytes>>30)
+	}
+	return ResultPassed, fmt.Sprintf("%.1f GiB are free below /var/lib", float64(free)/(1<<30))
+}
+
+// checkKubeletPort checks the kubelet API port is free or held by the kubelet of an earlier bootstrap
+func (c *HostChecker) checkKubeletPort() (string, string) {
+	listener, err := c.listen("tcp", fmt.Sprintf(":%d", kubeletPort))
+	if err == nil {
+		_ = listener.Close()
+		return ResultPassed, fmt.Sprintf("port %d is free", kubeletPort)
+	}
+	if c.kubeletActive() {
+		return ResultPassed, fmt.Sprintf("port %d is held by the running kubelet", kubeletPort)
+	}
+	return ResultFailed, fmt.Sprintf("port %d is in use by another process, kubelet cannot serve its API: %v", kubeletPort, err)
+}
+
+// checkPrivileges checks the agent runs as root or that sudo runs the commands bootstrap needs without a password.
+// The service user may only run the commands its sudoers rules list, so each is checked with sudo -l rather than
+// running an arbitrary command.
+func (c *HostChecker) checkPrivileges() (string, string) {
+	if c.geteuid() == 0 {
+		return ResultPassed, "running as root"
+	}
+	var denied []string
+	for _, command := range privilegedCommands {
+		if _, err := c.run("sudo", append([]string{"-n", "-l"}, command...)...); err != nil {
+			denied = append(denied, strings.Join(command, " "))
+		}
+	}
+	if len(denied) > 0 {
+		return ResultFailed, fmt.Sprintf("the agent does not run as root and sudo does not allow %s without a password, install the sudoers rules with install-service",
+			strings.Join(denied, ", "))
+	}
+	return ResultPassed, "sudo runs the commands bootstrap needs without a password"
+}
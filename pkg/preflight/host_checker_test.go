@@ -0,0 +1,223 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// fakeListener is the listener a free kubelet port is bound with
+type fakeListener struct{ net.Listener }
+
+func (fakeListener) Close() error { return nil }
+
+// healthyHost returns a HostChecker on a host passing every check, which tests then break one way at a time
+func healthyHost(t *testing.T, files map[string]string) *HostChecker {
+	t.Helper()
+	kernelFiles := map[string]string{
+		"sys/module/br_netfilter/refcnt":   "0",
+		"sys/module/overlay/refcnt":        "0",
+		"sys/fs/cgroup/cgroup.controllers": "cpu memory",
+		"proc/swaps":                       "Filename\tType\tSize\tUsed\tPriority\n",
+	}
+	for path, content := range files {
+		kernelFiles[path] = content
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &HostChecker{
+		config: &config.Config{},
+		logger: logger,
+		root:   writeKernelFiles(t, kernelFiles),
+		run: func(name string, args ...string) (string, error) {
+			switch name {
+			case "timedatectl":
+				return "yes\n", nil
+			case "df":
+				return "Avail\n64424509440\n", nil
+			}
+			return "", errors.New("unexpected command " + name)
+		},
+		reach:         func(context.Context, string) error { return nil },
+		listen:        func(string, string) (net.Listener, error) { return fakeListener{}, nil },
+		geteuid:       func() int { return 0 },
+		kubeletActive: func() bool { return false },
+	}
+}
+
+func TestHostCheckerPasses(t *testing.T) {
+	checker := healthyHost(t, nil)
+	report := checker.Run(context.Background())
+	if !report.Passed || len(report.Checks) != 8 {
+		t.Fatalf("Run() = %+v, want 8 passed checks", report)
+	}
+	for _, check := range report.Checks {
+		if check.Result != ResultPassed {
+			t.Errorf("%s = %s: %s, want passed", check.Name, check.Result, check.Message)
+		}
+	}
+	if err := checker.Execute(context.Background()); err != nil {
+		t.Errorf("Execute() unexpected error: %v", err)
+	}
+}
+
+func TestHostCheckerFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		modify      func(c *HostChecker)
+		check       string
+		wantResult  string
+		wantMessage string
+	}{
+		{
+			name:  "swap on",
+			files: map[string]string{"proc/swaps": "Filename\tType\n/swap.img\tfile\n"},
+			check: CheckSwap, wantResult: ResultFailed, wantMessage: "1 swap device(s) are active",
+		},
+		{
+			name:   "swap on with swapBehavior",
+			files:  map[string]string{"proc/swaps": "Filename\tType\n/swap.img\tfile\n"},
+			modify: func(c *HostChecker) { c.config.Node.Kubelet.SwapBehavior = "LimitedSwap" },
+			check:  CheckSwap, wantResult: ResultPassed,
+		},
+		{
+			name:   "swap on with system.swap disable",
+			files:  map[string]string{"proc/swaps": "Filename\tType\n/swap.img\tfile\n"},
+			modify: func(c *HostChecker) { c.config.System.Swap = config.SwapPolicyDisable },
+			check:  CheckSwap, wantResult: ResultPassed, wantMessage: "bootstrap turns them off",
+		},
+		{
+			name: "disk nearly full",
+			modify: func(c *HostChecker) {
+				c.run = func(name string, args ...string) (string, error) { return "Avail\n10737418240\n", nil }
+			},
+			check: CheckDiskSpace, wantResult: ResultFailed, wantMessage: "10.0 GiB are free",
+		},
+		{
+			name: "unreachable endpoint",
+			modify: func(c *HostChecker) {
+				c.reach = func(_ context.Context, endpoint string) error {
+					if strings.Contains(endpoint, "management") {
+						return errors.New("connection refused")
+					}
+					return nil
+				}
+			},
+			check: CheckConnectivity, wantResult: ResultFailed, wantMessage: "https://management.azure.com/ (connection refused)",
+		},
+		{
+			name: "port held by another process",
+			modify: func(c *HostChecker) {
+				c.listen = func(string, string) (net.Listener, error) { return nil, errors.New("address already in use") }
+			},
+			check: CheckKubeletPort, wantResult: ResultFailed, wantMessage: "in use by another process",
+		},
+		{
+			name: "port held by kubelet",
+			modify: func(c *HostChecker) {
+				c.listen = func(string, string) (net.Listener, error) { return nil, errors.New("address already in use") }
+				c.kubeletActive = func() bool { return true }
+			},
+			check: CheckKubeletPort, wantResult: ResultPassed,
+		},
+		{
+			name: "no root and no sudo",
+			modify: func(c *HostChecker) {
+				c.geteuid = func() int { return 1000 }
+			},
+			check: CheckPrivileges, wantResult: ResultFailed,
+		},
+		{
+			name: "service user with sudoers rules",
+			modify: func(c *HostChecker) {
+				c.geteuid = func() int { return 1000 }
+				run := c.run
+				c.run = func(name string, args ...string) (string, error) {
+					if name == "sudo" {
+						return "/usr/bin/" + strings.Join(args[2:], " ") + "\n", nil
+					}
+					return run(name, args...)
+				}
+			},
+			check: CheckPrivileges, wantResult: ResultPassed,
+		},
+		{
+			name: "sudoers rules missing a command",
+			modify: func(c *HostChecker) {
+				c.geteuid = func() int { return 1000 }
+				run := c.run
+				c.run = func(name string, args ...string) (string, error) {
+					if name == "sudo" && args[2] == "modprobe" {
+						return "", errors.New("exit status 1")
+					}
+					if name == "sudo" {
+						return "", nil
+					}
+					return run(name, args...)
+				}
+			},
+			check: CheckPrivileges, wantResult: ResultFailed, wantMessage: "sudo does not allow modprobe br_netfilter without a password",
+		},
+		{
+			name: "clock not synchronized only warns",
+			modify: func(c *HostChecker) {
+				run := c.run
+				c.run = func(name string, args ...string) (string, error) {
+					if name == "timedatectl" {
+						return "no\n", nil
+					}
+					return run(name, args...)
+				}
+			},
+			check: CheckTimeSync, wantResult: ResultWarning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := healthyHost(t, tt.files)
+			if tt.modify != nil {
+				tt.modify(checker)
+			}
+			report := checker.Run(context.Background())
+			for _, check := range report.Checks {
+				if check.Name != tt.check {
+					continue
+				}
+				if check.Result != tt.wantResult || !strings.Contains(check.Message, tt.wantMessage) {
+					t.Errorf("%s = %s: %q, want %s: %q", check.Name, check.Result, check.Message, tt.wantResult, tt.wantMessage)
+				}
+			}
+			if wantPassed := tt.wantResult != ResultFailed; report.Passed != wantPassed {
+				t.Errorf("Passed = %v, want %v", report.Passed, wantPassed)
+			}
+		})
+	}
+}
+
+func TestHostCheckerMissingKernelModule(t *testing.T) {
+	checker := healthyHost(t, nil)
+	checker.root = writeKernelFiles(t, map[string]string{
+		"sys/module/overlay/refcnt": "0",
+		"proc/swaps":                "Filename\n",
+	})
+
+	err := checker.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "br_netfilter are neither loaded nor available") {
+		t.Fatalf("Execute() error = %v, want the missing br_netfilter module", err)
+	}
+
+	// agent.skipPreflight turns blocking failures into warnings
+	checker.config.Agent.SkipPreflight = true
+	if err := checker.Execute(context.Background()); err != nil {
+		t.Errorf("Execute() with skipPreflight unexpected error: %v", err)
+	}
+}
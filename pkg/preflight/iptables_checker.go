@@ -0,0 +1,221 @@
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// iptablesState describes the rules programmed through each iptables backend
+type iptablesState struct {
+	active      string         // backend the iptables command uses
+	rules       map[string]int // rules per backend
+	kubeChains  map[string]int // Kubernetes chains (KUBE-*) per backend
+	unavailable map[string]bool
+}
+
+// IPTablesChecker makes kubelet, kube-proxy and the CNI program their rules through one iptables backend.
+// Kube-proxy picks the backend that holds kubelet's chains, so rules split across backends break pod networking.
+type IPTablesChecker struct {
+	config   *config.Config
+	logger   *logrus.Logger
+	run      func(name string, args ...string) (string, error)
+	lookPath func(file string) (string, error)
+}
+
+// NewIPTablesChecker creates a new IPTablesChecker
+func NewIPTablesChecker(logger *logrus.Logger) *IPTablesChecker {
+	return &IPTablesChecker{
+		config:   config.GetConfig(),
+		logger:   logger,
+		run:      utils.RunCommandWithOutput,
+		lookPath: exec.LookPath,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *IPTablesChecker) GetName() string {
+	return "IPTablesPreflight"
+}
+
+// Validate validates prerequisites for the iptables preflight check
+func (c *IPTablesChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so the iptables backend is re-checked on every bootstrap
+func (c *IPTablesChecker) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute detects the iptables backend, blocks bootstrap on Kubernetes rules split across backends
+// and points the iptables alternatives at the backend kubelet should use
+func (c *IPTablesChecker) Execute(_ context.Context) error {
+	if _, err := c.lookPath("iptables"); err != nil {
+		c.logger.Warnf("Skipping iptables preflight checks, iptables is not installed: %v", err)
+		return nil
+	}
+
+	state := c.detect()
+	backend, reason := c.selectBackend(state)
+	findings := checkIPTablesState(state, backend)
+	for _, finding := range findings {
+		if finding.Severity == SeverityWarning {
+			c.logger.Warnf("iptables preflight: %s", finding)
+		}
+	}
+	if err := errorFromFindings(findings); err != nil {
+		return err
+	}
+
+	if backend == state.active {
+		c.logger.Infof("iptables uses the %s backend (%s)", backend, reason)
+		return nil
+	}
+	c.logger.Infof("Switching iptables from the %s to the %s backend (%s)", state.active, backend, reason)
+	if err := c.setAlternatives(backend); err != nil {
+		return fmt.Errorf("failed to switch iptables to the %s backend: %w", backend, err)
+	}
+	return nil
+}
+
+// detect reads the active backend and counts the rules and Kubernetes chains of each backend
+func (c *IPTablesChecker) detect() *iptablesState {
+	state := &iptablesState{
+		active:      config.IPTablesBackendLegacy,
+		rules:       map[string]int{},
+		kubeChains:  map[string]int{},
+		unavailable: map[string]bool{},
+	}
+
+	// iptables 1.8 and newer report the backend in the version, older releases only have the legacy one
+	if version, err := c.run("iptables", "--version"); err == nil && strings.Contains(version, "nf_tables") {
+		state.active = config.IPTablesBackendNFT
+	}
+
+	for _, backend := range []string{config.IPTablesBackendNFT, config.IPTablesBackendLegacy} {
+		saveCommand := "iptables-" + backend + "-save"
+		if _, err := c.lookPath(saveCommand); err != nil {
+			state.unavailable[backend] = true
+			continue
+		}
+		output, err := c.run(saveCommand)
+		if err != nil {
+			c.logger.Debugf("Failed to read %s rules: %v", backend, err)
+			continue
+		}
+		state.rules[backend], state.kubeChains[backend] = countRules(output)
+	}
+	return state
+}
+
+// selectBackend picks the backend kubelet should use: the configured one, the one already holding
+// Kubernetes chains, the one holding more rules or the distribution default, in that order
+func (c *IPTablesChecker) selectBackend(state *iptablesState) (string, string) {
+	if c.config.Node.IPTablesBackend != "" {
+		return c.config.Node.IPTablesBackend, "configured in node.iptablesBackend"
+	}
+
+	other := otherBackend(state.active)
+	switch {
+	case state.kubeChains[state.active] > 0:
+		return state.active, "Kubernetes chains exist"
+	case state.kubeChains[other] > 0:
+		return other, "Kubernetes chains exist"
+	case state.rules[other] > state.rules[state.active]:
+		return other, fmt.Sprintf("holds %d existing rules", state.rules[other])
+	}
+	return state.active, "distribution default"
+}
+
+// setAlternatives points iptables and ip6tables at the given backend
+func (c *IPTablesChecker) setAlternatives(backend string) error {
+	alternatives := ""
+	for _, command := range []string{"update-alternatives", "alternatives"} {
+		if _, err := c.lookPath(command); err == nil {
+			alternatives = command
+			break
+		}
+	}
+	if alternatives == "" {
+		return fmt.Errorf("no alternatives system found to select iptables-%s", backend)
+	}
+
+	for _, name := range []string{"iptables", "ip6tables"} {
+		path, err := c.lookPath(name + "-" + backend)
+		if err != nil {
+			if name == "iptables" {
+				return fmt.Errorf("%s-%s is not installed: %w", name, backend, err)
+			}
+			c.logger.Debugf("Skipping %s, %s-%s is not installed", name, name, backend)
+			continue
+		}
+		if _, err := c.run(alternatives, "--set", name, path); err != nil {
+			return fmt.Errorf("failed to select %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// checkIPTablesState returns findings for rule states that break pod networking with the selected backend
+func checkIPTablesState(state *iptablesState, backend string) []Finding {
+	var findings []Finding
+	if state.unavailable[backend] && backend != state.active {
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Check:    "IPTablesBackend",
+			Message:  fmt.Sprintf("the %s iptables backend is selected but iptables-%s is not installed", backend, backend),
+			Guidance: "Install it, or set node.iptablesBackend to the backend the distribution ships",
+		})
+	}
+
+	// Kube-proxy follows the backend holding kubelet's chains, so stale chains in the other backend mislead it
+	other := otherBackend(backend)
+	if state.kubeChains[other] > 0 {
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Check:    "IPTablesMixedRules",
+			Message:  fmt.Sprintf("%d Kubernetes chain(s) exist in the %s iptables backend, but kubelet uses %s", state.kubeChains[other], other, backend),
+			Guidance: fmt.Sprintf("Run unbootstrap, then flush them with 'iptables-%s -F' and 'iptables-%s -X' for each table, or reboot, before bootstrapping again",
+				other, other),
+		})
+	} else if state.rules[other] > 0 {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "IPTablesMixedRules",
+			Message:  fmt.Sprintf("%d rule(s) exist in the %s iptables backend, which kubelet does not use", state.rules[other], other),
+			Guidance: "Host firewall rules there still apply to pod traffic but are not visible with the iptables command",
+		})
+	}
+	return findings
+}
+
+// countRules counts the rules and Kubernetes chains of iptables-save output
+func countRules(output string) (int, int) {
+	var rules, kubeChains int
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "-A "):
+			rules++
+		case strings.HasPrefix(line, ":KUBE-"):
+			kubeChains++
+		}
+	}
+	return rules, kubeChains
+}
+
+func otherBackend(backend string) string {
+	if backend == config.IPTablesBackendNFT {
+		return config.IPTablesBackendLegacy
+	}
+	return config.IPTablesBackendNFT
+}
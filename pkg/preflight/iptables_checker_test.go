@@ -0,0 +1,160 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	hostRules = "*filter\n:INPUT ACCEPT [0:0]\n-A INPUT -p tcp --dport 22 -j ACCEPT\nCOMMIT\n"
+	kubeRules = "*filter\n:INPUT ACCEPT [0:0]\n:KUBE-FIREWALL - [0:0]\n:KUBE-KUBELET-CANARY - [0:0]\n-A INPUT -j KUBE-FIREWALL\nCOMMIT\n"
+)
+
+// fakeIPTables serves iptables commands from canned output and records the alternatives it is asked to set
+type fakeIPTables struct {
+	version   string
+	saves     map[string]string // iptables-save output per backend, missing backends are not installed
+	installed []string          // other installed commands
+	selected  []string
+}
+
+func (f *fakeIPTables) run(name string, args ...string) (string, error) {
+	switch name {
+	case "iptables":
+		return f.version, nil
+	case "update-alternatives":
+		f.selected = append(f.selected, args[len(args)-1])
+		return "", nil
+	}
+	for backend, output := range f.saves {
+		if name == "iptables-"+backend+"-save" {
+			return output, nil
+		}
+	}
+	return "", errors.New("unexpected command " + name)
+}
+
+func (f *fakeIPTables) lookPath(file string) (string, error) {
+	if file == "iptables" || slices.Contains(f.installed, file) {
+		return "/usr/sbin/" + file, nil
+	}
+	for backend := range f.saves {
+		if file == "iptables-"+backend+"-save" || file == "iptables-"+backend || file == "ip6tables-"+backend {
+			return "/usr/sbin/" + file, nil
+		}
+	}
+	return "", errors.New("not found")
+}
+
+func newTestIPTablesChecker(cfg *config.Config, fake *fakeIPTables) *IPTablesChecker {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &IPTablesChecker{config: cfg, logger: logger, run: fake.run, lookPath: fake.lookPath}
+}
+
+func TestIPTablesChecker(t *testing.T) {
+	const nftVersion = "iptables v1.8.7 (nf_tables)"
+
+	tests := []struct {
+		name         string
+		backend      string
+		fake         *fakeIPTables
+		wantSelected []string
+		wantErr      string
+	}{
+		{
+			name: "distribution default kept on a clean host",
+			fake: &fakeIPTables{
+				version: nftVersion,
+				saves:   map[string]string{"nft": "", "legacy": ""},
+			},
+		},
+		{
+			name: "switches to the backend holding the host firewall",
+			fake: &fakeIPTables{
+				version:   nftVersion,
+				saves:     map[string]string{"nft": "", "legacy": hostRules},
+				installed: []string{"update-alternatives"},
+			},
+			wantSelected: []string{"/usr/sbin/iptables-legacy", "/usr/sbin/ip6tables-legacy"},
+		},
+		{
+			name: "keeps the backend holding kubelet chains",
+			fake: &fakeIPTables{
+				version: nftVersion,
+				saves:   map[string]string{"nft": kubeRules, "legacy": hostRules},
+			},
+		},
+		{
+			name: "Kubernetes chains in both backends fail",
+			fake: &fakeIPTables{
+				version: nftVersion,
+				saves:   map[string]string{"nft": kubeRules, "legacy": kubeRules},
+			},
+			wantErr: "2 Kubernetes chain(s) exist in the legacy iptables backend",
+		},
+		{
+			name:    "configured backend with stale chains in the other fails",
+			backend: config.IPTablesBackendLegacy,
+			fake: &fakeIPTables{
+				version:   nftVersion,
+				saves:     map[string]string{"nft": kubeRules, "legacy": ""},
+				installed: []string{"update-alternatives"},
+			},
+			wantErr: "exist in the nft iptables backend, but kubelet uses legacy",
+		},
+		{
+			name:    "configured backend that is not installed fails",
+			backend: config.IPTablesBackendLegacy,
+			fake: &fakeIPTables{
+				version: nftVersion,
+				saves:   map[string]string{"nft": ""},
+			},
+			wantErr: "iptables-legacy is not installed",
+		},
+		{
+			name: "no alternatives system",
+			fake: &fakeIPTables{
+				version: nftVersion,
+				saves:   map[string]string{"nft": "", "legacy": hostRules},
+			},
+			wantErr: "no alternatives system found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Node.IPTablesBackend = tt.backend
+			err := newTestIPTablesChecker(cfg, tt.fake).Execute(context.Background())
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() unexpected error: %v", err)
+			}
+			if !slices.Equal(tt.fake.selected, tt.wantSelected) {
+				t.Errorf("selected alternatives = %v, want %v", tt.fake.selected, tt.wantSelected)
+			}
+		})
+	}
+}
+
+func TestCountRules(t *testing.T) {
+	rules, kubeChains := countRules(kubeRules + hostRules)
+	if rules != 2 || kubeChains != 2 {
+		t.Errorf("countRules() = %d rules, %d Kubernetes chains, want 2 and 2", rules, kubeChains)
+	}
+}
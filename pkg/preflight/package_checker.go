@@ -0,0 +1,137 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+	"go.goms.io/aks/AKSFlexNode/pkg/timesync"
+)
+
+// PackageChecker installs the host packages bootstrap components require before any component is installed,
+// so a missing package fails bootstrap up front instead of in the middle of a component install
+type PackageChecker struct {
+	config        *config.Config
+	logger        *logrus.Logger
+	requirements  []packages.Requirement
+	detectManager func() (packages.Manager, error)
+}
+
+// NewPackageChecker creates a new PackageChecker
+func NewPackageChecker(logger *logrus.Logger) *PackageChecker {
+	cfg := config.GetConfig()
+	return &PackageChecker{
+		config:        cfg,
+		logger:        logger,
+		requirements:  packages.Requirements(cfg.Packages.Additional, timesync.Requirements(cfg.TimeSync.Enabled)...),
+		detectManager: packages.DetectManager,
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *PackageChecker) GetName() string {
+	return "PackagePreflight"
+}
+
+// Validate validates prerequisites for the package preflight check
+func (c *PackageChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted returns true when every required package is already present
+func (c *PackageChecker) IsCompleted(_ context.Context) bool {
+	manager, _ := c.detectManager()
+	return len(packages.Missing(c.requirements, manager)) == 0
+}
+
+// Plan describes the packages Execute would install, for dry runs
+func (c *PackageChecker) Plan(_ context.Context) []string {
+	manager, _ := c.detectManager()
+	missing := packages.Missing(c.requirements, manager)
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{"install packages " + strings.Join(packages.PackageNames(missing), ", ")}
+}
+
+// Execute installs missing packages from the offline package directory or the package repositories
+func (c *PackageChecker) Execute(ctx context.Context) error {
+	manager, managerErr := c.detectManager()
+	missing := packages.Missing(c.requirements, manager)
+	if len(missing) == 0 {
+		return nil
+	}
+	for _, req := range missing {
+		c.logger.Infof("Required package %s is missing: %s", req.Package, req.Reason)
+	}
+	if managerErr != nil {
+		return fmt.Errorf("cannot install missing packages %s: %w", strings.Join(packages.PackageNames(missing), ", "), managerErr)
+	}
+
+	// A package can be installed while the command it provides is missing, unbootstrap must not remove those
+	var absent []string
+	for _, pkg := range packages.PackageNames(missing) {
+		if !manager.IsInstalled(pkg) {
+			absent = append(absent, pkg)
+		}
+	}
+
+	if err := c.install(ctx, manager, missing); err != nil {
+		return err
+	}
+	c.recordInstalled(manager, absent)
+
+	if stillMissing := packages.Missing(missing, manager); len(stillMissing) > 0 {
+		return fmt.Errorf("required packages are still missing after installation: %s", strings.Join(packages.PackageNames(stillMissing), ", "))
+	}
+	c.logger.Infof("Installed required packages: %s", strings.Join(packages.PackageNames(missing), ", "))
+	return nil
+}
+
+// recordInstalled records which of the previously absent packages the agent installed, so unbootstrap removes
+// only those. Failing to record them leaves the packages on the host, so it does not fail bootstrap.
+func (c *PackageChecker) recordInstalled(manager packages.Manager, absent []string) {
+	var installed []string
+	for _, pkg := range absent {
+		if manager.IsInstalled(pkg) {
+			installed = append(installed, pkg)
+		}
+	}
+	if len(installed) == 0 {
+		return
+	}
+	if err := packages.RecordInstalled(manager.Name(), installed); err != nil {
+		c.logger.Warnf("Failed to record installed packages %s, unbootstrap will keep them: %v", strings.Join(installed, ", "), err)
+	}
+}
+
+// install installs the missing packages, from the offline package directory when one is configured
+func (c *PackageChecker) install(ctx context.Context, manager packages.Manager, missing []packages.Requirement) error {
+	names := packages.PackageNames(missing)
+
+	if offlineDir := c.config.Packages.OfflineDir; offlineDir != "" {
+		files, err := packages.OfflinePackageFiles(offlineDir, manager)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("missing packages %s and no %s files found in offline package directory %s",
+				strings.Join(names, ", "), manager.FileExtension(), offlineDir)
+		}
+		c.logger.Infof("Installing %d package file(s) from %s with %s", len(files), offlineDir, manager.Name())
+		if err := manager.InstallFiles(ctx, files); err != nil {
+			return fmt.Errorf("failed to install packages from %s: %w", offlineDir, err)
+		}
+		return nil
+	}
+
+	c.logger.Infof("Installing %s with %s", strings.Join(names, ", "), manager.Name())
+	if err := manager.Install(ctx, names); err != nil {
+		return fmt.Errorf("failed to install packages %s: %w", strings.Join(names, ", "), err)
+	}
+	return nil
+}
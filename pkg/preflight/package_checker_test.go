@@ -0,0 +1,213 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+)
+
+// fakePackageManager "installs" packages by creating executables of the same name in binDir
+type fakePackageManager struct {
+	binDir         string
+	installed      []string
+	installedFiles []string
+	dependents     map[string][]string
+}
+
+func (m *fakePackageManager) Name() string                   { return "fake" }
+func (m *fakePackageManager) IsInstalled(pkg string) bool    { return slices.Contains(m.installed, pkg) }
+func (m *fakePackageManager) Dependents(pkg string) []string { return m.dependents[pkg] }
+func (m *fakePackageManager) FileExtension() string          { return ".deb" }
+
+func (m *fakePackageManager) Remove(_ context.Context, pkgs []string) error {
+	m.installed = slices.DeleteFunc(m.installed, func(pkg string) bool { return slices.Contains(pkgs, pkg) })
+	return nil
+}
+
+func (m *fakePackageManager) Install(_ context.Context, pkgs []string) error {
+	for _, pkg := range pkgs {
+		if err := os.WriteFile(filepath.Join(m.binDir, pkg), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			return err
+		}
+		m.installed = append(m.installed, pkg)
+	}
+	return nil
+}
+
+func (m *fakePackageManager) InstallFiles(ctx context.Context, files []string) error {
+	m.installedFiles = append(m.installedFiles, files...)
+	for _, file := range files {
+		pkg, _, _ := strings.Cut(filepath.Base(file), "_")
+		if err := m.Install(ctx, []string{pkg}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useTempInstallRecord points the installed packages record to a temporary directory
+func useTempInstallRecord(t *testing.T) {
+	t.Helper()
+	original := packages.InstalledFilePath
+	packages.InstalledFilePath = filepath.Join(t.TempDir(), "installed-packages.json")
+	t.Cleanup(func() { packages.InstalledFilePath = original })
+}
+
+func newTestPackageChecker(t *testing.T, cfg *config.Config, manager packages.Manager, managerErr error) *PackageChecker {
+	t.Helper()
+	useTempInstallRecord(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &PackageChecker{
+		config: cfg,
+		logger: logger,
+		requirements: []packages.Requirement{
+			{Package: "flexnode-test-tool", Command: "flexnode-test-tool", Reason: "test"},
+		},
+		detectManager: func() (packages.Manager, error) { return manager, managerErr },
+	}
+}
+
+func TestPackageCheckerInstallsFromRepositories(t *testing.T) {
+	binDir := t.TempDir()
+	// Recording the installed package needs mkdir from the system PATH
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	manager := &fakePackageManager{binDir: binDir}
+	checker := newTestPackageChecker(t, &config.Config{}, manager, nil)
+
+	if checker.IsCompleted(context.Background()) {
+		t.Fatal("IsCompleted() = true before the package is installed")
+	}
+	if err := checker.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if !slices.Equal(manager.installed, []string{"flexnode-test-tool"}) {
+		t.Errorf("installed packages = %v, want [flexnode-test-tool]", manager.installed)
+	}
+	if !checker.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = false after the package is installed")
+	}
+	if record, err := packages.LoadInstalled(); err != nil || record == nil || !slices.Equal(record.Packages, []string{"flexnode-test-tool"}) {
+		t.Errorf("LoadInstalled() = %+v, %v, want the installed package recorded", record, err)
+	}
+}
+
+func TestPackageCheckerDoesNotRecordPresentPackages(t *testing.T) {
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir)
+	// The package is installed, only the command it provides is missing
+	manager := &fakePackageManager{binDir: binDir, installed: []string{"flexnode-test-tool"}}
+	checker := newTestPackageChecker(t, &config.Config{}, manager, nil)
+
+	if err := checker.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if record, err := packages.LoadInstalled(); err != nil || record != nil {
+		t.Errorf("LoadInstalled() = %+v, %v, want no record for a package found present", record, err)
+	}
+}
+
+func TestPackageUnInstallerRemovesOnlyRecordedPackages(t *testing.T) {
+	useTempInstallRecord(t)
+	if err := packages.RecordInstalled("fake", []string{"curl", "jq", "libjq1"}); err != nil {
+		t.Fatal(err)
+	}
+	manager := &fakePackageManager{
+		installed: []string{"curl", "jq", "libjq1", "tar", "monitoring-agent"},
+		dependents: map[string][]string{
+			"libjq1": {"jq"},
+			"curl":   {"monitoring-agent"}, // installed after bootstrap by other host software
+		},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	uninstaller := &PackageUnInstaller{logger: logger, detectManager: func() (packages.Manager, error) { return manager, nil }}
+
+	if err := uninstaller.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if want := []string{"curl", "tar", "monitoring-agent"}; !slices.Equal(manager.installed, want) {
+		t.Errorf("installed packages = %v, want %v", manager.installed, want)
+	}
+	if !uninstaller.IsCompleted(context.Background()) {
+		t.Error("IsCompleted() = false after the recorded packages were handled")
+	}
+}
+
+func TestPackageUnInstallerKeepsDependenciesOfKeptPackages(t *testing.T) {
+	useTempInstallRecord(t)
+	if err := packages.RecordInstalled("fake", []string{"jq", "libjq1"}); err != nil {
+		t.Fatal(err)
+	}
+	manager := &fakePackageManager{
+		installed: []string{"jq", "libjq1", "inventory-script"},
+		dependents: map[string][]string{
+			"libjq1": {"jq"},
+			"jq":     {"inventory-script"},
+		},
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	uninstaller := &PackageUnInstaller{logger: logger, detectManager: func() (packages.Manager, error) { return manager, nil }}
+
+	if err := uninstaller.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if want := []string{"jq", "libjq1", "inventory-script"}; !slices.Equal(manager.installed, want) {
+		t.Errorf("installed packages = %v, want %v", manager.installed, want)
+	}
+}
+
+func TestPackageCheckerInstallsFromOfflineDirectory(t *testing.T) {
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir)
+	offlineDir := t.TempDir()
+	for _, name := range []string{"flexnode-test-tool_1.0_amd64.deb", "libflexnode_1.0_amd64.deb", "README.txt"} {
+		if err := os.WriteFile(filepath.Join(offlineDir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager := &fakePackageManager{binDir: binDir}
+	cfg := &config.Config{Packages: config.PackagesConfig{OfflineDir: offlineDir}}
+	checker := newTestPackageChecker(t, cfg, manager, nil)
+
+	if err := checker.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	// Every package file is installed in one transaction so dependencies shipped alongside resolve offline
+	if len(manager.installedFiles) != 2 {
+		t.Errorf("installed files = %v, want the 2 .deb files", manager.installedFiles)
+	}
+}
+
+func TestPackageCheckerFailures(t *testing.T) {
+	t.Run("no package manager", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		checker := newTestPackageChecker(t, &config.Config{}, nil, errors.New("no supported package manager found"))
+		err := checker.Execute(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "flexnode-test-tool") {
+			t.Errorf("Execute() error = %v, want error naming the missing package", err)
+		}
+	})
+
+	t.Run("empty offline directory", func(t *testing.T) {
+		binDir := t.TempDir()
+		t.Setenv("PATH", binDir)
+		cfg := &config.Config{Packages: config.PackagesConfig{OfflineDir: t.TempDir()}}
+		checker := newTestPackageChecker(t, cfg, &fakePackageManager{binDir: binDir}, nil)
+		err := checker.Execute(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "no .deb files found") {
+			t.Errorf("Execute() error = %v, want error about the empty offline directory", err)
+		}
+	})
+}
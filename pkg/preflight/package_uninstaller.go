@@ -0,0 +1,97 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+)
+
+// PackageUnInstaller removes the host packages PackageChecker installed. Packages that were already present
+// before bootstrap are never recorded, and recorded packages other host software came to depend on are kept.
+type PackageUnInstaller struct {
+	logger        *logrus.Logger
+	detectManager func() (packages.Manager, error)
+}
+
+// NewPackageUnInstaller creates a new PackageUnInstaller
+func NewPackageUnInstaller(logger *logrus.Logger) *PackageUnInstaller {
+	return &PackageUnInstaller{
+		logger:        logger,
+		detectManager: packages.DetectManager,
+	}
+}
+
+// GetName returns the cleanup step name
+func (u *PackageUnInstaller) GetName() string {
+	return "Package_Uninstaller"
+}
+
+// IsCompleted returns true when no installed packages are recorded
+func (u *PackageUnInstaller) IsCompleted(_ context.Context) bool {
+	record, err := packages.LoadInstalled()
+	return err == nil && record == nil
+}
+
+// Plan describes the packages Execute would remove, for dry runs
+func (u *PackageUnInstaller) Plan(_ context.Context) []string {
+	record, err := packages.LoadInstalled()
+	if err != nil || record == nil {
+		return nil
+	}
+	return []string{"remove packages " + strings.Join(record.Packages, ", ") + " unless other host software depends on them"}
+}
+
+// Execute removes the recorded packages nothing else depends on
+func (u *PackageUnInstaller) Execute(ctx context.Context) error {
+	record, err := packages.LoadInstalled()
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+	manager, err := u.detectManager()
+	if err != nil {
+		return fmt.Errorf("cannot remove installed packages %s: %w", strings.Join(record.Packages, ", "), err)
+	}
+
+	var remove, forget []string
+	dependents := map[string][]string{}
+	for _, pkg := range record.Packages {
+		if !manager.IsInstalled(pkg) {
+			forget = append(forget, pkg)
+			continue
+		}
+		remove = append(remove, pkg)
+		dependents[pkg] = manager.Dependents(pkg)
+	}
+	// Removing a package removes whatever depends on it, so a package is only removed when everything depending
+	// on it is removed as well. Keeping one package can keep the packages it depends on, repeat until none changes.
+	for changed := true; changed; {
+		changed = false
+		for _, pkg := range remove {
+			if kept := slices.DeleteFunc(slices.Clone(dependents[pkg]), func(dependent string) bool {
+				return slices.Contains(remove, dependent)
+			}); len(kept) > 0 {
+				u.logger.Warnf("Keeping package %s installed by the agent, %s depend on it", pkg, strings.Join(kept, ", "))
+				remove = slices.DeleteFunc(remove, func(candidate string) bool { return candidate == pkg })
+				forget = append(forget, pkg)
+				changed = true
+				break
+			}
+		}
+	}
+
+	if len(remove) > 0 {
+		u.logger.Infof("Removing packages installed by the agent with %s: %s", manager.Name(), strings.Join(remove, ", "))
+		if err := manager.Remove(ctx, remove); err != nil {
+			return fmt.Errorf("failed to remove packages %s: %w", strings.Join(remove, ", "), err)
+		}
+	}
+	return packages.ForgetInstalled(slices.Concat(remove, forget))
+}
@@ -0,0 +1,106 @@
+package preflight
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// procMountsPath lists the mounted filesystems and their options, below the checker root
+const procMountsPath = "/proc/mounts"
+
+// StagingChecker verifies the staging directory installers download and extract artifacts into
+// is not on a filesystem mounted noexec, which hardened hosts commonly do for /tmp
+type StagingChecker struct {
+	config *config.Config
+	logger *logrus.Logger
+	root   string // /proc/mounts is read below root, "/" outside of tests
+}
+
+// NewStagingChecker creates a new StagingChecker
+func NewStagingChecker(logger *logrus.Logger) *StagingChecker {
+	return &StagingChecker{
+		config: config.GetConfig(),
+		logger: logger,
+		root:   "/",
+	}
+}
+
+// GetName returns the step name for the executor interface
+func (c *StagingChecker) GetName() string {
+	return "StagingPreflight"
+}
+
+// Validate validates prerequisites for the staging directory preflight check
+func (c *StagingChecker) Validate(_ context.Context) error {
+	return nil
+}
+
+// IsCompleted always returns false so the mount is re-checked on every bootstrap
+func (c *StagingChecker) IsCompleted(_ context.Context) bool {
+	return false
+}
+
+// Execute blocks bootstrap when the staging directory is on a noexec mount
+func (c *StagingChecker) Execute(_ context.Context) error {
+	findings := c.check()
+	for _, finding := range findings {
+		if finding.Severity == SeverityWarning {
+			c.logger.Warnf("Staging preflight: %s", finding)
+		}
+	}
+	if err := errorFromFindings(findings); err != nil {
+		return err
+	}
+	c.logger.Infof("Staging directory %s allows execution", c.config.Paths.StagingDir)
+	return nil
+}
+
+// check returns a finding when the mount holding the staging directory does not allow execution
+func (c *StagingChecker) check() []Finding {
+	stagingDir := c.config.Paths.StagingDir
+	mounts, err := os.ReadFile(filepath.Join(c.root, procMountsPath))
+	if err != nil {
+		return []Finding{{Severity: SeverityWarning, Check: "StagingMount",
+			Message: fmt.Sprintf("cannot verify the mount options of staging directory %s: %v", stagingDir, err)}}
+	}
+
+	mountPoint, options := mountOf(mounts, stagingDir)
+	if slices.Contains(options, "noexec") {
+		return []Finding{{Severity: SeverityError, Check: "StagingMount",
+			Message:  fmt.Sprintf("staging directory %s is on %s, which is mounted noexec", stagingDir, mountPoint),
+			Guidance: "Set paths.stagingDir to a directory on a filesystem mounted with exec, such as one below /var/lib"}}
+	}
+	return nil
+}
+
+// mountOf returns the mount point and options of the most specific mount in /proc/mounts containing path
+func mountOf(mounts []byte, path string) (string, []string) {
+	path = filepath.Clean(path)
+	var mountPoint string
+	var options []string
+	scanner := bufio.NewScanner(bytes.NewReader(mounts))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		// /proc/mounts escapes spaces in mount points
+		point := strings.ReplaceAll(fields[1], `\040`, " ")
+		contains := point == "/" || path == point || strings.HasPrefix(path, point+"/")
+		// Later entries shadow earlier ones mounted at the same point
+		if contains && len(point) >= len(mountPoint) {
+			mountPoint, options = point, strings.Split(fields[3], ",")
+		}
+	}
+	return mountPoint, options
+}
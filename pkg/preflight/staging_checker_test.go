@@ -0,0 +1,70 @@
+package preflight
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestStagingChecker(t *testing.T) {
+	tests := []struct {
+		name       string
+		stagingDir string
+		mounts     string
+		wantErr    string
+	}{
+		{
+			name:       "root filesystem allows execution",
+			stagingDir: "/var/lib/aks-flex-node/tmp",
+			mounts:     "/dev/sda1 / ext4 rw,relatime 0 0\ntmpfs /tmp tmpfs rw,nosuid,nodev,noexec 0 0\n",
+		},
+		{
+			name:       "noexec /tmp",
+			stagingDir: "/tmp/aks-flex-node",
+			mounts:     "/dev/sda1 / ext4 rw,relatime 0 0\ntmpfs /tmp tmpfs rw,nosuid,nodev,noexec 0 0\n",
+			wantErr:    "staging directory /tmp/aks-flex-node is on /tmp, which is mounted noexec",
+		},
+		{
+			name:       "noexec /var",
+			stagingDir: "/var/lib/aks-flex-node/tmp",
+			mounts:     "/dev/sda1 / ext4 rw,relatime 0 0\n/dev/sda2 /var ext4 rw,nodev,noexec 0 0\n",
+			wantErr:    "is on /var, which is mounted noexec",
+		},
+		{
+			name:       "sibling mount point with a common prefix",
+			stagingDir: "/var/lib/aks-flex-node/tmp",
+			mounts:     "/dev/sda1 / ext4 rw,relatime 0 0\n/dev/sda2 /var/li ext4 rw,noexec 0 0\n",
+		},
+		{
+			name:       "remount with exec shadows the earlier mount",
+			stagingDir: "/var/lib/aks-flex-node/tmp",
+			mounts:     "/dev/sda1 / ext4 rw,relatime 0 0\n/dev/sda2 /var ext4 rw,noexec 0 0\n/dev/sda2 /var ext4 rw,relatime 0 0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Paths.StagingDir = tt.stagingDir
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			checker := &StagingChecker{config: cfg, logger: logger, root: writeKernelFiles(t, map[string]string{procMountsPath: tt.mounts})}
+
+			err := checker.Execute(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Dir is the directory where provenance records are persisted, one JSON file per component
+var Dir = filepath.Join(config.AgentStateDir, "provenance")
+
+// Record describes where an installed component came from and when it was installed
+type Record struct {
+	Component    string    `json:"component"`
+	Version      string    `json:"version"`
+	SourceURL    string    `json:"sourceURL"`
+	SHA256       string    `json:"sha256"`
+	InstalledAt  time.Time `json:"installedAt"`
+	AgentVersion string    `json:"agentVersion"`
+}
+
+var (
+	agentVersion   = "unknown"
+	agentVersionMu sync.RWMutex
+)
+
+// SetAgentVersion sets the agent version stamped on new provenance records
+func SetAgentVersion(version string) {
+	agentVersionMu.Lock()
+	defer agentVersionMu.Unlock()
+	agentVersion = version
+}
+
+func getAgentVersion() string {
+	agentVersionMu.RLock()
+	defer agentVersionMu.RUnlock()
+	return agentVersion
+}
+
+// RecordInstall computes the checksum of the downloaded artifact and persists a provenance record for the component
+func RecordInstall(component, version, sourceURL, artifactPath string) error {
+	checksum, err := FileSHA256(artifactPath)
+	if err != nil {
+		return fmt.Errorf("failed to compute checksum of %s: %w", artifactPath, err)
+	}
+
+	return Save(&Record{
+		Component:    component,
+		Version:      version,
+		SourceURL:    sourceURL,
+		SHA256:       checksum,
+		InstalledAt:  time.Now().UTC(),
+		AgentVersion: getAgentVersion(),
+	})
+}
+
+// Save persists a provenance record, replacing any previous record for the same component
+func Save(record *Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance record: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", Dir); err != nil {
+		return fmt.Errorf("failed to create provenance directory %s: %w", Dir, err)
+	}
+	return utils.WriteFileAtomicSystem(recordPath(record.Component), data, 0o644)
+}
+
+// Load reads the provenance record of a single component
+func Load(component string) (*Record, error) {
+	data, err := os.ReadFile(recordPath(component))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance record for %s: %w", component, err)
+	}
+	record := &Record{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance record for %s: %w", component, err)
+	}
+	return record, nil
+}
+
+// LoadAll reads all persisted provenance records sorted by component name.
+// A missing provenance directory yields no records and no error.
+func LoadAll() ([]Record, error) {
+	entries, err := os.ReadDir(Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read provenance directory %s: %w", Dir, err)
+	}
+
+	records := make([]Record, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		record, err := Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Component < records[j].Component })
+	return records, nil
+}
+
+// Remove deletes the provenance record of a component, if any
+func Remove(component string) error {
+	return utils.RunCleanupCommand(recordPath(component))
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 checksum of a file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func recordPath(component string) string {
+	return filepath.Join(Dir, component+".json")
+}
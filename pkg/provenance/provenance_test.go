@@ -0,0 +1,63 @@
+package provenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordInstallAndLoadAll(t *testing.T) {
+	originalDir := Dir
+	Dir = filepath.Join(t.TempDir(), "provenance")
+	defer func() { Dir = originalDir }()
+
+	SetAgentVersion("v1.2.3")
+	defer SetAgentVersion("unknown")
+
+	artifact := filepath.Join(t.TempDir(), "runc.amd64")
+	if err := os.WriteFile(artifact, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("failed to write artifact: %v", err)
+	}
+
+	if err := RecordInstall("runc", "1.1.12", "https://example.com/runc.amd64", artifact); err != nil {
+		t.Fatalf("RecordInstall() error = %v", err)
+	}
+	if err := Save(&Record{Component: "containerd", Version: "1.7.20"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	records, err := LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Component != "containerd" || records[1].Component != "runc" {
+		t.Fatalf("LoadAll() returned unexpected records: %+v", records)
+	}
+
+	runc := records[1]
+	// sha256("hello")
+	if runc.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected checksum %s", runc.SHA256)
+	}
+	if runc.AgentVersion != "v1.2.3" || runc.SourceURL != "https://example.com/runc.amd64" || runc.InstalledAt.IsZero() {
+		t.Errorf("unexpected record: %+v", runc)
+	}
+
+	if err := Remove("runc"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := Load("runc"); err == nil {
+		t.Error("expected runc record to be removed")
+	}
+}
+
+func TestLoadAllMissingDir(t *testing.T) {
+	originalDir := Dir
+	Dir = filepath.Join(t.TempDir(), "missing")
+	defer func() { Dir = originalDir }()
+
+	records, err := LoadAll()
+	if err != nil || len(records) != 0 {
+		t.Errorf("LoadAll() = %v, %v; want no records and no error", records, err)
+	}
+}
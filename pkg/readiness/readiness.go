@@ -0,0 +1,224 @@
+package readiness
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// HistoryFilePath records the boot-to-Ready timelines of the most recent host boots
+var HistoryFilePath = filepath.Join(config.AgentStateDir, "readiness.json")
+
+// Kernel files identifying the current boot and when it happened
+var (
+	bootIDPath   = "/proc/sys/kernel/random/boot_id"
+	procStatPath = "/proc/stat"
+)
+
+// maxHistory bounds how many boots are kept in the history
+const maxHistory = 20
+
+// Boot is the timeline from a host boot to the node first reporting Ready in that boot.
+// The timeline is complete once ReadyAt is set, later bootstraps in the same boot do not change it.
+type Boot struct {
+	BootID              string     `json:"bootId"`
+	BootedAt            time.Time  `json:"bootedAt"`
+	AgentStartedAt      *time.Time `json:"agentStartedAt,omitempty"`
+	BootstrapStartedAt  *time.Time `json:"bootstrapStartedAt,omitempty"`
+	BootstrapFinishedAt *time.Time `json:"bootstrapFinishedAt,omitempty"`
+	BootstrapSucceeded  bool       `json:"bootstrapSucceeded"`
+	KubeletStartedAt    *time.Time `json:"kubeletStartedAt,omitempty"`
+	ReadyAt             *time.Time `json:"readyAt,omitempty"`
+	BootToReadySeconds  float64    `json:"bootToReadySeconds,omitempty"`
+}
+
+// History holds the timelines of the most recent boots, oldest first
+type History struct {
+	Boots []Boot `json:"boots"`
+}
+
+// Latest returns the most recent boot that reached Ready, nil when none did
+func (h *History) Latest() *Boot {
+	for i := len(h.Boots) - 1; i >= 0; i-- {
+		if h.Boots[i].ReadyAt != nil {
+			return &h.Boots[i]
+		}
+	}
+	return nil
+}
+
+// boot returns the timeline of the given boot, starting one when the boot is not in the history yet
+func (h *History) boot(bootID string, bootedAt time.Time) *Boot {
+	for i := range h.Boots {
+		if h.Boots[i].BootID == bootID {
+			return &h.Boots[i]
+		}
+	}
+	h.Boots = append(h.Boots, Boot{BootID: bootID, BootedAt: bootedAt.UTC()})
+	if len(h.Boots) > maxHistory {
+		h.Boots = h.Boots[len(h.Boots)-maxHistory:]
+	}
+	return &h.Boots[len(h.Boots)-1]
+}
+
+// RecordAgentStart records the first agent start in the current boot
+func RecordAgentStart(at time.Time) error {
+	return update(func(boot *Boot) {
+		if boot.AgentStartedAt == nil {
+			boot.AgentStartedAt = timePtr(at)
+		}
+	})
+}
+
+// RecordBootstrap records a bootstrap in the current boot, until the node has reached Ready
+func RecordBootstrap(startedAt, finishedAt time.Time, succeeded bool) error {
+	return update(func(boot *Boot) {
+		if boot.ReadyAt != nil {
+			return
+		}
+		boot.BootstrapStartedAt = timePtr(startedAt)
+		boot.BootstrapFinishedAt = timePtr(finishedAt)
+		boot.BootstrapSucceeded = succeeded
+	})
+}
+
+// ObserveReady completes the timeline of the current boot when the node became Ready after the boot.
+// readySince is the last transition time of the node Ready condition. It returns the current boot's timeline.
+func ObserveReady(readySince time.Time) (*Boot, error) {
+	var current Boot
+	err := update(func(boot *Boot) {
+		// A node that stayed Ready through a short reboot never reports a transition in this boot
+		if boot.ReadyAt == nil && !readySince.Before(boot.BootedAt) {
+			boot.ReadyAt = timePtr(readySince)
+			boot.BootToReadySeconds = readySince.Sub(boot.BootedAt).Seconds()
+			if startedAt, err := kubeletStartedAt(boot.BootedAt); err == nil {
+				boot.KubeletStartedAt = timePtr(startedAt)
+			}
+		}
+		current = *boot
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// Current returns the timeline of the current boot, nil when the agent recorded nothing in this boot yet
+func Current() (*Boot, error) {
+	bootID, err := currentBootID()
+	if err != nil {
+		return nil, err
+	}
+	history, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range history.Boots {
+		if history.Boots[i].BootID == bootID {
+			return &history.Boots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// update applies fn to the timeline of the current boot and persists the history
+func update(fn func(boot *Boot)) error {
+	bootID, err := currentBootID()
+	if err != nil {
+		return err
+	}
+	bootedAt, err := bootTime()
+	if err != nil {
+		return err
+	}
+	history, err := Load()
+	if err != nil {
+		return err
+	}
+	fn(history.boot(bootID, bootedAt))
+	return Save(history)
+}
+
+// Save persists the boot history
+func Save(history *History) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal readiness history: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(HistoryFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", HistoryFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(HistoryFilePath, data, 0o644)
+}
+
+// Load reads the boot history, returning an empty history when none was saved yet
+func Load() (*History, error) {
+	history := &History{}
+	data, err := os.ReadFile(HistoryFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return history, nil
+		}
+		return nil, fmt.Errorf("failed to read readiness history: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse readiness history: %w", err)
+	}
+	return history, nil
+}
+
+// currentBootID returns the kernel's random identifier of the current boot
+func currentBootID() (string, error) {
+	data, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read boot ID: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// bootTime returns when the host booted, from the btime line of /proc/stat
+func bootTime() (time.Time, error) {
+	file, err := os.Open(procStatPath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read boot time: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("failed to parse boot time %q: %w", value, err)
+			}
+			return time.Unix(seconds, 0).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no boot time found in %s", procStatPath)
+}
+
+// kubeletStartedAt returns when kubelet last entered the active state, from its monotonic systemd timestamp
+func kubeletStartedAt(bootedAt time.Time) (time.Time, error) {
+	output, err := utils.RunCommandWithOutput("systemctl", "show", "kubelet", "--property=ActiveEnterTimestampMonotonic", "--value")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read kubelet start time: %w", err)
+	}
+	micros, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil || micros == 0 {
+		return time.Time{}, fmt.Errorf("kubelet has not started in this boot")
+	}
+	return bootedAt.Add(time.Duration(micros) * time.Microsecond), nil
+}
+
+func timePtr(t time.Time) *time.Time {
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,113 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// kubeletRunner answers the kubelet start time query and runs every other command on the host
+type kubeletRunner struct {
+	utils.CommandRunner
+	activeEnterMicros string
+}
+
+func (r *kubeletRunner) Run(ctx context.Context, cmd utils.Command) (*utils.CommandResult, error) {
+	if cmd.Name == "systemctl" {
+		return &utils.CommandResult{Output: r.activeEnterMicros}, nil
+	}
+	return r.CommandRunner.Run(ctx, cmd)
+}
+
+// fakeBoot points the kernel boot files at a boot with the given ID and boot time
+func fakeBoot(t *testing.T, dir, bootID string, bootedAt time.Time) {
+	t.Helper()
+	bootIDPath = filepath.Join(dir, "boot_id")
+	procStatPath = filepath.Join(dir, "stat")
+	if err := os.WriteFile(bootIDPath, []byte(bootID+"\n"), 0o644); err != nil {
+		t.Fatalf("failed to write boot ID: %v", err)
+	}
+	stat := fmt.Sprintf("cpu  1 2 3 4\nintr 0\nbtime %d\nprocesses 42\n", bootedAt.Unix())
+	if err := os.WriteFile(procStatPath, []byte(stat), 0o644); err != nil {
+		t.Fatalf("failed to write stat: %v", err)
+	}
+}
+
+func TestBootTimeline(t *testing.T) {
+	dir := t.TempDir()
+	HistoryFilePath = filepath.Join(dir, "readiness.json")
+	defer utils.SetCommandRunner(&kubeletRunner{CommandRunner: utils.GetCommandRunner(), activeEnterMicros: "95000000"})()
+
+	bootedAt := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	fakeBoot(t, dir, "boot-1", bootedAt)
+
+	if err := RecordAgentStart(bootedAt.Add(30 * time.Second)); err != nil {
+		t.Fatalf("RecordAgentStart() unexpected error: %v", err)
+	}
+	// A restarted agent keeps the first start of the boot
+	if err := RecordAgentStart(bootedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("RecordAgentStart() unexpected error: %v", err)
+	}
+	if err := RecordBootstrap(bootedAt.Add(35*time.Second), bootedAt.Add(80*time.Second), true); err != nil {
+		t.Fatalf("RecordBootstrap() unexpected error: %v", err)
+	}
+
+	// The Ready condition still reports the transition of the previous boot
+	boot, err := ObserveReady(bootedAt.Add(-time.Hour))
+	if err != nil || boot.ReadyAt != nil {
+		t.Fatalf("ObserveReady() before the boot = %+v, %v, want an incomplete timeline", boot, err)
+	}
+
+	boot, err = ObserveReady(bootedAt.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("ObserveReady() unexpected error: %v", err)
+	}
+	if boot.BootToReadySeconds != 120 {
+		t.Errorf("BootToReadySeconds = %v, want 120", boot.BootToReadySeconds)
+	}
+	if boot.AgentStartedAt == nil || !boot.AgentStartedAt.Equal(bootedAt.Add(30*time.Second)) {
+		t.Errorf("AgentStartedAt = %v, want the first agent start", boot.AgentStartedAt)
+	}
+	if boot.KubeletStartedAt == nil || !boot.KubeletStartedAt.Equal(bootedAt.Add(95*time.Second)) {
+		t.Errorf("KubeletStartedAt = %v, want 95s after boot", boot.KubeletStartedAt)
+	}
+
+	// The timeline is complete, a later re-bootstrap in the same boot does not change it
+	if err := RecordBootstrap(bootedAt.Add(3*time.Hour), bootedAt.Add(4*time.Hour), false); err != nil {
+		t.Fatalf("RecordBootstrap() unexpected error: %v", err)
+	}
+	if boot, err = ObserveReady(bootedAt.Add(4 * time.Hour)); err != nil || !boot.BootstrapSucceeded || boot.BootToReadySeconds != 120 {
+		t.Errorf("ObserveReady() after completion = %+v, %v, want the first timeline", boot, err)
+	}
+
+	// The next boot starts a new timeline while the history keeps the previous one
+	fakeBoot(t, dir, "boot-2", bootedAt.Add(24*time.Hour))
+	if current, err := Current(); err != nil || current != nil {
+		t.Errorf("Current() in a new boot = %+v, %v, want nothing recorded", current, err)
+	}
+	if err := RecordAgentStart(bootedAt.Add(24*time.Hour + 20*time.Second)); err != nil {
+		t.Fatalf("RecordAgentStart() unexpected error: %v", err)
+	}
+	history, err := Load()
+	if err != nil || len(history.Boots) != 2 {
+		t.Fatalf("Load() = %+v, %v, want two boots", history, err)
+	}
+	if latest := history.Latest(); latest == nil || latest.BootID != "boot-1" {
+		t.Errorf("Latest() = %+v, want the last boot that reached Ready", latest)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	history := &History{}
+	for i := 0; i < maxHistory+5; i++ {
+		history.boot(fmt.Sprintf("boot-%d", i), time.Unix(int64(i), 0))
+	}
+	if len(history.Boots) != maxHistory || history.Boots[0].BootID != "boot-5" {
+		t.Errorf("history kept %d boots starting at %s, want the last %d", len(history.Boots), history.Boots[0].BootID, maxHistory)
+	}
+}
@@ -0,0 +1,111 @@
+package resolvconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Host DNS stacks
+const (
+	StackResolved       = "systemd-resolved"
+	StackNetworkManager = "NetworkManager"
+	StackStatic         = "static"
+)
+
+// Well-known resolv.conf locations
+const (
+	HostPath            = "/etc/resolv.conf"
+	ResolvedPath        = "/run/systemd/resolve/resolv.conf" // upstream servers, without the 127.0.0.53 stub
+	NetworkManagerPath  = "/run/NetworkManager/resolv.conf"
+	networkManagerStamp = "generated by networkmanager"
+)
+
+// Result describes the host DNS stack and the resolv.conf kubelet should hand to pods
+type Result struct {
+	Stack       string   // StackResolved, StackNetworkManager or StackStatic
+	Path        string   // resolv.conf with the upstream name servers
+	Nameservers []string // name servers listed in Path
+	BrokenLink  string   // target of /etc/resolv.conf when it is a symlink to a missing file
+	Warnings    []string // setups pods may not resolve names with
+}
+
+// Detect inspects the host DNS stack. Paths are resolved below root, which is "/" outside of tests.
+func Detect(root string) *Result {
+	result := &Result{Stack: StackStatic, Path: HostPath}
+
+	if target, err := os.Readlink(filepath.Join(root, HostPath)); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(HostPath), target)
+		}
+		if !exists(root, target) {
+			result.BrokenLink = target
+		}
+	}
+
+	hostData, _ := os.ReadFile(filepath.Join(root, HostPath))
+	switch {
+	case exists(root, ResolvedPath):
+		result.Stack = StackResolved
+		result.Path = ResolvedPath
+	case exists(root, NetworkManagerPath):
+		result.Stack = StackNetworkManager
+		// A broken or missing /etc/resolv.conf falls back to the file NetworkManager maintains
+		if result.BrokenLink != "" || hostData == nil {
+			result.Path = NetworkManagerPath
+		}
+	case bytes.Contains(bytes.ToLower(hostData), []byte(networkManagerStamp)):
+		result.Stack = StackNetworkManager
+	}
+
+	if result.BrokenLink != "" {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s is a symlink to missing file %s", HostPath, result.BrokenLink))
+	}
+	nameservers, warnings := Check(root, result.Path)
+	result.Nameservers = nameservers
+	result.Warnings = append(result.Warnings, warnings...)
+	return result
+}
+
+// Check returns the name servers a resolv.conf lists and warnings when pods cannot resolve names with it
+func Check(root, path string) ([]string, []string) {
+	data, err := os.ReadFile(filepath.Join(root, path))
+	if err != nil {
+		return nil, []string{fmt.Sprintf("cannot read %s: %v", path, err)}
+	}
+
+	nameservers := parseNameservers(data)
+	if len(nameservers) == 0 {
+		return nil, []string{fmt.Sprintf("%s lists no name servers", path)}
+	}
+	for _, server := range nameservers {
+		if ip := net.ParseIP(server); ip == nil || !ip.IsLoopback() {
+			return nameservers, nil
+		}
+	}
+	// Pods have their own loopback interface, so a local caching resolver is unreachable from them
+	return nameservers, []string{fmt.Sprintf("%s only lists loopback name servers %s, which pods cannot reach; set node.kubelet.resolvConf to a file with the upstream servers",
+		path, strings.Join(nameservers, ", "))}
+}
+
+// parseNameservers returns the nameserver entries of a resolv.conf
+func parseNameservers(data []byte) []string {
+	var servers []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+func exists(root, path string) bool {
+	_, err := os.Stat(filepath.Join(root, path))
+	return err == nil
+}
@@ -0,0 +1,121 @@
+package resolvconf
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a file below root, creating its directory
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// symlink creates /etc/resolv.conf below root pointing at target
+func symlink(t *testing.T, root, target string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, HostPath)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name            string
+		setup           func(t *testing.T, root string)
+		wantStack       string
+		wantPath        string
+		wantNameservers []string
+		wantBrokenLink  bool
+		wantWarning     string
+	}{
+		{
+			name: "systemd-resolved",
+			setup: func(t *testing.T, root string) {
+				writeFile(t, root, ResolvedPath, "nameserver 10.1.0.53\nnameserver 10.1.0.54\n")
+				writeFile(t, root, "/run/systemd/resolve/stub-resolv.conf", "nameserver 127.0.0.53\n")
+				symlink(t, root, "../run/systemd/resolve/stub-resolv.conf")
+			},
+			wantStack:       StackResolved,
+			wantPath:        ResolvedPath,
+			wantNameservers: []string{"10.1.0.53", "10.1.0.54"},
+		},
+		{
+			name: "NetworkManager",
+			setup: func(t *testing.T, root string) {
+				writeFile(t, root, HostPath, "# Generated by NetworkManager\nsearch corp.example.com\nnameserver 192.168.1.1\n")
+			},
+			wantStack:       StackNetworkManager,
+			wantPath:        HostPath,
+			wantNameservers: []string{"192.168.1.1"},
+		},
+		{
+			name: "broken stub symlink with NetworkManager",
+			setup: func(t *testing.T, root string) {
+				writeFile(t, root, NetworkManagerPath, "nameserver 192.168.1.1\n")
+				symlink(t, root, "/run/systemd/resolve/missing-stub-resolv.conf")
+			},
+			wantStack:       StackNetworkManager,
+			wantPath:        NetworkManagerPath,
+			wantNameservers: []string{"192.168.1.1"},
+			wantBrokenLink:  true,
+			wantWarning:     "symlink to missing file",
+		},
+		{
+			name: "static file with a local caching resolver",
+			setup: func(t *testing.T, root string) {
+				writeFile(t, root, HostPath, "nameserver 127.0.0.1\nnameserver ::1\n")
+			},
+			wantStack:       StackStatic,
+			wantPath:        HostPath,
+			wantNameservers: []string{"127.0.0.1", "::1"},
+			wantWarning:     "only lists loopback name servers",
+		},
+		{
+			name: "static file without name servers",
+			setup: func(t *testing.T, root string) {
+				writeFile(t, root, HostPath, "search example.com\n")
+			},
+			wantStack:   StackStatic,
+			wantPath:    HostPath,
+			wantWarning: "lists no name servers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			tt.setup(t, root)
+
+			result := Detect(root)
+			if result.Stack != tt.wantStack || result.Path != tt.wantPath {
+				t.Errorf("Detect() = %s %s, want %s %s", result.Stack, result.Path, tt.wantStack, tt.wantPath)
+			}
+			if !slices.Equal(result.Nameservers, tt.wantNameservers) {
+				t.Errorf("Detect() nameservers = %v, want %v", result.Nameservers, tt.wantNameservers)
+			}
+			if (result.BrokenLink != "") != tt.wantBrokenLink {
+				t.Errorf("Detect() broken link = %q, want broken %v", result.BrokenLink, tt.wantBrokenLink)
+			}
+			warnings := strings.Join(result.Warnings, "\n")
+			if tt.wantWarning == "" && warnings != "" {
+				t.Errorf("Detect() unexpected warnings: %s", warnings)
+			}
+			if !strings.Contains(warnings, tt.wantWarning) {
+				t.Errorf("Detect() warnings = %q, want one containing %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
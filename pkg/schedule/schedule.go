@@ -0,0 +1,77 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// StateFilePath records when each periodic daemon task last ran, so a restarted agent resumes its schedule
+var StateFilePath = filepath.Join(config.AgentStateDir, "schedule.json")
+
+// Periodic daemon tasks
+const (
+	TaskStatus      = "status"
+	TaskBootstrap   = "bootstrapCheck"
+	TaskUpdateCheck = "updateCheck"
+	TaskSiteTags    = "siteTags"
+)
+
+// State holds the last run time of each periodic daemon task
+type State struct {
+	LastRun map[string]time.Time `json:"lastRun"`
+}
+
+// Delay returns how long to wait before the next run of a task, zero when it is due.
+// Tasks that never ran are due, as are tasks recorded in the future after a clock change.
+func (s *State) Delay(task string, interval time.Duration, now time.Time) time.Duration {
+	last, ok := s.LastRun[task]
+	if !ok || last.After(now) {
+		return 0
+	}
+	if next := last.Add(interval); next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// Record records a run of a task
+func (s *State) Record(task string, at time.Time) {
+	if s.LastRun == nil {
+		s.LastRun = map[string]time.Time{}
+	}
+	s.LastRun[task] = at.UTC()
+}
+
+// Save persists the schedule state
+func Save(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule state: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(StateFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", StateFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(StateFilePath, data, 0o644)
+}
+
+// Load reads the persisted schedule state, returning an empty state when none was saved yet
+func Load() (*State, error) {
+	state := &State{LastRun: map[string]time.Time{}}
+	data, err := os.ReadFile(StateFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, fmt.Errorf("failed to read schedule state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return &State{LastRun: map[string]time.Time{}}, fmt.Errorf("failed to parse schedule state: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := &State{}
+	state.Record(TaskStatus, now.Add(-20*time.Second))
+	state.Record(TaskSiteTags, now.Add(-time.Hour))
+	state.Record(TaskBootstrap, now.Add(time.Hour))
+
+	tests := []struct {
+		task string
+		want time.Duration
+	}{
+		{TaskStatus, 40 * time.Second},
+		{TaskSiteTags, 0},    // overdue
+		{TaskUpdateCheck, 0}, // never ran
+		{TaskBootstrap, 0},   // recorded in the future after a clock change
+	}
+	for _, tt := range tests {
+		if got := state.Delay(tt.task, time.Minute, now); got != tt.want {
+			t.Errorf("Delay(%s) = %s, want %s", tt.task, got, tt.want)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	StateFilePath = filepath.Join(t.TempDir(), "schedule.json")
+
+	state, err := Load()
+	if err != nil || len(state.LastRun) != 0 {
+		t.Fatalf("Load() without a state file = %v, %v, want an empty state", state, err)
+	}
+
+	ranAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state.Record(TaskUpdateCheck, ranAt)
+	if err := Save(state); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if got := loaded.LastRun[TaskUpdateCheck]; !got.Equal(ranAt) {
+		t.Errorf("loaded last run = %s, want %s", got, ranAt)
+	}
+}
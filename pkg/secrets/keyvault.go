@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
+)
+
+const (
+	// keyVaultAPIVersion is the Key Vault data plane API version secrets are read with
+	keyVaultAPIVersion = "7.4"
+
+	// keyVaultRequestTimeout bounds a single secret request
+	keyVaultRequestTimeout = 30 * time.Second
+
+	// maxSecretResponseSize bounds how much of a secret response is read, Key Vault secrets are at most 25 KB
+	maxSecretResponseSize = 64 * 1024
+)
+
+// KeyVault reads secrets from an Azure Key Vault with the managed identity of the machine
+type KeyVault struct {
+	vaultURL   string
+	scope      string
+	credential azcore.TokenCredential
+	client     *http.Client
+}
+
+// NewKeyVault creates a KeyVault reading from the vault of azure.keyVault
+func NewKeyVault(cfg *config.Config) (*KeyVault, error) {
+	credential, err := auth.NewAuthProvider().ManagedIdentityCredential(cfg.Azure.KeyVault.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyVault{
+		vaultURL:   strings.TrimSuffix(cfg.Azure.KeyVault.VaultURL, "/"),
+		scope:      auth.Cloud(cfg).KeyVaultScope(),
+		credential: credential,
+		client:     &http.Client{Timeout: keyVaultRequestTimeout},
+	}, nil
+}
+
+// secretBundle is the part of the Key Vault secret response the agent uses
+type secretBundle struct {
+	Value string `json:"value"`
+}
+
+// keyVaultError is the error response of Key Vault
+type keyVaultError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GetSecret returns the current value of the named secret, or of a version given as name/version. Throttling
+// and server errors are retried, a missing secret or permission is not.
+func (k *KeyVault) GetSecret(ctx context.Context, name string) (string, error) {
+	var value string
+	err := retry.Do(ctx, retry.Default(), func(ctx context.Context) error {
+		var err error
+		value, err = k.getSecret(ctx, name)
+		return err
+	})
+	return value, err
+}
+
+// getSecret makes a single secret request
+func (k *KeyVault) getSecret(ctx context.Context, name string) (string, error) {
+	token, err := k.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{k.scope}})
+	if err != nil {
+		return "", fmt.Errorf("failed to get a Key Vault token with the managed identity: %w", err)
+	}
+
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	secretURL := fmt.Sprintf("%s/secrets/%s?api-version=%s", k.vaultURL, strings.Join(segments, "/"), keyVaultAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Key Vault request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from %s: %w", name, k.vaultURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from %s: %w", name, k.vaultURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := fmt.Sprintf("HTTP status %d", resp.StatusCode)
+		var kvErr keyVaultError
+		if json.Unmarshal(data, &kvErr) == nil && kvErr.Error.Code != "" {
+			message = fmt.Sprintf("%s: %s", kvErr.Error.Code, kvErr.Error.Message)
+		}
+		return "", failure.WithClass(failure.ClassifyHTTPStatus(resp.StatusCode),
+			fmt.Errorf("failed to read secret %s from %s: %s", name, k.vaultURL, message))
+	}
+
+	var bundle secretBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return "", fmt.Errorf("failed to parse secret %s from %s: %w", name, k.vaultURL, err)
+	}
+	return bundle.Value, nil
+}
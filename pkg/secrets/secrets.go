@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+const (
+	// cacheTTL is how long a fetched secret is reused, long enough for every step of a bootstrap and short
+	// enough that a rotated secret is picked up by the next one
+	cacheTTL = 5 * time.Minute
+
+	// resolveTimeout bounds fetching a secret while the configuration is loaded
+	resolveTimeout = 2 * time.Minute
+)
+
+// Provider fetches secrets by name
+type Provider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// cacheEntry is a fetched secret and when it was fetched
+type cacheEntry struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// Cache reuses the secrets a provider returned for a while, so the configuration reloads and steps of a
+// bootstrap fetch each secret once
+type Cache struct {
+	provider Provider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCache creates a Cache of the secrets of provider, reused for ttl
+func NewCache(provider Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+// GetSecret returns the named secret, fetching it when it is not cached or the cached value expired
+func (c *Cache) GetSecret(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[name]; ok && c.now().Sub(entry.fetchedAt) < c.ttl {
+		return entry.value, nil
+	}
+	value, err := c.provider.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	c.entries[name] = cacheEntry{value: value, fetchedAt: c.now()}
+	return value, nil
+}
+
+// vaults caches the secrets of each Key Vault and identity the agent read from
+var (
+	vaults   = map[config.KeyVaultConfig]*Cache{}
+	vaultsMu sync.Mutex
+)
+
+// keyVaultCache returns the secret cache of the Key Vault of the configuration
+func keyVaultCache(cfg *config.Config) (*Cache, error) {
+	vaultsMu.Lock()
+	defer vaultsMu.Unlock()
+	key := *cfg.Azure.KeyVault
+	if cache, ok := vaults[key]; ok {
+		return cache, nil
+	}
+	vault, err := NewKeyVault(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cache := NewCache(vault, cacheTTL)
+	vaults[key] = cache
+	return cache, nil
+}
+
+// ResolveKeyVaultSecret fetches a ${keyvault:name} reference while the configuration is loaded. It is the
+// config.KeyVaultResolver of the agent.
+func ResolveKeyVaultSecret(cfg *config.Config, name string) (string, error) {
+	cache, err := keyVaultCache(cfg)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	return cache.GetSecret(ctx, name)
+}
+
+// ClientSecret returns the service principal secret. A secret referenced from Key Vault is fetched again once
+// the cached value expired, so a rotated secret is used without reloading the configuration.
+func ClientSecret(ctx context.Context, cfg *config.Config) (string, error) {
+	name, ok := config.KeyVaultSecretName(cfg.ClientSecretReference())
+	if !ok || cfg.Azure.KeyVault == nil {
+		return cfg.Azure.ServicePrincipal.ClientSecret, nil
+	}
+	cache, err := keyVaultCache(cfg)
+	if err != nil {
+		return "", err
+	}
+	return cache.GetSecret(ctx, name)
+}
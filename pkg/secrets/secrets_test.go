@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
+)
+
+// fakeCredential issues a fixed token for the scope it expects
+type fakeCredential struct {
+	scope string
+}
+
+func (c *fakeCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(options.Scopes) != 1 || options.Scopes[0] != c.scope {
+		return azcore.AccessToken{}, errors.New("unexpected scope")
+	}
+	return azcore.AccessToken{Token: "kv-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// countingProvider returns a new value for every fetch
+type countingProvider struct {
+	fetches int
+}
+
+func (p *countingProvider) GetSecret(_ context.Context, name string) (string, error) {
+	p.fetches++
+	return name + "-" + strings.Repeat("v", p.fetches), nil
+}
+
+func TestKeyVaultGetSecret(t *testing.T) {
+	defer retry.SetDefault(retry.Policy{Attempts: 1})()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer kv-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("api-version") != keyVaultAPIVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/secrets/sp-secret", "/secrets/sp-secret/0123abcd":
+			_, _ = w.Write([]byte(`{"value": "s3cret", "id": "https://vault/secrets/sp-secret/0123abcd"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": "SecretNotFound", "message": "A secret with (name/id) missing was not found in this key vault."}}`))
+		}
+	}))
+	defer server.Close()
+
+	vault := &KeyVault{
+		vaultURL:   server.URL,
+		scope:      "https://vault.azure.net/.default",
+		credential: &fakeCredential{scope: "https://vault.azure.net/.default"},
+		client:     server.Client(),
+	}
+
+	for _, name := range []string{"sp-secret", "sp-secret/0123abcd"} {
+		value, err := vault.GetSecret(context.Background(), name)
+		if err != nil || value != "s3cret" {
+			t.Errorf("GetSecret(%s) = %q, %v, want s3cret", name, value, err)
+		}
+	}
+
+	_, err := vault.GetSecret(context.Background(), "missing")
+	if err == nil || !strings.Contains(err.Error(), "SecretNotFound") {
+		t.Errorf("GetSecret(missing) error = %v, want SecretNotFound", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	provider := &countingProvider{}
+	cache := NewCache(provider, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	first, _ := cache.GetSecret(context.Background(), "sp")
+	second, _ := cache.GetSecret(context.Background(), "sp")
+	if first != second || provider.fetches != 1 {
+		t.Errorf("cached secret fetched %d times, got %q then %q", provider.fetches, first, second)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if third, _ := cache.GetSecret(context.Background(), "sp"); third == first || provider.fetches != 2 {
+		t.Errorf("expired secret was not fetched again, got %q after %d fetches", third, provider.fetches)
+	}
+}
+
+func TestClientSecretWithoutKeyVault(t *testing.T) {
+	cfg := &config.Config{Azure: config.AzureConfig{ServicePrincipal: &config.ServicePrincipalConfig{ClientSecret: "plain"}}}
+	secret, err := ClientSecret(context.Background(), cfg)
+	if err != nil || secret != "plain" {
+		t.Errorf("ClientSecret() = %q, %v, want the configured secret", secret, err)
+	}
+}
@@ -0,0 +1,181 @@
+package sitetags
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/maintenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Arc machine tags carrying site-level settings
+const (
+	SiteTag                = "aks-flex-node-site"                 // site identifier, applied as the SiteLabel node label
+	LabelsTag              = "aks-flex-node-labels"               // node label overrides, e.g. "topology.kubernetes.io/zone=store-12,tier=gold"
+	MaintenanceScheduleTag = "aks-flex-node-maintenance-schedule" // cron schedule of maintenance window starts
+	MaintenanceDurationTag = "aks-flex-node-maintenance-duration" // how long each maintenance window stays open
+	MaintenanceTimeZoneTag = "aks-flex-node-maintenance-timezone" // IANA time zone of the maintenance schedule
+)
+
+// SiteLabel is the node label the site identifier is applied as
+const SiteLabel = "kubernetes.azure.com/flex-node-site"
+
+// StateFilePath records the site settings last applied from the Arc machine tags
+var StateFilePath = filepath.Join(config.AgentStateDir, "site-tags.json")
+
+// labelKeyPattern matches a node label key with an optional DNS subdomain prefix
+var labelKeyPattern = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+// labelValuePattern matches a node label value
+var labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+
+// Overrides are the site-level settings read from the Arc machine tags
+type Overrides struct {
+	Site              string                          `json:"site,omitempty"`
+	Labels            map[string]string               `json:"labels,omitempty"`
+	MaintenanceWindow *config.MaintenanceWindowConfig `json:"maintenanceWindow,omitempty"`
+	AppliedAt         time.Time                       `json:"appliedAt"`
+}
+
+// Parse extracts the site settings from the Arc machine tags. Invalid settings are skipped
+// and described in the returned warnings, so one bad tag does not discard the others.
+func Parse(tags map[string]*string) (*Overrides, []string) {
+	values := make(map[string]string)
+	for key, value := range tags {
+		if value != nil {
+			// Azure tag names are case-insensitive
+			values[strings.ToLower(key)] = strings.TrimSpace(*value)
+		}
+	}
+
+	overrides := &Overrides{}
+	var warnings []string
+
+	if site := values[SiteTag]; site != "" {
+		if labelValuePattern.MatchString(site) {
+			overrides.Site = site
+		} else {
+			warnings = append(warnings, fmt.Sprintf("ignoring tag %s: %q is not a valid node label value", SiteTag, site))
+		}
+	}
+
+	if labels := values[LabelsTag]; labels != "" {
+		for _, pair := range strings.Split(labels, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(value) {
+				warnings = append(warnings, fmt.Sprintf("ignoring label %q in tag %s: not a valid key=value node label", pair, LabelsTag))
+				continue
+			}
+			if overrides.Labels == nil {
+				overrides.Labels = make(map[string]string)
+			}
+			overrides.Labels[key] = value
+		}
+	}
+
+	if schedule := values[MaintenanceScheduleTag]; schedule != "" {
+		window := &config.MaintenanceWindowConfig{
+			Schedule: schedule,
+			Duration: values[MaintenanceDurationTag],
+			TimeZone: values[MaintenanceTimeZoneTag],
+		}
+		if _, err := maintenance.NewWindow(window.Schedule, window.Duration, window.TimeZone); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignoring maintenance window tags: %v", err))
+		} else {
+			overrides.MaintenanceWindow = window
+		}
+	}
+
+	return overrides, warnings
+}
+
+// NodeLabels returns the node labels the site settings set, including the site label
+func (o *Overrides) NodeLabels() map[string]string {
+	labels := maps.Clone(o.Labels)
+	if o.Site != "" {
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[SiteLabel] = o.Site
+	}
+	return labels
+}
+
+// Apply merges the site settings into the configuration. Tag values take precedence over the configuration file.
+func (o *Overrides) Apply(cfg *config.Config) {
+	if labels := o.NodeLabels(); len(labels) > 0 {
+		if cfg.Node.Labels == nil {
+			cfg.Node.Labels = make(map[string]string)
+		}
+		maps.Copy(cfg.Node.Labels, labels)
+	}
+	if o.MaintenanceWindow != nil {
+		window := *o.MaintenanceWindow
+		cfg.Agent.MaintenanceWindow = &window
+	}
+}
+
+// Equal reports whether two sets of site settings are the same, ignoring when they were applied
+func (o *Overrides) Equal(other *Overrides) bool {
+	if o == nil || other == nil {
+		return o == other
+	}
+	if o.Site != other.Site || !maps.Equal(o.Labels, other.Labels) {
+		return false
+	}
+	if o.MaintenanceWindow == nil || other.MaintenanceWindow == nil {
+		return o.MaintenanceWindow == other.MaintenanceWindow
+	}
+	return *o.MaintenanceWindow == *other.MaintenanceWindow
+}
+
+// RemovedLabels returns the node labels previously set from tags that the current settings no longer set, in sorted order
+func RemovedLabels(previous, current *Overrides) []string {
+	if previous == nil {
+		return nil
+	}
+	currentLabels := current.NodeLabels()
+	var removed []string
+	for _, key := range slices.Sorted(maps.Keys(previous.NodeLabels())) {
+		if _, ok := currentLabels[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	return removed
+}
+
+// Save persists the applied site settings so they survive agent restarts and appear in node status
+func Save(overrides *Overrides) error {
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal site settings: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(StateFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", StateFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(StateFilePath, data, 0o644)
+}
+
+// Load reads the site settings last applied, returning nil when none were applied yet
+func Load() (*Overrides, error) {
+	data, err := os.ReadFile(StateFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read site settings: %w", err)
+	}
+	overrides := &Overrides{}
+	if err := json.Unmarshal(data, overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse site settings: %w", err)
+	}
+	return overrides, nil
+}
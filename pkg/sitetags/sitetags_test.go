@@ -0,0 +1,95 @@
+package sitetags
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestParse(t *testing.T) {
+	overrides, warnings := Parse(map[string]*string{
+		"AKS-Flex-Node-Site":                 ptr("store-0042"),
+		"aks-flex-node-labels":               ptr("topology.kubernetes.io/zone=west-3, tier=gold,bad label=x"),
+		"aks-flex-node-maintenance-schedule": ptr("0 2 * * sat"),
+		"aks-flex-node-maintenance-duration": ptr("4h"),
+		"aks-flex-node-maintenance-timezone": ptr("America/Chicago"),
+		"environment":                        ptr("edge"),
+		"aks-flex-node-version":              nil,
+	})
+
+	if overrides.Site != "store-0042" {
+		t.Errorf("Site = %q, want store-0042", overrides.Site)
+	}
+	wantLabels := map[string]string{"topology.kubernetes.io/zone": "west-3", "tier": "gold"}
+	if !maps.Equal(overrides.Labels, wantLabels) {
+		t.Errorf("Labels = %v, want %v", overrides.Labels, wantLabels)
+	}
+	wantWindow := config.MaintenanceWindowConfig{Schedule: "0 2 * * sat", Duration: "4h", TimeZone: "America/Chicago"}
+	if overrides.MaintenanceWindow == nil || *overrides.MaintenanceWindow != wantWindow {
+		t.Errorf("MaintenanceWindow = %+v, want %+v", overrides.MaintenanceWindow, wantWindow)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "bad label=x") {
+		t.Errorf("warnings = %v, want one warning for the invalid label", warnings)
+	}
+}
+
+func TestParseSkipsInvalidSettings(t *testing.T) {
+	overrides, warnings := Parse(map[string]*string{
+		SiteTag:                ptr("store 42"),
+		MaintenanceScheduleTag: ptr("0 2 * * sat"),
+	})
+
+	if overrides.Site != "" || overrides.MaintenanceWindow != nil {
+		t.Errorf("Parse() = %+v, want invalid settings skipped", overrides)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %v, want one for the site and one for the maintenance window", warnings)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Node.Labels = map[string]string{"tier": "silver", "kubernetes.azure.com/managed": "false"}
+
+	overrides := &Overrides{
+		Site:              "store-0042",
+		Labels:            map[string]string{"tier": "gold"},
+		MaintenanceWindow: &config.MaintenanceWindowConfig{Schedule: "0 2 * * *", Duration: "2h"},
+	}
+	overrides.Apply(cfg)
+
+	want := map[string]string{"tier": "gold", "kubernetes.azure.com/managed": "false", SiteLabel: "store-0042"}
+	if !maps.Equal(cfg.Node.Labels, want) {
+		t.Errorf("labels = %v, want %v", cfg.Node.Labels, want)
+	}
+	if cfg.Agent.MaintenanceWindow == nil || cfg.Agent.MaintenanceWindow == overrides.MaintenanceWindow {
+		t.Errorf("maintenance window = %v, want a copy of the tag window", cfg.Agent.MaintenanceWindow)
+	}
+}
+
+func TestEqualAndRemovedLabels(t *testing.T) {
+	previous := &Overrides{Site: "store-0042", Labels: map[string]string{"tier": "gold", "region": "west"}}
+	current := &Overrides{Labels: map[string]string{"tier": "gold"}}
+
+	if previous.Equal(current) {
+		t.Error("Equal() = true for different settings")
+	}
+	if !current.Equal(&Overrides{Labels: map[string]string{"tier": "gold"}}) {
+		t.Error("Equal() = false for identical settings")
+	}
+	if current.Equal(nil) {
+		t.Error("Equal(nil) = true")
+	}
+
+	if got, want := RemovedLabels(previous, current), []string{SiteLabel, "region"}; !slices.Equal(got, want) {
+		t.Errorf("RemovedLabels() = %v, want %v", got, want)
+	}
+	if got := RemovedLabels(nil, current); got != nil {
+		t.Errorf("RemovedLabels(nil, ...) = %v, want nil", got)
+	}
+}
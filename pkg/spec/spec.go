@@ -0,0 +1,244 @@
+package spec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v5"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// CacheFilePath holds the last fetched spec, shared by the steps of a bootstrap and across agent restarts
+var CacheFilePath = filepath.Join(config.AgentStateDir, "cluster-spec.json")
+
+// cacheTTL is how long a fetched spec is reused before the managed cluster is fetched again
+const cacheTTL = 15 * time.Minute
+
+// cachedSpec is the on-disk form of a fetched spec
+type cachedSpec struct {
+	ClusterID string              `json:"clusterId"`
+	FetchedAt time.Time           `json:"fetchedAt"`
+	Spec      *ManagedClusterSpec `json:"spec"`
+}
+
+// ManagedClusterSpec holds the subset of the target AKS managed cluster spec the agent relies on
+type ManagedClusterSpec struct {
+	Name              string `json:"name"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+	FQDN              string `json:"fqdn,omitempty"`
+	NetworkPlugin     string `json:"networkPlugin,omitempty"`     // azure, kubenet or none
+	NetworkPluginMode string `json:"networkPluginMode,omitempty"` // overlay for Azure CNI Overlay
+	NetworkPolicy     string `json:"networkPolicy,omitempty"`
+	NetworkDataplane  string `json:"networkDataplane,omitempty"` // azure or cilium
+	PodCIDR           string `json:"podCIDR,omitempty"`
+	ServiceCIDR       string `json:"serviceCIDR,omitempty"`
+	DNSServiceIP      string `json:"dnsServiceIP,omitempty"`
+
+	SKUName string `json:"skuName,omitempty"` // Base for standard clusters
+	SKUTier string `json:"skuTier,omitempty"` // Free, Standard or Premium
+
+	AADManaged            bool `json:"aadManaged"`            // Microsoft Entra ID integration enabled
+	AzureRBACEnabled      bool `json:"azureRBACEnabled"`      // Azure RBAC for Kubernetes authorization
+	LocalAccountsDisabled bool `json:"localAccountsDisabled"` // admin/local kubeconfig credentials unavailable
+
+	PrivateCluster     bool     `json:"privateCluster"`
+	PrivateFQDN        string   `json:"privateFQDN,omitempty"`
+	PublicFQDNEnabled  bool     `json:"publicFQDNEnabled"` // private cluster also exposes a public FQDN
+	AuthorizedIPRanges []string `json:"authorizedIPRanges,omitempty"`
+}
+
+// Collector retrieves the target managed cluster spec using the Azure SDK
+type Collector struct {
+	config   *config.Config
+	logger   *logrus.Logger
+	mcClient *armcontainerservice.ManagedClustersClient
+	readOnly bool // never writes the cache, for dry runs
+}
+
+// NewCollector creates a new managed cluster spec Collector
+func NewCollector(logger *logrus.Logger) *Collector {
+	return &Collector{
+		config: config.GetConfig(),
+		logger: logger,
+	}
+}
+
+// NewReadOnlyCollector creates a Collector for dry runs, which reuses a cached spec but never writes the cache
+func NewReadOnlyCollector(logger *logrus.Logger) *Collector {
+	collector := NewCollector(logger)
+	collector.readOnly = true
+	return collector
+}
+
+// Collect returns the spec of the target managed cluster.
+// A spec fetched less than 15 minutes ago is reused, so restarts and repeated bootstraps do not each call ARM.
+func (c *Collector) Collect(ctx context.Context) (*ManagedClusterSpec, error) {
+	clusterID := c.config.GetTargetClusterID()
+	if cached := loadCache(clusterID, time.Now()); cached != nil {
+		c.logger.Debugf("Using managed cluster spec fetched at %s", cached.FetchedAt.Format(time.RFC3339))
+		return cached.Spec, nil
+	}
+
+	clusterSpec, err := c.fetch(ctx)
+	if err != nil || c.readOnly {
+		return clusterSpec, err
+	}
+	if err := saveCache(&cachedSpec{ClusterID: clusterID, FetchedAt: time.Now().UTC(), Spec: clusterSpec}); err != nil {
+		c.logger.Debugf("Failed to cache managed cluster spec: %v", err)
+	}
+	return clusterSpec, nil
+}
+
+// ResolveKubernetesVersion selects the kubelet version for kubernetes.version "auto" or omitted from the cluster's
+// current Kubernetes version and records it in the config. A pinned version is kept and nothing is fetched.
+func (c *Collector) ResolveKubernetesVersion(ctx context.Context) error {
+	if !c.config.IsKubernetesVersionAuto() {
+		return nil
+	}
+	clusterSpec, err := c.Collect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch managed cluster spec to select the kubernetes version: %w", err)
+	}
+	version, err := compat.SelectKubeletVersion(clusterSpec.KubernetesVersion)
+	if err != nil {
+		return fmt.Errorf("failed to select the kubernetes version: %w", err)
+	}
+	if version != c.config.GetKubernetesVersion() {
+		c.logger.Infof("Selected Kubernetes version %s to match cluster %s", version, clusterSpec.Name)
+	}
+	c.config.SetResolvedKubernetesVersion(version)
+	return nil
+}
+
+// fetch fetches the target managed cluster and returns its spec
+func (c *Collector) fetch(ctx context.Context) (*ManagedClusterSpec, error) {
+	if c.mcClient == nil {
+		if err := c.setUpClients(); err != nil {
+			return nil, fmt.Errorf("failed to set up Azure SDK clients: %w", err)
+		}
+	}
+
+	resourceGroup := c.config.GetTargetClusterResourceGroup()
+	clusterName := c.config.GetTargetClusterName()
+	c.logger.Debugf("Fetching managed cluster spec for %s in resource group %s", clusterName, resourceGroup)
+
+	resp, err := c.mcClient.Get(ctx, resourceGroup, clusterName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get managed cluster %s in resource group %s: %w", clusterName, resourceGroup, err)
+	}
+	return newManagedClusterSpec(&resp.ManagedCluster), nil
+}
+
+func (c *Collector) setUpClients() error {
+	cred, err := auth.NewAuthProvider().UserCredential(c.config)
+	if err != nil {
+		return fmt.Errorf("failed to get authentication credential: %w", err)
+	}
+	clientFactory, err := armcontainerservice.NewClientFactory(c.config.GetTargetClusterSubscriptionID(), cred, auth.ClientOptions(c.config))
+	if err != nil {
+		return fmt.Errorf("failed to create Azure Container Service client factory: %w", err)
+	}
+	c.mcClient = clientFactory.NewManagedClustersClient()
+	return nil
+}
+
+// newManagedClusterSpec extracts the fields the agent relies on from a managed cluster resource
+func newManagedClusterSpec(mc *armcontainerservice.ManagedCluster) *ManagedClusterSpec {
+	spec := &ManagedClusterSpec{
+		Name: stringValue(mc.Name),
+	}
+	if mc.SKU != nil {
+		spec.SKUName = stringValue(mc.SKU.Name)
+		spec.SKUTier = stringValue(mc.SKU.Tier)
+	}
+	if mc.Properties == nil {
+		return spec
+	}
+
+	spec.KubernetesVersion = stringValue(mc.Properties.CurrentKubernetesVersion)
+	if spec.KubernetesVersion == "" {
+		spec.KubernetesVersion = stringValue(mc.Properties.KubernetesVersion)
+	}
+	spec.FQDN = stringValue(mc.Properties.Fqdn)
+
+	if profile := mc.Properties.NetworkProfile; profile != nil {
+		spec.NetworkPlugin = stringValue(profile.NetworkPlugin)
+		spec.NetworkPluginMode = stringValue(profile.NetworkPluginMode)
+		spec.NetworkPolicy = stringValue(profile.NetworkPolicy)
+		spec.NetworkDataplane = stringValue(profile.NetworkDataplane)
+		spec.PodCIDR = stringValue(profile.PodCidr)
+		spec.ServiceCIDR = stringValue(profile.ServiceCidr)
+		spec.DNSServiceIP = stringValue(profile.DNSServiceIP)
+	}
+
+	if aad := mc.Properties.AADProfile; aad != nil {
+		spec.AADManaged = boolValue(aad.Managed)
+		spec.AzureRBACEnabled = boolValue(aad.EnableAzureRBAC)
+	}
+	spec.LocalAccountsDisabled = boolValue(mc.Properties.DisableLocalAccounts)
+
+	spec.PrivateFQDN = stringValue(mc.Properties.PrivateFQDN)
+	if access := mc.Properties.APIServerAccessProfile; access != nil {
+		spec.PrivateCluster = boolValue(access.EnablePrivateCluster)
+		spec.PublicFQDNEnabled = boolValue(access.EnablePrivateClusterPublicFQDN)
+		for _, ipRange := range access.AuthorizedIPRanges {
+			if ipRange != nil {
+				spec.AuthorizedIPRanges = append(spec.AuthorizedIPRanges, *ipRange)
+			}
+		}
+	}
+	return spec
+}
+
+// loadCache returns the cached spec of the cluster when it is fresh, nil otherwise
+func loadCache(clusterID string, now time.Time) *cachedSpec {
+	data, err := os.ReadFile(CacheFilePath)
+	if err != nil {
+		return nil
+	}
+	cached := &cachedSpec{}
+	if err := json.Unmarshal(data, cached); err != nil || cached.Spec == nil {
+		return nil
+	}
+	// Resource IDs are case-insensitive, a cache of another cluster or from the future is never used
+	age := now.Sub(cached.FetchedAt)
+	if !strings.EqualFold(cached.ClusterID, clusterID) || age < 0 || age > cacheTTL {
+		return nil
+	}
+	return cached
+}
+
+// saveCache persists a fetched spec
+func saveCache(cached *cachedSpec) error {
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal managed cluster spec: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(CacheFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", CacheFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(CacheFilePath, data, 0o644)
+}
+
+// stringValue dereferences optional string-like SDK fields, returning an empty string for nil
+func stringValue[T ~string](value *T) string {
+	if value == nil {
+		return ""
+	}
+	return string(*value)
+}
+
+// boolValue dereferences optional bool SDK fields, returning false for nil
+func boolValue(value *bool) bool {
+	return value != nil && *value
+}
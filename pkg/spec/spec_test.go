@@ -0,0 +1,74 @@
+package spec
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+func TestCache(t *testing.T) {
+	CacheFilePath = filepath.Join(t.TempDir(), "cluster-spec.json")
+	const clusterID = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/edge"
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if cached := loadCache(clusterID, fetchedAt); cached != nil {
+		t.Fatalf("loadCache() without a cache file = %+v, want nil", cached)
+	}
+	if err := saveCache(&cachedSpec{ClusterID: clusterID, FetchedAt: fetchedAt, Spec: &ManagedClusterSpec{Name: "edge"}}); err != nil {
+		t.Fatalf("saveCache() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		clusterID string
+		now       time.Time
+		wantHit   bool
+	}{
+		{"fresh", clusterID, fetchedAt.Add(5 * time.Minute), true},
+		{"resource ID in other case", "/subscriptions/SUB/resourcegroups/rg/providers/Microsoft.ContainerService/managedClusters/edge", fetchedAt, true},
+		{"expired", clusterID, fetchedAt.Add(cacheTTL + time.Second), false},
+		{"other cluster", clusterID + "-2", fetchedAt, false},
+		{"fetched in the future", clusterID, fetchedAt.Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cached := loadCache(tt.clusterID, tt.now)
+			if (cached != nil) != tt.wantHit {
+				t.Fatalf("loadCache() = %+v, want hit %v", cached, tt.wantHit)
+			}
+			if cached != nil && cached.Spec.Name != "edge" {
+				t.Errorf("cached spec name = %s, want edge", cached.Spec.Name)
+			}
+		})
+	}
+}
+
+func TestResolveKubernetesVersion(t *testing.T) {
+	CacheFilePath = filepath.Join(t.TempDir(), "cluster-spec.json")
+	const clusterID = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/edge"
+	if err := saveCache(&cachedSpec{ClusterID: clusterID, FetchedAt: time.Now().UTC(), Spec: &ManagedClusterSpec{Name: "edge", KubernetesVersion: "1.32.7"}}); err != nil {
+		t.Fatalf("saveCache() unexpected error: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for version, want := range map[string]string{"": "1.32.7", "auto": "1.32.7", "1.31.2": "1.31.2"} {
+		cfg := &config.Config{
+			Azure:      config.AzureConfig{TargetCluster: &config.TargetClusterConfig{ResourceID: clusterID}},
+			Kubernetes: config.KubernetesConfig{Version: version},
+		}
+		collector := &Collector{config: cfg, logger: logger}
+		if err := collector.ResolveKubernetesVersion(context.Background()); err != nil {
+			t.Fatalf("ResolveKubernetesVersion() with version %q unexpected error: %v", version, err)
+		}
+		if got := cfg.GetKubernetesVersion(); got != want {
+			t.Errorf("GetKubernetesVersion() with version %q = %q, want %q", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// BootstrapFailureFilePath records the last bootstrap failure so it survives agent restarts
+// and can be surfaced by node-problem-detector
+var BootstrapFailureFilePath = filepath.Join(config.AgentStateDir, "bootstrap-failure.json")
+
+// BootstrapFailure describes consecutive bootstrap failures observed by the agent
+type BootstrapFailure struct {
+	Error               string    `json:"error"`
+	FirstFailedAt       time.Time `json:"firstFailedAt"`
+	LastFailedAt        time.Time `json:"lastFailedAt"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+
+	// Class of the last failure: transient failures are retried with backoff, permanent ones need an operator
+	Class failure.Class `json:"class,omitempty"`
+}
+
+// RecordBootstrapFailure persists a bootstrap failure, incrementing the consecutive failure count
+func RecordBootstrapFailure(bootstrapErr error) error {
+	now := time.Now().UTC()
+	record, err := LoadBootstrapFailure()
+	if err != nil || record == nil {
+		record = &BootstrapFailure{FirstFailedAt: now}
+	}
+	record.Error = bootstrapErr.Error()
+	record.Class = failure.Classify(bootstrapErr)
+	record.LastFailedAt = now
+	record.ConsecutiveFailures++
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bootstrap failure: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(BootstrapFailureFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", BootstrapFailureFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(BootstrapFailureFilePath, data, 0o644)
+}
+
+// ClearBootstrapFailure removes the bootstrap failure record after a successful bootstrap
+func ClearBootstrapFailure() error {
+	if !utils.FileExists(BootstrapFailureFilePath) {
+		return nil
+	}
+	return utils.RunCleanupCommand(BootstrapFailureFilePath)
+}
+
+// LoadBootstrapFailure reads the bootstrap failure record, returning nil when the last bootstrap succeeded
+func LoadBootstrapFailure() (*BootstrapFailure, error) {
+	data, err := os.ReadFile(BootstrapFailureFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read bootstrap failure record: %w", err)
+	}
+	record := &BootstrapFailure{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap failure record: %w", err)
+	}
+	return record, nil
+}
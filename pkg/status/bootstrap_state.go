@@ -0,0 +1,93 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// BootstrapStateFilePath records the outcome of every bootstrap step so a failed bootstrap can be resumed
+// from the failed step. Unlike the progress file it survives reboots.
+var BootstrapStateFilePath = filepath.Join(config.AgentStateDir, "bootstrap-state.json")
+
+// Outcomes of a bootstrap step recorded in the bootstrap state
+const (
+	StepPending   = "pending"   // the step did not run yet, or bootstrap stopped before it
+	StepSucceeded = "succeeded" // the step ran or was already completed
+	StepFailed    = "failed"
+)
+
+// BootstrapState is the outcome of each step of the latest bootstrap, in pipeline order
+type BootstrapState struct {
+	StartedAt  time.Time   `json:"startedAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+	InputsHash string      `json:"inputsHash"` // hash of the configuration and component defaults bootstrap ran with
+	Steps      []StepState `json:"steps"`
+}
+
+// StepState is the outcome of a single bootstrap step
+type StepState struct {
+	Name       string     `json:"name"`
+	Outcome    string     `json:"outcome"`              // pending, succeeded or failed
+	InputsHash string     `json:"inputsHash,omitempty"` // inputs the step last ran with, a resumed step keeps those of its run
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+
+	// FailureClass tells whether retrying the failed step may succeed: transient, permanent or unknown
+	FailureClass failure.Class `json:"failureClass,omitempty"`
+}
+
+// Step returns the recorded state of the named step, nil when the step is not recorded
+func (s *BootstrapState) Step(name string) *StepState {
+	for i := range s.Steps {
+		if s.Steps[i].Name == name {
+			return &s.Steps[i]
+		}
+	}
+	return nil
+}
+
+// WriteBootstrapState atomically writes the bootstrap state to the given path, stamping its update time
+func WriteBootstrapState(path string, state *BootstrapState) error {
+	state.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bootstrap state: %w", err)
+	}
+	if err := sysutil.RunSystemCommand("mkdir", "-p", filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	return fsutil.WriteFileAtomicSystem(path, data, 0o644)
+}
+
+// LoadBootstrapState reads the bootstrap state from the given path, returning nil when no bootstrap was recorded
+func LoadBootstrapState(path string) (*BootstrapState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read bootstrap state: %w", err)
+	}
+	state := &BootstrapState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap state: %w", err)
+	}
+	return state, nil
+}
+
+// ClearBootstrapState removes the bootstrap state, e.g. after unbootstrap removed what bootstrap set up
+func ClearBootstrapState(path string) error {
+	if !fsutil.FileExists(path) {
+		return nil
+	}
+	return fsutil.RunCleanupCommand(path)
+}
@@ -0,0 +1,134 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// nodeLeaseNamespace holds the leases kubelet renews as its heartbeat
+const nodeLeaseNamespace = "kube-node-lease"
+
+// ClusterNodeStatus is the node as the cluster API server sees it
+type ClusterNodeStatus struct {
+	Conditions    []NodeCondition `json:"conditions"`              // Conditions set by kubelet, the node problem detector and cluster controllers
+	Taints        []string        `json:"taints,omitempty"`        // Taints in key[=value]:Effect format
+	Unschedulable bool            `json:"unschedulable"`           // Node is cordoned
+	LastHeartbeat *time.Time      `json:"lastHeartbeat,omitempty"` // Last renewal of the kubelet node lease
+}
+
+// NodeCondition is a condition of the node object
+type NodeCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastHeartbeatTime  time.Time `json:"lastHeartbeatTime"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// nodeObject holds the fields of the Kubernetes node object reported in status
+type nodeObject struct {
+	Spec struct {
+		Unschedulable bool `json:"unschedulable"`
+		Taints        []struct {
+			Key    string `json:"key"`
+			Value  string `json:"value"`
+			Effect string `json:"effect"`
+		} `json:"taints"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []NodeCondition `json:"conditions"`
+	} `json:"status"`
+}
+
+// collectClusterNode reads the node object and its lease from the API server with the kubelet credentials.
+// It returns nil when kubelet has no credentials yet.
+func (c *Collector) collectClusterNode(_ context.Context) (*ClusterNodeStatus, error) {
+	nodeName := c.config.GetNodeName()
+	if nodeName == "" {
+		return nil, fmt.Errorf("failed to determine node name")
+	}
+	// Before bootstrap kubelet has no credentials to read the node with
+	if !utils.FileExists(kubelet.KubeletKubeconfigPath) {
+		return nil, nil
+	}
+
+	output, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubelet.KubeletKubeconfigPath,
+		"get", "node", nodeName, "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+	clusterNode, err := parseClusterNode([]byte(output))
+	if err != nil {
+		return nil, err
+	}
+
+	// Kubelet renews its lease every 10 seconds, far more often than it updates the node conditions
+	renewTime, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubelet.KubeletKubeconfigPath,
+		"get", "lease", nodeName, "-n", nodeLeaseNamespace, "-o", "jsonpath={.spec.renewTime}")
+	if err != nil {
+		c.logger.Debugf("Failed to get node lease of %s: %v", nodeName, err)
+	} else if heartbeat, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(renewTime)); err == nil {
+		clusterNode.LastHeartbeat = &heartbeat
+	}
+	return clusterNode, nil
+}
+
+// parseClusterNode extracts the reported fields from a node object in JSON
+func parseClusterNode(data []byte) (*ClusterNodeStatus, error) {
+	var node nodeObject
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse node object: %w", err)
+	}
+
+	clusterNode := &ClusterNodeStatus{
+		Conditions:    node.Status.Conditions,
+		Unschedulable: node.Spec.Unschedulable,
+	}
+	for _, taint := range node.Spec.Taints {
+		key := taint.Key
+		if taint.Value != "" {
+			key += "=" + taint.Value
+		}
+		clusterNode.Taints = append(clusterNode.Taints, key+":"+taint.Effect)
+	}
+	return clusterNode, nil
+}
+
+// readiness returns Ready, NotReady or Unknown from the Ready condition
+func (s *ClusterNodeStatus) readiness() string {
+	if s == nil {
+		return "Unknown"
+	}
+	for _, condition := range s.Conditions {
+		if condition.Type != "Ready" {
+			continue
+		}
+		switch condition.Status {
+		case "True":
+			return "Ready"
+		case "False":
+			return "NotReady"
+		}
+	}
+	return "Unknown"
+}
+
+// readySince returns when the node last became Ready, false when it is not Ready
+func (s *ClusterNodeStatus) readySince() (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	for _, condition := range s.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" {
+			return condition.LastTransitionTime, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,50 @@
+package status
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseClusterNode(t *testing.T) {
+	node := []byte(`{
+  "kind": "Node",
+  "spec": {
+    "unschedulable": true,
+    "taints": [
+      {"key": "node.kubernetes.io/unschedulable", "effect": "NoSchedule"},
+      {"key": "edge.example.com/site", "value": "store-42", "effect": "NoExecute"}
+    ]
+  },
+  "status": {
+    "conditions": [
+      {"type": "MemoryPressure", "status": "False", "reason": "KubeletHasSufficientMemory",
+       "lastHeartbeatTime": "2026-03-01T10:00:00Z", "lastTransitionTime": "2026-02-28T08:00:00Z"},
+      {"type": "Ready", "status": "False", "reason": "KubeletNotReady", "message": "container runtime network not ready",
+       "lastHeartbeatTime": "2026-03-01T10:00:00Z", "lastTransitionTime": "2026-03-01T09:55:00Z"}
+    ]
+  }
+}`)
+
+	clusterNode, err := parseClusterNode(node)
+	if err != nil {
+		t.Fatalf("parseClusterNode() unexpected error: %v", err)
+	}
+	if !clusterNode.Unschedulable {
+		t.Error("Unschedulable = false, want true")
+	}
+	wantTaints := []string{"node.kubernetes.io/unschedulable:NoSchedule", "edge.example.com/site=store-42:NoExecute"}
+	if !slices.Equal(clusterNode.Taints, wantTaints) {
+		t.Errorf("Taints = %v, want %v", clusterNode.Taints, wantTaints)
+	}
+	if len(clusterNode.Conditions) != 2 || clusterNode.Conditions[1].Message != "container runtime network not ready" {
+		t.Errorf("Conditions = %+v, want both conditions with their messages", clusterNode.Conditions)
+	}
+	if got := clusterNode.readiness(); got != "NotReady" {
+		t.Errorf("readiness() = %s, want NotReady", got)
+	}
+
+	var missing *ClusterNodeStatus
+	if got := missing.readiness(); got != "Unknown" {
+		t.Errorf("readiness() of a missing cluster view = %s, want Unknown", got)
+	}
+}
@@ -6,59 +6,156 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroupdriver"
+	"go.goms.io/aks/AKSFlexNode/pkg/clusterca"
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
 	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
 	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/discovery"
+	"go.goms.io/aks/AKSFlexNode/pkg/ebpf"
+	"go.goms.io/aks/AKSFlexNode/pkg/healthcheck"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/readiness"
+	"go.goms.io/aks/AKSFlexNode/pkg/sitetags"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/timesync"
+	"go.goms.io/aks/AKSFlexNode/pkg/update"
 	"go.goms.io/aks/AKSFlexNode/pkg/utils"
 )
 
 // Collector collects system and node status information
 type Collector struct {
-	config       *config.Config
-	logger       *logrus.Logger
-	agentVersion string
+	config *config.Config
+	logger *logrus.Logger
 }
 
 // NewCollector creates a new status collector
-func NewCollector(cfg *config.Config, logger *logrus.Logger, agentVersion string) *Collector {
+func NewCollector(cfg *config.Config, logger *logrus.Logger) *Collector {
 	return &Collector{
-		config:       cfg,
-		logger:       logger,
-		agentVersion: agentVersion,
+		config: cfg,
+		logger: logger,
 	}
 }
 
 // CollectStatus collects essential node status information
 func (c *Collector) CollectStatus(ctx context.Context) (*NodeStatus, error) {
+	build := buildinfo.Get()
 	status := &NodeStatus{
-		LastUpdated:  time.Now(),
-		AgentVersion: c.agentVersion,
+		SchemaVersion: SchemaVersion,
+		LastUpdated:   time.Now(),
+		AgentVersion:  build.Version,
+		AgentBuild:    build,
 	}
 
 	// Get kubelet related status
 	status.KubeletVersion = c.getKubeletVersion(ctx)
 	status.KubeletRunning = utils.IsServiceActive("kubelet")
-	status.KubeletReady = c.isKubeletReady(ctx)
+	// The node object and lease show the node as the cluster sees it, including readiness
+	clusterNode, err := c.collectClusterNode(ctx)
+	if err != nil {
+		c.logger.Warnf("Failed to collect the cluster view of the node: %v", err)
+	}
+	status.ClusterNode = clusterNode
+	status.KubeletReady = clusterNode.readiness()
+	status.BootReadiness = c.observeReadiness(clusterNode)
+	status.ClusterCA = c.verifyClusterCA(ctx)
+	status.KubeletKeyProtection = kubelet.KeyProtectionLevel()
+	certificates, err := kubelet.ReadCertificates(time.Now())
+	if err != nil {
+		c.logger.Warnf("Failed to read kubelet certificates: %v", err)
+	}
+	status.KubeletCertificates = certificates
+	if c.config != nil && c.config.Node.Kubelet.ServerTLSBootstrap {
+		pending, err := kubelet.PendingServingCSRs(ctx, c.config.GetNodeName())
+		if err != nil {
+			c.logger.Warnf("Failed to list kubelet serving certificate requests: %v", err)
+		}
+		status.PendingServingCSRs = pending
+	}
 
 	// get containerd related status
 	status.ContainerdVersion = c.getContainerdVersion(ctx)
 	// check if containerd is running, it will cause kubelet not ready
 	status.ContainerdRunning = utils.IsServiceActive("containerd")
+	status.CgroupDrivers = cgroupdriver.Detect()
+	status.EBPF = ebpf.Detect("/")
+	status.Hardware = discovery.Detect("/")
+
+	// A skewed clock makes token and certificate validation fail intermittently
+	maxDrift := config.DefaultTimeSyncMaxDrift
+	if c.config != nil {
+		maxDrift = c.config.GetTimeSyncMaxDrift()
+	}
+	status.TimeSync = timesync.Detect(ctx, maxDrift)
+	if status.TimeSync.Warning != "" {
+		c.logger.Warnf("Clock synchronization: %s", status.TimeSync.Warning)
+	}
 
 	// Get runc version
 	status.RuncVersion = c.getRuncVersion(ctx)
 
 	// Collect Arc status
-	arcStatus, err := c.collectArcStatus(ctx)
+	arcStatus, err := c.CollectArcStatus(ctx)
 	if err != nil {
 		c.logger.Warnf("Failed to collect Arc status: %v", err)
 	}
 	status.ArcStatus = arcStatus
 
+	// Collect component installation provenance
+	records, err := provenance.LoadAll()
+	if err != nil {
+		c.logger.Warnf("Failed to load component provenance: %v", err)
+	}
+	status.Provenance = records
+
+	// Surface the last compatibility check so fleet-wide version skew is visible
+	report, err := compat.LoadReport()
+	if err != nil {
+		c.logger.Warnf("Failed to load compatibility report: %v", err)
+	}
+	status.Compatibility = report
+
+	// Surface consecutive bootstrap failures and whether the agent keeps retrying them
+	bootstrapFailure, err := LoadBootstrapFailure()
+	if err != nil {
+		c.logger.Warnf("Failed to load bootstrap failure record: %v", err)
+	}
+	status.BootstrapFailure = bootstrapFailure
+
+	// Surface whether a newer agent is published on the configured release channel
+	updateResult, err := update.LoadResult()
+	if err != nil {
+		c.logger.Warnf("Failed to load update check result: %v", err)
+	}
+	status.AgentUpdate = updateResult
+
+	// Surface the site settings inherited from the Arc machine tags
+	if c.config != nil && c.config.IsSiteTagsEnabled() {
+		siteTags, err := sitetags.Load()
+		if err != nil {
+			c.logger.Warnf("Failed to load site settings: %v", err)
+		}
+		status.SiteTags = siteTags
+	}
+	if c.config != nil {
+		status.Profile = c.config.Profile()
+	}
+
+	// Run site-specific health checks
+	if c.config != nil && len(c.config.HealthChecks) > 0 {
+		status.HealthChecks = healthcheck.Run(ctx, c.config.HealthChecks)
+		for _, result := range status.HealthChecks {
+			if !result.Healthy {
+				c.logger.Warnf("Health check %s failed: %s", result.Name, result.Message)
+			}
+		}
+	}
+
 	return status, nil
 }
 
@@ -120,8 +217,8 @@ func (c *Collector) getRuncVersion(ctx context.Context) string {
 	return "unknown"
 }
 
-// collectArcStatus gathers Azure Arc machine registration and connection status
-func (c *Collector) collectArcStatus(ctx context.Context) (ArcStatus, error) {
+// CollectArcStatus gathers Azure Arc machine registration and connection status
+func (c *Collector) CollectArcStatus(ctx context.Context) (ArcStatus, error) {
 	status := ArcStatus{}
 
 	// Try to get comprehensive Arc status from azcmagent show
@@ -192,46 +289,13 @@ func (c *Collector) parseArcShowOutput(status *ArcStatus, output string) {
 	}
 }
 
-// isKubeletReady checks if the kubelet reports the node as Ready
-func (c *Collector) isKubeletReady(ctx context.Context) string {
-	hostName, err := os.Hostname()
-	if err != nil {
-		c.logger.Warnf("Failed to get hostname: %v", err)
-		return "Unknown"
-	}
-
-	// Readiness condition status is one of: True, False, Unknown
-	args := []string{
-		"--kubeconfig",
-		kubelet.KubeletKubeconfigPath,
-		"get",
-		"node",
-		hostName,
-		"-o",
-		"jsonpath={.status.conditions[?(@.type==\"Ready\")].status}",
-	}
-
-	output, err := utils.RunCommandWithOutput("kubectl", args...)
-	if err != nil {
-		// Common in dev: agent runs as ubuntu and can't read root:aks-flex-node 0640 kubeconfig.
-		// Retry with sudo (non-interactive) if we see a permissions failure.
-		c.logger.Errorf("kubectl command failed: %v with output: %s", err, output)
-		return "Unknown"
-	}
-
-	switch strings.TrimSpace(output) {
-	case "True":
-		return "Ready"
-	case "False":
-		return "NotReady"
-	default:
-		return "Unknown"
-	}
-}
-
 // NeedsBootstrap checks if the node needs to be (re)bootstrapped based on status file
 func (c *Collector) NeedsBootstrap(ctx context.Context) bool {
-	statusFilePath := GetStatusFilePath()
+	return c.needsBootstrap(ctx, GetStatusFilePath())
+}
+
+// needsBootstrap checks the status file at statusFilePath
+func (c *Collector) needsBootstrap(ctx context.Context, statusFilePath string) bool {
 	// Try to read the status file
 	statusData, err := os.ReadFile(statusFilePath)
 	if err != nil {
@@ -260,7 +324,7 @@ func (c *Collector) NeedsBootstrap(ctx context.Context) bool {
 	}
 
 	// Check if status is too old (older than 5 minutes might indicate daemon issues)
-	if time.Since(nodeStatus.LastUpdated) > 5*time.Minute {
+	if nodeStatus.IsStale(time.Now()) {
 		c.logger.Info("Status file is stale (older than 5 minutes) - bootstrap needed")
 		return true
 	}
@@ -276,22 +340,97 @@ func (c *Collector) NeedsBootstrap(ctx context.Context) bool {
 		return true
 	}
 
+	// Kubelet falls back to TLS bootstrap when it restarts with an expired client certificate
+	if certs := nodeStatus.KubeletCertificates; certs != nil && certs.Client != nil && certs.Client.Expired {
+		c.logger.Infof("Status file indicates the kubelet client certificate expired at %s - bootstrap needed",
+			certs.Client.NotAfter.Format(time.RFC3339))
+		return true
+	}
+
+	// Check for failing health checks configured to trigger remediation
+	if failing := healthcheck.Failing(nodeStatus.HealthChecks, config.HealthCheckActionRemediate); len(failing) > 0 {
+		c.logger.Infof("Status file indicates health check %s failing - bootstrap needed", failing[0].Name)
+		return true
+	}
+
+	// With kubernetes.version auto kubelet follows the cluster's Kubernetes version
+	if version := c.clusterKubeletVersion(ctx); version != "" && version != nodeStatus.KubeletVersion {
+		c.logger.Infof("Cluster runs Kubernetes %s but kubelet is %s - bootstrap needed", version, nodeStatus.KubeletVersion)
+		return true
+	}
+
 	c.logger.Debug("Status file indicates healthy state - no bootstrap needed")
 	return false
 }
 
+// verifyClusterCA verifies the API server certificate chain against the cluster CA cached at bootstrap.
+// It returns nil before bootstrap has cached a CA.
+func (c *Collector) verifyClusterCA(ctx context.Context) *clusterca.Status {
+	cache, err := clusterca.Load()
+	if err != nil {
+		c.logger.Warnf("Failed to load cached cluster CA: %v", err)
+		return nil
+	}
+	if cache == nil {
+		return nil
+	}
+	result := clusterca.Verify(ctx, cache)
+	if !result.Verified {
+		c.logger.Warnf("Cluster CA check failed, the cluster CA was rotated or the API server connection is intercepted: %s", result.Message)
+	}
+	return result
+}
+
+// observeReadiness completes the boot-to-Ready timeline once the node is Ready and returns the current boot's timeline
+func (c *Collector) observeReadiness(clusterNode *ClusterNodeStatus) *readiness.Boot {
+	readySince, ready := clusterNode.readySince()
+	if !ready {
+		boot, err := readiness.Current()
+		if err != nil {
+			c.logger.Debugf("Failed to load the boot readiness timeline: %v", err)
+		}
+		return boot
+	}
+	boot, err := readiness.ObserveReady(readySince)
+	if err != nil {
+		c.logger.Warnf("Failed to record the boot readiness timeline: %v", err)
+		return nil
+	}
+	return boot
+}
+
+// clusterKubeletVersion returns the kubelet version selected from the cluster's current Kubernetes version,
+// empty when kubernetes.version is pinned, the daemon upgrades the node with kubernetes.autoUpgrade instead of
+// re-bootstrapping it, or the cluster spec is not available
+func (c *Collector) clusterKubeletVersion(ctx context.Context) string {
+	if c.config == nil || !c.config.IsKubernetesVersionAuto() || c.config.IsAutoUpgradeEnabled() {
+		return ""
+	}
+	// The spec is cached, so this reaches Azure at most once per cache period
+	clusterSpec, err := spec.NewCollector(c.logger).Collect(ctx)
+	if err != nil {
+		c.logger.Debugf("Failed to fetch managed cluster spec to check the kubelet version: %v", err)
+		return ""
+	}
+	version, err := compat.SelectKubeletVersion(clusterSpec.KubernetesVersion)
+	if err != nil {
+		c.logger.Debugf("Failed to select the kubelet version: %v", err)
+		return ""
+	}
+	return version
+}
+
 // GetStatusFilePath returns the appropriate status directory path
 // Uses /run/aks-flex-node/status.json when running as aks-flex-node user (systemd service)
 // Uses /tmp/aks-flex-node/status.json for direct user execution (testing/development)
 func GetStatusFilePath() string {
 	// Running as regular user (testing/development) - use temp directory
-	statusDir := "/tmp/aks-flex-node"
+	statusFilePath := "/tmp/aks-flex-node/status.json"
 	// Check if we're running as the aks-flex-node service user
 	currentUser, err := user.Current()
 	if err == nil && currentUser.Username == "aks-flex-node" {
 		// Running as systemd service user - use runtime directory for status files
-		statusDir = "/run/aks-flex-node"
+		statusFilePath = ServiceStatusFilePath
 	}
-
-	return filepath.Join(statusDir, "status.json")
+	return statusFilePath
 }
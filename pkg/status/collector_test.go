@@ -0,0 +1,79 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+)
+
+func TestNeedsBootstrapFollowsClusterVersion(t *testing.T) {
+	dir := t.TempDir()
+	const clusterID = "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"
+	previousCache := spec.CacheFilePath
+	spec.CacheFilePath = filepath.Join(dir, "cluster-spec.json")
+	t.Cleanup(func() { spec.CacheFilePath = previousCache })
+	cache := `{"clusterId": "` + clusterID + `", "fetchedAt": "` + time.Now().UTC().Format(time.RFC3339) + `",
+		"spec": {"name": "test-cluster", "kubernetesVersion": "1.32.7"}}`
+	if err := os.WriteFile(spec.CacheFilePath, []byte(cache), 0o600); err != nil {
+		t.Fatalf("failed to write cluster spec cache: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	tests := []struct {
+		name           string
+		version        string
+		autoUpgrade    string
+		kubeletVersion string
+		want           bool
+	}{
+		{name: "auto on the cluster's version", version: "auto", kubeletVersion: "1.32.7", want: false},
+		{name: "auto behind the cluster", version: "auto", kubeletVersion: "1.31.2", want: true},
+		{name: "omitted behind the cluster", version: "", kubeletVersion: "1.31.2", want: true},
+		{name: "auto upgraded in place", version: "auto", autoUpgrade: "patch", kubeletVersion: "1.31.2", want: false},
+		{name: "pinned", version: "1.31.2", kubeletVersion: "1.31.2", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.json")
+			configJSON := `{"azure": {"subscriptionId": "12345678-1234-1234-1234-123456789012", "tenantId": "12345678-1234-1234-1234-123456789012",
+				"cloud": "AzurePublicCloud", "targetCluster": {"location": "eastus", "resourceId": "` + clusterID + `"}},
+				"kubernetes": {"version": "` + tt.version + `", "autoUpgrade": "` + tt.autoUpgrade + `"}}`
+			if err := os.WriteFile(configFile, []byte(configJSON), 0o644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			cfg, err := config.LoadConfig(configFile)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			statusFile := filepath.Join(t.TempDir(), "status.json")
+			data, err := json.Marshal(&NodeStatus{
+				KubeletVersion: tt.kubeletVersion,
+				RuncVersion:    "1.2.5",
+				KubeletRunning: true,
+				ArcStatus:      ArcStatus{Connected: true},
+				LastUpdated:    time.Now(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(statusFile, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := NewCollector(cfg, logger).needsBootstrap(context.Background(), statusFile); got != tt.want {
+				t.Errorf("needsBootstrap() = %v, want %v for kubelet %s on a cluster running 1.32.7", got, tt.want, tt.kubeletVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+)
+
+// Progress phases reported while the agent runs bootstrap or unbootstrap
+const (
+	ProgressRunning   = "running"
+	ProgressSucceeded = "succeeded"
+	ProgressFailed    = "failed"
+)
+
+// Progress describes the state of an in-flight bootstrap or unbootstrap so that external
+// provisioning tooling can poll it and apply its own timeouts
+type Progress struct {
+	Operation     string        `json:"operation"`             // bootstrap or unbootstrap
+	Phase         string        `json:"phase"`                 // running, succeeded or failed
+	CurrentStep   string        `json:"currentStep,omitempty"` // steps running at once are comma-separated
+	StepIndex     int           `json:"stepIndex"`             // 1-based index of the current step
+	TotalSteps    int           `json:"totalSteps"`
+	StartedAt     time.Time     `json:"startedAt"`
+	StepStartedAt time.Time     `json:"stepStartedAt,omitempty"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+	LastError     string        `json:"lastError,omitempty"`
+	FailureClass  failure.Class `json:"failureClass,omitempty"` // transient, permanent or unknown, set with lastError
+}
+
+// GetProgressFilePath returns the progress file path, located next to the status file
+func GetProgressFilePath() string {
+	return filepath.Join(filepath.Dir(GetStatusFilePath()), "progress.json")
+}
+
+// WriteProgress atomically writes the progress to the given path, stamping its update time
+func WriteProgress(path string, progress *Progress) error {
+	progress.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create progress directory: %w", err)
+	}
+
+	// Write to temporary file first, then rename so pollers never observe a partial file
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write progress to temp file: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename temp progress file: %w", err)
+	}
+	return nil
+}
+
+// ReadProgress reads the progress from the given path
+func ReadProgress(path string) (*Progress, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress file %s: %w", path, err)
+	}
+	progress := &Progress{}
+	if err := json.Unmarshal(data, progress); err != nil {
+		return nil, fmt.Errorf("failed to parse progress file %s: %w", path, err)
+	}
+	return progress, nil
+}
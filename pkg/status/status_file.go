@@ -0,0 +1,45 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ServiceStatusFilePath is the status file written by the agent service
+const ServiceStatusFilePath = "/run/aks-flex-node/status.json"
+
+// StaleAfter is how old a status file may get before the daemon writing it is considered stopped or stuck.
+// The daemon rewrites it every minute.
+const StaleAfter = 5 * time.Minute
+
+// FindStatusFile returns the status file of the current user, or the one of the agent service when only
+// that exists, so operators running the CLI as another user still find the daemon's status
+func FindStatusFile() string {
+	path := GetStatusFilePath()
+	if _, err := os.Stat(path); err != nil {
+		if _, err := os.Stat(ServiceStatusFilePath); err == nil {
+			return ServiceStatusFilePath
+		}
+	}
+	return path
+}
+
+// ReadStatus reads the node status from the given path
+func ReadStatus(path string) (*NodeStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status file %s: %w", path, err)
+	}
+	nodeStatus := &NodeStatus{}
+	if err := json.Unmarshal(data, nodeStatus); err != nil {
+		return nil, fmt.Errorf("failed to parse status file %s: %w", path, err)
+	}
+	return nodeStatus, nil
+}
+
+// IsStale reports whether the status was last updated more than StaleAfter before now
+func (s *NodeStatus) IsStale(now time.Time) bool {
+	return now.Sub(s.LastUpdated) > StaleAfter
+}
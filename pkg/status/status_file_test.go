@@ -0,0 +1,35 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	if err := os.WriteFile(path, []byte(`{"kubeletRunning": true, "kubeletReady": "True", "lastUpdated": "2026-03-01T10:00:00Z"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeStatus, err := ReadStatus(path)
+	if err != nil {
+		t.Fatalf("ReadStatus() unexpected error: %v", err)
+	}
+	if !nodeStatus.KubeletRunning || nodeStatus.KubeletReady != "True" {
+		t.Errorf("ReadStatus() = %+v, want a running and ready kubelet", nodeStatus)
+	}
+
+	updated := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	if nodeStatus.IsStale(updated.Add(time.Minute)) {
+		t.Error("IsStale() = true for a status updated a minute ago")
+	}
+	if !nodeStatus.IsStale(updated.Add(StaleAfter + time.Second)) {
+		t.Errorf("IsStale() = false for a status older than %s", StaleAfter)
+	}
+
+	if _, err := ReadStatus(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("ReadStatus() of a missing file succeeded")
+	}
+}
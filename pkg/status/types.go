@@ -2,8 +2,26 @@ package status
 
 import (
 	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/cgroupdriver"
+	"go.goms.io/aks/AKSFlexNode/pkg/clusterca"
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/discovery"
+	"go.goms.io/aks/AKSFlexNode/pkg/ebpf"
+	"go.goms.io/aks/AKSFlexNode/pkg/healthcheck"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/readiness"
+	"go.goms.io/aks/AKSFlexNode/pkg/sitetags"
+	"go.goms.io/aks/AKSFlexNode/pkg/timesync"
+	"go.goms.io/aks/AKSFlexNode/pkg/update"
 )
 
+// SchemaVersion is the version of the NodeStatus layout, bumped on incompatible changes
+// so consumers aggregating status across a fleet of mixed agent versions can parse each file
+const SchemaVersion = 1
+
 // NodeStatus represents the current status and health information of the AKS edge node
 type NodeStatus struct {
 	// Component versions
@@ -17,12 +35,65 @@ type NodeStatus struct {
 
 	ContainerdRunning bool `json:"containerdRunning"`
 
+	// Cgroup drivers in the live kubelet and containerd configurations, which must match
+	CgroupDrivers *cgroupdriver.Report `json:"cgroupDrivers,omitempty"`
+
+	// Kernel capabilities eBPF-based components such as Cilium, Hubble or eBPF monitoring agents rely on
+	EBPF *ebpf.Report `json:"ebpf,omitempty"`
+
+	// Clock synchronization, with a warning when the clock drifted far enough to make TLS authentication fail
+	TimeSync *timesync.Status `json:"timeSync,omitempty"`
+
+	// Hardware the node's hardware profile label is derived from
+	Hardware *discovery.Profile `json:"hardware,omitempty"`
+
+	// The node as the cluster API server sees it, when the API server is reachable
+	ClusterNode *ClusterNodeStatus `json:"clusterNode,omitempty"`
+
+	// API server certificate chain verified against the cluster CA cached at bootstrap
+	ClusterCA *clusterca.Status `json:"clusterCA,omitempty"`
+
+	// Timeline from the host boot to the node first reporting Ready in this boot
+	BootReadiness *readiness.Boot `json:"bootReadiness,omitempty"`
+
+	// How the kubelet client key is protected at rest: tpm or file
+	KubeletKeyProtection string `json:"kubeletKeyProtection,omitempty"`
+
+	// Validity of the kubelet client and serving certificates
+	KubeletCertificates *kubelet.Certificates `json:"kubeletCertificates,omitempty"`
+
+	// Kubelet serving certificate requests waiting for approval, with node.kubelet.serverTLSBootstrap
+	PendingServingCSRs []string `json:"pendingServingCSRs,omitempty"`
+
 	// Azure Arc status
 	ArcStatus ArcStatus `json:"arcStatus"`
 
+	// Installation provenance of downloaded components
+	Provenance []provenance.Record `json:"provenance,omitempty"`
+
+	// Consecutive bootstrap failures and the class of the last one, absent after a successful bootstrap
+	BootstrapFailure *BootstrapFailure `json:"bootstrapFailure,omitempty"`
+
+	// Version skew against the target cluster from the last compatibility check
+	Compatibility *compat.Report `json:"compatibility,omitempty"`
+
+	// Agent version published on the configured release channel, from the last update check
+	AgentUpdate *update.Result `json:"agentUpdate,omitempty"`
+
+	// Site settings inherited from the Arc machine tags
+	SiteTags *sitetags.Overrides `json:"siteTags,omitempty"`
+
+	// Node pool profile of the configuration merged over the node settings
+	Profile string `json:"profile,omitempty"`
+
+	// Results of the operator-defined health checks
+	HealthChecks []healthcheck.Result `json:"healthChecks,omitempty"`
+
 	// Metadata
-	LastUpdated  time.Time `json:"lastUpdated"`
-	AgentVersion string    `json:"agentVersion"`
+	SchemaVersion int            `json:"schemaVersion"`
+	LastUpdated   time.Time      `json:"lastUpdated"`
+	AgentVersion  string         `json:"agentVersion"`
+	AgentBuild    buildinfo.Info `json:"agentBuild"`
 }
 
 // ArcStatus contains Azure Arc machine registration and connection status
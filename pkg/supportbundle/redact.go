@@ -0,0 +1,95 @@
+package supportbundle
+
+import (
+	"regexp"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// redactedValue replaces secrets in the collected files and command output
+const redactedValue = "***REDACTED***"
+
+// minSecretLength is the length below which configured secrets are not replaced wherever they appear, as
+// short values would redact unrelated text
+const minSecretLength = 6
+
+var (
+	// sensitiveSettingPattern matches settings whose value is a secret in JSON, YAML, TOML and environment files,
+	// e.g. "clientSecret": "...", password = "..." or ARM_CLIENT_SECRET=...
+	sensitiveSettingPattern = regexp.MustCompile(`(?i)(["']?[a-z0-9_.-]*(secret|password|passwd|token|auth|key-data|keydata)[a-z0-9_.-]*["']?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,{\[]+)`)
+	// referenceSettingPattern matches setting names that name where a secret is instead of holding it
+	referenceSettingPattern = regexp.MustCompile(`(?i)(file|path|dir|ref|reference|type|mode|enabled)["']?\s*[:=]\s*$`)
+	// bearerTokenPattern matches bearer authorization headers and JSON web tokens
+	bearerTokenPattern = regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9._~+/=-]+|eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// urlPasswordPattern matches the password of URLs with user information
+	urlPasswordPattern = regexp.MustCompile(`(://[^/\s:@"']+:)[^/\s@"']+@`)
+	// privateKeyPattern matches PEM private keys
+	privateKeyPattern = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// Redactor replaces secrets in text: the secrets of the configuration wherever they appear, settings named like
+// secrets, bearer tokens, URL passwords and private keys
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor creates a Redactor that also replaces the resolved secrets of cfg
+func NewRedactor(cfg *config.Config) *Redactor {
+	r := &Redactor{}
+	if cfg == nil {
+		return r
+	}
+	if sp := cfg.Azure.ServicePrincipal; sp != nil {
+		r.add(sp.ClientSecret)
+	}
+	for _, registry := range cfg.Containerd.Registries {
+		r.add(registry.Password)
+	}
+	return r
+}
+
+// add replaces secret wherever it appears
+func (r *Redactor) add(secret string) {
+	if len(secret) >= minSecretLength {
+		r.secrets = append(r.secrets, secret)
+	}
+}
+
+// Redact returns data with the secrets replaced
+func (r *Redactor) Redact(data []byte) []byte {
+	text := string(data)
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+	text = privateKeyPattern.ReplaceAllString(text, redactedValue)
+	text = urlPasswordPattern.ReplaceAllString(text, "${1}"+redactedValue+"@")
+	text = bearerTokenPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if prefix := bearerTokenPattern.FindStringSubmatch(match)[1]; prefix != "" {
+			return prefix + redactedValue
+		}
+		return redactedValue
+	})
+	text = sensitiveSettingPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := sensitiveSettingPattern.FindStringSubmatch(match)
+		setting, value := groups[1], groups[3]
+		if referenceSettingPattern.MatchString(setting) || !isSecretValue(strings.Trim(value, `"'`)) {
+			return match
+		}
+		if quote := value[0]; quote == '"' || quote == '\'' {
+			return setting + string(quote) + redactedValue + string(quote)
+		}
+		return setting + redactedValue
+	})
+	return []byte(text)
+}
+
+// isSecretValue returns false for values of secret settings that cannot hold a secret: empty, boolean and null
+// values, secret references and values redacted before
+func isSecretValue(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "true", "false", "null":
+		return false
+	}
+	return value != redactedValue && !strings.HasPrefix(value, "${")
+}
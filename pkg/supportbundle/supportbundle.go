@@ -0,0 +1,303 @@
+// Package supportbundle collects the logs, configuration and state needed to diagnose a node into a single
+// archive that can be attached to a support ticket. Secrets are redacted before anything is written.
+package supportbundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/auth"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/platform"
+	"go.goms.io/aks/AKSFlexNode/pkg/provenance"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+const (
+	// maxFileSize is the size above which only the end of a file is collected, as the end of a log is what
+	// describes the failure
+	maxFileSize = 32 << 20
+	// commandTimeout bounds every command collected
+	commandTimeout = 2 * time.Minute
+	// errorsFileName is the bundle file listing what could not be collected
+	errorsFileName = "collection-errors.txt"
+)
+
+// journalUnits are the services whose journal is collected: the agent, the node components and the Arc agent
+var journalUnits = []string{"aks-flex-node-agent", "kubelet", "containerd", "kube-proxy", "node-problem-detector", "himdsd"}
+
+// statusUnits are the services whose systemctl status is collected
+var statusUnits = []string{"kubelet", "containerd", "node-problem-detector"}
+
+// excludedStateFiles are the files of the agent state directory left out of the bundle as they hold credentials
+var excludedStateFiles = map[string]bool{
+	filepath.Base(auth.OnboardingStateFilePath): true,
+}
+
+// Options control what is collected and where the bundle is written
+type Options struct {
+	OutputDir string        // Directory the bundle is written to
+	Since     time.Duration // How far back journals are collected
+}
+
+// Collect writes a support bundle of the node to a timestamped tar.gz in the output directory and returns its
+// path. Items that cannot be collected are listed in the bundle instead of failing the collection.
+func Collect(ctx context.Context, cfg *config.Config, opts Options, logger *logrus.Logger) (string, error) {
+	now := time.Now().UTC()
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	name := fmt.Sprintf("aks-flex-node-support-%s-%s", hostname, now.Format("20060102T150405Z"))
+	bundlePath := filepath.Join(opts.OutputDir, name+".tar.gz")
+
+	// The bundle describes the host in detail, so only its owner may read it
+	file, err := os.OpenFile(bundlePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create support bundle: %w", err)
+	}
+	b := newBundle(file, name, NewRedactor(cfg), now, logger)
+	b.collect(ctx, cfg, opts)
+	if err := b.close(); err != nil {
+		_ = file.Close()
+		_ = os.Remove(bundlePath)
+		return "", fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(bundlePath)
+		return "", fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	if len(b.errors) > 0 {
+		logger.Warnf("%d item(s) could not be collected, see %s in the bundle", len(b.errors), errorsFileName)
+	}
+	return bundlePath, nil
+}
+
+// bundle writes redacted entries to a tar.gz below a top-level directory
+type bundle struct {
+	gzip     *gzip.Writer
+	tar      *tar.Writer
+	root     string
+	redactor *Redactor
+	modTime  time.Time
+	logger   *logrus.Logger
+	errors   []string
+}
+
+func newBundle(w io.Writer, root string, redactor *Redactor, modTime time.Time, logger *logrus.Logger) *bundle {
+	gz := gzip.NewWriter(w)
+	return &bundle{
+		gzip:     gz,
+		tar:      tar.NewWriter(gz),
+		root:     root,
+		redactor: redactor,
+		modTime:  modTime,
+		logger:   logger,
+	}
+}
+
+// collect adds everything the support bundle holds on the current platform
+func (b *bundle) collect(ctx context.Context, cfg *config.Config, opts Options) {
+	host := platform.Current()
+
+	b.addData("agent/version.txt", func() ([]byte, error) {
+		return []byte(buildinfo.Get().String() + "\n"), nil
+	})
+	b.addData("agent/config.json", func() ([]byte, error) {
+		redacted, err := cfg.Redacted()
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(redacted, "", "  ")
+	})
+	b.addDir("agent/status", filepath.Dir(status.FindStatusFile()), false, nil)
+	b.addDir("agent/state", config.AgentStateDir, false, excludedStateFiles)
+	b.addDir("agent/state/provenance", provenance.Dir, false, nil)
+	b.addDir("agent/logs", cfg.Agent.LogDir, true, nil)
+
+	b.addFile("containerd/config.toml", host.Paths.ContainerdConfig)
+	b.addFile("kubelet/config.yaml", filepath.Join(host.Paths.KubeletDir, "config.yaml"))
+	if host.IsWindows() {
+		return
+	}
+	b.addDir("containerd/certs.d", "/etc/containerd/certs.d", true, nil)
+	b.addFile("kubelet/kubelet", "/etc/default/kubelet")
+	b.addDir("kubelet/kubelet.service.d", "/etc/systemd/system/kubelet.service.d", false, nil)
+
+	since := time.Now().Add(-opts.Since).Format("2006-01-02 15:04:05")
+	for _, unit := range journalUnits {
+		b.addCommand(ctx, "journal/"+unit+".log", "journalctl", "--no-pager", "-u", unit, "--since", since)
+	}
+	b.addCommand(ctx, "journal/kernel.log", "journalctl", "--no-pager", "-k", "--since", since)
+	for _, unit := range statusUnits {
+		b.addCommand(ctx, "services/"+unit+".txt", "systemctl", "status", unit)
+	}
+	b.addCommand(ctx, "arc/azcmagent-show.txt", "azcmagent", "show")
+}
+
+// addData adds the data collect returns as name
+func (b *bundle) addData(name string, collect func() ([]byte, error)) {
+	data, err := collect()
+	if err != nil {
+		b.failed(name, err)
+		return
+	}
+	b.write(name, data)
+}
+
+// addFile adds the file at filePath as name, keeping the end of files larger than maxFileSize
+func (b *bundle) addFile(name, filePath string) {
+	data, err := readFile(filePath)
+	if err != nil {
+		b.failed(name, err)
+		return
+	}
+	b.write(name, data)
+}
+
+// addDir adds the regular files of dir below prefix, descending into subdirectories when recursive. Files whose
+// names are in excluded are left out.
+func (b *bundle) addDir(prefix, dir string, recursive bool, excluded map[string]bool) {
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == dir {
+				return err
+			}
+			b.failed(path.Join(prefix, filepath.ToSlash(strings.TrimPrefix(filePath, dir))), err)
+			return nil
+		}
+		if entry.IsDir() {
+			if filePath != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Sockets and other special files have nothing to read
+		if !entry.Type().IsRegular() || excluded[entry.Name()] {
+			return nil
+		}
+		relative, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		b.addFile(path.Join(prefix, filepath.ToSlash(relative)), filePath)
+		return nil
+	})
+	if err != nil {
+		b.failed(prefix, err)
+	}
+}
+
+// addCommand adds the output of a command as name, keeping the end of output larger than maxFileSize. The output
+// of a failing command is kept, as commands such as systemctl status fail exactly when their output matters.
+func (b *bundle) addCommand(ctx context.Context, name, command string, args ...string) {
+	commandCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	output, err := sysutil.RunCommandContext(commandCtx, command, args...)
+	if err != nil {
+		b.failed(name, fmt.Errorf("%s %s: %w", command, strings.Join(args, " "), err))
+	}
+	if output != "" {
+		b.write(name, keepTail([]byte(output)))
+	}
+}
+
+// failed records that name could not be collected
+func (b *bundle) failed(name string, err error) {
+	b.logger.Debugf("Support bundle: failed to collect %s: %v", name, err)
+	b.errors = append(b.errors, fmt.Sprintf("%s: %v", name, err))
+}
+
+// write adds data with secrets redacted as name. Write errors surface when the bundle is closed.
+func (b *bundle) write(name string, data []byte) {
+	data = b.redactor.Redact(data)
+	header := &tar.Header{
+		Name:    path.Join(b.root, name),
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: b.modTime,
+	}
+	if err := b.tar.WriteHeader(header); err != nil {
+		b.failed(name, err)
+		return
+	}
+	if _, err := b.tar.Write(data); err != nil {
+		b.failed(name, err)
+	}
+}
+
+// close lists the items that could not be collected and finishes the archive
+func (b *bundle) close() error {
+	if len(b.errors) > 0 {
+		b.write(errorsFileName, []byte(strings.Join(b.errors, "\n")+"\n"))
+	}
+	return errors.Join(b.tar.Close(), b.gzip.Close())
+}
+
+// readFile reads a file, through sudo when the current user cannot read it, keeping the end of files larger
+// than maxFileSize
+func readFile(filePath string) ([]byte, error) {
+	data, err := readTail(filePath)
+	if errors.Is(err, fs.ErrPermission) {
+		output, catErr := sysutil.RunCommandWithOutput("cat", filePath)
+		if catErr != nil {
+			return nil, err
+		}
+		return keepTail([]byte(output)), nil
+	}
+	return data, err
+}
+
+// readTail reads a file, keeping its last maxFileSize bytes
+func readTail(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	omitted := info.Size() - maxFileSize
+	if omitted <= 0 {
+		return io.ReadAll(file)
+	}
+	if _, err := file.Seek(omitted, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return truncated(data, int(omitted)), nil
+}
+
+// keepTail keeps the last maxFileSize bytes of data, marked as truncated when anything was left out
+func keepTail(data []byte) []byte {
+	if len(data) <= maxFileSize {
+		return data
+	}
+	return truncated(data[len(data)-maxFileSize:], len(data)-maxFileSize)
+}
+
+// truncated marks data as the end of a file whose first omitted bytes were left out
+func truncated(data []byte, omitted int) []byte {
+	return append([]byte(fmt.Sprintf("[first %d bytes omitted]\n", omitted)), data...)
+}
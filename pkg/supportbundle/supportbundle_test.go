@@ -0,0 +1,118 @@
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// readBundle returns the entries of a bundle by name
+func readBundle(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to open bundle: %v", err)
+	}
+	reader := tar.NewReader(gz)
+	entries := map[string]string{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("failed to read bundle: %v", err)
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", header.Name, err)
+		}
+		entries[header.Name] = string(content)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	stateDir := t.TempDir()
+	files := map[string]string{
+		"bootstrap-state.json":   `{"steps": []}`,
+		"onboarding.json":        `{"refreshToken": "device-token"}`,
+		"nested/ignored.json":    `{}`,
+		"registry/password.toml": `password = "hunter22"`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(stateDir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(stateDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{
+		Azure: config.AzureConfig{
+			ServicePrincipal: &config.ServicePrincipalConfig{ClientID: "client", ClientSecret: "sp-secret-value"},
+		},
+	}
+
+	var buf bytes.Buffer
+	b := newBundle(&buf, "support", NewRedactor(cfg), time.Now(), logrus.New())
+	b.addDir("state", stateDir, false, excludedStateFiles)
+	b.addDir("registries", filepath.Join(stateDir, "registry"), true, nil)
+	b.addData("agent.log", func() ([]byte, error) {
+		return []byte("authenticating with sp-secret-value\n"), nil
+	})
+	b.addFile("missing.txt", filepath.Join(stateDir, "missing.txt"))
+	b.addCommand(context.Background(), "journal.log", "seq", "20000")
+	if err := b.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	entries := readBundle(t, buf.Bytes())
+	if got := entries["support/state/bootstrap-state.json"]; got != files["bootstrap-state.json"] {
+		t.Errorf("state/bootstrap-state.json = %q, want the file content", got)
+	}
+	if _, ok := entries["support/state/onboarding.json"]; ok {
+		t.Error("bundle contains the onboarding state, which holds credentials")
+	}
+	if _, ok := entries["support/state/nested/ignored.json"]; ok {
+		t.Error("bundle contains a subdirectory of a directory collected without recursion")
+	}
+	if got := entries["support/registries/password.toml"]; got != `password = "***REDACTED***"` {
+		t.Errorf("registries/password.toml = %q, want the password redacted", got)
+	}
+	if got := entries["support/agent.log"]; strings.Contains(got, "sp-secret-value") {
+		t.Errorf("agent.log = %q, want the configured secret redacted", got)
+	}
+	if got := entries["support/journal.log"]; !strings.HasPrefix(got, "1\n2\n") || !strings.HasSuffix(got, "\n20000\n") {
+		t.Errorf("journal.log = %q...%q, want the complete command output", got[:min(len(got), 10)], got[max(len(got)-10, 0):])
+	}
+	if got := entries["support/"+errorsFileName]; !strings.Contains(got, "missing.txt") {
+		t.Errorf("%s = %q, want the missing file listed", errorsFileName, got)
+	}
+}
+
+func TestReadTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large.log")
+	content := strings.Repeat("x", maxFileSize) + "last line\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readTail(path)
+	if err != nil {
+		t.Fatalf("readTail() error = %v", err)
+	}
+	if !strings.HasPrefix(string(data), "[first 10 bytes omitted]\n") || !strings.HasSuffix(string(data), "last line\n") {
+		t.Errorf("readTail() = %q...%q, want the end of the file marked as truncated", data[:40], data[len(data)-20:])
+	}
+}
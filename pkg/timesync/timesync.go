@@ -0,0 +1,154 @@
+package timesync
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/packages"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// Time synchronization services, by the name the status file reports them with
+const (
+	Chrony    = "chrony"
+	Timesyncd = "systemd-timesyncd"
+	NTPd      = "ntpd"
+)
+
+// service is a time synchronization service and the systemd units distributions ship it as
+type service struct {
+	name  string
+	units []string
+}
+
+// services are checked in order, chrony and systemd-timesyncd are the ones the agent can configure
+var services = []service{
+	{Chrony, []string{"chronyd", "chrony"}},
+	{Timesyncd, []string{"systemd-timesyncd"}},
+	{NTPd, []string{"ntpd", "ntp", "ntpsec"}},
+}
+
+// Status is the clock synchronization of the node as reported in the status file
+type Status struct {
+	Service      string `json:"service,omitempty"`  // Active time synchronization service
+	Synchronized bool   `json:"synchronized"`       // The kernel clock is synchronized with NTP
+	Offset       string `json:"offset,omitempty"`   // Offset of the clock from its time source, when the service reports it
+	Warning      string `json:"warning,omitempty"`  // Why the clock may make TLS authentication fail
+	MaxDrift     string `json:"maxDrift,omitempty"` // Offset above which the warning is set
+}
+
+// ActiveService returns the name and unit of the running time synchronization service, empty when none runs
+func ActiveService() (string, string) {
+	for _, svc := range services {
+		for _, unit := range svc.units {
+			if sysutil.IsServiceActive(unit) {
+				return svc.name, unit
+			}
+		}
+	}
+	return "", ""
+}
+
+// InstalledService returns the name and unit of the installed time synchronization service the agent can
+// configure, chrony before systemd-timesyncd, empty when neither is installed
+func InstalledService() (string, string) {
+	for _, svc := range services[:2] {
+		for _, unit := range svc.units {
+			if sysutil.ServiceExists(unit) {
+				return svc.name, unit
+			}
+		}
+	}
+	return "", ""
+}
+
+// Requirements returns the chrony package when time synchronization is managed and neither chrony nor
+// systemd-timesyncd is installed
+func Requirements(managed bool) []packages.Requirement {
+	if !managed {
+		return nil
+	}
+	if name, _ := InstalledService(); name != "" {
+		return nil
+	}
+	return []packages.Requirement{{Package: "chrony", Command: "chronyd",
+		Reason: "timeSync.enabled needs a time synchronization service and neither chrony nor systemd-timesyncd is installed"}}
+}
+
+// Detect reads whether the clock is synchronized and its offset, and sets a warning when it is not
+// synchronized or drifted further than maxDrift
+func Detect(ctx context.Context, maxDrift time.Duration) *Status {
+	status := &Status{MaxDrift: maxDrift.String()}
+	status.Service, _ = ActiveService()
+
+	if output, err := sysutil.RunCommandContext(ctx, "timedatectl", "show", "--property=NTPSynchronized", "--value"); err == nil {
+		status.Synchronized = strings.TrimSpace(output) == "yes"
+	}
+
+	var offset time.Duration
+	var offsetErr error = fmt.Errorf("%s does not report its offset", status.Service)
+	switch status.Service {
+	case Chrony:
+		var output string
+		if output, offsetErr = sysutil.RunCommandContext(ctx, "chronyc", "-c", "tracking"); offsetErr == nil {
+			offset, offsetErr = ParseChronyTracking(output)
+		}
+	case Timesyncd:
+		var output string
+		if output, offsetErr = sysutil.RunCommandContext(ctx, "timedatectl", "timesync-status"); offsetErr == nil {
+			offset, offsetErr = ParseTimesyncStatus(output)
+		}
+	}
+	if offsetErr == nil {
+		status.Offset = offset.String()
+	}
+
+	status.Warning = warning(status, offset, offsetErr == nil, maxDrift)
+	return status
+}
+
+// warning returns why the clock may make TLS authentication fail, empty when it is synchronized within maxDrift
+func warning(status *Status, offset time.Duration, offsetKnown bool, maxDrift time.Duration) string {
+	switch {
+	case status.Service == "":
+		return "no time synchronization service runs, the clock drifts and TLS authentication fails once it is skewed"
+	case offsetKnown && offset.Abs() > maxDrift:
+		return fmt.Sprintf("the clock is %s off its time source, more than the %s maxDrift", offset.Abs(), maxDrift)
+	case !status.Synchronized:
+		return fmt.Sprintf("%s runs but the clock is not synchronized, check it can reach its NTP servers", status.Service)
+	}
+	return ""
+}
+
+// ParseChronyTracking returns the offset of the system clock from the CSV output of chronyc -c tracking, whose
+// fifth field is the offset in seconds
+func ParseChronyTracking(output string) (time.Duration, error) {
+	fields := strings.Split(strings.TrimSpace(output), ",")
+	if len(fields) < 5 {
+		return 0, fmt.Errorf("unexpected chronyc tracking output %q", output)
+	}
+	seconds, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected chronyc tracking offset %q: %w", fields[4], err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ParseTimesyncStatus returns the offset of the Offset line of timedatectl timesync-status
+func ParseTimesyncStatus(output string) (time.Duration, error) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || key != "Offset" {
+			continue
+		}
+		offset, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("unexpected timesync-status offset %q: %w", value, err)
+		}
+		return offset, nil
+	}
+	return 0, fmt.Errorf("timesync-status reports no offset, systemd-timesyncd has not reached a server yet")
+}
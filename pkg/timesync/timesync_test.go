@@ -0,0 +1,65 @@
+package timesync
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChronyTracking(t *testing.T) {
+	output := "A9FEA97B,169.254.169.123,3,1760000000.123456,-0.002500000,-0.000001,0.000010,-12.345,-0.001,0.020,0.000500,0.000200,64.2,Normal\n"
+	got, err := ParseChronyTracking(output)
+	if err != nil || got != -2500*time.Microsecond {
+		t.Errorf("ParseChronyTracking() = %v, %v, want -2.5ms", got, err)
+	}
+	if _, err := ParseChronyTracking("506 Cannot talk to daemon"); err == nil {
+		t.Error("ParseChronyTracking() accepted an error message")
+	}
+}
+
+func TestParseTimesyncStatus(t *testing.T) {
+	output := `       Server: 10.0.0.1 (ntp.contoso.local)
+Poll interval: 34min 8s (min: 32s; max 34min 8s)
+         Leap: normal
+      Version: 4
+      Stratum: 2
+    Reference: C0A80001
+    Precision: 1us (-23)
+Root distance: 1.219ms (max: 5s)
+       Offset: +1.567s
+        Delay: 1.103ms
+       Jitter: 412us
+ Packet count: 12
+`
+	got, err := ParseTimesyncStatus(output)
+	if err != nil || got != 1567*time.Millisecond {
+		t.Errorf("ParseTimesyncStatus() = %v, %v, want 1.567s", got, err)
+	}
+	if _, err := ParseTimesyncStatus("       Server: n/a\n"); err == nil {
+		t.Error("ParseTimesyncStatus() returned an offset without an Offset line")
+	}
+}
+
+func TestWarning(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      Status
+		offset      time.Duration
+		offsetKnown bool
+		want        string
+	}{
+		{name: "in sync", status: Status{Service: Chrony, Synchronized: true}, offset: 3 * time.Millisecond, offsetKnown: true},
+		{name: "no service", status: Status{}, want: "no time synchronization service runs"},
+		{name: "drift", status: Status{Service: Chrony, Synchronized: true}, offset: -2 * time.Second, offsetKnown: true, want: "the clock is 2s off"},
+		{name: "not synchronized", status: Status{Service: Timesyncd}, want: "not synchronized"},
+		{name: "offset unknown but synchronized", status: Status{Service: NTPd, Synchronized: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := warning(&tt.status, tt.offset, tt.offsetKnown, time.Second)
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("warning() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// ResultFilePath records the result of the last update check for status reporting and metrics
+var ResultFilePath = filepath.Join(config.AgentStateDir, "update-check.json")
+
+// maxManifestSize bounds how much of the channel manifest response is read
+const maxManifestSize = 64 * 1024
+
+// Manifest is the channel manifest published for a release channel such as "latest"
+type Manifest struct {
+	Version    string `json:"version"`              // Agent version currently published on the channel, e.g. "v0.5.0"
+	ReleaseURL string `json:"releaseUrl,omitempty"` // Where to download the release or read its notes
+}
+
+// Result is the outcome of comparing the running agent with the channel manifest
+type Result struct {
+	CurrentVersion string    `json:"currentVersion"`
+	LatestVersion  string    `json:"latestVersion"`
+	ReleaseURL     string    `json:"releaseUrl,omitempty"`
+	Outdated       bool      `json:"outdated"`
+	Message        string    `json:"message,omitempty"` // why the versions could not be compared
+	CheckedAt      time.Time `json:"checkedAt"`
+}
+
+// Check fetches the channel manifest and reports whether currentVersion is older than the published version
+func Check(ctx context.Context, manifestURL, currentVersion string) (*Result, error) {
+	manifest, err := fetchManifest(ctx, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		CurrentVersion: currentVersion,
+		LatestVersion:  manifest.Version,
+		ReleaseURL:     manifest.ReleaseURL,
+		CheckedAt:      time.Now().UTC(),
+	}
+	cmp, err := compareVersions(currentVersion, manifest.Version)
+	if err != nil {
+		// Development builds carry no release version to compare
+		result.Message = fmt.Sprintf("cannot compare agent version %s with %s: %v", currentVersion, manifest.Version, err)
+		return result, nil
+	}
+	result.Outdated = cmp < 0
+	return result, nil
+}
+
+// fetchManifest downloads and parses the channel manifest
+func fetchManifest(ctx context.Context, manifestURL string) (*Manifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create update manifest request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch update manifest %s: %w", manifestURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch update manifest %s: HTTP status %d", manifestURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read update manifest: %w", err)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse update manifest: %w", err)
+	}
+	if manifest.Version == "" {
+		return nil, fmt.Errorf("update manifest %s has no version", manifestURL)
+	}
+	return manifest, nil
+}
+
+// compareVersions compares two semantic versions such as "v0.4.1" or "0.5.0-rc.1",
+// returning -1, 0 or 1. A pre-release sorts before the release it precedes.
+func compareVersions(a, b string) (int, error) {
+	aCore, aPre, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bCore, bPre, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range aCore {
+		if aCore[i] != bCore[i] {
+			if aCore[i] < bCore[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0, nil
+	case aPre == "":
+		return 1, nil
+	case bPre == "":
+		return -1, nil
+	default:
+		return strings.Compare(aPre, bPre), nil
+	}
+}
+
+// parseVersion splits a semantic version into its major, minor and patch numbers and pre-release suffix
+func parseVersion(version string) ([3]int, string, error) {
+	var core [3]int
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	// Build metadata does not affect precedence
+	v, _, _ = strings.Cut(v, "+")
+	v, pre, _ := strings.Cut(v, "-")
+
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return core, "", fmt.Errorf("%q is not a semantic version", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return core, "", fmt.Errorf("%q is not a semantic version", version)
+		}
+		core[i] = n
+	}
+	return core, pre, nil
+}
+
+// SaveResult persists the update check result so status collection and metrics can surface it
+func SaveResult(result *Result) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal update check result: %w", err)
+	}
+	if err := utils.RunSystemCommand("mkdir", "-p", filepath.Dir(ResultFilePath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", ResultFilePath, err)
+	}
+	return utils.WriteFileAtomicSystem(ResultFilePath, data, 0o644)
+}
+
+// LoadResult reads the last update check result, returning nil when no check has run yet
+func LoadResult() (*Result, error) {
+	data, err := os.ReadFile(ResultFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read update check result: %w", err)
+	}
+	result := &Result{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("failed to parse update check result: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v0.4.1", "v0.5.0", -1},
+		{"0.5.0", "v0.5.0", 0},
+		{"v1.10.0", "v1.9.3", 1},
+		{"v0.5.0-rc.1", "v0.5.0", -1},
+		{"v0.5.0", "v0.5.0-rc.1", 1},
+		{"v0.5.0-rc.1", "v0.5.0-rc.2", -1},
+		{"v0.5.0+build.7", "v0.5.0", 0},
+	}
+	for _, tt := range tests {
+		got, err := compareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("compareVersions(%q, %q) unexpected error: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"dev", "v1.2", "f5d4f7f-dirty"} {
+		if _, err := compareVersions(invalid, "v1.0.0"); err == nil {
+			t.Errorf("compareVersions(%q, ...) expected an error", invalid)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			_, _ = w.Write([]byte(`{"version": "v0.5.0", "releaseUrl": "https://example.com/releases/v0.5.0"}`))
+		case "/empty.json":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		path           string
+		currentVersion string
+		wantOutdated   bool
+		wantMessage    string
+		wantErr        string
+	}{
+		{
+			name:           "outdated agent",
+			path:           "/latest.json",
+			currentVersion: "v0.4.2",
+			wantOutdated:   true,
+		},
+		{
+			name:           "current agent",
+			path:           "/latest.json",
+			currentVersion: "v0.5.0",
+		},
+		{
+			name:           "development build",
+			path:           "/latest.json",
+			currentVersion: "dev",
+			wantMessage:    "cannot compare agent version dev",
+		},
+		{
+			name:           "manifest without version",
+			path:           "/empty.json",
+			currentVersion: "v0.4.2",
+			wantErr:        "has no version",
+		},
+		{
+			name:           "missing manifest",
+			path:           "/missing.json",
+			currentVersion: "v0.4.2",
+			wantErr:        "HTTP status 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Check(context.Background(), server.URL+tt.path, tt.currentVersion)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Check() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check() unexpected error: %v", err)
+			}
+			if result.Outdated != tt.wantOutdated {
+				t.Errorf("Check() outdated = %v, want %v", result.Outdated, tt.wantOutdated)
+			}
+			if result.LatestVersion != "v0.5.0" || result.ReleaseURL == "" {
+				t.Errorf("Check() = %+v, want the manifest version and release URL", result)
+			}
+			if !strings.Contains(result.Message, tt.wantMessage) {
+				t.Errorf("Check() message = %q, want it to contain %q", result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/netutil"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// The helpers below moved to focused packages: commands, sudo, tracing and systemd to sysutil, files to fsutil
+// and downloads to netutil. They stay here so existing callers keep compiling; new code imports the packages.
+
+// Command execution, see sysutil
+const (
+	DefaultCommandTimeout   = sysutil.DefaultCommandTimeout
+	DefaultMaxCommandOutput = sysutil.DefaultMaxCommandOutput
+)
+
+type (
+	Command       = sysutil.Command
+	CommandResult = sysutil.CommandResult
+	CommandError  = sysutil.CommandError
+	CommandRunner = sysutil.CommandRunner
+	CommandMetric = sysutil.CommandMetric
+	ExecRunner    = sysutil.ExecRunner
+	TracingRunner = sysutil.TracingRunner
+)
+
+// NewExecRunner creates an ExecRunner with default timeout and output limits
+func NewExecRunner() *ExecRunner { return sysutil.NewExecRunner() }
+
+// SetCommandRunner replaces the runner used by the package-level command helpers, returning a restore function
+func SetCommandRunner(runner CommandRunner) func() { return sysutil.SetCommandRunner(runner) }
+
+// GetCommandRunner returns the runner used by the package-level command helpers
+func GetCommandRunner() CommandRunner { return sysutil.GetCommandRunner() }
+
+// GetCommandMetrics returns a snapshot of command execution statistics sorted by command name
+func GetCommandMetrics() []CommandMetric { return sysutil.GetCommandMetrics() }
+
+// RunCommandContext executes a command with context cancellation and returns its captured output
+func RunCommandContext(ctx context.Context, name string, args ...string) (string, error) {
+	return sysutil.RunCommandContext(ctx, name, args...)
+}
+
+// RunSystemCommandContext executes a command with context cancellation, streaming its output to the console
+func RunSystemCommandContext(ctx context.Context, name string, args ...string) error {
+	return sysutil.RunSystemCommandContext(ctx, name, args...)
+}
+
+// RunSystemCommand executes a system command with sudo when needed for privileged operations
+func RunSystemCommand(name string, args ...string) error {
+	return sysutil.RunSystemCommand(name, args...)
+}
+
+// RunCommandWithOutput executes a command and returns output with sudo when needed
+func RunCommandWithOutput(name string, args ...string) (string, error) {
+	return sysutil.RunCommandWithOutput(name, args...)
+}
+
+// BinaryExists checks if a binary exists in PATH
+func BinaryExists(binaryName string) bool { return sysutil.BinaryExists(binaryName) }
+
+// GetArc retrieves the system architecture in a format matching reference scripts
+func GetArc() (string, error) { return sysutil.GetArc() }
+
+// EnableTracing logs every external command and file write to the logger at trace level
+func EnableTracing(logger *logrus.Logger) { sysutil.EnableTracing(logger) }
+
+// RedactArgs returns a copy of the command arguments with secrets replaced, for logging
+func RedactArgs(args []string) []string { return sysutil.RedactArgs(args) }
+
+// Services, see sysutil
+
+// IsServiceActive checks if a systemd service is active
+func IsServiceActive(serviceName string) bool { return sysutil.IsServiceActive(serviceName) }
+
+// ServiceExists checks if a systemd service unit file exists
+func ServiceExists(serviceName string) bool { return sysutil.ServiceExists(serviceName) }
+
+// StopService stops a systemd service
+func StopService(serviceName string) error { return sysutil.StopService(serviceName) }
+
+// DisableService disables a systemd service
+func DisableService(serviceName string) error { return sysutil.DisableService(serviceName) }
+
+// EnableAndStartService enables and starts a systemd service
+func EnableAndStartService(serviceName string) error {
+	return sysutil.EnableAndStartService(serviceName)
+}
+
+// RestartService restarts a systemd service
+func RestartService(serviceName string) error { return sysutil.RestartService(serviceName) }
+
+// ReloadSystemd reloads systemd daemon configuration
+func ReloadSystemd() error { return sysutil.ReloadSystemd() }
+
+// WaitForService waits until a systemd service is active or timeout occurs
+func WaitForService(ctx context.Context, serviceName string, timeout time.Duration, logger *logrus.Logger) error {
+	return sysutil.WaitForService(ctx, serviceName, timeout, logger)
+}
+
+// Files, see fsutil
+
+// FileExists checks if a file exists
+func FileExists(path string) bool { return fsutil.FileExists(path) }
+
+// FileExistsAndValid checks if a file exists and is not empty
+func FileExistsAndValid(path string) bool { return fsutil.FileExistsAndValid(path) }
+
+// DirectoryExists checks if a directory exists
+func DirectoryExists(path string) bool { return fsutil.DirectoryExists(path) }
+
+// RunCleanupCommand removes a file or directory using rm -f, ignoring "not found" errors
+func RunCleanupCommand(path string) error { return fsutil.RunCleanupCommand(path) }
+
+// ShredFile overwrites a file before removing it, shredded is false when shred is not available
+func ShredFile(path string) (shredded bool, err error) { return fsutil.ShredFile(path) }
+
+// RemoveFiles removes multiple files, continuing on errors
+func RemoveFiles(files []string, logger *logrus.Logger) []error {
+	return fsutil.RemoveFiles(files, logger)
+}
+
+// RemoveDirectories removes multiple directories recursively, continuing on errors
+func RemoveDirectories(directories []string, logger *logrus.Logger) []error {
+	return fsutil.RemoveDirectories(directories, logger)
+}
+
+// CreateTempFile creates a temporary file with given pattern and content
+func CreateTempFile(pattern string, content []byte) (*os.File, error) {
+	return fsutil.CreateTempFile(pattern, content)
+}
+
+// CleanupTempFile removes a temporary file
+func CleanupTempFile(filePath string) { fsutil.CleanupTempFile(filePath) }
+
+// StagingPath returns the path of name in the staging directory, creating the directory when it is missing
+func StagingPath(stagingDir, name string) (string, error) {
+	return fsutil.StagingPath(stagingDir, name)
+}
+
+// WriteFileAtomic writes data to a file atomically using a temporary file and rename operation
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	return fsutil.WriteFileAtomic(filename, data, perm)
+}
+
+// WriteFileAtomicSystem writes data to a file atomically, using sudo for privileged paths
+func WriteFileAtomicSystem(filename string, data []byte, perm os.FileMode) error {
+	return fsutil.WriteFileAtomicSystem(filename, data, perm)
+}
+
+// Downloads, see netutil
+
+// DownloadFile downloads a file from URL to destination, aborting when ctx is cancelled
+func DownloadFile(ctx context.Context, url, destination string) error {
+	return netutil.DownloadFile(ctx, url, destination)
+}
@@ -0,0 +1,168 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// FileExists checks if a file exists
+func FileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
+
+// FileExistsAndValid checks if a file exists and is not empty (useful for binaries)
+func FileExistsAndValid(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Size() > 0
+}
+
+// DirectoryExists checks if a directory exists
+func DirectoryExists(path string) bool {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return info.IsDir()
+}
+
+// ignorableCleanupErrors defines patterns for errors that should be ignored during cleanup operations
+var ignorableCleanupErrors = []string{
+	"not loaded",
+	"does not exist",
+	"No such file or directory",
+	"cannot remove",
+	"cannot stat",
+}
+
+// shouldIgnoreCleanupError checks if an error should be ignored during cleanup operations
+func shouldIgnoreCleanupError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errStr := err.Error()
+	for _, pattern := range ignorableCleanupErrors {
+		if matched, _ := regexp.MatchString(pattern, errStr); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// RunCleanupCommand removes a file or directory using rm -f, ignoring "not found" errors
+// This is specifically designed for cleanup operations where missing files should not be treated as errors
+func RunCleanupCommand(path string) error {
+	err := sysutil.RunSystemCommand("rm", "-f", path)
+
+	// For cleanup operations, ignore common "not found" type errors
+	if err != nil && !shouldIgnoreCleanupError(err) {
+		// Log the error for actual failures (stderr was already shown during execution)
+		fmt.Fprintf(os.Stderr, "Cleanup command failed: rm -f %s - %v\n", path, err)
+		return err
+	}
+	return nil
+}
+
+// ShredFile overwrites a file with random data and zeros before removing it, so secrets do not linger in freed blocks.
+// When shred is not available the file is only removed and shredded is false.
+func ShredFile(path string) (shredded bool, err error) {
+	if !sysutil.BinaryExists("shred") {
+		return false, RunCleanupCommand(path)
+	}
+	if err := sysutil.RunSystemCommand("shred", "--force", "--zero", "--remove", path); err != nil {
+		return false, fmt.Errorf("failed to shred %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// RemoveFiles removes multiple files, continuing on errors and logging results
+func RemoveFiles(files []string, logger *logrus.Logger) []error {
+	var errors []error
+
+	for _, file := range files {
+		logger.Debugf("Removing file: %s", file)
+		if err := sysutil.RunSystemCommand("rm", "-f", file); err != nil {
+			logger.Debugf("Failed to remove file %s: %v (may not exist)", file, err)
+			errors = append(errors, fmt.Errorf("failed to remove %s: %w", file, err))
+		} else {
+			logger.Debugf("Removed file: %s", file)
+		}
+	}
+
+	return errors
+}
+
+// RemoveDirectories removes multiple directories recursively, continuing on errors
+func RemoveDirectories(directories []string, logger *logrus.Logger) []error {
+	var errors []error
+
+	for _, dir := range directories {
+		logger.Infof("Removing directory: %s", dir)
+
+		// Check if directory exists first
+		if !DirectoryExists(dir) {
+			logger.Debugf("Directory %s does not exist, skipping", dir)
+			continue
+		}
+
+		if err := sysutil.RunSystemCommand("sudo", "rm", "-rf", dir); err != nil {
+			logger.Errorf("Failed to remove directory %s: %v", dir, err)
+			errors = append(errors, fmt.Errorf("failed to remove %s: %w", dir, err))
+		} else {
+			logger.Infof("Successfully removed directory: %s", dir)
+		}
+	}
+
+	return errors
+}
+
+// CreateTempFile creates a temporary file with given pattern and content
+func CreateTempFile(pattern string, content []byte) (*os.File, error) {
+	tempFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	if _, err := tempFile.Write(content); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	// Reopen for reading
+	reopened, err := os.Open(tempFile.Name())
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("failed to reopen temporary file: %w", err)
+	}
+
+	return reopened, nil
+}
+
+// CleanupTempFile removes a temporary file
+func CleanupTempFile(filePath string) {
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("Failed to cleanup temporary file %s: %v", filePath, err)
+	}
+}
+
+// StagingPath returns the path of name in the staging directory, creating the directory when it is missing.
+// Installers stage downloads and extracted binaries there rather than in /tmp, which hardened hosts mount noexec.
+func StagingPath(stagingDir, name string) (string, error) {
+	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory %s: %w", stagingDir, err)
+	}
+	return filepath.Join(stagingDir, name), nil
+}
@@ -0,0 +1,74 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// LstatSystem returns the type of the file at path without following symlinks: fs.ModeSymlink, fs.ModeDir or 0 for
+// any other file. Paths the current user cannot see, such as files in a root-only directory, are checked through
+// sudo. The error wraps fs.ErrNotExist only when the path is absent; any other error leaves it unknown.
+func LstatSystem(path string) (fs.FileMode, error) {
+	info, err := os.Lstat(path)
+	if err == nil {
+		return info.Mode().Type() & (fs.ModeSymlink | fs.ModeDir), nil
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		return 0, err
+	}
+	output, err := sysutil.RunCommandWithOutput("ls", "-ld", "--", path)
+	if err != nil {
+		return 0, lsError(path, err)
+	}
+	switch {
+	case strings.HasPrefix(output, "l"):
+		return fs.ModeSymlink, nil
+	case strings.HasPrefix(output, "d"):
+		return fs.ModeDir, nil
+	}
+	return 0, nil
+}
+
+// ListFilesSystem returns the names of the entries of dir that are not directories. A directory the current user
+// cannot read, such as a root-only one, is listed through sudo. The error wraps fs.ErrNotExist only when dir is
+// absent.
+func ListFilesSystem(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		return names, nil
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		return nil, err
+	}
+	// -p marks directories with a trailing slash
+	output, err := sysutil.RunCommandWithOutput("ls", "-Ap", "--", dir)
+	if err != nil {
+		return nil, lsError(dir, err)
+	}
+	var names []string
+	for _, name := range strings.Split(strings.TrimSpace(output), "\n") {
+		if name != "" && !strings.HasSuffix(name, "/") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// lsError wraps fs.ErrNotExist when ls failed because path is absent
+func lsError(path string, err error) error {
+	if strings.Contains(err.Error(), "No such file or directory") {
+		return fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	return fmt.Errorf("failed to check %s: %w", path, err)
+}
@@ -0,0 +1,59 @@
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLstatSystem(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "kubelet-client.pem")
+	link := filepath.Join(dir, "kubelet-client-current.pem")
+	if err := os.WriteFile(file, []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]fs.FileMode{file: 0, link: fs.ModeSymlink, dir: fs.ModeDir} {
+		if got, err := LstatSystem(path); err != nil || got != want {
+			t.Errorf("LstatSystem(%s) = %v, %v, want %v", path, got, err, want)
+		}
+	}
+	if _, err := LstatSystem(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LstatSystem() of a missing file error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestListFilesSystem(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kubelet.key"), []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := ListFilesSystem(dir); err != nil || !slices.Equal(got, []string{"kubelet.key"}) {
+		t.Errorf("ListFilesSystem() = %v, %v, want the file only", got, err)
+	}
+	if _, err := ListFilesSystem(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ListFilesSystem() of a missing directory error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestLsError(t *testing.T) {
+	absent := errors.New("command ls failed: exit status 2, output: ls: cannot access '/var/lib/kubelet/pki': No such file or directory")
+	if err := lsError("/var/lib/kubelet/pki", absent); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("lsError() of an absent path = %v, want fs.ErrNotExist", err)
+	}
+	denied := errors.New("command sudo failed: exit status 1, output: sudo: a password is required")
+	if err := lsError("/var/lib/kubelet/pki", denied); errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("lsError() of a sudo failure = %v, want an error other than fs.ErrNotExist", err)
+	}
+}
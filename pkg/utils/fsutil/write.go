@@ -0,0 +1,138 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// FileWriter writes whole files. Installers depend on it rather than on the filesystem directly,
+// so their tests can capture what would be written.
+type FileWriter interface {
+	WriteFile(filename string, data []byte, perm os.FileMode) error
+}
+
+// AtomicWriter writes files the agent owns with WriteFileAtomic
+type AtomicWriter struct{}
+
+// WriteFile writes data to filename atomically
+func (AtomicWriter) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	return WriteFileAtomic(filename, data, perm)
+}
+
+// SystemWriter writes files below system paths with WriteFileAtomicSystem, using sudo when needed
+type SystemWriter struct{}
+
+// WriteFile writes data to filename atomically with system-level permissions
+func (SystemWriter) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	return WriteFileAtomicSystem(filename, data, perm)
+}
+
+var (
+	_ FileWriter = AtomicWriter{}
+	_ FileWriter = SystemWriter{}
+)
+
+// WriteFileAtomic writes data to a file atomically using a temporary file and rename operation
+// This prevents partial writes and corruption during system failures
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	err := writeFileAtomic(filename, data, perm)
+	sysutil.AuditFileWrite(filename, data, perm, err)
+	if err != nil {
+		return err
+	}
+	sysutil.TraceFileWrite(filename, data, perm)
+	return nil
+}
+
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	// Create temporary file in the same directory as the target file
+	dir := filepath.Dir(filename)
+	tmpFile, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(filename)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	tmpPath := tmpFile.Name()
+	defer func() {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpPath) // Clean up temp file on error
+	}()
+
+	// Write data to temporary file
+	if _, err := tmpFile.Write(data); err != nil {
+		return fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+
+	// Ensure data is flushed to disk
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+
+	// Close the temporary file
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	// Set the correct permissions
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	// Atomic rename to final location
+	if err := os.Rename(tmpPath, filename); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// WriteFileAtomicSystem writes data to a file atomically with system-level permissions
+// Uses sudo for privileged paths that require elevated permissions
+func WriteFileAtomicSystem(filename string, data []byte, perm os.FileMode) error {
+	// For system paths, use the temporary file approach with sudo copy/move
+	if sysutil.RequiresSudo("cp", []string{filename}) {
+		err := writeFileAtomicSudo(filename, data, perm)
+		sysutil.AuditFileWrite(filename, data, perm, err)
+		if err != nil {
+			return err
+		}
+		sysutil.TraceFileWrite(filename, data, perm)
+		return nil
+	}
+
+	// For non-privileged paths, use regular atomic write
+	return WriteFileAtomic(filename, data, perm)
+}
+
+// writeFileAtomicSudo writes data to a privileged path through a temporary file copied and moved with sudo
+func writeFileAtomicSudo(filename string, data []byte, perm os.FileMode) error {
+	// Create temp file in user-writable location
+	tempFile, err := CreateTempFile("atomic-write-*.tmp", data)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer CleanupTempFile(tempFile.Name())
+
+	// Close the temp file before sudo operations
+	_ = tempFile.Close()
+
+	// Create temporary file in target directory using sudo
+	tempPath := filename + ".tmp"
+	if err := sysutil.RunSystemCommand("cp", tempFile.Name(), tempPath); err != nil {
+		return fmt.Errorf("failed to copy to temporary location: %w", err)
+	}
+
+	// Set proper permissions
+	if err := sysutil.RunSystemCommand("chmod", fmt.Sprintf("%o", perm), tempPath); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	// Atomic rename
+	if err := sysutil.RunSystemCommand("mv", tempPath, filename); err != nil {
+		return fmt.Errorf("failed to rename to final location: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+func TestFileWriters(t *testing.T) {
+	dir := t.TempDir()
+	for name, writer := range map[string]FileWriter{"atomic": AtomicWriter{}, "system": SystemWriter{}} {
+		path := filepath.Join(dir, name+".conf")
+		if err := writer.WriteFile(path, []byte("first"), 0o600); err != nil {
+			t.Fatalf("%s WriteFile() unexpected error: %v", name, err)
+		}
+		if err := writer.WriteFile(path, []byte("second"), 0o640); err != nil {
+			t.Fatalf("%s WriteFile() overwrite unexpected error: %v", name, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("%s WriteFile() did not write %s: %v", name, path, err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "second" || info.Mode().Perm() != 0o640 {
+			t.Errorf("%s WriteFile() = %q with mode %o, want second with mode 640", name, data, info.Mode().Perm())
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("writers left temporary files behind: %v", entries)
+	}
+}
+
+func TestTraceFileWrite(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.TraceLevel)
+	t.Cleanup(sysutil.SetCommandRunner(sysutil.GetCommandRunner()))
+	sysutil.EnableTracing(logger)
+	t.Cleanup(func() { sysutil.EnableTracing(nil) })
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := WriteFileAtomicSystem(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("file was not written: %v", err)
+	}
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one trace entry for the write, got %d", len(entries))
+	}
+	want := "write: " + path + " (5 bytes, mode 644, sha256 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824)"
+	if entries[0].Message != want {
+		t.Errorf("trace = %q, want %q", entries[0].Message, want)
+	}
+}
+
+// auditEvents collects the audit events of file writes
+type auditEvents []sysutil.AuditEvent
+
+func (e *auditEvents) Record(event sysutil.AuditEvent) { *e = append(*e, event) }
+
+func TestWriteFileAtomicSystemDryRunIsNotAudited(t *testing.T) {
+	events := &auditEvents{}
+	restoreRunner := sysutil.SetCommandRunner(sysutil.GetCommandRunner())
+	defer restoreRunner()
+	sysutil.EnableAudit(events)
+	defer sysutil.EnableAudit(nil)
+	dryRun := sysutil.NewDryRunRunner(sysutil.GetCommandRunner())
+	sysutil.SetCommandRunner(dryRun)
+
+	path := "/etc/aks-flex-node/dry-run-audit.conf"
+	if err := WriteFileAtomicSystem(path, []byte("setting"), 0o644); err != nil {
+		t.Fatalf("WriteFileAtomicSystem() unexpected error: %v", err)
+	}
+	if recorded := dryRun.Drain(); len(recorded) == 0 {
+		t.Fatal("dry run recorded no commands for the system file write")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote %s", path)
+	}
+	if len(*events) != 0 {
+		t.Errorf("dry run audited %+v, want no audit events", *events)
+	}
+}
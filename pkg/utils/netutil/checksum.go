@@ -0,0 +1,124 @@
+package netutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+)
+
+// ErrChecksumMismatch reports a downloaded file whose content does not match its expected checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// maxChecksumFileSize bounds a checksum file, which lists a few lines of checksums and file names
+const maxChecksumFileSize = 1024 * 1024
+
+// FileSHA256 returns the hex-encoded SHA-256 of a file
+func FileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", filePath, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifySHA256 checks that the file has the expected hex-encoded SHA-256. A mismatch is a permanent
+// failure: downloading again through the same mirror or proxy returns the same content.
+func VerifySHA256(filePath, expected string) error {
+	actual, err := FileSHA256(filePath)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return failure.Permanent(fmt.Errorf("%w: %s has SHA-256 %s, expected %s", ErrChecksumMismatch, filePath, actual, strings.ToLower(expected)))
+	}
+	return nil
+}
+
+// FetchChecksum downloads a checksum file in sha256sum format and returns the SHA-256 it lists for fileName.
+// A file with a single checksum, such as the .sha256 files published next to release archives, may omit the name.
+func FetchChecksum(ctx context.Context, checksumURL, fileName string) (string, error) {
+	file, err := os.CreateTemp("", "checksum-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	_ = file.Close()
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if err := DownloadFile(ctx, checksumURL, file.Name()); err != nil {
+		return "", fmt.Errorf("failed to download checksum file: %w", err)
+	}
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxChecksumFileSize {
+		return "", fmt.Errorf("checksum file %s is larger than %d bytes", checksumURL, maxChecksumFileSize)
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	sum, err := ParseChecksumFile(string(data), fileName)
+	if err != nil {
+		return "", fmt.Errorf("invalid checksum file %s: %w", checksumURL, err)
+	}
+	return sum, nil
+}
+
+// ParseChecksumFile returns the SHA-256 listed for fileName in sha256sum output: one "<checksum>  <name>" line
+// per file, with a "*" before binary names. A single checksum without a name applies to any file.
+func ParseChecksumFile(content, fileName string) (string, error) {
+	var unnamed []string
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !isSHA256(fields[0]) {
+			continue
+		}
+		if len(fields) == 1 {
+			unnamed = append(unnamed, fields[0])
+			continue
+		}
+		if path.Base(strings.TrimPrefix(fields[1], "*")) == fileName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if len(unnamed) == 1 {
+		return strings.ToLower(unnamed[0]), nil
+	}
+	return "", fmt.Errorf("no SHA-256 listed for %s", fileName)
+}
+
+// DownloadFileWithChecksum downloads url to destination and verifies it against the expected SHA-256,
+// removing the download when it does not match
+func DownloadFileWithChecksum(ctx context.Context, url, destination, expected string) error {
+	if err := DownloadFile(ctx, url, destination); err != nil {
+		return err
+	}
+	if err := VerifySHA256(destination, expected); err != nil {
+		_ = os.Remove(destination)
+		return fmt.Errorf("failed to verify download of %s: %w", url, err)
+	}
+	return nil
+}
+
+// isSHA256 checks if value is a hex-encoded SHA-256
+func isSHA256(value string) bool {
+	if len(value) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
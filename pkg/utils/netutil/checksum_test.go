@@ -0,0 +1,73 @@
+package netutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	amd64 := strings.Repeat("a", 64)
+	arm64 := strings.Repeat("B", 64)
+	tests := []struct {
+		name     string
+		content  string
+		fileName string
+		want     string
+		wantErr  bool
+	}{
+		{name: "sha256sum listing", content: amd64 + "  runc.amd64\n" + arm64 + " *runc.arm64\n", fileName: "runc.arm64", want: strings.ToLower(arm64)},
+		{name: "listing with directories", content: amd64 + "  release/bin/cni-plugins-linux-amd64-v1.5.1.tgz\n", fileName: "cni-plugins-linux-amd64-v1.5.1.tgz", want: amd64},
+		{name: "single unnamed checksum", content: amd64 + "\n", fileName: "kubernetes-node-linux-amd64.tar.gz", want: amd64},
+		{name: "file not listed", content: amd64 + "  runc.amd64\n", fileName: "runc.arm64", wantErr: true},
+		{name: "no checksum", content: "<html>Not Found</html>\n", fileName: "runc.amd64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksumFile(tt.content, tt.fileName)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseChecksumFile() = %q, %v, want %q (error: %t)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloadFileWithChecksum(t *testing.T) {
+	digest := sha256.Sum256([]byte("artifact"))
+	sum := hex.EncodeToString(digest[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/artifact.tar.gz.sha256sum" {
+			_, _ = w.Write([]byte(sum + "  artifact.tar.gz\n"))
+			return
+		}
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+
+	expected, err := FetchChecksum(context.Background(), server.URL+"/artifact.tar.gz.sha256sum", "artifact.tar.gz")
+	if err != nil || expected != sum {
+		t.Fatalf("FetchChecksum() = %q, %v, want %q", expected, err, sum)
+	}
+
+	destination := filepath.Join(t.TempDir(), "artifact.tar.gz")
+	if err := DownloadFileWithChecksum(context.Background(), server.URL+"/artifact.tar.gz", destination, expected); err != nil {
+		t.Fatalf("DownloadFileWithChecksum() unexpected error: %v", err)
+	}
+
+	err = DownloadFileWithChecksum(context.Background(), server.URL+"/artifact.tar.gz", destination, strings.Repeat("0", 64))
+	if !errors.Is(err, ErrChecksumMismatch) || failure.Classify(err) != failure.ClassPermanent {
+		t.Errorf("DownloadFileWithChecksum() with a wrong checksum = %v, want a permanent checksum mismatch", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Error("download failing verification was not removed")
+	}
+}
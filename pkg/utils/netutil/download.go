@@ -0,0 +1,84 @@
+package netutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
+)
+
+// DefaultDownloadTimeout bounds a single download, including reading the response body
+const DefaultDownloadTimeout = 10 * time.Minute
+
+// Downloader fetches a URL into a local file. Installers depend on it rather than on HTTP directly,
+// so their tests can serve artifacts without a network.
+type Downloader interface {
+	Download(ctx context.Context, url, destination string) error
+}
+
+// HTTPDownloader downloads over HTTP(S) with its client
+type HTTPDownloader struct {
+	Client *http.Client
+}
+
+var _ Downloader = (*HTTPDownloader)(nil)
+
+// NewHTTPDownloader creates an HTTPDownloader whose client times out after DefaultDownloadTimeout
+func NewHTTPDownloader() *HTTPDownloader {
+	return &HTTPDownloader{Client: &http.Client{Timeout: DefaultDownloadTimeout}}
+}
+
+// Download downloads url to destination, aborting when ctx is cancelled. A failed response is
+// classified by its status, so throttling and server errors are retried as transient failures.
+func (d *HTTPDownloader) Download(ctx context.Context, url, destination string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s: %w", url, err)
+	}
+
+	// Make request
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download from %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return failure.WithClass(failure.ClassifyHTTPStatus(resp.StatusCode),
+			fmt.Errorf("download failed with status %d for %s", resp.StatusCode, url))
+	}
+
+	// Create destination file
+	out, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", destination, err)
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	// Copy response body to file
+	_, err = io.Copy(out, resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", destination, err)
+	}
+
+	return nil
+}
+
+// DownloadFile downloads a file from URL to destination with a default HTTPDownloader, aborting when ctx is cancelled.
+// Failures other than permanent ones, such as a dropped connection or a throttled request, are retried with the
+// default retry policy.
+func DownloadFile(ctx context.Context, url, destination string) error {
+	downloader := NewHTTPDownloader()
+	return retry.Do(ctx, retry.Default(), func(ctx context.Context) error {
+		return downloader.Download(ctx, url, destination)
+	})
+}
@@ -0,0 +1,100 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/retry"
+)
+
+func TestDownloadFileHonorsCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	destination := filepath.Join(t.TempDir(), "artifact")
+	if err := DownloadFile(context.Background(), server.URL+"/artifact", destination); err != nil {
+		t.Fatalf("DownloadFile() unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "artifact" {
+		t.Errorf("downloaded file = %q, %v, want artifact", data, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := DownloadFile(ctx, server.URL+"/slow", destination); !errors.Is(err, context.Canceled) {
+		t.Errorf("DownloadFile() with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestHTTPDownloaderClassifiesStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var downloader Downloader = NewHTTPDownloader()
+	destination := filepath.Join(t.TempDir(), "artifact")
+	if err := downloader.Download(context.Background(), server.URL+"/throttled", destination); failure.Classify(err) != failure.ClassTransient {
+		t.Errorf("Download() of a throttled URL = %v, want a transient failure", err)
+	}
+	if err := downloader.Download(context.Background(), server.URL+"/missing", destination); failure.Classify(err) != failure.ClassPermanent {
+		t.Errorf("Download() of a missing URL = %v, want a permanent failure", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("failed downloads created %s", destination)
+	}
+}
+
+func TestDownloadFileRetriesTransientFailures(t *testing.T) {
+	t.Cleanup(retry.SetDefault(retry.Policy{Attempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+
+	destination := filepath.Join(t.TempDir(), "artifact")
+	if err := DownloadFile(context.Background(), server.URL+"/artifact", destination); err != nil {
+		t.Fatalf("DownloadFile() unexpected error after transient failures: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("DownloadFile() made %d requests, want 3", requests)
+	}
+
+	requests = 0
+	if err := DownloadFile(context.Background(), server.URL+"/missing", destination); failure.Classify(err) != failure.ClassPermanent {
+		t.Errorf("DownloadFile() of a missing URL = %v, want a permanent failure", err)
+	}
+	if requests != 1 {
+		t.Errorf("DownloadFile() retried a missing URL %d times, want a single request", requests)
+	}
+}
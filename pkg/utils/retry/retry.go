@@ -0,0 +1,130 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+)
+
+// Defaults of the retry policy, sized for edge links that drop connections for seconds at a time
+const (
+	DefaultAttempts     = 5
+	DefaultInitialDelay = 5 * time.Second
+	DefaultMaxDelay     = 30 * time.Second
+)
+
+// Policy defines how often and how patiently a failed operation is retried
+type Policy struct {
+	Attempts       int           // total attempts including the first; less than 1 means a single attempt
+	InitialDelay   time.Duration // delay before the second attempt, doubled for every further attempt
+	MaxDelay       time.Duration // upper bound of the delay between attempts
+	AttemptTimeout time.Duration // bounds each attempt; zero leaves it to the operation's own timeout
+
+	// OnRetry is called before waiting for the next attempt, e.g. to log the failure
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// defaultPolicy is the policy returned by Default
+var (
+	defaultPolicy   = Policy{Attempts: DefaultAttempts, InitialDelay: DefaultInitialDelay, MaxDelay: DefaultMaxDelay}
+	defaultPolicyMu sync.RWMutex
+)
+
+// SetDefault replaces the policy returned by Default, which the agent configures from agent.retry.
+// It returns a function restoring the previous policy, which is mainly useful for tests.
+func SetDefault(policy Policy) func() {
+	defaultPolicyMu.Lock()
+	defer defaultPolicyMu.Unlock()
+	previous := defaultPolicy
+	defaultPolicy = policy
+	return func() {
+		defaultPolicyMu.Lock()
+		defer defaultPolicyMu.Unlock()
+		defaultPolicy = previous
+	}
+}
+
+// Default returns the policy downloads and Azure API calls are retried with
+func Default() Policy {
+	defaultPolicyMu.RLock()
+	defer defaultPolicyMu.RUnlock()
+	return defaultPolicy
+}
+
+// Do runs fn until it succeeds, the attempts are used up or ctx is cancelled, waiting with exponential backoff
+// and jitter between attempts. Permanent failures are returned right away, as retrying fails the same way.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	attempts := max(policy.Attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = runAttempt(ctx, policy.AttemptTimeout, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return cancelled(ctx, err)
+		}
+		if failure.Classify(err) == failure.ClassPermanent {
+			return err
+		}
+		if attempt >= attempts {
+			break
+		}
+
+		delay := Backoff(policy, attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return cancelled(ctx, err)
+		}
+	}
+	if attempts == 1 {
+		return err
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
+
+// Backoff returns the delay after the given failed attempt: the initial delay doubled for every earlier
+// attempt and capped at the maximum delay, of which a random half is kept so a fleet of nodes that lost
+// the same link does not retry in lockstep
+func Backoff(policy Policy, attempt int) time.Duration {
+	delay := policy.InitialDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 {
+		delay = min(delay, policy.MaxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// cancelled returns the failure of an attempt interrupted by the cancellation of ctx, keeping the cancellation visible
+func cancelled(ctx context.Context, err error) error {
+	if errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w after: %w", ctx.Err(), err)
+}
+
+// runAttempt runs a single attempt, bounded by the attempt timeout when one is set
+func runAttempt(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
@@ -0,0 +1,104 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/failure"
+)
+
+func TestDo(t *testing.T) {
+	policy := Policy{Attempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	reset := errors.New("connection reset by peer")
+
+	tests := []struct {
+		name      string
+		failures  []error // errors of the first attempts, later attempts succeed
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "transient failures recover", failures: []error{failure.Transient(reset), reset}, wantCalls: 3},
+		{name: "attempts used up", failures: []error{reset, reset, reset}, wantCalls: 3, wantErr: true},
+		{name: "permanent failure is not retried", failures: []error{failure.Permanent(reset)}, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, retries := 0, 0
+			policy := policy
+			policy.OnRetry = func(int, time.Duration, error) { retries++ }
+			err := Do(context.Background(), policy, func(context.Context) error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, reset) {
+				t.Errorf("Do() error = %v, want it to wrap the last failure", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Do() made %d attempts, want %d", calls, tt.wantCalls)
+			}
+			if want := min(calls, policy.Attempts) - 1; !tt.wantErr && retries != want {
+				t.Errorf("OnRetry called %d times, want %d", retries, want)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Do(ctx, Policy{Attempts: 5, InitialDelay: time.Hour}, func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("network unreachable")
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do() = %v after %d attempts, want context.Canceled after 1 attempt", err, calls)
+	}
+}
+
+func TestDoBoundsAttempts(t *testing.T) {
+	policy := Policy{Attempts: 2, AttemptTimeout: 10 * time.Millisecond}
+	calls := 0
+	err := Do(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 2 {
+		t.Errorf("Do() = %v after %d attempts, want a deadline after 2 attempts", err, calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := Policy{InitialDelay: 4 * time.Second, MaxDelay: 30 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: 4 * time.Second, 2: 8 * time.Second, 3: 16 * time.Second, 4: 30 * time.Second, 10: 30 * time.Second} {
+		for range 20 {
+			if got := Backoff(policy, attempt); got < want/2 || got > want {
+				t.Fatalf("Backoff(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+			}
+		}
+	}
+	if got := Backoff(Policy{}, 3); got != 0 {
+		t.Errorf("Backoff() without delays = %v, want 0", got)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	restore := SetDefault(Policy{Attempts: 9})
+	if got := Default().Attempts; got != 9 {
+		t.Errorf("Default().Attempts = %d, want 9", got)
+	}
+	restore()
+	if got := Default().Attempts; got != DefaultAttempts {
+		t.Errorf("Default().Attempts after restore = %d, want %d", got, DefaultAttempts)
+	}
+}
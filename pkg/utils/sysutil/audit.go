@@ -0,0 +1,127 @@
+package sysutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of changes reported to the audit sink
+const (
+	AuditKindCommand = "command" // external command changing the host
+	AuditKindService = "service" // systemctl command changing a service
+	AuditKindFile    = "file"    // file written through the fsutil helpers
+)
+
+// AuditEvent is a change the agent made, or tried to make, to the host
+type AuditEvent struct {
+	Kind     string
+	Command  string      // command line with secrets redacted, for commands and services
+	Service  string      // units a service command acts on
+	Path     string      // file written
+	Mode     os.FileMode // mode of the file written
+	SHA256   string      // SHA-256 of the content written
+	ExitCode int
+	Duration time.Duration
+	Err      error
+}
+
+// AuditSink receives the changes the agent makes to the host
+type AuditSink interface {
+	Record(event AuditEvent)
+}
+
+// auditSink receives audit events for file writes; nil disables auditing
+var (
+	auditSink   AuditSink
+	auditSinkMu sync.RWMutex
+)
+
+// EnableAudit reports every external command that changes the host, and every file written through the fsutil
+// helpers, to the sink. Commands that only inspect the host are not reported.
+func EnableAudit(sink AuditSink) {
+	auditSinkMu.Lock()
+	auditSink = sink
+	auditSinkMu.Unlock()
+	if sink != nil {
+		SetCommandRunner(&AuditRunner{next: GetCommandRunner(), sink: sink})
+	}
+}
+
+func getAuditSink() AuditSink {
+	auditSinkMu.RLock()
+	defer auditSinkMu.RUnlock()
+	return auditSink
+}
+
+// AuditRunner reports every command changing the host and its outcome to a sink after running it with another
+// runner
+type AuditRunner struct {
+	next CommandRunner
+	sink AuditSink
+}
+
+// Run runs the command with the wrapped runner and reports it unless it only inspects the host
+func (r *AuditRunner) Run(ctx context.Context, cmd Command) (*CommandResult, error) {
+	result, err := r.next.Run(ctx, cmd)
+	if IsInspectCommand(cmd.Name, cmd.Args) {
+		return result, err
+	}
+
+	event := AuditEvent{
+		Kind:    AuditKindCommand,
+		Command: strings.TrimSpace(cmd.Name + " " + strings.Join(RedactArgs(cmd.Args), " ")),
+		Err:     err,
+	}
+	if result != nil {
+		event.ExitCode = result.ExitCode
+		event.Duration = result.Duration
+	}
+	if cmd.Name == "systemctl" {
+		event.Kind = AuditKindService
+		event.Service = strings.Join(serviceUnits(cmd.Args), " ")
+	}
+	r.sink.Record(event)
+	return result, err
+}
+
+// serviceUnits returns the units of a systemctl command line, the arguments after the verb that are not flags
+func serviceUnits(args []string) []string {
+	var units []string
+	verbSeen := false
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if verbSeen {
+			units = append(units, arg)
+		}
+		verbSeen = true
+	}
+	return units
+}
+
+// AuditFileWrite reports a file write and its outcome, with the SHA-256 of the content, when auditing is enabled.
+// Nothing is reported while a DryRunRunner is installed, which records the commands writing system files instead
+// of running them.
+func AuditFileWrite(filename string, data []byte, perm os.FileMode, err error) {
+	sink := getAuditSink()
+	if sink == nil {
+		return
+	}
+	if _, dryRun := GetCommandRunner().(*DryRunRunner); dryRun {
+		return
+	}
+	sum := sha256.Sum256(data)
+	sink.Record(AuditEvent{
+		Kind:   AuditKindFile,
+		Path:   filename,
+		Mode:   perm,
+		SHA256: hex.EncodeToString(sum[:]),
+		Err:    err,
+	})
+}
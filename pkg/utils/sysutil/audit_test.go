@@ -0,0 +1,52 @@
+package sysutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type recordingSink struct {
+	events []AuditEvent
+}
+
+func (s *recordingSink) Record(event AuditEvent) {
+	s.events = append(s.events, event)
+}
+
+type failingRunner struct{}
+
+func (failingRunner) Run(context.Context, Command) (*CommandResult, error) {
+	return &CommandResult{ExitCode: 5}, errors.New("exit status 5")
+}
+
+func TestAuditRunner(t *testing.T) {
+	sink := &recordingSink{}
+	runner := &AuditRunner{next: &stubRunner{result: &CommandResult{}}, sink: sink}
+	ctx := context.Background()
+
+	_, _ = runner.Run(ctx, Command{Name: "systemctl", Args: []string{"is-active", "kubelet"}})
+	_, _ = runner.Run(ctx, Command{Name: "azcmagent", Args: []string{"connect", "--access-token", "secret-token"}})
+	_, _ = runner.Run(ctx, Command{Name: "systemctl", Args: []string{"restart", "--no-block", "containerd", "kubelet"}})
+
+	if len(sink.events) != 2 {
+		t.Fatalf("recorded %d events, want 2 without the inspecting systemctl is-active: %+v", len(sink.events), sink.events)
+	}
+	if got, want := sink.events[0].Command, "azcmagent connect --access-token "+redactedValue; got != want {
+		t.Errorf("command = %q, want %q", got, want)
+	}
+	if sink.events[0].Kind != AuditKindCommand {
+		t.Errorf("kind = %q, want %q", sink.events[0].Kind, AuditKindCommand)
+	}
+	if service := sink.events[1]; service.Kind != AuditKindService || service.Service != "containerd kubelet" {
+		t.Errorf("systemctl restart recorded as %q of %q, want a service event of containerd kubelet", service.Kind, service.Service)
+	}
+
+	failing := &AuditRunner{next: failingRunner{}, sink: sink}
+	if _, err := failing.Run(ctx, Command{Name: "mount", Args: []string{"--bind", "/a", "/b"}}); err == nil {
+		t.Fatal("AuditRunner swallowed the command error")
+	}
+	if event := sink.events[2]; event.Err == nil || event.ExitCode != 5 {
+		t.Errorf("failed command recorded as %+v, want its error and exit code 5", event)
+	}
+}
@@ -0,0 +1,228 @@
+package sysutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCommandTimeout bounds how long a single external command may run
+	DefaultCommandTimeout = 10 * time.Minute
+
+	// DefaultMaxCommandOutput caps how much output of a failed command is attached to its error
+	DefaultMaxCommandOutput = 64 * 1024
+)
+
+// Command describes an external command to execute
+type Command struct {
+	Name    string
+	Args    []string
+	Timeout time.Duration // zero uses the runner default
+	Stream  bool          // also stream output to the agent's stdout/stderr
+}
+
+// CommandResult holds the outcome of an executed command
+type CommandResult struct {
+	Output   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// CommandError is returned when a command fails, carrying the tail of its captured output for context.
+// Arguments are deliberately not part of the message as they may contain secrets.
+type CommandError struct {
+	Name     string
+	Output   string
+	Duration time.Duration
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("command %s failed after %v: %v", e.Name, e.Duration.Round(time.Millisecond), e.Err)
+	if output := strings.TrimSpace(e.Output); output != "" {
+		msg += fmt.Sprintf(", output: %s", output)
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// CommandRunner executes external commands
+type CommandRunner interface {
+	Run(ctx context.Context, cmd Command) (*CommandResult, error)
+}
+
+// ExecRunner runs commands on the host with sudo handling, timeouts and output capture. The result always holds
+// the complete output, callers parse it; only the copy attached to a CommandError is capped to MaxOutputBytes.
+type ExecRunner struct {
+	DefaultTimeout time.Duration
+	MaxOutputBytes int
+}
+
+// NewExecRunner creates an ExecRunner with default timeout and output limits
+func NewExecRunner() *ExecRunner {
+	return &ExecRunner{
+		DefaultTimeout: DefaultCommandTimeout,
+		MaxOutputBytes: DefaultMaxCommandOutput,
+	}
+}
+
+// Run executes the command and records its duration in the command metrics
+func (r *ExecRunner) Run(ctx context.Context, cmd Command) (*CommandResult, error) {
+	timeout := cmd.Timeout
+	if timeout <= 0 {
+		timeout = r.DefaultTimeout
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output := &outputBuffer{}
+	execCmd := createCommand(timeoutCtx, cmd.Name, cmd.Args)
+	if cmd.Stream {
+		execCmd.Stdout = io.MultiWriter(os.Stdout, output)
+		execCmd.Stderr = io.MultiWriter(os.Stderr, output)
+	} else {
+		execCmd.Stdout = output
+		execCmd.Stderr = output
+	}
+
+	startTime := time.Now()
+	err := execCmd.Run()
+	result := &CommandResult{
+		Output:   output.String(),
+		ExitCode: execCmd.ProcessState.ExitCode(),
+		Duration: time.Since(startTime),
+	}
+	recordCommandMetric(cmd.Name, result.Duration, err)
+
+	if err != nil {
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("timed out after %v: %w", timeout, err)
+		}
+		return result, &CommandError{Name: cmd.Name, Output: tail(result.Output, r.MaxOutputBytes), Duration: result.Duration, Err: err}
+	}
+	return result, nil
+}
+
+// commandRunner is the runner used by the package-level command helpers
+var (
+	commandRunner   CommandRunner = NewExecRunner()
+	commandRunnerMu sync.RWMutex
+)
+
+// SetCommandRunner replaces the runner used by the package-level command helpers.
+// It returns a function restoring the previous runner, which is mainly useful for tests.
+func SetCommandRunner(runner CommandRunner) func() {
+	commandRunnerMu.Lock()
+	defer commandRunnerMu.Unlock()
+	previous := commandRunner
+	commandRunner = runner
+	return func() {
+		commandRunnerMu.Lock()
+		defer commandRunnerMu.Unlock()
+		commandRunner = previous
+	}
+}
+
+// GetCommandRunner returns the runner used by the package-level command helpers
+func GetCommandRunner() CommandRunner {
+	commandRunnerMu.RLock()
+	defer commandRunnerMu.RUnlock()
+	return commandRunner
+}
+
+// RunCommandContext executes a command with context cancellation and returns its captured output
+func RunCommandContext(ctx context.Context, name string, args ...string) (string, error) {
+	result, err := GetCommandRunner().Run(ctx, Command{Name: name, Args: args})
+	if result == nil {
+		return "", err
+	}
+	return result.Output, err
+}
+
+// RunSystemCommandContext executes a command with context cancellation, streaming its output to the console
+func RunSystemCommandContext(ctx context.Context, name string, args ...string) error {
+	_, err := GetCommandRunner().Run(ctx, Command{Name: name, Args: args, Stream: true})
+	return err
+}
+
+// CommandMetric aggregates execution statistics for a single command name
+type CommandMetric struct {
+	Name          string        `json:"name"`
+	Count         int           `json:"count"`
+	Failures      int           `json:"failures"`
+	TotalDuration time.Duration `json:"totalDuration"`
+	MaxDuration   time.Duration `json:"maxDuration"`
+}
+
+var (
+	commandMetrics   = map[string]*CommandMetric{}
+	commandMetricsMu sync.Mutex
+)
+
+// recordCommandMetric records the duration and outcome of an executed command
+func recordCommandMetric(name string, duration time.Duration, err error) {
+	commandMetricsMu.Lock()
+	defer commandMetricsMu.Unlock()
+
+	metric, ok := commandMetrics[name]
+	if !ok {
+		metric = &CommandMetric{Name: name}
+		commandMetrics[name] = metric
+	}
+	metric.Count++
+	if err != nil {
+		metric.Failures++
+	}
+	metric.TotalDuration += duration
+	metric.MaxDuration = max(metric.MaxDuration, duration)
+}
+
+// GetCommandMetrics returns a snapshot of command execution statistics sorted by command name
+func GetCommandMetrics() []CommandMetric {
+	commandMetricsMu.Lock()
+	defer commandMetricsMu.Unlock()
+
+	metrics := make([]CommandMetric, 0, len(commandMetrics))
+	for _, metric := range commandMetrics {
+		metrics = append(metrics, *metric)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
+
+// outputBuffer collects the output of a command, which may write stdout and stderr concurrently
+type outputBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *outputBuffer) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// tail returns the last limit bytes of output, marked as truncated when anything was cut
+func tail(output string, limit int) string {
+	if limit <= 0 || len(output) <= limit {
+		return output
+	}
+	return "[truncated] ..." + output[len(output)-limit:]
+}
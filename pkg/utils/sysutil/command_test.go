@@ -0,0 +1,81 @@
+package sysutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	if got := tail("hello", 5); got != "hello" {
+		t.Errorf("tail() at the limit = %q, want the output unchanged", got)
+	}
+	if got := tail("hello world", 5); got != "[truncated] ...world" {
+		t.Errorf("tail() over the limit = %q, want the marked tail", got)
+	}
+}
+
+func TestExecRunner(t *testing.T) {
+	runner := NewExecRunner()
+
+	t.Run("captures output", func(t *testing.T) {
+		result, err := runner.Run(context.Background(), Command{Name: "echo", Args: []string{"hello"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.TrimSpace(result.Output) != "hello" {
+			t.Errorf("expected output 'hello', got %q", result.Output)
+		}
+	})
+
+	t.Run("attaches output to errors", func(t *testing.T) {
+		result, err := runner.Run(context.Background(), Command{Name: "sh", Args: []string{"-c", "echo boom >&2; exit 3"}})
+		var cmdErr *CommandError
+		if !errors.As(err, &cmdErr) {
+			t.Fatalf("expected CommandError, got %v", err)
+		}
+		if !strings.Contains(cmdErr.Error(), "boom") {
+			t.Errorf("expected error to contain command output, got %q", cmdErr.Error())
+		}
+		if result.ExitCode != 3 {
+			t.Errorf("expected exit code 3, got %d", result.ExitCode)
+		}
+	})
+
+	t.Run("keeps the complete output", func(t *testing.T) {
+		capped := &ExecRunner{DefaultTimeout: DefaultCommandTimeout, MaxOutputBytes: 16}
+		result, err := capped.Run(context.Background(), Command{Name: "sh", Args: []string{"-c", "seq 1000; exit 1"}})
+		if len(strings.Fields(result.Output)) != 1000 {
+			t.Errorf("expected all 1000 lines of output, got %d bytes", len(result.Output))
+		}
+		var cmdErr *CommandError
+		if !errors.As(err, &cmdErr) || !strings.HasPrefix(cmdErr.Output, "[truncated]") || !strings.HasSuffix(cmdErr.Output, "1000\n") {
+			t.Errorf("expected the error to carry the marked tail of the output, got %v", err)
+		}
+	})
+
+	t.Run("enforces timeout", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), Command{Name: "sleep", Args: []string{"5"}, Timeout: 100 * time.Millisecond})
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("expected timeout error, got %v", err)
+		}
+	})
+}
+
+func TestCommandMetrics(t *testing.T) {
+	recordCommandMetric("metric-test", time.Second, nil)
+	recordCommandMetric("metric-test", 3*time.Second, errors.New("failed"))
+
+	for _, metric := range GetCommandMetrics() {
+		if metric.Name != "metric-test" {
+			continue
+		}
+		if metric.Count != 2 || metric.Failures != 1 || metric.MaxDuration != 3*time.Second || metric.TotalDuration != 4*time.Second {
+			t.Errorf("unexpected metric values: %+v", metric)
+		}
+		return
+	}
+	t.Error("metric-test not found in command metrics")
+}
@@ -0,0 +1,103 @@
+package sysutil
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// inspectCommands lists the commands, by name and leading subcommand words, that only inspect the host.
+// A nil list means every invocation of the command inspects.
+var inspectCommands = map[string][]string{
+	"systemctl":            {"is-active", "is-enabled", "is-failed", "status", "show", "cat", "list-units", "list-unit-files"},
+	"kubectl":              {"get", "auth can-i", "version", "cluster-info"},
+	"az":                   {"account show", "account get-access-token", "version"},
+	"azcmagent":            {"show", "version", "check"},
+	"ctr":                  {"version", "namespaces list", "namespaces ls", "containers list", "containers ls", "tasks list", "tasks ls"},
+	"rpm":                  {"-q"},
+	"dpkg":                 {"-s", "-l", "--status", "--list"},
+	"dpkg-query":           nil,
+	"journalctl":           nil,
+	"cat":                  nil,
+	"ls":                   nil,
+	"uname":                nil,
+	"which":                nil,
+	"findmnt":              nil,
+	"lsmod":                nil,
+	"iptables-save":        nil,
+	"iptables-nft-save":    nil,
+	"iptables-legacy-save": nil,
+	"nvidia-smi":           nil,
+}
+
+// IsInspectCommand reports whether a command only inspects the host, such as querying a service state or
+// printing the version of a binary, so it is safe to run during a dry run
+func IsInspectCommand(name string, args []string) bool {
+	if slices.Contains(args, "--version") || (len(args) > 0 && args[0] == "version") {
+		return true
+	}
+	subcommands, ok := inspectCommands[name]
+	if !ok {
+		return false
+	}
+	if subcommands == nil {
+		return true
+	}
+	raw := strings.Join(args, " ")
+	stripped := strings.Join(withoutLeadingFlags(args), " ")
+	for _, subcommand := range subcommands {
+		for _, line := range []string{raw, stripped} {
+			if line == subcommand || strings.HasPrefix(line, subcommand+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withoutLeadingFlags drops the flags before the first subcommand, e.g. "--kubeconfig <path>" of kubectl.
+// A flag without "=" is assumed to take the next argument as its value.
+func withoutLeadingFlags(args []string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if !strings.Contains(args[0], "=") && len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	return args
+}
+
+// DryRunRunner runs the commands that only inspect the host with the wrapped runner and records every other
+// command instead of running it, reporting success with no output. It lets steps check the host during a
+// dry run without changing it.
+type DryRunRunner struct {
+	next     CommandRunner
+	mu       sync.Mutex
+	recorded []string
+}
+
+// NewDryRunRunner creates a DryRunRunner delegating the commands that inspect the host to next
+func NewDryRunRunner(next CommandRunner) *DryRunRunner {
+	return &DryRunRunner{next: next}
+}
+
+// Run runs an inspecting command and records any other command, with secrets redacted
+func (r *DryRunRunner) Run(ctx context.Context, cmd Command) (*CommandResult, error) {
+	if IsInspectCommand(cmd.Name, cmd.Args) {
+		return r.next.Run(ctx, cmd)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, strings.TrimSpace(cmd.Name+" "+strings.Join(RedactArgs(cmd.Args), " ")))
+	return &CommandResult{}, nil
+}
+
+// Drain returns the commands recorded since the last call
+func (r *DryRunRunner) Drain() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded := r.recorded
+	r.recorded = nil
+	return recorded
+}
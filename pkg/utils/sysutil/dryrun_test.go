@@ -0,0 +1,55 @@
+package sysutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIsInspectCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{name: "systemctl", args: []string{"is-active", "kubelet"}, want: true},
+		{name: "systemctl", args: []string{"restart", "kubelet"}, want: false},
+		{name: "kubectl", args: []string{"--kubeconfig", "/var/lib/kubelet/kubeconfig", "get", "node", "edge-1"}, want: true},
+		{name: "kubectl", args: []string{"--kubeconfig=/tmp/admin", "auth", "can-i", "create", "csr"}, want: true},
+		{name: "kubectl", args: []string{"--kubeconfig", "/tmp/admin", "label", "node", "edge-1", "a=b"}, want: false},
+		{name: "ctr", args: []string{"namespaces", "list", "-q"}, want: true},
+		{name: "ctr", args: []string{"namespaces", "remove", "k8s.io"}, want: false},
+		{name: "rpm", args: []string{"-q", "conntrack-tools"}, want: true},
+		{name: "/usr/bin/runc", args: []string{"--version"}, want: true},
+		{name: "journalctl", args: []string{"-u", "kubelet"}, want: true},
+		{name: "mkdir", args: []string{"-p", "/etc/kubernetes"}, want: false},
+		{name: "bash", args: []string{"-c", "systemctl is-active kubelet"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := IsInspectCommand(tt.name, tt.args); got != tt.want {
+			t.Errorf("IsInspectCommand(%s %s) = %v, want %v", tt.name, strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+}
+
+func TestDryRunRunner(t *testing.T) {
+	runner := NewDryRunRunner(&stubRunner{result: &CommandResult{Output: "active\n"}})
+
+	result, err := runner.Run(context.Background(), Command{Name: "systemctl", Args: []string{"is-active", "kubelet"}})
+	if err != nil || result.Output != "active\n" {
+		t.Errorf("Run() of an inspecting command = %+v, %v, want the wrapped runner's result", result, err)
+	}
+
+	result, err = runner.Run(context.Background(), Command{Name: "azcmagent", Args: []string{"connect", "--access-token", "tok"}})
+	if err != nil || result.Output != "" {
+		t.Errorf("Run() of a changing command = %+v, %v, want an empty success", result, err)
+	}
+
+	recorded := runner.Drain()
+	if len(recorded) != 1 || recorded[0] != "azcmagent connect --access-token "+redactedValue {
+		t.Errorf("Drain() = %q, want the redacted azcmagent command only", recorded)
+	}
+	if again := runner.Drain(); len(again) != 0 {
+		t.Errorf("second Drain() = %q, want nothing", again)
+	}
+}
@@ -0,0 +1,51 @@
+package sysutil
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// RunSystemCommand executes a system command with sudo when needed for privileged operations
+// Output is streamed to the console and also attached to the returned error on failure
+func RunSystemCommand(name string, args ...string) error {
+	return RunSystemCommandContext(context.Background(), name, args...)
+}
+
+// RunCommandWithOutput executes a command and returns output with sudo when needed
+func RunCommandWithOutput(name string, args ...string) (string, error) {
+	return RunCommandContext(context.Background(), name, args...)
+}
+
+// BinaryExists checks if a binary exists in PATH using 'which' command
+func BinaryExists(binaryName string) bool {
+	_, err := RunCommandWithOutput("which", binaryName)
+	return err == nil
+}
+
+// GetArc retrieves the system architecture in a format matching reference scripts
+func GetArc() (string, error) {
+	// Windows has no uname, release archives name the Go architecture there
+	if runtime.GOOS == "windows" {
+		return runtime.GOARCH, nil
+	}
+
+	// Get architecture using same logic as reference script
+	arch, err := RunCommandWithOutput("uname", "-m")
+	if err != nil {
+		return "", fmt.Errorf("failed to get architecture: %w", err)
+	}
+	arch = strings.TrimSpace(arch)
+
+	// Map architecture names to match reference script logic
+	switch arch {
+	case "armv7l", "armv7":
+		arch = "arm"
+	case "aarch64":
+		arch = "arm64"
+	case "x86_64":
+		arch = "amd64"
+	}
+	return arch, nil
+}
@@ -0,0 +1,48 @@
+package sysutil
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// sudoCommandLists holds the command lists for sudo determination
+var (
+	alwaysNeedsSudo = []string{"apt", "apt-get", "dpkg", "tdnf", "dnf", "yum", "zypper", "shred", "systemctl", "mount", "umount", "modprobe", "sysctl", "swapoff", "swapon", "blkid", "mkfs.ext4", "mkfs.xfs", "azcmagent", "usermod", "kubectl", "ctr",
+		"iptables-nft-save", "iptables-legacy-save", "update-alternatives", "alternatives"}
+	conditionalSudo = []string{"mkdir", "cp", "chmod", "chown", "mv", "tar", "rm", "bash", "install", "ln", "cat", "ls"}
+	systemPaths     = []string{"/etc/", "/usr/", "/var/", "/opt/", "/boot/", "/sys/"}
+)
+
+// RequiresSudo determines if a command needs sudo based on command name and arguments
+func RequiresSudo(name string, args []string) bool {
+	// Check if this command always needs sudo
+	if slices.Contains(alwaysNeedsSudo, name) {
+		return true
+	}
+
+	// Check if this command needs sudo based on the paths involved
+	if slices.Contains(conditionalSudo, name) {
+		for _, arg := range args {
+			for _, sysPath := range systemPaths {
+				if strings.HasPrefix(arg, sysPath) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// createCommand creates an exec.Cmd bound to ctx with appropriate sudo handling
+func createCommand(ctx context.Context, name string, args []string) *exec.Cmd {
+	if RequiresSudo(name, args) && os.Geteuid() != 0 {
+		allArgs := append([]string{"-E", name}, args...)
+		return exec.CommandContext(ctx, "sudo", allArgs...)
+	}
+	// Run directly (either doesn't need sudo or already running as root)
+	return exec.CommandContext(ctx, name, args...)
+}
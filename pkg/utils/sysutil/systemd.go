@@ -0,0 +1,178 @@
+package sysutil
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ServiceManager controls the services of the host's init system. Installers depend on it rather than on
+// systemctl directly, so their tests can substitute a fake.
+type ServiceManager interface {
+	IsActive(name string) bool
+	Exists(name string) bool
+	Stop(name string) error
+	Disable(name string) error
+	EnableAndStart(name string) error
+	Restart(name string) error
+	Reload() error
+	WaitForActive(ctx context.Context, name string, timeout time.Duration, logger *logrus.Logger) error
+}
+
+// Systemd manages systemd services with systemctl through the package command runner
+type Systemd struct{}
+
+var _ ServiceManager = Systemd{}
+
+// IsActive checks if a systemd service is active
+func (Systemd) IsActive(name string) bool {
+	output, err := RunCommandWithOutput("systemctl", "is-active", name)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(output) == "active"
+}
+
+// Exists checks if a systemd service unit file exists
+func (Systemd) Exists(name string) bool {
+	err := RunSystemCommand("systemctl", "list-unit-files", name+".service")
+	return err == nil
+}
+
+// Stop stops a systemd service
+func (Systemd) Stop(name string) error {
+	return RunSystemCommand("systemctl", "stop", name)
+}
+
+// Disable disables a systemd service
+func (Systemd) Disable(name string) error {
+	return RunSystemCommand("systemctl", "disable", name)
+}
+
+// EnableAndStart enables and starts a systemd service
+func (Systemd) EnableAndStart(name string) error {
+	return RunSystemCommand("systemctl", "enable", "--now", name)
+}
+
+// Restart restarts a systemd service
+func (Systemd) Restart(name string) error {
+	return RunSystemCommand("systemctl", "restart", name)
+}
+
+// Reload reloads systemd daemon configuration
+func (Systemd) Reload() error {
+	return RunSystemCommand("systemctl", "daemon-reload")
+}
+
+// WaitForActive waits until a systemd service is active or timeout occurs
+func (Systemd) WaitForActive(ctx context.Context, name string, timeout time.Duration, logger *logrus.Logger) error {
+	logger.Debugf("Waiting for service %s to be active (timeout: %v)", name, timeout)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			if ctx.Err() != nil {
+				return fmt.Errorf("stopped waiting for service %s to start: %w", name, ctx.Err())
+			}
+			return fmt.Errorf("timeout waiting for service %s to start", name)
+		case <-ticker.C:
+			// Check if service is active
+			if err := RunSystemCommandContext(timeoutCtx, "systemctl", "is-active", name); err == nil {
+				logger.Debugf("Service %s is active", name)
+				return nil
+			}
+
+			// Log current status for debugging
+			if output, err := RunCommandWithOutput("systemctl", "status", name); err == nil {
+				logger.Debugf("Service %s status: %s", name, output)
+			}
+		}
+	}
+}
+
+// serviceManager is the service manager used by the package-level service helpers
+var (
+	serviceManager   = defaultServiceManager()
+	serviceManagerMu sync.RWMutex
+)
+
+// defaultServiceManager returns the service manager of the host's init system: the Windows service control
+// manager on Windows and systemd everywhere else
+func defaultServiceManager() ServiceManager {
+	if runtime.GOOS == "windows" {
+		return WindowsServices{}
+	}
+	return Systemd{}
+}
+
+// SetServiceManager replaces the service manager used by the package-level service helpers.
+// It returns a function restoring the previous manager, which is mainly useful for tests.
+func SetServiceManager(manager ServiceManager) func() {
+	serviceManagerMu.Lock()
+	defer serviceManagerMu.Unlock()
+	previous := serviceManager
+	serviceManager = manager
+	return func() {
+		serviceManagerMu.Lock()
+		defer serviceManagerMu.Unlock()
+		serviceManager = previous
+	}
+}
+
+// GetServiceManager returns the service manager used by the package-level service helpers
+func GetServiceManager() ServiceManager {
+	serviceManagerMu.RLock()
+	defer serviceManagerMu.RUnlock()
+	return serviceManager
+}
+
+// IsServiceActive checks if a service is active
+func IsServiceActive(serviceName string) bool {
+	return GetServiceManager().IsActive(serviceName)
+}
+
+// ServiceExists checks if a service unit file exists
+func ServiceExists(serviceName string) bool {
+	return GetServiceManager().Exists(serviceName)
+}
+
+// StopService stops a service
+func StopService(serviceName string) error {
+	return GetServiceManager().Stop(serviceName)
+}
+
+// DisableService disables a service
+func DisableService(serviceName string) error {
+	return GetServiceManager().Disable(serviceName)
+}
+
+// EnableAndStartService enables and starts a service
+func EnableAndStartService(serviceName string) error {
+	return GetServiceManager().EnableAndStart(serviceName)
+}
+
+// RestartService restarts a service
+func RestartService(serviceName string) error {
+	return GetServiceManager().Restart(serviceName)
+}
+
+// ReloadSystemd reloads systemd daemon configuration
+func ReloadSystemd() error {
+	return GetServiceManager().Reload()
+}
+
+// WaitForService waits until a service is active or timeout occurs
+func WaitForService(ctx context.Context, serviceName string, timeout time.Duration, logger *logrus.Logger) error {
+	return GetServiceManager().WaitForActive(ctx, serviceName, timeout, logger)
+}
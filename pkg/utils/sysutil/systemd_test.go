@@ -0,0 +1,140 @@
+package sysutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recordingRunner records the command lines it runs and answers systemctl is-active with active
+type recordingRunner struct {
+	commands []string
+}
+
+func (r *recordingRunner) Run(_ context.Context, cmd Command) (*CommandResult, error) {
+	line := strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+	r.commands = append(r.commands, line)
+	if line == "systemctl is-active kubelet" {
+		return &CommandResult{Output: "active\n"}, nil
+	}
+	if line == "systemctl is-active containerd" {
+		return &CommandResult{Output: "inactive\n", ExitCode: 3}, errors.New("exit status 3")
+	}
+	return &CommandResult{}, nil
+}
+
+func TestSystemd(t *testing.T) {
+	runner := &recordingRunner{}
+	t.Cleanup(SetCommandRunner(runner))
+
+	var services ServiceManager = Systemd{}
+	if !services.IsActive("kubelet") || services.IsActive("containerd") {
+		t.Error("IsActive() did not follow systemctl is-active")
+	}
+	_ = services.Stop("kubelet")
+	_ = services.EnableAndStart("kubelet")
+	_ = services.Reload()
+
+	want := []string{
+		"systemctl is-active kubelet",
+		"systemctl is-active containerd",
+		"systemctl stop kubelet",
+		"systemctl enable --now kubelet",
+		"systemctl daemon-reload",
+	}
+	if strings.Join(runner.commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands = %q, want %q", runner.commands, want)
+	}
+}
+
+// scRunner records the command lines it runs and answers sc.exe query like a host running kubelet only
+type scRunner struct {
+	commands []string
+}
+
+func (r *scRunner) Run(_ context.Context, cmd Command) (*CommandResult, error) {
+	line := strings.Join(append([]string{cmd.Name}, cmd.Args...), " ")
+	r.commands = append(r.commands, line)
+	switch line {
+	case "sc.exe query kubelet":
+		return &CommandResult{Output: "SERVICE_NAME: kubelet\n        STATE              : 4  RUNNING\n"}, nil
+	case "sc.exe query containerd":
+		return &CommandResult{Output: "SERVICE_NAME: containerd\n        STATE              : 1  STOPPED\n"}, nil
+	case "sc.exe query kube-proxy":
+		return &CommandResult{Output: "[SC] EnumQueryServicesStatus:OpenService FAILED 1060", ExitCode: 1060}, errors.New("exit status 1060")
+	}
+	return &CommandResult{}, nil
+}
+
+func TestWindowsServices(t *testing.T) {
+	runner := &scRunner{}
+	t.Cleanup(SetCommandRunner(runner))
+
+	var services ServiceManager = WindowsServices{}
+	if !services.IsActive("kubelet") || services.IsActive("containerd") {
+		t.Error("IsActive() did not follow the sc.exe query state")
+	}
+	if !services.Exists("containerd") || services.Exists("kube-proxy") {
+		t.Error("Exists() did not follow sc.exe query")
+	}
+	runner.commands = nil
+	_ = services.EnableAndStart("containerd")
+	_ = services.EnableAndStart("kubelet")
+	_ = services.Reload()
+
+	want := []string{
+		"sc.exe config containerd start= auto",
+		"sc.exe query containerd",
+		"sc.exe start containerd",
+		"sc.exe config kubelet start= auto",
+		"sc.exe query kubelet",
+	}
+	if strings.Join(runner.commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands = %q, want %q", runner.commands, want)
+	}
+}
+
+// fakeServices records the services restarted through the package-level helpers
+type fakeServices struct {
+	Systemd
+	restarted []string
+}
+
+func (f *fakeServices) Restart(name string) error {
+	f.restarted = append(f.restarted, name)
+	return nil
+}
+
+func TestSetServiceManager(t *testing.T) {
+	fake := &fakeServices{}
+	restore := SetServiceManager(fake)
+	if err := RestartService("kubelet"); err != nil {
+		t.Fatalf("RestartService() unexpected error: %v", err)
+	}
+	restore()
+	if len(fake.restarted) != 1 || fake.restarted[0] != "kubelet" {
+		t.Errorf("restarted = %v, want kubelet", fake.restarted)
+	}
+	if _, ok := GetServiceManager().(Systemd); !ok {
+		t.Errorf("restore did not reinstate the systemd service manager, got %T", GetServiceManager())
+	}
+}
+
+func TestRequiresSudo(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{name: "systemctl", args: []string{"status", "kubelet"}, want: true},
+		{name: "mkdir", args: []string{"-p", "/etc/kubernetes"}, want: true},
+		{name: "mkdir", args: []string{"-p", "/tmp/aks-flex-node"}, want: false},
+		{name: "uname", args: []string{"-m"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := RequiresSudo(tt.name, tt.args); got != tt.want {
+			t.Errorf("RequiresSudo(%s %v) = %v, want %v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
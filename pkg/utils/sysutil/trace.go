@@ -0,0 +1,107 @@
+package sysutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// redactedValue replaces secrets in traced command lines
+const redactedValue = "***REDACTED***"
+
+// sensitiveFlags are command line flags whose value is a secret
+var sensitiveFlags = map[string]bool{
+	"--access-token":               true,
+	"--service-principal-secret":   true,
+	"--client-secret":              true,
+	"--password":                   true,
+	"--token":                      true,
+	"--secret":                     true,
+	"--service-principal-password": true,
+}
+
+// sensitiveKeyPattern matches key=value arguments whose value is a secret, e.g. client_secret=... in curl form data
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)^(.*(secret|password|token)[a-z_-]*)=(.+)$`)
+
+// bearerTokenPattern matches JSON web tokens and bearer authorization headers passed as arguments
+var bearerTokenPattern = regexp.MustCompile(`(?i)(^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.)|(authorization:\s*bearer\s)`)
+
+// traceLogger receives trace messages for external commands and file writes; nil disables tracing
+var (
+	traceLogger   *logrus.Logger
+	traceLoggerMu sync.RWMutex
+)
+
+// EnableTracing logs every external command and every file written through the fsutil helpers to the logger
+// at trace level. Command arguments holding secrets are redacted and command output is never logged.
+// A nil logger stops tracing file writes; commands keep being traced by the runner installed earlier.
+func EnableTracing(logger *logrus.Logger) {
+	traceLoggerMu.Lock()
+	traceLogger = logger
+	traceLoggerMu.Unlock()
+	if logger != nil {
+		SetCommandRunner(&TracingRunner{next: GetCommandRunner(), logger: logger})
+	}
+}
+
+func getTraceLogger() *logrus.Logger {
+	traceLoggerMu.RLock()
+	defer traceLoggerMu.RUnlock()
+	return traceLogger
+}
+
+// TracingRunner logs every command it runs, with secrets redacted, before delegating to another runner
+type TracingRunner struct {
+	next   CommandRunner
+	logger *logrus.Logger
+}
+
+// Run logs the command line and its outcome around running it with the wrapped runner
+func (r *TracingRunner) Run(ctx context.Context, cmd Command) (*CommandResult, error) {
+	r.logger.Tracef("exec: %s %s", cmd.Name, strings.Join(RedactArgs(cmd.Args), " "))
+	result, err := r.next.Run(ctx, cmd)
+	if result != nil {
+		r.logger.Tracef("exec: %s exited with code %d after %v", cmd.Name, result.ExitCode, result.Duration)
+	} else if err != nil {
+		r.logger.Tracef("exec: %s failed to start: %v", cmd.Name, err)
+	}
+	return result, err
+}
+
+// RedactArgs returns a copy of the command arguments with secrets replaced, for logging
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case i > 0 && sensitiveFlags[args[i-1]]:
+			redacted[i] = redactedValue
+		case bearerTokenPattern.MatchString(arg):
+			redacted[i] = redactedValue
+		default:
+			if flag, _, found := strings.Cut(arg, "="); found && sensitiveFlags[flag] {
+				redacted[i] = flag + "=" + redactedValue
+			} else if match := sensitiveKeyPattern.FindStringSubmatch(arg); match != nil {
+				redacted[i] = match[1] + "=" + redactedValue
+			} else {
+				redacted[i] = arg
+			}
+		}
+	}
+	return redacted
+}
+
+// TraceFileWrite logs a file write with the SHA-256 of its content when tracing is enabled
+func TraceFileWrite(filename string, data []byte, perm os.FileMode) {
+	logger := getTraceLogger()
+	if logger == nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	logger.Tracef("write: %s (%d bytes, mode %o, sha256 %s)", filename, len(data), perm, hex.EncodeToString(sum[:]))
+}
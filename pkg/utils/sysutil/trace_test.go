@@ -0,0 +1,98 @@
+package sysutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"connect", "--resource-group", "rg", "--access-token", "secret-token"},
+			want: []string{"connect", "--resource-group", "rg", "--access-token", redactedValue},
+		},
+		{
+			args: []string{"login", "--client-secret=hunter2"},
+			want: []string{"login", "--client-secret=" + redactedValue},
+		},
+		{
+			args: []string{"-d", "client_secret=hunter2", "-d", "grant_type=client_credentials"},
+			want: []string{"-d", "client_secret=" + redactedValue, "-d", "grant_type=client_credentials"},
+		},
+		{
+			args: []string{"-H", "Authorization: Bearer abc", "https://management.azure.com"},
+			want: []string{"-H", redactedValue, "https://management.azure.com"},
+		},
+		{
+			args: []string{"eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJ4In0.c2ln"},
+			want: []string{redactedValue},
+		},
+		{
+			args: []string{"mkdir", "-p", "/etc/kubernetes"},
+			want: []string{"mkdir", "-p", "/etc/kubernetes"},
+		},
+	}
+	for _, tt := range tests {
+		got := RedactArgs(tt.args)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("RedactArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+type stubRunner struct {
+	result *CommandResult
+}
+
+func (r *stubRunner) Run(context.Context, Command) (*CommandResult, error) {
+	return r.result, nil
+}
+
+func TestTracingRunner(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.TraceLevel)
+	runner := &TracingRunner{next: &stubRunner{result: &CommandResult{Output: "secret output"}}, logger: logger}
+
+	if _, err := runner.Run(context.Background(), Command{Name: "azcmagent", Args: []string{"connect", "--access-token", "tok"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	logged := strings.Join(messages, "\n")
+	if !strings.Contains(logged, "exec: azcmagent connect --access-token "+redactedValue) {
+		t.Errorf("expected redacted command line in trace, got:\n%s", logged)
+	}
+	if strings.Contains(logged, "tok\n") || strings.Contains(logged, "secret output") {
+		t.Errorf("trace leaked the token or command output:\n%s", logged)
+	}
+}
+
+func TestTraceFileWrite(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.TraceLevel)
+	TraceFileWrite("/etc/untraced.yaml", []byte("hello"), 0o644)
+
+	t.Cleanup(SetCommandRunner(GetCommandRunner()))
+	EnableTracing(logger)
+	t.Cleanup(func() { EnableTracing(nil) })
+	TraceFileWrite("/etc/kubernetes/config.yaml", []byte("hello"), 0o644)
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one trace entry once tracing is enabled, got %d", len(entries))
+	}
+	want := "write: /etc/kubernetes/config.yaml (5 bytes, mode 644, sha256 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824)"
+	if entries[0].Message != want {
+		t.Errorf("trace = %q, want %q", entries[0].Message, want)
+	}
+}
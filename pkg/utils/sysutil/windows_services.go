@@ -0,0 +1,87 @@
+package sysutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WindowsServices manages Windows services with sc.exe through the package command runner
+type WindowsServices struct{}
+
+var _ ServiceManager = WindowsServices{}
+
+// IsActive checks if a Windows service is running
+func (WindowsServices) IsActive(name string) bool {
+	output, err := RunCommandWithOutput("sc.exe", "query", name)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(output, "RUNNING")
+}
+
+// Exists checks if a Windows service is registered
+func (WindowsServices) Exists(name string) bool {
+	_, err := RunCommandWithOutput("sc.exe", "query", name)
+	return err == nil
+}
+
+// Stop stops a Windows service and waits until it has stopped
+func (WindowsServices) Stop(name string) error {
+	return RunSystemCommand("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Stop-Service -Force -Name "+name)
+}
+
+// Disable keeps a Windows service from starting with the machine
+func (WindowsServices) Disable(name string) error {
+	return RunSystemCommand("sc.exe", "config", name, "start=", "disabled")
+}
+
+// EnableAndStart starts a Windows service with the machine and now
+func (s WindowsServices) EnableAndStart(name string) error {
+	if err := RunSystemCommand("sc.exe", "config", name, "start=", "auto"); err != nil {
+		return err
+	}
+	if s.IsActive(name) {
+		return nil
+	}
+	return RunSystemCommand("sc.exe", "start", name)
+}
+
+// Restart restarts a Windows service
+func (WindowsServices) Restart(name string) error {
+	return RunSystemCommand("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Restart-Service -Force -Name "+name)
+}
+
+// Reload does nothing, the service control manager reads service configuration when a service starts
+func (WindowsServices) Reload() error {
+	return nil
+}
+
+// WaitForActive waits until a Windows service is running or timeout occurs
+func (s WindowsServices) WaitForActive(ctx context.Context, name string, timeout time.Duration, logger *logrus.Logger) error {
+	logger.Debugf("Waiting for service %s to be running (timeout: %v)", name, timeout)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			if ctx.Err() != nil {
+				return fmt.Errorf("stopped waiting for service %s to start: %w", name, ctx.Err())
+			}
+			return fmt.Errorf("timeout waiting for service %s to start", name)
+		case <-ticker.C:
+			if s.IsActive(name) {
+				logger.Debugf("Service %s is running", name)
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpuSet  string
+		want    []int
+		wantErr bool
+	}{
+		{name: "single CPU", cpuSet: "3", want: []int{3}},
+		{name: "ranges and singles", cpuSet: "0-2,8,10-11", want: []int{0, 1, 2, 8, 10, 11}},
+		{name: "sysfs trailing newline", cpuSet: "0-3\n", want: []int{0, 1, 2, 3}},
+		{name: "overlapping ranges are de-duplicated", cpuSet: "4,0-2,1-4", want: []int{0, 1, 2, 3, 4}},
+		{name: "empty element", cpuSet: "0,,2", wantErr: true},
+		{name: "reversed range", cpuSet: "3-1", wantErr: true},
+		{name: "not a number", cpuSet: "a-b", wantErr: true},
+		{name: "negative CPU", cpuSet: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCPUSet(tt.cpuSet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCPUSet(%q) error = %v, wantErr %v", tt.cpuSet, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCPUSet(%q) = %v, want %v", tt.cpuSet, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// Check results
+const (
+	ResultPassed  = "passed"
+	ResultFailed  = "failed"
+	ResultSkipped = "skipped"
+)
+
+// Checks run by the smoke test, in order
+const (
+	CheckNodeReady    = "NodeReady"
+	CheckPodScheduled = "PodScheduled"
+	CheckPodRunning   = "PodRunning"
+	CheckPodNetwork   = "PodNetworkAndDNS"
+)
+
+const (
+	// podNamespace holds the smoke test pod, which is deleted once the checks finish
+	podNamespace = "default"
+
+	// clusterServiceName is resolved through cluster DNS from the smoke test pod.
+	// Resolving it reaches CoreDNS over the pod network and the service network of the cluster.
+	clusterServiceName = "kubernetes.default.svc.cluster.local"
+
+	// DefaultTimeout bounds how long the smoke test pod may take to be scheduled and finish its checks
+	DefaultTimeout = 3 * time.Minute
+)
+
+// pollInterval is how often the smoke test pod is polled
+var pollInterval = 2 * time.Second
+
+// Check is the outcome of one smoke test check
+type Check struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"` // passed, failed or skipped
+	Message string `json:"message"`
+}
+
+// Report is the outcome of the smoke test
+type Report struct {
+	Node   string  `json:"node"`
+	Passed bool    `json:"passed"`
+	Checks []Check `json:"checks"`
+}
+
+// Verifier runs an end-to-end smoke test proving the bootstrapped node can run pods
+type Verifier struct {
+	config  *config.Config
+	logger  *logrus.Logger
+	image   string
+	timeout time.Duration
+
+	// adminKubeconfig writes cluster credentials that can create pods, replaced in tests
+	adminKubeconfig func(ctx context.Context, logger *logrus.Logger) (string, error)
+}
+
+// New creates a Verifier running the smoke test pod from image, or the default verify image when empty
+func New(cfg *config.Config, logger *logrus.Logger, image string, timeout time.Duration) *Verifier {
+	if image == "" {
+		image = defaults.Get().Images.Verify
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Verifier{
+		config:          cfg,
+		logger:          logger,
+		image:           image,
+		timeout:         timeout,
+		adminKubeconfig: kubelet.WriteAdminKubeconfig,
+	}
+}
+
+// Run checks the node is Ready, then schedules a pod on it that resolves a cluster service through
+// cluster DNS. The pod checks are skipped when no cluster credentials that can create pods are available.
+func (v *Verifier) Run(ctx context.Context) *Report {
+	report := &Report{Node: v.config.GetNodeName()}
+	record := func(name, result, format string, args ...any) {
+		report.Checks = append(report.Checks, Check{Name: name, Result: result, Message: fmt.Sprintf(format, args...)})
+	}
+	skipPodChecks := func(format string, args ...any) {
+		for _, name := range []string{CheckPodScheduled, CheckPodRunning, CheckPodNetwork} {
+			record(name, ResultSkipped, format, args...)
+		}
+	}
+
+	if err := v.checkNodeReady(); err != nil {
+		record(CheckNodeReady, ResultFailed, "%v", err)
+		skipPodChecks("node is not Ready")
+		return report
+	}
+	record(CheckNodeReady, ResultPassed, "node %s is Ready", report.Node)
+
+	kubeconfigPath, err := v.adminKubeconfig(ctx, v.logger)
+	if err != nil {
+		skipPodChecks("cluster credentials that can create pods are not available: %v", err)
+		report.Passed = true
+		return report
+	}
+	defer utils.CleanupTempFile(kubeconfigPath)
+
+	podName := "aks-flex-node-verify-" + uuid.NewString()[:8]
+	if err := v.createPod(kubeconfigPath, podName, report.Node); err != nil {
+		record(CheckPodScheduled, ResultFailed, "%v", err)
+		record(CheckPodRunning, ResultSkipped, "pod was not created")
+		record(CheckPodNetwork, ResultSkipped, "pod was not created")
+		return report
+	}
+	defer v.deletePod(kubeconfigPath, podName)
+
+	state := v.waitForPod(ctx, kubeconfigPath, podName)
+	switch {
+	case state.nodeName == "":
+		record(CheckPodScheduled, ResultFailed, "pod %s was not scheduled within %v: %s", podName, v.timeout, state.schedulingMessage)
+		record(CheckPodRunning, ResultSkipped, "pod was not scheduled")
+		record(CheckPodNetwork, ResultSkipped, "pod was not scheduled")
+		return report
+	case state.nodeName != report.Node:
+		record(CheckPodScheduled, ResultFailed, "pod %s was scheduled on %s instead of this node", podName, state.nodeName)
+		record(CheckPodRunning, ResultSkipped, "pod was not scheduled on this node")
+		record(CheckPodNetwork, ResultSkipped, "pod was not scheduled on this node")
+		return report
+	}
+	record(CheckPodScheduled, ResultPassed, "pod %s was scheduled on this node", podName)
+
+	if !state.started {
+		record(CheckPodRunning, ResultFailed, "pod %s did not start within %v, phase %s: %s", podName, v.timeout, state.phase, state.waitingReason)
+		record(CheckPodNetwork, ResultSkipped, "pod did not start")
+		return report
+	}
+	record(CheckPodRunning, ResultPassed, "pod %s reached Running", podName)
+
+	switch state.phase {
+	case "Succeeded":
+		record(CheckPodNetwork, ResultPassed, "pod resolved %s through cluster DNS", clusterServiceName)
+		report.Passed = true
+	case "Failed":
+		record(CheckPodNetwork, ResultFailed, "pod failed to resolve %s through cluster DNS: %s", clusterServiceName, v.podLogs(kubeconfigPath, podName))
+	default:
+		record(CheckPodNetwork, ResultFailed, "pod did not finish resolving %s within %v", clusterServiceName, v.timeout)
+	}
+	return report
+}
+
+// checkNodeReady checks the Ready condition of this node with the kubelet credentials
+func (v *Verifier) checkNodeReady() error {
+	output, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubelet.KubeletKubeconfigPath,
+		"get", "node", v.config.GetNodeName(), "-o", "jsonpath={.status.conditions[?(@.type==\"Ready\")].status}")
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", v.config.GetNodeName(), err)
+	}
+	if status := strings.TrimSpace(output); status != "True" {
+		return fmt.Errorf("node %s Ready condition is %q", v.config.GetNodeName(), status)
+	}
+	return nil
+}
+
+// createPod creates the smoke test pod pinned to this node. It tolerates every taint, so a node
+// tainted for dedicated workloads is still verified.
+func (v *Verifier) createPod(kubeconfigPath, podName, nodeName string) error {
+	manifest, err := json.Marshal(podManifest(podName, nodeName, v.image))
+	if err != nil {
+		return fmt.Errorf("failed to render smoke test pod: %w", err)
+	}
+	manifestFile, err := utils.CreateTempFile("aks-flex-node-verify-*.json", manifest)
+	if err != nil {
+		return err
+	}
+	_ = manifestFile.Close()
+	defer utils.CleanupTempFile(manifestFile.Name())
+
+	v.logger.Infof("Creating smoke test pod %s/%s on node %s", podNamespace, podName, nodeName)
+	if _, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubeconfigPath, "create", "-f", manifestFile.Name()); err != nil {
+		return fmt.Errorf("failed to create smoke test pod %s: %w", podName, err)
+	}
+	return nil
+}
+
+// podManifest returns the smoke test pod, which exits successfully once cluster DNS resolves the API server service
+func podManifest(podName, nodeName, image string) map[string]any {
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]any{
+			"name":      podName,
+			"namespace": podNamespace,
+			"labels":    map[string]string{"app.kubernetes.io/managed-by": "aks-flex-node"},
+		},
+		"spec": map[string]any{
+			"nodeSelector":                 map[string]string{"kubernetes.io/hostname": nodeName},
+			"tolerations":                  []map[string]string{{"operator": "Exists"}},
+			"restartPolicy":                "Never",
+			"automountServiceAccountToken": false,
+			"containers": []map[string]any{{
+				"name":    "verify",
+				"image":   image,
+				"command": []string{"sh", "-c", fmt.Sprintf("for i in 1 2 3 4 5; do nslookup %s && exit 0; sleep 5; done; exit 1", clusterServiceName)},
+			}},
+		},
+	}
+}
+
+// podState is what the smoke test observed of its pod
+type podState struct {
+	nodeName          string
+	phase             string
+	started           bool // the container reached Running, possibly already finished
+	schedulingMessage string
+	waitingReason     string
+}
+
+// waitForPod polls the smoke test pod until it finishes or the timeout expires
+func (v *Verifier) waitForPod(ctx context.Context, kubeconfigPath, podName string) podState {
+	waitCtx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	var state podState
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		output, err := utils.RunCommandContext(waitCtx, "kubectl", "--kubeconfig", kubeconfigPath,
+			"get", "pod", podName, "-n", podNamespace, "-o", "jsonpath="+
+				`{.spec.nodeName}{"|"}{.status.phase}{"|"}`+
+				`{.status.conditions[?(@.type=="PodScheduled")].message}{"|"}`+
+				`{.status.containerStatuses[0].state.waiting.reason}`)
+		if err == nil {
+			fields := strings.Split(strings.TrimSpace(output), "|")
+			for len(fields) < 4 {
+				fields = append(fields, "")
+			}
+			state.nodeName, state.phase = fields[0], fields[1]
+			state.schedulingMessage, state.waitingReason = fields[2], fields[3]
+			if state.phase == "Running" || state.phase == "Succeeded" || state.phase == "Failed" {
+				state.started = true
+			}
+			if state.phase == "Succeeded" || state.phase == "Failed" {
+				return state
+			}
+		} else {
+			v.logger.Debugf("Failed to get smoke test pod %s: %v", podName, err)
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return state
+		case <-ticker.C:
+		}
+	}
+}
+
+// podLogs returns the output of the smoke test pod for failure messages
+func (v *Verifier) podLogs(kubeconfigPath, podName string) string {
+	output, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubeconfigPath, "logs", podName, "-n", podNamespace)
+	if err != nil {
+		return fmt.Sprintf("failed to get pod logs: %v", err)
+	}
+	return strings.TrimSpace(output)
+}
+
+// deletePod removes the smoke test pod without waiting for it to terminate
+func (v *Verifier) deletePod(kubeconfigPath, podName string) {
+	if _, err := utils.RunCommandWithOutput("kubectl", "--kubeconfig", kubeconfigPath,
+		"delete", "pod", podName, "-n", podNamespace, "--ignore-not-found", "--wait=false"); err != nil {
+		v.logger.Warnf("Failed to delete smoke test pod %s: %v", podName, err)
+	}
+}
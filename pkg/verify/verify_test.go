@@ -0,0 +1,167 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// fakeKubectl answers the kubectl commands of the smoke test and records the pods it deletes
+type fakeKubectl struct {
+	ready     string
+	podStates []string // successive answers to the pod status query, the last one repeats
+	logs      string
+	created   bool
+	deleted   bool
+}
+
+func (f *fakeKubectl) Run(_ context.Context, cmd utils.Command) (*utils.CommandResult, error) {
+	if cmd.Name != "kubectl" {
+		return nil, errors.New("unexpected command " + cmd.Name)
+	}
+	switch {
+	case slices.Contains(cmd.Args, "node"):
+		return &utils.CommandResult{Output: f.ready}, nil
+	case slices.Contains(cmd.Args, "create"):
+		f.created = true
+		return &utils.CommandResult{}, nil
+	case slices.Contains(cmd.Args, "logs"):
+		return &utils.CommandResult{Output: f.logs}, nil
+	case slices.Contains(cmd.Args, "delete"):
+		f.deleted = true
+		return &utils.CommandResult{}, nil
+	}
+	state := f.podStates[0]
+	if len(f.podStates) > 1 {
+		f.podStates = f.podStates[1:]
+	}
+	return &utils.CommandResult{Output: state}, nil
+}
+
+func newTestVerifier(t *testing.T, fake *fakeKubectl, credentialsErr error) *Verifier {
+	t.Helper()
+	original := pollInterval
+	pollInterval = time.Millisecond
+	t.Cleanup(func() { pollInterval = original })
+	t.Cleanup(utils.SetCommandRunner(fake))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{}
+	cfg.Node.HostnameOverride = "edge-node-1"
+	v := New(cfg, logger, "busybox", 50*time.Millisecond)
+	v.adminKubeconfig = func(context.Context, *logrus.Logger) (string, error) {
+		if credentialsErr != nil {
+			return "", credentialsErr
+		}
+		return filepath.Join(t.TempDir(), "admin.kubeconfig"), nil
+	}
+	return v
+}
+
+// results returns the result of every check by name
+func results(report *Report) map[string]string {
+	byName := map[string]string{}
+	for _, check := range report.Checks {
+		byName[check.Name] = check.Result
+	}
+	return byName
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name           string
+		fake           *fakeKubectl
+		credentialsErr error
+		wantPassed     bool
+		want           map[string]string
+		wantMessage    string
+	}{
+		{
+			name:       "usable node",
+			fake:       &fakeKubectl{ready: "True", podStates: []string{"|Pending|0/3 nodes are available|", "edge-node-1|Running||", "edge-node-1|Succeeded||"}},
+			wantPassed: true,
+			want:       map[string]string{CheckNodeReady: ResultPassed, CheckPodScheduled: ResultPassed, CheckPodRunning: ResultPassed, CheckPodNetwork: ResultPassed},
+		},
+		{
+			name: "node not Ready",
+			fake: &fakeKubectl{ready: "False"},
+			want: map[string]string{CheckNodeReady: ResultFailed, CheckPodScheduled: ResultSkipped, CheckPodRunning: ResultSkipped, CheckPodNetwork: ResultSkipped},
+		},
+		{
+			name:           "no credentials to create pods",
+			fake:           &fakeKubectl{ready: "True"},
+			credentialsErr: errors.New("authorization failed"),
+			wantPassed:     true,
+			want:           map[string]string{CheckNodeReady: ResultPassed, CheckPodScheduled: ResultSkipped, CheckPodRunning: ResultSkipped, CheckPodNetwork: ResultSkipped},
+		},
+		{
+			name:        "pod not scheduled",
+			fake:        &fakeKubectl{ready: "True", podStates: []string{"|Pending|0/3 nodes are available: node(s) had untolerated taint|"}},
+			want:        map[string]string{CheckNodeReady: ResultPassed, CheckPodScheduled: ResultFailed, CheckPodRunning: ResultSkipped, CheckPodNetwork: ResultSkipped},
+			wantMessage: "untolerated taint",
+		},
+		{
+			name:        "image cannot be pulled",
+			fake:        &fakeKubectl{ready: "True", podStates: []string{"edge-node-1|Pending||ImagePullBackOff"}},
+			want:        map[string]string{CheckNodeReady: ResultPassed, CheckPodScheduled: ResultPassed, CheckPodRunning: ResultFailed, CheckPodNetwork: ResultSkipped},
+			wantMessage: "ImagePullBackOff",
+		},
+		{
+			name:        "cluster DNS unreachable",
+			fake:        &fakeKubectl{ready: "True", podStates: []string{"edge-node-1|Failed||"}, logs: ";; connection timed out; no servers could be reached"},
+			want:        map[string]string{CheckNodeReady: ResultPassed, CheckPodScheduled: ResultPassed, CheckPodRunning: ResultPassed, CheckPodNetwork: ResultFailed},
+			wantMessage: "no servers could be reached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newTestVerifier(t, tt.fake, tt.credentialsErr).Run(context.Background())
+
+			if report.Passed != tt.wantPassed {
+				t.Errorf("Run() passed = %v, want %v: %+v", report.Passed, tt.wantPassed, report.Checks)
+			}
+			if got := results(report); !maps.Equal(got, tt.want) {
+				t.Errorf("Run() checks = %v, want %v", got, tt.want)
+			}
+			if tt.wantMessage != "" && !strings.Contains(failedMessages(report), tt.wantMessage) {
+				t.Errorf("Run() failure messages = %q, want %q", failedMessages(report), tt.wantMessage)
+			}
+			if tt.fake.created && !tt.fake.deleted {
+				t.Error("Run() left the smoke test pod behind")
+			}
+		})
+	}
+}
+
+func failedMessages(report *Report) string {
+	var messages []string
+	for _, check := range report.Checks {
+		if check.Result == ResultFailed {
+			messages = append(messages, check.Message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+func TestPodManifestPinsNodeAndToleratesTaints(t *testing.T) {
+	spec := podManifest("aks-flex-node-verify-1", "edge-node-1", "busybox")["spec"].(map[string]any)
+	if spec["nodeSelector"].(map[string]string)["kubernetes.io/hostname"] != "edge-node-1" {
+		t.Errorf("nodeSelector = %v, want this node", spec["nodeSelector"])
+	}
+	if spec["tolerations"].([]map[string]string)[0]["operator"] != "Exists" {
+		t.Errorf("tolerations = %v, want every taint tolerated", spec["tolerations"])
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/platform"
+	"go.goms.io/aks/AKSFlexNode/pkg/preflight"
+)
+
+// NewPreflightCommand creates the preflight command
+func NewPreflightCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check the machine can run a node before bootstrapping it",
+		Long: "Run the host checks bootstrap starts with: kernel modules br_netfilter and overlay, cgroup v2, swap, " +
+			"time sync, connectivity to Entra ID, Azure Resource Manager, the API server and the download hosts, " +
+			"free disk space, the kubelet port 10250 and root or sudo privileges. " +
+			"Fails when a critical check fails, which also blocks bootstrap unless it runs with --skip-preflight.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %s. Valid values are: table, json", output)
+			}
+			return runPreflight(cmd.Context(), output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+
+	return cmd
+}
+
+// runPreflight runs the host checks and prints them, failing when a critical check failed
+func runPreflight(ctx context.Context, output string) error {
+	if platform.Current().IsWindows() {
+		return fmt.Errorf("host preflight checks are not available on Windows nodes yet")
+	}
+
+	report := preflight.NewHostChecker(logger.GetLoggerFromContext(ctx)).Run(ctx)
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(writer, "CHECK\tRESULT\tCRITICAL\tMESSAGE")
+		for _, check := range report.Checks {
+			_, _ = fmt.Fprintf(writer, "%s\t%s\t%t\t%s\n", check.Name, check.Result, check.Critical, check.Message)
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !report.Passed {
+		return fmt.Errorf("critical host preflight checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/containerd"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/platform"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/fsutil"
+)
+
+// configWatchInterval is how often the daemon checks the configuration file for changes
+const configWatchInterval = 30 * time.Second
+
+// Components the daemon reconfigures when their settings change, containerd first as kubelet runs on it
+const (
+	reconfigureContainerd = "containerd"
+	reconfigureKubelet    = "kubelet"
+)
+
+// nodeSettings are the labels and taints of the node. Kubelet only applies them when the node registers, so the
+// Node object is updated directly instead of restarting kubelet.
+var nodeSettings = []string{"node.labels", "node.taints", "node.managedPrefixes"}
+
+// daemonSettings are read by the daemon before each disruptive action, so changes apply without reconfiguring anything
+var daemonSettings = []string{"agent.maintenanceWindow", "maintenance", "kubernetes.autoUpgrade"}
+
+// kubeletSettings are the settings the kubelet configuration is rendered from
+var kubeletSettings = []string{"node", "azure.servicePrincipal", "azure.workloadIdentity", "azure.keyVault"}
+
+// configReload notices changes to the configuration file and applies a reloaded configuration to the running node,
+// reconfiguring only the components whose settings changed
+type configReload struct {
+	path    string
+	hash    [sha256.Size]byte // hash of the configuration file when it was last read
+	pending map[string]bool   // components whose changed settings were not applied yet
+}
+
+// newConfigReload starts watching the configuration file at path
+func newConfigReload(path string) *configReload {
+	r := &configReload{path: path, pending: map[string]bool{}}
+	r.fileChanged()
+	return r
+}
+
+// fileChanged returns true when the configuration file changed since it was last read. A file that cannot be
+// read counts as unchanged, the configuration is reloaded once it can be read again.
+func (r *configReload) fileChanged() bool {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return false
+	}
+	hash := sha256.Sum256(data)
+	if hash == r.hash {
+		return false
+	}
+	r.hash = hash
+	return true
+}
+
+// plan compares a reloaded configuration with the previous one and records the components to reconfigure.
+// Node labels and taints are applied right away, settings that need a new installation apply at the next bootstrap.
+func (r *configReload) plan(ctx context.Context, previous, current *config.Config) {
+	log := logger.GetLoggerFromContext(ctx)
+	changed, err := config.Diff(previous, current)
+	if err != nil {
+		log.Warnf("Failed to compare the reloaded configuration, changed settings apply at the next bootstrap: %v", err)
+		return
+	}
+	if len(changed) == 0 {
+		log.Info("Reloaded configuration is unchanged")
+		return
+	}
+	log.Infof("Configuration changed: %s", strings.Join(changed, ", "))
+
+	// Nothing runs yet on a node that never registered, its next bootstrap applies everything
+	if platform.Current().IsWindows() || !fsutil.FileExists(kubelet.NodeKubeconfigPath()) {
+		log.Info("Changed settings apply at the next bootstrap")
+		return
+	}
+
+	var deferred []string
+	syncNode := false
+	for _, setting := range changed {
+		switch {
+		case setting == "agent.logLevel":
+			// Applied when the configuration was reloaded
+		case config.SettingIn(setting, daemonSettings...):
+			// Applied at the next daemon check
+		case config.SettingIn(setting, "profiles"):
+			// Applied through the node settings the selected profile changes
+		case config.SettingIn(setting, nodeSettings...):
+			syncNode = true
+		case setting == "containerd.version":
+			deferred = append(deferred, setting)
+		case config.SettingIn(setting, "containerd"):
+			r.pending[reconfigureContainerd] = true
+		case config.SettingIn(setting, kubeletSettings...):
+			r.pending[reconfigureKubelet] = true
+		default:
+			deferred = append(deferred, setting)
+		}
+	}
+	if syncNode {
+		reconcileNode(ctx, current, previous)
+	}
+	if len(deferred) > 0 {
+		log.Infof("Changed settings %s apply at the next bootstrap", strings.Join(deferred, ", "))
+	}
+}
+
+// apply reconfigures and restarts the components with changed settings. Restarts are disruptive, so they wait
+// for the maintenance policy to permit them, and a failed reconfiguration is retried at the next bootstrap check.
+func (r *configReload) apply(ctx context.Context, cfg *config.Config) {
+	if len(r.pending) == 0 {
+		return
+	}
+	log := logger.GetLoggerFromContext(ctx)
+	components := slices.Sorted(maps.Keys(r.pending))
+	if permitted, reason := maintenancePermits(cfg, config.MaintenanceActionReconfigure); !permitted {
+		log.Infof("Reconfiguring %s for the changed configuration waits: %s", strings.Join(components, ", "), reason)
+		return
+	}
+
+	for _, component := range []string{reconfigureContainerd, reconfigureKubelet} {
+		if !r.pending[component] {
+			continue
+		}
+		log.Infof("Reconfiguring %s for the changed configuration", component)
+		if err := reconfigureComponent(ctx, component); err != nil {
+			log.Errorf("Failed to reconfigure %s, retrying at the next bootstrap check: %v", component, err)
+			continue
+		}
+		delete(r.pending, component)
+		log.Infof("Reconfigured %s", component)
+	}
+}
+
+// reconfigureComponent renders the configuration of a component from the active configuration and restarts it
+func reconfigureComponent(ctx context.Context, component string) error {
+	log := logger.GetLoggerFromContext(ctx)
+	switch component {
+	case reconfigureContainerd:
+		return containerd.RepairConfig(log)
+	case reconfigureKubelet:
+		// The installer stops kubelet while switching to the new configuration and restarts it
+		installer := kubelet.NewInstaller(log)
+		if err := installer.Validate(ctx); err != nil {
+			return err
+		}
+		return installer.Execute(ctx)
+	}
+	return nil
+}
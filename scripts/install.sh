@@ -19,6 +19,7 @@ INSTALL_DIR="/usr/local/bin"
 CONFIG_DIR="/etc/aks-flex-node"
 DATA_DIR="/var/lib/aks-flex-node"
 LOG_DIR="/var/log/aks-flex-node"
+CACHE_DIR="/var/cache/aks-flex-node"
 GITHUB_API="https://api.github.com/repos/${REPO}"
 GITHUB_RELEASES="${GITHUB_API}/releases"
 
@@ -333,10 +334,10 @@ setup_directories() {
     log_info "Creating directories..."
 
     # Create directories
-    mkdir -p "$CONFIG_DIR" "$DATA_DIR" "$LOG_DIR"
+    mkdir -p "$CONFIG_DIR" "$DATA_DIR" "$LOG_DIR" "$CACHE_DIR"
     chown root:root "$CONFIG_DIR"
-    chown "$SERVICE_USER:$SERVICE_USER" "$DATA_DIR" "$LOG_DIR"
-    chmod 755 "$CONFIG_DIR" "$DATA_DIR" "$LOG_DIR"
+    chown "$SERVICE_USER:$SERVICE_USER" "$DATA_DIR" "$LOG_DIR" "$CACHE_DIR"
+    chmod 755 "$CONFIG_DIR" "$DATA_DIR" "$LOG_DIR" "$CACHE_DIR"
 
     # Ensure log file can be created with correct permissions
     touch "$LOG_DIR/aks-flex-node.log"
@@ -498,6 +499,7 @@ EOF
     echo "  Configuration: $CONFIG_DIR"
     echo "  Data:          $DATA_DIR"
     echo "  Logs:          $LOG_DIR"
+    echo "  Cache:         $CACHE_DIR"
     echo "  Binary:        $INSTALL_DIR/aks-flex-node"
     echo ""
     echo -e "${YELLOW}Uninstall:${NC}"
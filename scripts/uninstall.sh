@@ -18,6 +18,7 @@ INSTALL_DIR="/usr/local/bin"
 CONFIG_DIR="/etc/aks-flex-node"
 DATA_DIR="/var/lib/aks-flex-node"
 LOG_DIR="/var/log/aks-flex-node"
+CACHE_DIR="/var/cache/aks-flex-node"
 
 # Functions
 log_info() {
@@ -47,6 +48,7 @@ confirm_uninstall() {
     echo "• Configuration directory ($CONFIG_DIR)"
     echo "• Data directory ($DATA_DIR)"
     echo "• Log directory ($LOG_DIR)"
+    echo "• Download cache directory ($CACHE_DIR)"
     echo "• Sudo permissions (/etc/sudoers.d/aks-flex-node)"
     echo "• Azure Arc agent and connection"
     echo ""
@@ -186,7 +188,7 @@ remove_directories() {
     log_info "Removing directories..."
 
     # Remove directories
-    for dir in "$CONFIG_DIR" "$DATA_DIR" "$LOG_DIR"; do
+    for dir in "$CONFIG_DIR" "$DATA_DIR" "$LOG_DIR" "$CACHE_DIR"; do
         if [[ -d "$dir" ]]; then
             log_info "Removing directory: $dir"
             rm -rf "$dir"
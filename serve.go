@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/api"
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/buildinfo"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/defaults"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/readiness"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// NewServeCommand creates the serve command
+func NewServeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the orchestration API for site managers",
+		Long: "Serve an authenticated local API that triggers bootstrap, unbootstrap and upgrade, streams step progress " +
+			"and reports node status. Unlike the agent command, nothing runs until it is requested through the API.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Context())
+		},
+	}
+}
+
+// serveConfig holds the configuration API operations run with, an upgrade replaces it with the reloaded file
+type serveConfig struct {
+	mu  sync.Mutex
+	cfg *config.Config
+}
+
+func (s *serveConfig) get() *config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+func (s *serveConfig) set(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
+
+// runServe serves the orchestration API until the agent is stopped, then waits for a running operation to wind down
+func runServe(ctx context.Context) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	logger.Infof("Starting AKS Flex Node Agent %s in serve mode", buildinfo.Get())
+	if err := readiness.RecordAgentStart(time.Now()); err != nil {
+		logger.Warnf("Failed to record agent start: %v", err)
+	}
+
+	if _, err := defaults.Load(); err != nil {
+		return fmt.Errorf("failed to load component defaults: %w", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+	applyCachedSiteTags(ctx, cfg)
+	current := &serveConfig{cfg: cfg}
+
+	operations := map[string]api.OperationFunc{
+		api.OperationBootstrap: func(ctx context.Context) error {
+			return runBootstrapOperation(ctx, current.get())
+		},
+		api.OperationUnbootstrap: func(ctx context.Context) error {
+			result, err := bootstrapper.New(current.get(), logger).Unbootstrap(ctx)
+			if err != nil {
+				return err
+			}
+			return handleExecutionResult(result, "unbootstrap", logger)
+		},
+		// An upgrade picks up the Kubernetes version the orchestrator wrote to the configuration file
+		api.OperationUpgrade: func(ctx context.Context) error {
+			reloaded, err := config.LoadConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to reload config from %s: %w", configPath, err)
+			}
+			applyCachedSiteTags(ctx, reloaded)
+			current.set(reloaded)
+			return runUpgradeOperation(ctx, reloaded)
+		},
+	}
+	statusFunc := func(ctx context.Context) (any, error) {
+		return status.NewCollector(current.get(), logger).CollectStatus(ctx)
+	}
+	server := api.NewServer(operations, statusFunc, status.GetProgressFilePath(), logger)
+
+	listeners, err := apiListeners(cfg.Agent.API)
+	if err != nil {
+		return err
+	}
+	err = api.Serve(ctx, server.Handler(ctx), listeners, logger)
+	server.Wait()
+	return err
+}
+
+// runBootstrapOperation bootstraps the node and records the outcome like the agent does
+func runBootstrapOperation(ctx context.Context, cfg *config.Config) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	startedAt := time.Now()
+	result, err := bootstrapper.New(cfg, logger).Bootstrap(ctx)
+	if err == nil {
+		err = handleExecutionResult(result, "bootstrap", logger)
+	}
+	recordBootstrapOutcome(ctx, startedAt, err)
+	return err
+}
+
+// runUpgradeOperation upgrades kubelet to the configured Kubernetes version like the daemon's automatic upgrades:
+// the node is drained before kubelet restarts and uncordoned afterwards, even when the upgrade fails
+func runUpgradeOperation(ctx context.Context, cfg *config.Config) error {
+	if err := spec.NewCollector(logger.GetLoggerFromContext(ctx)).ResolveKubernetesVersion(ctx); err != nil {
+		return err
+	}
+	return upgradeKubernetes(ctx, cfg, cfg.GetKubernetesVersion())
+}
+
+// apiListeners opens the Unix socket and, when an address is configured, the mutual TLS listener of the API
+func apiListeners(apiConfig *config.APIConfig) ([]net.Listener, error) {
+	if apiConfig == nil {
+		apiConfig = &config.APIConfig{}
+	}
+	socketPath := apiConfig.SocketPath
+	if socketPath == "" {
+		socketPath = api.DefaultSocketPath
+	}
+
+	socket, err := api.ListenUnix(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	listeners := []net.Listener{socket}
+	if apiConfig.Address != "" {
+		tcp, err := api.ListenMutualTLS(apiConfig.Address, apiConfig.TLSCertFile, apiConfig.TLSKeyFile, apiConfig.ClientCAFile)
+		if err != nil {
+			_ = socket.Close()
+			return nil, err
+		}
+		listeners = append(listeners, tcp)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+)
+
+// loadTestConfig loads a configuration for the test cluster with the kubernetes settings given as JSON
+func loadTestConfig(t *testing.T, kubernetes string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	configJSON := `{"azure": {"subscriptionId": "12345678-1234-1234-1234-123456789012", "tenantId": "12345678-1234-1234-1234-123456789012",
+		"cloud": "AzurePublicCloud", "targetCluster": {"location": "eastus",
+		"resourceId": "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"}},
+		"kubernetes": ` + kubernetes + `}`
+	if err := os.WriteFile(path, []byte(configJSON), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return cfg
+}
+
+func TestRunUpgradeOperation(t *testing.T) {
+	originalUpgrade, originalUncordon := upgradeNode, uncordonNode
+	defer func() { upgradeNode, uncordonNode = originalUpgrade, originalUncordon }()
+
+	tests := []struct {
+		name         string
+		result       *bootstrapper.ExecutionResult
+		err          error
+		wantErr      bool
+		wantUncordon bool
+	}{
+		{name: "upgraded", result: &bootstrapper.ExecutionResult{Success: true}},
+		{name: "failed step", result: &bootstrapper.ExecutionResult{Error: "upgrade failed at step KubeletRestarter"}, wantErr: true, wantUncordon: true},
+		{name: "not started", err: errors.New("upgrading Kubernetes is not supported"), wantErr: true, wantUncordon: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t, `{"version": "1.32.7"}`)
+			var upgradedTo string
+			upgradeNode = func(ctx context.Context, cfg *config.Config, version string) (*bootstrapper.ExecutionResult, error) {
+				upgradedTo = version
+				return tt.result, tt.err
+			}
+			uncordoned := false
+			uncordonNode = func(ctx context.Context, logger *logrus.Logger) error {
+				uncordoned = true
+				return nil
+			}
+
+			err := runUpgradeOperation(context.Background(), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runUpgradeOperation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if upgradedTo != "1.32.7" {
+				t.Errorf("upgrade steps ran for version %q, want the configured 1.32.7", upgradedTo)
+			}
+			if uncordoned != tt.wantUncordon {
+				t.Errorf("uncordoned = %v, want %v", uncordoned, tt.wantUncordon)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/agent_service"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+)
+
+// The service unit and sudoers rules the install script downloads, shipped in the binary for install-service
+var (
+	//go:embed aks-flex-node-agent.service
+	serviceUnit []byte
+	//go:embed aks-flex-node-sudoers
+	serviceSudoers []byte
+)
+
+// NewInstallServiceCommand creates the install-service command
+func NewInstallServiceCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "install-service",
+		Short: "Install the agent as a systemd service",
+		Long: "Create the aks-flex-node service user and the directories it writes, install its sudoers rules and " +
+			"the aks-flex-node-agent unit running this binary with the given configuration, and enable the unit",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInstallService(cmd.Context())
+		},
+	}
+}
+
+// NewUninstallServiceCommand creates the uninstall-service command
+func NewUninstallServiceCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall-service",
+		Short: "Remove the agent systemd service",
+		Long:  "Stop and remove the aks-flex-node-agent unit, its sudoers rules and the aks-flex-node service user, without unbootstrapping the node",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUninstallService(cmd.Context())
+		},
+	}
+}
+
+// runInstallService installs and enables the agent service unless it is installed already
+func runInstallService(ctx context.Context) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	installer := agent_service.NewInstaller(logger, agent_service.Files{Unit: serviceUnit, Sudoers: serviceSudoers}, configPath)
+	if err := installer.Validate(ctx); err != nil {
+		return err
+	}
+	if installer.IsCompleted(ctx) {
+		fmt.Println("The agent service is already installed and enabled")
+		return nil
+	}
+	if err := installer.Execute(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Installed and enabled aks-flex-node-agent, start it with: sudo systemctl start aks-flex-node-agent")
+	return nil
+}
+
+// runUninstallService removes the agent service
+func runUninstallService(ctx context.Context) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	uninstaller := agent_service.NewUnInstaller(logger)
+	if uninstaller.IsCompleted(ctx) {
+		fmt.Println("The agent service is not installed")
+		return nil
+	}
+	return uninstaller.Execute(ctx)
+}
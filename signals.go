@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"syscall"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// notifyDaemonSignals registers for the signals handled by the agent daemon:
+// SIGHUP reopens log files and reloads the configuration, SIGUSR1 dumps diagnostics to the log
+func notifyDaemonSignals() chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, daemonSignals...)
+	return signals
+}
+
+// stopDaemonSignals stops relaying daemon signals to the channel
+func stopDaemonSignals(signals chan os.Signal) {
+	signal.Stop(signals)
+}
+
+// handleDaemonSignal handles a daemon signal and returns the configuration to use from now on
+func handleDaemonSignal(ctx context.Context, sig os.Signal, cfg *config.Config, overrides agentOverrides) *config.Config {
+	switch sig {
+	case syscall.SIGHUP:
+		return reloadDaemon(ctx, cfg, overrides)
+	case diagnosticsSignal:
+		dumpDiagnostics(ctx, cfg)
+	}
+	return cfg
+}
+
+// reloadDaemon reopens log files and reloads the configuration file.
+// An invalid configuration is rejected and the current configuration stays active.
+func reloadDaemon(ctx context.Context, cfg *config.Config, overrides agentOverrides) *config.Config {
+	log := logger.GetLoggerFromContext(ctx)
+	log.Info("Received SIGHUP, reopening log files and reloading configuration")
+
+	if err := logger.ReopenLogFiles(); err != nil {
+		log.Errorf("Failed to reopen log files: %v", err)
+	}
+	return reloadConfig(ctx, cfg, overrides)
+}
+
+// reloadConfig reloads the configuration file and returns the configuration to use from now on.
+// An invalid configuration is rejected and the current configuration stays active.
+func reloadConfig(ctx context.Context, cfg *config.Config, overrides agentOverrides) *config.Config {
+	log := logger.GetLoggerFromContext(ctx)
+	reloaded, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Errorf("Configuration reload rejected, keeping current configuration: %v", err)
+		return cfg
+	}
+	overrides.apply(reloaded)
+	applyCachedSiteTags(ctx, reloaded)
+	// The node keeps the version it was bootstrapped with; a newly selected "auto" version is resolved now
+	reloaded.KeepResolvedKubernetesVersion(cfg)
+	if reloaded.IsKubernetesVersionAuto() && reloaded.GetKubernetesVersion() == "" {
+		if err := spec.NewCollector(log).ResolveKubernetesVersion(ctx); err != nil {
+			log.Warnf("Failed to resolve the Kubernetes version of the reloaded configuration: %v", err)
+		}
+	}
+
+	if level, err := logger.ParseLogLevel(reloaded.Agent.LogLevel); err == nil && level != log.GetLevel() {
+		log.SetLevel(level)
+		log.Infof("Log level changed to %s", reloaded.Agent.LogLevel)
+	}
+
+	log.Infof("Configuration reloaded from %s", configPath)
+	return reloaded
+}
+
+// dumpDiagnostics logs the current node status and the stacks of all goroutines for live debugging
+func dumpDiagnostics(ctx context.Context, cfg *config.Config) {
+	log := logger.GetLoggerFromContext(ctx)
+	log.Info("Received SIGUSR1, dumping diagnostics")
+
+	nodeStatus, err := status.NewCollector(cfg, log).CollectStatus(ctx)
+	if err != nil {
+		log.Errorf("Failed to collect status for diagnostics: %v", err)
+	} else if statusData, err := json.MarshalIndent(nodeStatus, "", "  "); err == nil {
+		log.Infof("Current status:\n%s", statusData)
+	}
+
+	var stacks bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&stacks, 2); err != nil {
+		log.Errorf("Failed to dump goroutine stacks: %v", err)
+		return
+	}
+	log.Infof("Goroutine stacks:\n%s", stacks.String())
+}
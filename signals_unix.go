@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// diagnosticsSignal makes the agent daemon dump diagnostics to the log
+var diagnosticsSignal os.Signal = syscall.SIGUSR1
+
+// daemonSignals are the signals the agent daemon handles
+var daemonSignals = []os.Signal{syscall.SIGHUP, diagnosticsSignal}
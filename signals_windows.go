@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// diagnosticsSignal is not delivered on Windows, which has no SIGUSR1
+var diagnosticsSignal os.Signal = nil
+
+// daemonSignals are the signals the agent daemon handles
+var daemonSignals = []os.Signal{syscall.SIGHUP}
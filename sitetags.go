@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/arc"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/sitetags"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils"
+)
+
+// siteTagsRefreshInterval is how often the daemon reads the site settings from the Arc machine tags
+const siteTagsRefreshInterval = 10 * time.Minute
+
+// applyCachedSiteTags merges the site settings last read from the Arc machine tags into a freshly loaded configuration,
+// so they hold before the Arc machine can be reached
+func applyCachedSiteTags(ctx context.Context, cfg *config.Config) {
+	if !cfg.IsSiteTagsEnabled() {
+		return
+	}
+	cached, err := sitetags.Load()
+	if err != nil {
+		logger.GetLoggerFromContext(ctx).Warnf("Failed to load site settings: %v", err)
+		return
+	}
+	if cached != nil {
+		cached.Apply(cfg)
+	}
+}
+
+// refreshSiteTags reads the site settings from the Arc machine tags and returns the configuration to use from now on.
+// When the settings changed, the configuration file is reloaded with them applied and the node labels are updated.
+func refreshSiteTags(ctx context.Context, cfg *config.Config, overrides agentOverrides) *config.Config {
+	log := logger.GetLoggerFromContext(ctx)
+	if !cfg.IsSiteTagsEnabled() {
+		return cfg
+	}
+
+	tags, err := arc.FetchMachineTags(ctx, cfg)
+	if err != nil {
+		log.Warnf("Failed to read site settings from Arc machine tags: %v", err)
+		return cfg
+	}
+	current, warnings := sitetags.Parse(tags)
+	for _, warning := range warnings {
+		log.Warnf("Site settings: %s", warning)
+	}
+
+	previous, err := sitetags.Load()
+	if err != nil {
+		log.Warnf("Failed to load site settings: %v", err)
+	}
+	if current.Equal(previous) {
+		return cfg
+	}
+
+	// Start from the configuration file so settings dropped from the tags fall back to it
+	reloaded, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Errorf("Failed to reload configuration for new site settings, keeping current configuration: %v", err)
+		return cfg
+	}
+	overrides.apply(reloaded)
+	current.Apply(reloaded)
+
+	// Kubelet only applies its node labels at registration, so label the running node directly
+	if utils.FileExists(kubelet.KubeletKubeconfigPath) {
+		// Labels dropped from the tags are removed unless the configuration file sets them
+		var removed []string
+		for _, key := range sitetags.RemovedLabels(previous, current) {
+			if _, ok := reloaded.Node.Labels[key]; !ok {
+				removed = append(removed, key)
+			}
+		}
+		if err := kubelet.ApplyNodeLabels(reloaded, current.NodeLabels(), removed, log); err != nil {
+			log.Warnf("Failed to apply site labels to the node, they apply at the next bootstrap: %v", err)
+		}
+	}
+
+	current.AppliedAt = time.Now().UTC()
+	if err := sitetags.Save(current); err != nil {
+		log.Warnf("Failed to save site settings: %v", err)
+	}
+	log.Infof("Applied site settings from Arc machine tags (site: %q, %d label(s), maintenance window: %t)",
+		current.Site, len(current.Labels), current.MaintenanceWindow != nil)
+	return reloaded
+}
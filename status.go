@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/status"
+)
+
+// NewStatusCommand creates the status command
+func NewStatusCommand() *cobra.Command {
+	var (
+		output string
+		live   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the health of this node",
+		Long: "Print the node health the agent daemon last recorded in its status file: kubelet, containerd and Arc state, " +
+			"component versions and heartbeats. The status is collected live when the file is missing, older than " +
+			status.StaleAfter.String() + " or --live is set.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" && output != "yaml" {
+				return fmt.Errorf("invalid --output %s. Valid values are: table, json, yaml", output)
+			}
+			return runStatus(cmd.Context(), output, live)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table, json or yaml")
+	cmd.Flags().BoolVar(&live, "live", false, "Collect the status now instead of reading the status file")
+
+	return cmd
+}
+
+// runStatus prints the node status from the status file, collecting it live when the file cannot be used
+func runStatus(ctx context.Context, output string, live bool) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
+	statusFilePath := status.FindStatusFile()
+	source := "from " + statusFilePath
+	var nodeStatus *status.NodeStatus
+	if !live {
+		nodeStatus, err = status.ReadStatus(statusFilePath)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Collecting status live, the daemon's status is unavailable: %v\n", err)
+		case nodeStatus.IsStale(time.Now()):
+			fmt.Fprintf(os.Stderr, "Collecting status live, %s was last updated %s ago and the daemon may not be running\n",
+				statusFilePath, time.Since(nodeStatus.LastUpdated).Round(time.Second))
+			nodeStatus = nil
+		}
+	}
+	if nodeStatus == nil {
+		// Collection logs what it finds, keep only its warnings out of the output
+		statusLogger := logrus.New()
+		statusLogger.SetOutput(os.Stderr)
+		statusLogger.SetLevel(logrus.WarnLevel)
+
+		nodeStatus, err = status.NewCollector(cfg, statusLogger).CollectStatus(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to collect node status: %w", err)
+		}
+		source = "collected live"
+	}
+
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(nodeStatus)
+	case "yaml":
+		data, err := yaml.Marshal(nodeStatus)
+		if err != nil {
+			return fmt.Errorf("failed to marshal status to YAML: %w", err)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return writeStatusSummary(os.Stdout, cfg.GetNodeName(), source, nodeStatus, time.Now())
+}
+
+// writeStatusSummary prints the node status as a human-readable summary, one component per line
+func writeStatusSummary(w io.Writer, nodeName, source string, s *status.NodeStatus, now time.Time) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(name, format string, args ...any) {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\n", name, fmt.Sprintf(format, args...))
+	}
+
+	row("Node", "%s", nodeName)
+	row("Updated", "%s (%s)", timeAgo(s.LastUpdated, now), source)
+	row("Agent", "%s", s.AgentVersion)
+	row("Kubelet", "%s, ready: %s, version %s", runningState(s.KubeletRunning), valueOrUnknown(s.KubeletReady), valueOrUnknown(s.KubeletVersion))
+	row("Containerd", "%s, version %s", runningState(s.ContainerdRunning), valueOrUnknown(s.ContainerdVersion))
+	row("Runc", "version %s", valueOrUnknown(s.RuncVersion))
+	if s.ClusterNode != nil && s.ClusterNode.LastHeartbeat != nil {
+		row("Node heartbeat", "%s", timeAgo(*s.ClusterNode.LastHeartbeat, now))
+	}
+
+	if certs := s.KubeletCertificates; certs != nil {
+		if certs.Client != nil {
+			row("Client cert", "%s", certificateExpiry(certs.Client))
+		}
+		if certs.Serving != nil {
+			row("Serving cert", "%s", certificateExpiry(certs.Serving))
+		}
+	}
+
+	arc := "not registered"
+	if s.ArcStatus.Registered {
+		arc = "registered"
+		if s.ArcStatus.Connected {
+			arc = "connected"
+		}
+		if s.ArcStatus.MachineName != "" {
+			arc += ", machine " + s.ArcStatus.MachineName
+		}
+		if !s.ArcStatus.LastHeartbeat.IsZero() {
+			arc += ", last heartbeat " + timeAgo(s.ArcStatus.LastHeartbeat, now)
+		}
+	}
+	row("Arc", "%s", arc)
+
+	if failure := s.BootstrapFailure; failure != nil {
+		row("Bootstrap", "%d consecutive failure(s) since %s, last: %s",
+			failure.ConsecutiveFailures, failure.FirstFailedAt.Format(time.RFC3339), failure.Error)
+	}
+	if len(s.HealthChecks) > 0 {
+		var failing []string
+		for _, result := range s.HealthChecks {
+			if !result.Healthy {
+				failing = append(failing, result.Name)
+			}
+		}
+		checks := fmt.Sprintf("%d of %d healthy", len(s.HealthChecks)-len(failing), len(s.HealthChecks))
+		if len(failing) > 0 {
+			checks += ", failing: " + strings.Join(failing, ", ")
+		}
+		row("Health checks", "%s", checks)
+	}
+	return writer.Flush()
+}
+
+// timeAgo renders a timestamp with its age, e.g. "2026-03-01T10:00:00Z (45s ago)"
+func timeAgo(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), now.Sub(t).Round(time.Second))
+}
+
+// certificateExpiry renders the validity of a kubelet certificate, e.g. "expires 2027-03-01T10:00:00Z (300 days)"
+func certificateExpiry(expiry *kubelet.CertificateExpiry) string {
+	notAfter := expiry.NotAfter.Format(time.RFC3339)
+	switch {
+	case expiry.Expired:
+		return fmt.Sprintf("expired %s", notAfter)
+	case expiry.RotationOverdue:
+		return fmt.Sprintf("expires %s (%d days), rotation overdue", notAfter, expiry.DaysRemaining)
+	}
+	return fmt.Sprintf("expires %s (%d days)", notAfter, expiry.DaysRemaining)
+}
+
+func runningState(running bool) string {
+	if running {
+		return "running"
+	}
+	return "not running"
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
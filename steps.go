@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// stepInfo describes a registered step and whether it is completed on this machine
+type stepInfo struct {
+	Operation   string `json:"operation"`
+	Order       int    `json:"order"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+}
+
+// NewStepsCommand creates the steps command with its subcommands
+func NewStepsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "steps",
+		Short: "Inspect the bootstrap, unbootstrap and upgrade steps",
+	}
+	cmd.AddCommand(newStepsListCommand())
+	return cmd
+}
+
+// newStepsListCommand creates the steps list command
+func newStepsListCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the bootstrap, unbootstrap and upgrade steps",
+		Long:  "List all bootstrap, unbootstrap and upgrade steps in execution order with a description and whether each is completed on this machine",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %s. Valid values are: table, json", output)
+			}
+			return runStepsList(cmd.Context(), output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+
+	return cmd
+}
+
+// runStepsList prints the step catalog and the completion state of every step on this machine
+func runStepsList(ctx context.Context, output string) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
+	// Completion checks log what they find, keep only their warnings out of the listing
+	stepLogger := logrus.New()
+	stepLogger.SetOutput(os.Stderr)
+	stepLogger.SetLevel(logrus.WarnLevel)
+
+	steps := listSteps(ctx, stepOperations(bootstrapper.New(cfg, stepLogger)))
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(steps)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "OPERATION\tORDER\tNAME\tCOMPLETED\tDESCRIPTION")
+	for _, step := range steps {
+		_, _ = fmt.Fprintf(writer, "%s\t%d\t%s\t%t\t%s\n", step.Operation, step.Order, step.Name, step.Completed, step.Description)
+	}
+	return writer.Flush()
+}
+
+// stepOperation is an operation and its steps in execution order
+type stepOperation struct {
+	name  string
+	steps []bootstrapper.Step
+}
+
+// stepOperations returns the bootstrap, unbootstrap and upgrade steps of the bootstrapper
+func stepOperations(b *bootstrapper.Bootstrapper) []stepOperation {
+	return []stepOperation{
+		{name: "bootstrap", steps: b.BootstrapSteps()},
+		{name: "unbootstrap", steps: b.UnbootstrapSteps()},
+		{name: "upgrade", steps: b.UpgradeSteps()},
+	}
+}
+
+// listSteps describes the steps of the operations in execution order, checking whether each is completed.
+// Like a dry run, the checks go through a dry-run runner, so listing never runs commands that change the host.
+func listSteps(ctx context.Context, operations []stepOperation) []stepInfo {
+	defer sysutil.SetCommandRunner(sysutil.NewDryRunRunner(sysutil.GetCommandRunner()))()
+
+	var infos []stepInfo
+	for _, operation := range operations {
+		for i, step := range operation.steps {
+			infos = append(infos, stepInfo{
+				Operation:   operation.name,
+				Order:       i + 1,
+				Name:        step.Executor.GetName(),
+				Description: step.Description,
+				Completed:   step.Executor.IsCompleted(ctx),
+			})
+		}
+	}
+	return infos
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// recordingRunner records the commands it is asked to run
+type recordingRunner struct {
+	commands []string
+}
+
+func (r *recordingRunner) Run(_ context.Context, cmd sysutil.Command) (*sysutil.CommandResult, error) {
+	r.commands = append(r.commands, cmd.Name)
+	return &sysutil.CommandResult{}, nil
+}
+
+// checkingStep checks its completion by running an inspecting command and, wrongly, one changing the host
+type checkingStep struct {
+	name string
+}
+
+func (s *checkingStep) Execute(context.Context) error { return nil }
+func (s *checkingStep) GetName() string               { return s.name }
+func (s *checkingStep) IsCompleted(context.Context) bool {
+	_, inspectErr := sysutil.RunCommandWithOutput("systemctl", "is-active", "kubelet")
+	changeErr := sysutil.RunSystemCommand("systemctl", "restart", "kubelet")
+	return inspectErr == nil && changeErr == nil
+}
+
+func TestListSteps(t *testing.T) {
+	runner := &recordingRunner{}
+	defer sysutil.SetCommandRunner(runner)()
+
+	steps := listSteps(context.Background(), []stepOperation{
+		{name: "bootstrap", steps: []bootstrapper.Step{{Executor: &checkingStep{name: "First"}, Description: "First step"}, {Executor: &checkingStep{name: "Second"}, Description: "Second step"}}},
+		{name: "upgrade", steps: []bootstrapper.Step{{Executor: &checkingStep{name: "Third"}, Description: "Third step"}}},
+	})
+
+	want := []stepInfo{
+		{Operation: "bootstrap", Order: 1, Name: "First", Description: "First step", Completed: true},
+		{Operation: "bootstrap", Order: 2, Name: "Second", Description: "Second step", Completed: true},
+		{Operation: "upgrade", Order: 1, Name: "Third", Description: "Third step", Completed: true},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("listSteps() = %+v, want %+v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i, steps[i], want[i])
+		}
+	}
+	// Only the inspecting systemctl is-active of each step reaches the host
+	if len(runner.commands) != len(want) {
+		t.Errorf("commands run on the host = %q, want only the %d inspecting checks", runner.commands, len(want))
+	}
+	if sysutil.GetCommandRunner() != runner {
+		t.Error("listSteps() did not restore the command runner")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/bootstrapper"
+	"go.goms.io/aks/AKSFlexNode/pkg/compat"
+	"go.goms.io/aks/AKSFlexNode/pkg/components/kubelet"
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/platform"
+	"go.goms.io/aks/AKSFlexNode/pkg/spec"
+	"go.goms.io/aks/AKSFlexNode/pkg/utils/sysutil"
+)
+
+// upgradeRetryInterval is how long the daemon waits before retrying a Kubernetes upgrade that failed, so a
+// failing upgrade does not drain the node at every bootstrap check
+const upgradeRetryInterval = time.Hour
+
+// kubernetesUpgrade remembers the last Kubernetes upgrade that failed
+type kubernetesUpgrade struct {
+	failedVersion string
+	retryAt       time.Time
+}
+
+// checkKubernetesUpgrade upgrades kubelet to the cluster's Kubernetes version when kubernetes.autoUpgrade allows
+// it. The upgrade waits for the maintenance policy to permit it and for the gate health checks to pass.
+func checkKubernetesUpgrade(ctx context.Context, cfg *config.Config, upgrade *kubernetesUpgrade) {
+	logger := logger.GetLoggerFromContext(ctx)
+	if !cfg.IsAutoUpgradeEnabled() || platform.Current().IsWindows() {
+		return
+	}
+
+	installed, err := installedKubeletVersion(ctx)
+	if err != nil {
+		logger.Warnf("Skipping Kubernetes upgrade check: %v", err)
+		return
+	}
+	clusterSpec, err := spec.NewCollector(logger).Collect(ctx)
+	if err != nil {
+		logger.Warnf("Skipping Kubernetes upgrade check, failed to read the cluster version: %v", err)
+		return
+	}
+	target, err := compat.SelectKubeletVersion(clusterSpec.KubernetesVersion)
+	if err != nil {
+		logger.Warnf("Skipping Kubernetes upgrade check: %v", err)
+		return
+	}
+	if target == installed {
+		return
+	}
+	if err := compat.CheckUpgrade(cfg.Kubernetes.AutoUpgrade, installed, target); err != nil {
+		logger.Warnf("Not upgrading kubelet to the cluster's Kubernetes %s: %v", target, err)
+		return
+	}
+
+	now := time.Now()
+	if target == upgrade.failedVersion && now.Before(upgrade.retryAt) {
+		logger.Debugf("Kubernetes upgrade to %s failed before, retrying after %s", target, upgrade.retryAt.Format(time.RFC3339))
+		return
+	}
+	if permitted, reason := maintenancePermits(cfg, config.MaintenanceActionUpgrade); !permitted {
+		logger.Infof("Kubelet %s differs from the cluster's Kubernetes %s, deferring the upgrade: %s", installed, target, reason)
+		return
+	}
+	if failing := failingGateChecks(ctx, cfg); len(failing) > 0 {
+		for _, check := range failing {
+			logger.Warnf("Kubernetes upgrade gated by failing health check %s: %s", check.Name, check.Message)
+		}
+		return
+	}
+
+	logger.Infof("Upgrading kubelet from %s to the cluster's Kubernetes %s", installed, target)
+	if err := upgradeKubernetes(ctx, cfg, target); err != nil {
+		*upgrade = kubernetesUpgrade{failedVersion: target, retryAt: now.Add(upgradeRetryInterval)}
+		logger.Errorf("Kubernetes upgrade to %s failed, retrying after %s: %v", target, upgrade.retryAt.Format(time.RFC3339), err)
+		return
+	}
+	*upgrade = kubernetesUpgrade{}
+}
+
+// Upgrade actions, replaced in tests
+var (
+	upgradeNode = func(ctx context.Context, cfg *config.Config, version string) (*bootstrapper.ExecutionResult, error) {
+		return bootstrapper.New(cfg, logger.GetLoggerFromContext(ctx)).Upgrade(ctx, version)
+	}
+	uncordonNode = kubelet.UncordonAfterUpgrade
+)
+
+// upgradeKubernetes drains the node, upgrades kubelet to version and uncordons the node. A failed upgrade still
+// uncordons the node, which would otherwise stay unschedulable until the upgrade is retried.
+func upgradeKubernetes(ctx context.Context, cfg *config.Config, version string) error {
+	logger := logger.GetLoggerFromContext(ctx)
+	result, err := upgradeNode(ctx, cfg, version)
+	if err == nil {
+		err = handleExecutionResult(result, "upgrade", logger)
+	}
+	if err != nil {
+		if uncordonErr := uncordonNode(ctx, logger); uncordonErr != nil {
+			logger.Warnf("Failed to uncordon the node after the failed upgrade: %v", uncordonErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// installedKubeletVersion returns the version of the installed kubelet binary
+func installedKubeletVersion(ctx context.Context) (string, error) {
+	output, err := sysutil.RunCommandContext(ctx, filepath.Join(platform.Current().Paths.BinDir, "kubelet"), "--version")
+	if err != nil {
+		return "", fmt.Errorf("failed to get kubelet version: %w", err)
+	}
+	// Output looks like "Kubernetes v1.32.7"
+	parts := strings.Fields(strings.TrimSpace(output))
+	if len(parts) < 2 {
+		return "", fmt.Errorf("failed to parse kubelet version from output: %s", output)
+	}
+	return strings.TrimPrefix(parts[1], "v"), nil
+}
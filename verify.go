@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go.goms.io/aks/AKSFlexNode/pkg/config"
+	"go.goms.io/aks/AKSFlexNode/pkg/logger"
+	"go.goms.io/aks/AKSFlexNode/pkg/verify"
+)
+
+// NewVerifyCommand creates the verify command
+func NewVerifyCommand() *cobra.Command {
+	var (
+		output  string
+		image   string
+		timeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the bootstrapped node can run pods",
+		Long: "Run an end-to-end smoke test after bootstrap: check the node is Ready, schedule a test pod on it, " +
+			"wait for it to run and resolve a cluster service through cluster DNS, then delete it. " +
+			"The pod checks are skipped when no cluster credentials that can create pods are available.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %s. Valid values are: table, json", output)
+			}
+			return runVerify(cmd.Context(), output, image, timeout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	cmd.Flags().StringVar(&image, "image", "", "Image of the test pod, which must provide sh and nslookup (default: images.verify of the component defaults)")
+	cmd.Flags().DurationVar(&timeout, "timeout", verify.DefaultTimeout, "How long the test pod may take to be scheduled and finish its checks")
+
+	return cmd
+}
+
+// runVerify runs the smoke test and prints its checks, failing when any check failed
+func runVerify(ctx context.Context, output, image string, timeout time.Duration) error {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config from %s: %w", configPath, err)
+	}
+
+	report := verify.New(cfg, logger.GetLoggerFromContext(ctx), image, timeout).Run(ctx)
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(writer, "CHECK\tRESULT\tMESSAGE")
+		for _, check := range report.Checks {
+			_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", check.Name, check.Result, check.Message)
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !report.Passed {
+		return fmt.Errorf("node %s failed verification", report.Node)
+	}
+	return nil
+}